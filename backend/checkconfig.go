@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/storage"
+	"photobridge/utils"
+)
+
+// runConfigCheck implements "photobridge check-config": validates settings, prints
+// a report and exits non-zero if any check fails. The server is not started.
+func runConfigCheck() {
+	results := config.AppConfig.Check(true)
+
+	// Checks that need packages config can't import
+	if bundle := config.AppConfig.OutboundCABundle; bundle != "" {
+		if _, err := utils.LoadCABundle(bundle); err != nil {
+			results = append(results, config.CheckResult{Name: "OUTBOUND_CA_BUNDLE", Status: config.CheckError, Message: err.Error()})
+		} else {
+			results = append(results, config.CheckResult{Name: "OUTBOUND_CA_BUNDLE", Status: config.CheckOK, Message: bundle})
+		}
+	}
+	if keys, err := utils.LoadJWTKeys(config.AppConfig.JWTAlgorithm, config.AppConfig.JWTPrivateKeyFile, config.AppConfig.JWTVerifyKeyFiles); err != nil {
+		results = append(results, config.CheckResult{Name: "JWT_KEYS", Status: config.CheckError, Message: err.Error()})
+	} else {
+		results = append(results, config.CheckResult{Name: "JWT_KEYS", Status: config.CheckOK, Message: fmt.Sprintf("%s, %d verification key(s) published", keys.Algorithm(), len(keys.JWKS()))})
+	}
+	if geoDB := config.AppConfig.GeoIPDatabasePath; geoDB != "" {
+		if err := utils.InitGeoIP(geoDB); err != nil {
+			results = append(results, config.CheckResult{Name: "GEOIP_DB_PATH", Status: config.CheckError, Message: err.Error()})
+		} else {
+			results = append(results, config.CheckResult{Name: "GEOIP_DB_PATH", Status: config.CheckOK, Message: geoDB})
+		}
+	}
+	if config.AppConfig.StorageBackend == "s3" {
+		results = append(results, checkS3Storage())
+	}
+	if !config.AppConfig.UsesSQLite() && config.AppConfig.DatabaseURL != "" {
+		results = append(results, checkDatabase())
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == config.CheckError {
+			failed++
+		}
+		fmt.Printf("[%-5s] %-24s %s\n", r.Status, r.Name, r.Message)
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d check(s) failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("\nConfiguration OK")
+}
+
+// checkS3Storage verifies that the configured bucket is reachable
+func checkS3Storage() config.CheckResult {
+	backend, err := storage.New(config.AppConfig)
+	if err != nil {
+		return config.CheckResult{Name: "S3_BUCKET", Status: config.CheckError, Message: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := backend.(*storage.S3).Ping(ctx); err != nil {
+		return config.CheckResult{Name: "S3_BUCKET", Status: config.CheckError, Message: err.Error()}
+	}
+	return config.CheckResult{Name: "S3_BUCKET", Status: config.CheckOK, Message: config.AppConfig.S3Bucket + " is reachable"}
+}
+
+// checkDatabase verifies that the PostgreSQL/MySQL database accepts connections
+func checkDatabase() config.CheckResult {
+	dialector, err := database.Dialector(config.AppConfig)
+	if err != nil {
+		return config.CheckResult{Name: "DATABASE_URL", Status: config.CheckError, Message: err.Error()}
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return config.CheckResult{Name: "DATABASE_URL", Status: config.CheckError, Message: err.Error()}
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return config.CheckResult{Name: "DATABASE_URL", Status: config.CheckError, Message: err.Error()}
+	}
+	defer sqlDB.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return config.CheckResult{Name: "DATABASE_URL", Status: config.CheckError, Message: err.Error()}
+	}
+	return config.CheckResult{Name: "DATABASE_URL", Status: config.CheckOK, Message: config.AppConfig.DatabaseDriver + " database is reachable"}
+}
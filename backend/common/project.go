@@ -5,6 +5,16 @@ import (
 	"photobridge/models"
 )
 
+// PhotoMetaColumns selects a photo without its thumbnail blobs
+const PhotoMetaColumns = "id, project_id, base_name, normal_ext, raw_ext, has_raw, file_hash, normal_hash, raw_hash, thumb_width, thumb_height, thumb_params, width, height, normal_size, raw_size, taken_at, album_id, created_at, updated_at"
+
+// PhotoAdminColumns adds the curation fields (rating, caption) the admin panel shows
+const PhotoAdminColumns = PhotoMetaColumns + ", rating, caption"
+
+// PhotoExifColumns selects the stored EXIF summary (models.PhotoExif)
+const PhotoExifColumns = "exif_scanned, exif_camera_make, exif_camera_model, exif_lens_model, exif_software, exif_focal_length, exif_aperture, " +
+	"exif_exposure_time, exif_iso, exif_latitude, exif_longitude, exif_orientation, exif_exposure_mode, exif_white_balance, exif_flash, exif_metering_mode"
+
 // CountPhotosInProject returns the number of photos in a project
 func CountPhotosInProject(projectID uint) int64 {
 	var count int64
@@ -1,8 +1,12 @@
 package common
 
 import (
+	"sort"
+
 	"photobridge/database"
 	"photobridge/models"
+
+	"gorm.io/gorm"
 )
 
 // GetExcludedIDs extracts photo IDs from exclusions
@@ -14,6 +18,215 @@ func GetExcludedIDs(exclusions []models.PhotoExclusion) []uint {
 	return excludedIDs
 }
 
+// GetHighlightIDs returns highlighted photo IDs in display order, skipping excluded photos
+func GetHighlightIDs(highlights []models.PhotoHighlight, excludedIDs []uint) []uint {
+	excluded := make(map[uint]bool, len(excludedIDs))
+	for _, id := range excludedIDs {
+		excluded[id] = true
+	}
+
+	sorted := make([]models.PhotoHighlight, len(highlights))
+	copy(sorted, highlights)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Position < sorted[j].Position })
+
+	ids := make([]uint, 0, len(sorted))
+	for _, h := range sorted {
+		if !excluded[h.PhotoID] {
+			ids = append(ids, h.PhotoID)
+		}
+	}
+	return ids
+}
+
+// SetLinkHighlights replaces a link's highlights with the given photo IDs (in order).
+// Photos outside the link's project and duplicates are dropped, and the list is
+// capped at models.MaxHighlightsPerLink.
+func SetLinkHighlights(link *models.ShareLink, photoIDs []uint) error {
+	if err := database.DB.Where("link_id = ?", link.ID).Delete(&models.PhotoHighlight{}).Error; err != nil {
+		return err
+	}
+	if len(photoIDs) == 0 {
+		return nil
+	}
+
+	var validIDs []uint
+	database.DB.Model(&models.Photo{}).Where("project_id = ? AND id IN ?", link.ProjectID, photoIDs).Pluck("id", &validIDs)
+	valid := make(map[uint]bool, len(validIDs))
+	for _, id := range validIDs {
+		valid[id] = true
+	}
+
+	seen := make(map[uint]bool)
+	position := 0
+	for _, photoID := range photoIDs {
+		if !valid[photoID] || seen[photoID] || position >= models.MaxHighlightsPerLink {
+			continue
+		}
+		seen[photoID] = true
+		if err := database.DB.Create(&models.PhotoHighlight{LinkID: link.ID, PhotoID: photoID, Position: position}).Error; err != nil {
+			return err
+		}
+		position++
+	}
+	return nil
+}
+
+// ApplyExclusionChanges adds and removes exclusions of a link in one transaction.
+// Existing rows are kept (IDs are preserved). Adding an already excluded photo only
+// updates its reason and note, and only when a reason or note is given.
+func ApplyExclusionChanges(linkID uint, add, remove []uint, reason, note string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(remove) > 0 {
+			if err := tx.Where("link_id = ? AND photo_id IN ?", linkID, remove).Delete(&models.PhotoExclusion{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(add) == 0 {
+			return nil
+		}
+
+		var existing []uint
+		if err := tx.Model(&models.PhotoExclusion{}).Where("link_id = ? AND photo_id IN ?", linkID, add).Pluck("photo_id", &existing).Error; err != nil {
+			return err
+		}
+		if len(existing) > 0 && (reason != "" || note != "") {
+			if err := tx.Model(&models.PhotoExclusion{}).Where("link_id = ? AND photo_id IN ?", linkID, existing).
+				Updates(map[string]interface{}{"reason": reason, "note": note}).Error; err != nil {
+				return err
+			}
+		}
+		seen := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			seen[id] = true
+		}
+		for _, photoID := range add {
+			if seen[photoID] {
+				continue
+			}
+			seen[photoID] = true
+			if err := tx.Create(&models.PhotoExclusion{LinkID: linkID, PhotoID: photoID, Reason: reason, Note: note}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceExclusions sets a link's exclusions to exactly photoIDs, only touching rows that change
+func ReplaceExclusions(linkID uint, photoIDs []uint) error {
+	var current []uint
+	if err := database.DB.Model(&models.PhotoExclusion{}).Where("link_id = ?", linkID).Pluck("photo_id", &current).Error; err != nil {
+		return err
+	}
+
+	wanted := make(map[uint]bool, len(photoIDs))
+	for _, id := range photoIDs {
+		wanted[id] = true
+	}
+	var remove []uint
+	for _, id := range current {
+		if !wanted[id] {
+			remove = append(remove, id)
+		}
+	}
+	return ApplyExclusionChanges(linkID, photoIDs, remove, "", "")
+}
+
+// GetRawExcludedIDs returns the set of photos whose RAW file is hidden from a link
+func GetRawExcludedIDs(rawExclusions []models.RawExclusion) map[uint]bool {
+	ids := make(map[uint]bool, len(rawExclusions))
+	for _, e := range rawExclusions {
+		ids[e.PhotoID] = true
+	}
+	return ids
+}
+
+// SetRawExclusions replaces the photos whose RAW file is hidden from a link
+func SetRawExclusions(linkID uint, photoIDs []uint) error {
+	if err := database.DB.Where("link_id = ?", linkID).Delete(&models.RawExclusion{}).Error; err != nil {
+		return err
+	}
+	for _, photoID := range photoIDs {
+		if err := database.DB.Create(&models.RawExclusion{LinkID: linkID, PhotoID: photoID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsRawExcluded checks if only the RAW file of a photo is hidden from a share link
+func IsRawExcluded(linkID uint, photoID uint) bool {
+	var count int64
+	database.DB.Model(&models.RawExclusion{}).Where("link_id = ? AND photo_id = ?", linkID, photoID).Count(&count)
+	return count > 0
+}
+
+// CapturedAtExpr is a photo's EXIF capture time, falling back to upload time
+const CapturedAtExpr = "COALESCE(taken_at, created_at)"
+
+// ApplyDateRange restricts a photo query to the link's date range (if any)
+func ApplyDateRange(query *gorm.DB, link *models.ShareLink) *gorm.DB {
+	column := CapturedAtExpr
+	if link.DateBasis == models.DateBasisUploaded {
+		column = "created_at"
+	}
+	if link.FromDate != nil && !link.FromDate.IsZero() {
+		query = query.Where(column+" >= ?", *link.FromDate)
+	}
+	if link.ToDate != nil && !link.ToDate.IsZero() {
+		query = query.Where(column+" < ?", *link.ToDate)
+	}
+	return query
+}
+
+// FilterDateRange keeps the photo IDs inside the link's date range, preserving order.
+// Photos in the trash (whose highlights are kept for a restore) are dropped as well.
+func FilterDateRange(link *models.ShareLink, photoIDs []uint) []uint {
+	if len(photoIDs) == 0 {
+		return photoIDs
+	}
+	var inRange []uint
+	ApplyDateRange(database.DB.Model(&models.Photo{}).Where("id IN ?", photoIDs), link).Pluck("id", &inRange)
+	keep := make(map[uint]bool, len(inRange))
+	for _, id := range inRange {
+		keep[id] = true
+	}
+	filtered := make([]uint, 0, len(inRange))
+	for _, id := range photoIDs {
+		if keep[id] {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered
+}
+
+// IsPhotoVisible checks that a photo is neither excluded from the link nor outside its date range
+func IsPhotoVisible(link *models.ShareLink, photoID uint) bool {
+	if IsPhotoExcluded(link.ID, photoID) {
+		return false
+	}
+	if !link.HasDateRange() {
+		return true
+	}
+	var count int64
+	ApplyDateRange(database.DB.Model(&models.Photo{}).Where("id = ?", photoID), link).Count(&count)
+	return count > 0
+}
+
+// VisiblePhotoIDs keeps the photo IDs the link shows: photos of its project that are
+// neither excluded nor outside its date range. Order and duplicates are not preserved.
+func VisiblePhotoIDs(link *models.ShareLink, photoIDs []uint) ([]uint, error) {
+	visible := []uint{}
+	if len(photoIDs) == 0 {
+		return visible, nil
+	}
+	excluded := database.DB.Model(&models.PhotoExclusion{}).Select("photo_id").Where("link_id = ?", link.ID)
+	query := database.DB.Model(&models.Photo{}).
+		Where("project_id = ? AND id IN ? AND id NOT IN (?)", link.ProjectID, photoIDs, excluded)
+	err := ApplyDateRange(query, link).Pluck("id", &visible).Error
+	return visible, err
+}
+
 // IsPhotoExcluded checks if a photo is excluded from a share link
 // Returns true if the photo is excluded, false otherwise
 func IsPhotoExcluded(linkID uint, photoID uint) bool {
@@ -21,3 +234,20 @@ func IsPhotoExcluded(linkID uint, photoID uint) bool {
 	database.DB.Model(&models.PhotoExclusion{}).Where("link_id = ? AND photo_id = ?", linkID, photoID).Count(&exclusionCount)
 	return exclusionCount > 0
 }
+
+// ClaimDownload counts a download against the link's download limit. The count is
+// raised in one statement, so concurrent downloads can't exceed the limit; returns
+// false once the limit is reached.
+func ClaimDownload(link *models.ShareLink) (bool, error) {
+	result := database.DB.Model(&models.ShareLink{}).
+		Where("id = ? AND (max_downloads <= 0 OR download_count < max_downloads)", link.ID).
+		UpdateColumn("download_count", gorm.Expr("download_count + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	link.DownloadCount++
+	return true, nil
+}
@@ -0,0 +1,35 @@
+package common
+
+import (
+	"errors"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict means a project or share link changed after the client read it
+// (the If-Match version no longer matches)
+var ErrVersionConflict = errors.New("modified by another request")
+
+// BumpLinkVersion increments the version of a share link before it is changed. With
+// ifVersion set (not 0) the link must still be at that version, so of two tabs
+// editing the same link only the first save wins.
+func BumpLinkVersion(linkID, ifVersion uint) error {
+	query := database.DB.Model(&models.ShareLink{}).Where("id = ?", linkID)
+	if ifVersion != 0 {
+		query = query.Where("version = ?", ifVersion)
+	}
+	result := query.UpdateColumn("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if ifVersion != 0 {
+			return ErrVersionConflict
+		}
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
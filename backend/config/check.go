@@ -0,0 +1,267 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Check result statuses
+const (
+	CheckOK    = "ok"
+	CheckWarn  = "warn"
+	CheckError = "error"
+)
+
+// CheckResult is one line of the configuration check report
+type CheckResult struct {
+	Name    string
+	Status  string
+	Message string
+}
+
+// IsProduction reports whether the server runs in production (ENV=production or DOCKER=true)
+func IsProduction() bool {
+	return os.Getenv("ENV") == "production" || os.Getenv("DOCKER") == "true"
+}
+
+// InsecureSecrets returns the env variables still set to their built-in default values
+func (c *Config) InsecureSecrets() []string {
+	var names []string
+	if c.AdminPassword == defaultAdminPassword {
+		names = append(names, "ADMIN_PASSWORD")
+	}
+	if c.APIKey == defaultAPIKey {
+		names = append(names, "API_KEY")
+	}
+	if c.JWTSecret == defaultJWTSecret {
+		names = append(names, "JWT_SECRET")
+	}
+	return names
+}
+
+// Check validates the loaded configuration without starting the server.
+// checkPort controls whether the TCP listen addresses are probed for availability.
+func (c *Config) Check(checkPort bool) []CheckResult {
+	var results []CheckResult
+	add := func(name, status, format string, args ...interface{}) {
+		results = append(results, CheckResult{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Secrets
+	secretStatus := CheckWarn
+	if IsProduction() {
+		secretStatus = CheckError
+	}
+	if insecure := c.InsecureSecrets(); len(insecure) > 0 {
+		add("secrets", secretStatus, "%v left at insecure default values", insecure)
+	} else {
+		add("secrets", CheckOK, "admin password, API key and JWT secret are set")
+	}
+	if len(c.JWTSecret) < 32 {
+		add("JWT_SECRET", CheckWarn, "shorter than 32 characters")
+	}
+	switch c.JWTAlgorithm {
+	case "HS256":
+	case "RS256", "EDDSA":
+		if c.JWTPrivateKeyFile == "" {
+			add("JWT_PRIVATE_KEY_FILE", CheckError, "required for JWT_ALGORITHM=%s", c.JWTAlgorithm)
+		}
+	default:
+		add("JWT_ALGORITHM", CheckError, "unsupported algorithm %q (use HS256, RS256 or EdDSA)", c.JWTAlgorithm)
+	}
+
+	// Paths
+	for _, dir := range []struct{ name, path string }{
+		{"UPLOAD_DIR", c.UploadDir},
+		{"THUMB_DIR", c.ThumbDir},
+		{"DATABASE_PATH", filepath.Dir(c.DatabasePath)},
+		{"LOG_FILE", filepath.Dir(c.LogFile)},
+		{"UPLOAD_CHUNK_DIR", c.UploadChunkDir},
+	} {
+		if (dir.name == "LOG_FILE" && c.LogFile == "") || (dir.name == "DATABASE_PATH" && !c.UsesSQLite()) {
+			continue
+		}
+		if err := CheckWritableDir(dir.path); err != nil {
+			add(dir.name, CheckError, "%v", err)
+		} else {
+			add(dir.name, CheckOK, "%s is writable", dir.path)
+		}
+	}
+
+	// Listeners
+	if addrs, err := c.ListenAddresses(); err != nil {
+		add("LISTEN", CheckError, "%v", err)
+	} else if checkPort {
+		for _, addr := range addrs {
+			if addr.Network == "unix" || addr.Network == ListenSystemd {
+				continue // Sockets are created or passed at startup
+			}
+			if ln, err := net.Listen(addr.Network, addr.Address); err != nil {
+				add("LISTEN", CheckError, "cannot listen on %s: %v", addr, err)
+			} else {
+				ln.Close()
+				add("LISTEN", CheckOK, "%s is free", addr)
+			}
+		}
+	}
+
+	// URLs
+	if c.CNCDNURL != "" {
+		checkURL(add, "CNCDN_URL", c.CNCDNURL)
+	}
+	countries := make([]string, 0, len(c.CDNRegionURLs))
+	for country := range c.CDNRegionURLs {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	for _, country := range countries {
+		checkURL(add, "CDN_REGION_MAP["+country+"]", c.CDNRegionURLs[country])
+	}
+	if err := c.ValidateCORS(); err != nil {
+		add("CORS", CheckError, "%v", err)
+	} else {
+		add("CORS", CheckOK, "admin: %s; share: %s; other routes: %s",
+			DescribeOrigins(c.CORSOriginsFor(CORSRoutesAdmin)),
+			DescribeOrigins(c.CORSOriginsFor(CORSRoutesShare)),
+			DescribeOrigins(c.CORSOriginsFor(CORSRoutesDefault)))
+	}
+	if c.NotifyWebhookURL != "" {
+		checkURL(add, "NOTIFY_WEBHOOK_URL", c.NotifyWebhookURL)
+	}
+	if c.SMTPHost != "" {
+		switch {
+		case len(c.AdminEmails) == 0:
+			add("ADMIN_EMAIL", CheckWarn, "SMTP_HOST is set but no recipient; no emails are sent")
+		case c.MailFrom() == "":
+			add("SMTP_FROM", CheckError, "required when SMTP_USERNAME is not set")
+		default:
+			add("SMTP", CheckOK, "emails to %s via %s:%d", strings.Join(c.AdminEmails, ", "), c.SMTPHost, c.SMTPPort)
+		}
+	}
+	if c.PublicURL != "" {
+		checkURL(add, "PUBLIC_URL", c.PublicURL)
+	}
+	switch c.LogFormat {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		add("LOG_FORMAT", CheckError, "unknown format %q (use text or json)", c.LogFormat)
+	}
+	switch c.LogLevel {
+	case "", "debug", "info", "warn", "error":
+	default:
+		add("LOG_LEVEL", CheckError, "unknown level %q (use debug, info, warn or error)", c.LogLevel)
+	}
+	switch c.DigestSchedule {
+	case "", DigestWeekly, DigestMonthly, DigestOff:
+	default:
+		add("DIGEST_SCHEDULE", CheckError, "unknown schedule %q (use weekly, monthly or off)", c.DigestSchedule)
+	}
+	switch c.SharePasswordChars {
+	case "", "digits", "alphanumeric":
+	default:
+		add("SHARE_PASSWORD_ALPHABET", CheckError, "unknown alphabet %q (use digits or alphanumeric)", c.SharePasswordChars)
+	}
+	if c.OutboundProxyURL != "" {
+		if u, err := url.Parse(c.OutboundProxyURL); err != nil || u.Host == "" {
+			add("OUTBOUND_PROXY_URL", CheckError, "invalid proxy URL %q", c.OutboundProxyURL)
+		} else {
+			add("OUTBOUND_PROXY_URL", CheckOK, "%s", u.Redacted())
+		}
+	}
+
+	// Optional files
+	for _, file := range []struct{ name, path string }{
+		{"OUTBOUND_CA_BUNDLE", c.OutboundCABundle},
+		{"GEOIP_DB_PATH", c.GeoIPDatabasePath},
+		{"SHARE_CARD_FONT", c.ShareCardFont},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			add(file.name, CheckError, "%v", err)
+		}
+	}
+
+	// Database
+	switch c.DatabaseDriver {
+	case "", "sqlite":
+	case "postgres", "mysql":
+		if c.DatabaseURL == "" {
+			add("DATABASE_URL", CheckError, "required for DATABASE_DRIVER=%s", c.DatabaseDriver)
+		}
+	default:
+		add("DATABASE_DRIVER", CheckError, "unknown driver %q (use sqlite, postgres or mysql)", c.DatabaseDriver)
+	}
+
+	// Storage
+	switch c.StorageBackend {
+	case "", "local":
+	case "s3":
+		if c.S3Bucket == "" || c.S3AccessKeyID == "" || c.S3SecretAccessKey == "" {
+			add("STORAGE_BACKEND", CheckError, "s3 requires S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+		}
+		if c.S3Endpoint != "" {
+			checkURL(add, "S3_ENDPOINT", c.S3Endpoint)
+		}
+	default:
+		add("STORAGE_BACKEND", CheckError, "unknown backend %q (use local or s3)", c.StorageBackend)
+	}
+
+	// Feature combinations
+	if (c.TurnstileSiteKey == "") != (c.TurnstileSecretKey == "") {
+		add("TURNSTILE", CheckError, "TURNSTILE_SITE_KEY and TURNSTILE_SECRET_KEY must be set together")
+	}
+	if c.CDNSignRequired && c.CDNSignKey == "" {
+		add("CDN_SIGN_REQUIRED", CheckError, "requires CDN_SIGN_KEY")
+	}
+	checkConvertCommand(add, "RAW_CONVERT_COMMAND", c.RawConvertCommand)
+	checkConvertCommand(add, "HEIF_CONVERT_COMMAND", c.HEIFConvertCommand)
+
+	return results
+}
+
+// checkConvertCommand checks that a converter command has its placeholders and can be found
+func checkConvertCommand(add func(name, status, format string, args ...interface{}), name, command string) {
+	if command == "" {
+		return
+	}
+	fields := strings.Fields(command)
+	if !strings.Contains(command, "{input}") || !strings.Contains(command, "{output}") {
+		add(name, CheckError, "must contain {input} and {output}")
+	} else if path, err := exec.LookPath(fields[0]); err != nil {
+		add(name, CheckError, "%v", err)
+	} else {
+		add(name, CheckOK, "%s", path)
+	}
+}
+
+// checkURL reports whether value is an absolute http(s) URL
+func checkURL(add func(name, status, format string, args ...interface{}), name, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add(name, CheckError, "not an absolute http(s) URL: %q", value)
+		return
+	}
+	add(name, CheckOK, "%s", u.Redacted())
+}
+
+// CheckWritableDir verifies a directory exists (or can be created) and accepts new files
+func CheckWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, ".photobridge-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
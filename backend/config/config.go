@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -12,51 +13,255 @@ import (
 )
 
 type Config struct {
-	AdminUsername      string
-	AdminPassword      string
-	APIKey             string
-	JWTSecret          string
-	Port               string
-	UploadDir          string
-	DatabasePath       string
-	CNCDNURL           string          // China CDN URL (e.g., https://cdn.pb.jangit.me)
-	cdnIPSet           map[string]bool // CDN server IPs (set for O(1) lookup, only grows)
-	cdnIPMutex         sync.RWMutex    // Protects cdnIPSet
-	TurnstileSiteKey   string          // Cloudflare Turnstile site key (public)
-	TurnstileSecretKey string          // Cloudflare Turnstile secret key (private)
-	ThumbWorkers       int             // Number of thumbnail workers
-	ThumbJobTimeoutSec int             // Per-thumbnail job timeout in seconds
+	AdminUsername       string
+	AdminPassword       string
+	APIKey              string
+	JWTSecret           string
+	JWTAlgorithm        string   // Admin token signing algorithm: HS256 (JWT_SECRET), RS256 or EdDSA
+	JWTPrivateKeyFile   string   // PEM private key for RS256/EdDSA signing
+	JWTVerifyKeyFiles   []string // PEM keys of rotated-out signing keys, still accepted until their tokens expire
+	AdminTokenMinutes   int      // Lifetime of admin access tokens, renewed with the refresh token
+	AdminSessionDays    int      // Admin sessions end after this many days without a refresh
+	Port                string
+	Listen              []string // Bind addresses (host:port, [::]:port, unix:/path, systemd) replacing ":PORT"
+	UploadDir           string
+	ThumbDir            string // Generated thumbnails (<project ID>/<photo ID>_<size>.jpg)
+	DatabasePath        string
+	DatabaseDriver      string            // sqlite (DATABASE_PATH), postgres or mysql (DATABASE_URL)
+	DatabaseURL         string            // DSN of the PostgreSQL/MySQL database
+	StorageBackend      string            // Where originals are stored: local (UPLOAD_DIR) or s3
+	S3Endpoint          string            // S3-compatible endpoint (empty = AWS endpoint of S3Region)
+	S3Region            string            // Signing region ("auto" for Cloudflare R2)
+	S3Bucket            string            // Bucket holding the originals
+	S3Prefix            string            // Key prefix inside the bucket
+	S3AccessKeyID       string            // Credentials for the bucket
+	S3SecretAccessKey   string            // Secret for the bucket
+	S3PathStyle         bool              // Bucket in the URL path instead of the host name (MinIO)
+	S3PresignTTLSeconds int               // Downloads redirect to presigned URLs valid this long (0 = proxied)
+	CNCDNURL            string            // China CDN URL (e.g., https://cdn.pb.jangit.me)
+	CDNRegionURLs       map[string]string // Country code → CDN base URL (CDN_REGION_MAP), overrides CNCDNURL for CN
+	cdnIPSet            map[string]bool   // CDN server IPs (set for O(1) lookup, only grows)
+	cdnIPMutex          sync.RWMutex      // Protects cdnIPSet
+	TurnstileSiteKey    string            // Cloudflare Turnstile site key (public)
+	TurnstileSecretKey  string            // Cloudflare Turnstile secret key (private)
+	TurnstileTimeoutSec int               // Per-attempt timeout for Turnstile verification calls
+	TurnstileMaxRetries int               // Retries for Turnstile verification on network/5xx errors
+	TurnstileFailOpen   bool              // Allow visitors through when Cloudflare is unreachable
+	MemoryLimitMB       int               // Memory available to PhotoBridge (0 = unknown); selects MemoryProfile
+	MemoryProfile       string            // Profile providing the defaults of the memory-related settings (see memory.go)
+	ThumbWorkers        int               // Number of thumbnail workers
+	ThumbPreShrinkPx    int               // Huge images are shrunk to this long side before thumbnailing
+	ThumbSmallWidth     int               // Width of small (grid) thumbnails
+	ThumbLargeWidth     int               // Width of large (preview) thumbnails, above ThumbSmallWidth
+	ThumbSmallQuality   int               // JPEG quality of small thumbnails (1-100)
+	ThumbLargeQuality   int               // JPEG quality of large thumbnails (1-100)
+	PlaceholderCache    int               // RAW placeholder thumbnails cached in memory
+	SQLiteCacheMB       int               // SQLite page cache per connection
+	ThumbJobTimeoutSec  int               // Per-thumbnail job timeout in seconds
+	ThumbWarmLimit      int               // Photos without thumbnails queued at startup (0 = disabled)
+	LinkSweepInterval   int               // Expired share link sweep interval in minutes (0 = disabled)
+	NotifyWebhookURL    string            // Optional webhook for admin notifications (e.g. weekly link summary)
+	LogFormat           string            // Log line format: text (logfmt) or json
+	LogLevel            string            // Least severe level logged: debug, info, warn or error
+	LogFile             string            // Optional log file path (empty = stdout only)
+	LogMaxSizeMB        int               // Rotate log file when it exceeds this size
+	LogMaxBackups       int               // Number of rotated log files to keep (0 = unlimited)
+	LogMaxAgeDays       int               // Delete rotated log files older than this (0 = unlimited)
+	LogRotateHours      int               // Time-based rotation interval in hours (0 = size only)
+	OutboundProxyURL    string            // Explicit proxy for outbound calls (overrides HTTP(S)_PROXY)
+	OutboundCABundle    string            // Extra PEM CA certificates trusted for outbound TLS
+	GeoIPDatabasePath   string            // Optional MaxMind GeoLite2 database for country lookup without Cloudflare
+	HotlinkAllowedHosts []string          // Sites allowed to embed /uploads files (empty = hotlink protection off)
+	HotlinkAllowEmpty   bool              // Allow /uploads requests without Referer/Origin (direct visits, privacy extensions)
+	CORSOrigins         []string          // Origins allowed to call the API cross-origin ("*" = any, "https://*.example.com" = subdomains)
+	CORSAdminOrigins    []string          // Replaces CORSOrigins for /api/admin (empty = CORSOrigins without wildcards)
+	CORSShareOrigins    []string          // Origins allowed in addition on share routes and /uploads
+	CDNSignKey          string            // Secret for signed CDN /uploads URLs (empty = unsigned)
+	CDNSignParam        string            // Query parameter carrying the URL token (must match CDN token-auth config)
+	CDNSignTTLSeconds   int               // Validity of signed URLs after signing
+	CDNSignRequired     bool              // Require signed URLs for every /uploads request, not only CDN pulls
+	ShareCardFont       string            // Optional TTF/OTF/TTC font for share card titles (needed for CJK names)
+	RawPreviewEnabled   bool              // Generate thumbnails of RAW-only photos from the JPEG preview embedded in the RAW
+	RawConvertCommand   string            // External RAW→JPEG converter, e.g. "darktable-cli {input} {output}" (empty = disabled)
+	RawConvertTimeout   int               // Per-conversion timeout in seconds (RAW and HEIF)
+	HEIFConvertCommand  string            // External HEIC/HEIF→JPEG converter, e.g. "heif-convert {input} {output}" (empty = HEIC uploads get no thumbnails)
+	ArchiveSpoolDir     string            // Where prepared download-all archives are kept for resumable downloads
+	ArchiveSpoolMaxMB   int               // Disk budget for prepared archives, least recently used evicted first (0 = disabled)
+	ZipVolumeSizeMB     int               // Download-all archives can be fetched in parts of at most this size (0 = one archive)
+	DownloadJobDir      string            // Where archives prepared by download jobs are written
+	DownloadJobTTL      int               // Minutes a prepared archive is kept for download (0 = download jobs disabled)
+	DownloadJobMaxMB    int               // Disk budget for archives of download jobs, queued ones included (0 = unlimited)
+	NormalizeUploads    bool              // Bake EXIF orientation into uploaded JPEGs and strip their embedded thumbnails
+	NormalizeQuality    int               // JPEG quality used when re-encoding rotated uploads
+	MaxUploadSizeMB     int               // Largest accepted file per upload (0 = unlimited)
+	UploadChunkDir      string            // Where partial files of chunked uploads are assembled
+	UploadSessionTTL    int               // Hours without a chunk after which an upload session is discarded (0 = never)
+	TrashRetentionDays  int               // Days deleted photos and projects can be restored before they are purged (0 = no trash)
+	AccessLogDays       int               // Days share link views and downloads are kept one by one before being rolled up into daily totals (0 = forever)
+	ScanInterval        int               // Minutes between scans of project directories for files copied in directly (0 = disabled)
+	LoginMaxFailures    int               // Failed admin logins (per IP and per username) that lock the login (0 = no backoff or lockout)
+	LoginLockoutMinutes int               // How long too many failed admin logins lock the login
+	DBMonitorInterval   int               // Minutes between SQLite size checks (0 = disabled)
+	WALCheckpointMB     int               // Checkpoint and truncate the WAL once it is larger than this
+	DBSizeAlertMB       int               // Notify when the database (with WAL) exceeds this size (0 = disabled)
+	DBGrowthAlertMB     int               // Notify when the database grows by more than this within a day (0 = disabled)
+	SMTPHost            string            // Mail server for admin emails (empty = no email)
+	SMTPPort            int               // Mail server port (465 = implicit TLS, otherwise STARTTLS when offered)
+	SMTPUsername        string            // SMTP login (empty = no authentication)
+	SMTPPassword        string            // SMTP password
+	SMTPFrom            string            // Sender address (default SMTPUsername)
+	AdminEmails         []string          // Recipients of admin emails such as the digest
+	MailOnSelections    bool              // Email ADMIN_EMAIL when a client submits their selections
+	MailOnZip           bool              // Email ADMIN_EMAIL when a client downloads a full gallery ZIP
+	PublicURL           string            // Base URL of share links in emails (empty = the host the admin panel is opened on)
+	DigestSchedule      string            // Activity digest email: weekly, monthly or off
+	SharePasswordLength int               // Length of generated share passwords
+	SharePasswordChars  string            // Alphabet of generated share passwords: digits or alphanumeric
 }
 
 var AppConfig *Config
 
 const shortname = "[Config]"
 
+// Built-in defaults for credentials; refused in production (see InsecureSecrets)
+const (
+	defaultAdminPassword = "admin123"
+	defaultAPIKey        = "photobridge-api-key"
+	defaultJWTSecret     = "photobridge-jwt-secret"
+)
+
+// Default thumbnail widths (THUMB_SMALL_WIDTH, THUMB_LARGE_WIDTH)
+const (
+	defaultThumbSmallWidth = 400
+	defaultThumbLargeWidth = 1600
+)
+
 func Load() {
 	log.Printf("%s Loading configuration", shortname)
 
 	cdnURL := getEnv("CNCDN_URL", "")
+	memoryLimit := getEnvInt("MEMORY_LIMIT_MB", 0, 0)
+	profile := selectMemoryProfile(memoryLimit)
 
 	AppConfig = &Config{
-		AdminUsername:      getEnv("ADMIN_USERNAME", "admin"),
-		AdminPassword:      getEnv("ADMIN_PASSWORD", "admin123"),
-		APIKey:             getEnv("API_KEY", "photobridge-api-key"),
-		JWTSecret:          getEnv("JWT_SECRET", "photobridge-jwt-secret"),
-		Port:               getEnv("PORT", "8060"),
-		UploadDir:          getEnv("UPLOAD_DIR", "./uploads"),
-		DatabasePath:       getEnv("DATABASE_PATH", "./data/photobridge.db"),
-		CNCDNURL:           cdnURL,                             // Optional China CDN URL
-		cdnIPSet:           make(map[string]bool),              // Initialize CDN IP set
-		TurnstileSiteKey:   getEnv("TURNSTILE_SITE_KEY", ""),   // Optional Turnstile site key
-		TurnstileSecretKey: getEnv("TURNSTILE_SECRET_KEY", ""), // Optional Turnstile secret key
-		ThumbWorkers:       getEnvInt("THUMB_WORKERS", 2, 1),
-		ThumbJobTimeoutSec: getEnvInt("THUMB_JOB_TIMEOUT_SECONDS", 120, 0),
+		AdminUsername:       getEnv("ADMIN_USERNAME", "admin"),
+		AdminPassword:       getEnv("ADMIN_PASSWORD", defaultAdminPassword),
+		APIKey:              getEnv("API_KEY", defaultAPIKey),
+		JWTSecret:           getEnv("JWT_SECRET", defaultJWTSecret),
+		JWTAlgorithm:        strings.ToUpper(getEnv("JWT_ALGORITHM", "HS256")),
+		JWTPrivateKeyFile:   getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTVerifyKeyFiles:   parseList(getEnv("JWT_VERIFY_KEY_FILES", "")),
+		AdminTokenMinutes:   getEnvInt("ADMIN_TOKEN_MINUTES", 15, 1),
+		AdminSessionDays:    getEnvInt("ADMIN_SESSION_DAYS", 30, 1),
+		Port:                getEnv("PORT", "8060"),
+		Listen:              parseList(getEnv("LISTEN", "")),
+		UploadDir:           getEnv("UPLOAD_DIR", "./uploads"),
+		ThumbDir:            getEnv("THUMB_DIR", "./data/thumbs"),
+		DatabasePath:        getEnv("DATABASE_PATH", "./data/photobridge.db"),
+		DatabaseDriver:      strings.ToLower(getEnv("DATABASE_DRIVER", "sqlite")),
+		DatabaseURL:         getEnv("DATABASE_URL", ""),
+		StorageBackend:      strings.ToLower(getEnv("STORAGE_BACKEND", "local")),
+		S3Endpoint:          getEnv("S3_ENDPOINT", ""),
+		S3Region:            getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:            getEnv("S3_BUCKET", ""),
+		S3Prefix:            getEnv("S3_PREFIX", ""),
+		S3AccessKeyID:       getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretAccessKey:   getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3PathStyle:         getEnvBool("S3_PATH_STYLE", os.Getenv("S3_ENDPOINT") != ""),
+		S3PresignTTLSeconds: getEnvInt("S3_PRESIGN_TTL_SECONDS", 900, 0),
+		CNCDNURL:            cdnURL, // Optional China CDN URL
+		CDNRegionURLs:       parseCDNRegionMap(getEnv("CDN_REGION_MAP", "")),
+		cdnIPSet:            make(map[string]bool),              // Initialize CDN IP set
+		TurnstileSiteKey:    getEnv("TURNSTILE_SITE_KEY", ""),   // Optional Turnstile site key
+		TurnstileSecretKey:  getEnv("TURNSTILE_SECRET_KEY", ""), // Optional Turnstile secret key
+		TurnstileTimeoutSec: getEnvInt("TURNSTILE_TIMEOUT_SECONDS", 5, 1),
+		TurnstileMaxRetries: getEnvInt("TURNSTILE_MAX_RETRIES", 2, 0),
+		TurnstileFailOpen:   getEnvBool("TURNSTILE_FAIL_OPEN", false),
+		MemoryLimitMB:       memoryLimit,
+		MemoryProfile:       profile.name,
+		ThumbWorkers:        getEnvInt("THUMB_WORKERS", profile.thumbWorkers, 1),
+		ThumbPreShrinkPx:    getEnvInt("THUMB_PRESHRINK_PX", profile.thumbPreShrinkPx, 1600),
+		ThumbSmallWidth:     getEnvInt("THUMB_SMALL_WIDTH", defaultThumbSmallWidth, 64),
+		ThumbLargeWidth:     getEnvInt("THUMB_LARGE_WIDTH", defaultThumbLargeWidth, 128),
+		ThumbSmallQuality:   getEnvIntRange("THUMB_SMALL_QUALITY", 75, 1, 100),
+		ThumbLargeQuality:   getEnvIntRange("THUMB_LARGE_QUALITY", 85, 1, 100),
+		PlaceholderCache:    getEnvInt("PLACEHOLDER_CACHE_SIZE", profile.placeholderCacheSize, 1),
+		SQLiteCacheMB:       getEnvInt("SQLITE_CACHE_MB", profile.sqliteCacheMB, 1),
+		ThumbJobTimeoutSec:  getEnvInt("THUMB_JOB_TIMEOUT_SECONDS", 120, 0),
+		ThumbWarmLimit:      getEnvInt("THUMB_WARM_LIMIT", 0, 0),
+		LinkSweepInterval:   getEnvInt("LINK_SWEEP_INTERVAL_MINUTES", 60, 0),
+		NotifyWebhookURL:    getEnv("NOTIFY_WEBHOOK_URL", ""),
+		LogFormat:           strings.ToLower(getEnv("LOG_FORMAT", LogFormatText)),
+		LogLevel:            strings.ToLower(getEnv("LOG_LEVEL", "info")),
+		LogFile:             getEnv("LOG_FILE", ""),
+		LogMaxSizeMB:        getEnvInt("LOG_MAX_SIZE_MB", 100, 1),
+		LogMaxBackups:       getEnvInt("LOG_MAX_BACKUPS", 7, 0),
+		LogMaxAgeDays:       getEnvInt("LOG_MAX_AGE_DAYS", 30, 0),
+		LogRotateHours:      getEnvInt("LOG_ROTATE_HOURS", 24, 0),
+		OutboundProxyURL:    getEnv("OUTBOUND_PROXY_URL", ""),
+		OutboundCABundle:    getEnv("OUTBOUND_CA_BUNDLE", ""),
+		GeoIPDatabasePath:   getEnv("GEOIP_DB_PATH", ""),
+		HotlinkAllowedHosts: parseHostList(getEnv("HOTLINK_ALLOWED_ORIGINS", "")),
+		HotlinkAllowEmpty:   getEnvBool("HOTLINK_ALLOW_EMPTY_REFERER", true),
+		CORSOrigins:         parseList(getEnv("CORS_ALLOWED_ORIGINS", "")),
+		CORSAdminOrigins:    parseList(getEnv("CORS_ADMIN_ORIGINS", "")),
+		CORSShareOrigins:    parseList(getEnv("CORS_SHARE_ORIGINS", "")),
+		CDNSignKey:          getEnv("CDN_SIGN_KEY", ""),
+		CDNSignParam:        getEnv("CDN_SIGN_PARAM", "auth_key"),
+		CDNSignTTLSeconds:   getEnvInt("CDN_SIGN_TTL_SECONDS", 3600, 60),
+		CDNSignRequired:     getEnvBool("CDN_SIGN_REQUIRED", false),
+		ShareCardFont:       getEnv("SHARE_CARD_FONT", ""),
+		RawPreviewEnabled:   getEnvBool("RAW_PREVIEW_ENABLED", true),
+		RawConvertCommand:   getEnv("RAW_CONVERT_COMMAND", ""),
+		RawConvertTimeout:   getEnvInt("RAW_CONVERT_TIMEOUT_SECONDS", 120, 1),
+		HEIFConvertCommand:  getEnv("HEIF_CONVERT_COMMAND", ""),
+		ArchiveSpoolDir:     getEnv("ARCHIVE_SPOOL_DIR", filepath.Join(os.TempDir(), "photobridge-archives")),
+		ArchiveSpoolMaxMB:   getEnvInt("ARCHIVE_SPOOL_MAX_MB", profile.archiveSpoolMaxMB, 0),
+		ZipVolumeSizeMB:     getEnvInt("ZIP_VOLUME_SIZE_MB", 0, 0),
+		DownloadJobDir:      getEnv("DOWNLOAD_JOB_DIR", filepath.Join(os.TempDir(), "photobridge-download-jobs")),
+		DownloadJobTTL:      getEnvInt("DOWNLOAD_JOB_TTL_MINUTES", 60, 0),
+		DownloadJobMaxMB:    getEnvInt("DOWNLOAD_JOB_MAX_MB", 20480, 0),
+		NormalizeUploads:    getEnvBool("NORMALIZE_UPLOADS", false),
+		NormalizeQuality:    getEnvInt("NORMALIZE_JPEG_QUALITY", 95, 1),
+		MaxUploadSizeMB:     getEnvInt("MAX_UPLOAD_SIZE_MB", 0, 0),
+		UploadChunkDir:      getEnv("UPLOAD_CHUNK_DIR", filepath.Join(os.TempDir(), "photobridge-uploads")),
+		UploadSessionTTL:    getEnvInt("UPLOAD_SESSION_TTL_HOURS", 72, 0),
+		TrashRetentionDays:  getEnvInt("TRASH_RETENTION_DAYS", 30, 0),
+		AccessLogDays:       getEnvInt("ACCESS_LOG_RETENTION_DAYS", 90, 0),
+		ScanInterval:        getEnvInt("SCAN_INTERVAL_MINUTES", 0, 0),
+		LoginMaxFailures:    getEnvInt("LOGIN_MAX_FAILURES", 10, 0),
+		LoginLockoutMinutes: getEnvInt("LOGIN_LOCKOUT_MINUTES", 15, 1),
+		DBMonitorInterval:   getEnvInt("DB_MONITOR_INTERVAL_MINUTES", 5, 0),
+		WALCheckpointMB:     getEnvInt("WAL_CHECKPOINT_MB", 64, 1),
+		DBSizeAlertMB:       getEnvInt("DB_SIZE_ALERT_MB", 0, 0),
+		DBGrowthAlertMB:     getEnvInt("DB_GROWTH_ALERT_MB", 1024, 0),
+		SMTPHost:            getEnv("SMTP_HOST", ""),
+		SMTPPort:            getEnvInt("SMTP_PORT", 587, 1),
+		SMTPUsername:        getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:        getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:            getEnv("SMTP_FROM", ""),
+		AdminEmails:         parseList(getEnv("ADMIN_EMAIL", "")),
+		MailOnSelections:    getEnvBool("MAIL_ON_SELECTIONS", true),
+		MailOnZip:           getEnvBool("MAIL_ON_ZIP", true),
+		PublicURL:           strings.TrimRight(getEnv("PUBLIC_URL", ""), "/"),
+		DigestSchedule:      strings.ToLower(getEnv("DIGEST_SCHEDULE", DigestWeekly)),
+		SharePasswordLength: getEnvIntRange("SHARE_PASSWORD_LENGTH", 4, 4, 64),
+		SharePasswordChars:  strings.ToLower(getEnv("SHARE_PASSWORD_ALPHABET", "digits")),
+	}
+	if AppConfig.ThumbSmallWidth >= AppConfig.ThumbLargeWidth {
+		log.Printf("%s THUMB_SMALL_WIDTH=%d is not below THUMB_LARGE_WIDTH=%d, using defaults %d and %d", shortname,
+			AppConfig.ThumbSmallWidth, AppConfig.ThumbLargeWidth, defaultThumbSmallWidth, defaultThumbLargeWidth)
+		AppConfig.ThumbSmallWidth, AppConfig.ThumbLargeWidth = defaultThumbSmallWidth, defaultThumbLargeWidth
 	}
 	log.Printf("%s Configuration loaded - Port: %s, UploadDir: %s, DatabasePath: %s",
 		shortname, AppConfig.Port, AppConfig.UploadDir, AppConfig.DatabasePath)
+	if memoryLimit > 0 {
+		log.Printf("%s Memory profile %s for %d MB - thumb workers: %d, pre-shrink: %dpx, SQLite cache: %d MB, archive spool: %d MB",
+			shortname, profile.name, memoryLimit, AppConfig.ThumbWorkers, AppConfig.ThumbPreShrinkPx, AppConfig.SQLiteCacheMB, AppConfig.ArchiveSpoolMaxMB)
+	}
 
 	// Initial CDN IP resolution
-	if cdnURL != "" {
+	if AppConfig.HasCDN() {
 		initialIPs := AppConfig.refreshCDNIPs()
 		if len(initialIPs) > 0 {
 			log.Printf("%s CDN IP whitelist initialized: %v", shortname, initialIPs)
@@ -99,30 +304,213 @@ func getEnvInt(key string, defaultValue int, minValue int) int {
 	return parsed
 }
 
-// refreshCDNIPs resolves CDN IPs and adds them to the set (never removes)
-// Returns the list of newly added IPs
-func (c *Config) refreshCDNIPs() []string {
-	if c.CNCDNURL == "" {
-		return nil
+// getEnvIntRange is getEnvInt with an upper bound as well
+func getEnvIntRange(key string, defaultValue, minValue, maxValue int) int {
+	parsed := getEnvInt(key, defaultValue, minValue)
+	if parsed > maxValue {
+		log.Printf("%s %s=%d is above maximum %d, using default %d", shortname, key, parsed, maxValue, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
 
-	// Parse URL to extract hostname
-	parsedURL, err := url.Parse(c.CNCDNURL)
+	parsed, err := strconv.ParseBool(value)
 	if err != nil {
-		log.Printf("%s Failed to parse CNCDN_URL: %v", shortname, err)
-		return nil
+		log.Printf("%s Invalid %s=%q, using default %v", shortname, key, value, defaultValue)
+		return defaultValue
 	}
+	return parsed
+}
 
-	hostname := parsedURL.Hostname()
-	if hostname == "" {
-		log.Printf("%s No hostname found in CNCDN_URL", shortname)
-		return nil
+// parseCDNRegionMap parses "CN=https://cdn.cn.example.com,HK|TW=https://cdn.hk.example.com"
+// into a country → base URL map. Several countries can share a mirror using "|".
+func parseCDNRegionMap(value string) map[string]string {
+	regions := make(map[string]string)
+	if value == "" {
+		return regions
 	}
 
-	// Resolve hostname to IP addresses
-	ips, err := net.LookupIP(hostname)
-	if err != nil {
-		log.Printf("%s Failed to resolve CDN hostname %s: %v", shortname, hostname, err)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		countries, baseURL, ok := strings.Cut(entry, "=")
+		baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+		if !ok || baseURL == "" {
+			log.Printf("%s Ignoring invalid CDN_REGION_MAP entry %q", shortname, entry)
+			continue
+		}
+		for _, country := range strings.Split(countries, "|") {
+			if country = strings.ToUpper(strings.TrimSpace(country)); country != "" {
+				regions[country] = baseURL
+			}
+		}
+	}
+	return regions
+}
+
+// parseHostList parses "example.com,https://blog.example.com,*.example.org" into lowercase hostnames.
+// Full origins are accepted and reduced to their hostname; "*." prefixes match any subdomain.
+// parseList splits a comma-separated value, dropping empty entries
+func parseList(value string) []string {
+	var items []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			items = append(items, entry)
+		}
+	}
+	return items
+}
+
+func parseHostList(value string) []string {
+	var hosts []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "://") {
+			parsed, err := url.Parse(entry)
+			if err != nil || parsed.Hostname() == "" {
+				log.Printf("%s Ignoring invalid host %q", shortname, entry)
+				continue
+			}
+			entry = parsed.Hostname()
+		}
+		hosts = append(hosts, entry)
+	}
+	return hosts
+}
+
+// UsesSQLite reports whether the database is the SQLite file at DATABASE_PATH
+func (c *Config) UsesSQLite() bool {
+	return c.DatabaseDriver == "" || c.DatabaseDriver == "sqlite"
+}
+
+// Values of LOG_FORMAT
+const (
+	LogFormatText = "text" // logfmt key=value lines
+	LogFormatJSON = "json" // one JSON object per line, for Loki, ELK and the like
+)
+
+// Values of DIGEST_SCHEDULE
+const (
+	DigestWeekly  = "weekly"  // Mondays, covering the previous week
+	DigestMonthly = "monthly" // On the 1st, covering the previous month
+	DigestOff     = "off"
+)
+
+// MailEnabled reports whether admin emails can be sent (SMTP_HOST and ADMIN_EMAIL)
+func (c *Config) MailEnabled() bool {
+	return c.SMTPHost != "" && len(c.AdminEmails) > 0
+}
+
+// MailFrom returns the sender address of emails: SMTP_FROM, or the SMTP login
+func (c *Config) MailFrom() string {
+	if c.SMTPFrom != "" {
+		return c.SMTPFrom
+	}
+	return c.SMTPUsername
+}
+
+// HotlinkProtectionEnabled reports whether /uploads requests are checked for foreign referers
+func (c *Config) HotlinkProtectionEnabled() bool {
+	return len(c.HotlinkAllowedHosts) > 0
+}
+
+// IsHotlinkAllowedHost checks a referring hostname against HOTLINK_ALLOWED_ORIGINS and the CDN mirrors
+func (c *Config) IsHotlinkAllowedHost(host string) bool {
+	host = strings.ToLower(host)
+	if host == "" {
+		return false
+	}
+	for _, allowed := range c.HotlinkAllowedHosts {
+		if allowed == host {
+			return true
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	for _, cdnHost := range c.cdnHostnames() {
+		if strings.EqualFold(cdnHost, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// CDNURLForCountry returns the CDN base URL for a country code, or "" if none is configured
+func (c *Config) CDNURLForCountry(country string) string {
+	if country == "" {
+		return ""
+	}
+	if baseURL, ok := c.CDNRegionURLs[country]; ok {
+		return baseURL
+	}
+	if country == "CN" {
+		return c.CNCDNURL
+	}
+	return ""
+}
+
+// HasCDN reports whether any CDN mirror is configured
+func (c *Config) HasCDN() bool {
+	return c.CNCDNURL != "" || len(c.CDNRegionURLs) > 0
+}
+
+// cdnHostnames returns the distinct hostnames of all configured CDN mirrors
+func (c *Config) cdnHostnames() []string {
+	urls := []string{c.CNCDNURL}
+	for _, u := range c.CDNRegionURLs {
+		urls = append(urls, u)
+	}
+
+	seen := make(map[string]bool)
+	var hostnames []string
+	for _, u := range urls {
+		if u == "" {
+			continue
+		}
+		parsedURL, err := url.Parse(u)
+		if err != nil {
+			log.Printf("%s Failed to parse CDN URL %q: %v", shortname, u, err)
+			continue
+		}
+		hostname := parsedURL.Hostname()
+		if hostname == "" {
+			log.Printf("%s No hostname found in CDN URL %q", shortname, u)
+			continue
+		}
+		if !seen[hostname] {
+			seen[hostname] = true
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	return hostnames
+}
+
+// refreshCDNIPs resolves CDN IPs and adds them to the set (never removes)
+// Returns the list of newly added IPs
+func (c *Config) refreshCDNIPs() []string {
+	var ips []net.IP
+	for _, hostname := range c.cdnHostnames() {
+		// Resolve hostname to IP addresses
+		resolved, err := net.LookupIP(hostname)
+		if err != nil {
+			log.Printf("%s Failed to resolve CDN hostname %s: %v", shortname, hostname, err)
+			continue
+		}
+		ips = append(ips, resolved...)
+	}
+	if len(ips) == 0 {
 		return nil
 	}
 
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -153,6 +154,31 @@ func TestLoadFromEnv(t *testing.T) {
 	}
 }
 
+func TestLoadThumbSettings(t *testing.T) {
+	t.Setenv("UPLOAD_DIR", filepath.Join(t.TempDir(), "uploads"))
+
+	t.Setenv("THUMB_SMALL_WIDTH", "300")
+	t.Setenv("THUMB_LARGE_WIDTH", "2048")
+	t.Setenv("THUMB_SMALL_QUALITY", "70")
+	t.Setenv("THUMB_LARGE_QUALITY", "101")
+	Load()
+	if AppConfig.ThumbSmallWidth != 300 || AppConfig.ThumbLargeWidth != 2048 || AppConfig.ThumbSmallQuality != 70 {
+		t.Errorf("Expected the thumbnail settings from the environment, got %d/%d/%d",
+			AppConfig.ThumbSmallWidth, AppConfig.ThumbLargeWidth, AppConfig.ThumbSmallQuality)
+	}
+	if AppConfig.ThumbLargeQuality != 85 {
+		t.Errorf("Expected a quality above 100 to fall back to 85, got %d", AppConfig.ThumbLargeQuality)
+	}
+
+	// Small thumbnails must be smaller than large ones
+	t.Setenv("THUMB_SMALL_WIDTH", "1200")
+	t.Setenv("THUMB_LARGE_WIDTH", "800")
+	Load()
+	if AppConfig.ThumbSmallWidth != 400 || AppConfig.ThumbLargeWidth != 1600 {
+		t.Errorf("Expected the default widths, got %d/%d", AppConfig.ThumbSmallWidth, AppConfig.ThumbLargeWidth)
+	}
+}
+
 func TestLoadCreatesUploadDir(t *testing.T) {
 	// Create temp directory
 	tempDir, err := os.MkdirTemp("", "configtest")
@@ -325,3 +351,287 @@ func TestRefreshCDNIPs_NoDuplicates(t *testing.T) {
 		t.Errorf("New IP %s should be whitelisted", newIP)
 	}
 }
+
+func TestParseCDNRegionMap(t *testing.T) {
+	regions := parseCDNRegionMap(" CN=https://cdn.cn.example.com/ , hk|tw=https://cdn.hk.example.com,bad-entry,EU= ")
+
+	expected := map[string]string{
+		"CN": "https://cdn.cn.example.com",
+		"HK": "https://cdn.hk.example.com",
+		"TW": "https://cdn.hk.example.com",
+	}
+	if len(regions) != len(expected) {
+		t.Fatalf("Expected %d regions, got %d: %v", len(expected), len(regions), regions)
+	}
+	for country, url := range expected {
+		if regions[country] != url {
+			t.Errorf("regions[%q] = %q, expected %q", country, regions[country], url)
+		}
+	}
+
+	if len(parseCDNRegionMap("")) != 0 {
+		t.Error("Empty value should produce an empty map")
+	}
+}
+
+func TestCDNURLForCountry(t *testing.T) {
+	cfg := &Config{
+		CNCDNURL:      "https://cdn.cn.example.com",
+		CDNRegionURLs: map[string]string{"HK": "https://cdn.hk.example.com"},
+	}
+
+	tests := []struct {
+		country  string
+		expected string
+	}{
+		{"CN", "https://cdn.cn.example.com"},
+		{"HK", "https://cdn.hk.example.com"},
+		{"US", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := cfg.CDNURLForCountry(tt.country); got != tt.expected {
+			t.Errorf("CDNURLForCountry(%q) = %q, expected %q", tt.country, got, tt.expected)
+		}
+	}
+
+	// Region map entry overrides CNCDN_URL
+	cfg.CDNRegionURLs["CN"] = "https://mirror.cn.example.com"
+	if got := cfg.CDNURLForCountry("CN"); got != "https://mirror.cn.example.com" {
+		t.Errorf("Region map should override CNCDN_URL, got %q", got)
+	}
+}
+
+func TestCDNHostnames(t *testing.T) {
+	cfg := &Config{
+		CNCDNURL: "https://cdn.example.com",
+		CDNRegionURLs: map[string]string{
+			"HK": "https://cdn.example.com",
+			"DE": "https://eu.example.com:8443",
+		},
+	}
+
+	hostnames := cfg.cdnHostnames()
+	if len(hostnames) != 2 {
+		t.Errorf("Expected 2 distinct hostnames, got %v", hostnames)
+	}
+}
+
+func TestParseHostList(t *testing.T) {
+	hosts := parseHostList(" Studio.Example.com , https://blog.example.com:8443/path,*.partner.org,, ")
+
+	expected := []string{"studio.example.com", "blog.example.com", "*.partner.org"}
+	if len(hosts) != len(expected) {
+		t.Fatalf("Expected %d hosts, got %v", len(expected), hosts)
+	}
+	for i, host := range expected {
+		if hosts[i] != host {
+			t.Errorf("hosts[%d] = %q, expected %q", i, hosts[i], host)
+		}
+	}
+}
+
+func TestParseListenAddress(t *testing.T) {
+	tests := []struct {
+		entry   string
+		network string
+		wantErr bool
+	}{
+		{":8060", "tcp", false},
+		{"0.0.0.0:8060", "tcp4", false},
+		{"127.0.0.1:8060", "tcp4", false},
+		{"[::]:8060", "tcp6", false},
+		{"[::1]:8060", "tcp6", false},
+		{"unix:/run/photobridge.sock", "unix", false},
+		{"systemd", ListenSystemd, false},
+		{"8060", "", true},
+		{"localhost:8060", "", true},
+		{"0.0.0.0:http-alt-x", "", true},
+		{"unix:", "", true},
+	}
+	for _, tt := range tests {
+		addr, err := ParseListenAddress(tt.entry)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseListenAddress(%q) error = %v, wantErr %v", tt.entry, err, tt.wantErr)
+			continue
+		}
+		if addr.Network != tt.network {
+			t.Errorf("ParseListenAddress(%q) network = %q, expected %q", tt.entry, addr.Network, tt.network)
+		}
+	}
+
+	cfg := &Config{Port: "9090"}
+	if addrs, _ := cfg.ListenAddresses(); len(addrs) != 1 || addrs[0].Address != ":9090" {
+		t.Errorf("Expected every interface on PORT without LISTEN, got %v", addrs)
+	}
+	cfg.Listen = []string{"0.0.0.0:9090", "bogus"}
+	if _, err := cfg.ListenAddresses(); err == nil {
+		t.Error("Expected an invalid LISTEN entry to be rejected")
+	}
+}
+
+func TestIsHotlinkAllowedHost(t *testing.T) {
+	cfg := &Config{
+		CNCDNURL:            "https://cdn.example.cn",
+		HotlinkAllowedHosts: []string{"studio.example.com", "*.partner.org"},
+	}
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"studio.example.com", true},
+		{"STUDIO.example.com", true},
+		{"blog.partner.org", true},
+		{"partner.org", false},
+		{"cdn.example.cn", true},
+		{"evil.example.net", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.IsHotlinkAllowedHost(tt.host); got != tt.expected {
+			t.Errorf("IsHotlinkAllowedHost(%q) = %v, expected %v", tt.host, got, tt.expected)
+		}
+	}
+}
+
+func TestInsecureSecrets(t *testing.T) {
+	cfg := &Config{
+		AdminPassword: defaultAdminPassword,
+		APIKey:        "custom-api-key",
+		JWTSecret:     defaultJWTSecret,
+	}
+
+	insecure := cfg.InsecureSecrets()
+	if len(insecure) != 2 || insecure[0] != "ADMIN_PASSWORD" || insecure[1] != "JWT_SECRET" {
+		t.Errorf("Unexpected insecure secrets: %v", insecure)
+	}
+
+	cfg.AdminPassword = "s3cure"
+	cfg.JWTSecret = "another-secret"
+	if insecure := cfg.InsecureSecrets(); len(insecure) != 0 {
+		t.Errorf("Expected no insecure secrets, got %v", insecure)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	os.Unsetenv("ENV")
+	os.Unsetenv("DOCKER")
+
+	dir := t.TempDir()
+	cfg := &Config{
+		AdminPassword:      defaultAdminPassword,
+		APIKey:             "custom-api-key",
+		JWTSecret:          "a-sufficiently-long-jwt-secret-value",
+		UploadDir:          filepath.Join(dir, "uploads"),
+		DatabasePath:       filepath.Join(dir, "data", "photobridge.db"),
+		CNCDNURL:           "cdn.example.com",
+		CDNRegionURLs:      map[string]string{"HK": "https://cdn-hk.example.com"},
+		CDNSignRequired:    true,
+		RawConvertCommand:  "dcraw -c {input}",
+		HEIFConvertCommand: "no-such-heif-converter {input} {output}",
+		SharePasswordChars: "symbols",
+	}
+
+	statuses := make(map[string]string)
+	for _, r := range cfg.Check(false) {
+		statuses[r.Name] = r.Status
+	}
+
+	expected := map[string]string{
+		"secrets":                 CheckWarn, // defaults are only fatal in production
+		"UPLOAD_DIR":              CheckOK,
+		"DATABASE_PATH":           CheckOK,
+		"CNCDN_URL":               CheckError,
+		"CDN_REGION_MAP[HK]":      CheckOK,
+		"CDN_SIGN_REQUIRED":       CheckError,
+		"RAW_CONVERT_COMMAND":     CheckError,
+		"HEIF_CONVERT_COMMAND":    CheckError,
+		"SHARE_PASSWORD_ALPHABET": CheckError,
+	}
+	for name, status := range expected {
+		if statuses[name] != status {
+			t.Errorf("%s: status %q, expected %q", name, statuses[name], status)
+		}
+	}
+	if _, ok := statuses["LISTEN"]; ok {
+		t.Error("LISTEN should not be checked when checkPort is false")
+	}
+
+	cfg.DatabaseDriver = "postgres"
+	statuses = make(map[string]string)
+	for _, r := range cfg.Check(false) {
+		statuses[r.Name] = r.Status
+	}
+	if statuses["DATABASE_URL"] != CheckError {
+		t.Errorf("DATABASE_URL: status %q, expected %q", statuses["DATABASE_URL"], CheckError)
+	}
+	if _, ok := statuses["DATABASE_PATH"]; ok {
+		t.Error("DATABASE_PATH should not be checked for postgres")
+	}
+	cfg.DatabaseDriver = ""
+
+	os.Setenv("ENV", "production")
+	defer os.Unsetenv("ENV")
+	for _, r := range cfg.Check(false) {
+		if r.Name == "secrets" && r.Status != CheckError {
+			t.Errorf("Default secrets should be an error in production, got %q", r.Status)
+		}
+	}
+}
+
+func TestCORSOriginsFor(t *testing.T) {
+	t.Setenv("ENV", "production")
+	cfg := &Config{
+		CNCDNURL:         "https://cdn.example.cn/",
+		CORSOrigins:      []string{"https://studio.example.com", "https://*.example.com"},
+		CORSShareOrigins: []string{"https://blog.example.org"},
+	}
+
+	tests := []struct {
+		routes   string
+		expected []string
+	}{
+		{CORSRoutesDefault, []string{"https://studio.example.com", "https://*.example.com", "https://cdn.example.cn"}},
+		{CORSRoutesAdmin, []string{"https://studio.example.com", "https://cdn.example.cn"}},
+		{CORSRoutesShare, []string{"https://studio.example.com", "https://*.example.com", "https://blog.example.org", "https://cdn.example.cn"}},
+	}
+	for _, tt := range tests {
+		if got := cfg.CORSOriginsFor(tt.routes); strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("CORSOriginsFor(%s) = %v, expected %v", tt.routes, got, tt.expected)
+		}
+	}
+
+	cfg.CORSAdminOrigins = []string{"https://admin.example.com"}
+	if got := cfg.CORSOriginsFor(CORSRoutesAdmin); len(got) != 2 || got[0] != "https://admin.example.com" {
+		t.Errorf("Expected CORS_ADMIN_ORIGINS to replace the admin origins, got %v", got)
+	}
+
+	// Without a list, production only allows CDN mirrors and development any origin
+	cfg.CORSOrigins = nil
+	if got := cfg.CORSOriginsFor(CORSRoutesDefault); len(got) != 1 || got[0] != "https://cdn.example.cn" {
+		t.Errorf("Expected only the CDN mirror in production, got %v", got)
+	}
+	t.Setenv("ENV", "development")
+	if got := cfg.CORSOriginsFor(CORSRoutesAdmin); len(got) != 1 || got[0] != "*" {
+		t.Errorf("Expected any origin in development, got %v", got)
+	}
+}
+
+func TestValidateCORS(t *testing.T) {
+	valid := &Config{
+		CORSOrigins:      []string{"*", "https://studio.example.com", "http://localhost:5173"},
+		CORSShareOrigins: []string{"https://*.example.org"},
+	}
+	if err := valid.ValidateCORS(); err != nil {
+		t.Errorf("ValidateCORS() = %v", err)
+	}
+
+	for _, origin := range []string{"studio.example.com", "https://studio.example.com/gallery", "ftp://example.com", "https://*.*.example.com", "https://www.*.example.com"} {
+		cfg := &Config{CORSAdminOrigins: []string{origin}}
+		err := cfg.ValidateCORS()
+		if err == nil || !strings.HasPrefix(err.Error(), "CORS_ADMIN_ORIGINS") {
+			t.Errorf("Expected %q to be rejected, got %v", origin, err)
+		}
+	}
+}
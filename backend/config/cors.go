@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Route groups with their own CORS policy (see CORSOriginsFor)
+const (
+	CORSRoutesDefault = "default" // Public API and API key routes
+	CORSRoutesAdmin   = "admin"   // /api/admin
+	CORSRoutesShare   = "share"   // Share pages, /api/share and /uploads
+)
+
+// CORSOriginsFor returns the origins allowed to make cross-origin requests to a route
+// group; same-origin requests are always allowed. CDN mirrors are added to every group
+// since pages served through them call the API from the mirror's origin.
+//
+// Without CORS_ALLOWED_ORIGINS, development allows any origin on every group and
+// production only the CDN mirrors. The admin API never inherits wildcards: unless
+// CORS_ADMIN_ORIGINS is set it allows only the exact origins of CORS_ALLOWED_ORIGINS.
+func (c *Config) CORSOriginsFor(routes string) []string {
+	if len(c.CORSOrigins) == 0 && !IsProduction() {
+		return []string{"*"}
+	}
+
+	var origins []string
+	switch routes {
+	case CORSRoutesAdmin:
+		if len(c.CORSAdminOrigins) > 0 {
+			origins = append(origins, c.CORSAdminOrigins...)
+			break
+		}
+		for _, origin := range c.CORSOrigins {
+			if !strings.Contains(origin, "*") {
+				origins = append(origins, origin)
+			}
+		}
+	case CORSRoutesShare:
+		origins = append(append(origins, c.CORSOrigins...), c.CORSShareOrigins...)
+	default:
+		origins = append(origins, c.CORSOrigins...)
+	}
+	return appendMissing(origins, c.cdnOrigins()...)
+}
+
+// ValidateCORS checks the origins of CORS_ALLOWED_ORIGINS, CORS_ADMIN_ORIGINS and
+// CORS_SHARE_ORIGINS, reporting the first invalid entry
+func (c *Config) ValidateCORS() error {
+	for _, list := range []struct {
+		name    string
+		origins []string
+	}{
+		{"CORS_ALLOWED_ORIGINS", c.CORSOrigins},
+		{"CORS_ADMIN_ORIGINS", c.CORSAdminOrigins},
+		{"CORS_SHARE_ORIGINS", c.CORSShareOrigins},
+	} {
+		for _, origin := range list.origins {
+			if err := validateOrigin(origin); err != nil {
+				return fmt.Errorf("%s: %w", list.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DescribeOrigins formats a list of allowed CORS origins for logs and reports
+func DescribeOrigins(origins []string) string {
+	if len(origins) == 0 {
+		return "same origin only"
+	}
+	return strings.Join(origins, ", ")
+}
+
+// validateOrigin accepts "*", or scheme://host[:port] where the host may start with "*."
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) origin", origin)
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("%q must be scheme://host[:port] without a path", origin)
+	}
+	if wildcards := strings.Count(u.Host, "*"); wildcards > 1 || (wildcards == 1 && !strings.HasPrefix(u.Host, "*.")) {
+		return fmt.Errorf("%q may only use a wildcard as the first label (https://*.example.com)", origin)
+	}
+	return nil
+}
+
+// cdnOrigins returns the origins (scheme://host[:port]) of all configured CDN mirrors
+func (c *Config) cdnOrigins() []string {
+	urls := []string{c.CNCDNURL}
+	for _, u := range c.CDNRegionURLs {
+		urls = append(urls, u)
+	}
+
+	var origins []string
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if raw == "" || err != nil || u.Host == "" {
+			continue
+		}
+		origins = appendMissing(origins, u.Scheme+"://"+u.Host)
+	}
+	sort.Strings(origins)
+	return origins
+}
+
+// appendMissing appends the values not yet in list
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !slices.Contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ListenSystemd is the LISTEN entry standing for the sockets passed by systemd socket
+// activation (LISTEN_FDS)
+const ListenSystemd = "systemd"
+
+// ListenAddress is one parsed LISTEN entry
+type ListenAddress struct {
+	Network string // tcp (both IP versions), tcp4, tcp6, unix or systemd
+	Address string // host:port or socket path (empty for systemd)
+}
+
+func (a ListenAddress) String() string {
+	switch a.Network {
+	case "unix":
+		return "unix:" + a.Address
+	case ListenSystemd:
+		return ListenSystemd
+	}
+	return a.Address
+}
+
+// ParseListenAddress parses a LISTEN entry: "host:port" or "[ipv6]:port" (":port" is
+// every interface of both IP versions), "unix:/path/to/socket" or "systemd"
+func ParseListenAddress(entry string) (ListenAddress, error) {
+	if entry == ListenSystemd {
+		return ListenAddress{Network: ListenSystemd}, nil
+	}
+	if path, ok := strings.CutPrefix(entry, "unix:"); ok {
+		if path == "" {
+			return ListenAddress{}, fmt.Errorf("%q needs a socket path", entry)
+		}
+		return ListenAddress{Network: "unix", Address: path}, nil
+	}
+	host, port, err := net.SplitHostPort(entry)
+	if err != nil {
+		return ListenAddress{}, fmt.Errorf("%q is not host:port, unix:/path or systemd", entry)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return ListenAddress{}, fmt.Errorf("%q: invalid port %s", entry, port)
+	}
+	if host == "" {
+		return ListenAddress{Network: "tcp", Address: entry}, nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ListenAddress{}, fmt.Errorf("%q: %s is not an IP address", entry, host)
+	}
+	// An explicit IPv6 address, even [::], only takes IPv6 so it can be combined with
+	// 0.0.0.0 on the same port
+	if ip.To4() != nil {
+		return ListenAddress{Network: "tcp4", Address: entry}, nil
+	}
+	return ListenAddress{Network: "tcp6", Address: entry}, nil
+}
+
+// ListenAddresses returns the parsed LISTEN entries, or every interface on PORT when
+// LISTEN isn't set
+func (c *Config) ListenAddresses() ([]ListenAddress, error) {
+	if len(c.Listen) == 0 {
+		return []ListenAddress{{Network: "tcp", Address: ":" + c.Port}}, nil
+	}
+	addrs := make([]ListenAddress, 0, len(c.Listen))
+	for _, entry := range c.Listen {
+		addr, err := ParseListenAddress(entry)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import "os"
+
+// Memory profiles selected by MEMORY_LIMIT_MB
+const (
+	MemoryProfileStandard = "standard" // No limit set, or more than 1 GB
+	MemoryProfileLow      = "low"      // Up to 1 GB
+	MemoryProfileMinimal  = "minimal"  // Up to 512 MB
+)
+
+// memoryProfile holds the defaults a profile gives the memory-related settings.
+// Settings set explicitly in the environment always win.
+type memoryProfile struct {
+	name                 string
+	maxMB                int // Largest MEMORY_LIMIT_MB using this profile (0 = any)
+	thumbWorkers         int // THUMB_WORKERS: each worker holds one decoded image
+	thumbPreShrinkPx     int // THUMB_PRESHRINK_PX: long side huge images are reduced to before resizing
+	placeholderCacheSize int // PLACEHOLDER_CACHE_SIZE: RAW placeholder thumbnails kept in memory
+	sqliteCacheMB        int // SQLITE_CACHE_MB: page cache per database connection
+	archiveSpoolMaxMB    int // ARCHIVE_SPOOL_MAX_MB: the default spool directory is often a tmpfs (RAM)
+}
+
+// memoryProfiles is ordered from the smallest limit up; the last one is the default
+var memoryProfiles = []memoryProfile{
+	{name: MemoryProfileMinimal, maxMB: 512, thumbWorkers: 1, thumbPreShrinkPx: 1600, placeholderCacheSize: 64, sqliteCacheMB: 4, archiveSpoolMaxMB: 1024},
+	{name: MemoryProfileLow, maxMB: 1024, thumbWorkers: 1, thumbPreShrinkPx: 2400, placeholderCacheSize: 128, sqliteCacheMB: 8, archiveSpoolMaxMB: 4096},
+	{name: MemoryProfileStandard, thumbWorkers: 2, thumbPreShrinkPx: 3200, placeholderCacheSize: 512, sqliteCacheMB: 20, archiveSpoolMaxMB: 20480},
+}
+
+// selectMemoryProfile returns the profile for a memory limit in MB (0 = no limit)
+func selectMemoryProfile(limitMB int) memoryProfile {
+	if limitMB > 0 {
+		for _, profile := range memoryProfiles {
+			if profile.maxMB > 0 && limitMB <= profile.maxMB {
+				return profile
+			}
+		}
+	}
+	return memoryProfiles[len(memoryProfiles)-1]
+}
+
+// GoMemoryLimit returns the soft heap limit for the Go runtime derived from
+// MEMORY_LIMIT_MB (three quarters of it, leaving room for stacks and the OS), or 0
+// when no limit is set or GOMEMLIMIT is set explicitly
+func (c *Config) GoMemoryLimit() int64 {
+	if c.MemoryLimitMB <= 0 || os.Getenv("GOMEMLIMIT") != "" {
+		return 0
+	}
+	return int64(c.MemoryLimitMB) << 20 * 3 / 4
+}
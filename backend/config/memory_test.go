@@ -0,0 +1,52 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectMemoryProfile(t *testing.T) {
+	tests := map[int]string{
+		0:    MemoryProfileStandard,
+		256:  MemoryProfileMinimal,
+		512:  MemoryProfileMinimal,
+		513:  MemoryProfileLow,
+		1024: MemoryProfileLow,
+		4096: MemoryProfileStandard,
+	}
+	for limit, want := range tests {
+		if got := selectMemoryProfile(limit).name; got != want {
+			t.Errorf("selectMemoryProfile(%d) = %s, expected %s", limit, got, want)
+		}
+	}
+}
+
+func TestLoadMemoryProfile(t *testing.T) {
+	t.Setenv("UPLOAD_DIR", filepath.Join(t.TempDir(), "uploads"))
+	t.Setenv("MEMORY_LIMIT_MB", "512")
+	t.Setenv("THUMB_WORKERS", "")
+	t.Setenv("ARCHIVE_SPOOL_MAX_MB", "")
+	t.Setenv("SQLITE_CACHE_MB", "10") // explicit settings win over the profile
+	t.Setenv("GOMEMLIMIT", "")
+
+	Load()
+
+	if AppConfig.MemoryProfile != MemoryProfileMinimal || AppConfig.ThumbWorkers != 1 || AppConfig.ThumbPreShrinkPx != 1600 ||
+		AppConfig.ArchiveSpoolMaxMB != 1024 || AppConfig.SQLiteCacheMB != 10 {
+		t.Errorf("Unexpected settings for the minimal profile: %+v", AppConfig)
+	}
+	if limit := AppConfig.GoMemoryLimit(); limit != 384<<20 {
+		t.Errorf("GoMemoryLimit() = %d, expected 384 MB", limit)
+	}
+
+	t.Setenv("GOMEMLIMIT", "300MiB")
+	if limit := AppConfig.GoMemoryLimit(); limit != 0 {
+		t.Errorf("GOMEMLIMIT should take precedence, got %d", limit)
+	}
+
+	t.Setenv("MEMORY_LIMIT_MB", "")
+	Load()
+	if AppConfig.MemoryProfile != MemoryProfileStandard || AppConfig.ThumbWorkers != 2 || AppConfig.ThumbPreShrinkPx != 3200 || AppConfig.GoMemoryLimit() != 0 {
+		t.Errorf("Unexpected defaults without a memory limit: %+v", AppConfig)
+	}
+}
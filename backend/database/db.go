@@ -1,6 +1,8 @@
 package database
 
 import (
+	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -8,7 +10,6 @@ import (
 	"photobridge/config"
 	"photobridge/models"
 
-	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -18,31 +19,22 @@ var DB *gorm.DB
 const shortname = "[Database]"
 
 func Init() {
-	var err error
-
-	// Ensure data directory exists
-	dir := filepath.Dir(config.AppConfig.DatabasePath)
-	log.Printf("%s Creating database directory: %s", shortname, dir)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Fatalf("%s Failed to create database directory %s: %v", shortname, dir, err)
+	driver := config.AppConfig.DatabaseDriver
+	if config.AppConfig.UsesSQLite() {
+		prepareSQLitePath()
+		driver = DriverSQLite
 	}
-	log.Printf("%s Database directory created/verified: %s", shortname, dir)
 
-	// Check if database file exists
-	if _, err := os.Stat(config.AppConfig.DatabasePath); os.IsNotExist(err) {
-		log.Printf("%s Database file does not exist, will be created: %s", shortname, config.AppConfig.DatabasePath)
-	} else if err != nil {
-		log.Fatalf("%s Failed to check database file: %v", shortname, err)
-	} else {
-		log.Printf("%s Database file exists: %s", shortname, config.AppConfig.DatabasePath)
+	dialector, err := Dialector(config.AppConfig)
+	if err != nil {
+		log.Fatalf("%s %v", shortname, err)
 	}
-
-	log.Printf("%s Connecting to database: %s", shortname, config.AppConfig.DatabasePath)
-	DB, err = gorm.Open(sqlite.Open(config.AppConfig.DatabasePath), &gorm.Config{
+	log.Printf("%s Connecting to %s database", shortname, driver)
+	DB, err = gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Warn),
 	})
 	if err != nil {
-		log.Fatalf("%s Failed to connect to database %s: %v", shortname, config.AppConfig.DatabasePath, err)
+		log.Fatalf("%s Failed to connect to %s database: %v", shortname, driver, err)
 	}
 	log.Printf("%s Database connection established", shortname)
 
@@ -52,25 +44,8 @@ func Init() {
 		log.Fatalf("%s Failed to get database instance: %v", shortname, err)
 	}
 
-	// Enable WAL mode for better concurrency (allows concurrent reads)
-	log.Printf("%s Enabling WAL mode", shortname)
-	if _, err := sqlDB.Exec("PRAGMA journal_mode=WAL;"); err != nil {
-		log.Printf("%s Warning: Failed to enable WAL mode: %v", shortname, err)
-	}
-
-	// Set busy timeout to wait for locks instead of failing immediately
-	if _, err := sqlDB.Exec("PRAGMA busy_timeout=30000;"); err != nil {
-		log.Printf("%s Warning: Failed to set busy timeout: %v", shortname, err)
-	}
-
-	// Set synchronous mode to NORMAL for better performance
-	if _, err := sqlDB.Exec("PRAGMA synchronous=NORMAL;"); err != nil {
-		log.Printf("%s Warning: Failed to set synchronous mode: %v", shortname, err)
-	}
-
-	// Increase cache size to 20MB (default is 2MB)
-	if _, err := sqlDB.Exec("PRAGMA cache_size=-20000;"); err != nil {
-		log.Printf("%s Warning: Failed to set cache size: %v", shortname, err)
+	if driver == DriverSQLite {
+		configureSQLite(sqlDB)
 	}
 
 	// Set connection pool settings
@@ -80,6 +55,16 @@ func Init() {
 	sqlDB.SetMaxIdleConns(5)
 	log.Printf("%s Database optimization settings applied", shortname)
 
+	// Merge duplicate photo rows so the unique base name index can be created
+	if err := mergeDuplicatePhotos(DB); err != nil {
+		log.Fatalf("%s Failed to merge duplicate photos: %v", shortname, err)
+	}
+	if driver == DriverMySQL {
+		if err := ensureMySQLPhotoIndex(DB); err != nil {
+			log.Fatalf("%s Failed to create photo index: %v", shortname, err)
+		}
+	}
+
 	// Auto migrate models
 	log.Printf("%s Running database migrations", shortname)
 	err = DB.AutoMigrate(
@@ -87,10 +72,82 @@ func Init() {
 		&models.Photo{},
 		&models.ShareLink{},
 		&models.PhotoExclusion{},
+		&models.PhotoHighlight{},
+		&models.RawExclusion{},
+		&models.AdminSession{},
+		&models.UploadSession{},
+		&models.PhotoSelection{},
+		&models.PhotoComment{},
+		&models.PhotoAccess{},
+		&models.AccessLog{},
+		&models.LinkDailyStat{},
+		&models.LinkPhotoDailyStat{},
+		&models.ProjectDailyStat{},
+		&models.Tag{},
+		&models.Album{},
+		&models.TrashItem{},
+		&models.APIKey{},
+		&models.LoginAttempt{},
+		&models.AdminTwoFactor{},
+		&models.BackupCode{},
+		&models.Setting{},
 	)
 	if err != nil {
 		log.Fatalf("%s Failed to migrate database: %v", shortname, err)
 	}
 
+	// Hash share passwords stored in plain text by earlier versions
+	if err := hashSharePasswords(DB); err != nil {
+		log.Fatalf("%s Failed to hash share passwords: %v", shortname, err)
+	}
+
 	log.Printf("%s Database initialized successfully", shortname)
 }
+
+// prepareSQLitePath creates the directory of DATABASE_PATH
+func prepareSQLitePath() {
+	// Ensure data directory exists
+	dir := filepath.Dir(config.AppConfig.DatabasePath)
+	log.Printf("%s Creating database directory: %s", shortname, dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatalf("%s Failed to create database directory %s: %v", shortname, dir, err)
+	}
+	log.Printf("%s Database directory created/verified: %s", shortname, dir)
+
+	// Check if database file exists
+	if _, err := os.Stat(config.AppConfig.DatabasePath); os.IsNotExist(err) {
+		log.Printf("%s Database file does not exist, will be created: %s", shortname, config.AppConfig.DatabasePath)
+	} else if err != nil {
+		log.Fatalf("%s Failed to check database file: %v", shortname, err)
+	} else {
+		log.Printf("%s Database file exists: %s", shortname, config.AppConfig.DatabasePath)
+	}
+}
+
+// configureSQLite applies the SQLite pragmas
+func configureSQLite(sqlDB *sql.DB) {
+	// Enable WAL mode for better concurrency (allows concurrent reads)
+	log.Printf("%s Enabling WAL mode", shortname)
+	if _, err := sqlDB.Exec("PRAGMA journal_mode=WAL;"); err != nil {
+		log.Printf("%s Warning: Failed to enable WAL mode: %v", shortname, err)
+	}
+
+	// Set busy timeout to wait for locks instead of failing immediately
+	if _, err := sqlDB.Exec("PRAGMA busy_timeout=30000;"); err != nil {
+		log.Printf("%s Warning: Failed to set busy timeout: %v", shortname, err)
+	}
+
+	// Set synchronous mode to NORMAL for better performance
+	if _, err := sqlDB.Exec("PRAGMA synchronous=NORMAL;"); err != nil {
+		log.Printf("%s Warning: Failed to set synchronous mode: %v", shortname, err)
+	}
+
+	// Increase cache size (default is 2MB); SQLITE_CACHE_MB, 20MB unless a memory profile lowers it
+	cacheMB := config.AppConfig.SQLiteCacheMB
+	if cacheMB <= 0 {
+		cacheMB = 20
+	}
+	if _, err := sqlDB.Exec(fmt.Sprintf("PRAGMA cache_size=-%d;", cacheMB*1000)); err != nil {
+		log.Printf("%s Warning: Failed to set cache size: %v", shortname, err)
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+
+	"photobridge/config"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Database drivers (DATABASE_DRIVER)
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// Dialector returns the GORM dialector for the configured database
+func Dialector(cfg *config.Config) (gorm.Dialector, error) {
+	switch cfg.DatabaseDriver {
+	case "", DriverSQLite:
+		return sqlite.Open(cfg.DatabasePath), nil
+	case DriverPostgres:
+		if cfg.DatabaseURL == "" {
+			return nil, fmt.Errorf("DATABASE_URL is required for DATABASE_DRIVER=postgres")
+		}
+		return postgres.Open(cfg.DatabaseURL), nil
+	case DriverMySQL:
+		dsn, err := mysqlDSN(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.Open(dsn), nil
+	}
+	return nil, fmt.Errorf("unknown DATABASE_DRIVER %q (use sqlite, postgres or mysql)", cfg.DatabaseDriver)
+}
+
+// mysqlDSN validates a MySQL DSN and enables parseTime, without which DATETIME
+// columns can't be scanned into time.Time
+func mysqlDSN(dsn string) (string, error) {
+	if dsn == "" {
+		return "", fmt.Errorf("DATABASE_URL is required for DATABASE_DRIVER=mysql")
+	}
+	parsed, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+	parsed.ParseTime = true
+	return parsed.FormatDSN(), nil
+}
+
+// ensureMySQLPhotoIndex creates the unique base name index on MySQL, which has no
+// partial indexes (CREATE TABLE would add it without its WHERE clause and CREATE INDEX
+// fails on it). The expression is NULL for deleted rows and NULLs never collide, so
+// only live photos must be unique, as elsewhere. Needs MySQL 8.0.13 or later.
+// AutoMigrate finds the index afterwards and leaves it alone.
+func ensureMySQLPhotoIndex(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Photo{}) {
+		// Photos reference projects
+		if err := db.AutoMigrate(&models.Project{}); err != nil {
+			return err
+		}
+		if err := migrator.CreateTable(&models.Photo{}); err != nil {
+			return err
+		}
+		if err := migrator.DropIndex(&models.Photo{}, photoBaseNameIndex); err != nil {
+			return err
+		}
+	} else if migrator.HasIndex(&models.Photo{}, photoBaseNameIndex) {
+		return nil
+	}
+	return db.Exec("CREATE UNIQUE INDEX " + photoBaseNameIndex +
+		" ON photos (project_id, base_name, (IF(deleted_at IS NULL, 1, NULL)))").Error
+}
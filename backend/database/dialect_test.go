@@ -0,0 +1,48 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"photobridge/config"
+)
+
+func TestDialector(t *testing.T) {
+	tests := []struct {
+		driver, url string
+		name        string // Expected dialector name, empty if an error is expected
+	}{
+		{"", "", "sqlite"},
+		{"sqlite", "", "sqlite"},
+		{"postgres", "postgres://photobridge:secret@db:5432/photobridge", "postgres"},
+		{"postgres", "", ""},
+		{"mysql", "photobridge:secret@tcp(db:3306)/photobridge", "mysql"},
+		{"mysql", "not a dsn", ""},
+		{"oracle", "x", ""},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{DatabaseDriver: tt.driver, DatabaseURL: tt.url, DatabasePath: "test.db"}
+		dialector, err := Dialector(cfg)
+		if tt.name == "" {
+			if err == nil {
+				t.Errorf("Dialector(%q, %q) should fail", tt.driver, tt.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Dialector(%q, %q) failed: %v", tt.driver, tt.url, err)
+		} else if dialector.Name() != tt.name {
+			t.Errorf("Dialector(%q) = %s, expected %s", tt.driver, dialector.Name(), tt.name)
+		}
+	}
+}
+
+func TestMySQLDSNParseTime(t *testing.T) {
+	dsn, err := mysqlDSN("photobridge:secret@tcp(db:3306)/photobridge?charset=utf8mb4")
+	if err != nil {
+		t.Fatalf("mysqlDSN failed: %v", err)
+	}
+	if !strings.Contains(dsn, "parseTime=true") || !strings.Contains(dsn, "charset=utf8mb4") {
+		t.Errorf("Expected parseTime to be added and charset kept, got %s", dsn)
+	}
+}
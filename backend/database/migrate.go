@@ -0,0 +1,154 @@
+package database
+
+import (
+	"errors"
+	"log"
+
+	"photobridge/models"
+	"photobridge/utils"
+
+	"gorm.io/gorm"
+)
+
+// photoBaseNameIndex is the unique (project_id, base_name) index on live photos
+const photoBaseNameIndex = "idx_project_base_name"
+
+// IsDuplicateKey reports whether err is a unique constraint violation, for any dialect
+func IsDuplicateKey(db *gorm.DB, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		return errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey)
+	}
+	return false
+}
+
+// mergeDuplicatePhotos prepares databases created before the unique base name index:
+// concurrent uploads could store one shot as several rows. Each group is merged into its
+// oldest row (which takes over missing normal/RAW files, thumbnails, capture time and
+// share link references) and the other rows are deleted. It is a no-op once the index exists.
+func mergeDuplicatePhotos(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.Photo{}) || db.Migrator().HasIndex(&models.Photo{}, photoBaseNameIndex) {
+		return nil
+	}
+
+	type groupKey struct {
+		ProjectID uint
+		BaseName  string
+	}
+	var groups []groupKey
+	if err := db.Model(&models.Photo{}).Select("project_id, base_name").
+		Group("project_id, base_name").Having("COUNT(*) > 1").Scan(&groups).Error; err != nil {
+		return err
+	}
+
+	merged := 0
+	for _, g := range groups {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var photos []models.Photo
+			if err := tx.Where("project_id = ? AND base_name = ?", g.ProjectID, g.BaseName).Order("id").Find(&photos).Error; err != nil {
+				return err
+			}
+			if len(photos) < 2 {
+				return nil
+			}
+			keep := &photos[0]
+			updates := map[string]interface{}{}
+			for i := range photos[1:] {
+				other := &photos[i+1]
+				if keep.NormalExt == "" && other.NormalExt != "" {
+					keep.NormalExt = other.NormalExt
+					updates["normal_ext"] = other.NormalExt
+					updates["normal_hash"] = other.NormalHash
+					updates["file_hash"] = other.FileHash
+					updates["thumb_small"] = other.ThumbSmall
+					updates["thumb_large"] = other.ThumbLarge
+					updates["thumb_width"] = other.ThumbWidth
+					updates["thumb_height"] = other.ThumbHeight
+				}
+				if keep.RawExt == "" && other.RawExt != "" {
+					keep.RawExt = other.RawExt
+					updates["raw_ext"] = other.RawExt
+					updates["has_raw"] = other.HasRaw
+					updates["raw_hash"] = other.RawHash
+				}
+				if keep.TakenAt == nil && other.TakenAt != nil {
+					keep.TakenAt = other.TakenAt
+					updates["taken_at"] = other.TakenAt
+				}
+				if err := movePhotoReferences(tx, other.ID, keep.ID); err != nil {
+					return err
+				}
+				if err := tx.Delete(other).Error; err != nil {
+					return err
+				}
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(&models.Photo{}).Where("id = ?", keep.ID).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			merged += len(photos) - 1
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if merged > 0 {
+		log.Printf("%s Merged %d duplicate photo rows in %d groups", shortname, merged, len(groups))
+	}
+	return nil
+}
+
+// movePhotoReferences re-points share link rows from one photo to another,
+// dropping those the target already has
+func movePhotoReferences(tx *gorm.DB, fromID, toID uint) error {
+	for _, model := range []interface{}{&models.PhotoExclusion{}, &models.RawExclusion{}, &models.PhotoHighlight{}} {
+		existing := tx.Model(model).Select("link_id").Where("photo_id = ?", toID)
+		if err := tx.Where("photo_id = ? AND link_id IN (?)", fromID, existing).Delete(model).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(model).Where("photo_id = ?", fromID).Update("photo_id", toID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashSharePasswords moves the plain-text share passwords of databases from before
+// password hashing into password_hash and drops the old password column, so a leaked
+// database no longer reveals them. It is a no-op once the column is gone.
+func hashSharePasswords(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.ShareLink{}, "password") {
+		return nil
+	}
+
+	var rows []struct {
+		ID       uint
+		Password string
+	}
+	if err := db.Table("share_links").Select("id, password").Where("password <> ''").Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		hash, err := utils.HashPassword(row.Password)
+		if err != nil {
+			return err
+		}
+		if err := db.Table("share_links").Where("id = ?", row.ID).Update("password_hash", hash).Error; err != nil {
+			return err
+		}
+	}
+	if err := db.Migrator().DropColumn(&models.ShareLink{}, "password"); err != nil {
+		return err
+	}
+
+	log.Printf("%s Hashed %d share link passwords", shortname, len(rows))
+	return nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"photobridge/models"
+	"photobridge/utils"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMergeDuplicatePhotos(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.Exec(`CREATE TABLE photos (id integer PRIMARY KEY AUTOINCREMENT, project_id integer NOT NULL, base_name text NOT NULL,
+		normal_ext text, raw_ext text, has_raw numeric DEFAULT false, file_hash text, normal_hash text, raw_hash text,
+		thumb_small blob, thumb_large blob, thumb_width integer, thumb_height integer, taken_at datetime,
+		created_at datetime, updated_at datetime, deleted_at datetime)`).Error; err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+	if err := db.AutoMigrate(&models.PhotoExclusion{}, &models.RawExclusion{}, &models.PhotoHighlight{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	taken := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	rows := []models.Photo{
+		{ProjectID: 1, BaseName: "IMG_1", NormalExt: ".jpg", NormalHash: "n1", ThumbWidth: 400},
+		{ProjectID: 1, BaseName: "IMG_1", RawExt: ".arw", HasRaw: true, RawHash: "r1", TakenAt: &taken},
+		{ProjectID: 1, BaseName: "IMG_2", NormalExt: ".jpg"},
+		{ProjectID: 2, BaseName: "IMG_1", NormalExt: ".jpg"},
+	}
+	// The legacy table predates the width/height, curation, EXIF, thumbnail settings and album columns too
+	omit := []string{"width", "height", "normal_size", "raw_size", "rating", "caption", "thumb_params", "album_id"}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&models.Photo{}); err != nil {
+		t.Fatalf("Failed to parse photo model: %v", err)
+	}
+	for _, column := range stmt.Schema.DBNames {
+		if strings.HasPrefix(column, "exif_") {
+			omit = append(omit, column)
+		}
+	}
+	for i := range rows {
+		if err := db.Omit(omit...).Create(&rows[i]).Error; err != nil {
+			t.Fatalf("Failed to create photo: %v", err)
+		}
+	}
+	keepID, dupID := rows[0].ID, rows[1].ID
+	db.Create(&models.PhotoExclusion{LinkID: 1, PhotoID: dupID})
+	db.Create(&models.PhotoExclusion{LinkID: 2, PhotoID: keepID})
+	db.Create(&models.PhotoExclusion{LinkID: 2, PhotoID: dupID})
+	db.Create(&models.PhotoHighlight{LinkID: 1, PhotoID: dupID})
+
+	if err := mergeDuplicatePhotos(db); err != nil {
+		t.Fatalf("mergeDuplicatePhotos failed: %v", err)
+	}
+
+	var merged models.Photo
+	db.First(&merged, keepID)
+	if merged.NormalExt != ".jpg" || merged.RawExt != ".arw" || !merged.HasRaw || merged.RawHash != "r1" || merged.ThumbWidth != 400 {
+		t.Errorf("Kept photo should combine both files, got %+v", merged)
+	}
+	if merged.TakenAt == nil || !merged.TakenAt.Equal(taken) {
+		t.Errorf("Kept photo should take over the capture time, got %v", merged.TakenAt)
+	}
+
+	var count int64
+	db.Model(&models.Photo{}).Count(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 photos after merge, got %d", count)
+	}
+	db.Model(&models.PhotoExclusion{}).Where("photo_id = ?", dupID).Count(&count)
+	if count != 0 {
+		t.Error("Exclusions should be moved off the merged row")
+	}
+	db.Model(&models.PhotoExclusion{}).Where("photo_id = ?", keepID).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 exclusions on the kept photo (no duplicates), got %d", count)
+	}
+	db.Model(&models.PhotoHighlight{}).Where("photo_id = ?", keepID).Count(&count)
+	if count != 1 {
+		t.Error("Highlight should be moved to the kept photo")
+	}
+
+	// The unique index can now be created, and rejects new duplicates
+	if err := db.AutoMigrate(&models.Photo{}); err != nil {
+		t.Fatalf("AutoMigrate after merge failed: %v", err)
+	}
+	err = db.Create(&models.Photo{ProjectID: 1, BaseName: "IMG_2", NormalExt: ".png"}).Error
+	if !IsDuplicateKey(db, err) {
+		t.Errorf("Expected duplicate key error, got %v", err)
+	}
+
+	// Soft-deleted photos don't block re-uploading the same name
+	db.Where("project_id = 1 AND base_name = ?", "IMG_2").Delete(&models.Photo{})
+	if err := db.Create(&models.Photo{ProjectID: 1, BaseName: "IMG_2", NormalExt: ".jpg"}).Error; err != nil {
+		t.Errorf("Re-creating a deleted photo failed: %v", err)
+	}
+
+	// Later runs are no-ops
+	if err := mergeDuplicatePhotos(db); err != nil {
+		t.Errorf("Second run failed: %v", err)
+	}
+}
+
+func TestHashSharePasswords(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ShareLink{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	// Databases from before hashing keep the passwords in a plain password column
+	if err := db.Exec("ALTER TABLE share_links ADD COLUMN `password` varchar(4)").Error; err != nil {
+		t.Fatalf("Failed to add legacy column: %v", err)
+	}
+	db.Exec("INSERT INTO share_links (project_id, token, password_enabled, password) VALUES (1, 'locked', true, '4821'), (1, 'open', false, '')")
+
+	if err := hashSharePasswords(db); err != nil {
+		t.Fatalf("hashSharePasswords failed: %v", err)
+	}
+	if db.Migrator().HasColumn(&models.ShareLink{}, "password") {
+		t.Error("The plain password column should be dropped")
+	}
+	var locked, open models.ShareLink
+	db.Where("token = ?", "locked").First(&locked)
+	db.Where("token = ?", "open").First(&open)
+	if !utils.IsPasswordHash(locked.PasswordHash) || !utils.VerifyPassword(locked.PasswordHash, "4821") {
+		t.Errorf("Expected the hash of the old password, got %q", locked.PasswordHash)
+	}
+	if open.PasswordHash != "" {
+		t.Errorf("Links without a password should stay without one, got %q", open.PasswordHash)
+	}
+
+	// Running again is a no-op
+	if err := hashSharePasswords(db); err != nil {
+		t.Fatalf("Second run failed: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Access log of sensitive projects: every response that hands out an original file
+// (or the JPEG converted from a RAW) is recorded per photo with the visitor's IP,
+// country, user agent and the share link it came through. Separately, views and
+// downloads of every share link are counted in AccessLog for the link statistics.
+
+const (
+	defaultAccessLogLimit = 100
+	maxAccessLogLimit     = 1000
+	defaultTopPhotosLimit = 10
+	maxTopPhotosLimit     = 100
+)
+
+// originalAccess is one file of a photo handed out by a response
+type originalAccess struct {
+	photoID uint
+	file    string
+}
+
+// recordOriginalAccess logs accesses to originals if the project is sensitive. link is
+// nil when the request can't be attributed to a share link.
+func recordOriginalAccess(c *gin.Context, project *models.Project, link *models.ShareLink, action string, accesses ...originalAccess) {
+	if !project.Sensitive || len(accesses) == 0 {
+		return
+	}
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > models.MaxUserAgentLength {
+		userAgent = userAgent[:models.MaxUserAgentLength]
+	}
+	entries := make([]models.PhotoAccess, len(accesses))
+	for i, access := range accesses {
+		entries[i] = models.PhotoAccess{
+			PhotoID:   access.photoID,
+			ProjectID: project.ID,
+			File:      access.file,
+			Action:    action,
+			IP:        c.ClientIP(),
+			Country:   utils.GetClientCountry(c),
+			UserAgent: userAgent,
+		}
+		if link != nil {
+			entries[i].LinkID = &link.ID
+			entries[i].LinkAlias = link.Alias
+		}
+	}
+	if err := database.DB.CreateInBatches(entries, 500).Error; err != nil {
+		log.Printf("[AccessLog] Failed to record %d accesses in project %d: %v", len(entries), project.ID, err)
+	}
+}
+
+// recordLinkAccess logs a view or download through a share link for its statistics.
+// photoID is 0 unless a single photo was downloaded.
+func recordLinkAccess(c *gin.Context, link *models.ShareLink, action string, photoID uint) {
+	entry := models.AccessLog{
+		LinkID:    link.ID,
+		ProjectID: link.ProjectID,
+		Action:    action,
+		IP:        c.ClientIP(),
+		Country:   utils.GetClientCountry(c),
+	}
+	if photoID != 0 {
+		entry.PhotoID = &photoID
+	}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("[AccessLog] Failed to record %s of link %d: %v", action, link.ID, err)
+	}
+}
+
+// servedContent reports whether a response sent (the start of) a file or redirected to
+// a presigned URL of it: not for HEAD requests, 304s, errors or later ranges of a
+// download already recorded
+func servedContent(c *gin.Context) bool {
+	switch status := c.Writer.Status(); {
+	case c.Request.Method == http.MethodHead:
+		return false
+	case status == http.StatusFound:
+		return true
+	case status != http.StatusOK && status != http.StatusPartialContent:
+		return false
+	}
+	rangeHeader := c.GetHeader("Range")
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
+
+// cacheControl keeps originals of sensitive projects out of shared caches, so every
+// access reaches the server and is logged
+func cacheControl(project *models.Project, maxAge string) string {
+	if project.Sensitive {
+		return "private, " + maxAge
+	}
+	return "public, " + maxAge
+}
+
+// refererShareLink finds the share link of a gallery page (/s/<token>) that requested
+// a file of a project, so /uploads requests can be attributed to links
+func refererShareLink(c *gin.Context, projectID uint) *models.ShareLink {
+	referer, err := url.Parse(c.GetHeader("Referer"))
+	if err != nil {
+		return nil
+	}
+	token, ok := strings.CutPrefix(referer.Path, "/s/")
+	if !ok || token == "" {
+		return nil
+	}
+	token, _, _ = strings.Cut(token, "/")
+	var link models.ShareLink
+	if err := database.DB.Where("token = ? AND project_id = ?", token, projectID).First(&link).Error; err != nil {
+		return nil
+	}
+	return &link
+}
+
+// GetPhotoAccessLog returns the recorded accesses to a photo's originals, newest first
+// (?limit=, default 100). Deleted photos keep their history.
+func GetPhotoAccessLog(c *gin.Context) {
+	var photo models.Photo
+	if err := database.DB.Unscoped().Select("id, project_id").First(&photo, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	limit := defaultAccessLogLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(parsed, maxAccessLogLimit)
+	}
+
+	var project models.Project
+	database.DB.Unscoped().Select("id, sensitive").First(&project, photo.ProjectID)
+
+	var total int64
+	if err := database.DB.Model(&models.PhotoAccess{}).Where("photo_id = ?", photo.ID).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load access log"})
+		return
+	}
+	entries := []models.PhotoAccess{}
+	if err := database.DB.Where("photo_id = ?", photo.ID).Order("created_at DESC, id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load access log"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"photo_id":  photo.ID,
+		"sensitive": project.Sensitive,
+		"entries":   entries,
+		"total":     total,
+	})
+}
+
+// GetLinkStats returns the views, unique visitors and downloads of a share link, with
+// its most downloaded photos (?limit=, default 10)
+func GetLinkStats(c *gin.Context) {
+	var link models.ShareLink
+	if err := database.DB.Select("id").First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	limit := defaultTopPhotosLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(parsed, maxTopPhotosLimit)
+	}
+
+	stats, err := services.ReadLinkStats(link.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load link statistics"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
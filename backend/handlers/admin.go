@@ -2,24 +2,26 @@ package handlers
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"photobridge/common"
 	"photobridge/config"
 	"photobridge/database"
-	"photobridge/middleware"
 	"photobridge/models"
+	"photobridge/services"
 	"photobridge/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // generateShortToken generates a short URL-safe token (8 characters)
@@ -49,10 +51,13 @@ func generateUniqueToken() (string, error) {
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	OTP      string `json:"otp"` // TOTP or backup code, once two-factor authentication is enabled
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"` // Exchanged for new tokens at /api/admin/refresh
+	ExpiresIn    int    `json:"expires_in"`    // Seconds until Token expires
 }
 
 func Login(c *gin.Context) {
@@ -62,27 +67,62 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	if req.Username != config.AppConfig.AdminUsername || req.Password != config.AppConfig.AdminPassword {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	now := time.Now()
+	attempt := models.LoginAttempt{
+		Username:  truncateString(req.Username, 255),
+		IP:        c.ClientIP(),
+		Country:   utils.GetClientCountry(c),
+		UserAgent: truncateString(c.Request.UserAgent(), models.MaxUserAgentLength),
+		CreatedAt: now,
+	}
+	if !checkLoginBackoff(c, &attempt) {
 		return
 	}
 
-	claims := &middleware.Claims{
-		Username: req.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	// Both are checked in constant time (bcrypt if ADMIN_PASSWORD is a hash), so timing
+	// doesn't reveal how much of either matched
+	usernameOK := subtle.ConstantTimeCompare([]byte(req.Username), []byte(config.AppConfig.AdminUsername)) == 1
+	if !utils.VerifyPassword(config.AppConfig.AdminPassword, req.Password) || !usernameOK {
+		services.RecordLoginAttempt(&attempt)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if !checkLoginSecondFactor(c, &attempt, req.OTP) {
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.AppConfig.JWTSecret))
+	refreshToken, refreshHash := newRefreshToken()
+	session := models.AdminSession{
+		JTI:         newSessionJTI(),
+		Username:    req.Username,
+		IP:          c.ClientIP(),
+		UserAgent:   truncateString(c.Request.UserAgent(), models.MaxUserAgentLength),
+		RefreshHash: refreshHash,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(adminSessionTTL()),
+	}
+	response, err := adminTokens(&session, refreshToken, now)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	// Expired sessions are only kept until the next login
+	database.DB.Where("expires_at <= ?", now).Delete(&models.AdminSession{})
+	attempt.Success = true
+	services.RecordLoginAttempt(&attempt)
 
-	c.JSON(http.StatusOK, LoginResponse{Token: tokenString})
+	c.JSON(http.StatusOK, response)
+}
+
+// GetJWKS publishes the public keys of admin tokens, including rotated-out keys whose
+// tokens may still be valid. Empty with HS256, whose secret is never published.
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": utils.AdminJWTKeys().JWKS()})
 }
 
 // Project handlers
@@ -161,9 +201,12 @@ func GetProject(c *gin.Context) {
 		return
 	}
 
+	setVersionETag(c, project.Version)
 	c.JSON(http.StatusOK, project)
 }
 
+// UpdateProject changes a project's settings. With If-Match only the version the
+// client last read is updated (412 otherwise).
 func UpdateProject(c *gin.Context) {
 	id := c.Param("id")
 	var project models.Project
@@ -172,6 +215,10 @@ func UpdateProject(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
 	}
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
 
 	var req models.UpdateProjectRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -180,21 +227,8 @@ func UpdateProject(c *gin.Context) {
 	}
 
 	updates := map[string]interface{}{}
-	var needsDirectoryRename bool
-	var oldName string
-
 	if req.Name != "" {
-		// 验证项目名称安全性
-		if _, valid := utils.SanitizeProjectName(req.Name); !valid {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project name"})
-			return
-		}
-		// Check if name is actually changing
-		if req.Name != project.Name {
-			oldName = project.Name
-			needsDirectoryRename = true
-			updates["name"] = req.Name
-		}
+		updates["name"] = req.Name
 	}
 	if req.Description != "" {
 		updates["description"] = req.Description
@@ -202,53 +236,66 @@ func UpdateProject(c *gin.Context) {
 	if req.CoverPhoto != "" {
 		updates["cover_photo"] = req.CoverPhoto
 	}
-
-	// If renaming project, rename the upload directory first
-	if needsDirectoryRename {
-		uploadsDir := filepath.Join("uploads")
-		oldPath := filepath.Join(uploadsDir, oldName)
-		newPath := filepath.Join(uploadsDir, req.Name)
-
-		// Check if old directory exists
-		if _, err := os.Stat(oldPath); err == nil {
-			// Check if new directory already exists
-			if _, err := os.Stat(newPath); err == nil {
-				c.JSON(http.StatusConflict, gin.H{
-					"error":   "Project directory already exists",
-					"message": fmt.Sprintf("Cannot rename: directory '%s' already exists", req.Name),
-				})
-				return
-			}
-
-			// Rename directory
-			if err := os.Rename(oldPath, newPath); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   "Failed to rename project directory",
-					"message": err.Error(),
-				})
-				return
-			}
+	if req.NotifyWebhookURL != nil {
+		webhookURL := strings.TrimSpace(*req.NotifyWebhookURL)
+		if webhookURL != "" && !isWebhookURL(webhookURL) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "notify_webhook_url must be an absolute http(s) URL"})
+			return
+		}
+		updates["notify_webhook_url"] = webhookURL
+	}
+	if req.NotifyAlsoGlobal != nil {
+		updates["notify_also_global"] = *req.NotifyAlsoGlobal
+	}
+	if req.Sensitive != nil {
+		updates["sensitive"] = *req.Sensitive
+	}
+	if req.QuotaMB != nil {
+		if *req.QuotaMB < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quota_mb must not be negative"})
+			return
 		}
-		// If old directory doesn't exist, continue (maybe no photos uploaded yet)
+		updates["quota_mb"] = *req.QuotaMB
 	}
 
-	if err := database.DB.Model(&project).Updates(updates).Error; err != nil {
-		// If database update fails and we renamed directory, try to rollback
-		if needsDirectoryRename {
-			uploadsDir := filepath.Join("uploads")
-			oldPath := filepath.Join(uploadsDir, oldName)
-			newPath := filepath.Join(uploadsDir, req.Name)
-			os.Rename(newPath, oldPath) // Attempt rollback (ignore errors)
+	// 重命名会同时移动上传目录，失败时回滚
+	if err := services.UpdateProject(&project, updates, ifVersion); err != nil {
+		switch {
+		case errors.Is(err, common.ErrVersionConflict):
+			database.DB.First(&project, project.ID)
+			respondVersionConflict(c, project.Version, project)
+		case errors.Is(err, services.ErrInvalidProjectName):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project name"})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		case errors.Is(err, services.ErrInvalidCoverPhoto):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cover photo not found in project"})
+		case errors.Is(err, services.ErrProjectNameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Project name already exists"})
+		case errors.Is(err, services.ErrProjectDirExists):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Project directory already exists",
+				"message": fmt.Sprintf("Cannot rename: directory '%s' already exists", req.Name),
+			})
+		default:
+			log.Printf("[Admin] Failed to update project %d: %v", project.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
 		return
 	}
 
 	// 重新加载更新后的项目
 	database.DB.First(&project, id)
+	setVersionETag(c, project.Version)
 	c.JSON(http.StatusOK, project)
 }
 
+// isWebhookURL reports whether value is an absolute http(s) URL
+func isWebhookURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
 func DeleteProject(c *gin.Context) {
 	id := c.Param("id")
 	var project models.Project
@@ -258,6 +305,14 @@ func DeleteProject(c *gin.Context) {
 		return
 	}
 
+	// 独占锁：等待进行中的上传完成，期间不允许新的上传
+	unlock := services.LockProject(project.ID)
+	defer unlock()
+	if err := database.DB.First(&project, project.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
 	// 检查项目中是否还有照片
 	photoCount := common.CountPhotosInProject(project.ID)
 	if photoCount > 0 {
@@ -265,26 +320,10 @@ func DeleteProject(c *gin.Context) {
 		return
 	}
 
-	// 获取所有关联的分享链接，以便删除其排除规则
-	var linkIDs []uint
-	database.DB.Model(&models.ShareLink{}).Where("project_id = ?", id).Pluck("id", &linkIDs)
-	if len(linkIDs) > 0 {
-		database.DB.Where("link_id IN ?", linkIDs).Delete(&models.PhotoExclusion{})
-	}
-
-	// Delete associated links
-	database.DB.Where("project_id = ?", id).Delete(&models.ShareLink{})
-	database.DB.Delete(&project)
-
-	// 删除项目的物理文件目录（如果存在）
-	uploadDir := filepath.Join(config.AppConfig.UploadDir, project.Name)
-	// Validate path before deletion to prevent directory traversal
-	safeUploadDir, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, uploadDir)
-	if err == nil {
-		// Only delete if path validation succeeds
-		if err := os.RemoveAll(safeUploadDir); err != nil {
-			// 日志记录但不影响响应，因为数据库已清理
-		}
+	// With the trash enabled the project can be restored until it is purged
+	if err := services.DeleteProject(&project); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Project deleted"})
@@ -295,7 +334,7 @@ func GetShareLinks(c *gin.Context) {
 	projectID := c.Param("id")
 	var links []models.ShareLink
 
-	result := database.DB.Where("project_id = ?", projectID).Preload("Exclusions").Find(&links)
+	result := database.DB.Where("project_id = ?", projectID).Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").Preload("Selections").Find(&links)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
@@ -319,17 +358,44 @@ func CreateShareLink(c *gin.Context) {
 		return
 	}
 
+	if err := models.ValidateDateRange(req.FromDate, req.ToDate, req.DateBasis); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locale, ok := parseLinkLocale(req.Locale)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+		return
+	}
+	if err := req.Preferences.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateMaxDownloads(req.MaxDownloads); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ExclusionNote = strings.TrimSpace(req.ExclusionNote)
+	exclusionDetail := models.PatchExclusionsRequest{Reason: req.ExclusionReason, Note: req.ExclusionNote}
+	if err := exclusionDetail.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	token, err := generateUniqueToken()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate unique token"})
 		return
 	}
 
-	// Generate password if enabled
-	password := ""
+	// Generate password if enabled (or use the chosen one); only its hash is stored
+	password, passwordHash := "", ""
 	passwordEnabled := req.PasswordEnabled
 	if passwordEnabled {
-		password = utils.GenerateSharePassword()
+		if password, passwordHash, err = newSharePassword(req.Password, req.SharePasswordOptions); err != nil {
+			respondSharePasswordError(c, err)
+			return
+		}
 	}
 
 	link := models.ShareLink{
@@ -337,29 +403,53 @@ func CreateShareLink(c *gin.Context) {
 		Token:           token,
 		Alias:           req.Alias,
 		AllowRaw:        req.AllowRaw,
+		AllowDownload:   req.AllowDownload == nil || *req.AllowDownload,
+		AllowZip:        req.AllowZip == nil || *req.AllowZip,
+		MaxDownloads:    req.MaxDownloads,
 		PasswordEnabled: passwordEnabled,
-		Password:        password,
+		PasswordHash:    passwordHash,
+		ExpiresAt:       req.ExpiresAt,
+		FromDate:        req.FromDate,
+		ToDate:          req.ToDate,
+		DateBasis:       req.DateBasis,
+		Locale:          locale,
+		Preferences:     req.Preferences,
 	}
 
+	permissions := link.PermissionColumns() // Create overwrites false flags with their defaults
 	result := database.DB.Create(&link)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
+	database.DB.Model(&link).Updates(permissions)
 
 	// Add exclusions
 	for _, photoID := range req.Exclusions {
 		exclusion := models.PhotoExclusion{
 			LinkID:  link.ID,
 			PhotoID: photoID,
+			Reason:  req.ExclusionReason,
+			Note:    req.ExclusionNote,
 		}
 		database.DB.Create(&exclusion)
 	}
+	if len(req.RawExclusions) > 0 {
+		common.SetRawExclusions(link.ID, req.RawExclusions)
+	}
+	if len(req.Highlights) > 0 {
+		common.SetLinkHighlights(&link, req.Highlights)
+	}
 
-	database.DB.Preload("Exclusions").First(&link, link.ID)
-	c.JSON(http.StatusCreated, link)
+	database.DB.Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").First(&link, link.ID)
+	services.EnqueueShareCard(link.ID)
+	// The generated password is shown only in this response
+	c.JSON(http.StatusCreated, models.ShareLinkWithPassword{ShareLink: link, Password: password})
 }
 
+// UpdateShareLink changes a link's settings, exclusions and highlights. With If-Match
+// only the version the client last read is updated (412 otherwise), so a tab with a
+// stale exclusion list can't overwrite another tab's changes.
 func UpdateShareLink(c *gin.Context) {
 	linkID := c.Param("id")
 	var link models.ShareLink
@@ -368,6 +458,10 @@ func UpdateShareLink(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
 		return
 	}
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
 
 	var req models.UpdateShareLinkRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -375,41 +469,228 @@ func UpdateShareLink(c *gin.Context) {
 		return
 	}
 
+	// Validate the resulting date range (unchanged bounds keep their current values)
+	fromDate, toDate, dateBasis := link.FromDate, link.ToDate, link.DateBasis
+	if req.FromDate != nil {
+		fromDate = req.FromDate
+	}
+	if req.ToDate != nil {
+		toDate = req.ToDate
+	}
+	if req.DateBasis != nil {
+		dateBasis = *req.DateBasis
+	}
+	if err := models.ValidateDateRange(fromDate, toDate, dateBasis); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	updates := map[string]interface{}{}
+	if req.Locale != nil {
+		locale, ok := parseLinkLocale(*req.Locale)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+			return
+		}
+		updates["locale"] = locale
+	}
+	if req.Preferences != nil {
+		if err := req.Preferences.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Updates with a map skips serializers, so store the JSON text directly
+		encoded, _ := json.Marshal(req.Preferences)
+		updates["preferences"] = string(encoded)
+	}
+	newPassword := ""
 	// Always update alias (allow clearing it with empty string)
 	updates["alias"] = req.Alias
 	if req.AllowRaw != nil {
 		updates["allow_raw"] = *req.AllowRaw
 	}
-	if req.PasswordEnabled != nil {
+	if req.AllowDownload != nil {
+		updates["allow_download"] = *req.AllowDownload
+	}
+	if req.AllowZip != nil {
+		updates["allow_zip"] = *req.AllowZip
+	}
+	if req.MaxDownloads != nil {
+		if err := models.ValidateMaxDownloads(*req.MaxDownloads); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["max_downloads"] = *req.MaxDownloads
+	}
+	if req.ResetDownloads {
+		updates["download_count"] = 0
+	}
+	if req.Password != "" {
+		// A chosen password enables protection
+		password, hash, err := newSharePassword(req.Password, req.SharePasswordOptions)
+		if err != nil {
+			respondSharePasswordError(c, err)
+			return
+		}
+		newPassword = password
+		updates["password_enabled"] = true
+		updates["password_hash"] = hash
+	} else if req.PasswordEnabled != nil {
 		updates["password_enabled"] = *req.PasswordEnabled
 		// Generate password when enabling, clear when disabling
-		if *req.PasswordEnabled && link.Password == "" {
-			updates["password"] = utils.GenerateSharePassword()
+		if *req.PasswordEnabled && link.PasswordHash == "" {
+			password, hash, err := newSharePassword("", req.SharePasswordOptions)
+			if err != nil {
+				respondSharePasswordError(c, err)
+				return
+			}
+			newPassword = password
+			updates["password_hash"] = hash
 		} else if !*req.PasswordEnabled {
-			updates["password"] = ""
+			updates["password_hash"] = ""
+		}
+	}
+	if req.ExpiresAt != nil {
+		if req.ExpiresAt.IsZero() {
+			updates["expires_at"] = nil
+		} else {
+			updates["expires_at"] = *req.ExpiresAt
+		}
+	}
+	for column, bound := range map[string]*time.Time{"from_date": req.FromDate, "to_date": req.ToDate} {
+		if bound == nil {
+			continue
+		}
+		if bound.IsZero() {
+			updates[column] = nil
+		} else {
+			updates[column] = *bound
 		}
 	}
+	if req.DateBasis != nil {
+		updates["date_basis"] = *req.DateBasis
+	}
 
+	if !bumpLinkVersion(c, &link, ifVersion) {
+		return
+	}
 	database.DB.Model(&link).Updates(updates)
 
 	// Update exclusions
 	if req.Exclusions != nil {
-		database.DB.Where("link_id = ?", link.ID).Delete(&models.PhotoExclusion{})
-		for _, photoID := range req.Exclusions {
-			exclusion := models.PhotoExclusion{
-				LinkID:  link.ID,
-				PhotoID: photoID,
-			}
-			database.DB.Create(&exclusion)
+		if err := common.ReplaceExclusions(link.ID, req.Exclusions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exclusions"})
+			return
+		}
+	}
+	if req.RawExclusions != nil {
+		common.SetRawExclusions(link.ID, req.RawExclusions)
+	}
+	if req.Highlights != nil {
+		common.SetLinkHighlights(&link, req.Highlights)
+	}
+
+	database.DB.Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").First(&link, link.ID)
+	services.EnqueueShareCard(link.ID)
+	setVersionETag(c, link.Version)
+	// A password generated by enabling protection is shown only in this response
+	c.JSON(http.StatusOK, models.ShareLinkWithPassword{ShareLink: link, Password: newPassword})
+}
+
+// PatchShareLinkExclusions adds and removes exclusions without resending the whole list,
+// so concurrent edits of different photos don't overwrite each other. If-Match is
+// optional here; the link's version is bumped either way.
+func PatchShareLinkExclusions(c *gin.Context) {
+	var link models.ShareLink
+	if err := database.DB.First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	var req models.PatchExclusionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	removed := make(map[uint]bool, len(req.Remove))
+	for _, id := range req.Remove {
+		removed[id] = true
+	}
+	for _, id := range req.Add {
+		if removed[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Photo %d is in both add and remove", id)})
+			return
+		}
+	}
+
+	// Only photos of the link's project can be excluded
+	if len(req.Add) > 0 {
+		var count int64
+		database.DB.Model(&models.Photo{}).Where("project_id = ? AND id IN ?", link.ProjectID, req.Add).Count(&count)
+		if int(count) != len(uniqueIDs(req.Add)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Some photos do not belong to this project"})
+			return
 		}
 	}
 
-	database.DB.Preload("Exclusions").First(&link, link.ID)
+	if !bumpLinkVersion(c, &link, ifVersion) {
+		return
+	}
+	if err := common.ApplyExclusionChanges(link.ID, req.Add, req.Remove, req.Reason, req.Note); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exclusions"})
+		return
+	}
+
+	database.DB.Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").First(&link, link.ID)
+	services.EnqueueShareCard(link.ID)
+	setVersionETag(c, link.Version)
 	c.JSON(http.StatusOK, link)
 }
 
-func DeleteShareLink(c *gin.Context) {
+// bumpLinkVersion claims the next version of a link before it is changed. A conflict
+// is answered with 412 and the link as it is now.
+func bumpLinkVersion(c *gin.Context, link *models.ShareLink, ifVersion uint) bool {
+	err := common.BumpLinkVersion(link.ID, ifVersion)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, common.ErrVersionConflict):
+		database.DB.Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").First(link, link.ID)
+		respondVersionConflict(c, link.Version, link)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update share link"})
+	}
+	return false
+}
+
+// uniqueIDs returns ids without duplicates
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// RegenerateSharePassword replaces a link's password with a generated one, or the one
+// chosen in the optional body, and returns it (shown only once)
+func RegenerateSharePassword(c *gin.Context) {
 	linkID := c.Param("id")
 	var link models.ShareLink
 
@@ -418,60 +699,293 @@ func DeleteShareLink(c *gin.Context) {
 		return
 	}
 
-	database.DB.Where("link_id = ?", link.ID).Delete(&models.PhotoExclusion{})
-	database.DB.Delete(&link)
+	if !link.PasswordEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password protection is not enabled for this link"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Share link deleted"})
+	// The body is optional: without a chosen password a new one is generated
+	var req models.SharePasswordRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	password, hash, err := newSharePassword(req.Password, req.SharePasswordOptions)
+	if err != nil {
+		respondSharePasswordError(c, err)
+		return
+	}
+	if err := database.DB.Model(&link).Updates(map[string]interface{}{
+		"password_hash": hash,
+		"version":       gorm.Expr("version + 1"),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": link.ID, "password": password})
 }
 
-func DeletePhoto(c *gin.Context) {
-	photoID := c.Param("id")
-	var photo models.Photo
+// errInvalidSharePassword is returned by newSharePassword for a chosen password of the wrong format
+var errInvalidSharePassword = fmt.Errorf("password must be %d to %d letters or digits",
+	utils.MinSharePasswordLength, utils.MaxSharePasswordLength)
+
+// newSharePassword returns a share password (the chosen one, or if chosen is empty one
+// generated with opts, falling back to SHARE_PASSWORD_LENGTH and SHARE_PASSWORD_ALPHABET)
+// and its hash
+func newSharePassword(chosen string, opts models.SharePasswordOptions) (password, hash string, err error) {
+	password = chosen
+	if password == "" {
+		length, alphabet := opts.PasswordLength, opts.PasswordAlphabet
+		if length == 0 {
+			length = config.AppConfig.SharePasswordLength
+		}
+		if alphabet == "" {
+			alphabet = config.AppConfig.SharePasswordChars
+		}
+		if password, err = utils.GenerateSharePassword(length, alphabet); err != nil {
+			return "", "", err
+		}
+	} else if !utils.ValidateSharePassword(password) {
+		return "", "", errInvalidSharePassword
+	}
+	hash, err = utils.HashPassword(password)
+	return password, hash, err
+}
 
-	if err := database.DB.Preload("Project").First(&photo, photoID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+// respondSharePasswordError answers 400 for a malformed chosen password or unusable
+// options for a generated one, 500 otherwise
+func respondSharePasswordError(c *gin.Context, err error) {
+	if errors.Is(err, errInvalidSharePassword) || errors.Is(err, utils.ErrSharePasswordOptions) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+}
 
-	// Delete physical files from disk
-	uploadsDir := filepath.Join("uploads", photo.Project.Name)
+// Share access token lifetimes (tokens can't be revoked individually, so keep them short)
+const (
+	defaultShareAccessTTL = 12 * time.Hour
+	maxShareAccessTTL     = 7 * 24 * time.Hour
+)
 
-	// Delete normal image file
-	if photo.NormalExt != "" {
-		normalPath := filepath.Join(uploadsDir, photo.BaseName+photo.NormalExt)
-		if err := os.Remove(normalPath); err != nil && !os.IsNotExist(err) {
-			// Log error but continue (file might already be deleted)
-			fmt.Printf("Warning: failed to delete normal file %s: %v\n", normalPath, err)
+// CreateShareAccessToken mints a read-only bearer token for a share link, for viewers
+// that can't go through the Turnstile and password cookie flows (digital frames, TV apps).
+// It shows the photo list and thumbnails only. Optional body: {"ttl_minutes": 720}.
+// Deleting the link or changing its password invalidates its tokens.
+func CreateShareAccessToken(c *gin.Context) {
+	var link models.ShareLink
+	if err := database.DB.First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	if link.IsExpired() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Share link has expired"})
+		return
+	}
+
+	var req struct {
+		TTLMinutes int `json:"ttl_minutes"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 	}
+	ttl := defaultShareAccessTTL
+	if req.TTLMinutes != 0 {
+		ttl = time.Duration(req.TTLMinutes) * time.Minute
+	}
+	if ttl <= 0 || ttl > maxShareAccessTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ttl_minutes must be between 1 and %d", int(maxShareAccessTTL.Minutes()))})
+		return
+	}
+	// A token never outlives its link
+	if link.ExpiresAt != nil && time.Until(*link.ExpiresAt) < ttl {
+		ttl = time.Until(*link.ExpiresAt)
+	}
 
-	// Delete RAW file if exists
-	if photo.HasRaw && photo.RawExt != "" {
-		rawPath := filepath.Join(uploadsDir, photo.BaseName+photo.RawExt)
-		if err := os.Remove(rawPath); err != nil && !os.IsNotExist(err) {
-			// Log error but continue
-			fmt.Printf("Warning: failed to delete RAW file %s: %v\n", rawPath, err)
+	token, expiresAt, err := utils.GenerateShareAccessToken(link.Token, link.PasswordHash, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_at":   expiresAt,
+		"share_token":  link.Token,
+	})
+}
+
+// parseLinkLocale normalizes a link's language override ("" = none)
+func parseLinkLocale(value string) (string, bool) {
+	if strings.TrimSpace(value) == "" {
+		return "", true
+	}
+	locale := utils.NormalizeLocale(value)
+	return locale, locale != ""
+}
+
+// CloneShareLink copies a link's settings, exclusions and highlights under a new token
+func CloneShareLink(c *gin.Context) {
+	var source models.ShareLink
+	if err := database.DB.Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").
+		First(&source, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	var req models.CloneShareLinkRequest
+	// The body is optional: an empty request clones everything as-is
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	token, err := generateUniqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate unique token"})
+		return
+	}
+
+	link := models.ShareLink{
+		ProjectID:       source.ProjectID,
+		Token:           token,
+		Alias:           source.Alias + " (copy)",
+		AllowRaw:        source.AllowRaw,
+		AllowDownload:   source.AllowDownload,
+		AllowZip:        source.AllowZip,
+		MaxDownloads:    source.MaxDownloads, // the copy starts counting from zero
+		PasswordEnabled: source.PasswordEnabled,
+		PasswordHash:    source.PasswordHash,
+		ExpiresAt:       source.ExpiresAt,
+		FromDate:        source.FromDate,
+		ToDate:          source.ToDate,
+		DateBasis:       source.DateBasis,
+		Locale:          source.Locale,
+		Preferences:     source.Preferences,
+	}
+	if req.Alias != nil {
+		link.Alias = *req.Alias
+	}
+	if req.ExpiresAt != nil {
+		link.ExpiresAt = req.ExpiresAt
+		if req.ExpiresAt.IsZero() {
+			link.ExpiresAt = nil
+		}
+	}
+	if req.PasswordEnabled != nil {
+		link.PasswordEnabled = *req.PasswordEnabled
+	}
+
+	// A new password is generated on request, or when the source had none to reuse
+	newPassword := ""
+	if !link.PasswordEnabled {
+		link.PasswordHash = ""
+	} else if req.NewPassword || link.PasswordHash == "" {
+		if newPassword, link.PasswordHash, err = newSharePassword("", req.SharePasswordOptions); err != nil {
+			respondSharePasswordError(c, err)
+			return
+		}
+	}
+
+	permissions := link.PermissionColumns() // Create overwrites false flags with their defaults
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&link).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&link).Updates(permissions).Error; err != nil {
+			return err
+		}
+		for _, e := range source.Exclusions {
+			if err := tx.Create(&models.PhotoExclusion{LinkID: link.ID, PhotoID: e.PhotoID, Reason: e.Reason, Note: e.Note}).Error; err != nil {
+				return err
+			}
+		}
+		for _, e := range source.RawExclusions {
+			if err := tx.Create(&models.RawExclusion{LinkID: link.ID, PhotoID: e.PhotoID}).Error; err != nil {
+				return err
+			}
 		}
+		for _, h := range source.Highlights {
+			if err := tx.Create(&models.PhotoHighlight{LinkID: link.ID, PhotoID: h.PhotoID, Position: h.Position}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clone share link"})
+		return
+	}
+
+	database.DB.Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").First(&link, link.ID)
+	services.EnqueueShareCard(link.ID)
+	// A newly generated password is shown only in this response
+	c.JSON(http.StatusCreated, models.ShareLinkWithPassword{ShareLink: link, Password: newPassword})
+}
+
+// DeleteShareLink deletes a link. The selections and comments its client left are kept
+// with it unless ?delete_feedback=true confirms deleting them too.
+func DeleteShareLink(c *gin.Context) {
+	linkID := c.Param("id")
+	var link models.ShareLink
+
+	if err := database.DB.First(&link, linkID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	database.DB.Where("link_id = ?", link.ID).Delete(&models.PhotoExclusion{})
+	database.DB.Where("link_id = ?", link.ID).Delete(&models.PhotoHighlight{})
+	database.DB.Where("link_id = ?", link.ID).Delete(&models.RawExclusion{})
+	if c.Query("delete_feedback") == "true" {
+		database.DB.Where("link_id = ?", link.ID).Delete(&models.PhotoSelection{})
+		database.DB.Where("link_id = ?", link.ID).Delete(&models.PhotoComment{})
 	}
+	services.DeleteLinkStats(database.DB, link.ID)
+	database.DB.Delete(&link)
+	services.RemoveShareCard(link.Token)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Share link deleted"})
+}
 
-	// Note: Thumbnails (ThumbSmall, ThumbLarge) are stored in database as BLOBs
-	// and will be automatically deleted when the record is deleted
+func DeletePhoto(c *gin.Context) {
+	photoID := c.Param("id")
+	var photo models.Photo
 
-	// Delete exclusions
-	if err := database.DB.Where("photo_id = ?", photo.ID).Delete(&models.PhotoExclusion{}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo exclusions"})
+	if err := database.DB.Preload("Project").First(&photo, photoID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
 		return
 	}
 
-	// Delete database record
-	if err := database.DB.Delete(&photo).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
+	if err := removePhoto(&photo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Photo deleted"})
 }
 
+// removePhoto deletes a photo's files, thumbnails, link references and database record
+func removePhoto(photo *models.Photo) error {
+	result, err := services.DeletePhotos([]uint{photo.ID})
+	if err != nil {
+		return err
+	}
+	if len(result.Done) == 0 {
+		return fmt.Errorf("Photo not found")
+	}
+	return nil
+}
+
 // GetPhotoFiles returns the list of files for a photo
 func GetPhotoFiles(c *gin.Context) {
 	photoID := c.Param("id")
@@ -502,7 +1016,7 @@ func GetPhotoFiles(c *gin.Context) {
 		files = append(files, FileInfo{
 			Type:     "normal",
 			Filename: photo.BaseName + photo.NormalExt,
-			URL:      "/uploads/" + encodedProjectName + "/" + encodedBaseName + photo.NormalExt,
+			URL:      utils.SignUploadURL("/uploads/" + encodedProjectName + "/" + encodedBaseName + photo.NormalExt),
 			Ext:      photo.NormalExt,
 		})
 	}
@@ -511,7 +1025,7 @@ func GetPhotoFiles(c *gin.Context) {
 		files = append(files, FileInfo{
 			Type:     "raw",
 			Filename: photo.BaseName + photo.RawExt,
-			URL:      "/uploads/" + encodedProjectName + "/" + encodedBaseName + photo.RawExt,
+			URL:      utils.SignUploadURL("/uploads/" + encodedProjectName + "/" + encodedBaseName + photo.RawExt),
 			Ext:      photo.RawExt,
 		})
 	}
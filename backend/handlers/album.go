@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAlbums lists the albums of a project in display order with their photo counts
+func GetAlbums(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.Select("id").First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	albums, err := services.ListAlbums(project.ID, database.DB.Where("project_id = ?", project.ID), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, albums)
+}
+
+// CreateAlbum adds an album to a project
+func CreateAlbum(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.Select("id").First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	var req models.AlbumRequest
+	if !bindAlbumRequest(c, &req, true) {
+		return
+	}
+	album, err := services.CreateAlbum(project.ID, req)
+	if err != nil {
+		respondAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, album)
+}
+
+// UpdateAlbum renames an album or changes its position
+func UpdateAlbum(c *gin.Context) {
+	album, ok := findAlbum(c)
+	if !ok {
+		return
+	}
+	var req models.AlbumRequest
+	if !bindAlbumRequest(c, &req, false) {
+		return
+	}
+	if err := services.UpdateAlbum(album, req); err != nil {
+		respondAlbumError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, album)
+}
+
+// DeleteAlbum deletes an album; its photos stay in the project
+func DeleteAlbum(c *gin.Context) {
+	album, ok := findAlbum(c)
+	if !ok {
+		return
+	}
+	if err := services.DeleteAlbum(album); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete album"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Album deleted"})
+}
+
+// AssignPhotoAlbum puts photos of a project into one of its albums, or takes them out
+// of their album with "album_id": null
+func AssignPhotoAlbum(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.Select("id").First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	var req models.AssignAlbumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.AlbumID != nil {
+		if _, err := services.FindAlbum(project.ID, *req.AlbumID); err != nil {
+			respondAlbumError(c, err)
+			return
+		}
+	}
+	ids, err := services.AssignAlbum(project.ID, req.AlbumID, uniqueIDs(req.PhotoIDs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update photos"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": ids})
+}
+
+// findAlbum loads the album of the :id parameter
+func findAlbum(c *gin.Context) (*models.Album, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid album ID"})
+		return nil, false
+	}
+	var album models.Album
+	if err := database.DB.First(&album, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Album not found"})
+		return nil, false
+	}
+	return &album, true
+}
+
+// bindAlbumRequest binds and validates the body of an album request
+func bindAlbumRequest(c *gin.Context, req *models.AlbumRequest, create bool) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := req.Validate(create); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// respondAlbumError maps an error of the album services to a response
+func respondAlbumError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAlbumNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlbumNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// uploadAlbum reads the album an upload goes into from the parsed multipart form:
+// "album_id" names an album of the project, "album" one by name, created if missing.
+// The album is nil when neither is given.
+func uploadAlbum(c *gin.Context, project *models.Project) (*models.Album, bool) {
+	if value := strings.TrimSpace(c.PostForm("album_id")); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid album ID"})
+			return nil, false
+		}
+		album, err := services.FindAlbum(project.ID, uint(id))
+		if errors.Is(err, services.ErrAlbumNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if err != nil {
+			respondAlbumError(c, err)
+			return nil, false
+		}
+		return album, true
+	}
+	name := c.PostForm("album")
+	if strings.TrimSpace(name) == "" {
+		return nil, true
+	}
+	req := models.AlbumRequest{Name: &name}
+	if err := req.Validate(true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	album, err := services.FindOrCreateAlbum(project.ID, *req.Name)
+	if err != nil {
+		respondAlbumError(c, err)
+		return nil, false
+	}
+	return album, true
+}
+
+// assignUploadAlbum puts the photos of an upload into its album
+func assignUploadAlbum(album *models.Album, project *models.Project, photos []UploadedPhoto) {
+	if album == nil || len(photos) == 0 {
+		return
+	}
+	ids := make([]uint, len(photos))
+	for i := range photos {
+		ids[i] = photos[i].ID
+		photos[i].AlbumID = &album.ID
+	}
+	if _, err := services.AssignAlbum(project.ID, &album.ID, ids); err != nil {
+		log.Printf("[Upload] Failed to add %d photos to album %s: %v", len(ids), album.Name, err)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyResponse is an API key in the key list
+type APIKeyResponse struct {
+	models.APIKey
+	ProjectName string `json:"project_name,omitempty"` // Name of the project a scoped key is limited to
+}
+
+// GetAPIKeys lists the API keys, newest first
+func GetAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	if err := database.DB.Order("created_at DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var projects []models.Project
+	database.DB.Select("id, name").Where("id IN (?)", database.DB.Model(&models.APIKey{}).Select("project_id")).Find(&projects)
+	names := make(map[uint]string, len(projects))
+	for _, project := range projects {
+		names[project.ID] = project.Name
+	}
+
+	response := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		response[i] = APIKeyResponse{APIKey: key}
+		if key.ProjectID != nil {
+			response[i].ProjectName = names[*key.ProjectID]
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateAPIKey creates an API key; the key itself is only returned here
+func CreateAPIKey(c *gin.Context) {
+	var req models.APIKeyRequest
+	if !bindAPIKeyRequest(c, &req) {
+		return
+	}
+
+	secret := utils.GenerateAPIKey()
+	key := models.APIKey{
+		Name:       req.Name,
+		Prefix:     utils.APIKeyPrefix(secret),
+		KeyHash:    utils.HashAPIKey(secret),
+		Permission: req.Permission,
+		ProjectID:  req.ProjectID,
+	}
+	if err := database.DB.Create(&key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": secret})
+}
+
+// UpdateAPIKey replaces the name, permission and project scope of an API key
+func UpdateAPIKey(c *gin.Context) {
+	key, ok := findManagedAPIKey(c)
+	if !ok {
+		return
+	}
+	var req models.APIKeyRequest
+	if !bindAPIKeyRequest(c, &req) {
+		return
+	}
+
+	key.Name, key.Permission, key.ProjectID = req.Name, req.Permission, req.ProjectID
+	if err := database.DB.Model(key).Select("name", "permission", "project_id").Updates(key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// DeleteAPIKey revokes an API key
+func DeleteAPIKey(c *gin.Context) {
+	key, ok := findManagedAPIKey(c)
+	if !ok {
+		return
+	}
+	if err := database.DB.Delete(key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// findManagedAPIKey loads the key of the :id parameter. The key of the API_KEY setting
+// can only be changed through the setting.
+func findManagedAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return nil, false
+	}
+	var key models.APIKey
+	if err := database.DB.First(&key, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return nil, false
+	}
+	if key.FromEnv {
+		c.JSON(http.StatusConflict, gin.H{"error": "This key is set by API_KEY and can only be changed there"})
+		return nil, false
+	}
+	return &key, true
+}
+
+// bindAPIKeyRequest binds and validates an API key request, checking that the project exists
+func bindAPIKeyRequest(c *gin.Context, req *models.APIKeyRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if req.ProjectID != nil {
+		var project models.Project
+		if err := database.DB.Select("id").First(&project, *req.ProjectID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found"})
+			return false
+		}
+	}
+	return true
+}
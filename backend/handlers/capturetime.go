@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/storage"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// captureTimeLayouts are the accepted formats of reference_time; times without a
+// zone are read in the zone of the stored capture time, like EXIF dates
+var captureTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006:01:02 15:04:05"}
+
+// ShiftCaptureTimeRequest corrects the capture times of a set of photos, e.g. from a
+// second camera whose clock was set to the wrong timezone. The shift is either given
+// directly (offset_seconds) or derived from the correct time of a reference photo.
+type ShiftCaptureTimeRequest struct {
+	PhotoIDs         []uint `json:"photo_ids" binding:"required"`
+	OffsetSeconds    int64  `json:"offset_seconds"`
+	ReferencePhotoID uint   `json:"reference_photo_id"` // Must be one of photo_ids
+	ReferenceTime    string `json:"reference_time"`     // Correct capture time of the reference photo
+	RewriteFiles     bool   `json:"rewrite_files"`      // Also rewrite the EXIF dates in the normal and RAW files
+}
+
+// ShiftCaptureTimes shifts the stored capture times of photos in a project and
+// optionally rewrites the EXIF dates in their files. Photos without a capture time
+// are skipped. File hashes keep identifying the uploaded originals, so re-uploading
+// an uncorrected file is still detected as a duplicate.
+func ShiftCaptureTimes(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	var req ShiftCaptureTimeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ids := uniqueIDs(req.PhotoIDs)
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "photo_ids must not be empty"})
+		return
+	}
+
+	var photos []models.Photo
+	if err := database.DB.Select(photoMetaColumns).Where("project_id = ? AND id IN ?", project.ID, ids).Find(&photos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(photos) != len(ids) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Some photos do not belong to this project"})
+		return
+	}
+
+	offset := time.Duration(req.OffsetSeconds) * time.Second
+	if req.ReferencePhotoID != 0 {
+		var err error
+		if offset, err = referenceOffset(photos, req.ReferencePhotoID, req.ReferenceTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if offset == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset_seconds or reference_photo_id and reference_time are required"})
+		return
+	}
+
+	updated := 0
+	skipped := []uint{}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range photos {
+			photo := &photos[i]
+			if photo.TakenAt == nil {
+				skipped = append(skipped, photo.ID)
+				continue
+			}
+			shifted := photo.TakenAt.Add(offset)
+			if err := tx.Model(photo).Update("taken_at", shifted).Error; err != nil {
+				return err
+			}
+			photo.TakenAt = &shifted
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filesRewritten := 0
+	failed := []gin.H{}
+	if req.RewriteFiles {
+		unlock := services.RLockProject(project.ID)
+		for i := range photos {
+			photo := &photos[i]
+			if photo.TakenAt == nil {
+				continue
+			}
+			for _, kind := range []string{"normal", "raw"} {
+				if kind == "raw" && !photo.HasRaw {
+					continue
+				}
+				key, err := photoFileKey(project.Name, photo, kind)
+				if err != nil {
+					continue // No file of this kind
+				}
+				if err := shiftStoredExifTimes(key, offset); err == nil {
+					filesRewritten++
+				} else if !errors.Is(err, utils.ErrNoExifTimes) {
+					failed = append(failed, gin.H{"photo_id": photo.ID, "file": photo.BaseName + fileExt(photo, kind), "error": err.Error()})
+				}
+			}
+		}
+		unlock()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"offset_seconds":  int64(offset / time.Second),
+		"updated":         updated,
+		"skipped":         skipped, // Photos without a capture time
+		"files_rewritten": filesRewritten,
+		"failed":          failed,
+	})
+}
+
+// shiftStoredExifTimes rewrites the EXIF dates of a stored file. Files in object
+// storage are downloaded, rewritten and uploaded again.
+func shiftStoredExifTimes(key string, offset time.Duration) error {
+	ctx := context.Background()
+	path, release, err := storage.Fetch(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if _, err := utils.ShiftExifTimes(path, offset); err != nil {
+		return err
+	}
+	if storage.IsLocal() {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return storage.Default().Put(ctx, key, file, info.Size())
+}
+
+// referenceOffset derives the shift that moves the reference photo to its correct time
+func referenceOffset(photos []models.Photo, referenceID uint, referenceTime string) (time.Duration, error) {
+	for _, photo := range photos {
+		if photo.ID != referenceID {
+			continue
+		}
+		if photo.TakenAt == nil {
+			return 0, errors.New("Reference photo has no capture time")
+		}
+		for _, layout := range captureTimeLayouts {
+			if correct, err := time.ParseInLocation(layout, referenceTime, photo.TakenAt.Location()); err == nil {
+				return correct.Sub(*photo.TakenAt), nil
+			}
+		}
+		return 0, errors.New("reference_time must be a date like 2024-05-01T10:00:00")
+	}
+	return 0, errors.New("reference_photo_id must be one of photo_ids")
+}
+
+// fileExt returns the extension of a photo's normal or RAW file
+func fileExt(photo *models.Photo, kind string) string {
+	if kind == "raw" {
+		return photo.RawExt
+	}
+	return photo.NormalExt
+}
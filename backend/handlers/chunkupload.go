@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Chunked uploads: a large file is sent as a series of appends to an upload session
+// and then processed like a file of a regular upload, so a dropped connection only
+// costs the chunk in flight. Admin routes address the project by ID, API key routes by
+// name (see chunkUploadProject).
+
+// assembledFile is a chunked upload whose bytes have all arrived
+type assembledFile struct {
+	fileName string
+	path     string
+	bytes    int64
+	sha      string
+}
+
+func (f assembledFile) name() string            { return f.fileName }
+func (f assembledFile) size() int64             { return f.bytes }
+func (f assembledFile) hash() (string, error)   { return f.sha, nil }
+func (f assembledFile) saveTo(dst string) error { return services.MoveUploadFile(f.path, dst) }
+
+// chunkUploadProject loads the project of a chunked upload request. On API key routes
+// a missing project is created when a session is started, as UploadViaAPI does.
+func chunkUploadProject(c *gin.Context, create bool) (*models.Project, bool) {
+	var project models.Project
+	if id := c.Param("id"); id != "" {
+		if err := database.DB.First(&project, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return nil, false
+		}
+		return &project, true
+	}
+
+	name, valid := utils.SanitizeProjectName(c.Param("project"))
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project name"})
+		return nil, false
+	}
+	if err := database.DB.Where("name = ?", name).First(&project).Error; err != nil {
+		if !create {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return nil, false
+		}
+		project = models.Project{Name: name}
+		if err := database.DB.Create(&project).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+			return nil, false
+		}
+	}
+	return &project, true
+}
+
+// chunkUploadSession loads the session named in the URL
+func chunkUploadSession(c *gin.Context) (*models.Project, *models.UploadSession, bool) {
+	project, ok := chunkUploadProject(c, false)
+	if !ok {
+		return nil, nil, false
+	}
+	session, err := services.FindUploadSession(project.ID, c.Param("upload"))
+	if err != nil {
+		respondUploadSessionError(c, err, nil)
+		return nil, nil, false
+	}
+	return project, session, true
+}
+
+// respondUploadSessionError maps an upload session error to a response; conflicts
+// include the offset of session (if not nil) so the client knows where to continue
+func respondUploadSessionError(c *gin.Context, err error, session *models.UploadSession) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload session not found"})
+	case errors.Is(err, services.ErrUploadOffset), errors.Is(err, services.ErrUploadIncomplete):
+		response := gin.H{"error": err.Error()}
+		if session != nil {
+			response["offset"] = session.Received
+		}
+		c.JSON(http.StatusConflict, response)
+	case errors.Is(err, services.ErrUploadOverflow):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUploadHashMismatch):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[Upload] Chunked upload failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process upload session"})
+	}
+}
+
+// CreateUploadSession starts a chunked upload, or returns the unfinished session of the
+// same file (201 for a new session, 200 for one to resume from its offset)
+func CreateUploadSession(c *gin.Context) {
+	project, ok := chunkUploadProject(c, true)
+	if !ok {
+		return
+	}
+
+	var req models.CreateUploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !utils.ValidateFileName(req.FileName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file name"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Refuse a file that can't be stored before any of it is sent
+	if err := services.CheckUploadSize(req.Size); err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.CheckProjectQuota(project, req.Size); err != nil {
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the project's quota"})
+		return
+	}
+
+	session, created, err := services.OpenUploadSession(project.ID, req)
+	if err != nil {
+		respondUploadSessionError(c, err, nil)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, session)
+}
+
+// GetUploadSession reports how many bytes of a chunked upload arrived
+func GetUploadSession(c *gin.Context) {
+	if _, session, ok := chunkUploadSession(c); ok {
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// AppendUploadChunk appends the request body to a chunked upload. The offset query
+// parameter must equal the bytes received so far; a mismatch returns 409 with the
+// session's offset.
+func AppendUploadChunk(c *gin.Context) {
+	_, session, ok := chunkUploadSession(c)
+	if !ok {
+		return
+	}
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	if err := services.AppendUploadChunk(session, offset, c.Request.Body); err != nil {
+		respondUploadSessionError(c, err, session)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// CompleteUploadSession verifies a fully received chunked upload and processes it like
+// a file of a regular upload. The response has the same shape as UploadPhotos.
+func CompleteUploadSession(c *gin.Context) {
+	project, session, ok := chunkUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Received != session.Size {
+		respondUploadSessionError(c, services.ErrUploadIncomplete, session)
+		return
+	}
+
+	uploadDir, unlock, err := lockUploadDir(project)
+	if err != nil {
+		respondPrepareUploadError(c, err)
+		return
+	}
+	defer unlock()
+
+	var result FileUploadResult
+	var uploaded *UploadedPhoto
+	err = services.CompleteUploadSession(session, func(path, hash string) {
+		file := assembledFile{fileName: session.FileName, path: path, bytes: session.Size, sha: hash}
+		photo, status, _, err := processUploadedFile(file, project, uploadDir)
+		result, uploaded = describeUpload(session.FileName, photo, status, hash, err, project)
+	})
+	if err != nil {
+		respondUploadSessionError(c, err, session)
+		return
+	}
+
+	response := gin.H{
+		"message": "Uploaded 1 file",
+		"photos":  []UploadedPhoto{},
+		"results": []FileUploadResult{result},
+	}
+	if uploaded != nil {
+		response["photos"] = []UploadedPhoto{*uploaded}
+	} else {
+		response["failed"] = []string{result.File}
+		response["message"] = "Uploaded 0 files, 1 failed"
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CancelUploadSession discards a chunked upload and its received bytes
+func CancelUploadSession(c *gin.Context) {
+	if _, session, ok := chunkUploadSession(c); ok {
+		services.CancelUploadSession(session)
+		c.JSON(http.StatusOK, gin.H{"message": "Upload session cancelled"})
+	}
+}
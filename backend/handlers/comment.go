@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"photobridge/middleware"
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Comments: visitors of a link with the comments preference leave feedback on single
+// photos. The share routes already require Turnstile; posting is also limited per IP.
+// The admin lists comments across links and hides or deletes them.
+
+// ShareComment is a comment as the gallery shows it, without the moderation fields
+type ShareComment struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// toShareComment leaves out the moderation fields of a comment
+func toShareComment(comment *models.PhotoComment) ShareComment {
+	return ShareComment{ID: comment.ID, Name: comment.Name, Body: comment.Body, CreatedAt: comment.CreatedAt}
+}
+
+// GetSharePhotoComments lists the visible comments on a photo of a share link, oldest first
+func GetSharePhotoComments(c *gin.Context) {
+	link, photo := middleware.SharePhoto(c)
+	if !link.Preferences.Comments {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Comments are not enabled for this link"})
+		return
+	}
+	comments, err := services.PhotoComments(link.ID, photo.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load comments"})
+		return
+	}
+	items := make([]ShareComment, len(comments))
+	for i := range comments {
+		items[i] = toShareComment(&comments[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"comments": items, "count": len(items)})
+}
+
+// PostSharePhotoComment adds a visitor's comment to a photo of a share link. An IP
+// posting too often gets 429 with Retry-After.
+func PostSharePhotoComment(c *gin.Context) {
+	link, photo := middleware.SharePhoto(c)
+	if !link.Preferences.Comments {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Comments are not enabled for this link"})
+		return
+	}
+
+	var req models.CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ip := c.ClientIP()
+	wait, err := services.CommentRetryAfter(ip, time.Now())
+	if err != nil {
+		log.Printf("[Share] Failed to check recent comments of %s: %v", ip, err)
+	}
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "too_many_comments",
+			"message":     "Too many comments, try again later",
+			"retry_after": seconds,
+		})
+		return
+	}
+
+	comment, err := services.AddComment(link, photo.ID, req, ip, utils.GetClientCountry(c))
+	if err != nil {
+		log.Printf("[Share] Failed to add a comment to photo %d of link %d: %v", photo.ID, link.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add the comment"})
+		return
+	}
+	c.JSON(http.StatusCreated, toShareComment(comment))
+}
+
+// GetComments lists comments for moderation, newest first, with their photo and link;
+// ?project_id, ?link_id, ?photo_id and ?hidden filter them
+func GetComments(c *gin.Context) {
+	var q models.CommentQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comments, err := services.ListComments(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+	c.JSON(http.StatusOK, comments)
+}
+
+// ModerateComment hides a comment from the gallery or shows it again
+func ModerateComment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+	var req models.ModerateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := services.SetCommentHidden(uint(id), *req.Hidden)
+	if errors.Is(err, services.ErrCommentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update the comment"})
+		return
+	}
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment deletes a comment for good
+func DeleteComment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+	err = services.DeleteComment(uint(id))
+	if errors.Is(err, services.ErrCommentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete the comment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetContactSheet renders a contact sheet JPEG of a project: every photo, the ones in
+// ?photo_ids= (repeated), or the photos a client picked on ?link_id=, in ?columns=
+// (default 6). X-Missing-Thumbnails counts photos shown as empty tiles because their
+// thumbnail is still being generated; such a sheet is worth requesting again.
+func GetContactSheet(c *gin.Context) {
+	var q models.ContactSheetQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.Select("id, name").First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	sheet, err := services.GenerateContactSheet(&project, q)
+	switch {
+	case errors.Is(err, services.ErrContactSheetLink):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrNoContactSheetPhotos), errors.Is(err, services.ErrTooManyContactSheetPhotos):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("[ContactSheet] Rendering a sheet of project %s failed: %v", project.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render contact sheet"})
+		return
+	}
+
+	c.Header("X-Missing-Thumbnails", strconv.Itoa(sheet.Missing))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"contact-sheet-%d.jpg\"", project.ID))
+	c.Data(http.StatusOK, "image/jpeg", sheet.Data)
+}
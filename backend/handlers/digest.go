@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"photobridge/config"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// digestSchedule reads ?schedule= (weekly or monthly), defaulting to DIGEST_SCHEDULE;
+// previews and test emails also work with DIGEST_SCHEDULE=off
+func digestSchedule(c *gin.Context) (string, bool) {
+	switch schedule := c.Query("schedule"); schedule {
+	case config.DigestWeekly, config.DigestMonthly:
+		return schedule, true
+	case "":
+		if config.AppConfig.DigestSchedule == config.DigestMonthly {
+			return config.DigestMonthly, true
+		}
+		return config.DigestWeekly, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "schedule must be weekly or monthly"})
+	return "", false
+}
+
+// GetDigest returns the digest of the last complete week or month, as it is emailed
+func GetDigest(c *gin.Context) {
+	schedule, ok := digestSchedule(c)
+	if !ok {
+		return
+	}
+	from, to := services.DigestPeriod(schedule, time.Now())
+	digest, err := services.BuildDigest(schedule, from, to)
+	if err != nil {
+		log.Printf("[Digest] Failed to build digest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build digest"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"digest": digest, "text": digest.Text(), "mail_enabled": config.AppConfig.MailEnabled()})
+}
+
+// SendDigest emails the digest to ADMIN_EMAIL now, e.g. to test the SMTP settings
+func SendDigest(c *gin.Context) {
+	schedule, ok := digestSchedule(c)
+	if !ok {
+		return
+	}
+	digest, err := services.SendDigest(schedule, time.Now())
+	switch {
+	case errors.Is(err, services.ErrMailDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("[Digest] Failed to send digest: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Digest sent", "recipients": config.AppConfig.AdminEmails, "digest": digest})
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// downloadJobPayload is what serving a finished job needs to know of its archive
+type downloadJobPayload struct {
+	downloadType string
+	accesses     []originalAccess
+}
+
+// CreateShareDownloadJob prepares the download-all archive of a share link (or one part
+// of it) in the background instead of streaming it, for galleries too large to zip
+// while the visitor waits. Repeating the request returns the job already preparing or
+// keeping the same archive. The job is polled with GetShareDownloadJob.
+func CreateShareDownloadJob(c *gin.Context) {
+	if services.DownloadJobs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": services.ErrDownloadJobsDisabled.Error()})
+		return
+	}
+	var req models.DownloadJobRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, ok := loadDownloadLink(c)
+	if !ok || !checkShareDownload(c, link, true) {
+		return
+	}
+	photos, ok := loadSharePhotosForDownload(c, link)
+	if !ok {
+		return
+	}
+	files, accesses, err := collectShareArchive(link, photos, req.Type)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid directory path"})
+		return
+	}
+	if files.count() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No files to download"})
+		return
+	}
+	files, accesses, zipName, ok := shareArchivePart(c, files, accesses, fmt.Sprintf("%s-%s.zip", link.Project.Name, req.Type), req.Part)
+	if !ok {
+		return
+	}
+
+	size, _ := files.zipSize()
+	key := fmt.Sprintf("%s/%d", req.Type, req.Part)
+	job, err := services.DownloadJobs.Submit(link.ID, key, zipName, files.count(), size, files.writeZip,
+		downloadJobPayload{downloadType: req.Type, accesses: accesses})
+	if errors.Is(err, services.ErrDownloadJobsBusy) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start the download job"})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetShareDownloadJob reports the progress of a download job of the link
+func GetShareDownloadJob(c *gin.Context) {
+	if services.DownloadJobs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrDownloadJobNotFound.Error()})
+		return
+	}
+	link, ok := loadDownloadLink(c)
+	if !ok {
+		return
+	}
+	job, err := services.DownloadJobs.Get(link.ID, c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadShareJobFile sends the finished archive of a download job, with Range
+// support. Like the streamed archive, every request counts as a download.
+func DownloadShareJobFile(c *gin.Context) {
+	if services.DownloadJobs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrDownloadJobNotFound.Error()})
+		return
+	}
+	link, ok := loadDownloadLink(c)
+	if !ok || !checkShareDownload(c, link, true) {
+		return
+	}
+	file, job, payload, err := services.DownloadJobs.Open(link.ID, c.Param("jobId"))
+	switch {
+	case errors.Is(err, services.ErrDownloadJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrDownloadJobNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "job": job})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the archive"})
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read the archive"})
+		return
+	}
+
+	if !claimShareDownload(c, link) {
+		return
+	}
+	archive := payload.(downloadJobPayload)
+	defer func() {
+		if servedContent(c) {
+			recordOriginalAccess(c, &link.Project, link, models.AccessArchive, archive.accesses...)
+			recordLinkAccess(c, link, models.LinkAccessZip, 0)
+			services.NotifyZipDownloaded(link, publicBaseURL(c), archive.downloadType, c.ClientIP(), utils.GetClientCountry(c))
+		}
+	}()
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", job.FileName))
+	c.Header("ETag", `"`+job.ID+`"`)
+	// ServeContent handles Range, If-Range and Content-Length
+	http.ServeContent(c.Writer, c.Request, job.FileName, info.ModTime(), file)
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// duplicateHashColumns maps the duplicate kind to the hash column it groups by
+var duplicateHashColumns = map[string]string{
+	"normal": "normal_hash",
+	"raw":    "raw_hash",
+}
+
+// DuplicateFile is one copy of a duplicated file
+type DuplicateFile struct {
+	PhotoID     uint   `json:"photo_id"`
+	ProjectID   uint   `json:"project_id"`
+	ProjectName string `json:"project_name"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	Linked      bool   `json:"linked"` // Shares storage with the first copy (hard link)
+}
+
+// DuplicateGroup is a set of photos whose normal or RAW files have the same content
+type DuplicateGroup struct {
+	Kind        string          `json:"kind"` // normal or raw
+	Hash        string          `json:"hash"`
+	Files       []DuplicateFile `json:"files"`
+	WastedBytes int64           `json:"wasted_bytes"`
+}
+
+// photoFileKey returns the storage key of a photo's normal or RAW file
+func photoFileKey(projectName string, photo *models.Photo, kind string) (string, error) {
+	ext := photo.NormalExt
+	if kind == "raw" {
+		ext = photo.RawExt
+	}
+	if ext == "" || !utils.ValidatePathComponent(projectName) {
+		return "", fmt.Errorf("photo %d has no %s file", photo.ID, kind)
+	}
+	return storage.Key(projectName, photo.BaseName+ext), nil
+}
+
+// photoFilePath returns the validated on-disk path of a photo's normal or RAW file
+// (only meaningful with local storage)
+func photoFilePath(projectName string, photo *models.Photo, kind string) (string, error) {
+	key, err := photoFileKey(projectName, photo, kind)
+	if err != nil {
+		return "", err
+	}
+	return utils.ValidateSecurePath(config.AppConfig.UploadDir,
+		filepath.Join(config.AppConfig.UploadDir, filepath.FromSlash(key)))
+}
+
+// statPhotoFile returns the size and modification time of a stored photo file
+func statPhotoFile(projectName string, photo *models.Photo, kind string) (storage.FileInfo, error) {
+	key, err := photoFileKey(projectName, photo, kind)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+	return storage.Default().Stat(context.Background(), key)
+}
+
+// photoFileExists reports whether a photo's normal or RAW file is present in storage
+func photoFileExists(projectName string, photo *models.Photo, kind string) bool {
+	_, err := statPhotoFile(projectName, photo, kind)
+	return err == nil
+}
+
+// localFileInfo stats a photo file on disk for hard link detection; nil with
+// object storage, which has no links
+func localFileInfo(projectName string, photo *models.Photo, kind string) os.FileInfo {
+	if !storage.IsLocal() {
+		return nil
+	}
+	path, err := photoFilePath(projectName, photo, kind)
+	if err != nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// loadDuplicatePhotos returns the photos sharing a hash, with projects loaded
+func loadDuplicatePhotos(kind, hash string) ([]models.Photo, error) {
+	var photos []models.Photo
+	err := database.DB.Select(photoMetaColumns).Preload("Project").
+		Where(duplicateHashColumns[kind]+" = ?", hash).Order("id").Find(&photos).Error
+	return photos, err
+}
+
+// buildDuplicateGroup stats each copy; copies hard-linked to the first one are not counted as waste
+func buildDuplicateGroup(kind, hash string, photos []models.Photo) DuplicateGroup {
+	group := DuplicateGroup{Kind: kind, Hash: hash, Files: []DuplicateFile{}}
+
+	var first os.FileInfo
+	seen := false
+	for i := range photos {
+		photo := &photos[i]
+		file := DuplicateFile{
+			PhotoID:     photo.ID,
+			ProjectID:   photo.ProjectID,
+			ProjectName: photo.Project.Name,
+		}
+		if kind == "raw" {
+			file.Filename = photo.BaseName + photo.RawExt
+		} else {
+			file.Filename = photo.BaseName + photo.NormalExt
+		}
+
+		if info, err := statPhotoFile(photo.Project.Name, photo, kind); err == nil {
+			file.Size = info.Size
+			local := localFileInfo(photo.Project.Name, photo, kind)
+			switch {
+			case !seen:
+				seen, first = true, local
+			case first != nil && local != nil && os.SameFile(first, local):
+				file.Linked = true
+			default:
+				group.WastedBytes += info.Size
+			}
+		}
+		group.Files = append(group.Files, file)
+	}
+	return group
+}
+
+// GetDuplicates groups photos with identical normal or RAW files across all projects
+func GetDuplicates(c *gin.Context) {
+	groups := []DuplicateGroup{}
+	var totalWasted int64
+
+	for _, kind := range []string{"normal", "raw"} {
+		column := duplicateHashColumns[kind]
+
+		var hashes []string
+		if err := database.DB.Model(&models.Photo{}).
+			Where(column+" <> ''").
+			Group(column).Having("COUNT(*) > 1").
+			Pluck(column, &hashes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, hash := range hashes {
+			photos, err := loadDuplicatePhotos(kind, hash)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			group := buildDuplicateGroup(kind, hash, photos)
+			totalWasted += group.WastedBytes
+			groups = append(groups, group)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"groups":       groups,
+		"wasted_bytes": totalWasted,
+	})
+}
+
+// ResolveDuplicatesRequest resolves one duplicate group
+type ResolveDuplicatesRequest struct {
+	Kind   string `json:"kind" binding:"required"` // normal or raw
+	Hash   string `json:"hash" binding:"required"`
+	KeepID uint   `json:"keep_id" binding:"required"`
+	// delete: delete the other photos (both their normal and RAW files)
+	// reference: keep the other photos but replace their file with a hard link to the kept copy
+	Action string `json:"action" binding:"required"`
+}
+
+// ResolveDuplicates keeps one photo of a duplicate group and deletes or links the others
+func ResolveDuplicates(c *gin.Context) {
+	var req ResolveDuplicatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := duplicateHashColumns[req.Kind]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be normal or raw"})
+		return
+	}
+	if req.Action != "delete" && req.Action != "reference" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be delete or reference"})
+		return
+	}
+
+	photos, err := loadDuplicatePhotos(req.Kind, req.Hash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var keep *models.Photo
+	for i := range photos {
+		if photos[i].ID == req.KeepID {
+			keep = &photos[i]
+		}
+	}
+	if keep == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "keep_id is not part of this duplicate group"})
+		return
+	}
+
+	if req.Action == "reference" && !storage.IsLocal() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reference requires local storage (STORAGE_BACKEND=local)"})
+		return
+	}
+
+	keepPath, err := photoFilePath(keep.Project.Name, keep, req.Kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !photoFileExists(keep.Project.Name, keep, req.Kind) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kept file is missing on disk"})
+		return
+	}
+	keepInfo := localFileInfo(keep.Project.Name, keep, req.Kind)
+
+	resolved := 0
+	var freedBytes int64
+	failed := []gin.H{}
+	for i := range photos {
+		photo := &photos[i]
+		if photo.ID == keep.ID {
+			continue
+		}
+
+		path, err := photoFilePath(photo.Project.Name, photo, req.Kind)
+		if err != nil {
+			failed = append(failed, gin.H{"photo_id": photo.ID, "error": err.Error()})
+			continue
+		}
+		info, statErr := statPhotoFile(photo.Project.Name, photo, req.Kind)
+		local := localFileInfo(photo.Project.Name, photo, req.Kind)
+		alreadyLinked := keepInfo != nil && local != nil && os.SameFile(keepInfo, local)
+
+		if req.Action == "delete" {
+			err = removePhoto(photo)
+		} else if !alreadyLinked {
+			err = replaceWithLink(keepPath, path)
+		}
+		if err != nil {
+			failed = append(failed, gin.H{"photo_id": photo.ID, "error": err.Error()})
+			continue
+		}
+
+		resolved++
+		if statErr == nil && !alreadyLinked {
+			freedBytes += info.Size
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"resolved":    resolved,
+		"freed_bytes": freedBytes,
+		"failed":      failed,
+	})
+}
+
+// replaceWithLink atomically replaces path with a hard link to target
+func replaceWithLink(target, path string) error {
+	tmp := path + ".link"
+	os.Remove(tmp)
+	if err := os.Link(target, tmp); err != nil {
+		return fmt.Errorf("failed to link file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
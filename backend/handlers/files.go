@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeUpload serves /uploads/<project>/<file> from storage. Files of known photos get
+// validators from their database record (see serveOriginal); in sensitive projects
+// they are kept out of shared caches and their accesses are logged.
+func ServeUpload(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("filepath"), "/")
+	projectName, fileName, ok := strings.Cut(key, "/")
+	if !ok || !utils.ValidatePathComponent(projectName) || !utils.ValidateFileName(fileName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+
+	var err error
+	if photo, hash := findOriginal(projectName, fileName); photo != nil {
+		if photo.Project.Sensitive {
+			c.Header("Cache-Control", "private")
+		}
+		if err = serveOriginal(c, key, hash, photo.UpdatedAt); err == nil && photo.Project.Sensitive && servedContent(c) {
+			file := models.AccessFileNormal
+			if path.Ext(fileName) == photo.RawExt {
+				file = models.AccessFileRaw
+			}
+			recordOriginalAccess(c, &photo.Project, refererShareLink(c, photo.ProjectID), models.AccessView, originalAccess{photo.ID, file})
+		}
+	} else {
+		err = storage.Default().Stream(c.Writer, c.Request, key)
+	}
+	if err != nil {
+		c.Header("Cache-Control", "")
+		c.Header("ETag", "")
+		c.Header("Last-Modified", "")
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		log.Printf("[Storage] Failed to serve %s: %v", key, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read file from storage"})
+	}
+}
+
+// findOriginal looks up the photo a file in a project belongs to (with the project's
+// ID and sensitive flag) and the hash of that file
+func findOriginal(projectName, fileName string) (*models.Photo, string) {
+	var project models.Project
+	if err := database.DB.Select("id, sensitive").Where("name = ?", projectName).First(&project).Error; err != nil {
+		return nil, ""
+	}
+	ext := path.Ext(fileName)
+	var photo models.Photo
+	if err := database.DB.Select(photoMetaColumns).
+		Where("project_id = ? AND base_name = ? AND (normal_ext = ? OR raw_ext = ?)", project.ID, strings.TrimSuffix(fileName, ext), ext, ext).
+		First(&photo).Error; err != nil {
+		return nil, ""
+	}
+	photo.Project = project
+	if ext == photo.RawExt {
+		return &photo, photo.RawHash
+	}
+	if photo.NormalHash != "" {
+		return &photo, photo.NormalHash
+	}
+	return &photo, photo.FileHash // Records from before normal_hash existed
+}
+
+// originalETag builds a strong ETag from a file's hash. The record's update time is
+// part of it because rewriting EXIF dates changes the file but keeps the hash of the
+// uploaded original.
+func originalETag(hash string, updatedAt time.Time) string {
+	return `"` + hash + "-" + strconv.FormatInt(updatedAt.Unix(), 36) + `"`
+}
+
+// serveOriginal streams a stored original with validators from its database record
+// instead of the file's modification time: a strong ETag from the hash and
+// Last-Modified from the record. Files restored from backup with new modification
+// times keep their validators, so CDN revalidation still gets 304s. Conditional
+// requests are answered here; storage only sees plain or Range requests.
+func serveOriginal(c *gin.Context, key, hash string, updatedAt time.Time) error {
+	if hash == "" || !storage.ServesContent() {
+		return storage.Default().Stream(c.Writer, c.Request, key)
+	}
+
+	etag := originalETag(hash, updatedAt)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", updatedAt.UTC().Format(http.TimeFormat))
+
+	req := c.Request
+	if notModified(req, etag, updatedAt) {
+		c.Writer.Header().Del("Content-Type")
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return nil
+	}
+	if ifRange := req.Header.Get("If-Range"); ifRange != "" {
+		if ifRange != etag {
+			req.Header.Del("Range") // Changed since the client's copy: send it whole
+		}
+		req.Header.Del("If-Range")
+	}
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	return storage.Default().Stream(c.Writer, req, key)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when no ETag was sent
+func notModified(req *http.Request, etag string, modTime time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendGalleryEmail emails clients that the gallery of a share link is ready, with its
+// URL (under PUBLIC_URL) and optionally its password. Passwords are only stored
+// hashed, so the panel sends the one it was shown when creating the link.
+func SendGalleryEmail(c *gin.Context) {
+	var link models.ShareLink
+	if err := database.DB.Preload("Project").First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	if link.IsExpired() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Share link has expired"})
+		return
+	}
+
+	var req models.GalleryEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Locale != "" && utils.NormalizeLocale(req.Locale) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale"})
+		return
+	}
+	if req.Password != "" && (!link.PasswordEnabled || !utils.VerifyPassword(link.PasswordHash, req.Password)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password does not match the link's password"})
+		return
+	}
+
+	email := services.NewGalleryEmail(&link, publicBaseURL(c), req.Password, req.Message, req.Locale)
+	err := services.SendGalleryEmail(req.To, email)
+	switch {
+	case errors.Is(err, services.ErrSMTPDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("[Mail] Failed to send gallery email for link %d: %v", link.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email sent", "recipients": req.To, "url": email.URL, "locale": email.Locale})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"photobridge/config"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health is the liveness probe: the process is up and serving HTTP
+func Health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"memory": gin.H{"profile": config.AppConfig.MemoryProfile, "limit_mb": config.AppConfig.MemoryLimitMB},
+	})
+}
+
+// Ready is the readiness probe: 200 once every startup step succeeded, 503 while
+// steps are pending or after one failed, with the state of each step
+func Ready(c *gin.Context) {
+	ready, steps := services.Startup.Report()
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "failed", http.StatusServiceUnavailable
+		if services.Startup.Starting() {
+			status = "starting"
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "steps": steps})
+}
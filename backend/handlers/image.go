@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/middleware"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetImage serves a derivative of a photo picked by ?size= and ?format= (see
+// models.ImageQuery), giving the frontend one URL scheme for thumbnails, previews and
+// originals. Requests with ?share=<token> go through the link's checks (see
+// middleware.ImageAuth); others are admin or API key requests.
+func GetImage(c *gin.Context) {
+	var q models.ImageQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, photo := middleware.SharePhoto(c)
+	if link == nil {
+		var ok bool
+		if photo, ok = adminImagePhoto(c); !ok {
+			return
+		}
+		// A key limited to one project doesn't learn that photos of others exist
+		if key := middleware.CurrentAPIKey(c); key != nil && key.ProjectID != nil && *key.ProjectID != photo.ProjectID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+			return
+		}
+	}
+
+	if q.Size != models.ImageSizeOriginal {
+		serveThumb(c, photo.ID, q.Size)
+		return
+	}
+
+	project := &photo.Project
+	if link != nil {
+		project = &link.Project
+	}
+	photoType, ok := originalImageType(photo, q.Format)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "format_unavailable", "message": "The photo has no " + q.Format + " original"})
+		return
+	}
+	servePhotoFile(c, project, link, photo, photoType)
+}
+
+// imageURL returns the /api/image URL of a derivative; token is the share link the
+// image is fetched through, empty for admin URLs
+func imageURL(photoID uint, token, size, format string) string {
+	query := url.Values{}
+	if token != "" {
+		query.Set("share", token)
+	}
+	query.Set("size", size)
+	if format != "" {
+		query.Set("format", format)
+	}
+	return fmt.Sprintf("/api/image/%d?%s", photoID, query.Encode())
+}
+
+// adminImagePhoto loads the :photoId of an admin image request with its project
+func adminImagePhoto(c *gin.Context) (*models.Photo, bool) {
+	var photo models.Photo
+	if err := database.DB.Select(common.PhotoMetaColumns).First(&photo, c.Param("photoId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return nil, false
+	}
+	if err := database.DB.First(&photo.Project, photo.ProjectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+	return &photo, true
+}
+
+// originalImageType maps the format of an original image request to the photo type of
+// servePhotoFile; false if the photo has no such original
+func originalImageType(photo *models.Photo, format string) (string, bool) {
+	switch format {
+	case models.ImageFormatRaw:
+		return "raw", photo.HasRaw && photo.RawExt != ""
+	case models.ImageFormatJPEG:
+		switch ext := strings.ToLower(photo.NormalExt); {
+		case ext == ".jpg" || ext == ".jpeg":
+			return "normal", true
+		case ext == "" && services.CanConvertRaw(photo), services.CanConvertHEIF(photo):
+			return "converted", true
+		}
+		return "", false
+	}
+	// As uploaded; servePhotoFile converts RAW-only photos when it can
+	return "normal", photo.NormalExt != "" || services.CanConvertRaw(photo)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MissingFile is a photo file that is referenced in the database but absent on disk
+type MissingFile struct {
+	PhotoID     uint   `json:"photo_id"`
+	ProjectID   uint   `json:"project_id"`
+	ProjectName string `json:"project_name"`
+	Filename    string `json:"filename"`
+	Kind        string `json:"kind"` // normal or raw
+}
+
+// photoMissingKinds returns which of a photo's referenced files are missing on disk
+func photoMissingKinds(photo *models.Photo) (normal, raw bool) {
+	normal = photo.NormalExt != "" && !photoFileExists(photo.Project.Name, photo, "normal")
+	raw = photo.HasRaw && photo.RawExt != "" && !photoFileExists(photo.Project.Name, photo, "raw")
+	return normal, raw
+}
+
+// forEachPhoto walks the photos of one project (or all when projectID is 0) in batches
+func forEachPhoto(projectID uint, fn func(photo *models.Photo)) error {
+	query := database.DB.Select(photoMetaColumns).Preload("Project")
+	if projectID > 0 {
+		query = query.Where("project_id = ?", projectID)
+	}
+	var batch []models.Photo
+	return query.FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			fn(&batch[i])
+		}
+		return nil
+	}).Error
+}
+
+// GetMissingFiles lists photo files that are recorded in the database but missing on disk.
+// Optional query: project_id
+func GetMissingFiles(c *gin.Context) {
+	projectID, _ := strconv.ParseUint(c.Query("project_id"), 10, 32)
+
+	missing := []MissingFile{}
+	err := forEachPhoto(uint(projectID), func(photo *models.Photo) {
+		normal, raw := photoMissingKinds(photo)
+		if normal {
+			missing = append(missing, MissingFile{
+				PhotoID: photo.ID, ProjectID: photo.ProjectID, ProjectName: photo.Project.Name,
+				Filename: photo.BaseName + photo.NormalExt, Kind: "normal",
+			})
+		}
+		if raw {
+			missing = append(missing, MissingFile{
+				PhotoID: photo.ID, ProjectID: photo.ProjectID, ProjectName: photo.Project.Name,
+				Filename: photo.BaseName + photo.RawExt, Kind: "raw",
+			})
+		}
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"missing": missing,
+		"total":   len(missing),
+	})
+}
+
+// RepairMissingFiles drops references to files that are missing on disk: a photo that
+// lost its normal image keeps only its RAW file (and vice versa), and a photo with no
+// file left is deleted. Files can be restored afterwards by uploading them again.
+// POST body (optional): { "project_id": 1 }
+func RepairMissingFiles(c *gin.Context) {
+	var req struct {
+		ProjectID uint `json:"project_id"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	var broken []models.Photo
+	err := forEachPhoto(req.ProjectID, func(photo *models.Photo) {
+		if normal, raw := photoMissingKinds(photo); normal || raw {
+			broken = append(broken, *photo)
+		}
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	repaired, deleted := 0, 0
+	failed := []gin.H{}
+	for i := range broken {
+		photo := &broken[i]
+		normalMissing, rawMissing := photoMissingKinds(photo)
+		hasNormal := photo.NormalExt != "" && !normalMissing
+		hasRaw := photo.HasRaw && photo.RawExt != "" && !rawMissing
+
+		if !hasNormal && !hasRaw {
+			if err := removePhoto(photo); err != nil {
+				failed = append(failed, gin.H{"photo_id": photo.ID, "error": err.Error()})
+				continue
+			}
+			deleted++
+			continue
+		}
+
+		updates := map[string]interface{}{}
+		if normalMissing {
+			updates["normal_ext"] = ""
+			updates["normal_hash"] = ""
+			updates["normal_size"] = 0
+			updates["file_hash"] = photo.RawHash // file_hash follows the remaining file, as for RAW-only uploads
+			updates["thumb_small"] = nil
+			updates["thumb_large"] = nil
+			updates["thumb_width"] = 0
+			updates["thumb_height"] = 0
+		}
+		if rawMissing {
+			updates["raw_ext"] = ""
+			updates["has_raw"] = false
+			updates["raw_hash"] = ""
+			updates["raw_size"] = 0
+		}
+		if err := database.DB.Model(&models.Photo{}).Where("id = ?", photo.ID).Updates(updates).Error; err != nil {
+			failed = append(failed, gin.H{"photo_id": photo.ID, "error": err.Error()})
+			continue
+		}
+		if normalMissing {
+			services.RemoveThumbnails(photo.ProjectID, photo.ID)
+			services.ReplaceCoverPhoto(photo.ProjectID, services.CoverPhotoName(photo), "")
+		}
+		if rawMissing {
+			services.RemoveConvertedJPEG(photo.ID)
+		}
+		services.EnqueueProjectShareCards(photo.ProjectID)
+		repaired++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"repaired": repaired,
+		"deleted":  deleted,
+		"failed":   failed,
+	})
+}
+
+// ScanProject reconciles a project with the files in its directory (see
+// services.ScanProject), e.g. after copying files in with rsync
+func ScanProject(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.Select("id, name").First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	result, err := services.ScanProject(services.Queue, &project)
+	if errors.Is(err, services.ErrScanNeedsLocalStorage) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scanning needs local storage"})
+		return
+	}
+	if err != nil {
+		log.Printf("[Integrity] Failed to scan project %d: %v", project.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan project"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
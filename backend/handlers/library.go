@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// capturedAtExpr orders photos by EXIF capture time, falling back to upload time
+const capturedAtExpr = common.CapturedAtExpr
+
+const (
+	defaultTimelinePageSize = 100
+	maxTimelinePageSize     = 500
+)
+
+// timelineBucketFormats maps the granularity parameter to its date key layout
+var timelineBucketFormats = map[string]string{
+	"day":   "2006-01-02",
+	"month": "2006-01",
+	"year":  "2006",
+}
+
+// LibraryPhoto is a photo with its project context, used by cross-project listings
+type LibraryPhoto struct {
+	models.Photo
+	ProjectName string    `json:"project_name"`
+	CapturedAt  time.Time `json:"captured_at"` // taken_at, or created_at when the capture time is unknown
+	ThumbURL    string    `json:"thumb_url"`
+}
+
+// TimelineBucket groups photos captured in the same day/month/year
+type TimelineBucket struct {
+	Date   string         `json:"date"`
+	Count  int            `json:"count"`
+	Photos []LibraryPhoto `json:"photos"`
+}
+
+// toLibraryPhotos attaches project names and thumbnail URLs to photos
+func toLibraryPhotos(photos []models.Photo) []LibraryPhoto {
+	projectIDs := make([]uint, 0, len(photos))
+	for _, photo := range photos {
+		projectIDs = append(projectIDs, photo.ProjectID)
+	}
+
+	projectNames := make(map[uint]string)
+	if len(projectIDs) > 0 {
+		var projects []models.Project
+		database.DB.Select("id, name").Where("id IN ?", projectIDs).Find(&projects)
+		for _, project := range projects {
+			projectNames[project.ID] = project.Name
+		}
+	}
+
+	result := make([]LibraryPhoto, 0, len(photos))
+	for _, photo := range photos {
+		capturedAt := photo.CreatedAt
+		if photo.TakenAt != nil {
+			capturedAt = *photo.TakenAt
+		}
+		result = append(result, LibraryPhoto{
+			Photo:       photo,
+			ProjectName: projectNames[photo.ProjectID],
+			CapturedAt:  capturedAt,
+			ThumbURL:    imageURL(photo.ID, "", models.ImageSizeSmall, ""),
+		})
+	}
+	return result
+}
+
+// GetTimeline returns photos across all projects, newest capture first, bucketed by date.
+// Query: granularity=day|month|year (default month), from/to=YYYY-MM-DD, page, page_size.
+// A bucket can continue on the next page; clients merge buckets with the same date.
+func GetTimeline(c *gin.Context) {
+	granularity := c.DefaultQuery("granularity", "month")
+	layout, ok := timelineBucketFormats[granularity]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be day, month or year"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultTimelinePageSize)))
+	if pageSize < 1 || pageSize > maxTimelinePageSize {
+		pageSize = defaultTimelinePageSize
+	}
+
+	query := database.DB.Model(&models.Photo{})
+	if from := c.Query("from"); from != "" {
+		fromDate, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		query = query.Where(capturedAtExpr+" >= ?", fromDate)
+	}
+	if to := c.Query("to"); to != "" {
+		toDate, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		// Inclusive: everything before the start of the following day
+		query = query.Where(capturedAtExpr+" < ?", toDate.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var photos []models.Photo
+	if err := query.Select(photoMetaColumns).
+		Order(capturedAtExpr + " DESC").Order("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&photos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	buckets := []TimelineBucket{}
+	for _, photo := range toLibraryPhotos(photos) {
+		date := photo.CapturedAt.Format(layout)
+		if len(buckets) == 0 || buckets[len(buckets)-1].Date != date {
+			buckets = append(buckets, TimelineBucket{Date: date})
+		}
+		bucket := &buckets[len(buckets)-1]
+		bucket.Photos = append(bucket.Photos, photo)
+		bucket.Count++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"buckets":     buckets,
+		"granularity": granularity,
+		"page":        page,
+		"page_size":   pageSize,
+		"total":       total,
+		"has_more":    int64(page*pageSize) < total,
+	})
+}
+
+const (
+	defaultRecentLimit = 20
+	maxRecentLimit     = 100
+)
+
+// GetRecentPhotos returns the last N uploaded photos across all projects (?limit=, default 20)
+// for the admin activity feed
+func GetRecentPhotos(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultRecentLimit)))
+	if limit < 1 {
+		limit = defaultRecentLimit
+	}
+	if limit > maxRecentLimit {
+		limit = maxRecentLimit
+	}
+
+	var photos []models.Photo
+	if err := database.DB.Select(photoMetaColumns).
+		Order("created_at DESC").Order("id DESC").
+		Limit(limit).Find(&photos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"photos": toLibraryPhotos(photos)}
+	// Lets automated ingestion be checked at a glance
+	if len(photos) > 0 {
+		response["last_upload_at"] = photos[0].CreatedAt
+	} else {
+		response["last_upload_at"] = nil
+	}
+
+	c.JSON(http.StatusOK, response)
+}
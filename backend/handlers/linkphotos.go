@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LinkPhoto is a photo of a link's project with its inclusion in the link
+type LinkPhoto struct {
+	models.Photo
+	Included        bool   `json:"included"`                   // Not excluded from the link
+	Visible         bool   `json:"visible"`                    // Included and within the link's date range
+	ExclusionReason string `json:"exclusion_reason,omitempty"` // Only for excluded photos
+	ExclusionNote   string `json:"exclusion_note,omitempty"`
+}
+
+// GetLinkPhotos lists every photo of a link's project with whether the link includes
+// it, paged, sorted and filtered like the admin listing. ?included=true|false only
+// lists included or excluded photos.
+func GetLinkPhotos(c *gin.Context) {
+	var link models.ShareLink
+	if err := database.DB.First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	var q models.LinkPhotoQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.DB.Preload("Tags").Where("project_id = ?", link.ProjectID)
+	if q.Included != nil {
+		excluded := database.DB.Model(&models.PhotoExclusion{}).Select("photo_id").Where("link_id = ?", link.ID)
+		if *q.Included {
+			query = query.Where("id NOT IN (?)", excluded)
+		} else {
+			query = query.Where("id IN (?)", excluded)
+		}
+	}
+	var photos []models.Photo
+	total, err := services.ListPhotos(query, photoAdminColumns, q.PhotoListQuery, &photos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
+
+	response, err := linkPhotos(&link, photos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exclusions"})
+		return
+	}
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, response)
+}
+
+// SetLinkPhotoIncluded includes one photo in a share link or excludes it, so large
+// galleries can be curated one photo at a time. Like PatchShareLinkExclusions,
+// If-Match is optional and the link's version is bumped either way.
+func SetLinkPhotoIncluded(c *gin.Context) {
+	var link models.ShareLink
+	if err := database.DB.First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	ifVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+	var req models.SetPhotoIncludedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	photoID, _ := strconv.ParseUint(c.Param("photoId"), 10, 32)
+	var photo models.Photo
+	if err := database.DB.Preload("Tags").Select(photoAdminColumns).
+		Where("project_id = ?", link.ProjectID).First(&photo, photoID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+
+	if !bumpLinkVersion(c, &link, ifVersion) {
+		return
+	}
+	var add, remove []uint
+	if *req.Included {
+		remove = []uint{photo.ID}
+	} else {
+		add = []uint{photo.ID}
+	}
+	if err := common.ApplyExclusionChanges(link.ID, add, remove, req.Reason, req.Note); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exclusions"})
+		return
+	}
+	services.EnqueueShareCard(link.ID)
+
+	database.DB.First(&link, link.ID)
+	response, err := linkPhotos(&link, []models.Photo{photo})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exclusions"})
+		return
+	}
+	setVersionETag(c, link.Version)
+	c.JSON(http.StatusOK, response[0])
+}
+
+// linkPhotos adds the inclusion state in a link to photos of its project
+func linkPhotos(link *models.ShareLink, photos []models.Photo) ([]LinkPhoto, error) {
+	ids := make([]uint, len(photos))
+	for i, photo := range photos {
+		ids[i] = photo.ID
+	}
+	var exclusions []models.PhotoExclusion
+	if len(ids) > 0 {
+		if err := database.DB.Where("link_id = ? AND photo_id IN ?", link.ID, ids).Find(&exclusions).Error; err != nil {
+			return nil, err
+		}
+	}
+	excluded := make(map[uint]*models.PhotoExclusion, len(exclusions))
+	for i := range exclusions {
+		excluded[exclusions[i].PhotoID] = &exclusions[i]
+	}
+	visibleIDs, err := common.VisiblePhotoIDs(link, ids)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[uint]bool, len(visibleIDs))
+	for _, id := range visibleIDs {
+		visible[id] = true
+	}
+
+	response := make([]LinkPhoto, len(photos))
+	for i, photo := range photos {
+		response[i] = LinkPhoto{Photo: photo, Included: true, Visible: visible[photo.ID]}
+		if exclusion, ok := excluded[photo.ID]; ok {
+			response[i].Included = false
+			response[i].ExclusionReason = exclusion.Reason
+			response[i].ExclusionNote = exclusion.Note
+		}
+	}
+	return response, nil
+}
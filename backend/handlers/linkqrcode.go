@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetShareLinkQRCode renders the URL of a share link (under PUBLIC_URL) as a QR code
+// for printed cards, as PNG or SVG. A POST may send the link's password in its body,
+// which goes into the URL fragment that browsers don't send to the server; the gallery
+// reads it from there and unlocks itself. Like gallery emails, the password must be
+// the link's.
+func GetShareLinkQRCode(c *gin.Context) {
+	var link models.ShareLink
+	if err := database.DB.First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	var q models.LinkQRCodeRequest
+	bind := c.ShouldBindQuery
+	if c.Request.Method == http.MethodPost {
+		bind = c.ShouldBindJSON
+	}
+	if err := bind(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.Password != "" && (!link.PasswordEnabled || !utils.VerifyPassword(link.PasswordHash, q.Password)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password does not match the link's password"})
+		return
+	}
+
+	target := services.ShareURL(publicBaseURL(c), link.Token)
+	if q.Password != "" {
+		target += "#password=" + url.QueryEscape(q.Password)
+	}
+
+	render, contentType := utils.RenderQRCodePNG, "image/png"
+	if q.Format == models.QRCodeFormatSVG {
+		render, contentType = utils.RenderQRCodeSVG, "image/svg+xml"
+	}
+	data, err := render(target, q.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"link-%s.%s\"", link.Token, q.Format))
+	// The code may carry the password
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, contentType, data)
+}
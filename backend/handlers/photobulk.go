@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BulkDeletePhotos deletes many photos at once, across projects. The response lists
+// the deleted IDs and those that didn't exist.
+func BulkDeletePhotos(c *gin.Context) {
+	var req models.BulkPhotosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.DeletePhotos(uniqueIDs(req.PhotoIDs))
+	if err != nil {
+		log.Printf("[Bulk] Bulk delete failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photos"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"deleted":   result.Done,
+		"not_found": result.NotFound,
+	})
+}
+
+// BulkMovePhotos moves photos to another project with their files and thumbnails.
+// Photos whose name is already taken in the target project are reported as conflicts
+// and stay where they are.
+func BulkMovePhotos(c *gin.Context) {
+	var req models.BulkMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.MovePhotos(uniqueIDs(req.PhotoIDs), req.ProjectID)
+	if errors.Is(err, services.ErrTargetProjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("[Bulk] Bulk move to project %d failed: %v", req.ProjectID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move photos"})
+		return
+	}
+	conflicts := result.Conflicts
+	if conflicts == nil {
+		conflicts = []uint{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"moved":     result.Done,
+		"not_found": result.NotFound,
+		"conflicts": conflicts,
+	})
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PhotoFileDetail describes one file of a photo as stored on disk
+type PhotoFileDetail struct {
+	Type     string `json:"type"` // normal or raw
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	Ext      string `json:"ext"`
+	Hash     string `json:"hash"`
+	Size     int64  `json:"size"`    // 0 when missing
+	Missing  bool   `json:"missing"` // referenced in the database but absent on disk
+}
+
+// PhotoLinkRef identifies a share link in the photo detail
+type PhotoLinkRef struct {
+	ID    uint   `json:"id"`
+	Alias string `json:"alias"`
+	Token string `json:"token"`
+}
+
+// PhotoExclusionRef is a link hiding the photo, with the reason recorded for it
+type PhotoExclusionRef struct {
+	PhotoLinkRef
+	Reason     string     `json:"reason,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	ExcludedAt *time.Time `json:"excluded_at,omitempty"` // nil for exclusions older than reasons
+}
+
+// PhotoDetail is everything the admin photo inspector shows about one photo
+type PhotoDetail struct {
+	models.Photo
+	ProjectName     string              `json:"project_name"`
+	IsCover         bool                `json:"is_cover"`
+	Files           []PhotoFileDetail   `json:"files"`
+	Exif            ExifInfo            `json:"exif"`
+	ThumbStatus     string              `json:"thumb_status"`      // ready, queued, pending or raw_only
+	ExcludedFrom    []PhotoExclusionRef `json:"excluded_from"`     // links hiding the photo
+	RawExcludedFrom []PhotoLinkRef      `json:"raw_excluded_from"` // links hiding only its RAW file
+	HighlightedIn   []PhotoLinkRef      `json:"highlighted_in"`    // links featuring it in the hero strip
+	SelectedIn      []PhotoLinkRef      `json:"selected_in"`       // links whose visitors picked it
+}
+
+// GetPhotoDetail returns a photo with its files, EXIF summary, thumbnail state and
+// the share links that exclude, highlight or select it
+func GetPhotoDetail(c *gin.Context) {
+	var photo models.Photo
+	if err := database.DB.Select(photoAdminColumns).Preload("Project").Preload("Tags").First(&photo, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	project := photo.Project
+
+	detail := PhotoDetail{
+		Photo:       photo,
+		ProjectName: project.Name,
+		IsCover:     project.CoverPhoto != "" && project.CoverPhoto == services.CoverPhotoName(&photo),
+		Files:       []PhotoFileDetail{},
+		ThumbStatus: services.Queue.CurrentThumbnailStatus(&photo),
+	}
+
+	encodedProjectName := url.PathEscape(project.Name)
+	encodedBaseName := url.PathEscape(photo.BaseName)
+	addFile := func(kind, ext, hash string) {
+		file := PhotoFileDetail{
+			Type:     kind,
+			Filename: photo.BaseName + ext,
+			URL:      utils.SignUploadURL("/uploads/" + encodedProjectName + "/" + encodedBaseName + ext),
+			Ext:      ext,
+			Hash:     hash,
+			Missing:  true,
+		}
+		if info, err := statPhotoFile(project.Name, &photo, kind); err == nil {
+			file.Size = info.Size
+			file.Missing = false
+		}
+		detail.Files = append(detail.Files, file)
+	}
+	if photo.NormalExt != "" {
+		hash := photo.NormalHash
+		if hash == "" {
+			hash = photo.FileHash // records from before normal_hash existed
+		}
+		addFile("normal", photo.NormalExt, hash)
+	}
+	if photo.HasRaw && photo.RawExt != "" {
+		addFile("raw", photo.RawExt, photo.RawHash)
+	}
+
+	photoExif(&photo, project.Name)
+	detail.Exif = buildExifInfo(&photo, false)
+
+	var err error
+	if detail.ExcludedFrom, err = linksExcludingPhoto(photo.ID); err == nil {
+		if detail.RawExcludedFrom, err = linksReferencingPhoto(&models.RawExclusion{}, photo.ID); err == nil {
+			if detail.HighlightedIn, err = linksReferencingPhoto(&models.PhotoHighlight{}, photo.ID); err == nil {
+				detail.SelectedIn, err = linksReferencingPhoto(&models.PhotoSelection{}, photo.ID)
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// linksExcludingPhoto returns the share links hiding photoID with their exclusion reasons
+func linksExcludingPhoto(photoID uint) ([]PhotoExclusionRef, error) {
+	var rows []struct {
+		PhotoLinkRef
+		Reason    string
+		Note      string
+		CreatedAt time.Time
+	}
+	err := database.DB.Table("photo_exclusions").
+		Select("share_links.id, share_links.alias, share_links.token, photo_exclusions.reason, photo_exclusions.note, photo_exclusions.created_at").
+		Joins("JOIN share_links ON share_links.id = photo_exclusions.link_id AND share_links.deleted_at IS NULL").
+		Where("photo_exclusions.photo_id = ?", photoID).Order("share_links.id").Scan(&rows).Error
+
+	refs := make([]PhotoExclusionRef, 0, len(rows))
+	for _, row := range rows {
+		ref := PhotoExclusionRef{PhotoLinkRef: row.PhotoLinkRef, Reason: row.Reason, Note: row.Note}
+		if !row.CreatedAt.IsZero() {
+			createdAt := row.CreatedAt
+			ref.ExcludedAt = &createdAt
+		}
+		refs = append(refs, ref)
+	}
+	return refs, err
+}
+
+// linksReferencingPhoto returns the share links with a row for photoID in the given
+// link/photo join table (exclusions, RAW exclusions, highlights or selections)
+func linksReferencingPhoto(table interface{}, photoID uint) ([]PhotoLinkRef, error) {
+	refs := []PhotoLinkRef{}
+	linkIDs := database.DB.Model(table).Select("link_id").Where("photo_id = ?", photoID)
+	err := database.DB.Model(&models.ShareLink{}).Select("id, alias, token").
+		Where("id IN (?)", linkIDs).Order("id").Scan(&refs).Error
+	return refs, err
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportPhotoMetadata applies a CSV of tags, ratings and captions to the photos of a
+// project in one transaction. The CSV is the request body, or the "file" field of a
+// multipart form. Tags are added to existing ones unless ?replace_tags=true.
+func ImportPhotoMetadata(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.Select("id, name").First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, models.MaxMetadataCSVSize)
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			if isTooLarge(err) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "CSV file is too large"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing CSV file"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV file"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	rows, err := services.ParsePhotoMetadataCSV(body)
+	if err != nil {
+		if isTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "CSV file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.ImportPhotoMetadata(project.ID, rows, c.Query("replace_tags") == "true")
+	if err != nil {
+		log.Printf("[Metadata] Import into project %s failed: %v", project.Name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import metadata"})
+		return
+	}
+	log.Printf("[Metadata] Imported metadata of %d photos into project %s (%d not found)", result.Updated, project.Name, len(result.NotFound))
+	c.JSON(http.StatusOK, result)
+}
+
+// isTooLarge reports whether reading a body failed on http.MaxBytesReader's limit
+func isTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// searchPhotoColumns adds what a search matches on besides the base name
+const searchPhotoColumns = photoAdminColumns + ", exif_camera_make, exif_camera_model, exif_lens_model"
+
+// SearchPhoto is a photo in search results, with its project and camera
+type SearchPhoto struct {
+	models.Photo
+	ProjectName string `json:"project_name"`
+	CameraMake  string `json:"camera_make,omitempty"`
+	CameraModel string `json:"camera_model,omitempty"`
+	LensModel   string `json:"lens_model,omitempty"`
+}
+
+// SearchPhotos finds photos across projects by base name, tag, camera or lens. Every
+// word of ?q= must match; the listing parameters of models.PhotoListQuery apply and
+// results are always paged. X-Total-Count has the number of matches.
+func SearchPhotos(c *gin.Context) {
+	var q models.PhotoSearchQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.DB.Preload("Tags").Preload("Project", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id, name")
+	})
+	var photos []models.Photo
+	total, err := services.SearchPhotos(query, searchPhotoColumns, q, &photos)
+	if err != nil {
+		log.Printf("[Search] Photo search %q failed: %v", q.Q, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search photos"})
+		return
+	}
+
+	items := make([]SearchPhoto, len(photos))
+	for i, photo := range photos {
+		items[i] = SearchPhoto{
+			Photo:       photo,
+			ProjectName: photo.Project.Name,
+			CameraMake:  photo.Exif.CameraMake,
+			CameraModel: photo.Exif.CameraModel,
+			LensModel:   photo.Exif.LensModel,
+		}
+	}
+	perPage, _ := q.Limit()
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, gin.H{
+		"photos":   items,
+		"page":     q.Page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+// GetTags lists the tags on photos, by name, with how many photos have each
+func GetTags(c *gin.Context) {
+	tags, err := services.ListTags()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// TagPhotos adds tags to photos across projects, creating new tags as needed. The
+// response lists the tagged IDs, those that didn't exist and the number of new tags.
+func TagPhotos(c *gin.Context) {
+	req, ok := bindTagPhotosRequest(c)
+	if !ok {
+		return
+	}
+	result, created, err := services.TagPhotos(uniqueIDs(req.PhotoIDs), req.Tags)
+	if err != nil {
+		log.Printf("[Bulk] Bulk tag failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to tag photos"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tagged":       result.Done,
+		"not_found":    result.NotFound,
+		"tags_created": created,
+	})
+}
+
+// UntagPhotos removes tags from photos; the tags stay on other photos
+func UntagPhotos(c *gin.Context) {
+	req, ok := bindTagPhotosRequest(c)
+	if !ok {
+		return
+	}
+	result, err := services.UntagPhotos(uniqueIDs(req.PhotoIDs), req.Tags)
+	if err != nil {
+		log.Printf("[Bulk] Bulk untag failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to untag photos"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"untagged":  result.Done,
+		"not_found": result.NotFound,
+	})
+}
+
+// bindTagPhotosRequest binds and validates the body of a tag or untag request
+func bindTagPhotosRequest(c *gin.Context) (models.TagPhotosRequest, bool) {
+	var req models.TagPhotosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	return req, true
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// projectEventHeartbeat keeps idle event streams open through proxies that close
+// silent connections
+const projectEventHeartbeat = 20 * time.Second
+
+// GetProjectEvents streams the upload and thumbnail progress of a project as
+// server-sent events, named after their type (see services.ProjectEvent), until the
+// client disconnects. A "ready" event is sent once subscribed. Events are only sent
+// live: a client that reconnects should reload the photo list for what it missed.
+func GetProjectEvents(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	events, unsubscribe := services.SubscribeProjectEvents(project.ID)
+	defer unsubscribe()
+	heartbeat := time.NewTicker(projectEventHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // Don't let nginx buffer the stream
+	c.SSEvent("ready", gin.H{"project_id": project.ID})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+		case <-heartbeat.C:
+			io.WriteString(w, ": ping\n\n")
+		}
+		return true
+	})
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/middleware"
+	"photobridge/models"
+	"photobridge/services"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The read API (/api/v1) is the stable, read-only surface for external gallery
+// frontends and static site generators. Any API key can use it; a key limited to one
+// project only sees that project. Lists are always paged and answer
+// {"data", "page", "per_page", "total"}; ?fields= picks the fields of each object.
+// Images are fetched from /api/image with the same X-API-Key.
+
+// readPhotoColumns selects what the read API can return about a photo
+const readPhotoColumns = photoAdminColumns + ", " + common.PhotoExifColumns
+
+// GetReadProjects lists the projects the API key reaches, by name
+func GetReadProjects(c *gin.Context) {
+	var q models.ReadProjectQuery
+	if !bindReadQuery(c, &q) {
+		return
+	}
+	fields, _ := models.ParseFields(q.Fields, models.ReadProjectFields)
+
+	query := database.DB.Model(&models.Project{})
+	if key := middleware.CurrentAPIKey(c); key != nil && key.ProjectID != nil {
+		query = query.Where("id = ?", *key.ProjectID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
+		return
+	}
+	perPage, offset := q.Limit()
+	var projects []models.Project
+	if err := query.Order("name").Limit(perPage).Offset(offset).Find(&projects).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
+		return
+	}
+
+	data := make([]gin.H, len(projects))
+	for i := range projects {
+		data[i] = readProject(&projects[i], fields)
+	}
+	respondReadPage(c, data, max(q.Page, 1), perPage, total)
+}
+
+// GetReadProject returns one project by name
+func GetReadProject(c *gin.Context) {
+	var q models.ReadProjectQuery
+	if !bindReadQuery(c, &q) {
+		return
+	}
+	fields, _ := models.ParseFields(q.Fields, models.ReadProjectFields)
+
+	project, ok := findReadProject(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, readProject(project, fields))
+}
+
+// GetReadProjectPhotos lists the photos of a project, paged, sorted and filtered like
+// the admin listing (see models.PhotoListQuery)
+func GetReadProjectPhotos(c *gin.Context) {
+	var q models.ReadPhotoQuery
+	if !bindReadQuery(c, &q) {
+		return
+	}
+	fields, _ := models.ParseFields(q.Fields, models.ReadPhotoFields)
+	if q.Page == 0 {
+		q.Page = 1
+	}
+
+	project, ok := findReadProject(c)
+	if !ok {
+		return
+	}
+	query := database.DB.Where("project_id = ?", project.ID)
+	if slices.Contains(fields, "tags") {
+		query = query.Preload("Tags")
+	}
+	var photos []models.Photo
+	total, err := services.ListPhotos(query, readPhotoColumns, q.PhotoListQuery, &photos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
+
+	data := make([]gin.H, len(photos))
+	for i := range photos {
+		data[i] = readPhoto(&photos[i], fields)
+	}
+	perPage, _ := q.Limit()
+	respondReadPage(c, data, q.Page, perPage, total)
+}
+
+// GetReadPhoto returns one photo by ID
+func GetReadPhoto(c *gin.Context) {
+	var q models.ReadPhotoQuery
+	if !bindReadQuery(c, &q) {
+		return
+	}
+	fields, _ := models.ParseFields(q.Fields, models.ReadPhotoFields)
+
+	query := database.DB.Select(readPhotoColumns)
+	if slices.Contains(fields, "tags") {
+		query = query.Preload("Tags")
+	}
+	var photo models.Photo
+	if err := query.First(&photo, c.Param("photoId")).Error; err != nil || !apiKeyReachesPhoto(c, &photo) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	c.JSON(http.StatusOK, readPhoto(&photo, fields))
+}
+
+// readQuery is a query of the read API
+type readQuery interface {
+	Validate() error
+}
+
+// bindReadQuery binds and validates the query parameters of a read API request
+func bindReadQuery(c *gin.Context, q readQuery) bool {
+	if err := c.ShouldBindQuery(q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// respondReadPage answers one page of a read API list; X-Total-Count has the total too
+func respondReadPage(c *gin.Context, data []gin.H, page, perPage int, total int64) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, gin.H{
+		"data":     data,
+		"page":     page,
+		"per_page": perPage,
+		"total":    total,
+	})
+}
+
+// findReadProject loads the :project of a read API request (APIKeyAuth checked the scope)
+func findReadProject(c *gin.Context) (*models.Project, bool) {
+	name, valid := utils.SanitizeProjectName(c.Param("project"))
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project name"})
+		return nil, false
+	}
+	var project models.Project
+	if err := database.DB.Where("name = ?", name).First(&project).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+	return &project, true
+}
+
+// apiKeyReachesPhoto reports whether the request's API key may read a photo: its
+// project must exist (not be in the trash) and be within the key's scope
+func apiKeyReachesPhoto(c *gin.Context, photo *models.Photo) bool {
+	if key := middleware.CurrentAPIKey(c); key != nil && key.ProjectID != nil && *key.ProjectID != photo.ProjectID {
+		return false
+	}
+	var project models.Project
+	return database.DB.Select("id").First(&project, photo.ProjectID).Error == nil
+}
+
+// readProject returns the requested fields of a project
+func readProject(project *models.Project, fields []string) gin.H {
+	item := make(gin.H, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "id":
+			item[field] = project.ID
+		case "name":
+			item[field] = project.Name
+		case "description":
+			item[field] = project.Description
+		case "cover_photo":
+			item[field] = project.CoverPhoto
+		case "cover_url":
+			item[field] = coverThumbURL(project)
+		case "photo_count":
+			item[field] = common.CountPhotosInProject(project.ID)
+		case "created_at":
+			item[field] = project.CreatedAt
+		case "updated_at":
+			item[field] = project.UpdatedAt
+		}
+	}
+	return item
+}
+
+// coverThumbURL returns the large thumbnail URL of a project's cover photo, nil without one
+func coverThumbURL(project *models.Project) interface{} {
+	if project.CoverPhoto == "" {
+		return nil
+	}
+	ext := filepath.Ext(project.CoverPhoto)
+	var photo models.Photo
+	if err := database.DB.Select("id").Where("project_id = ? AND base_name = ? AND normal_ext = ?",
+		project.ID, strings.TrimSuffix(project.CoverPhoto, ext), ext).First(&photo).Error; err != nil {
+		return nil
+	}
+	return imageURL(photo.ID, "", models.ImageSizeLarge, "")
+}
+
+// readPhoto returns the requested fields of a photo; URLs of files the photo doesn't
+// have are null
+func readPhoto(photo *models.Photo, fields []string) gin.H {
+	item := make(gin.H, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "id":
+			item[field] = photo.ID
+		case "project_id":
+			item[field] = photo.ProjectID
+		case "base_name":
+			item[field] = photo.BaseName
+		case "normal_ext":
+			item[field] = photo.NormalExt
+		case "raw_ext":
+			item[field] = photo.RawExt
+		case "has_raw":
+			item[field] = photo.HasRaw
+		case "width":
+			item[field] = photo.Width
+		case "height":
+			item[field] = photo.Height
+		case "thumb_width":
+			item[field] = photo.ThumbWidth
+		case "thumb_height":
+			item[field] = photo.ThumbHeight
+		case "normal_size":
+			item[field] = photo.NormalSize
+		case "raw_size":
+			item[field] = photo.RawSize
+		case "taken_at":
+			item[field] = photo.TakenAt
+		case "created_at":
+			item[field] = photo.CreatedAt
+		case "updated_at":
+			item[field] = photo.UpdatedAt
+		case "rating":
+			item[field] = photo.Rating
+		case "caption":
+			item[field] = photo.Caption
+		case "tags":
+			tags := make([]string, len(photo.Tags))
+			for i, tag := range photo.Tags {
+				tags[i] = tag.Name
+			}
+			item[field] = tags
+		case "exif":
+			item[field] = photo.Exif
+		case "thumb_small_url":
+			item[field] = imageURL(photo.ID, "", models.ImageSizeSmall, "")
+		case "thumb_large_url":
+			item[field] = imageURL(photo.ID, "", models.ImageSizeLarge, "")
+		case "original_url":
+			item[field] = nil
+			if _, ok := originalImageType(photo, models.ImageFormatAuto); ok {
+				item[field] = imageURL(photo.ID, "", models.ImageSizeOriginal, "")
+			}
+		case "raw_url":
+			item[field] = nil
+			if _, ok := originalImageType(photo, models.ImageFormatRaw); ok {
+				item[field] = imageURL(photo.ID, "", models.ImageSizeOriginal, models.ImageFormatRaw)
+			}
+		}
+	}
+	return item
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkLoginBackoff refuses a login that has to wait after recent failures with 429
+// and Retry-After, recording it as blocked. The login goes ahead if the attempts
+// can't be read.
+func checkLoginBackoff(c *gin.Context, attempt *models.LoginAttempt) bool {
+	wait, err := services.LoginRetryAfter(attempt.IP, attempt.Username, attempt.CreatedAt)
+	if err != nil {
+		log.Printf("[Admin] Failed to check login attempts: %v", err)
+		return true
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	attempt.Blocked = true
+	services.RecordLoginAttempt(attempt)
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "too_many_attempts",
+		"message":     "Too many failed logins, try again later",
+		"retry_after": seconds,
+	})
+	return false
+}
+
+// GetLoginAudit lists recent admin login attempts with their IP and country, newest
+// first; ?ip, ?username and ?success filter them
+func GetLoginAudit(c *gin.Context) {
+	var q models.LoginAuditQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attempts, err := services.LoginAudit(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login attempts"})
+		return
+	}
+	c.JSON(http.StatusOK, attempts)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"photobridge/database"
+	"photobridge/middleware"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Selections (proofing): visitors of a link with the proofing preference pick their
+// favorite photos, and the photographer exports the picks from the admin panel.
+
+// respondSelections writes the visible selection of a link
+func respondSelections(c *gin.Context, link *models.ShareLink) {
+	ids, err := services.SelectedPhotoIDs(link)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load selections"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"photo_ids": ids, "count": len(ids)})
+}
+
+// GetShareSelections returns the photos picked on a share link, in the order they were picked
+func GetShareSelections(c *gin.Context) {
+	respondSelections(c, middleware.ShareLink(c))
+}
+
+// UpdateShareSelections adds and removes picks on a share link. Links without the
+// proofing preference are read-only (403); photos the link doesn't show are skipped.
+func UpdateShareSelections(c *gin.Context) {
+	link := middleware.ShareLink(c)
+	if !link.Preferences.Proofing {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Selections are not enabled for this link"})
+		return
+	}
+
+	var req models.UpdateSelectionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.UpdateSelections(link, req.Add, req.Remove); err != nil {
+		log.Printf("[Share] Failed to update selections of link %d: %v", link.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update selections"})
+		return
+	}
+	respondSelections(c, link)
+}
+
+// SubmitShareSelections tells the photographer the client has finished picking: the
+// link records when, and ADMIN_EMAIL is emailed the picks (MAIL_ON_SELECTIONS)
+func SubmitShareSelections(c *gin.Context) {
+	link := middleware.ShareLink(c)
+	if !link.Preferences.Proofing {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Selections are not enabled for this link"})
+		return
+	}
+
+	var req models.SubmitSelectionsRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := services.SubmitSelections(link, time.Now())
+	if errors.Is(err, services.ErrNothingSelected) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Select at least one photo before submitting"})
+		return
+	}
+	if err != nil {
+		log.Printf("[Share] Failed to submit selections of link %d: %v", link.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit selections"})
+		return
+	}
+	services.NotifySelectionsSubmitted(link, publicBaseURL(c), req.Note)
+	c.JSON(http.StatusOK, gin.H{"count": count, "submitted_at": link.SubmittedAt})
+}
+
+// GetLinkSelections reports the photos picked on a link. With ?format=csv the report is
+// a CSV file, with ?format=txt a list of file names (one per line) for pasting into
+// an editor's filename filter.
+func GetLinkSelections(c *gin.Context) {
+	// Selections outlive their link, so those of expired links can still be exported
+	var link models.ShareLink
+	if err := database.DB.Unscoped().First(&link, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	report, err := services.SelectionReport(&link)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fileName := "selections-" + link.Token
+	switch c.Query("format") {
+	case "":
+		c.JSON(http.StatusOK, gin.H{"link_id": link.ID, "count": len(report), "selections": report})
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"photo_id", "base_name", "file_name", "raw_name", "selected_at", "hidden"})
+		for _, row := range report {
+			w.Write([]string{
+				strconv.FormatUint(uint64(row.PhotoID), 10), row.BaseName, row.FileName, row.RawName,
+				row.SelectedAt.UTC().Format(time.RFC3339), strconv.FormatBool(row.Hidden),
+			})
+		}
+		w.Flush()
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", fileName))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	case "txt":
+		var sb strings.Builder
+		for _, row := range report {
+			sb.WriteString(row.FileName + "\n")
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.txt\"", fileName))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(sb.String()))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or txt"})
+	}
+}
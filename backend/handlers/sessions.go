@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/middleware"
+	"photobridge/models"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// refreshReuseGrace is how long a replaced refresh token is refused without revoking
+// its session, for concurrent refreshes (e.g. two browser tabs). Used any later, it
+// was most likely stolen.
+const refreshReuseGrace = 30 * time.Second
+
+// RefreshRequest exchanges a refresh token for new admin tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// adminSessionTTL is how long an admin session lasts without a refresh
+func adminSessionTTL() time.Duration {
+	return time.Duration(config.AppConfig.AdminSessionDays) * 24 * time.Hour
+}
+
+// adminTokenTTL is how long an admin access token is valid
+func adminTokenTTL() time.Duration {
+	return time.Duration(config.AppConfig.AdminTokenMinutes) * time.Minute
+}
+
+// AdminSessionResponse is a signed-in device in the session list
+type AdminSessionResponse struct {
+	models.AdminSession
+	Current bool `json:"current"` // The session making the request
+}
+
+// newSessionJTI generates the random token ID linking an admin token to its session
+func newSessionJTI() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newRefreshToken generates a refresh token and the hash it is stored as
+func newRefreshToken() (token, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token)
+}
+
+// hashRefreshToken returns the stored form of a refresh token
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// adminTokens signs an access token for a session and returns it with the refresh token
+func adminTokens(session *models.AdminSession, refreshToken string, now time.Time) (*LoginResponse, error) {
+	claims := &middleware.Claims{
+		Username: session.Username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        session.JTI,
+			ExpiresAt: jwt.NewNumericDate(now.Add(adminTokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	token, err := utils.AdminJWTKeys().Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResponse{Token: token, RefreshToken: refreshToken, ExpiresIn: int(adminTokenTTL().Seconds())}, nil
+}
+
+// RefreshAdminToken exchanges a session's refresh token for a new access token and a
+// new refresh token, extending the session. Each refresh token works once: using a
+// replaced one again (after refreshReuseGrace) revokes the session.
+func RefreshAdminToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	hash := hashRefreshToken(req.RefreshToken)
+	var session models.AdminSession
+	if err := database.DB.Where("refresh_hash = ? AND expires_at > ?", hash, now).First(&session).Error; err != nil {
+		revokeReusedRefreshToken(hash, now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	refreshToken, refreshHash := newRefreshToken()
+	response, err := adminTokens(&session, refreshToken, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	// Only replaces the token it was given, so of two concurrent refreshes one fails
+	result := database.DB.Model(&models.AdminSession{}).Where("id = ? AND refresh_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_hash":      refreshHash,
+			"prev_refresh_hash": hash,
+			"refreshed_at":      now,
+			"last_seen_at":      now,
+			"ip":                c.ClientIP(),
+			"expires_at":        now.Add(adminSessionTTL()),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// revokeReusedRefreshToken signs out the session whose replaced refresh token was
+// presented again after refreshReuseGrace
+func revokeReusedRefreshToken(hash string, now time.Time) {
+	var session models.AdminSession
+	if err := database.DB.Where("prev_refresh_hash = ?", hash).First(&session).Error; err != nil {
+		return
+	}
+	if session.RefreshedAt != nil && now.Sub(*session.RefreshedAt) < refreshReuseGrace {
+		return
+	}
+	if err := database.DB.Delete(&session).Error; err != nil {
+		log.Printf("[Admin] Failed to revoke session %d: %v", session.ID, err)
+		return
+	}
+	log.Printf("[Admin] Replaced refresh token of session %d used again, session revoked", session.ID)
+}
+
+// truncateString cuts s to at most n bytes without splitting a UTF-8 character
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && (s[n]&0xC0) == 0x80 {
+		n--
+	}
+	return s[:n]
+}
+
+// GetAdminSessions lists the active admin sessions, most recently used first
+func GetAdminSessions(c *gin.Context) {
+	var sessions []models.AdminSession
+	if err := database.DB.Where("expires_at > ?", time.Now()).Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentID := middleware.AdminSessionID(c)
+	response := make([]AdminSessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = AdminSessionResponse{AdminSession: session, Current: session.ID == currentID}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeAdminSession signs out one session; its token is rejected from the next request on
+func RevokeAdminSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	result := database.DB.Delete(&models.AdminSession{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked", "current": uint(id) == middleware.AdminSessionID(c)})
+}
+
+// Logout revokes the session of the request's token
+func Logout(c *gin.Context) {
+	if err := database.DB.Delete(&models.AdminSession{}, middleware.AdminSessionID(c)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
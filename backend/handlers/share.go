@@ -1,20 +1,30 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"photobridge/common"
 	"photobridge/config"
 	"photobridge/database"
+	"photobridge/middleware"
 	"photobridge/models"
+	"photobridge/services"
+	"photobridge/storage"
 	"photobridge/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ShareInfoResponse struct {
@@ -23,15 +33,37 @@ type ShareInfoResponse struct {
 	Alias       string  `json:"alias"`
 	AllowRaw    bool    `json:"allow_raw"`
 	PhotoCount  int     `json:"photo_count"`
+	Highlights  []uint  `json:"highlights"`   // Photo IDs for the hero strip, in display order
 	CDNBaseURL  string  `json:"cdn_base_url"` // CDN base URL for China users, empty if not applicable
-	Country     *string `json:"country"`      // Client's country code from CF-IPCountry header, null if not available
+	Country     *string `json:"country"`      // Client's country code from CF-IPCountry header or GeoIP, null if not available
+	// Locale is the suggested gallery language and LocaleSource where it came from
+	// (link override, Accept-Language, country or default)
+	Locale       string `json:"locale"`
+	LocaleSource string `json:"locale_source"`
+	// Preferences are the link's gallery presentation options; with cover_first set,
+	// CoverPhotoID is the project cover if the link shows it
+	Preferences  models.LinkPreferences `json:"preferences"`
+	CoverPhotoID uint                   `json:"cover_photo_id,omitempty"`
+	// Sizes totals the link's files and estimates the download-all archives, so
+	// clients can show e.g. "Download all (3.2 GB)" before starting
+	Sizes services.ShareSizes `json:"sizes"`
+	// AllowDownload is false for view-only links; AllowZip tells whether archives are
+	// offered and DownloadsLeft how many downloads remain (null = unlimited)
+	AllowDownload bool `json:"allow_download"`
+	AllowZip      bool `json:"allow_zip"`
+	DownloadsLeft *int `json:"downloads_left"`
+	// DownloadJobs tells whether archives can be prepared in the background
+	DownloadJobs bool `json:"download_jobs"`
+	// Albums are the project's sections with the number of photos the link shows in
+	// each (empty ones are left out); GetSharePhotos lists one with ?album_id=
+	Albums []services.AlbumSummary `json:"albums"`
 }
 
 func GetShareInfo(c *gin.Context) {
 	token := c.Param("token")
 	var link models.ShareLink
 
-	result := database.DB.Where("token = ?", token).Preload("Exclusions").Preload("Project").First(&link)
+	result := database.DB.Where("token = ?", token).Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").Preload("Project").First(&link)
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
 		return
@@ -52,34 +84,78 @@ func GetShareInfo(c *gin.Context) {
 	if len(excludedIDs) > 0 {
 		query = query.Where("id NOT IN ?", excludedIDs)
 	}
+	query = common.ApplyDateRange(query, &link).Session(&gorm.Session{})
 	query.Count(&photoCount)
 
-	// Get country from CF-IPCountry header
+	// Get country from CF-IPCountry header (or local GeoIP fallback)
 	var country *string
 	// In development environment (non-Docker), return "DEV" as country
 	if os.Getenv("ENV") != "production" && os.Getenv("DOCKER") != "true" {
 		devCountry := "DEV"
 		country = &devCountry
-	} else if countryHeader := c.GetHeader("CF-IPCountry"); countryHeader != "" {
-		country = &countryHeader
+	} else if clientCountry := utils.GetClientCountry(c); clientCountry != "" {
+		country = &clientCountry
 	}
 
+	countryCode := ""
+	if country != nil {
+		countryCode = *country
+	}
+	locale, localeSource := utils.SuggestLocale(link.Locale, c.GetHeader("Accept-Language"), countryCode)
+
+	sizes, err := services.SummarizeShareSizes(&link)
+	if err != nil {
+		log.Printf("[Share] Failed to summarize sizes of link %d: %v", link.ID, err)
+	}
+
+	var coverPhotoID uint
+	if link.Preferences.CoverFirst {
+		if id := services.CoverPhotoID(&project); id != 0 && common.IsPhotoVisible(&link, id) {
+			coverPhotoID = id
+		}
+	}
+
+	albums, err := services.ListAlbums(project.ID, query, true)
+	if err != nil {
+		log.Printf("[Share] Failed to list the albums of link %d: %v", link.ID, err)
+		albums = []services.AlbumSummary{}
+	}
+
+	recordLinkAccess(c, &link, models.LinkAccessView, 0)
+
 	c.JSON(http.StatusOK, ShareInfoResponse{
-		ProjectName: project.Name,
-		Description: project.Description,
-		Alias:       link.Alias,
-		AllowRaw:    link.AllowRaw,
-		PhotoCount:  int(photoCount),
-		CDNBaseURL:  utils.GetCDNBaseURL(c),
-		Country:     country,
+		ProjectName:   project.Name,
+		Description:   project.Description,
+		Alias:         link.Alias,
+		AllowRaw:      link.AllowRaw && link.AllowDownload,
+		PhotoCount:    int(photoCount),
+		Highlights:    common.FilterDateRange(&link, common.GetHighlightIDs(link.Highlights, excludedIDs)),
+		CDNBaseURL:    utils.GetCDNBaseURL(c),
+		Country:       country,
+		Locale:        locale,
+		LocaleSource:  localeSource,
+		Preferences:   link.Preferences,
+		CoverPhotoID:  coverPhotoID,
+		Sizes:         sizes,
+		AllowDownload: link.AllowDownload,
+		AllowZip:      link.AllowDownload && link.AllowZip,
+		DownloadsLeft: link.DownloadsLeft(),
+		DownloadJobs:  services.DownloadJobs != nil,
+		Albums:        albums,
 	})
 }
 
+// GetSharePhotos lists the photos a share link shows, paged, sorted and filtered like
+// GetProjectPhotos. Highlight positions count every highlighted photo, not just the page.
 func GetSharePhotos(c *gin.Context) {
+	q, ok := bindPhotoListQuery(c)
+	if !ok {
+		return
+	}
 	token := c.Param("token")
 	var link models.ShareLink
 
-	result := database.DB.Where("token = ?", token).Preload("Exclusions").Preload("Project").First(&link)
+	result := database.DB.Where("token = ?", token).Preload("Exclusions").Preload("RawExclusions").Preload("Highlights").Preload("Project").First(&link)
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
 		return
@@ -96,17 +172,33 @@ func GetSharePhotos(c *gin.Context) {
 	excludedIDs := common.GetExcludedIDs(link.Exclusions)
 
 	var photos []models.Photo
-	query := database.DB.Select(photoMetaColumns).Where("project_id = ?", link.ProjectID)
+	query := database.DB.Where("project_id = ?", link.ProjectID)
 	if len(excludedIDs) > 0 {
 		query = query.Where("id NOT IN ?", excludedIDs)
 	}
-	query.Find(&photos)
+	query = common.ApplyDateRange(query, &link)
+	total, err := services.ListPhotos(query, photoMetaColumns, q, &photos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
 
 	// Return photos with URLs
 	type PhotoWithURL struct {
 		models.Photo
-		NormalURL string `json:"normal_url"`
-		RawURL    string `json:"raw_url,omitempty"`
+		NormalURL         string `json:"normal_url,omitempty"`
+		RawURL            string `json:"raw_url,omitempty"`
+		ConvertedURL      string `json:"converted_url,omitempty"` // JPEG rendered from RAW-only and HEIC/HEIF photos
+		Highlight         bool   `json:"highlight"`
+		HighlightPosition *int   `json:"highlight_position,omitempty"`
+		// AspectRatio (width/height) lets masonry layouts reserve space before images load; 0 if unknown
+		AspectRatio float64 `json:"aspect_ratio"`
+	}
+
+	rawExcluded := common.GetRawExcludedIDs(link.RawExclusions)
+	highlightPositions := make(map[uint]int)
+	for i, photoID := range common.FilterDateRange(&link, common.GetHighlightIDs(link.Highlights, excludedIDs)) {
+		highlightPositions[photoID] = i
 	}
 
 	// Get CDN base URL based on client's country (CF-IPCountry header)
@@ -117,139 +209,283 @@ func GetSharePhotos(c *gin.Context) {
 
 	var response []PhotoWithURL
 	for _, photo := range photos {
-		item := PhotoWithURL{Photo: photo}
-		encodedBaseName := url.PathEscape(photo.BaseName)
-		if photo.NormalExt != "" {
-			item.NormalURL = fmt.Sprintf("%s/uploads/%s/%s%s", cdnBase, encodedProjectName, encodedBaseName, photo.NormalExt)
+		item := PhotoWithURL{Photo: photo, AspectRatio: services.PhotoAspectRatio(&photo)}
+		if position, ok := highlightPositions[photo.ID]; ok {
+			item.Highlight = true
+			item.HighlightPosition = &position
 		}
-		if photo.HasRaw && link.AllowRaw && photo.RawExt != "" {
-			item.RawURL = fmt.Sprintf("%s/uploads/%s/%s%s", cdnBase, encodedProjectName, encodedBaseName, photo.RawExt)
+		// View-only links and share access tokens get no originals: the large thumbnail is shown
+		if link.AllowDownload && !middleware.HasShareAccessToken(c) {
+			encodedBaseName := url.PathEscape(photo.BaseName)
+			if photo.NormalExt != "" {
+				item.NormalURL = cdnBase + utils.SignUploadURL(fmt.Sprintf("/uploads/%s/%s%s", encodedProjectName, encodedBaseName, photo.NormalExt))
+			}
+			if photo.HasRaw && link.AllowRaw && photo.RawExt != "" && !rawExcluded[photo.ID] {
+				// Downloads through /uploads can't be counted, so links with a limit use the share route
+				if link.MaxDownloads > 0 {
+					item.RawURL = fmt.Sprintf("/api/share/%s/photo/%d?type=raw", link.Token, photo.ID)
+				} else {
+					item.RawURL = cdnBase + utils.SignUploadURL(fmt.Sprintf("/uploads/%s/%s%s", encodedProjectName, encodedBaseName, photo.RawExt))
+				}
+			}
+			if services.CanConvertToJPEG(&photo) {
+				item.ConvertedURL = imageURL(photo.ID, link.Token, models.ImageSizeOriginal, models.ImageFormatJPEG)
+			}
 		}
 		response = append(response, item)
 	}
 
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if q.GroupBy == models.PhotoGroupDay {
+		c.JSON(http.StatusOK, gin.H{"groups": groupPhotoDays(photos, response)})
+		return
+	}
 	c.JSON(http.StatusOK, response)
 }
 
 func GetSharePhoto(c *gin.Context) {
-	token := c.Param("token")
-	photoIDStr := c.Param("photoId")
 	photoType := c.DefaultQuery("type", "normal") // normal or raw
+	link, photo := middleware.SharePhoto(c)
+	servePhotoFile(c, &link.Project, link, photo, photoType)
+}
 
-	photoIDUint, err := strconv.ParseUint(photoIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
-		return
-	}
-
-	var link models.ShareLink
-	result := database.DB.Where("token = ?", token).Preload("Project").First(&link)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+// servePhotoFile serves an original of a photo: "normal", "raw" or "converted" (the
+// JPEG rendered from the RAW or HEIC/HEIF, also served for normal requests of RAW-only
+// photos).
+// link is the share link of the request, nil for admin requests; view-only links serve
+// no originals, and RAW files need a link allowing them. Downloads through a link (see
+// isShareFileDownload) count against its max_downloads.
+func servePhotoFile(c *gin.Context, project *models.Project, link *models.ShareLink, photo *models.Photo, photoType string) {
+	// 验证项目名称安全性（虽然来自数据库，但做额外验证）
+	if !utils.ValidatePathComponent(project.Name) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid project configuration"})
 		return
 	}
-
-	project := link.Project
-	// Check if project exists (Preload doesn't fail if foreign key references non-existent record)
-	if project.ID == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	if link != nil && !link.AllowDownload {
+		respondDownloadDenied(c, ShareErrDownloadDisabled)
 		return
 	}
-
-	// Check if photo is excluded (optimized: direct query instead of loading all exclusions)
-	if common.IsPhotoExcluded(link.ID, uint(photoIDUint)) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Photo not accessible"})
+	download := link != nil && isShareFileDownload(c, photoType)
+	if download && !checkShareDownload(c, link, false) {
 		return
 	}
-
-	var photo models.Photo
-	// 验证照片属于该分享链接的项目
-	if err := database.DB.Select("id, project_id, base_name, normal_ext, raw_ext, has_raw").
-		Where("id = ? AND project_id = ?", photoIDUint, link.ProjectID).First(&photo).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
-		return
+	disposition, action := "inline", models.AccessView
+	if download {
+		disposition, action = "attachment", models.AccessDownload
 	}
-
-	// 验证项目名称安全性（虽然来自数据库，但做额外验证）
-	if !utils.ValidatePathComponent(project.Name) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid project configuration"})
-		return
+	record := func(file string) {
+		if !servedContent(c) {
+			return
+		}
+		recordOriginalAccess(c, project, link, action, originalAccess{photo.ID, file})
+		if download {
+			recordLinkAccess(c, link, models.LinkAccessDownload, photo.ID)
+		}
 	}
 
-	var filePath string
+	var fileName, hash string
 	if photoType == "raw" {
-		if !link.AllowRaw {
+		if link != nil && (!link.AllowRaw || common.IsRawExcluded(link.ID, photo.ID)) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "RAW download not allowed"})
 			return
 		}
-		filePath = filepath.Join(config.AppConfig.UploadDir, project.Name, photo.BaseName+photo.RawExt)
+		fileName, hash = photo.BaseName+photo.RawExt, photo.RawHash
+	} else if photoType == "converted" || (photo.NormalExt == "" && services.CanConvertRaw(photo)) {
+		// RAW-only photos are served as a JPEG rendered from the RAW when conversion is configured
+		if !services.CanConvertToJPEG(photo) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversion_unavailable", "message": "No converted JPEG available for this photo"})
+			return
+		}
+		convertedPath, err := services.EnsureConvertedJPEG(project.Name, photo)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "conversion_failed", "message": "Failed to convert the photo"})
+			return
+		}
+		if download && !claimShareDownload(c, link) {
+			return
+		}
+		c.Header("Cache-Control", cacheControl(project, "max-age=86400"))
+		c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s.jpg\"", disposition, photo.BaseName))
+		c.File(convertedPath)
+		record(models.AccessFileConverted)
+		return
 	} else {
-		filePath = filepath.Join(config.AppConfig.UploadDir, project.Name, photo.BaseName+photo.NormalExt)
+		fileName, hash = photo.BaseName+photo.NormalExt, photo.NormalHash
+		if hash == "" {
+			hash = photo.FileHash // records from before normal_hash existed
+		}
 	}
 
-	// Validate file path is secure before opening
-	safeFilePath, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, filePath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid file path"})
-		return
+	if download {
+		if !claimShareDownload(c, link) {
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s\"", disposition, fileName))
 	}
 
-	// Open file for ServeContent (handles ETag, If-None-Match, 304, Range requests)
-	file, err := os.Open(safeFilePath)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+	// Set cache headers
+	c.Header("Cache-Control", cacheControl(project, "max-age=31536000"))
+
+	// Strong ETag from the file hash; handles 304 and Range requests (or redirects to a presigned URL)
+	if err := serveOriginal(c, storage.Key(project.Name, fileName), hash, photo.UpdatedAt); err != nil {
+		c.Header("Cache-Control", "")
+		c.Header("Content-Disposition", "")
+		c.Header("ETag", "")
+		c.Header("Last-Modified", "")
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
 		return
 	}
-	defer file.Close()
+	if photoType == "raw" {
+		record(models.AccessFileRaw)
+	} else {
+		record(models.AccessFileNormal)
+	}
+}
+
+// isShareFileDownload reports whether a request for an original through a share link
+// downloads it, counted against the link's max_downloads: RAW files, which galleries
+// can't display, and any file asked for with ?download=true. Full-size views don't count.
+func isShareFileDownload(c *gin.Context, photoType string) bool {
+	return photoType == "raw" || c.Query("download") == "true"
+}
+
+// downloadFiles collects the files of a download. With local storage they are paths
+// below the project directory, so archives can be spooled; with object storage they
+// are zip entries read from the bucket.
+type downloadFiles struct {
+	project string
+	dir     string
+	paths   []string
+	entries []utils.ZipEntry
+	keys    []string        // Storage key of each entry, empty for local files
+	sizes   []utils.ZipFile // Every file in order, to size the archive
+}
 
-	fileInfo, err := file.Stat()
+func newDownloadFiles(projectName string) (*downloadFiles, error) {
+	dir, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, filepath.Join(config.AppConfig.UploadDir, projectName))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file info"})
-		return
+		return nil, err
 	}
+	return &downloadFiles{project: projectName, dir: dir}, nil
+}
 
-	// Set cache headers
-	c.Header("Cache-Control", "public, max-age=31536000")
+// addStored adds a file of the project if it exists, reporting whether it was added.
+// Object storage is not asked up front; missing objects are skipped while the archive
+// is written, and size is the recorded file size.
+func (d *downloadFiles) addStored(fileName string, size int64, modTime time.Time) bool {
+	if storage.IsLocal() {
+		path := filepath.Join(d.dir, fileName)
+		info, err := os.Stat(path)
+		if err != nil {
+			return false
+		}
+		d.paths = append(d.paths, path)
+		d.sizes = append(d.sizes, utils.ZipFile{Name: utils.ZipEntryName(path, d.dir), Size: info.Size()})
+		return true
+	}
+	key := storage.Key(d.project, fileName)
+	d.entries = append(d.entries, utils.ZipEntry{
+		Name:    fileName,
+		ModTime: modTime,
+		Open: func() (io.ReadCloser, error) {
+			return storage.Default().Get(context.Background(), key)
+		},
+	})
+	d.keys = append(d.keys, key)
+	d.sizes = append(d.sizes, utils.ZipFile{Name: fileName, Size: size})
+	return true
+}
 
-	// ServeContent automatically handles ETag, If-None-Match, 304, and Range requests
-	http.ServeContent(c.Writer, c.Request, fileInfo.Name(), fileInfo.ModTime(), file)
+// addLocal adds a local file outside the project directory (a converted RAW),
+// reporting whether it was added
+func (d *downloadFiles) addLocal(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if storage.IsLocal() {
+		d.paths = append(d.paths, path)
+		d.sizes = append(d.sizes, utils.ZipFile{Name: utils.ZipEntryName(path, d.dir), Size: info.Size()})
+		return true
+	}
+	d.entries = append(d.entries, utils.ZipEntry{
+		Name:    filepath.Base(path),
+		ModTime: info.ModTime(),
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	})
+	d.keys = append(d.keys, "")
+	d.sizes = append(d.sizes, utils.ZipFile{Name: filepath.Base(path), Size: info.Size()})
+	return true
 }
 
-// DownloadSinglePhoto - download a single photo with all its files (normal + raw) as zip
-func DownloadSinglePhoto(c *gin.Context) {
-	token := c.Param("token")
-	photoIDStr := c.Param("photoId")
+func (d *downloadFiles) count() int {
+	return len(d.paths) + len(d.entries)
+}
 
-	photoIDUint, err := strconv.ParseUint(photoIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
-		return
+// slice returns the files start to end (exclusive), for one volume of a split download
+func (d *downloadFiles) slice(start, end int) *downloadFiles {
+	part := &downloadFiles{project: d.project, dir: d.dir, sizes: d.sizes[start:end]}
+	if storage.IsLocal() {
+		part.paths = d.paths[start:end]
+	} else {
+		part.entries, part.keys = d.entries[start:end], d.keys[start:end]
 	}
+	return part
+}
 
-	var link models.ShareLink
-	result := database.DB.Where("token = ?", token).Preload("Project").First(&link)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
-		return
-	}
+// zipSize returns the size of the archive and whether it is exact: file sizes are
+// read from disk with local storage, object storage uses the recorded sizes
+func (d *downloadFiles) zipSize() (int64, bool) {
+	return utils.ZipSize(d.sizes), storage.IsLocal()
+}
 
-	project := link.Project
-	// Check if project exists (Preload doesn't fail if foreign key references non-existent record)
-	if project.ID == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-		return
+// serveSingle sends the only file of the download without a zip
+func (d *downloadFiles) serveSingle(c *gin.Context, cache string) {
+	// Set cache headers
+	c.Header("Cache-Control", cache)
+
+	var err error
+	switch {
+	case len(d.paths) == 1:
+		c.File(d.paths[0])
+	case d.keys[0] != "":
+		err = storage.Default().Stream(c.Writer, c.Request, d.keys[0])
+	default:
+		var file io.ReadCloser
+		if file, err = d.entries[0].Open(); err == nil {
+			defer file.Close()
+			http.ServeContent(c.Writer, c.Request, d.entries[0].Name, d.entries[0].ModTime, file.(io.ReadSeeker))
+		}
+	}
+	if err != nil {
+		c.Header("Cache-Control", "")
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
 	}
+}
 
-	// Check if photo is excluded (optimized: direct query instead of loading all exclusions)
-	if common.IsPhotoExcluded(link.ID, uint(photoIDUint)) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Photo not accessible"})
-		return
+// writeZip streams the files as a zip archive
+func (d *downloadFiles) writeZip(w io.Writer) error {
+	if storage.IsLocal() {
+		return utils.CreateZip(w, d.paths, d.dir)
 	}
+	return utils.CreateZipEntries(w, d.entries)
+}
 
-	var photo models.Photo
-	if err := database.DB.Select("id, project_id, base_name, normal_ext, raw_ext, has_raw").
-		Where("id = ? AND project_id = ?", photoIDUint, link.ProjectID).First(&photo).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+// DownloadSinglePhoto - download a single photo with all its files (normal + raw) as zip
+func DownloadSinglePhoto(c *gin.Context) {
+	link, photo := middleware.SharePhoto(c)
+	project := link.Project
+	if !checkShareDownload(c, link, false) {
 		return
 	}
 
@@ -259,59 +495,49 @@ func DownloadSinglePhoto(c *gin.Context) {
 		return
 	}
 
-	uploadDir := filepath.Join(config.AppConfig.UploadDir, project.Name)
-
-	// Validate upload directory path is secure
-	safeUploadDir, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, uploadDir)
+	files, err := newDownloadFiles(project.Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid directory path"})
 		return
 	}
 
-	var files []string
+	var accesses []originalAccess
 
 	// Add normal photo
-	if photo.NormalExt != "" {
-		filePath := filepath.Join(safeUploadDir, photo.BaseName+photo.NormalExt)
-		if _, err := os.Stat(filePath); err == nil {
-			files = append(files, filePath)
+	if photo.NormalExt != "" && files.addStored(photo.BaseName+photo.NormalExt, photo.NormalSize, photo.UpdatedAt) {
+		accesses = append(accesses, originalAccess{photo.ID, models.AccessFileNormal})
+	}
+
+	// RAW-only photo: include the converted JPEG when conversion is configured
+	if services.CanConvertRaw(photo) {
+		if convertedPath, err := services.EnsureConvertedJPEG(project.Name, photo); err == nil && files.addLocal(convertedPath) {
+			accesses = append(accesses, originalAccess{photo.ID, models.AccessFileConverted})
 		}
 	}
 
 	// Add RAW if allowed
-	if photo.HasRaw && photo.RawExt != "" && link.AllowRaw {
-		filePath := filepath.Join(safeUploadDir, photo.BaseName+photo.RawExt)
-		if _, err := os.Stat(filePath); err == nil {
-			files = append(files, filePath)
-		}
+	if photo.HasRaw && photo.RawExt != "" && link.AllowRaw && !common.IsRawExcluded(link.ID, photo.ID) &&
+		files.addStored(photo.BaseName+photo.RawExt, photo.RawSize, photo.UpdatedAt) {
+		accesses = append(accesses, originalAccess{photo.ID, models.AccessFileRaw})
 	}
 
-	if len(files) == 0 {
+	if files.count() == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No files to download"})
 		return
 	}
-
-	// If only one file, send directly without zip
-	if len(files) == 1 {
-		// Open file for ServeContent (handles ETag, If-None-Match, 304, Range requests)
-		file, err := os.Open(files[0])
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-			return
-		}
-		defer file.Close()
-
-		fileInfo, err := file.Stat()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file info"})
-			return
+	if !claimShareDownload(c, link) {
+		return
+	}
+	defer func() {
+		if servedContent(c) {
+			recordOriginalAccess(c, &project, link, models.AccessDownload, accesses...)
+			recordLinkAccess(c, link, models.LinkAccessDownload, photo.ID)
 		}
+	}()
 
-		// Set cache headers
-		c.Header("Cache-Control", "public, max-age=31536000")
-
-		// ServeContent automatically handles ETag, If-None-Match, 304, and Range requests
-		http.ServeContent(c.Writer, c.Request, fileInfo.Name(), fileInfo.ModTime(), file)
+	// If only one file, send directly without zip
+	if files.count() == 1 {
+		files.serveSingle(c, cacheControl(&project, "max-age=31536000"))
 		return
 	}
 
@@ -323,94 +549,376 @@ func DownloadSinglePhoto(c *gin.Context) {
 	// Note: HTTP headers are already sent at this point. If CreateZip fails,
 	// the client will receive an incomplete/malformed zip file.
 	// This is acceptable as pre-validating all files would be expensive.
-	if err := utils.CreateZip(c.Writer, files, safeUploadDir); err != nil {
+	if err := files.writeZip(c.Writer); err != nil {
 		// Cannot send error response - headers already sent
 		return
 	}
 }
 
 func DownloadSharePhotos(c *gin.Context) {
-	token := c.Param("token")
-	downloadType := c.DefaultQuery("type", "normal") // normal, raw, or all
-
-	var link models.ShareLink
-	result := database.DB.Where("token = ?", token).Preload("Exclusions").Preload("Project").First(&link)
-	if result.Error != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+	downloadType := c.DefaultQuery("type", models.DownloadNormal) // normal, raw, or all
+	part, err := strconv.Atoi(c.DefaultQuery("part", "0"))
+	if err != nil || part < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid part"})
 		return
 	}
 
-	project := link.Project
-	// Check if project exists (Preload doesn't fail if foreign key references non-existent record)
-	if project.ID == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	link, ok := loadDownloadLink(c)
+	if !ok || !checkShareDownload(c, link, true) {
+		return
+	}
+	photos, ok := loadSharePhotosForDownload(c, link)
+	if !ok {
 		return
 	}
 
-	// Validate project name to prevent directory traversal
-	if !utils.ValidatePathComponent(project.Name) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project name"})
+	serveShareArchive(c, link, photos, downloadType, fmt.Sprintf("%s-%s.zip", link.Project.Name, downloadType), part)
+	if startsArchive(c, part) {
+		services.NotifyZipDownloaded(link, publicBaseURL(c), downloadType, c.ClientIP(), utils.GetClientCountry(c))
+	}
+}
+
+// startsArchive reports whether a download-all response started a new download of the
+// archive, emailed with MAIL_ON_ZIP: the first part of a split archive, not the next
+// ones, and no resumed ranges
+func startsArchive(c *gin.Context, part int) bool {
+	return part <= 1 && servedContent(c)
+}
+
+// GetShareDownloadParts lists the volumes the download-all archive of a type is split
+// into with ZIP_VOLUME_SIZE_MB, each to be fetched with ?part=N. Listing doesn't count
+// as a download.
+func GetShareDownloadParts(c *gin.Context) {
+	downloadType := c.DefaultQuery("type", models.DownloadNormal)
+
+	link, ok := loadDownloadLink(c)
+	if !ok || !checkShareDownload(c, link, true) {
+		return
+	}
+	photos, ok := loadSharePhotosForDownload(c, link)
+	if !ok {
+		return
+	}
+	files, _, err := collectShareArchive(link, photos, downloadType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid directory path"})
 		return
 	}
 
+	volumes := utils.SplitZipVolumes(files.sizes, zipVolumeBytes())
+	parts := make([]gin.H, len(volumes))
+	for i, volume := range volumes {
+		parts[i] = gin.H{"part": i + 1, "files": volume.Files, "size": volume.Size}
+	}
+	totalSize, exact := files.zipSize()
+	c.JSON(http.StatusOK, gin.H{
+		"volume_size": zipVolumeBytes(),
+		"total_size":  totalSize,
+		"exact":       exact,
+		"parts":       parts,
+	})
+}
+
+// zipVolumeBytes is the largest part of a split download-all archive, 0 if they aren't split
+func zipVolumeBytes() int64 {
+	return int64(config.AppConfig.ZipVolumeSizeMB) << 20
+}
+
+// loadSharePhotosForDownload loads the photos of a link's download-all archive, ordered
+// by ID so the parts of split archives are the same on every request
+func loadSharePhotosForDownload(c *gin.Context, link *models.ShareLink) ([]models.Photo, bool) {
 	// Get photos excluding excluded ones
 	excludedIDs := common.GetExcludedIDs(link.Exclusions)
 
 	var photos []models.Photo
-	query := database.DB.Select("base_name, normal_ext, raw_ext, has_raw").Where("project_id = ?", link.ProjectID)
+	query := database.DB.Select(downloadPhotoColumns).Where("project_id = ?", link.ProjectID)
 	if len(excludedIDs) > 0 {
 		query = query.Where("id NOT IN ?", excludedIDs)
 	}
-	query.Find(&photos)
+	query = common.ApplyDateRange(query, link)
+	if err := query.Order("id").Find(&photos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return nil, false
+	}
+	return photos, true
+}
 
-	// Collect files to zip
-	uploadDir := filepath.Join(config.AppConfig.UploadDir, project.Name)
+// DownloadShareSelection downloads the chosen photos of a share link as one zip, so
+// visitors don't have to fetch them one by one or take the whole gallery. Every photo
+// must be visible through the link.
+func DownloadShareSelection(c *gin.Context) {
+	var req models.DownloadSelectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Validate upload directory path is secure
-	safeUploadDir, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, uploadDir)
+	link, ok := loadDownloadLink(c)
+	if !ok || !checkShareDownload(c, link, true) {
+		return
+	}
+
+	requested := uniqueIDs(req.PhotoIDs)
+	visible, err := common.VisiblePhotoIDs(link, requested)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid directory path"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
 		return
 	}
+	if len(visible) != len(requested) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Some photos are not part of this share"})
+		return
+	}
+
+	var photos []models.Photo
+	if err := database.DB.Select(downloadPhotoColumns).Where("id IN ?", visible).Order("id").Find(&photos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
+
+	serveShareArchive(c, link, photos, req.Type, fmt.Sprintf("%s-selection-%d.zip", link.Project.Name, len(photos)), 0)
+}
 
-	var files []string
+// Errors of share link downloads, sent as {"error": code, "message": ...} so the
+// gallery can explain why a download isn't offered
+const (
+	ShareErrDownloadDisabled = "download_disabled"      // the link is view-only
+	ShareErrZipDisabled      = "zip_disabled"           // the link doesn't offer archives
+	ShareErrDownloadLimit    = "download_limit_reached" // the link has used up its downloads
+)
+
+var shareDownloadMessages = map[string]string{
+	ShareErrDownloadDisabled: "This share link does not allow downloads",
+	ShareErrZipDisabled:      "This share link does not allow downloading archives",
+	ShareErrDownloadLimit:    "This share link has reached its download limit",
+}
+
+// respondDownloadDenied answers 403 with the code of a refused download
+func respondDownloadDenied(c *gin.Context, code string) {
+	c.JSON(http.StatusForbidden, gin.H{"error": code, "message": shareDownloadMessages[code]})
+}
+
+// checkShareDownload refuses a download the link doesn't allow: any download of a
+// view-only link, archives of links without zip, and downloads beyond the limit
+func checkShareDownload(c *gin.Context, link *models.ShareLink, archive bool) bool {
+	switch {
+	case !link.AllowDownload:
+		respondDownloadDenied(c, ShareErrDownloadDisabled)
+	case archive && !link.AllowZip:
+		respondDownloadDenied(c, ShareErrZipDisabled)
+	case link.DownloadLimitReached():
+		respondDownloadDenied(c, ShareErrDownloadLimit)
+	default:
+		return true
+	}
+	return false
+}
 
+// claimShareDownload counts a download against the link's limit just before it is
+// sent. Every request counts, resumed ranges too, so the limit can't be bypassed
+// with Range headers; HEAD requests are free.
+func claimShareDownload(c *gin.Context, link *models.ShareLink) bool {
+	if c.Request.Method == http.MethodHead {
+		return true
+	}
+	claimed, err := common.ClaimDownload(link)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count the download"})
+		return false
+	}
+	if !claimed {
+		respondDownloadDenied(c, ShareErrDownloadLimit)
+	}
+	return claimed
+}
+
+// downloadPhotoColumns selects what share link archives need of a photo
+const downloadPhotoColumns = "id, base_name, normal_ext, raw_ext, has_raw, normal_size, raw_size, updated_at"
+
+// loadDownloadLink loads the link of a share download with its exclusions and project
+func loadDownloadLink(c *gin.Context) (*models.ShareLink, bool) {
+	var link models.ShareLink
+	result := database.DB.Where("token = ?", c.Param("token")).Preload("Exclusions").Preload("RawExclusions").Preload("Project").First(&link)
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return nil, false
+	}
+
+	// Check if project exists (Preload doesn't fail if foreign key references non-existent record)
+	if link.Project.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+
+	// Validate project name to prevent directory traversal
+	if !utils.ValidatePathComponent(link.Project.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project name"})
+		return nil, false
+	}
+	return &link, true
+}
+
+// collectShareArchive collects the files of photos a share link offers as a zip:
+// downloadType picks the normal images (converted JPEGs for RAW-only photos), the
+// RAW files (only if the link allows RAW) or both. Each file has its access entry.
+func collectShareArchive(link *models.ShareLink, photos []models.Photo, downloadType string) (*downloadFiles, []originalAccess, error) {
+	project := link.Project
+
+	files, err := newDownloadFiles(project.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawExcluded := common.GetRawExcludedIDs(link.RawExclusions)
+
+	var accesses []originalAccess
 	for _, photo := range photos {
-		if downloadType == "normal" || downloadType == "all" {
+		if downloadType == models.DownloadNormal || downloadType == models.DownloadAll {
 			if photo.NormalExt != "" {
-				filePath := filepath.Join(safeUploadDir, photo.BaseName+photo.NormalExt)
-				if _, err := os.Stat(filePath); err == nil {
-					files = append(files, filePath)
+				if files.addStored(photo.BaseName+photo.NormalExt, photo.NormalSize, photo.UpdatedAt) {
+					accesses = append(accesses, originalAccess{photo.ID, models.AccessFileNormal})
+				}
+			} else if services.CanConvertRaw(&photo) {
+				// RAW-only photo: converted JPEGs are cached, so only the first download pays for conversion
+				if convertedPath, err := services.EnsureConvertedJPEG(project.Name, &photo); err == nil && files.addLocal(convertedPath) {
+					accesses = append(accesses, originalAccess{photo.ID, models.AccessFileConverted})
 				}
 			}
 		}
-		if (downloadType == "raw" || downloadType == "all") && link.AllowRaw {
-			if photo.HasRaw && photo.RawExt != "" {
-				filePath := filepath.Join(safeUploadDir, photo.BaseName+photo.RawExt)
-				if _, err := os.Stat(filePath); err == nil {
-					files = append(files, filePath)
-				}
+		if (downloadType == models.DownloadRaw || downloadType == models.DownloadAll) && link.AllowRaw {
+			if photo.HasRaw && photo.RawExt != "" && !rawExcluded[photo.ID] && files.addStored(photo.BaseName+photo.RawExt, photo.RawSize, photo.UpdatedAt) {
+				accesses = append(accesses, originalAccess{photo.ID, models.AccessFileRaw})
 			}
 		}
 	}
+	return files, accesses, nil
+}
 
-	if len(files) == 0 {
+// serveShareArchive sends the files of photos a share link offers as a zip (see
+// collectShareArchive). A part above 0 sends only that volume of the archive split
+// with ZIP_VOLUME_SIZE_MB. The archive size is sent as Content-Length when it is
+// exact, as X-Estimated-Size otherwise.
+func serveShareArchive(c *gin.Context, link *models.ShareLink, photos []models.Photo, downloadType, zipName string, part int) {
+	project := link.Project
+
+	// Collect files to zip
+	files, accesses, err := collectShareArchive(link, photos, downloadType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid directory path"})
+		return
+	}
+
+	if files.count() == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No files to download"})
 		return
 	}
+	files, accesses, zipName, ok := shareArchivePart(c, files, accesses, zipName, part)
+	if !ok {
+		return
+	}
+	if !claimShareDownload(c, link) {
+		return
+	}
+	defer func() {
+		if servedContent(c) {
+			recordOriginalAccess(c, &project, link, models.AccessArchive, accesses...)
+			recordLinkAccess(c, link, models.LinkAccessZip, 0)
+		}
+	}()
 
 	// Set headers for zip download
-	zipName := fmt.Sprintf("%s-%s.zip", project.Name, downloadType)
 	c.Header("Content-Type", "application/zip")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", zipName))
+	size, exact := files.zipSize()
+	if !exact {
+		c.Header("X-Estimated-Size", strconv.FormatInt(size, 10))
+		size = -1
+	}
+
+	// Archives of local files are spooled (the spool keys on file metadata)
+	if services.Archives != nil && storage.IsLocal() && serveSpooledZip(c, files.paths, files.dir, zipName, size) {
+		return
+	}
 
 	// Note: HTTP headers are already sent at this point. If CreateZip fails,
 	// the client will receive an incomplete/malformed zip file.
 	// This is acceptable as pre-validating all files would be expensive.
+	if size >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(size, 10))
+	}
 	// Stream zip
-	err = utils.CreateZip(c.Writer, files, safeUploadDir)
+	err = files.writeZip(c.Writer)
 	if err != nil {
 		// Cannot send error response - headers already sent
 		return
 	}
 }
+
+// shareArchivePart narrows an archive to one volume of it split with ZIP_VOLUME_SIZE_MB
+// (part above 0) and checks the file count before any byte is sent, answering 404 for
+// a part the archive doesn't have and 400 for too many files
+func shareArchivePart(c *gin.Context, files *downloadFiles, accesses []originalAccess, zipName string, part int) (*downloadFiles, []originalAccess, string, bool) {
+	if part > 0 {
+		volumes := utils.SplitZipVolumes(files.sizes, zipVolumeBytes())
+		if part > len(volumes) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Part not found, the archive has %d parts", len(volumes))})
+			return nil, nil, "", false
+		}
+		volume := volumes[part-1]
+		files, accesses = files.slice(volume.Start, volume.End), accesses[volume.Start:volume.End]
+		zipName = fmt.Sprintf("%s-part%d-of-%d.zip", strings.TrimSuffix(zipName, ".zip"), part, len(volumes))
+	}
+	// CreateZip would fail halfway through the response
+	if files.count() > utils.MaxFilesPerZip {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many files (%d), at most %d can be downloaded at once", files.count(), utils.MaxFilesPerZip)})
+		return nil, nil, "", false
+	}
+	return files, accesses, zipName, true
+}
+
+// serveSpooledZip serves a download-all archive through the archive spool. A prepared
+// archive is served with Content-Length and Range support so interrupted downloads can
+// resume; otherwise the archive is streamed while being written to the spool. The zip
+// output is deterministic, so the ETag of the first response matches the prepared
+// archive and If-Range resumes work; size, when not negative, is sent as its
+// Content-Length. Returns false if the caller should stream instead.
+func serveSpooledZip(c *gin.Context, files []string, basePath, zipName string, size int64) bool {
+	key, estimate, err := services.ArchiveKey(files, basePath)
+	if err != nil {
+		return false
+	}
+	c.Header("ETag", `"`+key+`"`)
+
+	if file, info, ok := services.Archives.Open(key); ok {
+		defer file.Close()
+		// ServeContent handles Range, If-Range and Content-Length
+		http.ServeContent(c.Writer, c.Request, zipName, info.ModTime(), file)
+		return true
+	}
+
+	// A range of an archive that is not prepared yet can't be served; send it whole
+	c.Request.Header.Del("Range")
+
+	spool, ok := services.Archives.Create(key, estimate)
+	if !ok {
+		// Being prepared by another request, or larger than the spool budget
+		c.Header("ETag", "")
+		return false
+	}
+	c.Header("Accept-Ranges", "bytes")
+	if size >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if err := utils.CreateZip(spool.Tee(c.Writer), files, basePath); err != nil {
+		log.Printf("[Download] Failed to prepare archive %s: %v", zipName, err)
+		spool.Abort()
+		return true
+	}
+	if err := spool.Commit(); err != nil {
+		log.Printf("[Download] Failed to store archive %s: %v", zipName, err)
+	}
+	return true
+}
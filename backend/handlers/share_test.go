@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/middleware"
+	"photobridge/models"
+	"photobridge/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupShareTest creates a project with a JPEG+RAW photo on disk, a link allowing
+// downloads ("open") and a view-only link allowing RAW files ("viewonly")
+func setupShareTest(t *testing.T) (*gin.Engine, *models.Photo) {
+	gin.SetMode(gin.TestMode)
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	err = database.DB.AutoMigrate(&models.Project{}, &models.Photo{}, &models.ShareLink{}, &models.PhotoExclusion{},
+		&models.PhotoHighlight{}, &models.RawExclusion{}, &models.PhotoAccess{}, &models.AccessLog{})
+	if err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	config.AppConfig = &config.Config{UploadDir: t.TempDir(), ThumbDir: t.TempDir()}
+	storage.Set(storage.NewLocal(config.AppConfig.UploadDir))
+
+	project := models.Project{Name: "wedding"}
+	database.DB.Create(&project)
+	photo := &models.Photo{ProjectID: project.ID, BaseName: "IMG_0001", NormalExt: ".jpg", HasRaw: true, RawExt: ".cr3"}
+	database.DB.Create(photo)
+	dir := filepath.Join(config.AppConfig.UploadDir, project.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	for _, name := range []string{"IMG_0001.jpg", "IMG_0001.cr3"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("original"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	database.DB.Create(&models.ShareLink{ProjectID: project.ID, Token: "open", AllowRaw: true})
+	viewOnly := models.ShareLink{ProjectID: project.ID, Token: "viewonly", AllowRaw: true}
+	database.DB.Create(&viewOnly)
+	database.DB.Model(&viewOnly).Update("allow_download", false) // false is skipped on create for the default
+
+	router := gin.New()
+	router.GET("/share/:token/photos", GetSharePhotos)
+	router.GET("/share/:token/photo/:photoId", middleware.RequireSharePhoto(), GetSharePhoto)
+	return router, photo
+}
+
+func TestGetSharePhotos_ViewOnly(t *testing.T) {
+	router, _ := setupShareTest(t)
+
+	for token, wantURLs := range map[string]bool{"open": true, "viewonly": false} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/share/"+token+"/photos", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", token, w.Code, w.Body.String())
+		}
+		var photos []map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &photos); err != nil || len(photos) != 1 {
+			t.Fatalf("%s: photos = %s (%v)", token, w.Body.String(), err)
+		}
+		_, hasNormal := photos[0]["normal_url"]
+		_, hasRaw := photos[0]["raw_url"]
+		if hasNormal != wantURLs || hasRaw != wantURLs {
+			t.Errorf("%s: normal_url present %v, raw_url present %v; want %v", token, hasNormal, hasRaw, wantURLs)
+		}
+	}
+}
+
+func TestGetSharePhoto_ViewOnly(t *testing.T) {
+	router, photo := setupShareTest(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/share/open/photo/%d?type=normal", photo.ID), nil))
+	if w.Code != http.StatusOK || w.Body.String() != "original" {
+		t.Errorf("normal original through a download link = %d %q, want 200", w.Code, w.Body.String())
+	}
+
+	for _, photoType := range []string{"normal", "raw", "converted"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/share/viewonly/photo/%d?type=%s", photo.ID, photoType), nil))
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != http.StatusForbidden || body.Error != ShareErrDownloadDisabled {
+			t.Errorf("%s original through a view-only link = %d %s, want 403 %s", photoType, w.Code, w.Body.String(), ShareErrDownloadDisabled)
+		}
+	}
+}
+
+func TestGetSharePhoto_DownloadLimit(t *testing.T) {
+	router, photo := setupShareTest(t)
+	link := models.ShareLink{ProjectID: photo.ProjectID, Token: "capped", AllowRaw: true, MaxDownloads: 1}
+	database.DB.Create(&link)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/share/capped/photo/%d?%s", photo.ID, query), nil))
+		return w
+	}
+	errorCode := func(w *httptest.ResponseRecorder) string {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return body.Error
+	}
+
+	// Full-size views don't count
+	for i := 0; i < 2; i++ {
+		if w := get("type=normal"); w.Code != http.StatusOK || w.Header().Get("Content-Disposition") != "" {
+			t.Fatalf("view %d = %d, Content-Disposition %q", i, w.Code, w.Header().Get("Content-Disposition"))
+		}
+	}
+	// RAW files always do, other files when downloaded
+	if w := get("type=raw"); w.Code != http.StatusOK || w.Header().Get("Content-Disposition") != `attachment; filename="IMG_0001.cr3"` {
+		t.Fatalf("RAW download = %d, Content-Disposition %q", w.Code, w.Header().Get("Content-Disposition"))
+	}
+	database.DB.First(&link, link.ID)
+	if link.DownloadCount != 1 {
+		t.Errorf("DownloadCount = %d, want 1", link.DownloadCount)
+	}
+	for _, query := range []string{"type=raw", "type=normal&download=true"} {
+		if w := get(query); w.Code != http.StatusForbidden || errorCode(w) != ShareErrDownloadLimit {
+			t.Errorf("%s after the limit = %d %s, want 403 %s", query, w.Code, w.Body.String(), ShareErrDownloadLimit)
+		}
+	}
+	if w := get("type=normal"); w.Code != http.StatusOK {
+		t.Errorf("view after the limit = %d, want 200", w.Code)
+	}
+
+	// The photo list doesn't offer the uncounted /uploads URL of the RAW file
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/share/capped/photos", nil))
+	var photos []map[string]any
+	json.Unmarshal(w.Body.Bytes(), &photos)
+	if len(photos) != 1 || photos[0]["raw_url"] != fmt.Sprintf("/api/share/capped/photo/%d?type=raw", photo.ID) {
+		t.Errorf("photos of a capped link = %s", w.Body.String())
+	}
+}
+
+func TestStartsArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tt := range []struct {
+		method, rangeHeader string
+		status, part        int
+		want                bool
+	}{
+		{http.MethodGet, "", http.StatusOK, 0, true},
+		{http.MethodGet, "", http.StatusOK, 1, true},
+		{http.MethodGet, "", http.StatusOK, 2, false},
+		{http.MethodGet, "bytes=0-", http.StatusOK, 1, true},
+		{http.MethodGet, "bytes=4096-", http.StatusOK, 0, false},
+		{http.MethodGet, "bytes=4096-", http.StatusPartialContent, 1, false},
+		{http.MethodHead, "", http.StatusOK, 0, false},
+		{http.MethodGet, "", http.StatusForbidden, 0, false},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(tt.method, "/share/open/download", nil)
+		if tt.rangeHeader != "" {
+			c.Request.Header.Set("Range", tt.rangeHeader)
+		}
+		c.Status(tt.status)
+		c.Writer.WriteHeaderNow()
+		if got := startsArchive(c, tt.part); got != tt.want {
+			t.Errorf("%s part %d, Range %q, status %d: startsArchive() = %v, want %v", tt.method, tt.part, tt.rangeHeader, tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetShareCard serves the social preview image of a share link.
+// It is public (no Turnstile or password) so messaging apps can fetch it; cards are
+// rendered in the background and lazily here if missing.
+func GetShareCard(c *gin.Context) {
+	var link models.ShareLink
+	if err := database.DB.Where("token = ?", c.Param("token")).
+		Preload("Project").Preload("Exclusions").Preload("Highlights").First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+	if link.IsExpired() {
+		c.JSON(http.StatusGone, gin.H{"error": "link_expired", "message": "This share link has expired"})
+		return
+	}
+
+	path := services.ShareCardPath(link.Token)
+	if _, err := os.Stat(path); err != nil {
+		if path, err = services.GenerateShareCard(&link); err != nil {
+			log.Printf("[ShareCard] Failed to render card for link %d: %v", link.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share card"})
+			return
+		}
+	}
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.File(path)
+}
+
+// ShareOGPage serves the SPA index for /s/:token with Open Graph tags injected,
+// so link previews in messaging apps show the project name and share card.
+func ShareOGPage(indexPath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := os.ReadFile(indexPath)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		var link models.ShareLink
+		if err := database.DB.Where("token = ?", c.Param("token")).Preload("Project").First(&link).Error; err == nil && !link.IsExpired() {
+			page = injectOGTags(page, shareOGTags(c, &link))
+		}
+
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	}
+}
+
+func shareOGTags(c *gin.Context, link *models.ShareLink) string {
+	base := requestBaseURL(c)
+
+	title := link.Project.Name
+	if link.Alias != "" {
+		title = fmt.Sprintf("%s - %s", link.Project.Name, link.Alias)
+	}
+	description := link.Project.Description
+	if link.PasswordEnabled {
+		description = "Password-protected photo gallery"
+	} else if description == "" {
+		description = "Shared photo gallery"
+	}
+
+	tags := []struct{ property, content string }{
+		{"og:type", "website"},
+		{"og:title", title},
+		{"og:description", description},
+		{"og:url", base + "/s/" + link.Token},
+		{"og:image", base + "/api/share/" + link.Token + "/card.jpg"},
+		{"og:image:width", "1200"},
+		{"og:image:height", "630"},
+	}
+
+	var b strings.Builder
+	for _, t := range tags {
+		fmt.Fprintf(&b, "<meta property=\"%s\" content=\"%s\">\n", t.property, html.EscapeString(t.content))
+	}
+	b.WriteString("<meta name=\"twitter:card\" content=\"summary_large_image\">\n")
+	return b.String()
+}
+
+// injectOGTags inserts tags right before </head>
+func injectOGTags(page []byte, tags string) []byte {
+	s := string(page)
+	i := strings.Index(s, "</head>")
+	if i < 0 {
+		return page
+	}
+	return []byte(s[:i] + tags + s[i:])
+}
+
+// requestBaseURL returns the scheme and host the request was made to
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// publicBaseURL returns the base URL of share links in emails: PUBLIC_URL, or the
+// host of the request
+func publicBaseURL(c *gin.Context) string {
+	if config.AppConfig.PublicURL != "" {
+		return config.AppConfig.PublicURL
+	}
+	return requestBaseURL(c)
+}
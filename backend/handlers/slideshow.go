@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Suggested slide durations in seconds; highlights stay on screen longer
+const (
+	defaultSlideDuration     = 6
+	defaultHighlightDuration = 10
+	maxSlideDuration         = 120
+)
+
+// SlideshowItem is one slide of a share link's slideshow playlist
+type SlideshowItem struct {
+	PhotoID     uint       `json:"photo_id"`
+	URL         string     `json:"url"` // large thumbnail
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	AspectRatio float64    `json:"aspect_ratio"`
+	Duration    int        `json:"duration"` // suggested seconds on screen
+	Caption     string     `json:"caption"`
+	TakenAt     *time.Time `json:"taken_at,omitempty"`
+	Highlight   bool       `json:"highlight"`
+}
+
+// GetShareSlideshow returns an ordered playlist for kiosk/TV clients: the link's
+// highlights in their order, then the remaining visible photos by capture time.
+// RAW-only photos are skipped since their thumbnail is only a placeholder.
+// Optional query: duration, highlight_duration (seconds), highlights_only=true
+func GetShareSlideshow(c *gin.Context) {
+	duration, ok := parseSlideDuration(c, "duration", defaultSlideDuration)
+	if !ok {
+		return
+	}
+	highlightDuration, ok := parseSlideDuration(c, "highlight_duration", defaultHighlightDuration)
+	if !ok {
+		return
+	}
+	highlightsOnly := c.Query("highlights_only") == "true"
+
+	var link models.ShareLink
+	result := database.DB.Where("token = ?", c.Param("token")).Preload("Exclusions").Preload("Highlights").Preload("Project").First(&link)
+	if result.Error != nil || link.Project.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+		return
+	}
+
+	excludedIDs := common.GetExcludedIDs(link.Exclusions)
+	highlightIDs := common.FilterDateRange(&link, common.GetHighlightIDs(link.Highlights, excludedIDs))
+	highlightPositions := make(map[uint]int, len(highlightIDs))
+	for i, id := range highlightIDs {
+		highlightPositions[id] = i
+	}
+
+	var photos []models.Photo
+	query := database.DB.Select(photoMetaColumns).Where("project_id = ? AND normal_ext <> ''", link.ProjectID)
+	if len(excludedIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludedIDs)
+	}
+	if highlightsOnly {
+		query = query.Where("id IN ?", append(highlightIDs, 0))
+	}
+	query = common.ApplyDateRange(query, &link)
+	if err := query.Order(common.CapturedAtExpr + ", id").Find(&photos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	highlights := make([]SlideshowItem, len(highlightIDs))
+	items := []SlideshowItem{}
+	for i := range photos {
+		photo := &photos[i]
+		item := SlideshowItem{
+			PhotoID:     photo.ID,
+			URL:         imageURL(photo.ID, link.Token, models.ImageSizeLarge, ""),
+			Width:       photo.Width,
+			Height:      photo.Height,
+			AspectRatio: services.PhotoAspectRatio(photo),
+			Duration:    duration,
+			Caption:     photo.BaseName,
+			TakenAt:     photo.TakenAt,
+		}
+		if position, ok := highlightPositions[photo.ID]; ok {
+			item.Highlight = true
+			item.Duration = highlightDuration
+			highlights[position] = item
+			continue
+		}
+		items = append(items, item)
+	}
+
+	// Highlights lead in their configured order (slots of RAW-only highlights stay empty)
+	playlist := make([]SlideshowItem, 0, len(highlights)+len(items))
+	for _, item := range highlights {
+		if item.PhotoID != 0 {
+			playlist = append(playlist, item)
+		}
+	}
+	playlist = append(playlist, items...)
+
+	total := 0
+	for _, item := range playlist {
+		total += item.Duration
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project_name":   link.Project.Name,
+		"items":          playlist,
+		"count":          len(playlist),
+		"total_duration": total,
+	})
+}
+
+// parseSlideDuration reads a duration in seconds from the query, responding 400 if invalid
+func parseSlideDuration(c *gin.Context, key string, defaultValue int) (int, bool) {
+	value := c.Query(key)
+	if value == "" {
+		return defaultValue, true
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 1 || seconds > maxSlideDuration {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be between 1 and %d seconds", key, maxSlideDuration)})
+		return 0, false
+	}
+	return seconds, true
+}
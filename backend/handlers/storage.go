@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStorageStats reports the size of the database (main file, WAL, free pages,
+// growth over the last day) and of the stored originals
+func GetStorageStats(c *gin.Context) {
+	db, err := services.ReadDatabaseStats()
+	if err != nil {
+		log.Printf("[Storage] Failed to read database size: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read database size"})
+		return
+	}
+
+	var photos struct {
+		Count       int64 `json:"count"`
+		NormalBytes int64 `json:"normal_bytes"`
+		RawBytes    int64 `json:"raw_bytes"`
+	}
+	err = database.DB.Model(&models.Photo{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(normal_size), 0) AS normal_bytes, COALESCE(SUM(raw_size), 0) AS raw_bytes").
+		Scan(&photos).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"database": db, "photos": photos})
+}
+
+// GetProjectUsage reports the storage used by a project's photos, its quota and the
+// per-file upload limit
+func GetProjectUsage(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	usage, err := services.GetProjectUsage(&project)
+	if err != nil {
+		log.Printf("[Storage] Failed to total the files of project %d: %v", project.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read project usage"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
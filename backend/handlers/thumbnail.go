@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	"photobridge/database"
+	"photobridge/middleware"
 	"photobridge/models"
 	"photobridge/services"
 	"photobridge/utils"
@@ -12,39 +17,57 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxThumbWait caps the optional ?wait= long-poll for thumbnails being generated
+const maxThumbWait = 30 * time.Second
+
 // serveThumb is a unified handler for serving thumbnails
 // size: "small" or "large"
-func serveThumb(c *gin.Context, photo *models.Photo, size string) {
-	if photo.NormalExt == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "raw_only", "message": "Only RAW file exists"})
+// Concurrent requests for the same photo share one lookup and enqueue; those of the
+// admin panel move the photo to the priority lane of the queue. With ?wait=N
+// a request for a thumbnail being generated waits up to N seconds for it instead of
+// returning 202 right away.
+func serveThumb(c *gin.Context, photoID uint, size string) {
+	lookup, err := services.Queue.LookupThumbnail(photoID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
 		return
 	}
-
-	var thumbData []byte
-	if size == "small" {
-		thumbData = photo.ThumbSmall
-	} else {
-		thumbData = photo.ThumbLarge
+	// The admin panel waits for its thumbnails; they go ahead of visitors and backfill
+	if lookup.Status == services.ThumbStatusQueued && middleware.AdminSessionID(c) != 0 {
+		services.Queue.Prioritize(photoID)
 	}
-
-	if len(thumbData) == 0 {
-		var project models.Project
-		if err := database.DB.First(&project, photo.ProjectID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-			return
+	if wait := parseThumbWait(c); wait > 0 && lookup.Status == services.ThumbStatusQueued {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), wait)
+		finished := services.Queue.WaitForThumbnail(ctx, photoID)
+		cancel()
+		if finished {
+			if lookup, err = services.Queue.LookupThumbnail(photoID); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+				return
+			}
 		}
+	}
 
-		if services.Queue == nil || !services.Queue.IsRunning() {
-			c.JSON(http.StatusServiceUnavailable, gin.H{
-				"error":   "queue_unavailable",
-				"message": "Thumbnail service unavailable, please retry later",
-				"queued":  false,
-			})
+	photo := lookup.Photo
+	if lookup.Status == services.ThumbStatusRawOnly {
+		if services.IsRawOnly(photo) {
+			serveRawPlaceholder(c, photo, size)
 			return
 		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "raw_only", "message": "Only RAW file exists"})
+		return
+	}
 
-		enqueued := services.Queue.Enqueue(photo, project.Name)
-		if !enqueued && !services.Queue.IsProcessing(photo.ID) {
+	if lookup.Status != services.ThumbStatusReady {
+		if lookup.Status != services.ThumbStatusQueued {
+			if services.Queue == nil || !services.Queue.IsRunning() {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error":   "queue_unavailable",
+					"message": "Thumbnail service unavailable, please retry later",
+					"queued":  false,
+				})
+				return
+			}
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":   "queue_busy",
 				"message": "Thumbnail queue is full, please retry later",
@@ -61,99 +84,124 @@ func serveThumb(c *gin.Context, photo *models.Photo, size string) {
 		return
 	}
 
-	etag := utils.GenerateETag(photo.ID, photo.UpdatedAt, size)
-
-	c.Header("ETag", etag)
-	c.Header("Cache-Control", "public, max-age=31536000")
-	c.Header("Vary", "Accept")
-
-	if clientETag := c.GetHeader("If-None-Match"); clientETag != "" && clientETag == etag {
-		c.Status(http.StatusNotModified)
+	file, err := os.Open(services.ThumbPath(photo.ProjectID, photo.ID, size))
+	if err != nil {
+		// Removed since the lookup (e.g. the image was just replaced)
+		c.JSON(http.StatusAccepted, gin.H{"error": "generating", "message": "Thumbnail is being generated, please retry later", "queued": false})
 		return
 	}
+	defer file.Close()
 
-	c.Header("Content-Type", "image/jpeg")
-	c.Data(http.StatusOK, "image/jpeg", thumbData)
+	// ServeContent answers If-None-Match with 304 and supports Range requests
+	c.Header("ETag", utils.GenerateETag(photo.ID, photo.UpdatedAt, size))
+	c.Header("Cache-Control", "public, max-age=31536000")
+	c.Header("Vary", "Accept")
+	http.ServeContent(c.Writer, c.Request, size+".jpg", photo.UpdatedAt, file)
 }
 
-// getAdminPhoto retrieves a photo for admin endpoints
-func getAdminPhoto(c *gin.Context) (*models.Photo, bool) {
-	photoID := c.Param("id")
-	var photo models.Photo
-
-	if err := database.DB.First(&photo, photoID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
-		return nil, false
+// parseThumbWait reads the optional ?wait= long-poll in seconds, capped at maxThumbWait
+func parseThumbWait(c *gin.Context) time.Duration {
+	seconds, err := strconv.Atoi(c.Query("wait"))
+	if err != nil || seconds <= 0 {
+		return 0
 	}
-
-	return &photo, true
+	if wait := time.Duration(seconds) * time.Second; wait < maxThumbWait {
+		return wait
+	}
+	return maxThumbWait
 }
 
-// getSharePhoto retrieves a photo for share endpoints with validation
-func getSharePhoto(c *gin.Context) (*models.Photo, bool) {
-	token := c.Param("token")
-	photoIDStr := c.Param("photoId")
-
-	photoIDUint, err := strconv.ParseUint(photoIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
-		return nil, false
-	}
+// serveRawPlaceholder serves the generated placeholder tile of a RAW-only photo.
+// It is cached for a shorter time than real thumbnails: the same URL starts returning
+// the real thumbnail once a JPEG is uploaded or the RAW's preview is extracted, which
+// also changes the ETag.
+func serveRawPlaceholder(c *gin.Context, photo *models.Photo, size string) {
+	etag := utils.GenerateETag(photo.ID, photo.UpdatedAt, "placeholder-"+size)
 
-	var link models.ShareLink
-	if err := database.DB.Where("token = ?", token).First(&link).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
-		return nil, false
-	}
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Header("X-Thumb-Placeholder", "raw")
 
-	var exclusionCount int64
-	database.DB.Model(&models.PhotoExclusion{}).Where("link_id = ? AND photo_id = ?", link.ID, photoIDUint).Count(&exclusionCount)
-	if exclusionCount > 0 {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Photo not accessible"})
-		return nil, false
+	if clientETag := c.GetHeader("If-None-Match"); clientETag != "" && clientETag == etag {
+		c.Status(http.StatusNotModified)
+		return
 	}
 
-	var photo models.Photo
-	if err := database.DB.Where("id = ? AND project_id = ?", photoIDUint, link.ProjectID).First(&photo).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
-		return nil, false
+	data, err := services.RawPlaceholderThumb(photo, size)
+	if err != nil {
+		log.Printf("[Thumbnail] Failed to render placeholder for photo %d: %v", photo.ID, err)
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusNotFound, gin.H{"error": "raw_only", "message": "Only RAW file exists"})
+		return
 	}
-
-	return &photo, true
+	c.Data(http.StatusOK, "image/jpeg", data)
 }
 
 // GetPhotoThumbSmall returns small thumbnail for list view.
 func GetPhotoThumbSmall(c *gin.Context) {
-	photo, ok := getAdminPhoto(c)
-	if !ok {
-		return
-	}
-	serveThumb(c, photo, "small")
+	serveThumb(c, adminPhotoID(c), services.ThumbSizeSmall)
 }
 
 // GetPhotoThumbLarge returns large thumbnail for preview.
 func GetPhotoThumbLarge(c *gin.Context) {
-	photo, ok := getAdminPhoto(c)
-	if !ok {
-		return
-	}
-	serveThumb(c, photo, "large")
+	serveThumb(c, adminPhotoID(c), services.ThumbSizeLarge)
 }
 
 // GetSharePhotoThumbSmall returns small thumbnail for share page.
 func GetSharePhotoThumbSmall(c *gin.Context) {
-	photo, ok := getSharePhoto(c)
-	if !ok {
-		return
-	}
-	serveThumb(c, photo, "small")
+	_, meta := middleware.SharePhoto(c)
+	serveThumb(c, meta.ID, services.ThumbSizeSmall)
 }
 
 // GetSharePhotoThumbLarge returns large thumbnail for share page.
 func GetSharePhotoThumbLarge(c *gin.Context) {
-	photo, ok := getSharePhoto(c)
-	if !ok {
+	_, meta := middleware.SharePhoto(c)
+	serveThumb(c, meta.ID, services.ThumbSizeLarge)
+}
+
+// RegenerateProjectThumbnails queues the missing thumbnails of a project's photos in
+// the background; with ?force=true every thumbnail is removed and generated again
+func RegenerateProjectThumbnails(c *gin.Context) {
+	var project models.Project
+	if err := database.DB.Select("id, name").First(&project, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
 	}
-	serveThumb(c, photo, "large")
+	if services.Queue == nil || !services.Queue.IsRunning() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Thumbnail queue is not running"})
+		return
+	}
+
+	result, err := services.RegenerateProjectThumbnails(services.Queue, &project, c.Query("force") == "true")
+	if err != nil {
+		log.Printf("[Thumbnail] Failed to regenerate thumbnails of project %d: %v", project.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate thumbnails"})
+		return
+	}
+	c.JSON(http.StatusAccepted, result)
+}
+
+// GetThumbQueue reports the waiting tasks of each lane of the thumbnail queue, the
+// thumbnails being generated and the failed generations
+func GetThumbQueue(c *gin.Context) {
+	if services.Queue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Thumbnail queue is not running"})
+		return
+	}
+	c.JSON(http.StatusOK, services.Queue.Status())
+}
+
+// RetryFailedThumbnails queues the photos whose thumbnail generation failed again
+func RetryFailedThumbnails(c *gin.Context) {
+	if services.Queue == nil || !services.Queue.IsRunning() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Thumbnail queue is not running"})
+		return
+	}
+	c.JSON(http.StatusAccepted, services.Queue.RetryFailed())
+}
+
+// adminPhotoID parses the photo ID of admin endpoints (0, which matches no photo, if invalid)
+func adminPhotoID(c *gin.Context) uint {
+	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	return uint(id)
 }
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"photobridge/config"
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrash lists deleted photos and projects that can still be restored, newest first
+// (?kind=photo or ?kind=project)
+func GetTrash(c *gin.Context) {
+	kind := c.Query("kind")
+	if kind != "" && kind != models.TrashKindPhoto && kind != models.TrashKindProject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be photo or project"})
+		return
+	}
+	items, err := services.ListTrash(kind)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load trash"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":          items,
+		"retention_days": config.AppConfig.TrashRetentionDays,
+	})
+}
+
+// RestoreTrashItem brings a deleted photo or project back
+func RestoreTrashItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trash item ID"})
+		return
+	}
+
+	err = services.RestoreTrashItem(uint(id))
+	switch {
+	case errors.Is(err, services.ErrTrashItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trash item not found"})
+	case errors.Is(err, services.ErrProjectInTrash), errors.Is(err, services.ErrRestoreConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("[Trash] Restoring item %d failed: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Restored"})
+	}
+}
+
+// PurgeTrashItem deletes an item in the trash for good without waiting for its time
+func PurgeTrashItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trash item ID"})
+		return
+	}
+
+	err = services.PurgeTrashItem(uint(id))
+	switch {
+	case errors.Is(err, services.ErrTrashItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trash item not found"})
+	case err != nil:
+		log.Printf("[Trash] Purging item %d failed: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Purged"})
+	}
+}
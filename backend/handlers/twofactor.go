@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"photobridge/models"
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+)
+
+// totpQRSize is the width in pixels of the enrollment QR code
+const totpQRSize = 256
+
+// TwoFactorSetupResponse is a TOTP secret to enroll, with its otpauth:// URL as a QR code
+type TwoFactorSetupResponse struct {
+	services.TwoFactorSetup
+	QRCode string `json:"qr_code"` // PNG data URL
+}
+
+// checkLoginSecondFactor requires a TOTP or backup code once 2FA is enabled. Without
+// one the login is answered with otp_required, so the panel can ask for it; a wrong
+// code counts as a failed login.
+func checkLoginSecondFactor(c *gin.Context, attempt *models.LoginAttempt, code string) bool {
+	enabled, err := services.TwoFactorEnabled()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor authentication"})
+		return false
+	}
+	if !enabled {
+		return true
+	}
+	if code == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "otp_required", "message": "Enter the code from your authenticator app"})
+		return false
+	}
+	if err := services.VerifyTwoFactorCode(code); err != nil {
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			services.RecordLoginAttempt(attempt)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_otp", "message": "Invalid authentication code"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor authentication"})
+		return false
+	}
+	return true
+}
+
+// GetTwoFactorStatus reports whether 2FA is enabled and how many backup codes are left
+func GetTwoFactorStatus(c *gin.Context) {
+	status, err := services.GetTwoFactorStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// SetUpTwoFactor generates a TOTP secret to scan into an authenticator app. Logins
+// only need codes once EnableTwoFactor confirmed one.
+func SetUpTwoFactor(c *gin.Context) {
+	setup, err := services.SetUpTwoFactor()
+	if errors.Is(err, services.ErrTwoFactorEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	png, err := qrcode.Encode(setup.OTPAuthURL, qrcode.Medium, totpQRSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+	c.JSON(http.StatusOK, TwoFactorSetupResponse{
+		TwoFactorSetup: *setup,
+		QRCode:         "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// EnableTwoFactor turns 2FA on with a code from the set-up secret and returns the
+// backup codes, which are only shown here
+func EnableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := services.EnableTwoFactor(req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "backup_codes": codes})
+}
+
+// DisableTwoFactor turns 2FA off with a TOTP or backup code
+func DisableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.DisableTwoFactor(req.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// RegenerateBackupCodes replaces the backup codes, confirmed with a TOTP code
+func RegenerateBackupCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	codes, err := services.RegenerateBackupCodes(req.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"backup_codes": codes})
+}
+
+// respondTwoFactorError answers a failed 2FA change
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid authentication code"})
+	case errors.Is(err, services.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+	case errors.Is(err, services.ErrTwoFactorNotSetUp), errors.Is(err, services.ErrTwoFactorDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
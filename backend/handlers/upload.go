@@ -1,53 +1,166 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"photobridge/common"
 	"photobridge/config"
 	"photobridge/database"
+	"photobridge/middleware"
 	"photobridge/models"
 	"photobridge/services"
+	"photobridge/storage"
 	"photobridge/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-const photoMetaColumns = "id, project_id, base_name, normal_ext, raw_ext, has_raw, file_hash, normal_hash, raw_hash, thumb_width, thumb_height, created_at, updated_at"
+const (
+	photoMetaColumns  = common.PhotoMetaColumns
+	photoAdminColumns = common.PhotoAdminColumns
+)
+
+// UploadedPhoto is a photo in an upload response, with the state of its thumbnail
+type UploadedPhoto struct {
+	models.Photo
+	ThumbStatus string `json:"thumb_status"` // ready, queued, raw_only or unavailable
+}
+
+// Per-file upload outcomes
+const (
+	UploadStatusCreated   = "created"   // new photo record
+	UploadStatusUpdated   = "updated"   // file added to or replaced in an existing photo
+	UploadStatusDuplicate = "duplicate" // identical file already in the project, nothing written
+	UploadStatusFailed    = "failed"
+)
+
+// Machine-readable reasons for failed uploads
+const (
+	UploadErrHash         = "hash_failed"
+	UploadErrInvalidPath  = "invalid_path"
+	UploadErrSave         = "save_failed"
+	UploadErrInvalidRaw   = "invalid_raw"
+	UploadErrInvalidImage = "invalid_image"
+	UploadErrTypeMismatch = "type_mismatch" // a photo of another format than its extension names (detected_type)
+	UploadErrDatabase     = "db_error"
+	UploadErrTooLarge     = "too_large"      // larger than MAX_UPLOAD_SIZE_MB
+	UploadErrQuota        = "quota_exceeded" // the project's storage quota has no room left
+)
+
+// FileUploadResult describes what happened to one file of an upload request
+type FileUploadResult struct {
+	File         string `json:"file"`
+	Status       string `json:"status"`
+	PhotoID      uint   `json:"photo_id,omitempty"`
+	Hash         string `json:"hash,omitempty"`
+	ThumbStatus  string `json:"thumb_status,omitempty"`
+	ErrorCode    string `json:"error_code,omitempty"`
+	Error        string `json:"error,omitempty"`
+	DetectedType string `json:"detected_type,omitempty"` // MIME type of the content, for type_mismatch
+}
+
+// uploadError is a processing error tagged with one of the UploadErr codes
+type uploadError struct {
+	code string
+	err  error
+}
+
+func (e *uploadError) Error() string { return e.err.Error() }
+func (e *uploadError) Unwrap() error { return e.err }
+
+func newUploadError(code string, err error) error {
+	return &uploadError{code: code, err: err}
+}
+
+// uploadSource is a received file: a file of a multipart upload or an assembled chunked upload
+type uploadSource interface {
+	name() string
+	size() int64
+	hash() (string, error)
+	saveTo(dst string) error
+}
+
+// formFile is a file of a multipart upload request
+type formFile struct {
+	c    *gin.Context
+	file *multipart.FileHeader
+}
+
+func (f formFile) name() string            { return filepath.Base(f.file.Filename) }
+func (f formFile) size() int64             { return f.file.Size }
+func (f formFile) hash() (string, error)   { return utils.CalculateFileHash(f.file) }
+func (f formFile) saveTo(dst string) error { return f.c.SaveUploadedFile(f.file, dst) }
 
 // processUploadedFile handles the common logic for processing an uploaded file
-// Returns the photo model and any error
-func processUploadedFile(c *gin.Context, file *multipart.FileHeader, project *models.Project, uploadDir string) (*models.Photo, error) {
-	filename := filepath.Base(file.Filename)
+// Returns the photo model, its upload status, the file hash and any error (an *uploadError)
+func processUploadedFile(file uploadSource, project *models.Project, uploadDir string) (*models.Photo, string, string, error) {
+	filename := file.name()
 	origExt := filepath.Ext(filename)
 	ext := strings.ToLower(origExt)
 	baseName := strings.TrimSuffix(filename, origExt)
+	services.PublishProjectEvent(project.ID, services.ProjectEvent{Type: services.EventUploadReceived, File: filename, Size: file.size()})
+
+	if err := services.CheckUploadSize(file.size()); err != nil {
+		return nil, "", "", newUploadError(UploadErrTooLarge, err)
+	}
 
 	// Calculate file hash for deduplication
-	fileHash, err := utils.CalculateFileHash(file)
+	fileHash, err := file.hash()
 	if err != nil {
-		return nil, fmt.Errorf("failed to calculate file hash: %v", err)
+		return nil, "", "", newUploadError(UploadErrHash, fmt.Errorf("failed to calculate file hash: %v", err))
 	}
+	services.PublishProjectEvent(project.ID, services.ProjectEvent{Type: services.EventHashComputed, File: filename, Hash: fileHash})
 
 	// Check if file with same hash already exists in this project
-	// Check appropriate hash field based on file type
+	// Check appropriate hash field based on file type; a match only counts if that
+	// photo actually has a file of the same type (file_hash is also set for RAW-only photos)
 	var existingByHash models.Photo
 	isRaw := models.IsRawExtension(ext)
+	kind := "normal"
+	dedupQuery := database.DB.Select(photoMetaColumns)
 	if isRaw {
-		// Check raw_hash field for RAW files
-		if err := database.DB.Select(photoMetaColumns).Where("project_id = ? AND raw_hash = ?", project.ID, fileHash).First(&existingByHash).Error; err == nil {
-			return &existingByHash, nil
-		}
+		kind = "raw"
+		dedupQuery = dedupQuery.Where("project_id = ? AND raw_ext <> '' AND raw_hash = ?", project.ID, fileHash)
 	} else {
-		// Check normal_hash and file_hash (backward compatibility) for normal images
-		if err := database.DB.Select(photoMetaColumns).Where("project_id = ? AND (normal_hash = ? OR file_hash = ?)", project.ID, fileHash, fileHash).First(&existingByHash).Error; err == nil {
-			return &existingByHash, nil
+		// normal_hash, or file_hash for records from before normal_hash existed
+		dedupQuery = dedupQuery.Where("project_id = ? AND normal_ext <> '' AND (normal_hash = ? OR (normal_hash = '' AND file_hash = ?))",
+			project.ID, fileHash, fileHash)
+	}
+	if err := dedupQuery.First(&existingByHash).Error; err == nil {
+		existingPath, err := photoFilePath(project.Name, &existingByHash, kind)
+		if err != nil {
+			return nil, "", fileHash, newUploadError(UploadErrInvalidPath, fmt.Errorf("invalid file path: %w", err))
+		}
+		if _, err := statPhotoFile(project.Name, &existingByHash, kind); !errors.Is(err, os.ErrNotExist) {
+			return &existingByHash, UploadStatusDuplicate, fileHash, nil
 		}
+		// The record exists but its file was lost: store this upload in its place
+		if err := saveUploadedPhotoFile(file, existingPath, isRaw); err != nil {
+			return nil, "", fileHash, err
+		}
+		if err := storePhotoFile(project.Name, existingPath); err != nil {
+			return nil, "", fileHash, err
+		}
+		log.Printf("[Upload] Restored missing file %s of photo %d", filepath.Base(existingPath), existingByHash.ID)
+		return &existingByHash, UploadStatusUpdated, fileHash, nil
+	}
+
+	// Duplicates and restored files take no room; new files must fit in the quota
+	if err := services.CheckProjectQuota(project, file.size()); err != nil {
+		if errors.Is(err, services.ErrQuotaExceeded) {
+			return nil, "", fileHash, newUploadError(UploadErrQuota, err)
+		}
+		return nil, "", fileHash, newUploadError(UploadErrDatabase, err)
 	}
 
 	// Save file with lowercase extension for consistency
@@ -57,26 +170,26 @@ func processUploadedFile(c *gin.Context, file *multipart.FileHeader, project *mo
 	// Validate destination path is secure
 	safeDst, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, dst)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file path: %w", err)
+		return nil, "", fileHash, newUploadError(UploadErrInvalidPath, fmt.Errorf("invalid file path: %w", err))
 	}
 
-	if err := c.SaveUploadedFile(file, safeDst); err != nil {
-		return nil, err
+	if err := saveUploadedPhotoFile(file, safeDst, isRaw); err != nil {
+		return nil, "", fileHash, err
 	}
 
-	// Validate file type by magic number
-	if isRaw {
-		// Validate RAW file (more permissive due to variety of formats)
-		if err := utils.ValidateRAWFile(safeDst); err != nil {
-			os.Remove(safeDst) // Clean up invalid file
-			return nil, fmt.Errorf("invalid RAW file: %w", err)
-		}
-	} else {
-		// Validate normal image file with strict magic number checking
-		if _, err := utils.ValidateImageFile(safeDst, nil); err != nil {
-			os.Remove(safeDst) // Clean up invalid file
-			return nil, fmt.Errorf("invalid image file: %w", err)
-		}
+	// EXIF summary and capture time (RAW and normal files of the same shot share them)
+	meta := uploadedFileMeta{Hash: fileHash}
+	meta.Exif, meta.CapturedAt = services.ReadPhotoExif(safeDst)
+	// Dimensions come from the image header so listings can reserve space before thumbnails exist
+	if !isRaw {
+		meta.Width, meta.Height, _ = utils.ReadImageSize(safeDst)
+	}
+	// Size of the stored file (after normalization), for download size estimates
+	if info, err := os.Stat(safeDst); err == nil {
+		meta.Size = info.Size()
+	}
+	if err := storePhotoFile(project.Name, safeDst); err != nil {
+		return nil, "", fileHash, err
 	}
 
 	// Check if photo with same base name exists
@@ -84,27 +197,10 @@ func processUploadedFile(c *gin.Context, file *multipart.FileHeader, project *mo
 	result := database.DB.Select(photoMetaColumns).Where("project_id = ? AND base_name = ?", project.ID, baseName).First(&existingPhoto)
 
 	if result.Error == nil {
-		updates := map[string]interface{}{}
-		if models.IsRawExtension(ext) {
-			updates["raw_ext"] = ext
-			updates["has_raw"] = true
-			updates["raw_hash"] = fileHash
-		} else if models.IsImageExtension(ext) {
-			updates["normal_ext"] = ext
-			updates["normal_hash"] = fileHash
-			updates["file_hash"] = fileHash // Keep for backward compatibility
-			updates["thumb_small"] = nil
-			updates["thumb_large"] = nil
-			updates["thumb_width"] = 0
-			updates["thumb_height"] = 0
+		if err := mergeIntoExistingPhoto(&existingPhoto, project, ext, meta); err != nil {
+			return nil, "", fileHash, newUploadError(UploadErrDatabase, err)
 		}
-		if len(updates) > 0 {
-			if err := database.DB.Model(&models.Photo{}).Where("id = ?", existingPhoto.ID).Updates(updates).Error; err != nil {
-				return nil, err
-			}
-			_ = database.DB.Select(photoMetaColumns).First(&existingPhoto, existingPhoto.ID).Error
-		}
-		return &existingPhoto, nil
+		return &existingPhoto, UploadStatusUpdated, fileHash, nil
 	}
 
 	// Create new photo (涓嶇敓鎴愮缉鐣ュ浘锛屾祻瑙堟椂鎸夐渶鐢熸垚)
@@ -112,42 +208,272 @@ func processUploadedFile(c *gin.Context, file *multipart.FileHeader, project *mo
 		ProjectID: project.ID,
 		BaseName:  baseName,
 		FileHash:  fileHash, // Keep for backward compatibility
+		TakenAt:   meta.CapturedAt,
+		Exif:      meta.Exif,
 	}
 	if models.IsRawExtension(ext) {
 		photo.RawExt = ext
 		photo.HasRaw = true
 		photo.RawHash = fileHash
+		photo.RawSize = meta.Size
 	} else if models.IsImageExtension(ext) {
 		photo.NormalExt = ext
 		photo.NormalHash = fileHash
+		photo.NormalSize = meta.Size
+		photo.Width = meta.Width
+		photo.Height = meta.Height
+	}
+	if err := database.DB.Create(&photo).Error; err != nil {
+		// A parallel request (e.g. the RAW and JPEG of one shot) created the photo after
+		// our lookup; the unique base name index rejected the second row, so merge instead
+		if database.IsDuplicateKey(database.DB, err) &&
+			database.DB.Select(photoMetaColumns).Where("project_id = ? AND base_name = ?", project.ID, baseName).First(&existingPhoto).Error == nil {
+			if err := mergeIntoExistingPhoto(&existingPhoto, project, ext, meta); err != nil {
+				return nil, "", fileHash, newUploadError(UploadErrDatabase, err)
+			}
+			return &existingPhoto, UploadStatusUpdated, fileHash, nil
+		}
+		os.Remove(safeDst)
+		storage.Default().Delete(context.Background(), storage.Key(project.Name, newFilename))
+		return nil, "", fileHash, newUploadError(UploadErrDatabase, err)
+	}
+
+	// Set first photo as cover if not set (RAW files can't be shown as a cover)
+	if project.CoverPhoto == "" && photo.NormalExt != "" {
+		if services.SetDefaultCoverPhoto(project.ID, services.CoverPhotoName(&photo)) {
+			project.CoverPhoto = services.CoverPhotoName(&photo)
+		}
+	}
+
+	return &photo, UploadStatusCreated, fileHash, nil
+}
+
+// uploadedFileMeta holds what was read from a saved upload
+type uploadedFileMeta struct {
+	Hash       string
+	CapturedAt *time.Time
+	Exif       models.PhotoExif
+	Width      int // 0 for RAW files or undecodable headers
+	Height     int
+	Size       int64 // Stored file size in bytes
+}
+
+// mergeIntoExistingPhoto records an uploaded file on the photo with the same base name
+// (adding its RAW or replacing its normal image) and reloads it
+func mergeIntoExistingPhoto(existingPhoto *models.Photo, project *models.Project, ext string, meta uploadedFileMeta) error {
+	updates := map[string]interface{}{}
+	if models.IsRawExtension(ext) {
+		updates["raw_ext"] = ext
+		updates["has_raw"] = true
+		updates["raw_hash"] = meta.Hash
+		updates["raw_size"] = meta.Size
+		if existingPhoto.NormalExt == "" {
+			// Thumbnails of a RAW-only photo came from the replaced RAW's preview
+			updates["thumb_width"] = 0
+			updates["thumb_height"] = 0
+		}
+	} else if models.IsImageExtension(ext) {
+		updates["normal_ext"] = ext
+		updates["normal_hash"] = meta.Hash
+		updates["file_hash"] = meta.Hash // Keep for backward compatibility
+		updates["normal_size"] = meta.Size
+		updates["thumb_small"] = nil
+		updates["thumb_large"] = nil
+		updates["thumb_width"] = 0
+		updates["thumb_height"] = 0
+		updates["width"] = meta.Width
+		updates["height"] = meta.Height
+	}
+	if meta.CapturedAt != nil && existingPhoto.TakenAt == nil {
+		updates["taken_at"] = *meta.CapturedAt
+	}
+	// The RAW's EXIF is the camera's own; a normal image's only counts without a RAW
+	if models.IsRawExtension(ext) || !existingPhoto.HasRaw {
+		for column, value := range services.ExifUpdates(meta.Exif) {
+			updates[column] = value
+		}
+	}
+	previousCover := services.CoverPhotoName(existingPhoto)
+	if len(updates) > 0 {
+		if err := database.DB.Model(&models.Photo{}).Where("id = ?", existingPhoto.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if _, replaced := updates["thumb_width"]; replaced {
+			services.RemoveThumbnails(project.ID, existingPhoto.ID)
+		}
+		_ = database.DB.Select(photoMetaColumns).First(existingPhoto, existingPhoto.ID).Error
+	}
+	// Keep the cover on this photo when its image is replaced by another format
+	if cover := services.CoverPhotoName(existingPhoto); existingPhoto.NormalExt != "" && cover != previousCover {
+		services.ReplaceCoverPhoto(project.ID, previousCover, cover)
+		if project.CoverPhoto == "" {
+			services.SetDefaultCoverPhoto(project.ID, cover)
+		}
+	}
+	return nil
+}
+
+// saveUploadedPhotoFile writes an uploaded file to dst and validates its magic number,
+// removing it again if it is not a valid image or RAW file. JPEGs are normalized
+// (see utils.NormalizeJPEG) when NORMALIZE_UPLOADS is enabled.
+func saveUploadedPhotoFile(file uploadSource, dst string, isRaw bool) error {
+	// Unlink first so a file hard-linked by duplicate resolution is replaced, not written through
+	os.Remove(dst)
+	if err := file.saveTo(dst); err != nil {
+		return newUploadError(UploadErrSave, err)
 	}
-	database.DB.Create(&photo)
 
-	// Set first photo as cover if not set
-	if project.CoverPhoto == "" {
-		project.CoverPhoto = baseName + ext
-		database.DB.Save(project)
+	// Validate file type by magic number; the content must match the extension
+	var typeErr *utils.FileTypeError
+	if isRaw {
+		// Validate RAW file by the headers of the supported RAW formats
+		if err := utils.ValidateRAWFile(dst); err != nil {
+			os.Remove(dst) // Clean up invalid file
+			if errors.As(err, &typeErr) {
+				return newUploadError(UploadErrTypeMismatch, err)
+			}
+			return newUploadError(UploadErrInvalidRaw, fmt.Errorf("invalid RAW file: %w", err))
+		}
+	} else {
+		// Validate normal image file with strict magic number checking
+		if err := utils.ValidateImageContent(dst); err != nil {
+			os.Remove(dst) // Clean up invalid file
+			if errors.As(err, &typeErr) {
+				return newUploadError(UploadErrTypeMismatch, err)
+			}
+			return newUploadError(UploadErrInvalidImage, fmt.Errorf("invalid image file: %w", err))
+		}
+		if config.AppConfig.NormalizeUploads {
+			// Bake in the EXIF rotation so viewers ignoring it show the photo upright;
+			// the file hash stays that of the upload so re-uploads are still detected
+			if _, err := utils.NormalizeJPEG(dst, config.AppConfig.NormalizeQuality); err != nil {
+				log.Printf("[Upload] Failed to normalize %s, keeping it as uploaded: %v", filepath.Base(dst), err)
+			}
+		}
+	}
+	return nil
+}
+
+// storePhotoFile moves a file saved to the project directory into storage (a no-op
+// with local storage)
+func storePhotoFile(projectName, path string) error {
+	if err := storage.Store(context.Background(), storage.Key(projectName, filepath.Base(path)), path); err != nil {
+		os.Remove(path)
+		return newUploadError(UploadErrSave, fmt.Errorf("failed to store file: %w", err))
 	}
+	return nil
+}
+
+// processUploadedFiles runs every file of an upload through processUploadedFile.
+// It returns the stored photos, one result per file (in request order) and the failed file names.
+func processUploadedFiles(c *gin.Context, files []*multipart.FileHeader, project *models.Project, uploadDir string) ([]UploadedPhoto, []FileUploadResult, []string) {
+	uploadedPhotos := []UploadedPhoto{}
+	results := make([]FileUploadResult, 0, len(files))
+	var failedFiles []string
+
+	for _, file := range files {
+		source := formFile{c: c, file: file}
+		photo, status, hash, err := processUploadedFile(source, project, uploadDir)
+		result, uploaded := describeUpload(source.name(), photo, status, hash, err, project)
+		results = append(results, result)
+		if uploaded == nil {
+			failedFiles = append(failedFiles, result.File)
+			continue
+		}
+		uploadedPhotos = append(uploadedPhotos, *uploaded)
+	}
+
+	return uploadedPhotos, results, failedFiles
+}
 
-	return &photo, nil
+// describeUpload turns the outcome of processUploadedFile into the file's result and,
+// unless it failed, the uploaded photo
+func describeUpload(fileName string, photo *models.Photo, status, hash string, err error, project *models.Project) (FileUploadResult, *UploadedPhoto) {
+	result := FileUploadResult{File: fileName, Hash: hash}
+	if err != nil {
+		result.Status = UploadStatusFailed
+		result.ErrorCode = UploadErrSave
+		var uerr *uploadError
+		if errors.As(err, &uerr) {
+			result.ErrorCode = uerr.code
+		}
+		var typeErr *utils.FileTypeError
+		if errors.As(err, &typeErr) {
+			result.DetectedType = typeErr.DetectedType
+		}
+		result.Error = err.Error()
+		services.PublishProjectEvent(project.ID, services.ProjectEvent{
+			Type:      services.EventUploadFailed,
+			File:      fileName,
+			Hash:      hash,
+			ErrorCode: result.ErrorCode,
+			Error:     result.Error,
+		})
+		return result, nil
+	}
+
+	// Enqueue for thumbnail generation right away so the first gallery visit finds it ready
+	uploaded := UploadedPhoto{
+		Photo:       *photo,
+		ThumbStatus: services.Queue.ThumbnailStatus(photo, project.Name),
+	}
+	result.Status = status
+	result.PhotoID = photo.ID
+	result.ThumbStatus = uploaded.ThumbStatus
+	services.PublishProjectEvent(project.ID, services.ProjectEvent{
+		Type:        services.EventUploadDone,
+		File:        fileName,
+		PhotoID:     photo.ID,
+		Hash:        hash,
+		Status:      status,
+		ThumbStatus: uploaded.ThumbStatus,
+	})
+	return result, &uploaded
 }
 
-// prepareUpload validates and prepares for file upload
-// Returns files, uploadDir, and any error
-func prepareUpload(c *gin.Context, project *models.Project) ([]*multipart.FileHeader, string, error) {
+// errUploadProjectGone means the project was deleted while the upload waited for its lock
+var errUploadProjectGone = errors.New("project not found")
+
+// prepareUpload validates and prepares for file upload.
+// A project whose quota is used up is refused before the body is read. The request
+// body is read first, then the project's upload directory is locked (see
+// lockUploadDir). Returns files, uploadDir, the unlock function (call it when done) and any error
+func prepareUpload(c *gin.Context, project *models.Project) ([]*multipart.FileHeader, string, func(), error) {
+	if err := services.CheckProjectQuota(project, 1); err != nil {
+		return nil, "", nil, err
+	}
+
 	form, err := c.MultipartForm()
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to parse form")
+		return nil, "", nil, fmt.Errorf("failed to parse form")
 	}
 
 	files := form.File["files"]
 	if len(files) == 0 {
-		return nil, "", fmt.Errorf("no files uploaded")
+		return nil, "", nil, fmt.Errorf("no files uploaded")
+	}
+
+	uploadDir, unlock, err := lockUploadDir(project)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return files, uploadDir, unlock, nil
+}
+
+// lockUploadDir takes the project's shared lock (so a rename or delete can't move the
+// directory mid-upload), reloads the project and creates its upload directory.
+// Returns uploadDir and the unlock function (call it when done)
+func lockUploadDir(project *models.Project) (string, func(), error) {
+	unlock := services.RLockProject(project.ID)
+	if err := database.DB.First(project, project.ID).Error; err != nil {
+		unlock()
+		return "", nil, errUploadProjectGone
 	}
 
 	// Validate project name for path safety
 	if !utils.ValidatePathComponent(project.Name) {
-		return nil, "", fmt.Errorf("invalid project name")
+		unlock()
+		return "", nil, fmt.Errorf("invalid project name")
 	}
 
 	// Create project upload directory
@@ -156,14 +482,28 @@ func prepareUpload(c *gin.Context, project *models.Project) ([]*multipart.FileHe
 	// Validate the upload directory path is secure
 	safeUploadDir, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, uploadDir)
 	if err != nil {
-		return nil, "", fmt.Errorf("invalid upload directory path: %w", err)
+		unlock()
+		return "", nil, fmt.Errorf("invalid upload directory path: %w", err)
 	}
 
 	if err := os.MkdirAll(safeUploadDir, 0755); err != nil {
-		return nil, "", fmt.Errorf("failed to create upload directory")
+		unlock()
+		return "", nil, fmt.Errorf("failed to create upload directory")
 	}
 
-	return files, safeUploadDir, nil
+	return safeUploadDir, unlock, nil
+}
+
+// respondPrepareUploadError maps a prepareUpload error to a response
+func respondPrepareUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errUploadProjectGone):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	case errors.Is(err, services.ErrFileTooLarge), errors.Is(err, services.ErrQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
 }
 
 func UploadPhotos(c *gin.Context) {
@@ -175,32 +515,24 @@ func UploadPhotos(c *gin.Context) {
 		return
 	}
 
-	files, uploadDir, err := prepareUpload(c, &project)
+	files, uploadDir, unlock, err := prepareUpload(c, &project)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondPrepareUploadError(c, err)
 		return
 	}
-
-	var uploadedPhotos []models.Photo
-	var failedFiles []string
-
-	for _, file := range files {
-		photo, err := processUploadedFile(c, file, &project, uploadDir)
-		if err != nil {
-			failedFiles = append(failedFiles, filepath.Base(file.Filename))
-			continue
-		}
-		uploadedPhotos = append(uploadedPhotos, *photo)
-
-		// Enqueue for thumbnail generation
-		if services.Queue != nil && photo.NormalExt != "" {
-			services.Queue.Enqueue(photo, project.Name)
-		}
+	defer unlock()
+	album, ok := uploadAlbum(c, &project)
+	if !ok {
+		return
 	}
 
+	uploadedPhotos, results, failedFiles := processUploadedFiles(c, files, &project, uploadDir)
+	assignUploadAlbum(album, &project, uploadedPhotos)
+
 	response := gin.H{
 		"message": fmt.Sprintf("Uploaded %d files", len(uploadedPhotos)),
 		"photos":  uploadedPhotos,
+		"results": results,
 	}
 	if len(failedFiles) > 0 {
 		response["failed"] = failedFiles
@@ -228,32 +560,26 @@ func UploadViaAPI(c *gin.Context) {
 		database.DB.Create(&project)
 	}
 
-	files, uploadDir, err := prepareUpload(c, &project)
+	files, uploadDir, unlock, err := prepareUpload(c, &project)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondPrepareUploadError(c, err)
 		return
 	}
-
-	var uploadedCount int
-	var failedFiles []string
-
-	for _, file := range files {
-		photo, err := processUploadedFile(c, file, &project, uploadDir)
-		if err != nil {
-			failedFiles = append(failedFiles, filepath.Base(file.Filename))
-			continue
-		}
-		uploadedCount++
-
-		// Enqueue for thumbnail generation
-		if services.Queue != nil && photo.NormalExt != "" {
-			services.Queue.Enqueue(photo, project.Name)
-		}
+	defer unlock()
+	album, ok := uploadAlbum(c, &project)
+	if !ok {
+		return
 	}
 
+	uploadedPhotos, results, failedFiles := processUploadedFiles(c, files, &project, uploadDir)
+	assignUploadAlbum(album, &project, uploadedPhotos)
+	uploadedCount := len(uploadedPhotos)
+
 	response := gin.H{
 		"message": fmt.Sprintf("Uploaded %d files to project '%s'", uploadedCount, project.Name),
 		"project": project,
+		"photos":  uploadedPhotos,
+		"results": results,
 	}
 	if len(failedFiles) > 0 {
 		response["failed"] = failedFiles
@@ -262,25 +588,72 @@ func UploadViaAPI(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetProjectPhotos lists a project's photos, paged, sorted and filtered by the query
+// parameters of models.PhotoListQuery; X-Total-Count has the number of matching photos
 func GetProjectPhotos(c *gin.Context) {
+	q, ok := bindPhotoListQuery(c)
+	if !ok {
+		return
+	}
 	projectID := c.Param("id")
 	var photos []models.Photo
 
-	result := database.DB.Select(photoMetaColumns).Where("project_id = ?", projectID).Find(&photos)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	total, err := services.ListPhotos(database.DB.Preload("Tags").Where("project_id = ?", projectID), photoAdminColumns, q, &photos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if q.GroupBy == models.PhotoGroupDay {
+		c.JSON(http.StatusOK, gin.H{"groups": groupPhotoDays(photos, photos)})
+		return
+	}
 	c.JSON(http.StatusOK, photos)
 }
 
+// PhotoDayGroup is the photos of one capture day in a listing with group_by=day. With
+// paging, a day can continue on the next page; clients merge groups with the same date.
+type PhotoDayGroup[T any] struct {
+	Date   string `json:"date"` // YYYY-MM-DD
+	Count  int    `json:"count"`
+	Photos []T    `json:"photos"`
+}
+
+// groupPhotoDays groups the response items of listed photos (one item per photo, in
+// the same order) by capture day
+func groupPhotoDays[T any](photos []models.Photo, items []T) []PhotoDayGroup[T] {
+	groups := []PhotoDayGroup[T]{}
+	for _, day := range services.GroupPhotosByDay(photos) {
+		groups = append(groups, PhotoDayGroup[T]{Date: day.Date, Count: day.End - day.Start, Photos: items[day.Start:day.End]})
+	}
+	return groups
+}
+
+// bindPhotoListQuery reads the paging, sort and filter parameters of a photo listing
+func bindPhotoListQuery(c *gin.Context) (models.PhotoListQuery, bool) {
+	var q models.PhotoListQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return q, false
+	}
+	if err := q.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return q, false
+	}
+	return q, true
+}
+
 // API Key authenticated handlers
 
-// GetProjectsViaAPI returns all projects (API Key auth)
+// GetProjectsViaAPI returns all projects, or the one a scoped key is limited to (API Key auth)
 func GetProjectsViaAPI(c *gin.Context) {
 	var projects []models.Project
-	result := database.DB.Find(&projects)
+	query := database.DB
+	if key := middleware.CurrentAPIKey(c); key != nil && key.ProjectID != nil {
+		query = query.Where("id = ?", *key.ProjectID)
+	}
+	result := query.Find(&projects)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
 		return
@@ -315,8 +688,12 @@ func GetProjectsViaAPI(c *gin.Context) {
 	})
 }
 
-// GetProjectPhotosViaAPI returns all photos in a project (API Key auth)
+// GetProjectPhotosViaAPI returns the photos in a project, all or one page of them (API Key auth)
 func GetProjectPhotosViaAPI(c *gin.Context) {
+	q, ok := bindPhotoListQuery(c)
+	if !ok {
+		return
+	}
 	projectName := c.Param("project")
 
 	// Sanitize project name
@@ -335,8 +712,12 @@ func GetProjectPhotosViaAPI(c *gin.Context) {
 
 	// Get photos
 	var photos []models.Photo
-	database.DB.Select("id, base_name, normal_ext, raw_ext, has_raw, file_hash, created_at").
-		Where("project_id = ?", project.ID).Find(&photos)
+	total, err := services.ListPhotos(database.DB.Where("project_id = ?", project.ID),
+		"id, base_name, normal_ext, raw_ext, has_raw, file_hash, taken_at, created_at", q, &photos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
 
 	// Build response
 	type PhotoInfo struct {
@@ -346,12 +727,13 @@ func GetProjectPhotosViaAPI(c *gin.Context) {
 		RawExt    string `json:"raw_ext,omitempty"`
 		HasRaw    bool   `json:"has_raw"`
 		FileHash  string `json:"file_hash,omitempty"`
+		TakenAt   string `json:"taken_at,omitempty"`
 		CreatedAt string `json:"created_at"`
 	}
 
 	var response []PhotoInfo
 	for _, p := range photos {
-		response = append(response, PhotoInfo{
+		info := PhotoInfo{
 			ID:        p.ID,
 			BaseName:  p.BaseName,
 			NormalExt: p.NormalExt,
@@ -359,18 +741,32 @@ func GetProjectPhotosViaAPI(c *gin.Context) {
 			HasRaw:    p.HasRaw,
 			FileHash:  p.FileHash,
 			CreatedAt: p.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		})
+		}
+		if p.TakenAt != nil {
+			info.TakenAt = p.TakenAt.Format("2006-01-02T15:04:05Z")
+		}
+		response = append(response, info)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	body := gin.H{
 		"project": gin.H{
 			"id":          project.ID,
 			"name":        project.Name,
 			"description": project.Description,
 		},
 		"photos": response,
-		"total":  len(response),
-	})
+		"total":  total,
+	}
+	if q.GroupBy == models.PhotoGroupDay {
+		delete(body, "photos")
+		body["groups"] = groupPhotoDays(photos, response)
+	}
+	if q.Paged() {
+		perPage, _ := q.Limit()
+		body["page"] = max(q.Page, 1)
+		body["per_page"] = perPage
+	}
+	c.JSON(http.StatusOK, body)
 }
 
 // CreateProjectViaAPI creates a new project (API Key auth)
@@ -437,6 +833,14 @@ func DeleteProjectViaAPI(c *gin.Context) {
 		return
 	}
 
+	// Exclusive lock: wait for running uploads and keep new ones out
+	unlock := services.LockProject(project.ID)
+	defer unlock()
+	if err := database.DB.Where("id = ? AND name = ?", project.ID, sanitizedName).First(&project).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
 	// Check if project has photos
 	photoCount := common.CountPhotosInProject(project.ID)
 	if photoCount > 0 {
@@ -447,27 +851,10 @@ func DeleteProjectViaAPI(c *gin.Context) {
 		return
 	}
 
-	// Get share link IDs for deletion
-	var linkIDs []uint
-	database.DB.Model(&models.ShareLink{}).Where("project_id = ?", project.ID).Pluck("id", &linkIDs)
-	if len(linkIDs) > 0 {
-		database.DB.Where("link_id IN ?", linkIDs).Delete(&models.PhotoExclusion{})
-	}
-
-	// Delete share links
-	database.DB.Where("project_id = ?", project.ID).Delete(&models.ShareLink{})
-
-	// Delete project
-	database.DB.Delete(&project)
-
-	// Delete upload directory with security validation
-	uploadDir := filepath.Join(config.AppConfig.UploadDir, project.Name)
-	safeUploadDir, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, uploadDir)
-	if err == nil {
-		// Only delete if path validation succeeds
-		os.RemoveAll(safeUploadDir)
+	if err := services.DeleteProject(&project); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		return
 	}
-	// If validation fails, silently skip deletion to avoid errors during project cleanup
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": fmt.Sprintf("Project '%s' deleted successfully", project.Name),
@@ -501,20 +888,23 @@ func CheckHashes(c *gin.Context) {
 
 	// Query existing hashes - check normal_hash, raw_hash, and file_hash (backward compatibility)
 	var existingPhotos []models.Photo
-	database.DB.Select("normal_hash, raw_hash, file_hash").
+	database.DB.Select(photoMetaColumns).
 		Where("project_id = ? AND (normal_hash IN ? OR raw_hash IN ? OR file_hash IN ?)",
 			project.ID, req.Hashes, req.Hashes, req.Hashes).Find(&existingPhotos)
 
+	// A hash only counts as existing while its file is on disk, so lost files get uploaded again
 	existingSet := make(map[string]bool)
-	for _, photo := range existingPhotos {
-		if photo.NormalHash != "" {
-			existingSet[photo.NormalHash] = true
+	for i := range existingPhotos {
+		photo := &existingPhotos[i]
+		normalHash := photo.NormalHash
+		if normalHash == "" {
+			normalHash = photo.FileHash
 		}
-		if photo.RawHash != "" {
-			existingSet[photo.RawHash] = true
+		if photo.NormalExt != "" && normalHash != "" && photoFileExists(project.Name, photo, "normal") {
+			existingSet[normalHash] = true
 		}
-		if photo.FileHash != "" {
-			existingSet[photo.FileHash] = true
+		if photo.RawExt != "" && photo.RawHash != "" && photoFileExists(project.Name, photo, "raw") {
+			existingSet[photo.RawHash] = true
 		}
 	}
 
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setVersionETag sends the version of a project or share link as its ETag
+func setVersionETag(c *gin.Context, version uint) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// ifMatchVersion reads the version an update expects from If-Match (the ETag or the
+// "version" field of an earlier response). It returns 0 when the header is missing
+// or "*", which skips the check; a malformed header is answered with 400.
+func ifMatchVersion(c *gin.Context) (uint, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || version == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "If-Match must be a version such as \"3\""})
+		return 0, false
+	}
+	return uint(version), true
+}
+
+// respondVersionConflict answers an update whose If-Match version is outdated with
+// the current state, so the client can show it and retry
+func respondVersionConflict(c *gin.Context, version uint, current interface{}) {
+	setVersionETag(c, version)
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":   "Modified by another session, reload and try again",
+		"current": current,
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"photobridge/utils"
+)
+
+// runHashPassword implements "photobridge hash-password": reads a password from stdin
+// and prints its bcrypt hash, to set ADMIN_PASSWORD without keeping the password in
+// the environment. Reading stdin keeps it out of the shell history.
+func runHashPassword() {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("%s Failed to read the password: %v", shortname, err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		log.Fatalf("%s The password must not be empty", shortname)
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Fatalf("%s Failed to hash the password: %v", shortname, err)
+	}
+	fmt.Println(hash)
+}
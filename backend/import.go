@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"photobridge/services"
+)
+
+// runImport implements "photobridge import [flags] <dir>": proposes one project per
+// folder of an existing photo archive, pairing JPEG and RAW files by base name.
+// With -apply the files are copied (or linked/moved) into the upload directory and
+// recorded with hashes and thumbnails. The proposal can be written with -out, edited
+// (project names, "skip": true) and applied with -plan.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "import the files (default: only print the proposed mapping)")
+	mode := fs.String("mode", services.ImportCopy, "how files are transferred: copy, link or move")
+	thumbs := fs.Bool("thumbs", true, "generate thumbnails during the import")
+	out := fs.String("out", "", "write the proposed plan as JSON to this file")
+	planFile := fs.String("plan", "", "use an edited plan instead of scanning")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: photobridge import [flags] <dir>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var plan *services.ImportPlan
+	switch {
+	case *planFile != "":
+		data, err := os.ReadFile(*planFile)
+		if err != nil {
+			log.Fatalf("%s Failed to read plan: %v", shortname, err)
+		}
+		plan = &services.ImportPlan{}
+		if err := json.Unmarshal(data, plan); err != nil {
+			log.Fatalf("%s Invalid plan %s: %v", shortname, *planFile, err)
+		}
+	case fs.NArg() == 1:
+		var err error
+		if plan, err = services.ScanImportDir(fs.Arg(0)); err != nil {
+			log.Fatalf("%s Failed to scan %s: %v", shortname, fs.Arg(0), err)
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	printImportPlan(plan)
+	if *out != "" {
+		data, _ := json.MarshalIndent(plan, "", "  ")
+		if err := os.WriteFile(*out, append(data, '\n'), 0644); err != nil {
+			log.Fatalf("%s Failed to write plan: %v", shortname, err)
+		}
+		log.Printf("%s Plan written to %s; edit it and run with -plan %s -apply", shortname, *out, *out)
+	}
+	if !*apply {
+		return
+	}
+
+	result, err := services.ApplyImportPlan(plan, services.ImportOptions{Mode: *mode, Thumbnails: *thumbs})
+	if err != nil {
+		log.Fatalf("%s Import failed: %v", shortname, err)
+	}
+	log.Printf("%s Imported into %d projects: %d new photos, %d files added to existing photos, %d duplicates skipped",
+		shortname, len(result.Projects), result.Created, result.Merged, result.Duplicates)
+	for _, failure := range result.Failed {
+		fmt.Println("  failed:", failure)
+	}
+}
+
+// printImportPlan prints the folder → project mapping as a table
+func printImportPlan(plan *services.ImportPlan) {
+	fmt.Printf("Archive: %s\n\n", plan.Root)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FOLDER\tPROJECT\tPHOTOS\tJPEG+RAW\tRAW ONLY\tNOTES")
+	for _, folder := range plan.Folders {
+		project := folder.Project
+		if folder.Skip {
+			project = "(skipped)"
+		} else if folder.Existing {
+			project += " (existing)"
+		}
+		notes := ""
+		if len(folder.Conflicts) > 0 {
+			notes += fmt.Sprintf("%d conflicts ", len(folder.Conflicts))
+		}
+		if len(folder.Invalid) > 0 {
+			notes += fmt.Sprintf("%d invalid names ", len(folder.Invalid))
+		}
+		if len(folder.Ignored) > 0 {
+			notes += fmt.Sprintf("%d other files", len(folder.Ignored))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", folder.Folder, project, folder.Photos, folder.Pairs, folder.RawOnly, notes)
+	}
+	w.Flush()
+}
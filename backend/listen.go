@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"photobridge/config"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket activation
+const listenFDsStart = 3
+
+// serve answers HTTP on every configured listener and returns when one of them fails
+func serve(handler http.Handler) error {
+	addrs, err := config.AppConfig.ListenAddresses()
+	if err != nil {
+		return fmt.Errorf("invalid LISTEN: %w", err)
+	}
+	listeners, err := openListeners(addrs)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: handler}
+	errs := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		log.Printf("%s Listening on %s", shortname, describeListener(ln))
+		go func(ln net.Listener) {
+			errs <- server.Serve(ln)
+		}(ln)
+	}
+	return <-errs
+}
+
+// openListeners opens the listeners of addrs. A unix socket left behind by a previous
+// run is replaced; the socket is made group-writable for a reverse proxy.
+func openListeners(addrs []config.ListenAddress) ([]net.Listener, error) {
+	var listeners []net.Listener
+	fail := func(err error) ([]net.Listener, error) {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		switch addr.Network {
+		case config.ListenSystemd:
+			activated, err := activatedListeners()
+			if err != nil {
+				return fail(err)
+			}
+			listeners = append(listeners, activated...)
+		case "unix":
+			if info, err := os.Lstat(addr.Address); err == nil && info.Mode()&os.ModeSocket != 0 {
+				os.Remove(addr.Address)
+			}
+			ln, err := net.Listen("unix", addr.Address)
+			if err != nil {
+				return fail(fmt.Errorf("cannot listen on %s: %w", addr, err))
+			}
+			if err := os.Chmod(addr.Address, 0660); err != nil {
+				log.Printf("%s Failed to set permissions of %s: %v", shortname, addr.Address, err)
+			}
+			listeners = append(listeners, ln)
+		default:
+			ln, err := net.Listen(addr.Network, addr.Address)
+			if err != nil {
+				return fail(fmt.Errorf("cannot listen on %s: %w", addr, err))
+			}
+			listeners = append(listeners, ln)
+		}
+	}
+	return listeners, nil
+}
+
+// activatedListeners takes over the sockets systemd passed (LISTEN_PID, LISTEN_FDS).
+// The variables are cleared so processes started later (RAW converters) don't see them.
+func activatedListeners() ([]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != os.Getpid() {
+		return nil, errors.New("LISTEN=systemd but no sockets were passed (LISTEN_PID)")
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, errors.New("LISTEN=systemd but no sockets were passed (LISTEN_FDS)")
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]net.Listener, 0, count)
+	for i := 0; i < count; i++ {
+		name := "systemd-socket-" + strconv.Itoa(i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(listenFDsStart+i), name)
+		ln, err := net.FileListener(file)
+		file.Close() // FileListener works on a duplicate
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket %s passed by systemd: %w", name, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// describeListener names a listener for the log, with a URL for TCP ones
+func describeListener(ln net.Listener) string {
+	addr := ln.Addr()
+	if addr.Network() != "tcp" {
+		return addr.Network() + ":" + addr.String()
+	}
+	host, port, _ := net.SplitHostPort(addr.String())
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() && ip.To4() != nil {
+		return fmt.Sprintf("http://0.0.0.0:%s (all IPv4 interfaces)", port)
+	}
+	return "http://" + addr.String()
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"time"
 
 	"photobridge/config"
@@ -12,8 +14,9 @@ import (
 	"photobridge/handlers"
 	"photobridge/middleware"
 	"photobridge/services"
+	"photobridge/storage"
+	"photobridge/utils"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
@@ -28,92 +31,168 @@ func main() {
 	// Load configuration
 	config.Load()
 
-	// Initialize database
-	database.Init()
+	// "photobridge check-config" prints a validation report and exits
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		runConfigCheck()
+		return
+	}
+
+	// "photobridge hash-password" prints the bcrypt hash of a password for ADMIN_PASSWORD
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		runHashPassword()
+		return
+	}
+
+	// Never run a production server with the well-known default credentials
+	if config.IsProduction() {
+		if insecure := config.AppConfig.InsecureSecrets(); len(insecure) > 0 {
+			log.Fatalf("%s Refusing to start in production with default %v; set them in the environment", shortname, insecure)
+		}
+	}
+
+	// A malformed origin would silently lock out (or let in) cross-origin clients
+	if err := config.AppConfig.ValidateCORS(); err != nil {
+		log.Fatalf("%s Invalid CORS configuration: %v", shortname, err)
+	}
+
+	// Keep the Go heap within MEMORY_LIMIT_MB (GOMEMLIMIT takes precedence)
+	if limit := config.AppConfig.GoMemoryLimit(); limit > 0 {
+		debug.SetMemoryLimit(limit)
+		log.Printf("%s Go memory limit set to %d MB", shortname, limit>>20)
+	}
+
+	// Admin token signing keys (HS256 with JWT_SECRET unless RS256/EdDSA is configured)
+	if err := utils.InitJWTKeys(); err != nil {
+		log.Fatalf("%s Failed to load JWT keys: %v", shortname, err)
+	}
 
-	// Initialize thumbnail generation queue
-	// Workers and timeout are configurable via environment variables.
-	// Queue is unbounded - tasks only store file paths, not image data
-	services.InitQueue(
-		config.AppConfig.ThumbWorkers,
-		time.Duration(config.AppConfig.ThumbJobTimeoutSec)*time.Second,
-	)
+	// Storage for original files (UPLOAD_DIR or an S3 bucket)
+	if err := storage.Init(); err != nil {
+		log.Fatalf("%s Failed to initialize storage: %v", shortname, err)
+	}
+
+	// Mirror logs to a rotating file if configured
+	var logOutput io.Writer = os.Stdout
+	if config.AppConfig.LogFile != "" {
+		logFile, err := utils.NewRotatingFile(
+			config.AppConfig.LogFile,
+			config.AppConfig.LogMaxSizeMB,
+			config.AppConfig.LogMaxBackups,
+			config.AppConfig.LogMaxAgeDays,
+			time.Duration(config.AppConfig.LogRotateHours)*time.Hour,
+		)
+		if err != nil {
+			log.Fatalf("%s Failed to open log file %s: %v", shortname, config.AppConfig.LogFile, err)
+		}
+		defer logFile.Close()
+
+		logOutput = io.MultiWriter(os.Stdout, logFile)
+		gin.DefaultWriter = logOutput
+		gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, logFile)
+	}
+
+	// Structured logs (LOG_FORMAT, LOG_LEVEL) for requests and log.Printf lines alike
+	if err := utils.SetupLogging(logOutput, config.AppConfig.LogFormat, config.AppConfig.LogLevel); err != nil {
+		log.Fatalf("%s Invalid logging configuration: %v", shortname, err)
+	}
+	if config.AppConfig.LogFile != "" {
+		log.Printf("%s Logging to %s (max %dMB, %d backups, %d days)", shortname,
+			config.AppConfig.LogFile, config.AppConfig.LogMaxSizeMB, config.AppConfig.LogMaxBackups, config.AppConfig.LogMaxAgeDays)
+	}
+
+	// Load local GeoIP database (fallback when CF-IPCountry is absent)
+	if err := utils.InitGeoIP(config.AppConfig.GeoIPDatabasePath); err != nil {
+		log.Printf("%s Warning: Failed to load GeoIP database %s: %v", shortname, config.AppConfig.GeoIPDatabasePath, err)
+	}
+
+	// "photobridge seed" fills a development database with sample data and exits
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		database.Init()
+		runSeed(os.Args[2:])
+		return
+	}
+
+	// "photobridge import <dir>" proposes (or, with -apply, performs) an archive import and exits
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		database.Init()
+		runImport(os.Args[2:])
+		return
+	}
+
+	// "photobridge migrate-thumbs" moves thumbnail blobs out of the database and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate-thumbs" {
+		database.Init()
+		runMigrateThumbs(os.Args[2:])
+		return
+	}
+
+	// "photobridge regenerate-thumbs" regenerates the thumbnails of one or every project and exits
+	if len(os.Args) > 1 && os.Args[1] == "regenerate-thumbs" {
+		database.Init()
+		runRegenerateThumbs(os.Args[2:])
+		return
+	}
+
+	// "photobridge reset-2fa" turns two-factor authentication off and exits
+	if len(os.Args) > 1 && os.Args[1] == "reset-2fa" {
+		database.Init()
+		runResetTwoFactor()
+		return
+	}
+
+	// Migrations, self-tests and background services run while the server answers
+	// /api/health (liveness) and /api/ready (readiness); other requests get 503 until done
+	go initialize()
 
 	// Create Gin router with custom middleware
 	r := gin.New()
 	r.Use(gin.Recovery())      // Recover from panics
 	r.Use(middleware.Logger()) // Custom logger with real IP and health check filtering
+	r.Use(middleware.RequireStartup(services.Startup, "/api/health", "/api/ready"))
 
 	// Set max memory for multipart forms to 8MB
 	// Files larger than this will be stored in temp files on disk
 	// This prevents large uploads from consuming too much RAM
 	r.MaxMultipartMemory = 8 << 20 // 8 MB
 
-	// Configure CORS
-	// In production (Docker), restrict CORS to prevent unauthorized access
-	// In development, allow all origins for convenience
-	var corsConfig cors.Config
-	if os.Getenv("ENV") == "production" || os.Getenv("DOCKER") == "true" {
-		// Production: Use specific origins if provided, otherwise allow all requests
-		if allowedOrigins := os.Getenv("CORS_ALLOWED_ORIGINS"); allowedOrigins != "" {
-			corsConfig = cors.Config{
-				AllowOrigins:     []string{allowedOrigins},
-				AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-				AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
-				ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
-				AllowCredentials: true,
-			}
-			log.Printf("%s CORS restricted to: %v", shortname, []string{allowedOrigins})
-		} else {
-			// Fallback: Allow any origin (frontend and backend are typically on same domain)
-			corsConfig = cors.Config{
-				AllowOriginFunc: func(origin string) bool {
-					return true // Allow all origins
-				},
-				AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-				AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
-				ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
-				AllowCredentials: true,
-			}
-			log.Printf("%s CORS allowing all origins (no CORS_ALLOWED_ORIGINS set)", shortname)
-		}
-	} else {
-		// Development: Allow all origins
-		corsConfig = cors.Config{
-			AllowOrigins:     []string{"*"},
-			AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key"},
-			ExposeHeaders:    []string{"Content-Length", "Content-Disposition"},
-			AllowCredentials: true,
-		}
-		log.Printf("%s CORS allowing all origins (development mode)", shortname)
+	// Configure CORS per route group: /api/admin is stricter than share routes.
+	// Without CORS_ALLOWED_ORIGINS, development allows all origins and production only
+	// same-origin requests and CDN mirrors.
+	for _, routes := range []string{config.CORSRoutesAdmin, config.CORSRoutesShare, config.CORSRoutesDefault} {
+		log.Printf("%s CORS %s routes: %s", shortname, routes, config.DescribeOrigins(config.AppConfig.CORSOriginsFor(routes)))
 	}
+	r.Use(middleware.CORS())
 
-	r.Use(cors.New(corsConfig))
-
-	// Serve uploaded files
-	r.Static("/uploads", config.AppConfig.UploadDir)
+	// Serve uploaded files (with optional hotlink protection and signed URLs)
+	uploads := r.Group("/uploads")
+	uploads.Use(middleware.HotlinkProtection(), middleware.RequireSignedUpload())
+	uploads.GET("/*filepath", handlers.ServeUpload)
+	uploads.HEAD("/*filepath", handlers.ServeUpload)
 
 	// Serve frontend static files (must be before wildcard routes)
 	frontendDir := "./frontend/dist"
 	if _, err := os.Stat(frontendDir); err == nil {
 		r.Static("/assets", filepath.Join(frontendDir, "assets"))
 		r.StaticFile("/vite.svg", filepath.Join(frontendDir, "vite.svg"))
+		// Share short URLs get Open Graph tags for link previews
+		r.GET("/s/:token", handlers.ShareOGPage(filepath.Join(frontendDir, "index.html")))
 	}
 
-	// Robots.txt - Block all crawlers
+	// Public keys of admin tokens (RS256/EdDSA) for services verifying them
+	r.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
+	// Robots.txt - Block all crawlers, except link preview bots reading share cards
 	r.GET("/robots.txt", func(c *gin.Context) {
 		c.Header("Content-Type", "text/plain; charset=utf-8")
-		c.String(http.StatusOK, "User-agent: *\nDisallow: /\n")
+		c.String(http.StatusOK, "User-agent: Twitterbot\nUser-agent: facebookexternalhit\nAllow: /s/\nAllow: /api/share/*/card.jpg\nDisallow: /\n\nUser-agent: *\nDisallow: /\n")
 	})
 
 	// API routes
 	api := r.Group("/api")
 	{
-		// Health check
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"status": "ok"})
-		})
+		// Liveness and readiness probes
+		api.GET("/health", handlers.Health)
+		api.GET("/ready", handlers.Ready)
 
 		// Turnstile verification endpoint (public)
 		api.POST("/verify", middleware.VerifyTurnstileHandler)
@@ -128,52 +207,146 @@ func main() {
 
 		// Public auth
 		api.POST("/admin/login", handlers.Login)
+		api.POST("/admin/refresh", handlers.RefreshAdminToken)
 
 		// Admin routes (require JWT)
 		admin := api.Group("/admin")
 		admin.Use(middleware.JWTAuth())
 		{
+			// Sessions (signed-in devices)
+			admin.GET("/sessions", handlers.GetAdminSessions)
+			admin.DELETE("/sessions/:id", handlers.RevokeAdminSession)
+			admin.GET("/security/login-audit", handlers.GetLoginAudit)
+			admin.GET("/2fa", handlers.GetTwoFactorStatus)
+			admin.POST("/2fa/setup", handlers.SetUpTwoFactor)
+			admin.POST("/2fa/enable", handlers.EnableTwoFactor)
+			admin.POST("/2fa/disable", handlers.DisableTwoFactor)
+			admin.POST("/2fa/backup-codes", handlers.RegenerateBackupCodes)
+			admin.GET("/apikeys", handlers.GetAPIKeys)
+			admin.POST("/apikeys", handlers.CreateAPIKey)
+			admin.PUT("/apikeys/:id", handlers.UpdateAPIKey)
+			admin.DELETE("/apikeys/:id", handlers.DeleteAPIKey)
+			admin.POST("/logout", handlers.Logout)
+
 			// Projects
 			admin.GET("/projects", handlers.GetProjects)
 			admin.POST("/projects", handlers.CreateProject)
 			admin.GET("/projects/:id", handlers.GetProject)
 			admin.PUT("/projects/:id", handlers.UpdateProject)
 			admin.DELETE("/projects/:id", handlers.DeleteProject)
+			admin.GET("/projects/:id/usage", handlers.GetProjectUsage)
+			admin.GET("/projects/:id/events", handlers.GetProjectEvents)
 
 			// Photos
 			admin.POST("/projects/:id/photos", handlers.UploadPhotos)
 			admin.GET("/projects/:id/photos", handlers.GetProjectPhotos)
 			admin.POST("/projects/:id/photos/check-hashes", handlers.CheckHashes)
+			admin.POST("/projects/:id/photos/chunks", handlers.CreateUploadSession)
+			admin.GET("/projects/:id/photos/chunks/:upload", handlers.GetUploadSession)
+			admin.PATCH("/projects/:id/photos/chunks/:upload", handlers.AppendUploadChunk)
+			admin.POST("/projects/:id/photos/chunks/:upload/complete", handlers.CompleteUploadSession)
+			admin.DELETE("/projects/:id/photos/chunks/:upload", handlers.CancelUploadSession)
+			admin.POST("/projects/:id/photos/capture-time", handlers.ShiftCaptureTimes)
+			admin.POST("/projects/:id/photos/metadata", handlers.ImportPhotoMetadata)
+			admin.POST("/projects/:id/photos/album", handlers.AssignPhotoAlbum)
+			admin.GET("/projects/:id/albums", handlers.GetAlbums)
+			admin.POST("/projects/:id/albums", handlers.CreateAlbum)
+			admin.PUT("/albums/:id", handlers.UpdateAlbum)
+			admin.DELETE("/albums/:id", handlers.DeleteAlbum)
+			admin.GET("/projects/:id/contact-sheet", handlers.GetContactSheet)
+			admin.POST("/projects/:id/regenerate-thumbnails", handlers.RegenerateProjectThumbnails)
+			admin.GET("/thumbqueue", handlers.GetThumbQueue)
+			admin.POST("/thumbqueue/retry-failed", handlers.RetryFailedThumbnails)
 			admin.DELETE("/photos/:id", handlers.DeletePhoto)
+			admin.POST("/photos/bulk-delete", handlers.BulkDeletePhotos)
+			admin.POST("/photos/bulk-move", handlers.BulkMovePhotos)
+			admin.POST("/photos/bulk-tag", handlers.TagPhotos)
+			admin.POST("/photos/bulk-untag", handlers.UntagPhotos)
+			admin.GET("/photos/search", handlers.SearchPhotos)
+			admin.GET("/tags", handlers.GetTags)
+			admin.GET("/photos/:id", handlers.GetPhotoDetail)
 			admin.GET("/photos/:id/exif", handlers.GetAdminPhotoExif)
 			admin.GET("/photos/:id/files", handlers.GetPhotoFiles)
+			admin.GET("/photos/:id/access-log", handlers.GetPhotoAccessLog)
 			admin.GET("/photos/:id/thumb/small", handlers.GetPhotoThumbSmall)
 			admin.GET("/photos/:id/thumb/large", handlers.GetPhotoThumbLarge)
 
+			// Trash
+			admin.GET("/trash", handlers.GetTrash)
+			admin.POST("/trash/:id/restore", handlers.RestoreTrashItem)
+			admin.DELETE("/trash/:id", handlers.PurgeTrashItem)
+
+			// Library (cross-project)
+			admin.GET("/timeline", handlers.GetTimeline)
+			admin.GET("/photos/recent", handlers.GetRecentPhotos)
+			admin.GET("/duplicates", handlers.GetDuplicates)
+			admin.GET("/storage", handlers.GetStorageStats)
+			admin.GET("/digest", handlers.GetDigest)
+			admin.POST("/digest/send", handlers.SendDigest)
+			admin.POST("/duplicates/resolve", handlers.ResolveDuplicates)
+			admin.GET("/integrity/missing", handlers.GetMissingFiles)
+			admin.POST("/integrity/repair", handlers.RepairMissingFiles)
+			admin.POST("/projects/:id/scan", handlers.ScanProject)
+
 			// Share links
 			admin.GET("/projects/:id/links", handlers.GetShareLinks)
 			admin.POST("/projects/:id/links", handlers.CreateShareLink)
 			admin.PUT("/links/:id", handlers.UpdateShareLink)
+			admin.PATCH("/links/:id/exclusions", handlers.PatchShareLinkExclusions)
+			admin.GET("/links/:id/photos", handlers.GetLinkPhotos)
+			admin.PATCH("/links/:id/photos/:photoId", handlers.SetLinkPhotoIncluded)
+			admin.POST("/links/:id/password/regenerate", handlers.RegenerateSharePassword)
+			admin.POST("/links/:id/clone", handlers.CloneShareLink)
+			admin.POST("/links/:id/access-token", handlers.CreateShareAccessToken)
+			admin.GET("/links/:id/selections", handlers.GetLinkSelections)
+			admin.POST("/links/:id/email", handlers.SendGalleryEmail)
+			admin.GET("/links/:id/qrcode", handlers.GetShareLinkQRCode)
+			admin.POST("/links/:id/qrcode", handlers.GetShareLinkQRCode)
+			admin.GET("/links/:id/stats", handlers.GetLinkStats)
 			admin.DELETE("/links/:id", handlers.DeleteShareLink)
+			admin.GET("/comments", handlers.GetComments)
+			admin.PATCH("/comments/:id", handlers.ModerateComment)
+			admin.DELETE("/comments/:id", handlers.DeleteComment)
 		}
 
-		// API routes (require API Key)
+		// API routes (require API Key; keys limited to a project only reach its routes)
 		apiKey := api.Group("")
 		apiKey.Use(middleware.APIKeyAuth())
 		{
 			// Upload
-			apiKey.POST("/upload/:project", handlers.UploadViaAPI)
+			upload := apiKey.Group("/upload/:project", middleware.RequireAPIKeyUpload())
+			upload.POST("", handlers.UploadViaAPI)
+			upload.POST("/chunks", handlers.CreateUploadSession)
+			upload.GET("/chunks/:upload", handlers.GetUploadSession)
+			upload.PATCH("/chunks/:upload", handlers.AppendUploadChunk)
+			upload.POST("/chunks/:upload/complete", handlers.CompleteUploadSession)
+			upload.DELETE("/chunks/:upload", handlers.CancelUploadSession)
 			// Projects
 			apiKey.GET("/projects", handlers.GetProjectsViaAPI)
-			apiKey.POST("/projects", handlers.CreateProjectViaAPI)
-			apiKey.DELETE("/projects/:project", handlers.DeleteProjectViaAPI)
+			apiKey.POST("/projects", middleware.RequireAPIKeyUpload(), middleware.RequireUnscopedAPIKey(), handlers.CreateProjectViaAPI)
+			apiKey.DELETE("/projects/:project", middleware.RequireAPIKeyUpload(), middleware.RequireUnscopedAPIKey(), handlers.DeleteProjectViaAPI)
 			apiKey.GET("/projects/:project/photos", handlers.GetProjectPhotosViaAPI)
+
+			// Read API for external gallery frontends (read-only, any key)
+			v1 := apiKey.Group("/v1")
+			v1.GET("/projects", handlers.GetReadProjects)
+			v1.GET("/projects/:project", handlers.GetReadProject)
+			v1.GET("/projects/:project/photos", handlers.GetReadProjectPhotos)
+			v1.GET("/photos/:photoId", handlers.GetReadPhoto)
 		}
 
+		// Image proxy: thumbnails, previews and originals by photo ID, for the admin
+		// panel (JWT), API keys or through a share link (?share=<token>)
+		api.GET("/image/:photoId", append(middleware.ImageAuth(), handlers.GetImage)...)
+
+		// Share card image (public so link previews can fetch it)
+		api.GET("/share/:token/card.jpg", handlers.GetShareCard)
+
 		// Share routes (public, with Turnstile verification)
 		// API routes: /api/share/:token for programmatic access
 		// Frontend uses /s/:token for short URLs (handled by SPA router)
 		share := api.Group("/share")
+		share.Use(middleware.ShareAccessToken()) // Bearer tokens for embedded viewers skip the checks below
 		share.Use(middleware.RequireTurnstile()) // Require verification for first-time visitors
 		{
 			// Password verification endpoint (does not require password middleware)
@@ -185,12 +358,29 @@ func main() {
 			{
 				shareProtected.GET("/:token", handlers.GetShareInfo)
 				shareProtected.GET("/:token/photos", handlers.GetSharePhotos)
-				shareProtected.GET("/:token/photo/:photoId", handlers.GetSharePhoto)
-				shareProtected.GET("/:token/photo/:photoId/exif", handlers.GetPhotoExif)
-				shareProtected.GET("/:token/photo/:photoId/download", handlers.DownloadSinglePhoto)
-				shareProtected.GET("/:token/photo/:photoId/thumb/small", handlers.GetSharePhotoThumbSmall)
-				shareProtected.GET("/:token/photo/:photoId/thumb/large", handlers.GetSharePhotoThumbLarge)
 				shareProtected.GET("/:token/download", handlers.DownloadSharePhotos)
+				shareProtected.GET("/:token/download/parts", handlers.GetShareDownloadParts)
+				shareProtected.POST("/:token/download-jobs", handlers.CreateShareDownloadJob)
+				shareProtected.GET("/:token/download-jobs/:jobId", handlers.GetShareDownloadJob)
+				shareProtected.GET("/:token/download-jobs/:jobId/file", handlers.DownloadShareJobFile)
+				shareProtected.POST("/:token/download", handlers.DownloadShareSelection)
+				shareProtected.GET("/:token/slideshow", handlers.GetShareSlideshow)
+				shareProtected.GET("/:token/selections", handlers.GetShareSelections)
+				shareProtected.POST("/:token/selections", handlers.UpdateShareSelections)
+				shareProtected.POST("/:token/selections/submit", handlers.SubmitShareSelections)
+
+				// Single-photo routes: the photo must belong to the link's project and be visible through it
+				sharePhoto := shareProtected.Group("/:token/photo/:photoId")
+				sharePhoto.Use(middleware.RequireSharePhoto())
+				{
+					sharePhoto.GET("", handlers.GetSharePhoto)
+					sharePhoto.GET("/exif", handlers.GetPhotoExif)
+					sharePhoto.GET("/download", handlers.DownloadSinglePhoto)
+					sharePhoto.GET("/thumb/small", handlers.GetSharePhotoThumbSmall)
+					sharePhoto.GET("/thumb/large", handlers.GetSharePhotoThumbLarge)
+					sharePhoto.GET("/comments", handlers.GetSharePhotoComments)
+					sharePhoto.POST("/comments", handlers.PostSharePhotoComment)
+				}
 			}
 		}
 	}
@@ -202,11 +392,8 @@ func main() {
 		})
 	}
 
-	// Start server
-	log.Printf("%s Server starting on 0.0.0.0:%s (all interfaces)", shortname, config.AppConfig.Port)
-	log.Printf("%s Local access: http://localhost:%s", shortname, config.AppConfig.Port)
-	log.Printf("%s Network access: http://<your-ip>:%s", shortname, config.AppConfig.Port)
-	if err := r.Run(":" + config.AppConfig.Port); err != nil {
+	// Start server on LISTEN, or every interface on PORT
+	if err := serve(r); err != nil {
 		log.Fatalf("%s Failed to start server: %v", shortname, err)
 	}
 }
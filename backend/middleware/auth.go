@@ -1,16 +1,26 @@
 package middleware
 
 import (
-	"fmt"
+	"crypto/subtle"
 	"net/http"
 	"strings"
+	"time"
 
-	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	adminSessionKey  = "admin_session_id"
+	apiKeyContextKey = "api_key"
+	// sessionTouchInterval limits how often a session's last-seen time is written
+	sessionTouchInterval = time.Minute
+)
+
 type Claims struct {
 	Username string `json:"username"`
 	jwt.RegisteredClaims
@@ -32,15 +42,10 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		// Only the configured algorithm is accepted, which prevents algorithm
+		// confusion attacks (e.g., RS256 -> HS256)
 		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			// Verify that the signing method is HMAC (HS256/HS384/HS512)
-			// This prevents algorithm confusion attacks (e.g., RS256 -> HS256)
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(config.AppConfig.JWTSecret), nil
-		})
+		token, err := utils.AdminJWTKeys().Parse(tokenString, claims)
 
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -48,22 +53,147 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		// The token is only good while its session exists (not revoked or signed out)
+		session, ok := activeAdminSession(claims.ID)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+			c.Abort()
+			return
+		}
+		touchAdminSession(session, c)
+
 		c.Set("username", claims.Username)
+		c.Set(adminSessionKey, session.ID)
 		c.Next()
 	}
 }
 
+// AdminSessionID returns the session of the request authorized by JWTAuth
+func AdminSessionID(c *gin.Context) uint {
+	return c.GetUint(adminSessionKey)
+}
+
+// activeAdminSession looks up the unexpired session of a token ID
+func activeAdminSession(jti string) (*models.AdminSession, bool) {
+	if jti == "" {
+		return nil, false // Tokens issued before sessions were tracked
+	}
+	var session models.AdminSession
+	if err := database.DB.Where("jti = ? AND expires_at > ?", jti, time.Now()).First(&session).Error; err != nil {
+		return nil, false
+	}
+	return &session, true
+}
+
+// touchAdminSession records the last activity of a session, at most once per sessionTouchInterval
+func touchAdminSession(session *models.AdminSession, c *gin.Context) {
+	if time.Since(session.LastSeenAt) < sessionTouchInterval && session.IP == c.ClientIP() {
+		return
+	}
+	database.DB.Model(session).Updates(map[string]interface{}{
+		"last_seen_at": time.Now(),
+		"ip":           c.ClientIP(),
+	})
+}
+
+// APIKeyAuth accepts the API keys stored in the database (X-API-Key). A key limited
+// to one project is refused on the routes of other projects (the :project parameter);
+// routes without one check the scope themselves (see CurrentAPIKey).
 func APIKeyAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Only accept API key from header to prevent logging/Referer leaks
-		apiKey := c.GetHeader("X-API-Key")
-
-		if apiKey == "" || apiKey != config.AppConfig.APIKey {
+		key, ok := lookupAPIKey(c.GetHeader("X-API-Key"))
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
 			c.Abort()
 			return
 		}
 
+		if name := c.Param("project"); name != "" && !apiKeyReachesProject(key, name) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is limited to another project"})
+			c.Abort()
+			return
+		}
+		touchAPIKey(key, c)
+
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// RequireAPIKeyUpload refuses read-only API keys
+func RequireAPIKeyUpload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := CurrentAPIKey(c); key == nil || !key.CanUpload() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is read-only"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireUnscopedAPIKey refuses API keys limited to one project, e.g. for creating projects
+func RequireUnscopedAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := CurrentAPIKey(c); key == nil || key.ProjectID != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is limited to one project"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
+
+// CurrentAPIKey returns the key of the request authorized by APIKeyAuth
+func CurrentAPIKey(c *gin.Context) *models.APIKey {
+	key, _ := c.Get(apiKeyContextKey)
+	apiKey, _ := key.(*models.APIKey)
+	return apiKey
+}
+
+// lookupAPIKey finds a key by its prefix and compares the hashes in constant time
+func lookupAPIKey(key string) (*models.APIKey, bool) {
+	if key == "" {
+		return nil, false
+	}
+	var candidates []models.APIKey
+	if err := database.DB.Where("prefix = ?", utils.APIKeyPrefix(key)).Find(&candidates).Error; err != nil {
+		return nil, false
+	}
+	hash := []byte(utils.HashAPIKey(key))
+	for i := range candidates {
+		if subtle.ConstantTimeCompare(hash, []byte(candidates[i].KeyHash)) == 1 {
+			return &candidates[i], true
+		}
+	}
+	return nil, false
+}
+
+// apiKeyReachesProject reports whether a key may access the project of the given name
+func apiKeyReachesProject(key *models.APIKey, name string) bool {
+	if key.ProjectID == nil {
+		return true
+	}
+	sanitized, valid := utils.SanitizeProjectName(name)
+	if !valid {
+		return false
+	}
+	var project models.Project
+	if err := database.DB.Select("id").Where("name = ?", sanitized).First(&project).Error; err != nil {
+		return false // Scoped keys can't create projects by uploading
+	}
+	return project.ID == *key.ProjectID
+}
+
+// touchAPIKey records the last use of a key, at most once per sessionTouchInterval
+func touchAPIKey(key *models.APIKey, c *gin.Context) {
+	if key.LastUsedAt != nil && time.Since(*key.LastUsedAt) < sessionTouchInterval && key.LastUsedIP == c.ClientIP() {
+		return
+	}
+	now := time.Now()
+	database.DB.Model(key).UpdateColumns(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": c.ClientIP(),
+	})
+}
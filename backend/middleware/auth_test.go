@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signAdminToken signs an admin token for the session with the given JTI
+func signAdminToken(t *testing.T, jti string) string {
+	t.Helper()
+	token, err := utils.AdminJWTKeys().Sign(&Claims{
+		Username: "admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTAuthRequiresActiveSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	database.DB.AutoMigrate(&models.AdminSession{})
+	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+
+	stale := time.Now().Add(-time.Hour)
+	active := models.AdminSession{JTI: "active", Username: "admin", LastSeenAt: stale, ExpiresAt: time.Now().Add(time.Hour)}
+	expired := models.AdminSession{JTI: "expired", Username: "admin", LastSeenAt: stale, ExpiresAt: time.Now().Add(-time.Minute)}
+	database.DB.Create(&active)
+	database.DB.Create(&expired)
+
+	router := gin.New()
+	router.GET("/admin", JWTAuth(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"session": AdminSessionID(c)})
+	})
+	request := func(jti string) int {
+		req := httptest.NewRequest("GET", "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+signAdminToken(t, jti))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request("active"); code != http.StatusOK {
+		t.Errorf("Active session: expected 200, got %d", code)
+	}
+	var touched models.AdminSession
+	database.DB.First(&touched, active.ID)
+	if !touched.LastSeenAt.After(stale) {
+		t.Error("Last-seen time should be updated")
+	}
+
+	for _, jti := range []string{"expired", "unknown", ""} {
+		if code := request(jti); code != http.StatusUnauthorized {
+			t.Errorf("Session %q: expected 401, got %d", jti, code)
+		}
+	}
+
+	// Revoking the session invalidates its token
+	database.DB.Delete(&active)
+	if code := request("active"); code != http.StatusUnauthorized {
+		t.Errorf("Revoked session: expected 401, got %d", code)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	database.DB.AutoMigrate(&models.APIKey{})
+
+	wedding := models.Project{Name: "wedding"}
+	portraits := models.Project{Name: "portraits"}
+	database.DB.Create(&wedding)
+	database.DB.Create(&portraits)
+	keys := map[string]*models.APIKey{
+		"pb_fullaccess00000000": {Name: "full", Permission: models.APIKeyUpload},
+		"pb_readonly0000000000": {Name: "read", Permission: models.APIKeyRead},
+		"pb_wedding00000000000": {Name: "scoped", Permission: models.APIKeyUpload, ProjectID: &wedding.ID},
+	}
+	for secret, key := range keys {
+		key.Prefix = utils.APIKeyPrefix(secret)
+		key.KeyHash = utils.HashAPIKey(secret)
+		database.DB.Create(key)
+	}
+
+	router := gin.New()
+	api := router.Group("", APIKeyAuth())
+	api.GET("/projects/:project/photos", func(c *gin.Context) { c.Status(http.StatusOK) })
+	api.POST("/upload/:project", RequireAPIKeyUpload(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	api.POST("/projects", RequireAPIKeyUpload(), RequireUnscopedAPIKey(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := func(method, path, key string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	tests := []struct {
+		method, path, key string
+		expected          int
+	}{
+		{"GET", "/projects/wedding/photos", "", http.StatusUnauthorized},
+		{"GET", "/projects/wedding/photos", "pb_fullaccess00000000x", http.StatusUnauthorized},
+		{"GET", "/projects/wedding/photos", "pb_readonly0000000000", http.StatusOK},
+		{"POST", "/upload/wedding", "pb_readonly0000000000", http.StatusForbidden},
+		{"POST", "/upload/wedding", "pb_fullaccess00000000", http.StatusOK},
+		{"POST", "/upload/new-project", "pb_fullaccess00000000", http.StatusOK},
+		{"POST", "/upload/wedding", "pb_wedding00000000000", http.StatusOK},
+		{"GET", "/projects/portraits/photos", "pb_wedding00000000000", http.StatusForbidden},
+		{"POST", "/upload/new-project", "pb_wedding00000000000", http.StatusForbidden},
+		{"POST", "/projects", "pb_wedding00000000000", http.StatusForbidden},
+		{"POST", "/projects", "pb_fullaccess00000000", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code := request(tt.method, tt.path, tt.key); code != tt.expected {
+			t.Errorf("%s %s with %q: expected %d, got %d", tt.method, tt.path, tt.key, tt.expected, code)
+		}
+	}
+
+	var used models.APIKey
+	database.DB.First(&used, keys["pb_readonly0000000000"].ID)
+	if used.LastUsedAt == nil {
+		t.Error("Last use should be recorded")
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/url"
+	"strings"
+
+	"photobridge/config"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS applies the policy of the route group a request belongs to (see
+// config.CORSOriginsFor). It must be installed on the router rather than on route
+// groups: preflight requests match no route, so only global middleware sees them.
+func CORS() gin.HandlerFunc {
+	policies := map[string]gin.HandlerFunc{}
+	for _, routes := range []string{config.CORSRoutesDefault, config.CORSRoutesAdmin, config.CORSRoutesShare} {
+		policies[routes] = newCORSPolicy(config.AppConfig.CORSOriginsFor(routes))
+	}
+	return func(c *gin.Context) {
+		policies[corsRoutes(c.Request.URL)](c)
+	}
+}
+
+// corsRoutes returns the route group of a request URL. Image proxy requests belong to
+// the share routes when they go through a share link and to the admin routes otherwise.
+func corsRoutes(u *url.URL) string {
+	path := u.Path
+	switch {
+	case hasPathPrefix(path, "/api/image") && u.Query().Get("share") != "":
+		return config.CORSRoutesShare
+	case hasPathPrefix(path, "/api/admin"), hasPathPrefix(path, "/api/image"):
+		return config.CORSRoutesAdmin
+	case hasPathPrefix(path, "/api/share"), hasPathPrefix(path, "/uploads"), hasPathPrefix(path, "/s"):
+		return config.CORSRoutesShare
+	default:
+		return config.CORSRoutesDefault
+	}
+}
+
+// hasPathPrefix reports whether path is prefix or below it
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// newCORSPolicy builds the CORS handler for a list of allowed origins; an empty list
+// only allows same-origin requests
+func newCORSPolicy(origins []string) gin.HandlerFunc {
+	corsConfig := cors.Config{
+		AllowOrigins:     origins,
+		AllowWildcard:    true,
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "X-Total-Count", "X-Missing-Thumbnails", "X-Estimated-Size", "ETag"},
+		AllowCredentials: true,
+	}
+	if len(origins) == 0 {
+		corsConfig.AllowOriginFunc = func(string) bool { return false }
+	}
+	return cors.New(corsConfig)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"photobridge/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ENV", "production")
+
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	config.AppConfig = &config.Config{
+		CORSOrigins:      []string{"https://studio.example.com", "https://*.example.com"},
+		CORSShareOrigins: []string{"https://blog.example.org"},
+	}
+
+	r := gin.New()
+	r.Use(CORS())
+	r.GET("/api/admin/projects", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/share/:token", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/projects", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/image/:photoId", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		origin   string
+		expected int
+	}{
+		{"exact origin on admin", http.MethodGet, "/api/admin/projects", "https://studio.example.com", http.StatusOK},
+		{"wildcard origin not inherited by admin", http.MethodGet, "/api/admin/projects", "https://app.example.com", http.StatusForbidden},
+		{"admin preflight uses admin policy", http.MethodOptions, "/api/admin/projects", "https://app.example.com", http.StatusForbidden},
+		{"wildcard origin on share", http.MethodGet, "/api/share/abc", "https://app.example.com", http.StatusOK},
+		{"share-only origin on share", http.MethodGet, "/api/share/abc", "https://blog.example.org", http.StatusOK},
+		{"share-only origin elsewhere", http.MethodGet, "/api/projects", "https://blog.example.org", http.StatusForbidden},
+		{"share-only origin on shared image", http.MethodGet, "/api/image/1?share=abc", "https://blog.example.org", http.StatusOK},
+		{"wildcard origin on admin image", http.MethodGet, "/api/image/1", "https://app.example.com", http.StatusForbidden},
+		{"share preflight", http.MethodOptions, "/api/share/abc", "https://blog.example.org", http.StatusNoContent},
+		{"same origin", http.MethodGet, "/api/admin/projects", "http://example.com", http.StatusOK},
+		{"no origin", http.MethodGet, "/api/admin/projects", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, w.Code)
+			}
+			if w.Code == http.StatusOK && tt.origin != "" && tt.origin != "http://example.com" &&
+				w.Header().Get("Access-Control-Allow-Origin") != tt.origin {
+				t.Errorf("Expected Access-Control-Allow-Origin %s, got %q", tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"photobridge/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HotlinkProtection rejects /uploads requests embedded from other sites.
+// The Referer (or Origin) must point at this server, a configured CDN mirror,
+// or a host in HOTLINK_ALLOWED_ORIGINS. Only active when HOTLINK_ALLOWED_ORIGINS is set.
+func HotlinkProtection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.AppConfig.HotlinkProtectionEnabled() {
+			c.Next()
+			return
+		}
+
+		// CDN edge servers fetch from origin on behalf of visitors
+		if config.AppConfig.IsCDNIP(GetRealIP(c)) {
+			c.Next()
+			return
+		}
+
+		source := c.GetHeader("Referer")
+		if source == "" {
+			source = c.GetHeader("Origin")
+		}
+		if source == "" {
+			if config.AppConfig.HotlinkAllowEmpty {
+				c.Next()
+				return
+			}
+			rejectHotlink(c)
+			return
+		}
+
+		sourceURL, err := url.Parse(source)
+		if err != nil || !isAllowedReferrer(sourceURL.Hostname(), c.Request.Host) {
+			rejectHotlink(c)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// isAllowedReferrer checks whether a referring hostname may embed files from requestHost
+func isAllowedReferrer(host, requestHost string) bool {
+	if host == "" {
+		return false
+	}
+	serving := requestHost
+	if h, _, err := net.SplitHostPort(requestHost); err == nil {
+		serving = h
+	}
+	if strings.EqualFold(host, serving) {
+		return true
+	}
+	return config.AppConfig.IsHotlinkAllowedHost(host)
+}
+
+func rejectHotlink(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "hotlink_forbidden"})
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"photobridge/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupHotlinkRouter() *gin.Engine {
+	r := gin.New()
+	uploads := r.Group("/uploads")
+	uploads.Use(HotlinkProtection())
+	uploads.GET("/*filepath", func(c *gin.Context) {
+		c.String(http.StatusOK, "image")
+	})
+	return r
+}
+
+func TestHotlinkProtection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	originalHosts := config.AppConfig.HotlinkAllowedHosts
+	originalAllowEmpty := config.AppConfig.HotlinkAllowEmpty
+	originalCDN := config.AppConfig.CNCDNURL
+	defer func() {
+		config.AppConfig.HotlinkAllowedHosts = originalHosts
+		config.AppConfig.HotlinkAllowEmpty = originalAllowEmpty
+		config.AppConfig.CNCDNURL = originalCDN
+	}()
+
+	config.AppConfig.HotlinkAllowedHosts = []string{"studio.example.com", "*.partner.org"}
+	config.AppConfig.HotlinkAllowEmpty = true
+	config.AppConfig.CNCDNURL = "https://cdn.example.cn"
+
+	tests := []struct {
+		name     string
+		referer  string
+		origin   string
+		expected int
+	}{
+		{"No referer", "", "", http.StatusOK},
+		{"Same host", "http://photos.example.com:8060/share/abc", "", http.StatusOK},
+		{"Allowed host", "https://studio.example.com/gallery", "", http.StatusOK},
+		{"Allowed subdomain", "https://blog.partner.org/post", "", http.StatusOK},
+		{"CDN mirror", "https://cdn.example.cn/", "", http.StatusOK},
+		{"Foreign referer", "https://evil.example.net/page", "", http.StatusForbidden},
+		{"Foreign origin", "", "https://evil.example.net", http.StatusForbidden},
+		{"Invalid referer", "not a url", "", http.StatusForbidden},
+	}
+
+	r := setupHotlinkRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://photos.example.com:8060/uploads/1/a.jpg", nil)
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestHotlinkProtection_EmptyRefererDenied(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	originalHosts := config.AppConfig.HotlinkAllowedHosts
+	originalAllowEmpty := config.AppConfig.HotlinkAllowEmpty
+	defer func() {
+		config.AppConfig.HotlinkAllowedHosts = originalHosts
+		config.AppConfig.HotlinkAllowEmpty = originalAllowEmpty
+	}()
+
+	config.AppConfig.HotlinkAllowedHosts = []string{"studio.example.com"}
+	config.AppConfig.HotlinkAllowEmpty = false
+
+	r := setupHotlinkRouter()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/uploads/1/a.jpg", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without referer, got %d", w.Code)
+	}
+
+	// CDN edge fetches are always allowed
+	config.AppConfig.InitCDNIPSet()
+	config.AppConfig.AddCDNIP("203.0.113.7")
+	req := httptest.NewRequest("GET", "/uploads/1/a.jpg", nil)
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected CDN request to pass, got %d", w.Code)
+	}
+}
+
+func TestHotlinkProtection_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	originalHosts := config.AppConfig.HotlinkAllowedHosts
+	defer func() { config.AppConfig.HotlinkAllowedHosts = originalHosts }()
+	config.AppConfig.HotlinkAllowedHosts = nil
+
+	r := setupHotlinkRouter()
+	req := httptest.NewRequest("GET", "/uploads/1/a.jpg", nil)
+	req.Header.Set("Referer", "https://evil.example.net/")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected hotlinking allowed when not configured, got %d", w.Code)
+	}
+}
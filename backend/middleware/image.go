@@ -0,0 +1,60 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// ImageAuth authorizes /api/image/:photoId. With ?share=<token> the request passes the
+// checks of the single-photo share routes (access token, Turnstile, password, photo
+// visibility) and the link is available through SharePhoto; otherwise it needs the
+// admin JWT or an X-API-Key (the handler checks the scope of a project-limited key).
+func ImageAuth() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		imageShareToken,
+		shareImageOnly(ShareAccessToken()),
+		shareImageOnly(RequireTurnstile()),
+		shareImageOnly(RequireSharePassword()),
+		shareImageOnly(RequireSharePhoto()),
+		adminImageOnly(imageAdminAuth(JWTAuth(), APIKeyAuth())),
+	}
+}
+
+// imageShareToken exposes ?share= as the :token parameter the share middleware reads
+func imageShareToken(c *gin.Context) {
+	if token := c.Query("share"); token != "" {
+		c.Params = append(c.Params, gin.Param{Key: "token", Value: token})
+	}
+	c.Next()
+}
+
+// imageAdminAuth authorizes an image request without a share link by its API key when
+// it sends one, by the admin JWT otherwise
+func imageAdminAuth(jwtAuth, apiKeyAuth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "" {
+			apiKeyAuth(c)
+			return
+		}
+		jwtAuth(c)
+	}
+}
+
+// shareImageOnly runs a share middleware only for requests through a share link
+func shareImageOnly(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("token") == "" {
+			c.Next()
+			return
+		}
+		handler(c)
+	}
+}
+
+// adminImageOnly runs an admin middleware only for requests without a share link
+func adminImageOnly(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("token") != "" {
+			c.Next()
+			return
+		}
+		handler(c)
+	}
+}
@@ -1,59 +1,100 @@
 package middleware
 
 import (
-	"fmt"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"photobridge/config"
+	"photobridge/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// RequestIDHeader carries the ID of a request in both directions: a valid ID sent by
+// a proxy in front of PhotoBridge is kept, otherwise one is generated
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "request_id"
+
+// maxRequestIDLength limits the IDs accepted from clients and proxies
+const maxRequestIDLength = 64
+
+// redactedQueryKeys are query parameters carrying secrets, kept out of request logs
+var redactedQueryKeys = map[string]bool{"password": true, "token": true, "sig": true}
+
+// redactQuery replaces the values of redactedQueryKeys in a raw query, leaving the
+// rest as it was sent
+func redactQuery(raw string) string {
+	params := strings.Split(raw, "&")
+	for i, param := range params {
+		rawKey, _, hasValue := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if hasValue && redactedQueryKeys[strings.ToLower(key)] {
+			params[i] = rawKey + "=REDACTED"
+		}
+	}
+	return strings.Join(params, "&")
+}
+
 // GetRealIP extracts the real client IP from Cloudflare headers
 // Priority: CF-Connecting-IP > X-Real-IP > X-Forwarded-For > RemoteAddr
 func GetRealIP(c *gin.Context) string {
-	// Cloudflare passes the real IP in CF-Connecting-IP
-	if ip := c.GetHeader("CF-Connecting-IP"); ip != "" {
-		return ip
-	}
+	return utils.GetRealIP(c)
+}
 
-	// Fallback to X-Real-IP
-	if ip := c.GetHeader("X-Real-IP"); ip != "" {
-		return ip
-	}
+// RequestID returns the ID Logger assigned to the request, "" outside of it
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
 
-	// Fallback to X-Forwarded-For (take the first IP)
-	if ip := c.GetHeader("X-Forwarded-For"); ip != "" {
-		// X-Forwarded-For can be: "client, proxy1, proxy2"
-		// We want the first IP (the client)
-		for i := 0; i < len(ip); i++ {
-			if ip[i] == ',' || ip[i] == ' ' {
-				return ip[:i]
-			}
+// validRequestID accepts IDs of letters, digits, '-', '_' and '.', so a forwarded ID
+// can't inject anything into the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
 		}
-		return ip
 	}
+	return true
+}
 
-	// Fallback to RemoteAddr
-	return c.ClientIP()
+func newRequestID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
-// Logger is a custom logger middleware that:
-// 1. Shows real client IP from Cloudflare headers
-// 2. Skips logging for /api/health endpoint
-// 3. Adds Cloudflare debugging headers to response
+// Logger assigns every request an ID (returned in X-Request-ID) and logs it as a
+// structured line through slog (see utils.SetupLogging):
+// 1. Shows the real client IP from Cloudflare headers
+// 2. Adds the Cloudflare ray, colo, country and cache status, or marks CDN pulls
+// 3. Logs the /api/health and /api/ready probes at debug level only
+// 4. Logs 4xx responses as warnings and 5xx responses as errors
+// 5. Redacts passwords, tokens and signatures in the query
 func Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Skip logging for health check endpoint
-		if c.Request.URL.Path == "/api/health" {
-			c.Next()
-			return
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
 		}
+		c.Set(requestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
 
-		// Start timer
 		start := time.Now()
 		path := c.Request.URL.Path
 		raw := c.Request.URL.RawQuery
+		probe := path == "/api/health" || path == "/api/ready"
 
 		// Get real IP
 		realIP := GetRealIP(c)
@@ -65,7 +106,7 @@ func Logger() gin.HandlerFunc {
 		var cfRay, cfCountry, cfCacheStatus string
 		if !isFromCDN {
 			cfRay = c.GetHeader("CF-Ray")
-			cfCountry = c.GetHeader("CF-IPCountry")
+			cfCountry = utils.GetClientCountry(c)
 			cfCacheStatus = c.GetHeader("CF-Cache-Status")
 
 			// Add Cloudflare debugging headers to response (helpful for frontend debugging)
@@ -80,51 +121,55 @@ func Logger() gin.HandlerFunc {
 		// Process request
 		c.Next()
 
-		// Calculate latency
-		latency := time.Since(start)
-		statusCode := c.Writer.Status()
-		method := c.Request.Method
-
-		// Build log message
-		logMsg := fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %s",
-			start.Format("2006/01/02 - 15:04:05"),
-			statusCode,
-			latency,
-			realIP,
-			method,
-			path,
-		)
-
-		// Add query string if present
-		if raw != "" {
-			logMsg += "?" + raw
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case probe:
+			level = slog.LevelDebug
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		logger := slog.Default()
+		if !logger.Enabled(c.Request.Context(), level) {
+			return
 		}
 
-		// Add source info
+		attrs := []slog.Attr{
+			slog.String("request_id", requestID),
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("ip", realIP),
+		}
+		if raw != "" {
+			attrs = append(attrs, slog.String("query", redactQuery(raw)))
+		}
+		if ua := c.Request.UserAgent(); ua != "" {
+			attrs = append(attrs, slog.String("user_agent", ua))
+		}
 		if isFromCDN {
-			// Request from CDN - show CDN marker
-			logMsg += " | Source: CDN"
-		} else if cfRay != "" || cfCountry != "" || cfCacheStatus != "" {
-			// Request through Cloudflare - show CF info
-			cfInfo := ""
-			if cfCountry != "" {
-				cfInfo += fmt.Sprintf(" | Country: %s", cfCountry)
-			}
-			if cfRay != "" {
-				cfInfo += fmt.Sprintf(" | Ray: %s", cfRay)
-			}
-			if cfCacheStatus != "" {
-				cfInfo += fmt.Sprintf(" | Cache: %s", cfCacheStatus)
+			attrs = append(attrs, slog.String("source", "cdn"))
+		}
+		if cfCountry != "" {
+			attrs = append(attrs, slog.String("country", cfCountry))
+		}
+		if cfRay != "" {
+			attrs = append(attrs, slog.String("cf_ray", cfRay))
+			// The ray ends in the data center that handled the request, e.g. "8a1b2c3d4e5f6a7b-NRT"
+			if dash := strings.LastIndexByte(cfRay, '-'); dash > 0 {
+				attrs = append(attrs, slog.String("cf_colo", cfRay[dash+1:]))
 			}
-			logMsg += cfInfo
 		}
-
-		// Print log
-		fmt.Println(logMsg)
-
-		// Log errors if any
+		if cfCacheStatus != "" {
+			attrs = append(attrs, slog.String("cf_cache_status", cfCacheStatus))
+		}
 		if len(c.Errors) > 0 {
-			fmt.Println(c.Errors.String())
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
 		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
 	}
 }
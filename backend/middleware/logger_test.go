@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoggerRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	r := gin.New()
+	r.Use(Logger())
+	r.GET("/api/share/:token", func(c *gin.Context) {
+		c.String(http.StatusNotFound, RequestID(c))
+	})
+	r.GET("/api/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// A valid forwarded ID is kept and logged with the Cloudflare metadata
+	w := serve("/api/share/abc?page=2", http.Header{"X-Request-Id": {"edge-42"}, "Cf-Ray": {"8a1b2c3d4e5f-NRT"}})
+	if w.Header().Get(RequestIDHeader) != "edge-42" || w.Body.String() != "edge-42" {
+		t.Errorf("Expected the forwarded ID, got header %q body %q", w.Header().Get(RequestIDHeader), w.Body.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON line, got %s", buf.String())
+	}
+	want := map[string]interface{}{
+		"level": "WARN", "msg": "request", "request_id": "edge-42", "path": "/api/share/abc", "query": "page=2",
+		"status": float64(404), "cf_ray": "8a1b2c3d4e5f-NRT", "cf_colo": "NRT",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+
+	// Invalid IDs are replaced
+	buf.Reset()
+	w = serve("/api/share/abc", http.Header{"X-Request-Id": {"bad id\n"}})
+	if id := w.Header().Get(RequestIDHeader); id == "" || strings.ContainsAny(id, " \n") {
+		t.Errorf("Expected a generated ID, got %q", id)
+	}
+
+	// Probes get an ID but are only logged at debug level
+	buf.Reset()
+	w = serve("/api/health", nil)
+	if w.Header().Get(RequestIDHeader) == "" || buf.Len() != 0 {
+		t.Errorf("Expected an unlogged probe with an ID, got %q", buf.String())
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	for raw, want := range map[string]string{
+		"page=2":                              "page=2",
+		"password=secret&format=svg":          "password=REDACTED&format=svg",
+		"format=png&Token=abc&sig=f00&size=5": "format=png&Token=REDACTED&sig=REDACTED&size=5",
+		"pass%77ord=secret":                   "pass%77ord=REDACTED",
+		"token":                               "token",
+	} {
+		if got := redactQuery(raw); got != want {
+			t.Errorf("redactQuery(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
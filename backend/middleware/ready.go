@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireStartup answers 503 while startup steps are still pending, so requests that
+// arrive before the database is migrated never reach the handlers. The given paths
+// (the health and readiness probes) are always passed through.
+func RequireStartup(readiness *services.Readiness, passthrough ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(passthrough))
+	for _, path := range passthrough {
+		skip[path] = true
+	}
+	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] || !readiness.Starting() {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", "5")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Server is starting"})
+	}
+}
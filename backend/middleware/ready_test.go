@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"photobridge/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireStartup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	readiness := services.NewReadiness("migrations", "upload_dir")
+
+	router := gin.New()
+	router.Use(RequireStartup(readiness, "/api/ready"))
+	router.GET("/api/ready", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/projects", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/api/projects"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After while starting, got %d", w.Code)
+	}
+	if w := get("/api/ready"); w.Code != http.StatusOK {
+		t.Errorf("Expected probes to pass while starting, got %d", w.Code)
+	}
+
+	readiness.Complete("migrations", nil)
+	if w := get("/api/projects"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 until every step finished, got %d", w.Code)
+	}
+
+	// A failed step ends startup: traffic flows, but the instance does not report ready
+	readiness.Complete("upload_dir", errors.New("not writable"))
+	if w := get("/api/projects"); w.Code != http.StatusOK {
+		t.Errorf("Expected requests to pass after startup, got %d", w.Code)
+	}
+	if ready, steps := readiness.Report(); ready || steps[1].Status != services.StepFailed || steps[1].Message != "not writable" {
+		t.Errorf("Expected a failed report, got %v %+v", ready, steps)
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const shareAccessKey = "share_access_token"
+
+// shareAccessRoutes are the share routes below /:token that share access tokens
+// authorize: the link, its photo list and slideshow, and thumbnails
+var shareAccessRoutes = map[string]bool{
+	"":                            true,
+	"/photos":                     true,
+	"/slideshow":                  true,
+	"/photo/:photoId/thumb/small": true,
+	"/photo/:photoId/thumb/large": true,
+}
+
+// shareAccessAllowed reports whether a share access token may be used for the request:
+// one of shareAccessRoutes, or a thumbnail or preview of /api/image
+func shareAccessAllowed(c *gin.Context) bool {
+	if _, route, ok := strings.Cut(c.FullPath(), "/:token"); ok {
+		return shareAccessRoutes[route]
+	}
+	return c.Query("size") != models.ImageSizeOriginal
+}
+
+// ShareAccessToken accepts "Authorization: Bearer pbs_..." tokens minted for the share
+// link in the URL, so embedded viewers (digital frames, TV apps) can skip the Turnstile
+// and password cookie flows. Tokens are read-only and only show photos: they authorize
+// GET/HEAD requests of the photo list and thumbnails, not originals or downloads, and
+// stop working once the link's password changes. Other bearer tokens (e.g. the admin
+// JWT sent by the web app) are ignored.
+func ShareAccessToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || !utils.IsShareAccessToken(bearer) {
+			c.Next()
+			return
+		}
+
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Share access tokens are read-only"})
+			c.Abort()
+			return
+		}
+		if !shareAccessAllowed(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Share access tokens only authorize the photo list and thumbnails"})
+			c.Abort()
+			return
+		}
+		var link models.ShareLink
+		err := database.DB.Select("password_hash").Where("token = ?", c.Param("token")).First(&link).Error
+		if err != nil || !utils.VerifyShareAccessToken(bearer, c.Param("token"), link.PasswordHash) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_access_token", "message": "The access token is invalid or has expired"})
+			c.Abort()
+			return
+		}
+
+		c.Set(shareAccessKey, true)
+		c.Next()
+	}
+}
+
+// HasShareAccessToken reports whether the request was authorized by ShareAccessToken
+func HasShareAccessToken(c *gin.Context) bool {
+	return c.GetBool(shareAccessKey)
+}
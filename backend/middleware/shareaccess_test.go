@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func shareAccessRouter() *gin.Engine {
+	router := gin.New()
+	group := router.Group("/share", ShareAccessToken(), RequireTurnstile(), RequireSharePassword())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	group.GET("/:token", ok)
+	group.POST("/:token", ok)
+	group.GET("/:token/photos", ok)
+	group.GET("/:token/download", ok)
+	group.GET("/:token/photo/:photoId", ok)
+	group.GET("/:token/photo/:photoId/thumb/large", ok)
+	return router
+}
+
+func TestShareAccessToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	link := createTestShareLink(t, "frame-link", true, "1234")
+	createTestShareLink(t, "other-link", true, "5678")
+
+	originalSiteKey, originalSecretKey := config.AppConfig.TurnstileSiteKey, config.AppConfig.TurnstileSecretKey
+	defer func() {
+		config.AppConfig.TurnstileSiteKey, config.AppConfig.TurnstileSecretKey = originalSiteKey, originalSecretKey
+	}()
+	config.AppConfig.TurnstileSiteKey = "test-site-key"
+	config.AppConfig.TurnstileSecretKey = "test-secret-key"
+
+	token, _, err := utils.GenerateShareAccessToken("frame-link", link.PasswordHash, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateShareAccessToken failed: %v", err)
+	}
+	router := shareAccessRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		want   int
+	}{
+		{"valid token skips turnstile and password", "GET", "/share/frame-link", "Bearer " + token, http.StatusOK},
+		{"photo list", "GET", "/share/frame-link/photos", "Bearer " + token, http.StatusOK},
+		{"thumbnails", "GET", "/share/frame-link/photo/1/thumb/large", "Bearer " + token, http.StatusOK},
+		{"no originals", "GET", "/share/frame-link/photo/1", "Bearer " + token, http.StatusForbidden},
+		{"no downloads", "GET", "/share/frame-link/download", "Bearer " + token, http.StatusForbidden},
+		{"token bound to its link", "GET", "/share/other-link", "Bearer " + token, http.StatusUnauthorized},
+		{"read-only", "POST", "/share/frame-link", "Bearer " + token, http.StatusForbidden},
+		{"invalid token", "GET", "/share/frame-link", "Bearer " + utils.ShareAccessTokenPrefix + "bogus", http.StatusUnauthorized},
+		{"other bearer tokens fall through to turnstile", "GET", "/share/frame-link", "Bearer admin-jwt", http.StatusForbidden},
+		{"no token", "GET", "/share/frame-link", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d (%s)", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestShareAccessToken_PasswordChanged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	link := createTestShareLink(t, "frame-link", true, "1234")
+	token, _, err := utils.GenerateShareAccessToken("frame-link", link.PasswordHash, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateShareAccessToken failed: %v", err)
+	}
+	router := shareAccessRouter()
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/share/frame-link/photos", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("Expected the token to work, got %d", code)
+	}
+	hash, _ := utils.HashPassword("5678")
+	database.DB.Model(link).Update("password_hash", hash)
+	if code := get(); code != http.StatusUnauthorized {
+		t.Errorf("Expected the token to be revoked by the new password, got %d", code)
+	}
+}
@@ -29,8 +29,16 @@ func RequireSharePassword() gin.HandlerFunc {
 			return
 		}
 
-		// If password is not enabled, allow access
-		if !link.PasswordEnabled {
+		// Expired links stay unreachable until the sweeper retires them
+		if link.IsExpired() {
+			c.JSON(http.StatusGone, gin.H{"error": "link_expired", "message": "This share link has expired"})
+			c.Abort()
+			return
+		}
+
+		// If password is not enabled (or a share access token was presented), allow access
+		if !link.PasswordEnabled || HasShareAccessToken(c) {
+			c.Set(shareLinkKey, &link)
 			c.Next()
 			return
 		}
@@ -38,9 +46,10 @@ func RequireSharePassword() gin.HandlerFunc {
 		// Check if user has valid verification cookie
 		cookieName := passwordCookieName + token
 		if cookie, err := c.Cookie(cookieName); err == nil && cookie != "" {
-			// Verify cookie signature
-			if utils.VerifyPasswordCookie(cookie, token) {
+			// Verify cookie signature (revoked once the password changed)
+			if utils.VerifyPasswordCookie(cookie, token, link.PasswordHash) {
 				// User is already verified with valid signature
+				c.Set(shareLinkKey, &link)
 				c.Next()
 				return
 			}
@@ -77,8 +86,13 @@ func VerifySharePasswordHandler(c *gin.Context) {
 		return
 	}
 
-	// Verify password
-	if req.Password != link.Password {
+	if link.IsExpired() {
+		c.JSON(http.StatusGone, gin.H{"error": "link_expired", "message": "This share link has expired"})
+		return
+	}
+
+	// Verify password (bcrypt compares in constant time)
+	if !utils.VerifyPassword(link.PasswordHash, req.Password) {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
 			"error":   "Incorrect password",
@@ -95,7 +109,7 @@ func VerifySharePasswordHandler(c *gin.Context) {
 	cookieName := passwordCookieName + token
 	c.SetCookie(
 		cookieName,
-		utils.GeneratePasswordCookie(token),
+		utils.GeneratePasswordCookie(token, link.PasswordHash),
 		passwordCookieMaxAge,
 		"/",
 		"",       // domain (empty = current domain)
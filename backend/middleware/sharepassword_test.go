@@ -40,7 +40,13 @@ func createTestShareLink(t *testing.T, token string, passwordEnabled bool, passw
 		Alias:           "test-alias",
 		AllowRaw:        true,
 		PasswordEnabled: passwordEnabled,
-		Password:        password,
+	}
+	if password != "" {
+		hash, err := utils.HashPassword(password)
+		if err != nil {
+			t.Fatalf("Failed to hash password: %v", err)
+		}
+		link.PasswordHash = hash
 	}
 
 	if err := database.DB.Create(link).Error; err != nil {
@@ -100,10 +106,10 @@ func TestRequireSharePassword_ValidCookie(t *testing.T) {
 
 	// Create a share link with password enabled
 	token := "test-token-with-password"
-	createTestShareLink(t, token, true, "1234")
+	link := createTestShareLink(t, token, true, "1234")
 
 	// Generate a valid password cookie for this token
-	validCookie := utils.GeneratePasswordCookie(token)
+	validCookie := utils.GeneratePasswordCookie(token, link.PasswordHash)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -389,11 +395,11 @@ func TestRequireSharePassword_CookieTokenBinding(t *testing.T) {
 	// Create two share links
 	token1 := "test-token-1"
 	token2 := "test-token-2"
-	createTestShareLink(t, token1, true, "1234")
+	link1 := createTestShareLink(t, token1, true, "1234")
 	createTestShareLink(t, token2, true, "5678")
 
 	// Generate a valid password cookie for token1
-	cookie1 := utils.GeneratePasswordCookie(token1)
+	cookie1 := utils.GeneratePasswordCookie(token1, link1.PasswordHash)
 
 	// Try to use cookie1 to access token2 (should fail due to token binding)
 	w := httptest.NewRecorder()
@@ -415,3 +421,74 @@ func TestRequireSharePassword_CookieTokenBinding(t *testing.T) {
 		t.Error("Cookie from one token should not work for a different token")
 	}
 }
+
+func TestRequireSharePassword_PasswordChanged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+
+	config.AppConfig = &config.Config{
+		JWTSecret: "test-secret",
+	}
+
+	token := "test-token-regenerated"
+	link := createTestShareLink(t, token, true, "1234")
+	cookie := utils.GeneratePasswordCookie(token, link.PasswordHash)
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "token", Value: token}}
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.AddCookie(&http.Cookie{Name: "pb_share_verified_" + token, Value: cookie})
+		c.Request = req
+		RequireSharePassword()(c)
+		return w
+	}
+	if w := request(); w.Code == http.StatusForbidden {
+		t.Fatal("Cookie should work before the password changes")
+	}
+
+	// Regenerating the password (e.g. after it leaked) revokes earlier verifications
+	hash, err := utils.HashPassword("5678")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	database.DB.Model(link).Update("password_hash", hash)
+	if w := request(); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 with a cookie for the old password, got %d", w.Code)
+	}
+}
+
+func TestVerifySharePasswordHandler_ChosenPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	config.AppConfig = &config.Config{
+		JWTSecret: "test-secret",
+	}
+
+	// Longer alphanumeric passwords are stored hashed like generated ones
+	token := "test-token"
+	link := createTestShareLink(t, token, true, "Spring2024Wedding")
+	if link.PasswordHash == "Spring2024Wedding" || !utils.IsPasswordHash(link.PasswordHash) {
+		t.Fatalf("Password should be stored as a bcrypt hash, got %q", link.PasswordHash)
+	}
+
+	for password, expected := range map[string]int{
+		"Spring2024Wedding": http.StatusOK,
+		"spring2024wedding": http.StatusForbidden,
+		"Spring2024":        http.StatusForbidden,
+	} {
+		jsonBody, _ := json.Marshal(map[string]string{"password": password})
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "token", Value: token}}
+		c.Request = httptest.NewRequest("POST", "/verify-password", bytes.NewReader(jsonBody))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		VerifySharePasswordHandler(c)
+
+		if w.Code != expected {
+			t.Errorf("Password %q: expected status %d, got %d", password, expected, w.Code)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	shareLinkKey  = "share_link"
+	sharePhotoKey = "share_photo"
+)
+
+// RequireSharePhoto resolves the :photoId of a share route and rejects photos the link
+// doesn't expose: photos of other projects (404), and photos that are excluded or outside
+// the link's date range (403). Must run after RequireSharePassword.
+// The photo is loaded without thumbnail blobs; use SharePhoto to read it.
+func RequireSharePhoto() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		photoID, err := strconv.ParseUint(c.Param("photoId"), 10, 32)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+			return
+		}
+
+		link := ShareLink(c)
+		if link == nil {
+			link = &models.ShareLink{}
+			if err := database.DB.Where("token = ?", c.Param("token")).First(link).Error; err != nil {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Share link not found"})
+				return
+			}
+		}
+		if link.Project.ID == 0 {
+			if err := database.DB.First(&link.Project, link.ProjectID).Error; err != nil {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+				return
+			}
+		}
+
+		// Check if photo is excluded or outside the link's date range
+		if !common.IsPhotoVisible(link, uint(photoID)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Photo not accessible"})
+			return
+		}
+
+		// 验证照片属于该分享链接的项目
+		var photo models.Photo
+		if err := database.DB.Select(common.PhotoMetaColumns).
+			Where("id = ? AND project_id = ?", photoID, link.ProjectID).First(&photo).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+			return
+		}
+
+		c.Set(shareLinkKey, link)
+		c.Set(sharePhotoKey, &photo)
+		c.Next()
+	}
+}
+
+// ShareLink returns the link resolved by RequireSharePassword, or nil
+func ShareLink(c *gin.Context) *models.ShareLink {
+	if v, ok := c.Get(shareLinkKey); ok {
+		return v.(*models.ShareLink)
+	}
+	return nil
+}
+
+// SharePhoto returns the link (with Project) and photo resolved by RequireSharePhoto
+func SharePhoto(c *gin.Context) (*models.ShareLink, *models.Photo) {
+	link, photo := ShareLink(c), (*models.Photo)(nil)
+	if v, ok := c.Get(sharePhotoKey); ok {
+		photo = v.(*models.Photo)
+	}
+	return link, photo
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupSharePhotoRouter creates a project with photos, share links and a router
+// that runs the same middleware chain as the single-photo share routes
+func setupSharePhotoRouter(t *testing.T) (*gin.Engine, map[string]uint) {
+	gin.SetMode(gin.TestMode)
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.Project{}, &models.Photo{}, &models.ShareLink{}, &models.PhotoExclusion{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	project := models.Project{Name: "wedding"}
+	other := models.Project{Name: "other"}
+	database.DB.Create(&project)
+	database.DB.Create(&other)
+
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := map[string]uint{}
+	for name, photo := range map[string]*models.Photo{
+		"visible":  {ProjectID: project.ID, BaseName: "IMG_1", NormalExt: ".jpg"},
+		"excluded": {ProjectID: project.ID, BaseName: "IMG_2", NormalExt: ".jpg"},
+		"old":      {ProjectID: project.ID, BaseName: "IMG_3", NormalExt: ".jpg", TakenAt: &old},
+		"foreign":  {ProjectID: other.ID, BaseName: "IMG_4", NormalExt: ".jpg"},
+	} {
+		database.DB.Create(photo)
+		ids[name] = photo.ID
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	past := time.Now().Add(-time.Hour)
+	links := []models.ShareLink{
+		{ProjectID: project.ID, Token: "open", Exclusions: []models.PhotoExclusion{{PhotoID: ids["excluded"]}}},
+		{ProjectID: project.ID, Token: "ranged", FromDate: &from},
+		{ProjectID: project.ID, Token: "locked", PasswordEnabled: true, PasswordHash: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		{ProjectID: project.ID, Token: "expired", ExpiresAt: &past},
+	}
+	for i := range links {
+		database.DB.Create(&links[i])
+	}
+
+	router := gin.New()
+	router.GET("/share/:token/photo/:photoId", RequireSharePassword(), RequireSharePhoto(), func(c *gin.Context) {
+		link, photo := SharePhoto(c)
+		c.String(http.StatusOK, "%s/%s/%d", link.Project.Name, link.Token, photo.ID)
+	})
+	return router, ids
+}
+
+func TestRequireSharePhoto(t *testing.T) {
+	router, ids := setupSharePhotoRouter(t)
+
+	tests := []struct {
+		name   string
+		token  string
+		photo  string
+		status int
+	}{
+		{"visible photo", "open", fmt.Sprint(ids["visible"]), http.StatusOK},
+		{"excluded photo", "open", fmt.Sprint(ids["excluded"]), http.StatusForbidden},
+		{"other project's photo", "open", fmt.Sprint(ids["foreign"]), http.StatusNotFound},
+		{"missing photo", "open", "9999", http.StatusNotFound},
+		{"invalid photo ID", "open", "abc", http.StatusBadRequest},
+		{"outside date range", "ranged", fmt.Sprint(ids["old"]), http.StatusForbidden},
+		{"inside date range", "ranged", fmt.Sprint(ids["visible"]), http.StatusOK},
+		{"password required", "locked", fmt.Sprint(ids["visible"]), http.StatusForbidden},
+		{"expired link", "expired", fmt.Sprint(ids["visible"]), http.StatusGone},
+		{"unknown link", "nope", fmt.Sprint(ids["visible"]), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/share/"+tt.token+"/photo/"+tt.photo, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d (body: %s)", tt.name, tt.status, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/share/open/photo/%d", ids["visible"]), nil))
+	if want := fmt.Sprintf("wedding/open/%d", ids["visible"]); w.Body.String() != want {
+		t.Errorf("Expected handler to see %q, got %q", want, w.Body.String())
+	}
+}
+
+func TestRequireSharePhoto_PasswordVerified(t *testing.T) {
+	router, ids := setupSharePhotoRouter(t)
+	var locked models.ShareLink
+	database.DB.Where("token = ?", "locked").First(&locked)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/share/locked/photo/%d", ids["visible"]), nil)
+	req.AddCookie(&http.Cookie{Name: "pb_share_verified_locked", Value: utils.GeneratePasswordCookie("locked", locked.PasswordHash)})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected verified visitor to get the photo, got %d (body: %s)", w.Code, w.Body.String())
+	}
+
+	// The cookie of one link doesn't open another's photos
+	req = httptest.NewRequest("GET", fmt.Sprintf("/share/locked/photo/%d", ids["excluded"]), nil)
+	req.AddCookie(&http.Cookie{Name: "pb_share_verified_locked", Value: utils.GeneratePasswordCookie("open", locked.PasswordHash)})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected cookie for another link to be rejected, got %d", w.Code)
+	}
+}
+
+func TestImageAuth(t *testing.T) {
+	_, ids := setupSharePhotoRouter(t)
+	database.DB.AutoMigrate(&models.AdminSession{})
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+	database.DB.Create(&models.AdminSession{JTI: "admin", Username: "admin", LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
+
+	router := gin.New()
+	router.GET("/image/:photoId", append(ImageAuth(), func(c *gin.Context) {
+		if link, photo := SharePhoto(c); link != nil {
+			c.String(http.StatusOK, "share %s/%d", link.Token, photo.ID)
+			return
+		}
+		c.String(http.StatusOK, "admin %s", c.Param("photoId"))
+	})...)
+
+	tests := []struct {
+		name   string
+		photo  string
+		query  string
+		admin  bool
+		status int
+		body   string
+	}{
+		{"admin sees every photo", "excluded", "", true, http.StatusOK, "admin %d"},
+		{"no credentials", "visible", "", false, http.StatusUnauthorized, ""},
+		{"share link", "visible", "?share=open", false, http.StatusOK, "share open/%d"},
+		{"admin token doesn't bypass the link", "excluded", "?share=open", true, http.StatusForbidden, ""},
+		{"password required", "visible", "?share=locked", false, http.StatusForbidden, ""},
+		{"unknown link", "visible", "?share=nope", false, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/image/%d%s", ids[tt.photo], tt.query), nil)
+		if tt.admin {
+			req.Header.Set("Authorization", "Bearer "+signAdminToken(t, "admin"))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d (body: %s)", tt.name, tt.status, w.Code, w.Body.String())
+		}
+		if want := fmt.Sprintf(tt.body, ids[tt.photo]); tt.body != "" && w.Body.String() != want {
+			t.Errorf("%s: expected handler to see %q, got %q", tt.name, want, w.Body.String())
+		}
+	}
+
+	// API keys take the admin path; the handler checks the scope of limited keys
+	database.DB.AutoMigrate(&models.APIKey{})
+	secret := "pb_readonly0000000000"
+	database.DB.Create(&models.APIKey{Name: "read", Permission: models.APIKeyRead,
+		Prefix: utils.APIKeyPrefix(secret), KeyHash: utils.HashAPIKey(secret)})
+	for key, status := range map[string]int{secret: http.StatusOK, "pb_unknown0000000000": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/image/%d", ids["excluded"]), nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("API key %s: expected status %d, got %d", key, status, w.Code)
+		}
+	}
+}
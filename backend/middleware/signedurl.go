@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"photobridge/config"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireSignedUpload validates signed /uploads URLs when CDN_SIGN_KEY is set.
+// A token, when present, must be valid and unexpired. Requests without a token are
+// rejected if they come from a CDN edge (leaked CDN links can't be re-fetched from
+// origin) or when CDN_SIGN_REQUIRED=true.
+func RequireSignedUpload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !utils.URLSigningEnabled() {
+			c.Next()
+			return
+		}
+
+		token := c.Query(config.AppConfig.CDNSignParam)
+		if token == "" {
+			if config.AppConfig.CDNSignRequired || config.AppConfig.IsCDNIP(GetRealIP(c)) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "signature_required"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if !utils.VerifyUploadSignature(c.Request.URL.EscapedPath(), token, time.Now()) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid_signature"})
+			return
+		}
+
+		c.Next()
+	}
+}
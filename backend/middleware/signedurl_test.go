@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupSignedUploadRouter(t *testing.T, required bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	originalKey := config.AppConfig.CDNSignKey
+	originalParam := config.AppConfig.CDNSignParam
+	originalTTL := config.AppConfig.CDNSignTTLSeconds
+	originalRequired := config.AppConfig.CDNSignRequired
+	t.Cleanup(func() {
+		config.AppConfig.CDNSignKey = originalKey
+		config.AppConfig.CDNSignParam = originalParam
+		config.AppConfig.CDNSignTTLSeconds = originalTTL
+		config.AppConfig.CDNSignRequired = originalRequired
+	})
+
+	config.AppConfig.CDNSignKey = "sign-secret"
+	config.AppConfig.CDNSignParam = "auth_key"
+	config.AppConfig.CDNSignTTLSeconds = 600
+	config.AppConfig.CDNSignRequired = required
+
+	r := gin.New()
+	uploads := r.Group("/uploads")
+	uploads.Use(RequireSignedUpload())
+	uploads.GET("/*filepath", func(c *gin.Context) {
+		c.String(http.StatusOK, "image")
+	})
+	return r
+}
+
+func TestRequireSignedUpload(t *testing.T) {
+	r := setupSignedUploadRouter(t, false)
+	config.AppConfig.InitCDNIPSet()
+	config.AppConfig.AddCDNIP("198.51.100.20")
+
+	signed := utils.SignUploadURL("/uploads/My%20Project/a.jpg")
+
+	tests := []struct {
+		name     string
+		url      string
+		clientIP string
+		expected int
+	}{
+		{"Valid signature", signed, "", http.StatusOK},
+		{"Valid signature via CDN", signed, "198.51.100.20", http.StatusOK},
+		{"Unsigned direct request", "/uploads/My%20Project/a.jpg", "", http.StatusOK},
+		{"Unsigned CDN pull", "/uploads/My%20Project/a.jpg", "198.51.100.20", http.StatusForbidden},
+		{"Signature for other file", strings.Replace(signed, "a.jpg", "b.jpg", 1), "", http.StatusForbidden},
+		{"Bad token", "/uploads/My%20Project/a.jpg?auth_key=1-0-0-deadbeef", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.clientIP != "" {
+				req.Header.Set("X-Real-IP", tt.clientIP)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestRequireSignedUpload_Required(t *testing.T) {
+	r := setupSignedUploadRouter(t, true)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/uploads/project/a.jpg", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for unsigned request, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", utils.SignUploadURL("/uploads/project/a.jpg"), nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for signed request, got %d", w.Code)
+	}
+}
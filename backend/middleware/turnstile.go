@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -18,8 +19,8 @@ const (
 // RequireTurnstile is a middleware that requires Turnstile verification for first-time visitors
 func RequireTurnstile() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Skip if Turnstile is not configured
-		if config.AppConfig.TurnstileSiteKey == "" || config.AppConfig.TurnstileSecretKey == "" {
+		// Skip if Turnstile is not configured, or for viewers with a share access token
+		if config.AppConfig.TurnstileSiteKey == "" || config.AppConfig.TurnstileSecretKey == "" || HasShareAccessToken(c) {
 			c.Next()
 			return
 		}
@@ -72,7 +73,15 @@ func VerifyTurnstileHandler(c *gin.Context) {
 	realIP := GetRealIP(c)
 
 	// Verify token with Cloudflare
-	success, err := utils.VerifyTurnstileToken(req.Token, realIP)
+	success, err := utils.VerifyTurnstileToken(c.Request.Context(), req.Token, realIP)
+	if errors.Is(err, utils.ErrTurnstileUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "verification_unavailable",
+			"message": "Verification service is temporarily unavailable, please try again later",
+		})
+		return
+	}
 	if err != nil || !success {
 		c.JSON(http.StatusForbidden, gin.H{
 			"success": false,
@@ -92,9 +101,9 @@ func VerifyTurnstileHandler(c *gin.Context) {
 		utils.GenerateVerificationCookie(),
 		cookieMaxAge,
 		"/",
-		"",        // domain (empty = current domain)
-		isSecure,  // secure (HTTPS only when appropriate)
-		true,      // httpOnly (not accessible via JavaScript)
+		"",       // domain (empty = current domain)
+		isSecure, // secure (HTTPS only when appropriate)
+		true,     // httpOnly (not accessible via JavaScript)
 	)
 
 	// Add debug header
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/services"
+)
+
+// runMigrateThumbs implements "photobridge migrate-thumbs [-batch N]": writes thumbnails
+// still stored as database blobs to THUMB_DIR and clears the blobs. SQLite databases
+// are vacuumed afterwards so the freed pages are returned to the filesystem.
+// Thumbnails are also moved one at a time when first requested, so running this is
+// optional; it reclaims the space at once.
+func runMigrateThumbs(args []string) {
+	fs := flag.NewFlagSet("migrate-thumbs", flag.ExitOnError)
+	batch := fs.Int("batch", 200, "photos loaded per batch")
+	fs.Parse(args)
+
+	if *batch < 1 {
+		log.Fatalf("%s -batch must be at least 1", shortname)
+	}
+
+	moved, err := services.MigrateThumbnailBlobs(*batch)
+	if err != nil {
+		log.Fatalf("%s Thumbnail migration failed after %d photos: %v", shortname, moved, err)
+	}
+	log.Printf("%s Moved thumbnails of %d photos to %s", shortname, moved, config.AppConfig.ThumbDir)
+
+	if moved > 0 && config.AppConfig.UsesSQLite() {
+		log.Printf("%s Vacuuming database to reclaim space", shortname)
+		if err := database.DB.Exec("VACUUM").Error; err != nil {
+			log.Fatalf("%s VACUUM failed: %v", shortname, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Share link activity recorded in AccessLog
+const (
+	LinkAccessView     = "view"     // gallery opened (share info loaded)
+	LinkAccessDownload = "download" // single-photo download
+	LinkAccessZip      = "zip"      // download-all ZIP
+)
+
+// AccessLog records one visit or download through a share link, for the link's view
+// and download statistics. Unlike PhotoAccess it covers every project, and entries are
+// deleted with their link.
+type AccessLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	LinkID    uint      `gorm:"index;not null" json:"link_id"`
+	ProjectID uint      `gorm:"index;not null" json:"project_id"`
+	PhotoID   *uint     `gorm:"index" json:"photo_id"`          // Set for single-photo downloads
+	Action    string    `gorm:"size:16;not null" json:"action"` // view, download or zip
+	IP        string    `gorm:"size:64" json:"ip"`
+	Country   string    `gorm:"size:8" json:"country,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// LinkDailyStat is the activity of a share link on one day, rolled up from AccessLog
+// entries older than ACCESS_LOG_RETENTION_DAYS
+type LinkDailyStat struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	LinkID       uint      `gorm:"uniqueIndex:idx_link_daily_stat,priority:1;not null" json:"link_id"`
+	ProjectID    uint      `gorm:"index;not null" json:"project_id"`
+	Day          time.Time `gorm:"uniqueIndex:idx_link_daily_stat,priority:2;not null" json:"day"` // Local midnight
+	Views        int64     `gorm:"not null;default:0" json:"views"`
+	Downloads    int64     `gorm:"not null;default:0" json:"downloads"`
+	ZipDownloads int64     `gorm:"not null;default:0" json:"zip_downloads"`
+	Visitors     int64     `gorm:"not null;default:0" json:"visitors"` // Distinct IPs of the day
+	FirstAccess  time.Time `json:"first_access"`
+	LastAccess   time.Time `json:"last_access"`
+}
+
+// LinkPhotoDailyStat counts the single-photo downloads of a photo through a share link
+// on one day, for the link's most downloaded photos
+type LinkPhotoDailyStat struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	LinkID    uint      `gorm:"uniqueIndex:idx_link_photo_daily_stat,priority:1;not null" json:"link_id"`
+	PhotoID   uint      `gorm:"uniqueIndex:idx_link_photo_daily_stat,priority:2;not null" json:"photo_id"`
+	Day       time.Time `gorm:"uniqueIndex:idx_link_photo_daily_stat,priority:3;not null" json:"day"`
+	Downloads int64     `gorm:"not null;default:0" json:"downloads"`
+}
+
+// ProjectDailyStat is the share link activity of a project on one day, for digests
+type ProjectDailyStat struct {
+	ID              uint      `gorm:"primarykey" json:"id"`
+	ProjectID       uint      `gorm:"uniqueIndex:idx_project_daily_stat,priority:1;not null" json:"project_id"`
+	Day             time.Time `gorm:"uniqueIndex:idx_project_daily_stat,priority:2;not null" json:"day"`
+	Views           int64     `gorm:"not null;default:0" json:"views"`
+	Downloads       int64     `gorm:"not null;default:0" json:"downloads"`
+	ZipDownloads    int64     `gorm:"not null;default:0" json:"zip_downloads"`
+	Visitors        int64     `gorm:"not null;default:0" json:"visitors"`         // Distinct IPs of the day
+	GalleriesViewed int64     `gorm:"not null;default:0" json:"galleries_viewed"` // Links opened that day
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// AdminSession tracks one admin login, so signed-in devices can be listed and revoked.
+// Admin tokens carry the session's JTI and are rejected once the session is gone. The
+// short-lived access tokens are renewed with the session's refresh token, which is
+// replaced on every use; the session expires when it isn't refreshed for
+// ADMIN_SESSION_DAYS.
+type AdminSession struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	JTI             string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Username        string     `gorm:"size:255" json:"username"`
+	IP              string     `gorm:"size:64" json:"ip"`
+	UserAgent       string     `gorm:"size:512" json:"user_agent"`
+	RefreshHash     string     `gorm:"size:64;index" json:"-"` // SHA-256 of the current refresh token
+	PrevRefreshHash string     `gorm:"size:64;index" json:"-"` // The refresh token it replaced, to detect reuse
+	RefreshedAt     *time.Time `json:"refreshed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+	ExpiresAt       time.Time  `gorm:"index" json:"expires_at"`
+}
+
+// MaxUserAgentLength caps the stored user agent of a session
+const MaxUserAgentLength = 512
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxAlbumNameLength limits the length of an album name
+const MaxAlbumNameLength = 100
+
+// Album is a section of a project (Ceremony, Reception, ...). A photo is in at most
+// one album (Photo.AlbumID); photos in no album are only listed with the whole project.
+type Album struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ProjectID uint      `gorm:"not null;uniqueIndex:idx_project_album_name,priority:1" json:"project_id"`
+	Name      string    `gorm:"size:100;not null;uniqueIndex:idx_project_album_name,priority:2" json:"name"`
+	Position  int       `gorm:"not null;default:0" json:"position"` // Display order within the project
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AlbumRequest creates an album or changes its name or position. A new album without
+// a position is added after the others.
+type AlbumRequest struct {
+	Name     *string `json:"name"`
+	Position *int    `json:"position"`
+}
+
+// Validate normalizes the name and checks it and the position
+func (r *AlbumRequest) Validate(create bool) error {
+	if r.Name != nil {
+		name := NormalizeTag(*r.Name)
+		r.Name = &name
+	}
+	switch {
+	case create && r.Name == nil, r.Name != nil && *r.Name == "":
+		return fmt.Errorf("name is required")
+	case r.Name != nil && len([]rune(*r.Name)) > MaxAlbumNameLength:
+		return fmt.Errorf("name must be at most %d characters", MaxAlbumNameLength)
+	case r.Position != nil && *r.Position < 0:
+		return fmt.Errorf("position must not be negative")
+	}
+	return nil
+}
+
+// AssignAlbumRequest puts photos of a project into an album, or takes them out of
+// their album when AlbumID is null
+type AssignAlbumRequest struct {
+	BulkPhotosRequest
+	AlbumID *uint `json:"album_id"`
+}
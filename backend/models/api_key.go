@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// API key permissions
+const (
+	APIKeyRead   = "read"   // List projects and photos
+	APIKeyUpload = "upload" // Also upload, create and delete projects
+)
+
+// MaxAPIKeyNameLength limits the name of an API key
+const MaxAPIKeyNameLength = 100
+
+// APIKey grants a script or tool access to the /api routes (X-API-Key). Only a hash of
+// the key is stored; Prefix identifies it in lists and narrows the lookup. A key with a
+// ProjectID only reaches that project.
+type APIKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:16;index;not null" json:"prefix"`
+	KeyHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Permission string     `gorm:"size:16;not null" json:"permission"`
+	ProjectID  *uint      `gorm:"index" json:"project_id"`                // nil = every project
+	FromEnv    bool       `gorm:"not null;default:false" json:"from_env"` // The API_KEY setting, kept in sync at startup
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"size:64" json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CanUpload reports whether the key may change projects and photos
+func (k *APIKey) CanUpload() bool {
+	return k.Permission == APIKeyUpload
+}
+
+// APIKeyRequest creates an API key or replaces its settings
+type APIKeyRequest struct {
+	Name       string `json:"name"`
+	Permission string `json:"permission"` // read or upload
+	ProjectID  *uint  `json:"project_id"` // null = every project
+}
+
+// Validate normalizes the name and checks the permission
+func (r *APIKeyRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Name) > MaxAPIKeyNameLength {
+		return fmt.Errorf("name is longer than %d characters", MaxAPIKeyNameLength)
+	}
+	if r.Permission != APIKeyRead && r.Permission != APIKeyUpload {
+		return fmt.Errorf("permission must be %q or %q", APIKeyRead, APIKeyUpload)
+	}
+	if r.ProjectID != nil && *r.ProjectID == 0 {
+		r.ProjectID = nil
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// PhotoComment is feedback a visitor of a share link left on one photo. Links opt in
+// with the comments preference; the admin can hide comments from the gallery or delete them.
+type PhotoComment struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	LinkID    uint      `gorm:"index;not null" json:"link_id"`
+	PhotoID   uint      `gorm:"index;not null" json:"photo_id"`
+	Name      string    `gorm:"size:100" json:"name"` // Optional, as given by the visitor
+	Body      string    `gorm:"size:2000;not null" json:"body"`
+	Hidden    bool      `gorm:"not null;default:false" json:"hidden"` // Hidden by the admin; only the admin sees it
+	IP        string    `gorm:"size:64;index" json:"ip"`
+	Country   string    `gorm:"size:8" json:"country,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Limits of a comment
+const (
+	MaxCommentNameLength = 100
+	MaxCommentLength     = 2000
+)
+
+// CommentRequest is a comment a visitor posts on a photo
+type CommentRequest struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+// Validate trims the name and body and checks their lengths
+func (r *CommentRequest) Validate() error {
+	r.Name = strings.Join(strings.Fields(r.Name), " ")
+	r.Body = strings.TrimSpace(r.Body)
+	switch {
+	case r.Body == "":
+		return fmt.Errorf("body is required")
+	case utf8.RuneCountInString(r.Body) > MaxCommentLength:
+		return fmt.Errorf("body must be at most %d characters", MaxCommentLength)
+	case utf8.RuneCountInString(r.Name) > MaxCommentNameLength:
+		return fmt.Errorf("name must be at most %d characters", MaxCommentNameLength)
+	}
+	return nil
+}
+
+// Bounds of the admin comment list
+const (
+	DefaultCommentListLimit = 100
+	MaxCommentListLimit     = 1000
+)
+
+// CommentQuery filters the comments the admin moderates
+type CommentQuery struct {
+	Limit     int   `form:"limit"` // default DefaultCommentListLimit
+	ProjectID uint  `form:"project_id"`
+	LinkID    uint  `form:"link_id"`
+	PhotoID   uint  `form:"photo_id"`
+	Hidden    *bool `form:"hidden"`
+}
+
+// Validate checks the limit
+func (q CommentQuery) Validate() error {
+	if q.Limit < 0 || q.Limit > MaxCommentListLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxCommentListLimit)
+	}
+	return nil
+}
+
+// ModerateCommentRequest hides a comment from the gallery or shows it again
+type ModerateCommentRequest struct {
+	Hidden *bool `json:"hidden"`
+}
+
+// Validate checks that the request says what to do
+func (r ModerateCommentRequest) Validate() error {
+	if r.Hidden == nil {
+		return fmt.Errorf("hidden is required")
+	}
+	return nil
+}
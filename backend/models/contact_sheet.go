@@ -0,0 +1,43 @@
+package models
+
+import "fmt"
+
+const (
+	// DefaultContactSheetColumns is the grid width when columns isn't given
+	DefaultContactSheetColumns = 6
+	// MaxContactSheetColumns limits the grid width (12 columns are about 3 000 pixels)
+	MaxContactSheetColumns = 12
+	// MaxContactSheetPhotos limits the photos on one sheet
+	MaxContactSheetPhotos = 500
+)
+
+// ContactSheetQuery picks the photos of a contact sheet (query parameters): every
+// photo of the project, the photos given by photo_ids, or the photos a client picked
+// on the share link link_id
+type ContactSheetQuery struct {
+	PhotoIDs []uint `form:"photo_ids"` // repeated: photo_ids=1&photo_ids=2
+	LinkID   uint   `form:"link_id"`   // a link of the project whose selection is shown
+	Columns  int    `form:"columns"`   // default DefaultContactSheetColumns
+}
+
+// Validate checks the grid width and that at most one photo source is given
+func (q ContactSheetQuery) Validate() error {
+	if q.Columns != 0 && (q.Columns < 2 || q.Columns > MaxContactSheetColumns) {
+		return fmt.Errorf("columns must be between 2 and %d", MaxContactSheetColumns)
+	}
+	if len(q.PhotoIDs) > 0 && q.LinkID != 0 {
+		return fmt.Errorf("give photo_ids or link_id, not both")
+	}
+	if len(q.PhotoIDs) > MaxContactSheetPhotos {
+		return fmt.Errorf("a contact sheet shows at most %d photos", MaxContactSheetPhotos)
+	}
+	return nil
+}
+
+// GridColumns returns the number of columns, applying the default
+func (q ContactSheetQuery) GridColumns() int {
+	if q.Columns == 0 {
+		return DefaultContactSheetColumns
+	}
+	return q.Columns
+}
@@ -1,6 +1,39 @@
 package models
 
+import "time"
+
+// Exclusion reasons: why a photo was hidden from a share link
+const (
+	ExclusionNotEdited     = "not_edited"
+	ExclusionDuplicate     = "duplicate"
+	ExclusionClientRequest = "client_request"
+	ExclusionOther         = "other"
+)
+
+// MaxExclusionNoteLength limits the free-text note of an exclusion
+const MaxExclusionNoteLength = 500
+
+// ValidExclusionReason reports whether reason is empty or one of the known categories
+func ValidExclusionReason(reason string) bool {
+	switch reason {
+	case "", ExclusionNotEdited, ExclusionDuplicate, ExclusionClientRequest, ExclusionOther:
+		return true
+	}
+	return false
+}
+
 type PhotoExclusion struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	LinkID    uint      `gorm:"index;not null" json:"link_id"`
+	PhotoID   uint      `gorm:"index;not null" json:"photo_id"`
+	Reason    string    `gorm:"size:32" json:"reason,omitempty"` // One of the Exclusion* categories; empty if none was given
+	Note      string    `gorm:"size:500" json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"` // Zero for exclusions made before reasons were recorded
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RawExclusion hides only the RAW file of a photo from a share link; the normal image stays available
+type RawExclusion struct {
 	ID      uint `gorm:"primarykey" json:"id"`
 	LinkID  uint `gorm:"index;not null" json:"link_id"`
 	PhotoID uint `gorm:"index;not null" json:"photo_id"`
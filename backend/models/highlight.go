@@ -0,0 +1,12 @@
+package models
+
+// PhotoHighlight pins a photo to the hero strip of a share link
+type PhotoHighlight struct {
+	ID       uint `gorm:"primarykey" json:"id"`
+	LinkID   uint `gorm:"index;not null" json:"link_id"`
+	PhotoID  uint `gorm:"index;not null" json:"photo_id"`
+	Position int  `gorm:"not null;default:0" json:"position"` // Display order in the hero strip
+}
+
+// MaxHighlightsPerLink limits the hero strip to a handful of photos
+const MaxHighlightsPerLink = 12
@@ -0,0 +1,46 @@
+package models
+
+import "fmt"
+
+// Image sizes served by /api/image
+const (
+	ImageSizeSmall    = "small"    // Grid thumbnail
+	ImageSizeLarge    = "large"    // Web-size preview
+	ImageSizeOriginal = "original" // The uploaded file
+)
+
+// Image formats served by /api/image
+const (
+	ImageFormatAuto = "auto" // Thumbnails as JPEG, originals as uploaded (RAW-only photos as converted JPEG)
+	ImageFormatJPEG = "jpeg" // A JPEG original, or the JPEG converted from a RAW-only or HEIC/HEIF photo
+	ImageFormatRaw  = "raw"  // The RAW file of the photo (originals only)
+)
+
+// ImageQuery picks the derivative /api/image returns (query parameters)
+type ImageQuery struct {
+	Size   string `form:"size"`   // small, large (default) or original
+	Format string `form:"format"` // auto (default), jpeg or raw
+}
+
+// Validate applies the defaults and checks the size and format against the allowlist
+func (q *ImageQuery) Validate() error {
+	if q.Size == "" {
+		q.Size = ImageSizeLarge
+	}
+	if q.Format == "" {
+		q.Format = ImageFormatAuto
+	}
+	switch q.Size {
+	case ImageSizeSmall, ImageSizeLarge:
+		if q.Format != ImageFormatAuto && q.Format != ImageFormatJPEG {
+			return fmt.Errorf("%s images are only available as %s", q.Size, ImageFormatJPEG)
+		}
+	case ImageSizeOriginal:
+		if q.Format != ImageFormatAuto && q.Format != ImageFormatJPEG && q.Format != ImageFormatRaw {
+			return fmt.Errorf("format must be %s, %s or %s", ImageFormatAuto, ImageFormatJPEG, ImageFormatRaw)
+		}
+	default:
+		return fmt.Errorf("size must be %s, %s or %s", ImageSizeSmall, ImageSizeLarge, ImageSizeOriginal)
+	}
+	return nil
+}
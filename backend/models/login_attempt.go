@@ -0,0 +1,41 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoginAttempt records one admin login attempt, for the backoff and lockout of repeated
+// failures and for the login audit
+type LoginAttempt struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Username  string    `gorm:"size:255;index" json:"username"`
+	IP        string    `gorm:"size:64;index" json:"ip"`
+	Country   string    `gorm:"size:8" json:"country,omitempty"`
+	UserAgent string    `gorm:"size:512" json:"user_agent"`
+	Success   bool      `json:"success"`
+	Blocked   bool      `json:"blocked"` // Refused during backoff or lockout, without checking the password
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// Bounds of the login audit list
+const (
+	DefaultLoginAuditLimit = 100
+	MaxLoginAuditLimit     = 1000
+)
+
+// LoginAuditQuery filters the login audit
+type LoginAuditQuery struct {
+	Limit    int    `form:"limit"` // default DefaultLoginAuditLimit
+	IP       string `form:"ip"`
+	Username string `form:"username"`
+	Success  *bool  `form:"success"`
+}
+
+// Validate checks the limit
+func (q LoginAuditQuery) Validate() error {
+	if q.Limit < 0 || q.Limit > MaxLoginAuditLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxLoginAuditLimit)
+	}
+	return nil
+}
@@ -8,18 +8,29 @@ import (
 
 type Photo struct {
 	ID            uint           `gorm:"primarykey" json:"id"`
-	ProjectID     uint           `gorm:"index;index:idx_project_file_hash,priority:1;index:idx_project_normal_hash,priority:1;index:idx_project_raw_hash,priority:1;not null" json:"project_id"`
-	BaseName      string         `gorm:"size:255;not null" json:"base_name"`
+	ProjectID     uint           `gorm:"index;index:idx_project_file_hash,priority:1;index:idx_project_normal_hash,priority:1;index:idx_project_raw_hash,priority:1;uniqueIndex:idx_project_base_name,priority:1,where:deleted_at IS NULL;not null" json:"project_id"`
+	BaseName      string         `gorm:"size:255;not null;uniqueIndex:idx_project_base_name,priority:2,where:deleted_at IS NULL" json:"base_name"`
 	NormalExt     string         `gorm:"size:10" json:"normal_ext"`
 	RawExt        string         `gorm:"size:10" json:"raw_ext"`
 	HasRaw        bool           `gorm:"default:false" json:"has_raw"`
 	FileHash      string         `gorm:"size:64;index;index:idx_project_file_hash,priority:2" json:"file_hash,omitempty"`    // SHA-256 hash for normal image (kept for backward compatibility)
 	NormalHash    string         `gorm:"size:64;index;index:idx_project_normal_hash,priority:2" json:"normal_hash,omitempty"`  // SHA-256 hash for normal image
 	RawHash       string         `gorm:"size:64;index;index:idx_project_raw_hash,priority:2" json:"raw_hash,omitempty"`     // SHA-256 hash for RAW file
-	ThumbSmall    []byte         `gorm:"type:blob" json:"-"`                          // 列表缩略图 ~300px
-	ThumbLarge    []byte         `gorm:"type:blob" json:"-"`                          // 预览缩略图 ~1200px
+	ThumbSmall    []byte         `json:"-"`                                           // 列表缩略图 ~300px
+	ThumbLarge    []byte         `json:"-"`                                           // 预览缩略图 ~1200px
 	ThumbWidth    int            `json:"thumb_width,omitempty"`                       // 缩略图宽度
 	ThumbHeight   int            `json:"thumb_height,omitempty"`                      // 缩略图高度
+	ThumbParams   string         `gorm:"size:32" json:"-"`                            // Sizes and qualities the thumbnails were generated with (utils.ThumbParams)
+	Width         int            `json:"width,omitempty"`                             // 原图宽度（上传时读取）
+	Height        int            `json:"height,omitempty"`                            // 原图高度
+	NormalSize    int64          `json:"normal_size,omitempty"`                       // 普通图片文件大小（字节）
+	RawSize       int64          `json:"raw_size,omitempty"`                          // RAW文件大小（字节）
+	TakenAt       *time.Time     `gorm:"index" json:"taken_at,omitempty"`             // EXIF capture time (nil if unknown)
+	Exif          PhotoExif      `gorm:"embedded;embeddedPrefix:exif_" json:"-"`      // Camera, lens, exposure and GPS
+	Rating        int            `gorm:"default:0" json:"rating,omitempty"`           // 0-5 stars, 0 is unrated
+	Caption       string         `gorm:"size:2000" json:"caption,omitempty"`
+	Tags          []Tag          `gorm:"many2many:photo_tags" json:"tags,omitempty"`
+	AlbumID       *uint          `gorm:"index" json:"album_id,omitempty"` // Section of the project the photo is in (nil = none)
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
@@ -41,6 +52,13 @@ func IsImageExtension(ext string) bool {
 	imageExtensions := map[string]bool{
 		".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
 		".webp": true, ".bmp": true, ".tiff": true, ".tif": true,
+		".heic": true, ".heif": true,
 	}
 	return imageExtensions[ext]
 }
+
+// IsHEIFExtension checks if the given extension is HEIC/HEIF, which Go can't decode and
+// most browsers can't display (see HEIF_CONVERT_COMMAND)
+func IsHEIFExtension(ext string) bool {
+	return ext == ".heic" || ext == ".heif"
+}
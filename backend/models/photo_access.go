@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Files of a photo an access can be to
+const (
+	AccessFileNormal    = "normal"
+	AccessFileRaw       = "raw"
+	AccessFileConverted = "converted" // JPEG rendered from the RAW or HEIC/HEIF
+)
+
+// Ways an original can be accessed
+const (
+	AccessView     = "view"     // opened in the gallery or through its URL
+	AccessDownload = "download" // single-photo download
+	AccessArchive  = "archive"  // part of a download-all ZIP
+)
+
+// PhotoAccess records one access to an original file of a photo in a sensitive
+// project, for model releases and privacy agreements that require knowing who saw
+// which photo. Entries outlive the photo and the link.
+type PhotoAccess struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	PhotoID   uint      `gorm:"index;not null" json:"photo_id"`
+	ProjectID uint      `gorm:"index;not null" json:"project_id"`
+	LinkID    *uint     `gorm:"index" json:"link_id"`                 // nil when the link is unknown (direct /uploads URL)
+	LinkAlias string    `gorm:"size:255" json:"link_alias,omitempty"` // Alias at the time, readable after the link is deleted
+	File      string    `gorm:"size:16;not null" json:"file"`         // normal, raw or converted
+	Action    string    `gorm:"size:16;not null" json:"action"`       // view, download or archive
+	IP        string    `gorm:"size:64" json:"ip"`
+	Country   string    `gorm:"size:8" json:"country,omitempty"`
+	UserAgent string    `gorm:"size:512" json:"user_agent"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
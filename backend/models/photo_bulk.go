@@ -0,0 +1,28 @@
+package models
+
+import "fmt"
+
+// MaxBulkPhotos limits the photos changed by one bulk request
+const MaxBulkPhotos = 5000
+
+// BulkPhotosRequest names the photos of a bulk operation
+type BulkPhotosRequest struct {
+	PhotoIDs []uint `json:"photo_ids"`
+}
+
+// Validate checks that the request names some photos, but not too many
+func (r BulkPhotosRequest) Validate() error {
+	if len(r.PhotoIDs) == 0 {
+		return fmt.Errorf("photo_ids must name at least one photo")
+	}
+	if len(r.PhotoIDs) > MaxBulkPhotos {
+		return fmt.Errorf("at most %d photos can be changed at once", MaxBulkPhotos)
+	}
+	return nil
+}
+
+// BulkMoveRequest moves photos to another project
+type BulkMoveRequest struct {
+	BulkPhotosRequest
+	ProjectID uint `json:"project_id" binding:"required"` // Target project
+}
@@ -0,0 +1,23 @@
+package models
+
+// PhotoExif is the EXIF summary of a photo, read once when its file is uploaded (or by
+// the startup backfill) and stored in exif_* columns. The capture time is Photo.TakenAt.
+// Descriptive settings (orientation, flash, ...) keep the wording the EXIF endpoints show.
+type PhotoExif struct {
+	Scanned      bool     `gorm:"index;default:false" json:"-"` // false until the files were read
+	CameraMake   string   `gorm:"size:64" json:"camera_make,omitempty"`
+	CameraModel  string   `gorm:"size:64" json:"camera_model,omitempty"`
+	LensModel    string   `gorm:"size:128" json:"lens_model,omitempty"`
+	Software     string   `gorm:"size:128" json:"software,omitempty"`
+	FocalLength  float64  `json:"focal_length,omitempty"`                 // mm
+	Aperture     float64  `json:"aperture,omitempty"`                     // f-number
+	ExposureTime string   `gorm:"size:16" json:"exposure_time,omitempty"` // seconds, "1/250" or "2.5"
+	ISO          int      `json:"iso,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	Orientation  string   `gorm:"size:32" json:"orientation,omitempty"`
+	ExposureMode string   `gorm:"size:32" json:"exposure_mode,omitempty"`
+	WhiteBalance string   `gorm:"size:32" json:"white_balance,omitempty"`
+	Flash        string   `gorm:"size:32" json:"flash,omitempty"`
+	MeteringMode string   `gorm:"size:32" json:"metering_mode,omitempty"`
+}
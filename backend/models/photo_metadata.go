@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	// MaxPhotoRating is the highest star rating of a photo (0 is unrated)
+	MaxPhotoRating = 5
+	// MaxCaptionLength limits a photo caption (characters)
+	MaxCaptionLength = 2000
+	// MaxPhotoTags limits the tags set on a photo by one metadata row
+	MaxPhotoTags = 50
+	// MaxMetadataCSVSize limits an uploaded metadata CSV
+	MaxMetadataCSVSize = 10 << 20
+)
+
+// PhotoMetadataRow is one row of a metadata import: the photo by base name and the
+// values to apply. Nil values (empty cells or missing columns) leave the photo's
+// value unchanged.
+type PhotoMetadataRow struct {
+	Line     int      // Line in the CSV, for error messages
+	BaseName string   // File name without extension
+	Tags     []string // Normalized tag names
+	Rating   *int
+	Caption  *string
+}
+
+// Validate checks the rating range, the caption length and the tags of the row
+func (r PhotoMetadataRow) Validate() error {
+	if r.BaseName == "" {
+		return fmt.Errorf("line %d: file name is empty", r.Line)
+	}
+	if r.Rating != nil && (*r.Rating < 0 || *r.Rating > MaxPhotoRating) {
+		return fmt.Errorf("line %d: rating must be between 0 and %d", r.Line, MaxPhotoRating)
+	}
+	if r.Caption != nil && utf8.RuneCountInString(*r.Caption) > MaxCaptionLength {
+		return fmt.Errorf("line %d: caption is longer than %d characters", r.Line, MaxCaptionLength)
+	}
+	if len(r.Tags) > MaxPhotoTags {
+		return fmt.Errorf("line %d: at most %d tags per photo", r.Line, MaxPhotoTags)
+	}
+	for _, tag := range r.Tags {
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return fmt.Errorf("line %d: tag %q is longer than %d characters", r.Line, tag, MaxTagLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Sort keys of a photo listing
+const (
+	PhotoSortName      = "name"       // base name
+	PhotoSortCreatedAt = "created_at" // upload time
+	PhotoSortTakenAt   = "taken_at"   // capture time, falling back to upload time
+)
+
+// PhotoGroupDay groups a photo listing by capture day
+const PhotoGroupDay = "day"
+
+// File kinds a photo listing can be filtered by
+const (
+	PhotoKindRawOnly    = "raw_only"    // RAW file without a JPEG/HEIC
+	PhotoKindWithRaw    = "with_raw"    // has a RAW file
+	PhotoKindWithoutRaw = "without_raw" // has no RAW file
+)
+
+const (
+	// DefaultPhotoPageSize is the page size when only page is given
+	DefaultPhotoPageSize = 100
+	// MaxPhotoPageSize limits the photos returned by one page
+	MaxPhotoPageSize = 1000
+)
+
+// PhotoListQuery pages, sorts and filters a photo listing (query parameters). Without
+// page and per_page every matching photo is returned, as before paging existed.
+type PhotoListQuery struct {
+	Page      int        `form:"page"`                                // 1-based
+	PerPage   int        `form:"per_page"`                            // default DefaultPhotoPageSize
+	Sort      string     `form:"sort"`                                // name, created_at or taken_at; default: upload order
+	Order     string     `form:"order"`                               // asc (default) or desc
+	Name      string     `form:"name"`                                // case-insensitive part of the base name
+	Kind      string     `form:"kind"`                                // raw_only, with_raw or without_raw
+	TakenFrom *time.Time `form:"taken_from" time_format:"2006-01-02"` // first capture day, inclusive
+	TakenTo   *time.Time `form:"taken_to" time_format:"2006-01-02"`   // last capture day, inclusive
+	GroupBy   string     `form:"group_by"`                            // day: grouped by capture day, in capture order
+	AlbumID   *uint      `form:"album_id"`                            // photos of an album; 0: photos in no album
+}
+
+// Validate checks the paging bounds and the sort and filter values
+func (q PhotoListQuery) Validate() error {
+	if q.Page < 0 {
+		return fmt.Errorf("page must be at least 1")
+	}
+	if q.PerPage < 0 || q.PerPage > MaxPhotoPageSize {
+		return fmt.Errorf("per_page must be between 1 and %d", MaxPhotoPageSize)
+	}
+	switch q.Sort {
+	case "", PhotoSortName, PhotoSortCreatedAt, PhotoSortTakenAt:
+	default:
+		return fmt.Errorf("sort must be %q, %q or %q", PhotoSortName, PhotoSortCreatedAt, PhotoSortTakenAt)
+	}
+	if q.Order != "" && q.Order != "asc" && q.Order != "desc" {
+		return fmt.Errorf("order must be \"asc\" or \"desc\"")
+	}
+	switch q.Kind {
+	case "", PhotoKindRawOnly, PhotoKindWithRaw, PhotoKindWithoutRaw:
+	default:
+		return fmt.Errorf("kind must be %q, %q or %q", PhotoKindRawOnly, PhotoKindWithRaw, PhotoKindWithoutRaw)
+	}
+	switch q.GroupBy {
+	case "":
+	case PhotoGroupDay:
+		if q.Sort != "" && q.Sort != PhotoSortTakenAt {
+			return fmt.Errorf("group_by=%s sorts by %q", PhotoGroupDay, PhotoSortTakenAt)
+		}
+	default:
+		return fmt.Errorf("group_by must be %q", PhotoGroupDay)
+	}
+	if q.TakenFrom != nil && q.TakenTo != nil && q.TakenTo.Before(*q.TakenFrom) {
+		return fmt.Errorf("taken_to must not be before taken_from")
+	}
+	return nil
+}
+
+const (
+	// MaxPhotoSearchLength limits the search text of a photo search
+	MaxPhotoSearchLength = 200
+	// MaxPhotoSearchTerms limits the words of a photo search
+	MaxPhotoSearchTerms = 10
+)
+
+// PhotoSearchQuery searches photos across projects: every word of Q must appear in the
+// base name, a tag, the camera or the lens. The listing parameters apply as well;
+// searches are always paged.
+type PhotoSearchQuery struct {
+	PhotoListQuery
+	Q         string `form:"q"`
+	ProjectID uint   `form:"project_id"` // limits the search to one project
+}
+
+// Validate checks the search text and the listing parameters
+func (q *PhotoSearchQuery) Validate() error {
+	q.Q = strings.TrimSpace(q.Q)
+	switch {
+	case q.Q == "":
+		return fmt.Errorf("q is required")
+	case utf8.RuneCountInString(q.Q) > MaxPhotoSearchLength:
+		return fmt.Errorf("q must be at most %d characters", MaxPhotoSearchLength)
+	case len(q.Terms()) > MaxPhotoSearchTerms:
+		return fmt.Errorf("q must have at most %d words", MaxPhotoSearchTerms)
+	}
+	if q.GroupBy != "" {
+		return fmt.Errorf("search results can't be grouped")
+	}
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	return q.PhotoListQuery.Validate()
+}
+
+// Terms returns the words of the search text
+func (q PhotoSearchQuery) Terms() []string {
+	return strings.Fields(q.Q)
+}
+
+// Paged reports whether the query asks for one page instead of every photo
+func (q PhotoListQuery) Paged() bool {
+	return q.Page > 0 || q.PerPage > 0
+}
+
+// Limit returns the page size and the number of photos to skip (only when Paged)
+func (q PhotoListQuery) Limit() (perPage, offset int) {
+	page, perPage := max(q.Page, 1), q.PerPage
+	if perPage == 0 {
+		perPage = DefaultPhotoPageSize
+	}
+	return perPage, (page - 1) * perPage
+}
@@ -1,6 +1,10 @@
 package models
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestIsRawExtension(t *testing.T) {
 	tests := []struct {
@@ -57,6 +61,8 @@ func TestIsImageExtension(t *testing.T) {
 		{"BMP", ".bmp", true},
 		{"TIFF", ".tiff", true},
 		{"TIF", ".tif", true},
+		{"HEIC", ".heic", true},
+		{"HEIF", ".heif", true},
 
 		// Non-image formats
 		{"CR2", ".cr2", false},
@@ -76,3 +82,241 @@ func TestIsImageExtension(t *testing.T) {
 		})
 	}
 }
+
+func TestPhotoListQueryValidate(t *testing.T) {
+	day := time.Date(2026, 5, 2, 0, 0, 0, 0, time.UTC)
+	before := day.AddDate(0, 0, -1)
+	tests := []struct {
+		name    string
+		query   PhotoListQuery
+		wantErr bool
+	}{
+		{"Everything", PhotoListQuery{}, false},
+		{"Page sorted by capture time", PhotoListQuery{Page: 3, PerPage: 50, Sort: PhotoSortTakenAt, Order: "desc"}, false},
+		{"One capture day", PhotoListQuery{Kind: PhotoKindRawOnly, TakenFrom: &day, TakenTo: &day}, false},
+		{"Negative page", PhotoListQuery{Page: -1}, true},
+		{"Page too large", PhotoListQuery{PerPage: MaxPhotoPageSize + 1}, true},
+		{"Unknown sort", PhotoListQuery{Sort: "size"}, true},
+		{"Unknown order", PhotoListQuery{Order: "random"}, true},
+		{"Unknown kind", PhotoListQuery{Kind: "heic"}, true},
+		{"Reversed days", PhotoListQuery{TakenFrom: &day, TakenTo: &before}, true},
+		{"Grouped by day", PhotoListQuery{GroupBy: PhotoGroupDay, Order: "desc"}, false},
+		{"Grouped by day, sorted by name", PhotoListQuery{GroupBy: PhotoGroupDay, Sort: PhotoSortName}, true},
+		{"Unknown grouping", PhotoListQuery{GroupBy: "month"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.query.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if perPage, offset := (PhotoListQuery{Page: 3}).Limit(); perPage != DefaultPhotoPageSize || offset != 2*DefaultPhotoPageSize {
+		t.Errorf("Expected page 3 of %d, got %d photos from %d", DefaultPhotoPageSize, perPage, offset)
+	}
+}
+
+func TestPhotoMetadataRowValidate(t *testing.T) {
+	rating := func(n int) *int { return &n }
+	caption := strings.Repeat("字", MaxCaptionLength)
+	longCaption := caption + "!"
+	tests := []struct {
+		name    string
+		row     PhotoMetadataRow
+		wantErr bool
+	}{
+		{"Tags only", PhotoMetadataRow{BaseName: "IMG_0001", Tags: []string{"bride"}}, false},
+		{"Unrated", PhotoMetadataRow{BaseName: "IMG_0001", Rating: rating(0)}, false},
+		{"Longest caption", PhotoMetadataRow{BaseName: "IMG_0001", Rating: rating(MaxPhotoRating), Caption: &caption}, false},
+		{"No name", PhotoMetadataRow{Rating: rating(3)}, true},
+		{"Negative rating", PhotoMetadataRow{BaseName: "IMG_0001", Rating: rating(-1)}, true},
+		{"Rating too high", PhotoMetadataRow{BaseName: "IMG_0001", Rating: rating(MaxPhotoRating + 1)}, true},
+		{"Caption too long", PhotoMetadataRow{BaseName: "IMG_0001", Caption: &longCaption}, true},
+		{"Tag too long", PhotoMetadataRow{BaseName: "IMG_0001", Tags: []string{strings.Repeat("a", MaxTagLength+1)}}, true},
+		{"Too many tags", PhotoMetadataRow{BaseName: "IMG_0001", Tags: make([]string, MaxPhotoTags+1)}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.row.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if got := NormalizeTag("  first \t dance "); got != "first dance" {
+		t.Errorf("NormalizeTag() = %q, want %q", got, "first dance")
+	}
+}
+
+func TestContactSheetQueryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   ContactSheetQuery
+		wantErr bool
+	}{
+		{"Whole project", ContactSheetQuery{}, false},
+		{"Some photos in 4 columns", ContactSheetQuery{PhotoIDs: []uint{1, 2}, Columns: 4}, false},
+		{"Link selection", ContactSheetQuery{LinkID: 3, Columns: MaxContactSheetColumns}, false},
+		{"One column", ContactSheetQuery{Columns: 1}, true},
+		{"Too many columns", ContactSheetQuery{Columns: MaxContactSheetColumns + 1}, true},
+		{"Photos and link", ContactSheetQuery{PhotoIDs: []uint{1}, LinkID: 3}, true},
+		{"Too many photos", ContactSheetQuery{PhotoIDs: make([]uint, MaxContactSheetPhotos+1)}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.query.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if columns := (ContactSheetQuery{}).GridColumns(); columns != DefaultContactSheetColumns {
+		t.Errorf("Expected %d columns by default, got %d", DefaultContactSheetColumns, columns)
+	}
+}
+
+func TestAPIKeyRequestValidate(t *testing.T) {
+	projectID, zero := uint(3), uint(0)
+	tests := []struct {
+		name    string
+		req     APIKeyRequest
+		wantErr bool
+	}{
+		{"Upload key", APIKeyRequest{Name: "Lightroom", Permission: APIKeyUpload}, false},
+		{"Read key of a project", APIKeyRequest{Name: "Website", Permission: APIKeyRead, ProjectID: &projectID}, false},
+		{"Blank name", APIKeyRequest{Name: "  ", Permission: APIKeyRead}, true},
+		{"Name too long", APIKeyRequest{Name: strings.Repeat("a", MaxAPIKeyNameLength+1), Permission: APIKeyRead}, true},
+		{"Unknown permission", APIKeyRequest{Name: "Script", Permission: "admin"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	req := APIKeyRequest{Name: " Script ", Permission: APIKeyRead, ProjectID: &zero}
+	if err := req.Validate(); err != nil || req.Name != "Script" || req.ProjectID != nil {
+		t.Errorf("Expected a trimmed name and no project, got %+v (%v)", req, err)
+	}
+}
+
+func TestAlbumRequestValidate(t *testing.T) {
+	name := func(s string) *string { return &s }
+	position := func(p int) *int { return &p }
+	tests := []struct {
+		name    string
+		req     AlbumRequest
+		create  bool
+		wantErr bool
+	}{
+		{"New album", AlbumRequest{Name: name("Ceremony")}, true, false},
+		{"New album without a name", AlbumRequest{Position: position(1)}, true, true},
+		{"Move only", AlbumRequest{Position: position(2)}, false, false},
+		{"Blank name", AlbumRequest{Name: name("  ")}, false, true},
+		{"Name too long", AlbumRequest{Name: name(strings.Repeat("a", MaxAlbumNameLength+1))}, true, true},
+		{"Negative position", AlbumRequest{Name: name("Reception"), Position: position(-1)}, true, true},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Validate(tt.create); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	req := AlbumRequest{Name: name("  First   dance ")}
+	if err := req.Validate(true); err != nil || *req.Name != "First dance" {
+		t.Errorf("Expected a normalized name, got %q (%v)", *req.Name, err)
+	}
+}
+
+func TestTagPhotosRequestValidate(t *testing.T) {
+	photos := BulkPhotosRequest{PhotoIDs: []uint{1, 2}}
+	tests := []struct {
+		name    string
+		req     TagPhotosRequest
+		wantErr bool
+	}{
+		{"Tags", TagPhotosRequest{BulkPhotosRequest: photos, Tags: []string{"Bride", "Vows"}}, false},
+		{"No photos", TagPhotosRequest{Tags: []string{"Bride"}}, true},
+		{"No tags", TagPhotosRequest{BulkPhotosRequest: photos}, true},
+		{"Blank tags only", TagPhotosRequest{BulkPhotosRequest: photos, Tags: []string{" ", ""}}, true},
+		{"Tag too long", TagPhotosRequest{BulkPhotosRequest: photos, Tags: []string{strings.Repeat("a", MaxTagLength+1)}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	req := TagPhotosRequest{BulkPhotosRequest: photos, Tags: []string{" First  dance", "", "first dance", "Bride"}}
+	if err := req.Validate(); err != nil || len(req.Tags) != 2 || req.Tags[0] != "First dance" || req.Tags[1] != "Bride" {
+		t.Errorf("Expected normalized tags without repeats, got %q (%v)", req.Tags, err)
+	}
+}
+
+func TestPhotoSearchQueryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       PhotoSearchQuery
+		wantErr bool
+	}{
+		{"Search", PhotoSearchQuery{Q: "canon bride"}, false},
+		{"Blank", PhotoSearchQuery{Q: "   "}, true},
+		{"Too long", PhotoSearchQuery{Q: strings.Repeat("a", MaxPhotoSearchLength+1)}, true},
+		{"Too many words", PhotoSearchQuery{Q: strings.Repeat("a ", MaxPhotoSearchTerms+1)}, true},
+		{"Grouped", PhotoSearchQuery{Q: "bride", PhotoListQuery: PhotoListQuery{GroupBy: PhotoGroupDay}}, true},
+		{"Bad sort", PhotoSearchQuery{Q: "bride", PhotoListQuery: PhotoListQuery{Sort: "size"}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.q.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	q := PhotoSearchQuery{Q: "  Sony   85mm "}
+	if err := q.Validate(); err != nil || q.Page != 1 || len(q.Terms()) != 2 || q.Terms()[1] != "85mm" {
+		t.Errorf("Expected a paged search for two words, got %+v %q (%v)", q, q.Terms(), err)
+	}
+}
+
+func TestImageQueryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   ImageQuery
+		wantErr bool
+	}{
+		{"Defaults", ImageQuery{}, false},
+		{"Small JPEG", ImageQuery{Size: ImageSizeSmall, Format: ImageFormatJPEG}, false},
+		{"Original as uploaded", ImageQuery{Size: ImageSizeOriginal}, false},
+		{"Original RAW", ImageQuery{Size: ImageSizeOriginal, Format: ImageFormatRaw}, false},
+		{"RAW thumbnail", ImageQuery{Size: ImageSizeSmall, Format: ImageFormatRaw}, true},
+		{"Unknown size", ImageQuery{Size: "4k"}, true},
+		{"Unknown format", ImageQuery{Size: ImageSizeOriginal, Format: "webp"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.query.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	query := ImageQuery{}
+	if query.Validate(); query.Size != ImageSizeLarge || query.Format != ImageFormatAuto {
+		t.Errorf("Expected a large auto image by default, got %+v", query)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := ParseFields(" id, base_name,id,,thumb_small_url ", ReadPhotoFields)
+	if err != nil || strings.Join(fields, ",") != "id,base_name,thumb_small_url" {
+		t.Errorf("Expected id,base_name,thumb_small_url, got %v (%v)", fields, err)
+	}
+	if fields, err := ParseFields("", ReadProjectFields); err != nil || len(fields) != len(ReadProjectFields) {
+		t.Errorf("Expected every field by default, got %v (%v)", fields, err)
+	}
+	for _, value := range []string{"id,file_hash", ",", "ID"} {
+		if _, err := ParseFields(value, ReadPhotoFields); err == nil {
+			t.Errorf("Fields %q: expected an error", value)
+		}
+	}
+
+	if err := (ReadPhotoQuery{PhotoListQuery: PhotoListQuery{Sort: "size"}}).Validate(); err == nil {
+		t.Error("Expected the listing parameters to be checked")
+	}
+	if err := (ReadProjectQuery{PerPage: MaxPhotoPageSize + 1}).Validate(); err == nil {
+		t.Error("Expected per_page to be limited")
+	}
+}
@@ -7,15 +7,20 @@ import (
 )
 
 type Project struct {
-	ID          uint           `gorm:"primarykey" json:"id"`
-	Name        string         `gorm:"uniqueIndex;size:255;not null" json:"name"`
-	Description string         `gorm:"type:text" json:"description"`
-	CoverPhoto  string         `gorm:"size:255" json:"cover_photo"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
-	Photos      []Photo        `gorm:"foreignKey:ProjectID" json:"photos,omitempty"`
-	ShareLinks  []ShareLink    `gorm:"foreignKey:ProjectID" json:"share_links,omitempty"`
+	ID               uint           `gorm:"primarykey" json:"id"`
+	Name             string         `gorm:"uniqueIndex;size:255;not null" json:"name"`
+	Description      string         `gorm:"type:text" json:"description"`
+	CoverPhoto       string         `gorm:"size:255" json:"cover_photo"`
+	NotifyWebhookURL string         `gorm:"size:1024" json:"notify_webhook_url"`     // Overrides NOTIFY_WEBHOOK_URL for this project's events
+	NotifyAlsoGlobal bool           `gorm:"default:false" json:"notify_also_global"` // Send this project's events to NOTIFY_WEBHOOK_URL as well
+	Sensitive        bool           `gorm:"default:false" json:"sensitive"`          // Log every access to original files (see PhotoAccess)
+	QuotaMB          int            `gorm:"default:0" json:"quota_mb"`               // Storage limit of its photo files (0 = unlimited)
+	Version          uint           `gorm:"not null;default:1" json:"version"`       // Incremented by every change, sent back in If-Match
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+	Photos           []Photo        `gorm:"foreignKey:ProjectID" json:"photos,omitempty"`
+	ShareLinks       []ShareLink    `gorm:"foreignKey:ProjectID" json:"share_links,omitempty"`
 }
 
 type CreateProjectRequest struct {
@@ -24,7 +29,11 @@ type CreateProjectRequest struct {
 }
 
 type UpdateProjectRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	CoverPhoto  string `json:"cover_photo"`
+	Name             string  `json:"name"`
+	Description      string  `json:"description"`
+	CoverPhoto       string  `json:"cover_photo"`
+	NotifyWebhookURL *string `json:"notify_webhook_url"` // "" clears the override
+	NotifyAlsoGlobal *bool   `json:"notify_also_global"`
+	Sensitive        *bool   `json:"sensitive"`
+	QuotaMB          *int    `json:"quota_mb"` // 0 removes the quota
 }
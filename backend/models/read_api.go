@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fields of the read API (/api/v1) a client can pick with ?fields=
+var (
+	ReadProjectFields = []string{
+		"id", "name", "description", "cover_photo", "cover_url", "photo_count", "created_at", "updated_at",
+	}
+	ReadPhotoFields = []string{
+		"id", "project_id", "base_name", "normal_ext", "raw_ext", "has_raw", "width", "height",
+		"thumb_width", "thumb_height", "normal_size", "raw_size", "taken_at", "created_at", "updated_at",
+		"rating", "caption", "tags", "exif", "thumb_small_url", "thumb_large_url", "original_url", "raw_url",
+	}
+)
+
+// ReadProjectQuery pages the project list of the read API and picks its fields
+type ReadProjectQuery struct {
+	Page    int    `form:"page"`     // 1-based, default 1
+	PerPage int    `form:"per_page"` // default DefaultPhotoPageSize
+	Fields  string `form:"fields"`   // comma-separated, default every field
+}
+
+// Validate checks the paging bounds and the field names
+func (q ReadProjectQuery) Validate() error {
+	if err := (PhotoListQuery{Page: q.Page, PerPage: q.PerPage}).Validate(); err != nil {
+		return err
+	}
+	_, err := ParseFields(q.Fields, ReadProjectFields)
+	return err
+}
+
+// Limit returns the page size and offset, paging from the first page by default
+func (q ReadProjectQuery) Limit() (perPage, offset int) {
+	return PhotoListQuery{Page: q.Page, PerPage: q.PerPage}.Limit()
+}
+
+// ReadPhotoQuery pages, sorts and filters the photos of the read API (see
+// PhotoListQuery) and picks their fields. Unlike the admin listing it is always paged.
+type ReadPhotoQuery struct {
+	PhotoListQuery
+	Fields string `form:"fields"` // comma-separated, default every field
+}
+
+// Validate checks the listing parameters and the field names
+func (q ReadPhotoQuery) Validate() error {
+	if err := q.PhotoListQuery.Validate(); err != nil {
+		return err
+	}
+	_, err := ParseFields(q.Fields, ReadPhotoFields)
+	return err
+}
+
+// ParseFields splits a ?fields= value, checking each name against allowed. An empty
+// value selects every allowed field.
+func ParseFields(value string, allowed []string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return allowed, nil
+	}
+	known := make(map[string]bool, len(allowed))
+	for _, field := range allowed {
+		known[field] = true
+	}
+	var fields []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] {
+			continue
+		}
+		if !known[field] {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields must name at least one field")
+	}
+	return fields, nil
+}
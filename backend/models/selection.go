@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// PhotoSelection marks a photo the client picked on a share link (proofing)
+type PhotoSelection struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	LinkID    uint      `gorm:"uniqueIndex:idx_selection_link_photo;not null" json:"link_id"`
+	PhotoID   uint      `gorm:"uniqueIndex:idx_selection_link_photo;index;not null" json:"photo_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UpdateSelectionsRequest adds and removes photos from a link's selection
+type UpdateSelectionsRequest struct {
+	Add    []uint `json:"add"`
+	Remove []uint `json:"remove"`
+}
+
+// MaxSelectionChanges limits the photos changed by one selection request
+const MaxSelectionChanges = 1000
+
+// Validate checks that the request changes something and not too much at once
+func (r UpdateSelectionsRequest) Validate() error {
+	if len(r.Add) == 0 && len(r.Remove) == 0 {
+		return fmt.Errorf("add or remove at least one photo")
+	}
+	if len(r.Add)+len(r.Remove) > MaxSelectionChanges {
+		return fmt.Errorf("at most %d photos can be changed at once", MaxSelectionChanges)
+	}
+	return nil
+}
+
+// MaxSubmitNoteLength limits the note sent with submitted selections
+const MaxSubmitNoteLength = 2000
+
+// SubmitSelectionsRequest tells the photographer the client has finished picking
+type SubmitSelectionsRequest struct {
+	Note string `json:"note"` // Optional message to the photographer
+}
+
+// Validate checks the note length
+func (r SubmitSelectionsRequest) Validate() error {
+	if utf8.RuneCountInString(r.Note) > MaxSubmitNoteLength {
+		return fmt.Errorf("note must be at most %d characters", MaxSubmitNoteLength)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Names of settings kept by the server itself
+const (
+	SettingLinkSummarySentAt = "link_sweeper.summary_sent_at" // RFC 3339 time of the last weekly retired-link summary
+)
+
+// Setting is a value the server keeps across restarts, such as when it last ran a
+// periodic job
+type Setting struct {
+	Name      string    `gorm:"primarykey;size:64" json:"name"`
+	Value     string    `gorm:"size:1024;not null" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,35 +1,370 @@
 package models
 
 import (
+	"fmt"
+	"net/mail"
 	"time"
+	"unicode/utf8"
 
 	"gorm.io/gorm"
 )
 
 type ShareLink struct {
-	ID              uint              `gorm:"primarykey" json:"id"`
-	ProjectID       uint              `gorm:"index;not null" json:"project_id"`
-	Token           string            `gorm:"uniqueIndex;size:64;not null" json:"token"`
-	Alias           string            `gorm:"size:255" json:"alias"`
-	AllowRaw        bool              `gorm:"default:true" json:"allow_raw"`
-	PasswordEnabled bool              `json:"password_enabled"`
-	Password        string            `gorm:"size:4" json:"password"`
-	CreatedAt       time.Time         `json:"created_at"`
-	DeletedAt       gorm.DeletedAt    `gorm:"index" json:"-"`
-	Project         Project           `gorm:"foreignKey:ProjectID" json:"-"`
-	Exclusions      []PhotoExclusion  `gorm:"foreignKey:LinkID" json:"exclusions,omitempty"`
+	ID              uint             `gorm:"primarykey" json:"id"`
+	ProjectID       uint             `gorm:"index;not null" json:"project_id"`
+	Token           string           `gorm:"uniqueIndex;size:64;not null" json:"token"`
+	Alias           string           `gorm:"size:255" json:"alias"`
+	AllowRaw        bool             `gorm:"default:true" json:"allow_raw"`
+	AllowDownload   bool             `gorm:"default:true" json:"allow_download"` // false = view-only, no downloads at all
+	AllowZip        bool             `gorm:"default:true" json:"allow_zip"`      // Download-all and selection archives
+	MaxDownloads    int              `json:"max_downloads"`                      // Downloads the link allows (0 = unlimited)
+	DownloadCount   int              `gorm:"not null;default:0" json:"download_count"`
+	PasswordEnabled bool             `json:"password_enabled"`
+	PasswordHash    string           `gorm:"size:72" json:"-"`          // bcrypt; the password is only returned once, see ShareLinkWithPassword
+	ExpiresAt       *time.Time       `gorm:"index" json:"expires_at"`   // nil = never expires
+	FromDate        *time.Time       `json:"from_date"`                 // Only photos at or after this time (nil = no lower bound)
+	ToDate          *time.Time       `json:"to_date"`                   // Only photos before this time (nil = no upper bound)
+	DateBasis       string           `gorm:"size:16" json:"date_basis"` // DateBasisCaptured or DateBasisUploaded
+	Locale          string           `gorm:"size:16" json:"locale"`     // Gallery language override (empty = suggested per visitor)
+	Preferences     LinkPreferences  `gorm:"type:text;serializer:json" json:"preferences"`
+	Version         uint             `gorm:"not null;default:1" json:"version"` // Incremented by every change, sent back in If-Match
+	SubmittedAt     *time.Time       `json:"submitted_at"`                      // When the client last submitted their selections (nil = never)
+	CreatedAt       time.Time        `json:"created_at"`
+	DeletedAt       gorm.DeletedAt   `gorm:"index" json:"-"`
+	Project         Project          `gorm:"foreignKey:ProjectID" json:"-"`
+	Exclusions      []PhotoExclusion `gorm:"foreignKey:LinkID" json:"exclusions,omitempty"`
+	RawExclusions   []RawExclusion   `gorm:"foreignKey:LinkID" json:"raw_exclusions,omitempty"`
+	Highlights      []PhotoHighlight `gorm:"foreignKey:LinkID" json:"highlights,omitempty"`
+	Selections      []PhotoSelection `gorm:"foreignKey:LinkID" json:"selections,omitempty"` // Photos the client picked
+}
+
+// Gallery presentation options
+const (
+	LayoutGrid    = "grid"
+	LayoutMasonry = "masonry"
+	ThemeLight    = "light"
+	ThemeDark     = "dark"
+	ThemeAuto     = "auto" // follow the visitor's system setting
+)
+
+// LinkPreferences tailor how the share gallery presents a link; empty values use the defaults
+type LinkPreferences struct {
+	Layout     string `json:"layout,omitempty"`      // LayoutGrid (default) or LayoutMasonry
+	Theme      string `json:"theme,omitempty"`       // ThemeLight (default), ThemeDark or ThemeAuto
+	CoverFirst bool   `json:"cover_first,omitempty"` // show the project cover above the photos
+	Proofing   bool   `json:"proofing,omitempty"`    // let visitors pick favorites (see PhotoSelection)
+	Comments   bool   `json:"comments,omitempty"`    // let visitors comment on photos (see PhotoComment)
+}
+
+// Validate checks the layout and theme values
+func (p LinkPreferences) Validate() error {
+	if p.Layout != "" && p.Layout != LayoutGrid && p.Layout != LayoutMasonry {
+		return fmt.Errorf("layout must be %q or %q", LayoutGrid, LayoutMasonry)
+	}
+	if p.Theme != "" && p.Theme != ThemeLight && p.Theme != ThemeDark && p.Theme != ThemeAuto {
+		return fmt.Errorf("theme must be %q, %q or %q", ThemeLight, ThemeDark, ThemeAuto)
+	}
+	return nil
+}
+
+// Date range bases: capture time (EXIF, falling back to upload time) or upload time
+const (
+	DateBasisCaptured = "captured"
+	DateBasisUploaded = "uploaded"
+)
+
+// ShareLinkWithPassword is returned when a password is generated; it is never included in listings
+type ShareLinkWithPassword struct {
+	ShareLink
+	Password string `json:"password,omitempty"`
 }
 
 type CreateShareLinkRequest struct {
-	Alias           string `json:"alias"`
-	AllowRaw        bool   `json:"allow_raw"`
-	PasswordEnabled bool   `json:"password_enabled"`
-	Exclusions      []uint `json:"exclusions"`
+	Alias           string          `json:"alias"`
+	AllowRaw        bool            `json:"allow_raw"`
+	AllowDownload   *bool           `json:"allow_download"` // default true
+	AllowZip        *bool           `json:"allow_zip"`      // default true
+	MaxDownloads    int             `json:"max_downloads"`
+	PasswordEnabled bool            `json:"password_enabled"`
+	Password        string          `json:"password"` // Chosen password; empty generates one
+	Exclusions      []uint          `json:"exclusions"`
+	ExclusionReason string          `json:"exclusion_reason"` // Recorded on the initial exclusions
+	ExclusionNote   string          `json:"exclusion_note"`
+	RawExclusions   []uint          `json:"raw_exclusions"` // Photos whose RAW file is hidden
+	Highlights      []uint          `json:"highlights"`     // Ordered photo IDs for the hero strip
+	ExpiresAt       *time.Time      `json:"expires_at"`
+	FromDate        *time.Time      `json:"from_date"`
+	ToDate          *time.Time      `json:"to_date"`
+	DateBasis       string          `json:"date_basis"` // "captured" (default) or "uploaded"
+	Locale          string          `json:"locale"`     // e.g. "en"; empty suggests a language per visitor
+	Preferences     LinkPreferences `json:"preferences"`
+	SharePasswordOptions
 }
 
 type UpdateShareLinkRequest struct {
-	Alias           string `json:"alias"`
-	AllowRaw        *bool  `json:"allow_raw"`
-	PasswordEnabled *bool  `json:"password_enabled"`
-	Exclusions      []uint `json:"exclusions"`
+	Alias           string           `json:"alias"`
+	AllowRaw        *bool            `json:"allow_raw"`
+	AllowDownload   *bool            `json:"allow_download"`
+	AllowZip        *bool            `json:"allow_zip"`
+	MaxDownloads    *int             `json:"max_downloads"`   // 0 removes the limit
+	ResetDownloads  bool             `json:"reset_downloads"` // start counting downloads from zero again
+	PasswordEnabled *bool            `json:"password_enabled"`
+	Password        string           `json:"password"` // Replaces the password and enables protection
+	Exclusions      []uint           `json:"exclusions"`
+	RawExclusions   []uint           `json:"raw_exclusions"` // nil keeps, empty clears
+	Highlights      []uint           `json:"highlights"`     // nil keeps, empty clears
+	ExpiresAt       *time.Time       `json:"expires_at"`     // zero time clears the expiry
+	FromDate        *time.Time       `json:"from_date"`      // zero time clears the bound
+	ToDate          *time.Time       `json:"to_date"`        // zero time clears the bound
+	DateBasis       *string          `json:"date_basis"`
+	Locale          *string          `json:"locale"`      // empty string clears the override
+	Preferences     *LinkPreferences `json:"preferences"` // replaces all preferences
+	SharePasswordOptions
+}
+
+// PatchExclusionsRequest adds and removes individual exclusions without replacing the whole set
+type PatchExclusionsRequest struct {
+	Add    []uint `json:"add"`
+	Remove []uint `json:"remove"`
+	Reason string `json:"reason"` // Category for the added photos; also re-categorizes ones already excluded
+	Note   string `json:"note"`
+}
+
+// Validate checks the reason and the note length
+func (r PatchExclusionsRequest) Validate() error {
+	if !ValidExclusionReason(r.Reason) {
+		return fmt.Errorf("reason must be %q, %q, %q or %q", ExclusionNotEdited, ExclusionDuplicate, ExclusionClientRequest, ExclusionOther)
+	}
+	if utf8.RuneCountInString(r.Note) > MaxExclusionNoteLength {
+		return fmt.Errorf("note must be at most %d characters", MaxExclusionNoteLength)
+	}
+	return nil
+}
+
+// LinkPhotoQuery lists the photos of a link's project (see PhotoListQuery), optionally
+// only the included or only the excluded ones
+type LinkPhotoQuery struct {
+	PhotoListQuery
+	Included *bool `form:"included"` // nil = both
+}
+
+// SetPhotoIncludedRequest includes a photo in a share link or excludes it
+type SetPhotoIncludedRequest struct {
+	Included *bool  `json:"included"`
+	Reason   string `json:"reason"` // Category of the exclusion, only when excluding
+	Note     string `json:"note"`
+}
+
+// Validate checks that included is given, and the reason and note like PatchExclusionsRequest
+func (r SetPhotoIncludedRequest) Validate() error {
+	if r.Included == nil {
+		return fmt.Errorf("included is required")
+	}
+	if *r.Included && (r.Reason != "" || r.Note != "") {
+		return fmt.Errorf("reason and note only apply when excluding a photo")
+	}
+	return PatchExclusionsRequest{Reason: r.Reason, Note: r.Note}.Validate()
+}
+
+// Kinds of files in a share link archive
+const (
+	DownloadNormal = "normal" // Normal images, or converted JPEGs of RAW-only photos
+	DownloadRaw    = "raw"    // RAW files, if the link allows them
+	DownloadAll    = "all"    // Both
+)
+
+// MaxDownloadSelection limits the photos of one selection download
+const MaxDownloadSelection = 1000
+
+// DownloadSelectionRequest downloads some photos of a share link as a zip
+type DownloadSelectionRequest struct {
+	PhotoIDs []uint `json:"photo_ids"`
+	Type     string `json:"type"` // DownloadNormal (default), DownloadRaw or DownloadAll
+}
+
+// Validate defaults the type and checks the photo count
+func (r *DownloadSelectionRequest) Validate() error {
+	if len(r.PhotoIDs) == 0 {
+		return fmt.Errorf("photo_ids must name at least one photo")
+	}
+	if len(r.PhotoIDs) > MaxDownloadSelection {
+		return fmt.Errorf("at most %d photos can be downloaded at once", MaxDownloadSelection)
+	}
+	if r.Type == "" {
+		r.Type = DownloadNormal
+	}
+	if r.Type != DownloadNormal && r.Type != DownloadRaw && r.Type != DownloadAll {
+		return fmt.Errorf("type must be %q, %q or %q", DownloadNormal, DownloadRaw, DownloadAll)
+	}
+	return nil
+}
+
+// DownloadJobRequest prepares the download-all archive of a share link in the background
+type DownloadJobRequest struct {
+	Type string `json:"type"` // DownloadNormal (default), DownloadRaw or DownloadAll
+	Part int    `json:"part"` // Volume of an archive split with ZIP_VOLUME_SIZE_MB, 0 = the whole archive
+}
+
+// Validate defaults the type and checks the part
+func (r *DownloadJobRequest) Validate() error {
+	if r.Type == "" {
+		r.Type = DownloadNormal
+	}
+	if r.Type != DownloadNormal && r.Type != DownloadRaw && r.Type != DownloadAll {
+		return fmt.Errorf("type must be %q, %q or %q", DownloadNormal, DownloadRaw, DownloadAll)
+	}
+	if r.Part < 0 {
+		return fmt.Errorf("part must not be negative")
+	}
+	return nil
+}
+
+// SharePasswordOptions shape a generated link password; zero values use
+// SHARE_PASSWORD_LENGTH and SHARE_PASSWORD_ALPHABET
+type SharePasswordOptions struct {
+	PasswordLength   int    `json:"password_length"`
+	PasswordAlphabet string `json:"password_alphabet"` // "digits" or "alphanumeric"
+}
+
+// SharePasswordRequest optionally chooses the new password of a link
+type SharePasswordRequest struct {
+	Password string `json:"password"` // empty generates one
+	SharePasswordOptions
+}
+
+// CloneShareLinkRequest overrides settings of the copied link; omitted fields are copied
+type CloneShareLinkRequest struct {
+	Alias           *string    `json:"alias"`            // default: source alias + " (copy)"
+	PasswordEnabled *bool      `json:"password_enabled"` // default: same as source
+	NewPassword     bool       `json:"new_password"`     // generate a new password instead of reusing the source's
+	ExpiresAt       *time.Time `json:"expires_at"`       // zero time clears the expiry
+	SharePasswordOptions
+}
+
+// MaxGalleryEmailRecipients limits the addresses of one gallery email
+const MaxGalleryEmailRecipients = 10
+
+// MaxGalleryEmailMessageLength limits the personal message of a gallery email
+const MaxGalleryEmailMessageLength = 2000
+
+// GalleryEmailRequest emails a client that their gallery is ready
+type GalleryEmailRequest struct {
+	To       []string `json:"to"`
+	Password string   `json:"password"` // Included in the email; must be the link's password
+	Message  string   `json:"message"`  // Personal note shown above the link
+	Locale   string   `json:"locale"`   // Language of the email (default: the link's, then zh-CN)
+}
+
+// Validate checks the message length and the recipients, reducing them to bare
+// addresses ("Ann <ann@example.com>" becomes "ann@example.com")
+func (r *GalleryEmailRequest) Validate() error {
+	if len(r.To) == 0 {
+		return fmt.Errorf("to must name at least one address")
+	}
+	if len(r.To) > MaxGalleryEmailRecipients {
+		return fmt.Errorf("at most %d recipients are allowed", MaxGalleryEmailRecipients)
+	}
+	for i, address := range r.To {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return fmt.Errorf("invalid address %q", address)
+		}
+		r.To[i] = parsed.Address
+	}
+	if utf8.RuneCountInString(r.Message) > MaxGalleryEmailMessageLength {
+		return fmt.Errorf("message must be at most %d characters", MaxGalleryEmailMessageLength)
+	}
+	return nil
+}
+
+// QR code formats and sizes (pixels) of a share link
+const (
+	QRCodeFormatPNG         = "png"
+	QRCodeFormatSVG         = "svg"
+	DefaultQRCodeSize       = 512
+	MinQRCodeSize           = 128
+	MaxQRCodeSize           = 2048
+	MaxQRCodePasswordLength = 72 // bcrypt reads no further
+)
+
+// LinkQRCodeRequest asks for a printable QR code of a share link's URL, in the query
+// of a GET or the JSON body of a POST. The password is only read from the body, so it
+// stays out of request logs.
+type LinkQRCodeRequest struct {
+	Format   string `form:"format" json:"format"` // QRCodeFormatPNG (default) or QRCodeFormatSVG
+	Size     int    `form:"size" json:"size"`     // Width in pixels, default DefaultQRCodeSize
+	Password string `form:"-" json:"password"`    // Put into the URL fragment for the gallery; must be the link's password
+}
+
+// Validate checks the format and size, filling in the defaults
+func (q *LinkQRCodeRequest) Validate() error {
+	if q.Format == "" {
+		q.Format = QRCodeFormatPNG
+	}
+	if q.Size == 0 {
+		q.Size = DefaultQRCodeSize
+	}
+	switch {
+	case q.Format != QRCodeFormatPNG && q.Format != QRCodeFormatSVG:
+		return fmt.Errorf("format must be %q or %q", QRCodeFormatPNG, QRCodeFormatSVG)
+	case q.Size < MinQRCodeSize || q.Size > MaxQRCodeSize:
+		return fmt.Errorf("size must be between %d and %d", MinQRCodeSize, MaxQRCodeSize)
+	case len(q.Password) > MaxQRCodePasswordLength:
+		return fmt.Errorf("password must be at most %d characters", MaxQRCodePasswordLength)
+	}
+	return nil
+}
+
+// IsExpired reports whether the link has passed its expiry time
+func (l *ShareLink) IsExpired() bool {
+	return l.ExpiresAt != nil && !l.ExpiresAt.IsZero() && time.Now().After(*l.ExpiresAt)
+}
+
+// DownloadLimitReached reports whether the link has used up its downloads
+func (l *ShareLink) DownloadLimitReached() bool {
+	return l.MaxDownloads > 0 && l.DownloadCount >= l.MaxDownloads
+}
+
+// DownloadsLeft returns how many downloads the link has left, nil without a limit
+func (l *ShareLink) DownloadsLeft() *int {
+	if l.MaxDownloads <= 0 {
+		return nil
+	}
+	left := max(l.MaxDownloads-l.DownloadCount, 0)
+	return &left
+}
+
+// PermissionColumns returns the link's download permissions as columns. Create skips
+// false values of columns with a default (and sets the fields to it), so they are
+// taken before a link is inserted and written again afterwards.
+func (l *ShareLink) PermissionColumns() map[string]interface{} {
+	return map[string]interface{}{
+		"allow_raw":      l.AllowRaw,
+		"allow_download": l.AllowDownload,
+		"allow_zip":      l.AllowZip,
+	}
+}
+
+// ValidateMaxDownloads checks a download limit
+func ValidateMaxDownloads(maxDownloads int) error {
+	if maxDownloads < 0 {
+		return fmt.Errorf("max_downloads must not be negative")
+	}
+	return nil
+}
+
+// HasDateRange reports whether the link only shows photos from a date range
+func (l *ShareLink) HasDateRange() bool {
+	return (l.FromDate != nil && !l.FromDate.IsZero()) || (l.ToDate != nil && !l.ToDate.IsZero())
+}
+
+// ValidateDateRange checks the date basis and that the range is not empty
+func ValidateDateRange(from, to *time.Time, basis string) error {
+	if basis != "" && basis != DateBasisCaptured && basis != DateBasisUploaded {
+		return fmt.Errorf("date_basis must be %q or %q", DateBasisCaptured, DateBasisUploaded)
+	}
+	if from != nil && to != nil && !from.IsZero() && !to.IsZero() && !from.Before(*to) {
+		return fmt.Errorf("from_date must be before to_date")
+	}
+	return nil
 }
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareLinkPasswordRedacted(t *testing.T) {
+	hash := "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+	link := ShareLink{ID: 1, Token: "abc", PasswordEnabled: true, PasswordHash: hash}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), hash) || strings.Contains(string(data), `"password"`) {
+		t.Errorf("Password hash must not be serialized: %s", data)
+	}
+
+	data, err = json.Marshal(ShareLinkWithPassword{ShareLink: link, Password: "1234"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"password":"1234"`) {
+		t.Errorf("ShareLinkWithPassword should include the password: %s", data)
+	}
+
+	data, _ = json.Marshal(ShareLinkWithPassword{ShareLink: link})
+	if strings.Contains(string(data), `"password"`) {
+		t.Errorf("Empty password should be omitted: %s", data)
+	}
+}
+
+func TestShareLinkIsExpired(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		expected  bool
+	}{
+		{"No expiry", nil, false},
+		{"Zero expiry", &time.Time{}, false},
+		{"Past", &past, true},
+		{"Future", &future, false},
+	}
+	for _, tt := range tests {
+		link := ShareLink{ExpiresAt: tt.expiresAt}
+		if got := link.IsExpired(); got != tt.expected {
+			t.Errorf("%s: IsExpired() = %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestShareLinkHasDateRange(t *testing.T) {
+	day := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	if (&ShareLink{}).HasDateRange() {
+		t.Error("Link without bounds should have no date range")
+	}
+	if (&ShareLink{FromDate: &time.Time{}, ToDate: &time.Time{}}).HasDateRange() {
+		t.Error("Zero bounds should be ignored")
+	}
+	if !(&ShareLink{FromDate: &day}).HasDateRange() || !(&ShareLink{ToDate: &day}).HasDateRange() {
+		t.Error("A single bound should define a date range")
+	}
+}
+
+func TestValidateDateRange(t *testing.T) {
+	from := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		from    *time.Time
+		to      *time.Time
+		basis   string
+		wantErr bool
+	}{
+		{"No range", nil, nil, "", false},
+		{"Valid range", &from, &to, DateBasisCaptured, false},
+		{"Open-ended", &from, nil, DateBasisUploaded, false},
+		{"Reversed", &to, &from, "", true},
+		{"Empty", &from, &from, "", true},
+		{"Cleared bound", &to, &time.Time{}, "", false},
+		{"Unknown basis", nil, nil, "modified", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateDateRange(tt.from, tt.to, tt.basis); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateDateRange() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLinkPreferencesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefs   LinkPreferences
+		wantErr bool
+	}{
+		{"Defaults", LinkPreferences{}, false},
+		{"Masonry dark", LinkPreferences{Layout: LayoutMasonry, Theme: ThemeDark, CoverFirst: true}, false},
+		{"Auto theme", LinkPreferences{Layout: LayoutGrid, Theme: ThemeAuto}, false},
+		{"Unknown layout", LinkPreferences{Layout: "list"}, true},
+		{"Unknown theme", LinkPreferences{Theme: "sepia"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.prefs.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPatchExclusionsRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     PatchExclusionsRequest
+		wantErr bool
+	}{
+		{"No reason", PatchExclusionsRequest{Add: []uint{1}}, false},
+		{"Known reason", PatchExclusionsRequest{Add: []uint{1}, Reason: ExclusionClientRequest, Note: "Asked by the bride"}, false},
+		{"Unknown reason", PatchExclusionsRequest{Reason: "blurry"}, true},
+		{"Long CJK note", PatchExclusionsRequest{Note: strings.Repeat("删", MaxExclusionNoteLength)}, false},
+		{"Note too long", PatchExclusionsRequest{Note: strings.Repeat("x", MaxExclusionNoteLength+1)}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSetPhotoIncludedRequestValidate(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name    string
+		req     SetPhotoIncludedRequest
+		wantErr bool
+	}{
+		{"Include", SetPhotoIncludedRequest{Included: &yes}, false},
+		{"Exclude", SetPhotoIncludedRequest{Included: &no}, false},
+		{"Exclude with reason", SetPhotoIncludedRequest{Included: &no, Reason: ExclusionDuplicate, Note: "Same as 0042"}, false},
+		{"Missing included", SetPhotoIncludedRequest{}, true},
+		{"Include with reason", SetPhotoIncludedRequest{Included: &yes, Reason: ExclusionOther}, true},
+		{"Unknown reason", SetPhotoIncludedRequest{Included: &no, Reason: "blurry"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.req.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDownloadSelectionRequestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      DownloadSelectionRequest
+		wantErr  bool
+		wantType string
+	}{
+		{"Default type", DownloadSelectionRequest{PhotoIDs: []uint{1, 2}}, false, DownloadNormal},
+		{"RAW", DownloadSelectionRequest{PhotoIDs: []uint{1}, Type: DownloadRaw}, false, DownloadRaw},
+		{"All", DownloadSelectionRequest{PhotoIDs: []uint{1}, Type: DownloadAll}, false, DownloadAll},
+		{"No photos", DownloadSelectionRequest{Type: DownloadAll}, true, ""},
+		{"Unknown type", DownloadSelectionRequest{PhotoIDs: []uint{1}, Type: "jpeg"}, true, ""},
+		{"Too many photos", DownloadSelectionRequest{PhotoIDs: make([]uint, MaxDownloadSelection+1)}, true, ""},
+	}
+	for _, tt := range tests {
+		err := tt.req.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && tt.req.Type != tt.wantType {
+			t.Errorf("%s: Type = %q, want %q", tt.name, tt.req.Type, tt.wantType)
+		}
+	}
+}
+
+func TestShareLinkDownloadLimit(t *testing.T) {
+	unlimited := ShareLink{DownloadCount: 100}
+	if unlimited.DownloadLimitReached() || unlimited.DownloadsLeft() != nil {
+		t.Error("Links without max_downloads should be unlimited")
+	}
+
+	limited := ShareLink{MaxDownloads: 3, DownloadCount: 1}
+	if limited.DownloadLimitReached() {
+		t.Error("Limit should not be reached after 1 of 3 downloads")
+	}
+	if left := limited.DownloadsLeft(); left == nil || *left != 2 {
+		t.Errorf("Expected 2 downloads left, got %v", left)
+	}
+
+	// Lowering the limit below the count leaves none, not a negative number
+	limited.MaxDownloads = 1
+	if !limited.DownloadLimitReached() {
+		t.Error("Limit should be reached")
+	}
+	if left := limited.DownloadsLeft(); left == nil || *left != 0 {
+		t.Errorf("Expected 0 downloads left, got %v", left)
+	}
+}
+
+func TestGalleryEmailRequestValidate(t *testing.T) {
+	req := GalleryEmailRequest{To: []string{"Ann <ann@example.com>", "bob@example.com"}}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if req.To[0] != "ann@example.com" || req.To[1] != "bob@example.com" {
+		t.Errorf("Expected bare addresses, got %v", req.To)
+	}
+
+	invalid := []GalleryEmailRequest{
+		{},
+		{To: []string{"not an address"}},
+		{To: make([]string, MaxGalleryEmailRecipients+1)},
+		{To: []string{"ann@example.com"}, Message: strings.Repeat("x", MaxGalleryEmailMessageLength+1)},
+	}
+	for _, req := range invalid {
+		if err := req.Validate(); err == nil {
+			t.Errorf("Expected %+v to be refused", req)
+		}
+	}
+}
+
+func TestLinkQRCodeRequestValidate(t *testing.T) {
+	q := LinkQRCodeRequest{}
+	if err := q.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if q.Format != QRCodeFormatPNG || q.Size != DefaultQRCodeSize {
+		t.Errorf("Expected a PNG of the default size, got %+v", q)
+	}
+
+	invalid := []LinkQRCodeRequest{
+		{Format: "gif"},
+		{Size: MinQRCodeSize - 1},
+		{Format: QRCodeFormatSVG, Size: MaxQRCodeSize + 1},
+		{Password: strings.Repeat("x", MaxQRCodePasswordLength+1)},
+	}
+	for _, q := range invalid {
+		if err := q.Validate(); err == nil {
+			t.Errorf("Expected %+v to be refused", q)
+		}
+	}
+}
+
+func TestCommentRequestValidate(t *testing.T) {
+	req := CommentRequest{Name: "  Anna   Berg ", Body: "\n Brighter, please \n"}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if req.Name != "Anna Berg" || req.Body != "Brighter, please" {
+		t.Errorf("Expected a trimmed name and body, got %q and %q", req.Name, req.Body)
+	}
+
+	invalid := []CommentRequest{
+		{Name: "Anna"},
+		{Body: "  "},
+		{Body: strings.Repeat("x", MaxCommentLength+1)},
+		{Name: strings.Repeat("x", MaxCommentNameLength+1), Body: "Nice"},
+	}
+	for _, req := range invalid {
+		if err := req.Validate(); err == nil {
+			t.Errorf("Expected %+v to be refused", req)
+		}
+	}
+	if err := (&CommentRequest{Body: strings.Repeat("好", MaxCommentLength)}).Validate(); err != nil {
+		t.Errorf("Expected the length to count characters, got %v", err)
+	}
+}
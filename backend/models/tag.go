@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxTagLength limits the length of a tag name
+const MaxTagLength = 100
+
+// Tag is a keyword attached to photos (many-to-many through photo_tags). Names are
+// unique regardless of case; the spelling of the first use is kept.
+type Tag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Name      string    `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// NormalizeTag trims a tag name and collapses the whitespace inside it
+func NormalizeTag(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// TagPhotosRequest adds tags to photos or removes them
+type TagPhotosRequest struct {
+	BulkPhotosRequest
+	Tags []string `json:"tags"`
+}
+
+// Validate checks the photos and normalizes the tags, dropping repeated ones
+func (r *TagPhotosRequest) Validate() error {
+	if err := r.BulkPhotosRequest.Validate(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(r.Tags))
+	tags := make([]string, 0, len(r.Tags))
+	for _, name := range r.Tags {
+		tag := NormalizeTag(name)
+		if tag == "" {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return fmt.Errorf("tag %q is longer than %d characters", tag, MaxTagLength)
+		}
+		if key := strings.ToLower(tag); !seen[key] {
+			seen[key] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return fmt.Errorf("tags must name at least one tag")
+	}
+	r.Tags = tags
+	return nil
+}
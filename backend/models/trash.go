@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Kinds of items in the trash
+const (
+	TrashKindPhoto   = "photo"
+	TrashKindProject = "project"
+)
+
+// TrashItem is a deleted photo or project that can be restored until PurgeAt, when
+// the trash purger deletes it for good. Originals of a deleted photo are kept in the
+// storage trash (see storage.TrashKey); a deleted project keeps its share links.
+type TrashItem struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Kind        string    `gorm:"size:16;not null" json:"kind"`          // photo or project
+	ProjectID   uint      `gorm:"index;not null" json:"project_id"`      // the photo's project, or the project
+	PhotoID     *uint     `gorm:"uniqueIndex" json:"photo_id,omitempty"` // nil for projects
+	Name        string    `gorm:"size:255;not null" json:"name"`         // base name or project name
+	Files       string    `gorm:"size:1024" json:"files,omitempty"`      // file names of a photo, comma separated
+	ProjectName string    `gorm:"-" json:"project_name,omitempty"`       // the photo's project (filled when listing)
+	CreatedAt   time.Time `json:"deleted_at"`
+	PurgeAt     time.Time `gorm:"index;not null" json:"purge_at"`
+}
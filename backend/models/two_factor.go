@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// AdminTwoFactor holds the TOTP secret of the admin login; there is at most one. Set
+// up but not yet confirmed with a code, it doesn't apply to logins.
+type AdminTwoFactor struct {
+	ID        uint       `gorm:"primarykey" json:"-"`
+	Secret    string     `gorm:"size:64;not null" json:"-"`
+	Enabled   bool       `json:"enabled"`
+	LastStep  int64      `json:"-"` // Time step of the last accepted code, so each code works once
+	EnabledAt *time.Time `json:"enabled_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// BackupCode is a single-use code for the admin login when the authenticator is lost
+type BackupCode struct {
+	ID        uint       `gorm:"primarykey" json:"-"`
+	CodeHash  string     `gorm:"uniqueIndex;size:64;not null" json:"-"` // SHA-256 of the normalized code
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TwoFactorCodeRequest confirms an action with a TOTP code (or a backup code where allowed)
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// UploadSession is a chunked upload in progress. Chunks are appended to a partial file
+// in UPLOAD_CHUNK_DIR; the record lets clients resume from Received after a dropped
+// connection, and is removed once the file is completed or the upload is cancelled.
+type UploadSession struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	Token     string    `gorm:"uniqueIndex;size:64;not null" json:"id"` // Public ID, also the partial file name
+	ProjectID uint      `gorm:"index;not null" json:"project_id"`
+	FileName  string    `gorm:"size:255;not null" json:"file_name"`
+	Size      int64     `gorm:"not null" json:"size"`
+	Hash      string    `gorm:"size:64" json:"hash,omitempty"`    // Expected SHA-256, verified on completion
+	Received  int64     `gorm:"not null;default:0" json:"offset"` // Bytes received so far, the offset of the next chunk
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateUploadSessionRequest starts (or resumes) a chunked upload
+type CreateUploadSessionRequest struct {
+	FileName string `json:"file_name" binding:"required"`
+	Size     int64  `json:"size" binding:"required"`
+	Hash     string `json:"hash"` // SHA-256 of the whole file; also lets an interrupted upload be resumed
+}
+
+// Validate checks the file type, size and hash, and lowercases the hash
+func (r *CreateUploadSessionRequest) Validate() error {
+	ext := strings.ToLower(filepath.Ext(r.FileName))
+	if !IsRawExtension(ext) && !IsImageExtension(ext) {
+		return fmt.Errorf("unsupported file type %q", ext)
+	}
+	if r.Size <= 0 {
+		return fmt.Errorf("size must be positive")
+	}
+	r.Hash = strings.ToLower(r.Hash)
+	if r.Hash != "" {
+		if _, err := hex.DecodeString(r.Hash); err != nil || len(r.Hash) != 64 {
+			return fmt.Errorf("hash must be a hex SHA-256 digest")
+		}
+	}
+	return nil
+}
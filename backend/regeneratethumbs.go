@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/services"
+)
+
+// runRegenerateThumbs implements "photobridge regenerate-thumbs [-project NAME] [-force]":
+// generates the missing thumbnails of one project (or every project), and those
+// generated with other thumbnail settings, and waits until they are written. With
+// -force every thumbnail is removed and generated again, e.g. to fix corrupt generations. Run it while the
+// server is stopped, or use POST /api/admin/projects/:id/regenerate-thumbnails.
+func runRegenerateThumbs(args []string) {
+	fs := flag.NewFlagSet("regenerate-thumbs", flag.ExitOnError)
+	name := fs.String("project", "", "project name (default: every project)")
+	force := fs.Bool("force", false, "remove and regenerate existing thumbnails")
+	fs.Parse(args)
+
+	var projects []models.Project
+	query := database.DB.Select("id, name").Order("id")
+	if *name != "" {
+		query = query.Where("name = ?", *name)
+	}
+	if err := query.Find(&projects).Error; err != nil {
+		log.Fatalf("%s Failed to load projects: %v", shortname, err)
+	}
+	if len(projects) == 0 && *name == "" {
+		log.Printf("%s No projects", shortname)
+		return
+	}
+	if len(projects) == 0 {
+		log.Fatalf("%s No project named %q", shortname, *name)
+	}
+
+	services.InitQueue(config.AppConfig.ThumbWorkers, time.Duration(config.AppConfig.ThumbJobTimeoutSec)*time.Second)
+	for _, project := range projects {
+		// The queue holds a limited number of tasks; a project larger than that takes
+		// several passes, the later ones picking up what is still missing
+		for pass := 0; ; pass++ {
+			result, err := services.RegenerateProjectThumbnails(services.Queue, &project, *force && pass == 0)
+			if err != nil {
+				log.Fatalf("%s Failed to regenerate thumbnails of %s: %v", shortname, project.Name, err)
+			}
+			// Stop returns once the queued thumbnails are written
+			services.Queue.Stop()
+			services.Queue.Start()
+			if result.Deferred == 0 || result.Queued == 0 {
+				log.Printf("%s Regenerated thumbnails of %s (%d photos, %d without a source image)",
+					shortname, project.Name, result.Photos, result.Skipped)
+				break
+			}
+		}
+	}
+	services.Queue.Stop()
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"photobridge/services"
+)
+
+// runResetTwoFactor implements "photobridge reset-2fa": turns two-factor
+// authentication off and deletes the backup codes, for when both the authenticator and
+// the backup codes are lost
+func runResetTwoFactor() {
+	if err := services.ResetTwoFactor(); err != nil {
+		log.Fatalf("%s Failed to reset two-factor authentication: %v", shortname, err)
+	}
+	fmt.Println("Two-factor authentication is off; set it up again in the admin panel")
+}
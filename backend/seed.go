@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"photobridge/services"
+)
+
+// runSeed implements "photobridge seed [-photos N]": fills the database with sample
+// projects, generated images and share links for local development.
+// Refuses to run when ENV=production.
+func runSeed(args []string) {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	photos := fs.Int("photos", 12, "generated photos per project")
+	fs.Parse(args)
+
+	if os.Getenv("ENV") == "production" {
+		log.Fatalf("%s Refusing to seed sample data with ENV=production", shortname)
+	}
+	if *photos < 1 {
+		log.Fatalf("%s -photos must be at least 1", shortname)
+	}
+
+	result, err := services.SeedDemoData(*photos)
+	if err != nil {
+		log.Fatalf("%s Seeding failed: %v", shortname, err)
+	}
+
+	log.Printf("%s Seeded %d projects with %d photos", shortname, len(result.Projects), result.Photos)
+	for _, link := range result.Links {
+		line := fmt.Sprintf("  /s/%s  %s", link.Token, link.Alias)
+		if link.PasswordEnabled {
+			line += " (password: " + link.Password + ")"
+		}
+		fmt.Println(line)
+	}
+}
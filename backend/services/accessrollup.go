@@ -0,0 +1,243 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	rollupShortname = "[AccessRollup]"
+	// rollupHour is the local hour access logs are rolled up at
+	rollupHour = 3
+	// rollupBatchSize is how many AccessLog entries are read at once
+	rollupBatchSize = 1000
+)
+
+// startOfDay returns the midnight starting the day of t in its location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// RollupAccessLogs adds the AccessLog entries of the days before the last retentionDays
+// to the daily per-link and per-project aggregates and deletes them, one day at a time.
+// Days are those of now's location. Returns how many entries were rolled up.
+func RollupAccessLogs(now time.Time, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := startOfDay(now).AddDate(0, 0, -retentionDays)
+
+	var total int64
+	for {
+		var oldest models.AccessLog
+		result := database.DB.Select("id, created_at").Where("created_at < ?", cutoff).Order("created_at").Limit(1).Find(&oldest)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return total, nil
+		}
+		day := startOfDay(oldest.CreatedAt.In(now.Location()))
+		rolled, err := rollupAccessDay(day)
+		if err != nil {
+			return total, err
+		}
+		total += rolled
+	}
+}
+
+// dayActivity accumulates the activity of a link or project on a day
+type dayActivity struct {
+	views, downloads, zips int64
+	ips                    map[string]struct{}
+	links                  map[uint]struct{} // Links opened, for projects
+	first, last            time.Time
+	projectID              uint
+}
+
+func (a *dayActivity) add(entry *models.AccessLog) {
+	switch entry.Action {
+	case models.LinkAccessView:
+		a.views++
+		if a.links != nil {
+			a.links[entry.LinkID] = struct{}{}
+		}
+	case models.LinkAccessDownload:
+		a.downloads++
+	case models.LinkAccessZip:
+		a.zips++
+	}
+	a.ips[entry.IP] = struct{}{}
+	if a.first.IsZero() || entry.CreatedAt.Before(a.first) {
+		a.first = entry.CreatedAt
+	}
+	if entry.CreatedAt.After(a.last) {
+		a.last = entry.CreatedAt
+	}
+}
+
+// rollupAccessDay moves the AccessLog entries of the day starting at day into the
+// aggregates in one transaction, so a failed rollup leaves them to the next run
+func rollupAccessDay(day time.Time) (int64, error) {
+	next := day.AddDate(0, 0, 1)
+	links := map[uint]*dayActivity{}
+	projects := map[uint]*dayActivity{}
+	photos := map[[2]uint]int64{} // Downloads by link and photo
+
+	var rolled int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var batch []models.AccessLog
+		err := tx.Where("created_at >= ? AND created_at < ?", day, next).FindInBatches(&batch, rollupBatchSize, func(*gorm.DB, int) error {
+			for i := range batch {
+				entry := &batch[i]
+				link := links[entry.LinkID]
+				if link == nil {
+					link = &dayActivity{ips: map[string]struct{}{}, projectID: entry.ProjectID}
+					links[entry.LinkID] = link
+				}
+				link.add(entry)
+				project := projects[entry.ProjectID]
+				if project == nil {
+					project = &dayActivity{ips: map[string]struct{}{}, links: map[uint]struct{}{}}
+					projects[entry.ProjectID] = project
+				}
+				project.add(entry)
+				if entry.Action == models.LinkAccessDownload && entry.PhotoID != nil {
+					photos[[2]uint{entry.LinkID, *entry.PhotoID}]++
+				}
+			}
+			rolled += int64(len(batch))
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for linkID, a := range links {
+			if err := addLinkDailyStat(tx, linkID, day, a); err != nil {
+				return err
+			}
+		}
+		for projectID, a := range projects {
+			if err := addProjectDailyStat(tx, projectID, day, a); err != nil {
+				return err
+			}
+		}
+		for key, downloads := range photos {
+			if err := addLinkPhotoDailyStat(tx, key[0], key[1], day, downloads); err != nil {
+				return err
+			}
+		}
+		return tx.Where("created_at >= ? AND created_at < ?", day, next).Delete(&models.AccessLog{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rolled, nil
+}
+
+// The add functions merge a day into an existing aggregate, which only happens for
+// entries recorded late. Distinct visitors can't be merged, so they are added up.
+
+func addLinkDailyStat(tx *gorm.DB, linkID uint, day time.Time, a *dayActivity) error {
+	var stat models.LinkDailyStat
+	if err := tx.Where("link_id = ? AND day = ?", linkID, day).Limit(1).Find(&stat).Error; err != nil {
+		return err
+	}
+	if stat.ID == 0 {
+		stat = models.LinkDailyStat{LinkID: linkID, ProjectID: a.projectID, Day: day, FirstAccess: a.first}
+	}
+	stat.Views += a.views
+	stat.Downloads += a.downloads
+	stat.ZipDownloads += a.zips
+	stat.Visitors += int64(len(a.ips))
+	if a.first.Before(stat.FirstAccess) {
+		stat.FirstAccess = a.first
+	}
+	if a.last.After(stat.LastAccess) {
+		stat.LastAccess = a.last
+	}
+	return tx.Save(&stat).Error
+}
+
+func addProjectDailyStat(tx *gorm.DB, projectID uint, day time.Time, a *dayActivity) error {
+	var stat models.ProjectDailyStat
+	if err := tx.Where("project_id = ? AND day = ?", projectID, day).Limit(1).Find(&stat).Error; err != nil {
+		return err
+	}
+	if stat.ID == 0 {
+		stat = models.ProjectDailyStat{ProjectID: projectID, Day: day}
+	}
+	stat.Views += a.views
+	stat.Downloads += a.downloads
+	stat.ZipDownloads += a.zips
+	stat.Visitors += int64(len(a.ips))
+	stat.GalleriesViewed += int64(len(a.links))
+	return tx.Save(&stat).Error
+}
+
+func addLinkPhotoDailyStat(tx *gorm.DB, linkID, photoID uint, day time.Time, downloads int64) error {
+	var stat models.LinkPhotoDailyStat
+	if err := tx.Where("link_id = ? AND photo_id = ? AND day = ?", linkID, photoID, day).Limit(1).Find(&stat).Error; err != nil {
+		return err
+	}
+	if stat.ID == 0 {
+		stat = models.LinkPhotoDailyStat{LinkID: linkID, PhotoID: photoID, Day: day}
+	}
+	stat.Downloads += downloads
+	return tx.Save(&stat).Error
+}
+
+// linkStatsModels hold the statistics of share links, deleted with them
+var linkStatsModels = []interface{}{&models.AccessLog{}, &models.LinkDailyStat{}, &models.LinkPhotoDailyStat{}}
+
+// DeleteLinkStats deletes the access log and daily aggregates of share links
+func DeleteLinkStats(tx *gorm.DB, linkIDs ...uint) error {
+	for _, model := range linkStatsModels {
+		if err := tx.Where("link_id IN ?", linkIDs).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextRollupAt returns the next rollupHour after now
+func nextRollupAt(now time.Time) time.Time {
+	next := startOfDay(now).Add(rollupHour * time.Hour)
+	if !next.After(now) {
+		next = startOfDay(now).AddDate(0, 0, 1).Add(rollupHour * time.Hour)
+	}
+	return next
+}
+
+// StartAccessRollup rolls up access logs older than ACCESS_LOG_RETENTION_DAYS at
+// startup and then nightly at 03:00 local time, keeping the stats queries fast
+func StartAccessRollup() {
+	retention := config.AppConfig.AccessLogDays
+	if retention <= 0 {
+		log.Printf("%s Disabled, keeping every access log entry", rollupShortname)
+		return
+	}
+
+	rollup := func() {
+		rolled, err := RollupAccessLogs(time.Now(), retention)
+		if err != nil {
+			log.Printf("%s Rollup failed: %v", rollupShortname, err)
+		} else if rolled > 0 {
+			log.Printf("%s Rolled up %d access log entries older than %d days", rollupShortname, rolled, retention)
+		}
+	}
+	go func() {
+		rollup()
+		for {
+			time.Sleep(time.Until(nextRollupAt(time.Now())))
+			rollup()
+		}
+	}()
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestRollupAccessLogs(t *testing.T) {
+	project := setupProjectTest(t)
+	if err := database.DB.AutoMigrate(&models.AccessLog{}, &models.LinkDailyStat{}, &models.LinkPhotoDailyStat{}, &models.ProjectDailyStat{}); err != nil {
+		t.Fatalf("Failed to migrate access log: %v", err)
+	}
+	config.AppConfig.DatabaseDriver = "postgres" // no SQLite file to measure for the digest
+	var photo models.Photo
+	database.DB.Where("project_id = ?", project.ID).First(&photo)
+
+	entry := func(linkID uint, action, ip string, photoID uint, at time.Time) {
+		log := models.AccessLog{LinkID: linkID, ProjectID: project.ID, Action: action, IP: ip, CreatedAt: at}
+		if photoID != 0 {
+			log.PhotoID = &photoID
+		}
+		if err := database.DB.Create(&log).Error; err != nil {
+			t.Fatalf("Failed to create access log: %v", err)
+		}
+	}
+	may1 := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	may2 := may1.AddDate(0, 0, 1)
+	recent := time.Date(2026, 6, 9, 18, 0, 0, 0, time.UTC)
+	entry(1, models.LinkAccessView, "10.0.0.1", 0, may1.Add(9*time.Hour))
+	entry(1, models.LinkAccessView, "10.0.0.1", 0, may1.Add(10*time.Hour))
+	entry(1, models.LinkAccessDownload, "10.0.0.2", photo.ID, may1.Add(11*time.Hour))
+	entry(2, models.LinkAccessView, "10.0.0.3", 0, may1.Add(12*time.Hour))
+	entry(1, models.LinkAccessZip, "10.0.0.4", 0, may2.Add(8*time.Hour))
+	entry(1, models.LinkAccessDownload, "10.0.0.5", photo.ID, recent)
+
+	before, err := ReadLinkStats(1, 10)
+	if err != nil {
+		t.Fatalf("ReadLinkStats() = %v", err)
+	}
+
+	now := time.Date(2026, 6, 10, 12, 0, 0, 0, time.UTC)
+	rolled, err := RollupAccessLogs(now, 30)
+	if err != nil || rolled != 5 {
+		t.Fatalf("RollupAccessLogs() = %d, %v; want 5 entries rolled up", rolled, err)
+	}
+	var left []models.AccessLog
+	database.DB.Find(&left)
+	if len(left) != 1 || !left[0].CreatedAt.Equal(recent) {
+		t.Errorf("AccessLog after rollup = %+v, want only the entry of June 9", left)
+	}
+
+	var linkDay models.LinkDailyStat
+	database.DB.Where("link_id = 1 AND day = ?", may1).First(&linkDay)
+	if linkDay.Views != 2 || linkDay.Downloads != 1 || linkDay.ZipDownloads != 0 || linkDay.Visitors != 2 || linkDay.ProjectID != project.ID {
+		t.Errorf("link 1 on May 1 = %+v, want 2 views, 1 download by 2 visitors", linkDay)
+	}
+	if !linkDay.FirstAccess.Equal(may1.Add(9*time.Hour)) || !linkDay.LastAccess.Equal(may1.Add(11*time.Hour)) {
+		t.Errorf("link 1 on May 1 spans %v - %v", linkDay.FirstAccess, linkDay.LastAccess)
+	}
+	var projectDays []models.ProjectDailyStat
+	database.DB.Order("day").Find(&projectDays)
+	if len(projectDays) != 2 || projectDays[0].Views != 3 || projectDays[0].GalleriesViewed != 2 || projectDays[0].Visitors != 3 || projectDays[1].ZipDownloads != 1 {
+		t.Errorf("project days = %+v, want May 1 with 3 views of 2 galleries by 3 visitors and May 2 with a ZIP", projectDays)
+	}
+	var photoDays []models.LinkPhotoDailyStat
+	database.DB.Find(&photoDays)
+	if len(photoDays) != 1 || photoDays[0].PhotoID != photo.ID || photoDays[0].Downloads != 1 {
+		t.Errorf("photo days = %+v, want 1 download of photo %d", photoDays, photo.ID)
+	}
+
+	// The stats read the aggregates and the entries left alike
+	after, err := ReadLinkStats(1, 10)
+	if err != nil {
+		t.Fatalf("ReadLinkStats() = %v", err)
+	}
+	if after.Views != before.Views || after.Downloads != before.Downloads || after.ZipDownloads != before.ZipDownloads || after.UniqueVisitors != before.UniqueVisitors {
+		t.Errorf("stats after rollup = %+v, want %+v", after, before)
+	}
+	if !after.FirstAccess.Equal(*before.FirstAccess) || !after.LastAccess.Equal(*before.LastAccess) {
+		t.Errorf("span after rollup = %v - %v, want %v - %v", after.FirstAccess, after.LastAccess, before.FirstAccess, before.LastAccess)
+	}
+	if len(after.TopPhotos) != 1 || after.TopPhotos[0] != (PhotoDownloadSum{photo.ID, "IMG_0001", 2}) {
+		t.Errorf("TopPhotos after rollup = %+v, want 2 downloads of IMG_0001", after.TopPhotos)
+	}
+	d, err := BuildDigest(config.DigestWeekly, may1, may1.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatalf("BuildDigest() = %v", err)
+	}
+	if d.Views != 3 || d.GalleriesViewed != 2 || d.Downloads != 1 || d.ZipDownloads != 1 {
+		t.Errorf("digest of rolled up days = %d views of %d galleries, %d downloads, %d zips; want 3, 2, 1, 1", d.Views, d.GalleriesViewed, d.Downloads, d.ZipDownloads)
+	}
+
+	// Entries within the retention window are kept, late ones merged into their day
+	if rolled, _ := RollupAccessLogs(now, 30); rolled != 0 {
+		t.Errorf("second rollup = %d entries, want 0", rolled)
+	}
+	entry(1, models.LinkAccessView, "10.0.0.6", 0, may1.Add(20*time.Hour))
+	if rolled, _ := RollupAccessLogs(now, 0); rolled != 0 {
+		t.Errorf("rollup without retention = %d entries, want 0", rolled)
+	}
+	if rolled, _ := RollupAccessLogs(now, 30); rolled != 1 {
+		t.Errorf("rollup of a late entry = %d entries, want 1", rolled)
+	}
+	database.DB.Where("link_id = 1 AND day = ?", may1).First(&linkDay)
+	if linkDay.Views != 3 || linkDay.Visitors != 3 || !linkDay.LastAccess.Equal(may1.Add(20*time.Hour)) {
+		t.Errorf("link 1 on May 1 after a late entry = %+v", linkDay)
+	}
+
+	if err := DeleteLinkStats(database.DB, 1); err != nil {
+		t.Fatalf("DeleteLinkStats() = %v", err)
+	}
+	var count int64
+	database.DB.Model(&models.LinkDailyStat{}).Where("link_id = 1").Count(&count)
+	if count != 0 {
+		t.Errorf("%d daily stats of a deleted link left", count)
+	}
+	if stats, _ := ReadLinkStats(1, 10); stats.Views != 0 || stats.FirstAccess != nil || len(stats.TopPhotos) != 0 {
+		t.Errorf("stats of a deleted link = %+v", stats)
+	}
+}
+
+func TestNextRollupAt(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	if next := nextRollupAt(time.Date(2026, 5, 1, 1, 0, 0, 0, loc)); !next.Equal(time.Date(2026, 5, 1, 3, 0, 0, 0, loc)) {
+		t.Errorf("next rollup before 03:00 = %v", next)
+	}
+	if next := nextRollupAt(time.Date(2026, 5, 1, 3, 0, 0, 0, loc)); !next.Equal(time.Date(2026, 5, 2, 3, 0, 0, 0, loc)) {
+		t.Errorf("next rollup at 03:00 = %v", next)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+const albumShortname = "[Album]"
+
+var (
+	// ErrAlbumNotFound is returned for albums that don't exist in the project
+	ErrAlbumNotFound = errors.New("album not found")
+	// ErrAlbumNameTaken is returned when the project already has an album of the name
+	ErrAlbumNameTaken = errors.New("the project already has an album of this name")
+)
+
+// AlbumSummary is an album with the number of photos in it
+type AlbumSummary struct {
+	models.Album
+	PhotoCount int64 `json:"photo_count"`
+}
+
+// ListAlbums returns the albums of a project in display order, counting the photos of
+// photoQuery (a photo query restricted to the project, e.g. to what a link shows).
+// With skipEmpty, albums without such photos are left out.
+func ListAlbums(projectID uint, photoQuery *gorm.DB, skipEmpty bool) ([]AlbumSummary, error) {
+	var albums []models.Album
+	if err := database.DB.Where("project_id = ?", projectID).Order("position, id").Find(&albums).Error; err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		AlbumID uint
+		Count   int64
+	}
+	if len(albums) > 0 {
+		if err := photoQuery.Model(&models.Photo{}).Select("album_id, COUNT(*) AS count").
+			Where("album_id IS NOT NULL").Group("album_id").Scan(&counts).Error; err != nil {
+			return nil, err
+		}
+	}
+	byAlbum := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		byAlbum[count.AlbumID] = count.Count
+	}
+	summaries := []AlbumSummary{}
+	for _, album := range albums {
+		if skipEmpty && byAlbum[album.ID] == 0 {
+			continue
+		}
+		summaries = append(summaries, AlbumSummary{Album: album, PhotoCount: byAlbum[album.ID]})
+	}
+	return summaries, nil
+}
+
+// FindAlbum loads an album of a project
+func FindAlbum(projectID, albumID uint) (*models.Album, error) {
+	var album models.Album
+	if err := database.DB.Where("project_id = ?", projectID).First(&album, albumID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlbumNotFound
+		}
+		return nil, err
+	}
+	return &album, nil
+}
+
+// CreateAlbum adds an album to a project; req must be validated. Without a position
+// the album goes after the others.
+func CreateAlbum(projectID uint, req models.AlbumRequest) (*models.Album, error) {
+	album := models.Album{ProjectID: projectID, Name: *req.Name}
+	if req.Position != nil {
+		album.Position = *req.Position
+	} else {
+		var last *int
+		database.DB.Model(&models.Album{}).Where("project_id = ?", projectID).Select("MAX(position)").Scan(&last)
+		if last != nil {
+			album.Position = *last + 1
+		}
+	}
+	if albumNameTaken(projectID, album.Name, 0) {
+		return nil, ErrAlbumNameTaken
+	}
+	if err := database.DB.Create(&album).Error; err != nil {
+		if database.IsDuplicateKey(database.DB, err) {
+			return nil, ErrAlbumNameTaken
+		}
+		return nil, err
+	}
+	return &album, nil
+}
+
+// FindOrCreateAlbum returns the album of a project with the given name, creating it
+// after the others if the project has none yet
+func FindOrCreateAlbum(projectID uint, name string) (*models.Album, error) {
+	req := models.AlbumRequest{Name: &name}
+	if err := req.Validate(true); err != nil {
+		return nil, err
+	}
+	var album models.Album
+	err := database.DB.Where("project_id = ? AND name = ?", projectID, *req.Name).First(&album).Error
+	if err == nil {
+		return &album, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	created, err := CreateAlbum(projectID, req)
+	if errors.Is(err, ErrAlbumNameTaken) {
+		// Created by a parallel upload
+		if err := database.DB.Where("project_id = ? AND name = ?", projectID, *req.Name).First(&album).Error; err != nil {
+			return nil, err
+		}
+		return &album, nil
+	}
+	return created, err
+}
+
+// UpdateAlbum renames or moves an album; req must be validated
+func UpdateAlbum(album *models.Album, req models.AlbumRequest) error {
+	updates := map[string]interface{}{}
+	if req.Name != nil && *req.Name != album.Name {
+		if albumNameTaken(album.ProjectID, *req.Name, album.ID) {
+			return ErrAlbumNameTaken
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Position != nil {
+		updates["position"] = *req.Position
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := database.DB.Model(album).Updates(updates).Error; err != nil {
+		if database.IsDuplicateKey(database.DB, err) {
+			return ErrAlbumNameTaken
+		}
+		return err
+	}
+	return database.DB.First(album, album.ID).Error
+}
+
+// albumNameTaken reports whether another album of the project has the name
+func albumNameTaken(projectID uint, name string, exceptID uint) bool {
+	var count int64
+	database.DB.Model(&models.Album{}).Where("project_id = ? AND name = ? AND id <> ?", projectID, name, exceptID).Count(&count)
+	return count > 0
+}
+
+// DeleteAlbum deletes an album; its photos stay in the project, in no album. Photos in
+// the trash are taken out as well, so a restored photo doesn't point at a deleted album.
+func DeleteAlbum(album *models.Album) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Photo{}).Where("album_id = ?", album.ID).Update("album_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(album).Error
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("%s Deleted album %s of project %d", albumShortname, album.Name, album.ProjectID)
+	return nil
+}
+
+// AssignAlbum puts photos of a project into an album (nil takes them out of their
+// album) and returns the IDs of the photos changed. IDs of other projects' photos are
+// ignored. The album must belong to the project.
+func AssignAlbum(projectID uint, albumID *uint, photoIDs []uint) ([]uint, error) {
+	var ids []uint
+	if err := database.DB.Model(&models.Photo{}).Where("project_id = ? AND id IN ?", projectID, photoIDs).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []uint{}, nil
+	}
+	if err := database.DB.Model(&models.Photo{}).Where("id IN ?", ids).Update("album_id", albumID).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// deleteProjectAlbums removes the albums of a project that is deleted for good
+func deleteProjectAlbums(tx *gorm.DB, projectID uint) error {
+	return tx.Where("project_id = ?", projectID).Delete(&models.Album{}).Error
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func albumNames(t *testing.T, projectID uint) []string {
+	t.Helper()
+	albums, err := ListAlbums(projectID, database.DB.Where("project_id = ?", projectID), false)
+	if err != nil {
+		t.Fatalf("ListAlbums() = %v", err)
+	}
+	names := make([]string, len(albums))
+	for i, album := range albums {
+		names[i] = album.Name
+	}
+	return names
+}
+
+func TestAlbums(t *testing.T) {
+	project := setupProjectTest(t)
+	name := func(s string) *string { return &s }
+
+	ceremony, err := CreateAlbum(project.ID, models.AlbumRequest{Name: name("Ceremony")})
+	if err != nil {
+		t.Fatalf("CreateAlbum() = %v", err)
+	}
+	reception, _ := CreateAlbum(project.ID, models.AlbumRequest{Name: name("Reception")})
+	if ceremony.Position != 0 || reception.Position != 1 {
+		t.Errorf("Expected new albums to go last, got positions %d and %d", ceremony.Position, reception.Position)
+	}
+	if _, err := CreateAlbum(project.ID, models.AlbumRequest{Name: name("Ceremony")}); !errors.Is(err, ErrAlbumNameTaken) {
+		t.Errorf("Expected ErrAlbumNameTaken, got %v", err)
+	}
+	// Another project may use the same name
+	other := models.Project{Name: "portraits"}
+	database.DB.Create(&other)
+	if _, err := CreateAlbum(other.ID, models.AlbumRequest{Name: name("Ceremony")}); err != nil {
+		t.Errorf("Expected the name to be free in another project, got %v", err)
+	}
+
+	if found, err := FindOrCreateAlbum(project.ID, "Reception"); err != nil || found.ID != reception.ID {
+		t.Errorf("Expected the existing album, got %+v (%v)", found, err)
+	}
+	portraits, err := FindOrCreateAlbum(project.ID, " Portraits ")
+	if err != nil || portraits.Name != "Portraits" || portraits.Position != 2 {
+		t.Errorf("Expected a new album, got %+v (%v)", portraits, err)
+	}
+
+	first := 0
+	if err := UpdateAlbum(portraits, models.AlbumRequest{Position: &first}); err != nil {
+		t.Fatalf("UpdateAlbum() = %v", err)
+	}
+	if err := UpdateAlbum(reception, models.AlbumRequest{Name: name("Ceremony")}); !errors.Is(err, ErrAlbumNameTaken) {
+		t.Errorf("Expected renaming onto another album to fail, got %v", err)
+	}
+	// Same position: creation order breaks the tie
+	if names := albumNames(t, project.ID); len(names) != 3 || names[0] != "Ceremony" || names[1] != "Portraits" || names[2] != "Reception" {
+		t.Errorf("Unexpected album order %v", names)
+	}
+	if _, err := FindAlbum(other.ID, reception.ID); !errors.Is(err, ErrAlbumNotFound) {
+		t.Errorf("Expected albums of other projects to be hidden, got %v", err)
+	}
+}
+
+func TestAssignAndDeleteAlbum(t *testing.T) {
+	project := setupProjectTest(t)
+	name := func(s string) *string { return &s }
+	album, _ := CreateAlbum(project.ID, models.AlbumRequest{Name: name("Ceremony")})
+	empty, _ := CreateAlbum(project.ID, models.AlbumRequest{Name: name("Reception")})
+
+	var photo models.Photo
+	database.DB.Where("project_id = ?", project.ID).First(&photo)
+	trashed := models.Photo{ProjectID: project.ID, BaseName: "IMG_0002", NormalExt: ".jpg"}
+	database.DB.Create(&trashed)
+	stranger := models.Photo{ProjectID: project.ID + 1, BaseName: "IMG_0001", NormalExt: ".jpg"}
+	database.DB.Create(&stranger)
+
+	ids, err := AssignAlbum(project.ID, &album.ID, []uint{photo.ID, trashed.ID, stranger.ID})
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("AssignAlbum() = %v, %v; want the project's two photos", ids, err)
+	}
+	database.DB.Delete(&trashed)
+
+	albums, _ := ListAlbums(project.ID, database.DB.Where("project_id = ?", project.ID), true)
+	if len(albums) != 1 || albums[0].ID != album.ID || albums[0].PhotoCount != 1 {
+		t.Errorf("Expected only the album with its visible photo, got %+v", albums)
+	}
+	if albums, _ := ListAlbums(project.ID, database.DB.Where("project_id = ?", project.ID), false); len(albums) != 2 || albums[1].ID != empty.ID {
+		t.Errorf("Expected empty albums when asked for, got %+v", albums)
+	}
+
+	if err := DeleteAlbum(album); err != nil {
+		t.Fatalf("DeleteAlbum() = %v", err)
+	}
+	var left int64
+	database.DB.Unscoped().Model(&models.Photo{}).Where("album_id IS NOT NULL").Count(&left)
+	if left != 0 {
+		t.Errorf("Expected the deleted album's photos, trashed ones included, to be in no album, %d are not", left)
+	}
+	if names := albumNames(t, project.ID); len(names) != 1 || names[0] != "Reception" {
+		t.Errorf("Expected only Reception to be left, got %v", names)
+	}
+}
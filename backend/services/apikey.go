@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+
+	"gorm.io/gorm"
+)
+
+const apiKeyShortname = "[APIKey]"
+
+// SyncEnvAPIKey keeps the key of the API_KEY setting in the database: a full-access
+// key, created on first start and updated when the setting changes. Keys created in
+// the admin panel are independent of it.
+func SyncEnvAPIKey() error {
+	if config.AppConfig.APIKey == "" {
+		return nil
+	}
+	hash := utils.HashAPIKey(config.AppConfig.APIKey)
+
+	var key models.APIKey
+	err := database.DB.Where("from_env = ?", true).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		key = models.APIKey{
+			Name:       "API_KEY",
+			Prefix:     utils.APIKeyPrefix(config.AppConfig.APIKey),
+			KeyHash:    hash,
+			Permission: models.APIKeyUpload,
+			FromEnv:    true,
+		}
+		if err := database.DB.Create(&key).Error; err != nil {
+			return err
+		}
+		log.Printf("%s Stored the API_KEY setting as a full-access key", apiKeyShortname)
+		return nil
+	}
+	if err != nil || key.KeyHash == hash {
+		return err
+	}
+	log.Printf("%s API_KEY changed, the previous key no longer works", apiKeyShortname)
+	return database.DB.Model(&key).UpdateColumns(map[string]interface{}{
+		"prefix":   utils.APIKeyPrefix(config.AppConfig.APIKey),
+		"key_hash": hash,
+	}).Error
+}
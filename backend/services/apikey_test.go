@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+func TestSyncEnvAPIKey(t *testing.T) {
+	setupProjectTest(t)
+	database.DB.AutoMigrate(&models.APIKey{})
+	config.AppConfig.APIKey = "first-key"
+
+	if err := SyncEnvAPIKey(); err != nil {
+		t.Fatalf("SyncEnvAPIKey failed: %v", err)
+	}
+	if err := SyncEnvAPIKey(); err != nil {
+		t.Fatalf("Second SyncEnvAPIKey failed: %v", err)
+	}
+	var keys []models.APIKey
+	database.DB.Find(&keys)
+	if len(keys) != 1 || !keys[0].FromEnv || keys[0].Permission != models.APIKeyUpload || keys[0].ProjectID != nil {
+		t.Fatalf("Expected one full-access key, got %+v", keys)
+	}
+	if keys[0].KeyHash != utils.HashAPIKey("first-key") {
+		t.Error("Expected the hash of API_KEY to be stored")
+	}
+
+	// Changing the setting replaces the key instead of adding one
+	config.AppConfig.APIKey = "second-key"
+	if err := SyncEnvAPIKey(); err != nil {
+		t.Fatalf("SyncEnvAPIKey failed: %v", err)
+	}
+	keys = nil
+	database.DB.Find(&keys)
+	if len(keys) != 1 || keys[0].KeyHash != utils.HashAPIKey("second-key") {
+		t.Errorf("Expected the key to be replaced, got %+v", keys)
+	}
+}
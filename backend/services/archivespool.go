@@ -0,0 +1,267 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const archiveShortname = "[ArchiveSpool]"
+
+// archiveSpoolFile matches the names of the archives the spool writes (finished and
+// being written), the only files it deletes in its directory
+var archiveSpoolFile = regexp.MustCompile(`^[0-9a-f]{64}(\.zip|-[0-9]+\.tmp)$`)
+
+// Archives keeps prepared download-all archives; nil when spooling is disabled
+var Archives *ArchiveSpool
+
+// archiveEntry is a finished archive in the spool
+type archiveEntry struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// ArchiveSpool stores zip archives on disk so repeated and resumed downloads can be
+// served with Content-Length and Range support. Disk usage (including archives being
+// written) stays within maxBytes; the least recently used archives are evicted first.
+type ArchiveSpool struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	entries  map[string]*archiveEntry
+	building map[string]bool
+	used     int64
+	now      func() time.Time
+}
+
+// NewArchiveSpool creates a spool in dir, removing archives left over from a previous
+// run. Other files in dir are left alone.
+func NewArchiveSpool(dir string, maxBytes int64) (*ArchiveSpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive spool: %w", err)
+	}
+	if err := removeLeftovers(dir, archiveSpoolFile); err != nil {
+		return nil, fmt.Errorf("failed to clear archive spool: %w", err)
+	}
+	return &ArchiveSpool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*archiveEntry),
+		building: make(map[string]bool),
+		now:      time.Now,
+	}, nil
+}
+
+// InitArchiveSpool sets up the global archive spool (maxMB 0 = disabled)
+func InitArchiveSpool(dir string, maxMB int) {
+	if maxMB <= 0 {
+		log.Printf("%s Disabled", archiveShortname)
+		return
+	}
+	spool, err := NewArchiveSpool(dir, int64(maxMB)<<20)
+	if err != nil {
+		log.Printf("%s %v, download-all archives will be streamed", archiveShortname, err)
+		return
+	}
+	Archives = spool
+	log.Printf("%s Using %s with a budget of %d MB", archiveShortname, dir, maxMB)
+}
+
+// ArchiveKey identifies the archive of a file list: it changes whenever a file is
+// added, removed, renamed or modified. It also returns an upper estimate of the
+// archive size used to reserve spool space.
+func ArchiveKey(files []string, basePath string) (string, int64, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n", basePath)
+	var estimate int64 = 1024 // end of central directory records
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", 0, err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\n", file, info.Size(), info.ModTime().UnixNano())
+		// Local header, data descriptor and central directory entry (with zip64 extras)
+		estimate += info.Size() + 256 + 2*int64(len(file))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), estimate, nil
+}
+
+// Open returns the finished archive for key, marking it as recently used
+func (s *ArchiveSpool) Open(key string) (*os.File, os.FileInfo, bool) {
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	if ok {
+		entry.lastUsed = s.now()
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil, nil, false
+	}
+
+	file, err := os.Open(entry.path)
+	if err != nil {
+		s.remove(key)
+		return nil, nil, false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		s.remove(key)
+		return nil, nil, false
+	}
+	return file, info, true
+}
+
+// Create reserves estimate bytes and starts writing the archive for key.
+// It returns false if the archive is already being written, or does not fit
+// in the budget even after evicting every finished archive.
+func (s *ArchiveSpool) Create(key string, estimate int64) (*SpoolWriter, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.building[key] || estimate > s.maxBytes {
+		return nil, false
+	}
+	if _, ok := s.entries[key]; ok {
+		return nil, false
+	}
+	if !s.evictLocked(estimate) {
+		return nil, false
+	}
+
+	file, err := os.CreateTemp(s.dir, key+"-*.tmp")
+	if err != nil {
+		log.Printf("%s Failed to create archive: %v", archiveShortname, err)
+		return nil, false
+	}
+	s.building[key] = true
+	s.used += estimate
+	return &SpoolWriter{spool: s, key: key, file: file, reserved: estimate}, true
+}
+
+// evictLocked removes least recently used archives until need bytes are free
+func (s *ArchiveSpool) evictLocked(need int64) bool {
+	for s.used+need > s.maxBytes {
+		var oldestKey string
+		var oldest *archiveEntry
+		for key, entry := range s.entries {
+			if oldest == nil || entry.lastUsed.Before(oldest.lastUsed) {
+				oldestKey, oldest = key, entry
+			}
+		}
+		if oldest == nil {
+			return false
+		}
+		// Downloads still reading the file keep their open handle
+		os.Remove(oldest.path)
+		s.used -= oldest.size
+		delete(s.entries, oldestKey)
+		log.Printf("%s Evicted archive %s (%d bytes)", archiveShortname, filepath.Base(oldest.path), oldest.size)
+	}
+	return true
+}
+
+func (s *ArchiveSpool) remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		os.Remove(entry.path)
+		s.used -= entry.size
+		delete(s.entries, key)
+	}
+}
+
+// Usage returns the bytes used by finished and in-progress archives
+func (s *ArchiveSpool) Usage() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+// SpoolWriter writes one archive into the spool
+type SpoolWriter struct {
+	spool    *ArchiveSpool
+	key      string
+	file     *os.File
+	size     int64
+	reserved int64
+}
+
+// Write implements io.Writer, failing once the reserved space is exceeded
+func (w *SpoolWriter) Write(p []byte) (int, error) {
+	if w.size+int64(len(p)) > w.reserved {
+		return 0, errors.New("archive exceeds reserved spool space")
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Tee returns a writer that writes to the spool and copies to client. A failing
+// client (e.g. a dropped connection) is skipped so the archive is still completed
+// and the next request can resume from it.
+func (w *SpoolWriter) Tee(client io.Writer) io.Writer {
+	return &spoolTee{spool: w, client: client}
+}
+
+// Commit makes the archive available to Open and releases unused reserved space
+func (w *SpoolWriter) Commit() error {
+	s := w.spool
+	if err := w.file.Close(); err != nil {
+		w.Abort()
+		return err
+	}
+	path := filepath.Join(s.dir, w.key+".zip")
+	if err := os.Rename(w.file.Name(), path); err != nil {
+		w.Abort()
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used += w.size - w.reserved
+	delete(s.building, w.key)
+	s.entries[w.key] = &archiveEntry{path: path, size: w.size, lastUsed: s.now()}
+	return nil
+}
+
+// Abort discards a partially written archive
+func (w *SpoolWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+
+	s := w.spool
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used -= w.reserved
+	delete(s.building, w.key)
+}
+
+type spoolTee struct {
+	spool     *SpoolWriter
+	client    io.Writer
+	clientErr error
+}
+
+func (t *spoolTee) Write(p []byte) (int, error) {
+	n, err := t.spool.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if t.clientErr == nil {
+		if _, err := t.client.Write(p); err != nil {
+			t.clientErr = err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeArchive(t *testing.T, spool *ArchiveSpool, key string, data []byte) {
+	t.Helper()
+	w, ok := spool.Create(key, int64(len(data)))
+	if !ok {
+		t.Fatalf("Create(%s) should succeed", key)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+}
+
+func TestNewArchiveSpoolKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	key := strings.Repeat("ab", 32)
+	leftovers := []string{key + ".zip", key + "-123456.tmp"}
+	kept := []string{"photo.jpg", "backup.zip", "notes-1.tmp"}
+	for _, name := range append(leftovers, kept...) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	if _, err := NewArchiveSpool(dir, 1<<20); err != nil {
+		t.Fatalf("NewArchiveSpool failed: %v", err)
+	}
+	for _, name := range leftovers {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected the leftover %s to be removed, got %v", name, err)
+		}
+	}
+	for _, name := range kept {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", name, err)
+		}
+	}
+}
+
+func TestArchiveSpoolCreateAndOpen(t *testing.T) {
+	spool, err := NewArchiveSpool(filepath.Join(t.TempDir(), "archives"), 100)
+	if err != nil {
+		t.Fatalf("NewArchiveSpool failed: %v", err)
+	}
+
+	if _, _, ok := spool.Open("a"); ok {
+		t.Error("Open should miss before the archive is prepared")
+	}
+
+	w, ok := spool.Create("a", 50)
+	if !ok {
+		t.Fatal("Create should succeed")
+	}
+	if _, ok := spool.Create("a", 50); ok {
+		t.Error("Create should refuse an archive that is already being written")
+	}
+	w.Write([]byte("zipdata"))
+	if _, _, ok := spool.Open("a"); ok {
+		t.Error("Open should miss while the archive is being written")
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if spool.Usage() != 7 {
+		t.Errorf("Unused reservation should be released, usage %d", spool.Usage())
+	}
+
+	file, info, ok := spool.Open("a")
+	if !ok {
+		t.Fatal("Open should return the prepared archive")
+	}
+	defer file.Close()
+	data, _ := io.ReadAll(file)
+	if string(data) != "zipdata" || info.Size() != 7 {
+		t.Errorf("Unexpected archive content %q", data)
+	}
+
+	if _, ok := spool.Create("b", 101); ok {
+		t.Error("Archives larger than the budget should not be spooled")
+	}
+}
+
+func TestArchiveSpoolEvictsLeastRecentlyUsed(t *testing.T) {
+	spool, err := NewArchiveSpool(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("NewArchiveSpool failed: %v", err)
+	}
+	now := time.Now()
+	spool.now = func() time.Time { return now }
+
+	writeArchive(t, spool, "a", make([]byte, 40))
+	now = now.Add(time.Second)
+	writeArchive(t, spool, "b", make([]byte, 40))
+	now = now.Add(time.Second)
+	file, _, _ := spool.Open("a") // a is now more recently used than b
+	file.Close()
+
+	now = now.Add(time.Second)
+	writeArchive(t, spool, "c", make([]byte, 40))
+
+	if _, _, ok := spool.Open("b"); ok {
+		t.Error("Least recently used archive should be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		file, _, ok := spool.Open(key)
+		if !ok {
+			t.Errorf("Archive %s should be kept", key)
+			continue
+		}
+		file.Close()
+	}
+	if spool.Usage() != 80 {
+		t.Errorf("Expected usage 80, got %d", spool.Usage())
+	}
+}
+
+func TestArchiveSpoolAbort(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := NewArchiveSpool(dir, 100)
+	if err != nil {
+		t.Fatalf("NewArchiveSpool failed: %v", err)
+	}
+
+	w, _ := spool.Create("a", 10)
+	if _, err := w.Write(make([]byte, 11)); err == nil {
+		t.Error("Write beyond the reservation should fail")
+	}
+	w.Abort()
+
+	if spool.Usage() != 0 {
+		t.Errorf("Abort should release the reservation, usage %d", spool.Usage())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Abort should remove the partial file, found %d files", len(entries))
+	}
+	if _, ok := spool.Create("a", 10); !ok {
+		t.Error("Archive should be writable again after Abort")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestArchiveSpoolTeeSurvivesClientFailure(t *testing.T) {
+	spool, err := NewArchiveSpool(t.TempDir(), 100)
+	if err != nil {
+		t.Fatalf("NewArchiveSpool failed: %v", err)
+	}
+
+	var client bytes.Buffer
+	w, _ := spool.Create("a", 20)
+	tee := w.Tee(&client)
+	tee.Write([]byte("hello "))
+	w.Commit()
+	if client.String() != "hello " {
+		t.Errorf("Client should receive the data, got %q", client.String())
+	}
+
+	w, _ = spool.Create("b", 20)
+	tee = w.Tee(failingWriter{})
+	if _, err := tee.Write([]byte("first ")); err != nil {
+		t.Fatalf("Client failure should not stop the archive: %v", err)
+	}
+	tee.Write([]byte("second"))
+	w.Commit()
+
+	file, _, ok := spool.Open("b")
+	if !ok {
+		t.Fatal("Archive should be complete after the client dropped")
+	}
+	defer file.Close()
+	if data, _ := io.ReadAll(file); string(data) != "first second" {
+		t.Errorf("Unexpected archive content %q", data)
+	}
+}
+
+func TestArchiveKey(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.jpg")
+	os.WriteFile(a, []byte("aaaa"), 0644)
+
+	key1, estimate, err := ArchiveKey([]string{a}, dir)
+	if err != nil {
+		t.Fatalf("ArchiveKey failed: %v", err)
+	}
+	if estimate < 4 {
+		t.Errorf("Estimate should cover the file data, got %d", estimate)
+	}
+	key2, _, _ := ArchiveKey([]string{a}, dir)
+	if key1 != key2 {
+		t.Error("Key should be stable for unchanged files")
+	}
+
+	os.WriteFile(a, []byte("changed"), 0644)
+	if key3, _, _ := ArchiveKey([]string{a}, dir); key3 == key1 {
+		t.Error("Key should change when a file changes")
+	}
+	if _, _, err := ArchiveKey([]string{filepath.Join(dir, "missing.jpg")}, dir); err == nil {
+		t.Error("ArchiveKey should fail for missing files")
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	commentRateLimit  = 5                // Comments one IP may post per window, across links
+	commentRateWindow = 10 * time.Minute // Window of the comment rate limit
+)
+
+// ErrCommentNotFound is returned for comments that don't exist
+var ErrCommentNotFound = errors.New("comment not found")
+
+// CommentRetryAfter returns how long ip must wait before posting another comment, 0
+// if it may post now. An IP posts at most commentRateLimit comments per
+// commentRateWindow; the wait ends when the oldest of them leaves the window.
+func CommentRetryAfter(ip string, now time.Time) (time.Duration, error) {
+	var recent []time.Time
+	err := database.DB.Model(&models.PhotoComment{}).Where("ip = ? AND created_at > ?", ip, now.Add(-commentRateWindow)).
+		Order("created_at DESC").Limit(commentRateLimit).Pluck("created_at", &recent).Error
+	if err != nil || len(recent) < commentRateLimit {
+		return 0, err
+	}
+	return max(recent[len(recent)-1].Add(commentRateWindow).Sub(now), 0), nil
+}
+
+// AddComment stores a visitor's comment on a photo of a link; req must be validated
+// and the link must show the photo
+func AddComment(link *models.ShareLink, photoID uint, req models.CommentRequest, ip, country string) (*models.PhotoComment, error) {
+	comment := models.PhotoComment{
+		LinkID:  link.ID,
+		PhotoID: photoID,
+		Name:    req.Name,
+		Body:    req.Body,
+		IP:      ip,
+		Country: country,
+	}
+	if err := database.DB.Create(&comment).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// PhotoComments returns the comments on a photo of a link that aren't hidden, oldest first
+func PhotoComments(linkID, photoID uint) ([]models.PhotoComment, error) {
+	comments := []models.PhotoComment{}
+	err := database.DB.Where("link_id = ? AND photo_id = ? AND hidden = ?", linkID, photoID, false).
+		Order("created_at, id").Find(&comments).Error
+	return comments, err
+}
+
+// AdminComment is a comment in the moderation list, with the photo and link it is on
+type AdminComment struct {
+	models.PhotoComment
+	ProjectID uint   `json:"project_id"`
+	BaseName  string `json:"base_name"`
+	LinkAlias string `json:"link_alias"`
+	LinkToken string `json:"link_token"`
+}
+
+// ListComments returns comments for moderation, newest first
+func ListComments(q models.CommentQuery) ([]AdminComment, error) {
+	query := database.DB.Model(&models.PhotoComment{}).
+		Select("photo_comments.*, photos.project_id, photos.base_name, share_links.alias AS link_alias, share_links.token AS link_token").
+		Joins("JOIN photos ON photos.id = photo_comments.photo_id").
+		Joins("JOIN share_links ON share_links.id = photo_comments.link_id").
+		Order("photo_comments.created_at DESC, photo_comments.id DESC")
+	if q.ProjectID != 0 {
+		query = query.Where("photos.project_id = ?", q.ProjectID)
+	}
+	if q.LinkID != 0 {
+		query = query.Where("photo_comments.link_id = ?", q.LinkID)
+	}
+	if q.PhotoID != 0 {
+		query = query.Where("photo_comments.photo_id = ?", q.PhotoID)
+	}
+	if q.Hidden != nil {
+		query = query.Where("photo_comments.hidden = ?", *q.Hidden)
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = models.DefaultCommentListLimit
+	}
+	comments := []AdminComment{}
+	err := query.Limit(limit).Scan(&comments).Error
+	return comments, err
+}
+
+// SetCommentHidden hides a comment from the gallery or shows it again
+func SetCommentHidden(id uint, hidden bool) (*models.PhotoComment, error) {
+	var comment models.PhotoComment
+	if err := database.DB.First(&comment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCommentNotFound
+		}
+		return nil, err
+	}
+	if err := database.DB.Model(&comment).Update("hidden", hidden).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// DeleteComment deletes a comment for good
+func DeleteComment(id uint) error {
+	result := database.DB.Delete(&models.PhotoComment{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
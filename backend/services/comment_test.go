@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupCommentTest(t *testing.T) (*models.ShareLink, []models.Photo) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.Photo{}, &models.ShareLink{}, &models.PhotoComment{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	photos := []models.Photo{
+		{ProjectID: 1, BaseName: "IMG_1", NormalExt: ".jpg"},
+		{ProjectID: 1, BaseName: "IMG_2", NormalExt: ".jpg"},
+	}
+	database.DB.Create(&photos)
+	link := models.ShareLink{ProjectID: 1, Token: "feedback", Alias: "Client", Preferences: models.LinkPreferences{Comments: true}}
+	database.DB.Create(&link)
+	return &link, photos
+}
+
+func TestCommentRetryAfter(t *testing.T) {
+	link, photos := setupCommentTest(t)
+	now := time.Now()
+	for i := 0; i < commentRateLimit; i++ {
+		database.DB.Create(&models.PhotoComment{LinkID: link.ID, PhotoID: photos[0].ID, Body: "Nice", IP: "203.0.113.7",
+			CreatedAt: now.Add(time.Duration(i-commentRateLimit) * time.Minute)})
+	}
+
+	wait, err := CommentRetryAfter("203.0.113.7", now)
+	if err != nil {
+		t.Fatalf("CommentRetryAfter() = %v", err)
+	}
+	// The oldest comment was posted five minutes ago and leaves the window in five more
+	if wait != commentRateWindow-time.Duration(commentRateLimit)*time.Minute {
+		t.Errorf("Expected a wait until the oldest comment leaves the window, got %v", wait)
+	}
+	if wait, _ := CommentRetryAfter("198.51.100.1", now); wait != 0 {
+		t.Errorf("Expected other IPs to post freely, got %v", wait)
+	}
+	if wait, _ := CommentRetryAfter("203.0.113.7", now.Add(commentRateWindow)); wait != 0 {
+		t.Errorf("Expected the limit to end with the window, got %v", wait)
+	}
+}
+
+func TestModerateComments(t *testing.T) {
+	link, photos := setupCommentTest(t)
+	req := models.CommentRequest{Name: "Anna", Body: "Could this one be brighter?"}
+	first, err := AddComment(link, photos[0].ID, req, "203.0.113.7", "DE")
+	if err != nil {
+		t.Fatalf("AddComment() = %v", err)
+	}
+	AddComment(link, photos[0].ID, models.CommentRequest{Body: "Love it"}, "203.0.113.7", "DE")
+	AddComment(link, photos[1].ID, models.CommentRequest{Body: "Crop tighter"}, "203.0.113.8", "")
+
+	comments, err := PhotoComments(link.ID, photos[0].ID)
+	if err != nil || len(comments) != 2 || comments[0].ID != first.ID {
+		t.Fatalf("PhotoComments() = %+v, %v; want both comments, oldest first", comments, err)
+	}
+
+	if _, err := SetCommentHidden(first.ID, true); err != nil {
+		t.Fatalf("SetCommentHidden() = %v", err)
+	}
+	if comments, _ := PhotoComments(link.ID, photos[0].ID); len(comments) != 1 || comments[0].Body != "Love it" {
+		t.Errorf("Expected the hidden comment to be left out, got %+v", comments)
+	}
+	if _, err := SetCommentHidden(999, true); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("Expected ErrCommentNotFound, got %v", err)
+	}
+
+	all, err := ListComments(models.CommentQuery{})
+	if err != nil || len(all) != 3 || all[0].BaseName != "IMG_2" || all[0].LinkAlias != "Client" || all[0].ProjectID != 1 {
+		t.Fatalf("ListComments() = %+v, %v; want every comment, newest first", all, err)
+	}
+	hidden := true
+	if hiddenOnly, _ := ListComments(models.CommentQuery{Hidden: &hidden}); len(hiddenOnly) != 1 || hiddenOnly[0].ID != first.ID {
+		t.Errorf("Expected only the hidden comment, got %+v", hiddenOnly)
+	}
+	if onPhoto, _ := ListComments(models.CommentQuery{PhotoID: photos[1].ID, Limit: 5}); len(onPhoto) != 1 || onPhoto[0].Body != "Crop tighter" {
+		t.Errorf("Expected the comment on the second photo, got %+v", onPhoto)
+	}
+
+	if err := DeleteComment(first.ID); err != nil {
+		t.Fatalf("DeleteComment() = %v", err)
+	}
+	if err := DeleteComment(first.ID); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("Expected deleting twice to report ErrCommentNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"photobridge/common"
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+const (
+	contactSheetShortname = "[ContactSheet]"
+	// maxCachedContactSheets bounds the cached sheets per project; the oldest go first
+	maxCachedContactSheets = 10
+)
+
+var (
+	// ErrNoContactSheetPhotos means the sheet would show no photos
+	ErrNoContactSheetPhotos = errors.New("no photos to show")
+	// ErrTooManyContactSheetPhotos means the project has more photos than a sheet shows
+	ErrTooManyContactSheetPhotos = fmt.Errorf("a contact sheet shows at most %d photos, pick some", models.MaxContactSheetPhotos)
+	// ErrContactSheetLink means link_id names no link of the project
+	ErrContactSheetLink = errors.New("share link not found in this project")
+)
+
+// ContactSheet is a rendered contact sheet. Missing counts photos whose thumbnail
+// wasn't ready and shows as an empty tile; such sheets aren't cached.
+type ContactSheet struct {
+	Data    []byte
+	Photos  int
+	Missing int
+}
+
+// contactSheetDir is where the cached sheets of a project are stored
+func contactSheetDir(projectID uint) string {
+	return filepath.Join(filepath.Dir(config.AppConfig.DatabasePath), "contactsheets", strconv.FormatUint(uint64(projectID), 10))
+}
+
+// GenerateContactSheet renders a grid of the small thumbnails of a project's photos
+// with their base names, ordered by name: all photos, the given ones, or a link's
+// selection. Sheets are cached on disk under a key of the photos and their update
+// times, so any change to the photos renders a new one. Thumbnails that don't exist
+// yet are queued.
+func GenerateContactSheet(project *models.Project, q models.ContactSheetQuery) (*ContactSheet, error) {
+	query := database.DB.Select(common.PhotoMetaColumns).Where("project_id = ?", project.ID)
+	subtitle := ""
+	switch {
+	case len(q.PhotoIDs) > 0:
+		query = query.Where("id IN ?", q.PhotoIDs)
+	case q.LinkID != 0:
+		var link models.ShareLink
+		if err := database.DB.Select("id, alias, token").Where("id = ? AND project_id = ?", q.LinkID, project.ID).First(&link).Error; err != nil {
+			return nil, ErrContactSheetLink
+		}
+		query = query.Where("id IN (?)", database.DB.Model(&models.PhotoSelection{}).Select("photo_id").Where("link_id = ?", link.ID))
+		name := link.Alias
+		if name == "" {
+			name = link.Token
+		}
+		subtitle = "Selection of " + name + " · "
+	}
+	var photos []models.Photo
+	if err := query.Order("base_name").Limit(models.MaxContactSheetPhotos + 1).Find(&photos).Error; err != nil {
+		return nil, err
+	}
+	if len(photos) == 0 {
+		return nil, ErrNoContactSheetPhotos
+	}
+	if len(photos) > models.MaxContactSheetPhotos {
+		return nil, ErrTooManyContactSheetPhotos
+	}
+
+	columns := min(q.GridColumns(), len(photos))
+	path := filepath.Join(contactSheetDir(project.ID), contactSheetKey(project, photos, subtitle, columns)+".jpg")
+	if data, err := os.ReadFile(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now) // Keep it among the recently used
+		return &ContactSheet{Data: data, Photos: len(photos)}, nil
+	}
+
+	sheet := &ContactSheet{Photos: len(photos)}
+	tiles := make([]utils.ContactSheetTile, len(photos))
+	for i := range photos {
+		photo := &photos[i]
+		tiles[i].Name = photo.BaseName + photo.NormalExt
+		if IsRawOnly(photo) {
+			tiles[i].Name = photo.BaseName + photo.RawExt
+			tiles[i].Thumb, _ = RawPlaceholderThumb(photo, ThumbSizeSmall)
+			continue
+		}
+		thumb, err := os.ReadFile(ThumbPath(project.ID, photo.ID, ThumbSizeSmall))
+		if err != nil {
+			sheet.Missing++
+			if Queue != nil {
+				Queue.Enqueue(photo, project.Name)
+			}
+			continue
+		}
+		tiles[i].Thumb = thumb
+	}
+
+	subtitle += fmt.Sprintf("%d photos · %s", len(photos), time.Now().Format("2006-01-02"))
+	data, err := utils.ComposeContactSheet(project.Name, subtitle, tiles, columns, config.AppConfig.ShareCardFont)
+	if err != nil {
+		return nil, err
+	}
+	sheet.Data = data
+	if sheet.Missing == 0 {
+		saveContactSheet(path, data)
+	}
+	return sheet, nil
+}
+
+// contactSheetKey identifies the content of a sheet: the photos, their thumbnails and
+// the header
+func contactSheetKey(project *models.Project, photos []models.Photo, subtitle string, columns int) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%d|%s", project.Name, subtitle, columns, time.Now().Format("2006-01-02"))
+	for _, photo := range photos {
+		// Regenerated thumbnails don't change the photo's updated_at
+		var thumbTime int64
+		if info, err := os.Stat(ThumbPath(project.ID, photo.ID, ThumbSizeSmall)); err == nil {
+			thumbTime = info.ModTime().UnixNano()
+		}
+		fmt.Fprintf(h, "|%d:%d:%d", photo.ID, photo.UpdatedAt.UnixNano(), thumbTime)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// saveContactSheet caches a sheet and drops the least recently used sheets of the
+// project beyond maxCachedContactSheets. Failures only cost a re-render.
+func saveContactSheet(path string, data []byte) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("%s Failed to create %s: %v", contactSheetShortname, dir, err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("%s Failed to write %s: %v", contactSheetShortname, tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= maxCachedContactSheets {
+		return
+	}
+	type cached struct {
+		path    string
+		modTime time.Time
+	}
+	var sheets []cached
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && filepath.Ext(entry.Name()) == ".jpg" {
+			sheets = append(sheets, cached{filepath.Join(dir, entry.Name()), info.ModTime()})
+		}
+	}
+	sort.Slice(sheets, func(i, j int) bool { return sheets[i].modTime.After(sheets[j].modTime) })
+	for _, sheet := range sheets[min(maxCachedContactSheets, len(sheets)):] {
+		os.Remove(sheet.path)
+	}
+}
+
+// RemoveProjectContactSheets deletes the cached sheets of a deleted project
+func RemoveProjectContactSheets(projectID uint) {
+	if err := os.RemoveAll(contactSheetDir(projectID)); err != nil {
+		log.Printf("%s Failed to remove sheets of project %d: %v", contactSheetShortname, projectID, err)
+	}
+}
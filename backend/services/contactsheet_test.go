@@ -0,0 +1,61 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+func TestGenerateContactSheet(t *testing.T) {
+	wedding, portraits, photo := setupBulkTest(t)
+
+	sheet, err := GenerateContactSheet(wedding, models.ContactSheetQuery{})
+	if err != nil {
+		t.Fatalf("GenerateContactSheet() = %v", err)
+	}
+	if sheet.Photos != 2 || sheet.Missing != 1 {
+		t.Errorf("Expected 2 photos with one thumbnail missing, got %d and %d", sheet.Photos, sheet.Missing)
+	}
+	if _, err := os.Stat(contactSheetDir(wedding.ID)); !os.IsNotExist(err) {
+		t.Error("Expected a sheet with missing thumbnails not to be cached")
+	}
+
+	var cover models.Photo
+	database.DB.Where("project_id = ? AND base_name = ?", wedding.ID, "IMG_0001").First(&cover)
+	thumb, _ := utils.ComposeRawPlaceholder("IMG_0001", ".jpg", utils.ThumbSmallWidth, "")
+	SaveThumbnails(wedding.ID, cover.ID, thumb, thumb)
+	sheet, err = GenerateContactSheet(wedding, models.ContactSheetQuery{Columns: 4})
+	if err != nil || sheet.Missing != 0 {
+		t.Fatalf("Expected a complete sheet, got %+v (%v)", sheet, err)
+	}
+	cached, err := GenerateContactSheet(wedding, models.ContactSheetQuery{Columns: 4})
+	if err != nil || string(cached.Data) != string(sheet.Data) {
+		t.Error("Expected the cached sheet to be served")
+	}
+	if entries, _ := os.ReadDir(contactSheetDir(wedding.ID)); len(entries) != 1 {
+		t.Errorf("Expected one cached sheet, got %d", len(entries))
+	}
+
+	// The photos a client picked on a link
+	var link models.ShareLink
+	database.DB.Where("token = ?", "bulk-link").First(&link)
+	database.DB.Create(&models.PhotoSelection{LinkID: link.ID, PhotoID: photo.ID})
+	sheet, err = GenerateContactSheet(wedding, models.ContactSheetQuery{LinkID: link.ID})
+	if err != nil || sheet.Photos != 1 {
+		t.Errorf("Expected the selected photo only, got %+v (%v)", sheet, err)
+	}
+	if _, err := GenerateContactSheet(portraits, models.ContactSheetQuery{LinkID: link.ID}); err != ErrContactSheetLink {
+		t.Errorf("Expected ErrContactSheetLink for a link of another project, got %v", err)
+	}
+	if _, err := GenerateContactSheet(portraits, models.ContactSheetQuery{}); err != ErrNoContactSheetPhotos {
+		t.Errorf("Expected ErrNoContactSheetPhotos, got %v", err)
+	}
+
+	RemoveProjectContactSheets(wedding.ID)
+	if _, err := os.Stat(contactSheetDir(wedding.ID)); !os.IsNotExist(err) {
+		t.Error("Expected the cached sheets to be removed")
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+)
+
+const (
+	dbMonitorShortname = "[DBMonitor]"
+	dbSizeEventID      = "database.size"
+	dbGrowthEventID    = "database.growth"
+	// dbGrowthWindow is the period database growth is measured over
+	dbGrowthWindow = 24 * time.Hour
+	// dbAlertRepeat limits how often the same alert is sent
+	dbAlertRepeat = 24 * time.Hour
+)
+
+// DatabaseStats describes the size of the SQLite database. Only Driver is set for
+// PostgreSQL and MySQL.
+type DatabaseStats struct {
+	Driver         string     `json:"driver"`
+	Path           string     `json:"path,omitempty"`
+	SizeBytes      int64      `json:"size_bytes"`      // Main database file
+	WALBytes       int64      `json:"wal_bytes"`       // Write-ahead log not yet checkpointed
+	FreeBytes      int64      `json:"free_bytes"`      // Unused pages, reclaimed by VACUUM
+	PageSize       int64      `json:"page_size"`       // Bytes per page
+	PageCount      int64      `json:"page_count"`      // Pages of the main file
+	GrowthBytes    *int64     `json:"growth_bytes"`    // Growth of SizeBytes+WALBytes since GrowthSince
+	GrowthSince    *time.Time `json:"growth_since"`    // Oldest size sample within the last day
+	LastCheckpoint *time.Time `json:"last_checkpoint"` // Last checkpoint triggered by the WAL size
+	CheckpointMB   int        `json:"checkpoint_mb"`   // WAL_CHECKPOINT_MB
+}
+
+// TotalBytes is the space the database takes on disk
+func (s *DatabaseStats) TotalBytes() int64 {
+	return s.SizeBytes + s.WALBytes
+}
+
+type dbSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// dbMonitor keeps the size samples and alert state between checks
+type dbMonitor struct {
+	mu             sync.Mutex
+	samples        []dbSample
+	lastCheckpoint *time.Time
+	lastAlert      map[string]time.Time
+}
+
+var databaseMonitor = &dbMonitor{lastAlert: make(map[string]time.Time)}
+
+// ReadDatabaseStats measures the SQLite files and page counts, with the growth and
+// checkpoint state recorded by the monitor
+func ReadDatabaseStats() (*DatabaseStats, error) {
+	stats := &DatabaseStats{Driver: config.AppConfig.DatabaseDriver, CheckpointMB: config.AppConfig.WALCheckpointMB}
+	if !config.AppConfig.UsesSQLite() {
+		return stats, nil
+	}
+
+	stats.Path = config.AppConfig.DatabasePath
+	info, err := os.Stat(stats.Path)
+	if err != nil {
+		return nil, err
+	}
+	stats.SizeBytes = info.Size()
+	if info, err := os.Stat(stats.Path + "-wal"); err == nil {
+		stats.WALBytes = info.Size()
+	}
+
+	var freePages int64
+	database.DB.Raw("PRAGMA page_size").Scan(&stats.PageSize)
+	database.DB.Raw("PRAGMA page_count").Scan(&stats.PageCount)
+	database.DB.Raw("PRAGMA freelist_count").Scan(&freePages)
+	stats.FreeBytes = freePages * stats.PageSize
+
+	databaseMonitor.mu.Lock()
+	defer databaseMonitor.mu.Unlock()
+	if len(databaseMonitor.samples) > 0 {
+		oldest := databaseMonitor.samples[0]
+		growth := stats.TotalBytes() - oldest.bytes
+		stats.GrowthBytes = &growth
+		stats.GrowthSince = &oldest.at
+	}
+	stats.LastCheckpoint = databaseMonitor.lastCheckpoint
+	return stats, nil
+}
+
+// CheckpointWAL copies the WAL into the database and truncates it. busy is true when
+// readers kept part of it from being checkpointed.
+func CheckpointWAL() (busy bool, err error) {
+	var result struct {
+		Busy         int
+		Log          int
+		Checkpointed int
+	}
+	if err := database.DB.Raw("PRAGMA wal_checkpoint(TRUNCATE)").Row().Scan(&result.Busy, &result.Log, &result.Checkpointed); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	databaseMonitor.mu.Lock()
+	databaseMonitor.lastCheckpoint = &now
+	databaseMonitor.mu.Unlock()
+	return result.Busy != 0, nil
+}
+
+// StartDatabaseMonitor checks the SQLite database every DB_MONITOR_INTERVAL_MINUTES:
+// checkpoints a WAL grown past WAL_CHECKPOINT_MB (long reads can keep the automatic
+// checkpoint from ever catching up) and notifies about an oversized or fast-growing
+// database.
+func StartDatabaseMonitor() {
+	interval := time.Duration(config.AppConfig.DBMonitorInterval) * time.Minute
+	if !config.AppConfig.UsesSQLite() || interval <= 0 {
+		log.Printf("%s Disabled", dbMonitorShortname)
+		return
+	}
+
+	log.Printf("%s Started with interval %s", dbMonitorShortname, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			runDatabaseMonitor(time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+func runDatabaseMonitor(now time.Time) {
+	stats, err := ReadDatabaseStats()
+	if err != nil {
+		log.Printf("%s Failed to read database size: %v", dbMonitorShortname, err)
+		return
+	}
+
+	if threshold := int64(config.AppConfig.WALCheckpointMB) << 20; stats.WALBytes > threshold {
+		busy, err := CheckpointWAL()
+		switch {
+		case err != nil:
+			log.Printf("%s Checkpoint of a %d MB WAL failed: %v", dbMonitorShortname, stats.WALBytes>>20, err)
+		case busy:
+			log.Printf("%s Checkpoint of a %d MB WAL was partly blocked by readers", dbMonitorShortname, stats.WALBytes>>20)
+		default:
+			log.Printf("%s Checkpointed a %d MB WAL", dbMonitorShortname, stats.WALBytes>>20)
+		}
+		if err == nil {
+			if stats, err = ReadDatabaseStats(); err != nil {
+				return
+			}
+		}
+	}
+
+	databaseMonitor.record(now, stats.TotalBytes())
+	checkDatabaseAlerts(now, stats)
+}
+
+// record adds a size sample and drops the ones older than dbGrowthWindow
+func (m *dbMonitor) record(now time.Time, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, dbSample{at: now, bytes: bytes})
+	keep := 0
+	for keep < len(m.samples)-1 && now.Sub(m.samples[keep].at) > dbGrowthWindow {
+		keep++
+	}
+	m.samples = m.samples[keep:]
+}
+
+// shouldAlert reports whether an alert may be sent now, remembering it if so
+func (m *dbMonitor) shouldAlert(event string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if last, ok := m.lastAlert[event]; ok && now.Sub(last) < dbAlertRepeat {
+		return false
+	}
+	m.lastAlert[event] = now
+	return true
+}
+
+// checkDatabaseAlerts notifies when the database passes DB_SIZE_ALERT_MB or grew by
+// more than DB_GROWTH_ALERT_MB within the last day, at most daily per alert
+func checkDatabaseAlerts(now time.Time, stats *DatabaseStats) {
+	total := stats.TotalBytes()
+	data := map[string]interface{}{
+		"size_bytes": stats.SizeBytes,
+		"wal_bytes":  stats.WALBytes,
+		"free_bytes": stats.FreeBytes,
+	}
+
+	if limit := int64(config.AppConfig.DBSizeAlertMB) << 20; limit > 0 && total > limit && databaseMonitor.shouldAlert(dbSizeEventID, now) {
+		message := fmt.Sprintf("The database takes %d MB, more than DB_SIZE_ALERT_MB (%d MB)", total>>20, config.AppConfig.DBSizeAlertMB)
+		sendDatabaseAlert(dbSizeEventID, message, data)
+	}
+
+	limit := int64(config.AppConfig.DBGrowthAlertMB) << 20
+	if limit <= 0 || stats.GrowthBytes == nil || *stats.GrowthBytes <= limit {
+		return
+	}
+	if databaseMonitor.shouldAlert(dbGrowthEventID, now) {
+		data["growth_bytes"] = *stats.GrowthBytes
+		data["growth_since"] = stats.GrowthSince
+		message := fmt.Sprintf("The database grew by %d MB since %s, more than DB_GROWTH_ALERT_MB (%d MB)",
+			*stats.GrowthBytes>>20, stats.GrowthSince.Format("2006-01-02 15:04"), config.AppConfig.DBGrowthAlertMB)
+		sendDatabaseAlert(dbGrowthEventID, message, data)
+	}
+}
+
+func sendDatabaseAlert(event, message string, data map[string]interface{}) {
+	log.Printf("%s %s", dbMonitorShortname, message)
+	if err := Notify(event, message, data); err != nil {
+		log.Printf("%s Failed to send %s notification: %v", dbMonitorShortname, event, err)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupDatabaseMonitorTest opens a file database in WAL mode with a fresh monitor
+func setupDatabaseMonitorTest(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "photobridge.db")
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	sqlDB, _ := database.DB.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	sqlDB.SetMaxOpenConns(1)
+	database.DB.Exec("PRAGMA journal_mode=WAL")
+	database.DB.Exec("PRAGMA wal_autocheckpoint=0")
+	if err := database.DB.AutoMigrate(&models.Project{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	originalConfig, originalMonitor := config.AppConfig, databaseMonitor
+	t.Cleanup(func() { config.AppConfig, databaseMonitor = originalConfig, originalMonitor })
+	config.AppConfig = &config.Config{DatabasePath: path, WALCheckpointMB: 1}
+	databaseMonitor = &dbMonitor{lastAlert: make(map[string]time.Time)}
+}
+
+func TestDatabaseMonitorCheckpoint(t *testing.T) {
+	setupDatabaseMonitorTest(t)
+	description := strings.Repeat("x", 4096)
+	for i := 0; i < 400; i++ {
+		database.DB.Create(&models.Project{Name: fmt.Sprintf("project-%d", i), Description: description})
+	}
+
+	stats, err := ReadDatabaseStats()
+	if err != nil {
+		t.Fatalf("ReadDatabaseStats failed: %v", err)
+	}
+	if stats.WALBytes <= 1<<20 || stats.PageSize == 0 || stats.GrowthBytes != nil {
+		t.Fatalf("Expected a WAL over 1 MB and no growth yet, got %+v", stats)
+	}
+
+	runDatabaseMonitor(time.Now())
+	stats, _ = ReadDatabaseStats()
+	if stats.WALBytes != 0 || stats.LastCheckpoint == nil {
+		t.Errorf("Expected the WAL to be checkpointed and truncated, got %+v", stats)
+	}
+	if stats.GrowthBytes == nil || *stats.GrowthBytes != 0 {
+		t.Errorf("Expected no growth since the first sample, got %v", stats.GrowthBytes)
+	}
+}
+
+func TestDatabaseAlerts(t *testing.T) {
+	setupDatabaseMonitorTest(t)
+	webhook := newWebhookRecorder(t)
+	config.AppConfig.NotifyWebhookURL = webhook.URL
+	config.AppConfig.DBSizeAlertMB = 1
+	config.AppConfig.DBGrowthAlertMB = 1
+
+	now := time.Now()
+	databaseMonitor.record(now.Add(-2*time.Hour), 0)
+	growth := int64(3 << 20)
+	since := now.Add(-2 * time.Hour)
+	stats := &DatabaseStats{SizeBytes: 3 << 20, GrowthBytes: &growth, GrowthSince: &since}
+
+	checkDatabaseAlerts(now, stats)
+	checkDatabaseAlerts(now.Add(time.Hour), stats) // Repeats within a day are dropped
+	received := webhook.notifications()
+	if len(received) != 2 || received[0].Event != dbSizeEventID || received[1].Event != dbGrowthEventID {
+		t.Fatalf("Expected one size and one growth alert, got %+v", received)
+	}
+
+	checkDatabaseAlerts(now.Add(25*time.Hour), stats)
+	if received := webhook.notifications(); len(received) != 4 {
+		t.Errorf("Expected the alerts to repeat after a day, got %d", len(received))
+	}
+
+	// Old samples leave the growth window, keeping the newest one
+	databaseMonitor.record(now.Add(30*time.Hour), 5<<20)
+	if len(databaseMonitor.samples) != 1 || databaseMonitor.samples[0].bytes != 5<<20 {
+		t.Errorf("Expected only the newest sample to be kept, got %+v", databaseMonitor.samples)
+	}
+}
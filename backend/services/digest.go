@@ -0,0 +1,285 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	digestShortname = "[Digest]"
+	// digestHour is the local hour digests are sent at
+	digestHour = 8
+	// digestExpiryWindow is how far ahead the digest lists expiring links
+	digestExpiryWindow = 7 * 24 * time.Hour
+	// digestTopProjects limits the projects listed with their uploads
+	digestTopProjects = 5
+)
+
+// ErrMailDisabled means SMTP_HOST or ADMIN_EMAIL is not set
+var ErrMailDisabled = errors.New("email is not configured (SMTP_HOST, ADMIN_EMAIL)")
+
+// Digest summarizes the activity of a period for the admin digest email
+type Digest struct {
+	Schedule        string           `json:"schedule"` // weekly or monthly
+	From            time.Time        `json:"from"`
+	To              time.Time        `json:"to"`
+	NewPhotos       int64            `json:"new_photos"`
+	NewBytes        int64            `json:"new_bytes"`       // Size of the originals uploaded in the period
+	ProjectUploads  []ProjectUploads `json:"project_uploads"` // Projects with the most new photos
+	Views           int64            `json:"views"`
+	GalleriesViewed int64            `json:"galleries_viewed"` // Share links opened at least once
+	Visitors        int64            `json:"visitors"`         // Distinct IPs
+	Downloads       int64            `json:"downloads"`        // Single-photo downloads
+	ZipDownloads    int64            `json:"zip_downloads"`
+	TotalBytes      int64            `json:"total_bytes"` // Size of every original now
+	Database        *DatabaseStats   `json:"database,omitempty"`
+	ExpiringLinks   []ExpiringLink   `json:"expiring_links"` // Links expiring within a week after To
+}
+
+// ProjectUploads counts the photos uploaded to a project in a digest period
+type ProjectUploads struct {
+	ProjectID uint   `json:"project_id"`
+	Name      string `json:"name"`
+	Photos    int64  `json:"photos"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// ExpiringLink is a share link listed in the digest because it expires soon
+type ExpiringLink struct {
+	ID          uint      `json:"id"`
+	ProjectName string    `json:"project_name"`
+	Alias       string    `json:"alias"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// DigestPeriod returns the last complete period of a schedule before now: the
+// previous Monday-to-Monday week, or the previous calendar month (local time)
+func DigestPeriod(schedule string, now time.Time) (from, to time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if schedule == config.DigestMonthly {
+		to = midnight.AddDate(0, 0, 1-now.Day())
+		return to.AddDate(0, -1, 0), to
+	}
+	to = midnight.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+	return to.AddDate(0, 0, -7), to
+}
+
+// nextDigestAt returns when the next digest of a schedule is due after now: Mondays
+// or the 1st of the month at digestHour
+func nextDigestAt(schedule string, now time.Time) time.Time {
+	_, periodEnd := DigestPeriod(schedule, now)
+	next := periodEnd.Add(digestHour * time.Hour)
+	for !next.After(now) {
+		if schedule == config.DigestMonthly {
+			next = next.AddDate(0, 1, 0)
+		} else {
+			next = next.AddDate(0, 0, 7)
+		}
+	}
+	return next
+}
+
+// BuildDigest collects the uploads, link activity, storage and expiring links of a period
+func BuildDigest(schedule string, from, to time.Time) (*Digest, error) {
+	d := &Digest{Schedule: schedule, From: from, To: to, ProjectUploads: []ProjectUploads{}, ExpiringLinks: []ExpiringLink{}}
+
+	uploads := database.DB.Model(&models.Photo{}).Where("photos.created_at >= ? AND photos.created_at < ?", from, to)
+	var totals struct {
+		Photos int64
+		Bytes  int64
+	}
+	if err := uploads.Session(&gorm.Session{}).Select("COUNT(*) AS photos, COALESCE(SUM(normal_size + raw_size), 0) AS bytes").Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	d.NewPhotos, d.NewBytes = totals.Photos, totals.Bytes
+	err := uploads.Session(&gorm.Session{}).
+		Select("photos.project_id, projects.name, COUNT(*) AS photos, COALESCE(SUM(photos.normal_size + photos.raw_size), 0) AS bytes").
+		Joins("JOIN projects ON projects.id = photos.project_id").
+		Group("photos.project_id, projects.name").
+		Order("photos DESC, photos.project_id").
+		Limit(digestTopProjects).
+		Scan(&d.ProjectUploads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	logs := func() *gorm.DB {
+		return database.DB.Model(&models.AccessLog{}).Where("created_at >= ? AND created_at < ?", from, to)
+	}
+	var counts []struct {
+		Action string
+		Count  int64
+	}
+	if err := logs().Select("action, COUNT(*) AS count").Group("action").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		switch count.Action {
+		case models.LinkAccessView:
+			d.Views = count.Count
+		case models.LinkAccessDownload:
+			d.Downloads = count.Count
+		case models.LinkAccessZip:
+			d.ZipDownloads = count.Count
+		}
+	}
+	var viewed []uint
+	if err := logs().Where("action = ?", models.LinkAccessView).Distinct().Pluck("link_id", &viewed).Error; err != nil {
+		return nil, err
+	}
+	if err := logs().Distinct("ip").Count(&d.Visitors).Error; err != nil {
+		return nil, err
+	}
+
+	// Days of the period rolled up after ACCESS_LOG_RETENTION_DAYS, with visitors per day
+	var rolled struct {
+		Views        int64
+		Downloads    int64
+		ZipDownloads int64
+		Visitors     int64
+	}
+	err = database.DB.Model(&models.ProjectDailyStat{}).
+		Where("day >= ? AND day < ?", from, to).
+		Select("COALESCE(SUM(views), 0) AS views, COALESCE(SUM(downloads), 0) AS downloads, COALESCE(SUM(zip_downloads), 0) AS zip_downloads, COALESCE(SUM(visitors), 0) AS visitors").
+		Scan(&rolled).Error
+	if err != nil {
+		return nil, err
+	}
+	d.Views += rolled.Views
+	d.Downloads += rolled.Downloads
+	d.ZipDownloads += rolled.ZipDownloads
+	d.Visitors += rolled.Visitors
+	var rolledViewed []uint
+	if err := database.DB.Model(&models.LinkDailyStat{}).Where("day >= ? AND day < ? AND views > 0", from, to).Distinct().Pluck("link_id", &rolledViewed).Error; err != nil {
+		return nil, err
+	}
+	galleries := map[uint]struct{}{}
+	for _, id := range append(viewed, rolledViewed...) {
+		galleries[id] = struct{}{}
+	}
+	d.GalleriesViewed = int64(len(galleries))
+
+	if err := database.DB.Model(&models.Photo{}).Select("COALESCE(SUM(normal_size + raw_size), 0)").Scan(&d.TotalBytes).Error; err != nil {
+		return nil, err
+	}
+	if config.AppConfig.UsesSQLite() {
+		if stats, err := ReadDatabaseStats(); err == nil {
+			d.Database = stats
+		}
+	}
+
+	err = database.DB.Model(&models.ShareLink{}).
+		Select("share_links.id, projects.name AS project_name, share_links.alias, share_links.expires_at").
+		Joins("JOIN projects ON projects.id = share_links.project_id AND projects.deleted_at IS NULL").
+		Where("share_links.expires_at >= ? AND share_links.expires_at < ?", to, to.Add(digestExpiryWindow)).
+		Order("share_links.expires_at").
+		Scan(&d.ExpiringLinks).Error
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Subject is the subject line of the digest email
+func (d *Digest) Subject() string {
+	return fmt.Sprintf("PhotoBridge %s digest: %s - %s", d.Schedule, d.From.Format("2006-01-02"), d.To.AddDate(0, 0, -1).Format("2006-01-02"))
+}
+
+// Text renders the digest as the plain text email body
+func (d *Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Activity from %s to %s\n\n", d.From.Format("Mon 2006-01-02"), d.To.AddDate(0, 0, -1).Format("Mon 2006-01-02"))
+
+	fmt.Fprintf(&b, "Uploads: %d photos (%s)\n", d.NewPhotos, formatBytes(d.NewBytes))
+	for _, project := range d.ProjectUploads {
+		fmt.Fprintf(&b, "  - %s: %d photos (%s)\n", project.Name, project.Photos, formatBytes(project.Bytes))
+	}
+	fmt.Fprintf(&b, "\nGalleries viewed: %d (%d views by %d visitors)\n", d.GalleriesViewed, d.Views, d.Visitors)
+	fmt.Fprintf(&b, "Downloads: %d single-photo, %d full-gallery ZIP\n", d.Downloads, d.ZipDownloads)
+
+	fmt.Fprintf(&b, "\nStorage: %s of originals, %s added in this period\n", formatBytes(d.TotalBytes), formatBytes(d.NewBytes))
+	if d.Database != nil {
+		fmt.Fprintf(&b, "Database: %s", formatBytes(d.Database.TotalBytes()))
+		if d.Database.GrowthBytes != nil {
+			fmt.Fprintf(&b, " (%+d MB in the last day)", *d.Database.GrowthBytes>>20)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(d.ExpiringLinks) == 0 {
+		b.WriteString("\nNo share links expire in the next 7 days.\n")
+	} else {
+		fmt.Fprintf(&b, "\nShare links expiring in the next 7 days:\n")
+		for _, link := range d.ExpiringLinks {
+			name := link.Alias
+			if name == "" {
+				name = fmt.Sprintf("link %d", link.ID)
+			}
+			fmt.Fprintf(&b, "  - %s / %s: %s\n", link.ProjectName, name, link.ExpiresAt.Local().Format("Mon 2006-01-02 15:04"))
+		}
+	}
+	return b.String()
+}
+
+// SendDigest builds the digest of the last complete period before now and emails it
+// to ADMIN_EMAIL
+func SendDigest(schedule string, now time.Time) (*Digest, error) {
+	if !config.AppConfig.MailEnabled() {
+		return nil, ErrMailDisabled
+	}
+	from, to := DigestPeriod(schedule, now)
+	digest, err := BuildDigest(schedule, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build digest: %w", err)
+	}
+	if err := utils.SendMail(config.AppConfig.AdminEmails, digest.Subject(), digest.Text()); err != nil {
+		return nil, err
+	}
+	log.Printf("%s Sent %s digest to %s", digestShortname, schedule, strings.Join(config.AppConfig.AdminEmails, ", "))
+	return digest, nil
+}
+
+// StartDigestMailer emails the activity digest on DIGEST_SCHEDULE (Mondays or the 1st
+// of the month at 08:00 local time). A digest due while the server was down is skipped.
+func StartDigestMailer() {
+	schedule := config.AppConfig.DigestSchedule
+	if !config.AppConfig.MailEnabled() || (schedule != config.DigestWeekly && schedule != config.DigestMonthly) {
+		log.Printf("%s Disabled", digestShortname)
+		return
+	}
+
+	log.Printf("%s Sending the %s digest, next on %s", digestShortname, schedule, nextDigestAt(schedule, time.Now()).Format("2006-01-02 15:04"))
+	go func() {
+		for {
+			due := nextDigestAt(schedule, time.Now())
+			time.Sleep(time.Until(due))
+			if _, err := SendDigest(schedule, due); err != nil {
+				log.Printf("%s Failed to send the %s digest: %v", digestShortname, schedule, err)
+			}
+		}
+	}()
+}
+
+// formatBytes renders a size with a binary unit, e.g. "3.2 GB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
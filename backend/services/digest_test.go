@@ -0,0 +1,124 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestDigestPeriod(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	wednesday := time.Date(2026, 10, 14, 15, 30, 0, 0, loc)
+
+	from, to := DigestPeriod(config.DigestWeekly, wednesday)
+	if want := time.Date(2026, 10, 12, 0, 0, 0, 0, loc); !to.Equal(want) || !from.Equal(want.AddDate(0, 0, -7)) {
+		t.Errorf("weekly period = %v - %v, want the week before %v", from, to, want)
+	}
+	from, to = DigestPeriod(config.DigestMonthly, wednesday)
+	if !from.Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, loc)) || !to.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, loc)) {
+		t.Errorf("monthly period = %v - %v, want September", from, to)
+	}
+
+	tests := []struct {
+		schedule string
+		now      time.Time
+		want     time.Time
+	}{
+		{config.DigestWeekly, wednesday, time.Date(2026, 10, 19, 8, 0, 0, 0, loc)},
+		{config.DigestWeekly, time.Date(2026, 10, 19, 7, 59, 0, 0, loc), time.Date(2026, 10, 19, 8, 0, 0, 0, loc)},
+		{config.DigestWeekly, time.Date(2026, 10, 19, 8, 0, 0, 0, loc), time.Date(2026, 10, 26, 8, 0, 0, 0, loc)},
+		{config.DigestMonthly, wednesday, time.Date(2026, 11, 1, 8, 0, 0, 0, loc)},
+		{config.DigestMonthly, time.Date(2026, 12, 31, 23, 0, 0, 0, loc), time.Date(2027, 1, 1, 8, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		if got := nextDigestAt(tt.schedule, tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextDigestAt(%s, %v) = %v, want %v", tt.schedule, tt.now, got, tt.want)
+		}
+	}
+	// The digest sent when due covers the week just ended
+	due := nextDigestAt(config.DigestWeekly, wednesday)
+	if _, to := DigestPeriod(config.DigestWeekly, due); !to.Equal(time.Date(2026, 10, 19, 0, 0, 0, 0, loc)) {
+		t.Errorf("digest due %v covers the week until %v", due, to)
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	project := setupProjectTest(t)
+	if err := database.DB.AutoMigrate(&models.AccessLog{}, &models.LinkDailyStat{}, &models.LinkPhotoDailyStat{}, &models.ProjectDailyStat{}); err != nil {
+		t.Fatalf("Failed to migrate access log: %v", err)
+	}
+	config.AppConfig.DatabaseDriver = "postgres" // no SQLite file to measure
+
+	from := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	inside, before := from.Add(time.Hour), from.Add(-time.Hour)
+
+	database.DB.Model(&models.Photo{}).Where("project_id = ?", project.ID).UpdateColumns(map[string]interface{}{"created_at": before, "normal_size": 500})
+	other := models.Project{Name: "portraits"}
+	database.DB.Create(&other)
+	for _, photo := range []models.Photo{
+		{ProjectID: project.ID, BaseName: "IMG_0002", NormalExt: ".jpg", NormalSize: 1000, RawSize: 3000, CreatedAt: inside},
+		{ProjectID: project.ID, BaseName: "IMG_0003", NormalExt: ".jpg", NormalSize: 1000, CreatedAt: inside},
+		{ProjectID: other.ID, BaseName: "IMG_0004", NormalExt: ".jpg", NormalSize: 2000, CreatedAt: inside},
+	} {
+		if err := database.DB.Create(&photo).Error; err != nil {
+			t.Fatalf("Failed to create photo: %v", err)
+		}
+	}
+
+	for _, entry := range []models.AccessLog{
+		{LinkID: 1, Action: models.LinkAccessView, IP: "10.0.0.1", CreatedAt: inside},
+		{LinkID: 1, Action: models.LinkAccessView, IP: "10.0.0.2", CreatedAt: inside},
+		{LinkID: 2, Action: models.LinkAccessView, IP: "10.0.0.1", CreatedAt: inside},
+		{LinkID: 1, Action: models.LinkAccessDownload, IP: "10.0.0.2", CreatedAt: inside},
+		{LinkID: 1, Action: models.LinkAccessZip, IP: "10.0.0.3", CreatedAt: inside},
+		{LinkID: 3, Action: models.LinkAccessView, IP: "10.0.0.9", CreatedAt: before},
+	} {
+		entry.ProjectID = project.ID
+		database.DB.Create(&entry)
+	}
+
+	soon, later := to.Add(48*time.Hour), to.Add(30*24*time.Hour)
+	database.DB.Create(&models.ShareLink{ProjectID: project.ID, Token: "soon", Alias: "family", ExpiresAt: &soon})
+	database.DB.Create(&models.ShareLink{ProjectID: project.ID, Token: "later", ExpiresAt: &later})
+	database.DB.Create(&models.ShareLink{ProjectID: project.ID, Token: "forever"})
+
+	d, err := BuildDigest(config.DigestWeekly, from, to)
+	if err != nil {
+		t.Fatalf("BuildDigest() = %v", err)
+	}
+	if d.NewPhotos != 3 || d.NewBytes != 7000 || d.TotalBytes != 7500 {
+		t.Errorf("uploads = %d photos, %d bytes of %d, want 3, 7000 of 7500", d.NewPhotos, d.NewBytes, d.TotalBytes)
+	}
+	if len(d.ProjectUploads) != 2 || d.ProjectUploads[0].Name != "wedding" || d.ProjectUploads[0].Photos != 2 || d.ProjectUploads[0].Bytes != 5000 {
+		t.Errorf("ProjectUploads = %+v, want wedding (2 photos, 5000 bytes) first", d.ProjectUploads)
+	}
+	if d.Views != 3 || d.GalleriesViewed != 2 || d.Visitors != 3 || d.Downloads != 1 || d.ZipDownloads != 1 {
+		t.Errorf("activity = %d views of %d galleries by %d visitors, %d downloads, %d zips; want 3, 2, 3, 1, 1",
+			d.Views, d.GalleriesViewed, d.Visitors, d.Downloads, d.ZipDownloads)
+	}
+	if len(d.ExpiringLinks) != 1 || d.ExpiringLinks[0].Alias != "family" || d.ExpiringLinks[0].ProjectName != "wedding" {
+		t.Errorf("ExpiringLinks = %+v, want the family link", d.ExpiringLinks)
+	}
+
+	text := d.Text()
+	for _, want := range []string{"Uploads: 3 photos (6.8 KB)", "wedding: 2 photos", "Galleries viewed: 2 (3 views by 3 visitors)", "Downloads: 1 single-photo, 1 full-gallery ZIP", "wedding / family"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("digest text lacks %q:\n%s", want, text)
+		}
+	}
+	if subject := d.Subject(); subject != "PhotoBridge weekly digest: 2026-10-05 - 2026-10-11" {
+		t.Errorf("Subject() = %q", subject)
+	}
+}
+
+func TestSendDigestWithoutMail(t *testing.T) {
+	setupProjectTest(t)
+	if _, err := SendDigest(config.DigestWeekly, time.Now()); err != ErrMailDisabled {
+		t.Errorf("SendDigest() without SMTP = %v, want ErrMailDisabled", err)
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+)
+
+const (
+	dimensionsShortname = "[Dimensions]"
+	dimensionsBatchSize = 200
+)
+
+// PhotoAspectRatio returns width/height of a photo for layout purposes.
+// Source dimensions are preferred; RAW-only photos use the 3:2 placeholder tile.
+// Returns 0 when nothing is known yet.
+func PhotoAspectRatio(photo *models.Photo) float64 {
+	switch {
+	case photo.Width > 0 && photo.Height > 0:
+		return float64(photo.Width) / float64(photo.Height)
+	case photo.ThumbWidth > 0 && photo.ThumbHeight > 0:
+		return float64(photo.ThumbWidth) / float64(photo.ThumbHeight)
+	case IsRawOnly(photo):
+		return 3.0 / 2.0
+	}
+	return 0
+}
+
+// BackfillPhotoDimensions fills in width/height for photos uploaded before they were recorded.
+// Dimensions of generated thumbnails are reused; other images only have their header read.
+// Returns the number of photos updated.
+func BackfillPhotoDimensions() int {
+	type photoRow struct {
+		ID          uint
+		BaseName    string
+		NormalExt   string
+		ThumbWidth  int
+		ThumbHeight int
+		ProjectName string
+	}
+
+	updated := 0
+	lastID := uint(0)
+	for {
+		var photos []photoRow
+		err := database.DB.Model(&models.Photo{}).
+			Select("photos.id, photos.base_name, photos.normal_ext, photos.thumb_width, photos.thumb_height, projects.name AS project_name").
+			Joins("JOIN projects ON projects.id = photos.project_id").
+			Where("photos.id > ? AND photos.width = 0 AND photos.normal_ext <> ''", lastID).
+			Order("photos.id").Limit(dimensionsBatchSize).
+			Scan(&photos).Error
+		if err != nil {
+			log.Printf("%s Failed to query photos: %v", dimensionsShortname, err)
+			return updated
+		}
+		if len(photos) == 0 {
+			break
+		}
+
+		for _, photo := range photos {
+			lastID = photo.ID
+			width, height := photo.ThumbWidth, photo.ThumbHeight
+			if width == 0 || height == 0 {
+				path, release, err := storage.Fetch(context.Background(), storage.Key(photo.ProjectName, photo.BaseName+photo.NormalExt))
+				if err != nil {
+					continue
+				}
+				var ok bool
+				width, height, ok = utils.ReadImageSize(path)
+				release()
+				if !ok {
+					continue
+				}
+			}
+			if err := database.DB.Model(&models.Photo{}).Where("id = ?", photo.ID).
+				Updates(map[string]interface{}{"width": width, "height": height}).Error; err == nil {
+				updated++
+			}
+		}
+	}
+
+	if updated > 0 {
+		log.Printf("%s Recorded dimensions of %d photos", dimensionsShortname, updated)
+	}
+	return updated
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestBackfillPhotoDimensions(t *testing.T) {
+	project := setupProjectTest(t)
+
+	// Replace the dummy file with a real 300x200 JPEG
+	file, err := os.Create(filepath.Join(config.AppConfig.UploadDir, project.Name, "IMG_0001.jpg"))
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	jpeg.Encode(file, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	file.Close()
+
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "IMG_0002", NormalExt: ".jpg", ThumbWidth: 400, ThumbHeight: 600})
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "IMG_0003", NormalExt: ".jpg"}) // file missing
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "DSC_0004", RawExt: ".nef", HasRaw: true})
+
+	if updated := BackfillPhotoDimensions(); updated != 2 {
+		t.Errorf("Expected 2 photos updated, got %d", updated)
+	}
+
+	expected := map[string][2]int{"IMG_0001": {300, 200}, "IMG_0002": {400, 600}, "IMG_0003": {0, 0}, "DSC_0004": {0, 0}}
+	var photos []models.Photo
+	database.DB.Find(&photos)
+	for _, photo := range photos {
+		if want := expected[photo.BaseName]; photo.Width != want[0] || photo.Height != want[1] {
+			t.Errorf("%s: expected %dx%d, got %dx%d", photo.BaseName, want[0], want[1], photo.Width, photo.Height)
+		}
+	}
+
+	if updated := BackfillPhotoDimensions(); updated != 0 {
+		t.Errorf("Second run should not update anything, got %d", updated)
+	}
+}
+
+func TestPhotoAspectRatio(t *testing.T) {
+	tests := []struct {
+		name  string
+		photo models.Photo
+		want  float64
+	}{
+		{"source dimensions", models.Photo{NormalExt: ".jpg", Width: 4000, Height: 2000, ThumbWidth: 10, ThumbHeight: 10}, 2},
+		{"thumbnail dimensions", models.Photo{NormalExt: ".jpg", ThumbWidth: 300, ThumbHeight: 400}, 0.75},
+		{"raw placeholder", models.Photo{RawExt: ".cr2", HasRaw: true}, 1.5},
+		{"unknown", models.Photo{NormalExt: ".jpg"}, 0},
+	}
+	for _, tt := range tests {
+		if got := PhotoAspectRatio(&tt.photo); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	downloadJobShortname = "[DownloadJob]"
+	// downloadJobWorkers is how many archives are written at the same time
+	downloadJobWorkers = 2
+	// downloadJobQueueLength limits the jobs waiting for a worker
+	downloadJobQueueLength = 32
+	// downloadJobsPerLink limits the unfinished jobs of one share link
+	downloadJobsPerLink = 3
+	// downloadJobSweepInterval is how often expired archives are deleted
+	downloadJobSweepInterval = time.Minute
+)
+
+// downloadJobFile matches the names of the archives the store writes, the only files
+// it deletes in its directory
+var downloadJobFile = regexp.MustCompile(`^download-job-[0-9a-f]{24}\.zip$`)
+
+// Download job states
+const (
+	DownloadJobQueued  = "queued"
+	DownloadJobRunning = "running"
+	DownloadJobReady   = "ready"
+	DownloadJobFailed  = "failed"
+)
+
+var (
+	// ErrDownloadJobsDisabled is returned when DOWNLOAD_JOB_TTL_MINUTES is 0
+	ErrDownloadJobsDisabled = errors.New("download jobs are disabled")
+	// ErrDownloadJobsBusy is returned when the queue, the link's job limit or the disk
+	// budget is exhausted
+	ErrDownloadJobsBusy = errors.New("too many archives are being prepared, try again later")
+	// ErrDownloadJobNotFound is returned for unknown and expired jobs
+	ErrDownloadJobNotFound = errors.New("download job not found")
+	// ErrDownloadJobNotReady is returned when fetching an archive that isn't finished
+	ErrDownloadJobNotReady = errors.New("archive is not ready")
+)
+
+// DownloadJobs prepares archives in the background; nil when download jobs are disabled
+var DownloadJobs *DownloadJobStore
+
+// DownloadJob reports the state of an archive prepared in the background
+type DownloadJob struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	FileName  string     `json:"file_name"`
+	Files     int        `json:"files"`
+	Size      int64      `json:"size"` // Expected archive size
+	Written   int64      `json:"written"`
+	Progress  int        `json:"progress"` // Percent of Size written
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Set once the job is finished
+}
+
+// downloadJob is a job with what the store needs to run and serve it
+type downloadJob struct {
+	DownloadJob
+	linkID  uint
+	key     string // Identifies the archive on its link, so repeated requests share a job
+	path    string
+	write   func(io.Writer) error
+	payload interface{}
+	written atomic.Int64
+}
+
+// DownloadJobStore queues archives to be written by a few workers into dir, where
+// finished archives are kept for ttl. Archives in progress and kept count against
+// maxBytes by their expected size.
+type DownloadJobStore struct {
+	dir      string
+	ttl      time.Duration
+	maxBytes int64
+
+	mu    sync.Mutex
+	jobs  map[string]*downloadJob
+	queue chan *downloadJob
+	now   func() time.Time
+}
+
+// NewDownloadJobStore creates a store in dir, removing archives left over from a
+// previous run (their jobs are gone). Other files in dir are left alone. maxBytes 0
+// means no disk budget.
+func NewDownloadJobStore(dir string, ttl time.Duration, maxBytes int64) (*DownloadJobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download job directory: %w", err)
+	}
+	if err := removeLeftovers(dir, downloadJobFile); err != nil {
+		return nil, fmt.Errorf("failed to clear download job directory: %w", err)
+	}
+	return &DownloadJobStore{
+		dir:      dir,
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		jobs:     make(map[string]*downloadJob),
+		queue:    make(chan *downloadJob, downloadJobQueueLength),
+		now:      time.Now,
+	}, nil
+}
+
+// removeLeftovers deletes the files in dir whose names match pattern, left over from a
+// previous run. Other files are never touched, as dir may be shared.
+func removeLeftovers(dir string, pattern *regexp.Regexp) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !pattern.MatchString(entry.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// InitDownloadJobs sets up the global download job store and starts its workers and
+// the deletion of expired archives (ttlMinutes 0 = disabled)
+func InitDownloadJobs(dir string, ttlMinutes, maxMB int) {
+	if ttlMinutes <= 0 {
+		log.Printf("%s Disabled", downloadJobShortname)
+		return
+	}
+	store, err := NewDownloadJobStore(dir, time.Duration(ttlMinutes)*time.Minute, int64(maxMB)<<20)
+	if err != nil {
+		log.Printf("%s %v, archives can only be streamed", downloadJobShortname, err)
+		return
+	}
+	for i := 0; i < downloadJobWorkers; i++ {
+		go store.work()
+	}
+	go func() {
+		ticker := time.NewTicker(downloadJobSweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			store.Sweep()
+		}
+	}()
+	DownloadJobs = store
+	log.Printf("%s Using %s, archives are kept for %d minutes", downloadJobShortname, dir, ttlMinutes)
+}
+
+// Submit queues an archive of files files and about size bytes for a share link, to
+// be written by write. key identifies the archive among the link's downloads: while a
+// job with the same key is unfinished or kept, it is returned instead. payload is kept
+// for the caller and returned by Open.
+func (s *DownloadJobStore) Submit(linkID uint, key, fileName string, files int, size int64, write func(io.Writer) error, payload interface{}) (DownloadJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unfinished := 0
+	var reserved int64
+	for _, job := range s.jobs {
+		if job.Status != DownloadJobFailed {
+			reserved += job.Size
+		}
+		if job.linkID != linkID {
+			continue
+		}
+		if job.key == key && job.Status != DownloadJobFailed {
+			return job.snapshot(), nil
+		}
+		if job.Status == DownloadJobQueued || job.Status == DownloadJobRunning {
+			unfinished++
+		}
+	}
+	if unfinished >= downloadJobsPerLink || (s.maxBytes > 0 && reserved+size > s.maxBytes) {
+		return DownloadJob{}, ErrDownloadJobsBusy
+	}
+
+	id, err := newDownloadJobID()
+	if err != nil {
+		return DownloadJob{}, err
+	}
+	job := &downloadJob{
+		DownloadJob: DownloadJob{
+			ID:        id,
+			Status:    DownloadJobQueued,
+			FileName:  fileName,
+			Files:     files,
+			Size:      size,
+			CreatedAt: s.now(),
+		},
+		linkID:  linkID,
+		key:     key,
+		path:    filepath.Join(s.dir, "download-job-"+id+".zip"),
+		write:   write,
+		payload: payload,
+	}
+	select {
+	case s.queue <- job:
+	default:
+		return DownloadJob{}, ErrDownloadJobsBusy
+	}
+	s.jobs[id] = job
+	return job.snapshot(), nil
+}
+
+// newDownloadJobID returns a random job ID, which is hard to guess so jobs of a link
+// aren't found by other visitors of it
+func newDownloadJobID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Get reports a job of a share link
+func (s *DownloadJobStore) Get(linkID uint, id string) (DownloadJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok || job.linkID != linkID {
+		return DownloadJob{}, ErrDownloadJobNotFound
+	}
+	return job.snapshot(), nil
+}
+
+// Open returns the finished archive of a job with the job and its payload
+func (s *DownloadJobStore) Open(linkID uint, id string) (*os.File, DownloadJob, interface{}, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	if !ok || job.linkID != linkID {
+		s.mu.Unlock()
+		return nil, DownloadJob{}, nil, ErrDownloadJobNotFound
+	}
+	info, payload, path := job.snapshot(), job.payload, job.path
+	s.mu.Unlock()
+
+	if info.Status != DownloadJobReady {
+		return nil, info, nil, ErrDownloadJobNotReady
+	}
+	// A sweep removing the file after this keeps the open handle working
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, info, nil, err
+	}
+	return file, info, payload, nil
+}
+
+// Sweep deletes finished jobs past their expiry with their archives, returning how
+// many were deleted
+func (s *DownloadJobStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	removed := 0
+	for id, job := range s.jobs {
+		if job.ExpiresAt == nil || now.Before(*job.ExpiresAt) {
+			continue
+		}
+		if err := os.Remove(job.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("%s Failed to remove archive %s: %v", downloadJobShortname, job.FileName, err)
+		}
+		delete(s.jobs, id)
+		removed++
+	}
+	return removed
+}
+
+// work writes the archives of queued jobs
+func (s *DownloadJobStore) work() {
+	for job := range s.queue {
+		s.run(job)
+	}
+}
+
+// run writes the archive of a job and marks it ready or failed
+func (s *DownloadJobStore) run(job *downloadJob) {
+	s.setStatus(job, DownloadJobRunning, nil)
+	started := time.Now()
+	err := s.writeArchive(job)
+	if err != nil {
+		os.Remove(job.path)
+		log.Printf("%s Failed to prepare %s: %v", downloadJobShortname, job.FileName, err)
+	} else {
+		log.Printf("%s Prepared %s (%d files, %d bytes) in %s", downloadJobShortname, job.FileName, job.Files, job.written.Load(), time.Since(started).Round(time.Second))
+	}
+	s.setStatus(job, DownloadJobReady, err)
+}
+
+func (s *DownloadJobStore) writeArchive(job *downloadJob) error {
+	file, err := os.Create(job.path)
+	if err != nil {
+		return err
+	}
+	if err := job.write(&jobWriter{file: file, written: &job.written}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// setStatus moves a job on; a finished job (ready, or failed with err) expires after the TTL
+func (s *DownloadJobStore) setStatus(job *downloadJob, status string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.Status = status
+	if status == DownloadJobRunning {
+		return
+	}
+	if err != nil {
+		job.Status, job.Error = DownloadJobFailed, err.Error()
+	}
+	expires := s.now().Add(s.ttl)
+	job.ExpiresAt = &expires
+	job.write = nil
+}
+
+// snapshot copies the job for callers; the caller holds the store lock
+func (job *downloadJob) snapshot() DownloadJob {
+	info := job.DownloadJob
+	info.Written = job.written.Load()
+	switch {
+	case info.Status == DownloadJobReady:
+		info.Progress = 100
+	case info.Size > 0:
+		// The size of object storage archives is estimated, so 100 waits for the end
+		info.Progress = int(min(info.Written*100/info.Size, 99))
+	}
+	return info
+}
+
+// jobWriter writes an archive to its file, counting the bytes for progress reports
+type jobWriter struct {
+	file    *os.File
+	written *atomic.Int64
+}
+
+func (w *jobWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.written.Add(int64(n))
+	return n, err
+}
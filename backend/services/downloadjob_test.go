@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestDownloadJobStore(t *testing.T, maxBytes int64) *DownloadJobStore {
+	t.Helper()
+	store, err := NewDownloadJobStore(filepath.Join(t.TempDir(), "jobs"), time.Hour, maxBytes)
+	if err != nil {
+		t.Fatalf("NewDownloadJobStore failed: %v", err)
+	}
+	return store
+}
+
+// writeString returns an archive writer that writes data
+func writeString(data string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, data)
+		return err
+	}
+}
+
+func TestDownloadJobLifecycle(t *testing.T) {
+	store := newTestDownloadJobStore(t, 0)
+
+	job, err := store.Submit(1, "normal/0", "p-normal.zip", 2, 8, writeString("zipdata!"), "payload")
+	if err != nil || job.Status != DownloadJobQueued || job.Progress != 0 {
+		t.Fatalf("Submit = %+v, %v", job, err)
+	}
+	// The same archive of the link shares the job, other links don't see it
+	if again, _ := store.Submit(1, "normal/0", "p-normal.zip", 2, 8, writeString("other"), nil); again.ID != job.ID {
+		t.Errorf("Expected the queued job to be reused, got %s", again.ID)
+	}
+	if _, err := store.Get(2, job.ID); !errors.Is(err, ErrDownloadJobNotFound) {
+		t.Errorf("Expected the job to be hidden from other links, got %v", err)
+	}
+	if _, _, _, err := store.Open(1, job.ID); !errors.Is(err, ErrDownloadJobNotReady) {
+		t.Errorf("Expected ErrDownloadJobNotReady, got %v", err)
+	}
+
+	store.run(<-store.queue)
+	done, err := store.Get(1, job.ID)
+	if err != nil || done.Status != DownloadJobReady || done.Progress != 100 || done.Written != 8 || done.ExpiresAt == nil {
+		t.Fatalf("Expected a ready job, got %+v, %v", done, err)
+	}
+	file, _, payload, err := store.Open(1, job.ID)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "zipdata!" || payload != "payload" {
+		t.Errorf("Open returned %q with payload %v", data, payload)
+	}
+
+	// Finished jobs are kept until they expire
+	if removed := store.Sweep(); removed != 0 {
+		t.Errorf("Expected nothing to expire yet, removed %d", removed)
+	}
+	store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if removed := store.Sweep(); removed != 1 {
+		t.Errorf("Expected the job to expire, removed %d", removed)
+	}
+	if _, _, _, err := store.Open(1, job.ID); !errors.Is(err, ErrDownloadJobNotFound) {
+		t.Errorf("Expected an expired job to be gone, got %v", err)
+	}
+}
+
+func TestNewDownloadJobStoreKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	leftover := filepath.Join(dir, "download-job-0123456789abcdef01234567.zip")
+	for _, path := range []string{leftover, filepath.Join(dir, "photo.jpg"), filepath.Join(dir, "backup.zip")} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "uploads"), 0755); err != nil {
+		t.Fatalf("Failed to create a subdirectory: %v", err)
+	}
+
+	if _, err := NewDownloadJobStore(dir, time.Hour, 0); err != nil {
+		t.Fatalf("NewDownloadJobStore failed: %v", err)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("Expected the leftover archive to be removed, got %v", err)
+	}
+	for _, name := range []string{"photo.jpg", "backup.zip", "uploads"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", name, err)
+		}
+	}
+}
+
+func TestDownloadJobFailure(t *testing.T) {
+	store := newTestDownloadJobStore(t, 0)
+	job, _ := store.Submit(1, "raw/0", "p-raw.zip", 1, 10, func(io.Writer) error {
+		return errors.New("bucket unavailable")
+	}, nil)
+	store.run(<-store.queue)
+
+	failed, _ := store.Get(1, job.ID)
+	if failed.Status != DownloadJobFailed || failed.Error != "bucket unavailable" {
+		t.Errorf("Expected a failed job, got %+v", failed)
+	}
+	// A failed archive is retried by a new job
+	if retry, _ := store.Submit(1, "raw/0", "p-raw.zip", 1, 10, writeString("x"), nil); retry.ID == job.ID {
+		t.Error("Expected a new job after a failure")
+	}
+}
+
+func TestDownloadJobLimits(t *testing.T) {
+	store := newTestDownloadJobStore(t, 100)
+
+	if _, err := store.Submit(1, "all/0", "a.zip", 1, 101, writeString(""), nil); !errors.Is(err, ErrDownloadJobsBusy) {
+		t.Errorf("Expected an archive over the budget to be refused, got %v", err)
+	}
+	for part := 1; part <= downloadJobsPerLink; part++ {
+		if _, err := store.Submit(1, "all/"+strconv.Itoa(part), "a.zip", 1, 10, writeString(""), nil); err != nil {
+			t.Fatalf("Submit of part %d failed: %v", part, err)
+		}
+	}
+	if _, err := store.Submit(1, "all/9", "a.zip", 1, 10, writeString(""), nil); !errors.Is(err, ErrDownloadJobsBusy) {
+		t.Errorf("Expected the link's job limit to apply, got %v", err)
+	}
+	if _, err := store.Submit(2, "all/0", "b.zip", 1, 80, writeString(""), nil); !errors.Is(err, ErrDownloadJobsBusy) {
+		t.Errorf("Expected queued archives to count against the budget, got %v", err)
+	}
+	if _, err := store.Submit(2, "all/0", "b.zip", 1, 70, writeString(""), nil); err != nil {
+		t.Errorf("Expected another link's job within the budget to be queued, got %v", err)
+	}
+}
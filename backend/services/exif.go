@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+const (
+	exifShortname = "[Exif]"
+	exifBatchSize = 200
+)
+
+var (
+	exifOrientations = map[int]string{
+		1: "Normal", 2: "Flip Horizontal", 3: "Rotate 180", 4: "Flip Vertical",
+		5: "Transpose", 6: "Rotate 90 CW", 7: "Transverse", 8: "Rotate 90 CCW",
+	}
+	exifExposureModes = map[int]string{0: "Auto", 1: "Manual", 2: "Auto Bracket"}
+	exifWhiteBalances = map[int]string{0: "Auto", 1: "Manual"}
+	exifMeteringModes = map[int]string{
+		1: "Average", 2: "Center Weighted", 3: "Spot", 4: "Multi Spot", 5: "Pattern", 6: "Partial",
+	}
+)
+
+// ReadPhotoExif reads the EXIF summary and capture time of an image or RAW file. The
+// summary is marked scanned even when the file has no EXIF, so it isn't read again;
+// takenAt is nil without a usable date.
+func ReadPhotoExif(path string) (summary models.PhotoExif, takenAt *time.Time) {
+	summary.Scanned = true
+	f, err := os.Open(path)
+	if err != nil {
+		return summary, nil
+	}
+	defer f.Close()
+	x, err := exif.Decode(f)
+	if err != nil {
+		return summary, nil
+	}
+
+	summary.CameraMake = exifString(x, exif.Make, 64)
+	summary.CameraModel = exifString(x, exif.Model, 64)
+	summary.LensModel = exifString(x, exif.LensModel, 128)
+	summary.Software = exifString(x, exif.Software, 128)
+	summary.FocalLength = exifRational(x, exif.FocalLength)
+	summary.Aperture = exifRational(x, exif.FNumber)
+	if tag, err := x.Get(exif.ExposureTime); err == nil {
+		if num, denom, err := tag.Rat2(0); err == nil && denom > 0 && num > 0 {
+			if num < denom {
+				summary.ExposureTime = strconv.FormatInt(num, 10) + "/" + strconv.FormatInt(denom, 10)
+			} else {
+				summary.ExposureTime = strconv.FormatFloat(float64(num)/float64(denom), 'f', 1, 64)
+			}
+		}
+	}
+	if iso, ok := exifInt(x, exif.ISOSpeedRatings); ok {
+		summary.ISO = iso
+	}
+	if lat, lng, err := x.LatLong(); err == nil && (lat != 0 || lng != 0) {
+		summary.Latitude, summary.Longitude = &lat, &lng
+	}
+
+	if value, ok := exifInt(x, exif.Orientation); ok {
+		summary.Orientation = exifOrientations[value]
+	}
+	if value, ok := exifInt(x, exif.ExposureMode); ok {
+		summary.ExposureMode = exifExposureModes[value]
+	}
+	if value, ok := exifInt(x, exif.WhiteBalance); ok {
+		summary.WhiteBalance = exifWhiteBalances[value]
+	}
+	if value, ok := exifInt(x, exif.Flash); ok {
+		summary.Flash = "No Flash"
+		if value&1 == 1 {
+			summary.Flash = "Fired"
+		}
+	}
+	if value, ok := exifInt(x, exif.MeteringMode); ok {
+		summary.MeteringMode = exifMeteringModes[value]
+	}
+
+	// Cameras with an unset clock write "0000:00:00 00:00:00" or factory defaults
+	if taken, err := x.DateTime(); err == nil && taken.Year() >= 1900 {
+		takenAt = &taken
+	}
+	return summary, takenAt
+}
+
+// exifString returns a text tag, cut to the column size
+func exifString(x *exif.Exif, name exif.FieldName, size int) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	value := tag.String()
+	if tag.Format() == tiff.StringVal {
+		value, _ = tag.StringVal()
+	}
+	if len(value) > size {
+		value = value[:size]
+	}
+	return value
+}
+
+// exifRational returns a rational tag as a number, 0 when missing
+func exifRational(x *exif.Exif, name exif.FieldName) float64 {
+	tag, err := x.Get(name)
+	if err != nil {
+		return 0
+	}
+	num, denom, err := tag.Rat2(0)
+	if err != nil || denom == 0 {
+		return 0
+	}
+	return float64(num) / float64(denom)
+}
+
+// exifInt returns an integer tag; ok is false when it's missing
+func exifInt(x *exif.Exif, name exif.FieldName) (int, bool) {
+	tag, err := x.Get(name)
+	if err != nil {
+		return 0, false
+	}
+	value, err := tag.Int(0)
+	return value, err == nil
+}
+
+// ExifUpdates returns the column updates recording an EXIF summary on a photo
+func ExifUpdates(summary models.PhotoExif) map[string]interface{} {
+	return map[string]interface{}{
+		"exif_scanned":       summary.Scanned,
+		"exif_camera_make":   summary.CameraMake,
+		"exif_camera_model":  summary.CameraModel,
+		"exif_lens_model":    summary.LensModel,
+		"exif_software":      summary.Software,
+		"exif_focal_length":  summary.FocalLength,
+		"exif_aperture":      summary.Aperture,
+		"exif_exposure_time": summary.ExposureTime,
+		"exif_iso":           summary.ISO,
+		"exif_latitude":      summary.Latitude,
+		"exif_longitude":     summary.Longitude,
+		"exif_orientation":   summary.Orientation,
+		"exif_exposure_mode": summary.ExposureMode,
+		"exif_white_balance": summary.WhiteBalance,
+		"exif_flash":         summary.Flash,
+		"exif_metering_mode": summary.MeteringMode,
+	}
+}
+
+// ScanPhotoExif reads the EXIF of a stored photo, from the RAW file when there is one
+// (cameras write the most complete EXIF there), and records it with the capture time
+// when the photo has none. Returns the stored summary.
+func ScanPhotoExif(photo *models.Photo, projectName string) models.PhotoExif {
+	summary := models.PhotoExif{Scanned: true}
+	var takenAt *time.Time
+	for _, ext := range []string{photo.RawExt, photo.NormalExt} {
+		if ext == "" || (ext == photo.RawExt && !photo.HasRaw) {
+			continue
+		}
+		path, release, err := storage.Fetch(context.Background(), storage.Key(projectName, photo.BaseName+ext))
+		if err != nil {
+			continue
+		}
+		summary, takenAt = ReadPhotoExif(path)
+		release()
+		if summary.CameraModel != "" || takenAt != nil {
+			break
+		}
+	}
+
+	updates := ExifUpdates(summary)
+	if takenAt != nil && photo.TakenAt == nil {
+		updates["taken_at"] = *takenAt
+		photo.TakenAt = takenAt
+	}
+	// UpdateColumns keeps updated_at, which is part of the originals' ETags
+	if err := database.DB.Model(&models.Photo{}).Where("id = ?", photo.ID).UpdateColumns(updates).Error; err != nil {
+		log.Printf("%s Failed to record EXIF of photo %d: %v", exifShortname, photo.ID, err)
+	}
+	photo.Exif = summary
+	return summary
+}
+
+// BackfillPhotoExif reads and stores the EXIF of photos uploaded before it was recorded
+// at upload time. Returns the number of photos scanned.
+func BackfillPhotoExif() int {
+	scanned := 0
+	lastID := uint(0)
+	names := map[uint]string{} // project names by ID
+	for {
+		var photos []models.Photo
+		err := database.DB.Select("id, project_id, base_name, normal_ext, raw_ext, has_raw, taken_at").
+			Where("id > ? AND exif_scanned = ?", lastID, false).
+			Order("id").Limit(exifBatchSize).Find(&photos).Error
+		if err != nil {
+			log.Printf("%s Failed to query photos: %v", exifShortname, err)
+			return scanned
+		}
+		if len(photos) == 0 {
+			break
+		}
+
+		for i := range photos {
+			photo := &photos[i]
+			lastID = photo.ID
+			name, ok := names[photo.ProjectID]
+			if !ok {
+				var project models.Project
+				database.DB.Select("id, name").First(&project, photo.ProjectID)
+				name = project.Name
+				names[photo.ProjectID] = name
+			}
+			if name == "" {
+				continue
+			}
+			ScanPhotoExif(photo, name)
+			scanned++
+		}
+	}
+
+	if scanned > 0 {
+		log.Printf("%s Recorded EXIF of %d photos", exifShortname, scanned)
+	}
+	return scanned
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+// writeExifJPEG writes a minimal JPEG whose EXIF has Make, Model (IFD0), ISO and
+// DateTimeOriginal (Exif IFD)
+func writeExifJPEG(t *testing.T, path string) {
+	t.Helper()
+	le := binary.LittleEndian
+	cameraMake, model, date := "Sony\x00", "ILCE-7M4\x00", "2024:03:15 14:30:05\x00"
+
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, le, uint32(8))
+	// IFD0 at 8: three entries (2 + 3*12 + 4 = 42 bytes), values from 50
+	binary.Write(&tiff, le, uint16(3))
+	binary.Write(&tiff, le, []uint16{0x010F, 2})
+	binary.Write(&tiff, le, []uint32{uint32(len(cameraMake)), 50})
+	binary.Write(&tiff, le, []uint16{0x0110, 2})
+	binary.Write(&tiff, le, []uint32{uint32(len(model)), uint32(50 + len(cameraMake))})
+	exifIFD := uint32(50 + len(cameraMake) + len(model))
+	binary.Write(&tiff, le, []uint16{0x8769, 4})
+	binary.Write(&tiff, le, []uint32{1, exifIFD})
+	binary.Write(&tiff, le, uint32(0))
+	tiff.WriteString(cameraMake + model)
+	// Exif IFD: two entries (30 bytes), the date after them
+	binary.Write(&tiff, le, uint16(2))
+	binary.Write(&tiff, le, []uint16{0x8827, 3})
+	binary.Write(&tiff, le, []uint32{1, 800})
+	binary.Write(&tiff, le, []uint16{0x9003, 2})
+	binary.Write(&tiff, le, []uint32{uint32(len(date)), exifIFD + 30})
+	binary.Write(&tiff, le, uint32(0))
+	tiff.WriteString(date)
+
+	var jpg bytes.Buffer
+	jpg.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&jpg, binary.BigEndian, uint16(2+6+tiff.Len()))
+	jpg.WriteString("Exif\x00\x00")
+	jpg.Write(tiff.Bytes())
+	jpg.Write([]byte{0xFF, 0xD9})
+	if err := os.WriteFile(path, jpg.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test JPEG: %v", err)
+	}
+}
+
+func TestReadPhotoExif(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exif.jpg")
+	writeExifJPEG(t, path)
+
+	summary, takenAt := ReadPhotoExif(path)
+	if !summary.Scanned || summary.CameraMake != "Sony" || summary.CameraModel != "ILCE-7M4" || summary.ISO != 800 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if expected := time.Date(2024, 3, 15, 14, 30, 5, 0, time.Local); takenAt == nil || !takenAt.Equal(expected) {
+		t.Errorf("Capture time = %v, expected %v", takenAt, expected)
+	}
+
+	// Files without EXIF are still marked scanned, so they aren't read again
+	plain := filepath.Join(t.TempDir(), "plain.jpg")
+	os.WriteFile(plain, []byte{0xFF, 0xD8, 0xFF, 0xD9}, 0644)
+	if summary, takenAt := ReadPhotoExif(plain); !summary.Scanned || summary.CameraModel != "" || takenAt != nil {
+		t.Errorf("Expected an empty scanned summary, got %+v, %v", summary, takenAt)
+	}
+}
+
+func TestBackfillPhotoExif(t *testing.T) {
+	project := setupProjectTest(t)
+	writeExifJPEG(t, filepath.Join(config.AppConfig.UploadDir, project.Name, "IMG_0001.jpg"))
+	var before models.Photo
+	database.DB.Where("base_name = ?", "IMG_0001").First(&before)
+
+	if scanned := BackfillPhotoExif(); scanned != 1 {
+		t.Fatalf("Expected one photo scanned, got %d", scanned)
+	}
+	var photo models.Photo
+	database.DB.First(&photo, before.ID)
+	if !photo.Exif.Scanned || photo.Exif.CameraModel != "ILCE-7M4" || photo.Exif.ISO != 800 {
+		t.Errorf("Unexpected stored summary %+v", photo.Exif)
+	}
+	if photo.TakenAt == nil || photo.TakenAt.Year() != 2024 {
+		t.Errorf("Expected the capture time to be filled in, got %v", photo.TakenAt)
+	}
+	if !photo.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Error("Expected updated_at to be kept")
+	}
+
+	if scanned := BackfillPhotoExif(); scanned != 0 {
+		t.Errorf("Expected scanned photos to be skipped, got %d", scanned)
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"photobridge/common"
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+
+	"gorm.io/gorm"
+)
+
+const fileSizeShortname = "[FileSize]"
+
+// ShareSizes summarizes what a share link's download-all archives would contain.
+// Sizes come from the recorded file sizes; RAW-only photos converted to JPEG on
+// download are not included in the normal totals.
+type ShareSizes struct {
+	NormalCount    int64 `json:"normal_count"`
+	NormalBytes    int64 `json:"normal_bytes"` // JPEG/PNG files visible on the link
+	RawCount       int64 `json:"raw_count"`
+	RawBytes       int64 `json:"raw_bytes"`        // RAW files downloadable from the link (0 without RAW access)
+	ZipNormalBytes int64 `json:"zip_normal_bytes"` // Estimated archive sizes per download type
+	ZipRawBytes    int64 `json:"zip_raw_bytes"`
+	ZipAllBytes    int64 `json:"zip_all_bytes"`
+	ZipVolumeBytes int64 `json:"zip_volume_bytes,omitempty"` // Archives larger than this are offered in parts (ZIP_VOLUME_SIZE_MB)
+}
+
+// fileTotals is one aggregate row of SummarizeShareSizes
+type fileTotals struct {
+	Count     int64
+	Bytes     int64
+	NameBytes int64
+}
+
+// SummarizeShareSizes totals the files visible on a share link (exclusions, RAW
+// exclusions, date range and RAW access applied) and estimates its archive sizes
+func SummarizeShareSizes(link *models.ShareLink) (ShareSizes, error) {
+	excludedIDs := common.GetExcludedIDs(link.Exclusions)
+	visible := func() *gorm.DB {
+		query := database.DB.Model(&models.Photo{}).Where("project_id = ?", link.ProjectID)
+		if len(excludedIDs) > 0 {
+			query = query.Where("id NOT IN ?", excludedIDs)
+		}
+		return common.ApplyDateRange(query, link)
+	}
+
+	var normal, raw fileTotals
+	err := visible().Where("normal_ext <> ''").
+		Select("COUNT(*) AS count, COALESCE(SUM(normal_size), 0) AS bytes, COALESCE(SUM(LENGTH(base_name) + LENGTH(normal_ext)), 0) AS name_bytes").
+		Scan(&normal).Error
+	if err != nil {
+		return ShareSizes{}, err
+	}
+	if link.AllowRaw {
+		query := visible().Where("has_raw = ? AND raw_ext <> ''", true)
+		if rawExcluded := common.GetRawExcludedIDs(link.RawExclusions); len(rawExcluded) > 0 {
+			ids := make([]uint, 0, len(rawExcluded))
+			for id := range rawExcluded {
+				ids = append(ids, id)
+			}
+			query = query.Where("id NOT IN ?", ids)
+		}
+		err := query.
+			Select("COUNT(*) AS count, COALESCE(SUM(raw_size), 0) AS bytes, COALESCE(SUM(LENGTH(base_name) + LENGTH(raw_ext)), 0) AS name_bytes").
+			Scan(&raw).Error
+		if err != nil {
+			return ShareSizes{}, err
+		}
+	}
+
+	return ShareSizes{
+		NormalCount:    normal.Count,
+		NormalBytes:    normal.Bytes,
+		RawCount:       raw.Count,
+		RawBytes:       raw.Bytes,
+		ZipNormalBytes: utils.EstimateZipSize(normal.Count, normal.Bytes, normal.NameBytes),
+		ZipRawBytes:    utils.EstimateZipSize(raw.Count, raw.Bytes, raw.NameBytes),
+		ZipAllBytes:    utils.EstimateZipSize(normal.Count+raw.Count, normal.Bytes+raw.Bytes, normal.NameBytes+raw.NameBytes),
+		ZipVolumeBytes: int64(config.AppConfig.ZipVolumeSizeMB) << 20,
+	}, nil
+}
+
+// BackfillPhotoSizes records the file sizes of photos uploaded before they were stored.
+// Returns the number of photos updated.
+func BackfillPhotoSizes() int {
+	type photoRow struct {
+		ID          uint
+		BaseName    string
+		NormalExt   string
+		RawExt      string
+		HasRaw      bool
+		NormalSize  int64
+		RawSize     int64
+		ProjectName string
+	}
+
+	updated := 0
+	lastID := uint(0)
+	for {
+		var photos []photoRow
+		err := database.DB.Model(&models.Photo{}).
+			Select("photos.id, photos.base_name, photos.normal_ext, photos.raw_ext, photos.has_raw, photos.normal_size, photos.raw_size, projects.name AS project_name").
+			Joins("JOIN projects ON projects.id = photos.project_id").
+			Where("photos.id > ? AND ((photos.normal_ext <> '' AND photos.normal_size = 0) OR (photos.has_raw = ? AND photos.raw_ext <> '' AND photos.raw_size = 0))", lastID, true).
+			Order("photos.id").Limit(dimensionsBatchSize).
+			Scan(&photos).Error
+		if err != nil {
+			log.Printf("%s Failed to query photos: %v", fileSizeShortname, err)
+			return updated
+		}
+		if len(photos) == 0 {
+			break
+		}
+
+		for _, photo := range photos {
+			lastID = photo.ID
+			backend := storage.Default()
+			updates := map[string]interface{}{}
+			if photo.NormalExt != "" && photo.NormalSize == 0 {
+				if info, err := backend.Stat(context.Background(), storage.Key(photo.ProjectName, photo.BaseName+photo.NormalExt)); err == nil {
+					updates["normal_size"] = info.Size
+				}
+			}
+			if photo.HasRaw && photo.RawExt != "" && photo.RawSize == 0 {
+				if info, err := backend.Stat(context.Background(), storage.Key(photo.ProjectName, photo.BaseName+photo.RawExt)); err == nil {
+					updates["raw_size"] = info.Size
+				}
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := database.DB.Model(&models.Photo{}).Where("id = ?", photo.ID).Updates(updates).Error; err == nil {
+				updated++
+			}
+		}
+	}
+
+	if updated > 0 {
+		log.Printf("%s Recorded file sizes of %d photos", fileSizeShortname, updated)
+	}
+	return updated
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+func TestBackfillPhotoSizes(t *testing.T) {
+	project := setupProjectTest(t)
+	dir := filepath.Join(config.AppConfig.UploadDir, project.Name)
+	os.WriteFile(filepath.Join(dir, "DSC_0002.jpg"), make([]byte, 300), 0644)
+	os.WriteFile(filepath.Join(dir, "DSC_0002.nef"), make([]byte, 5000), 0644)
+
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "DSC_0002", NormalExt: ".jpg", RawExt: ".nef", HasRaw: true})
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "DSC_0003", NormalExt: ".jpg"}) // file missing
+
+	if updated := BackfillPhotoSizes(); updated != 2 {
+		t.Errorf("Expected 2 photos updated, got %d", updated)
+	}
+
+	expected := map[string][2]int64{"IMG_0001": {4, 0}, "DSC_0002": {300, 5000}, "DSC_0003": {0, 0}}
+	var photos []models.Photo
+	database.DB.Find(&photos)
+	for _, photo := range photos {
+		if want := expected[photo.BaseName]; photo.NormalSize != want[0] || photo.RawSize != want[1] {
+			t.Errorf("%s: expected sizes %v, got %d/%d", photo.BaseName, want, photo.NormalSize, photo.RawSize)
+		}
+	}
+
+	if updated := BackfillPhotoSizes(); updated != 0 {
+		t.Errorf("Second run should not update anything, got %d", updated)
+	}
+}
+
+func TestSummarizeShareSizes(t *testing.T) {
+	project := setupProjectTest(t)
+	database.DB.Model(&models.Photo{}).Where("base_name = ?", "IMG_0001").Update("normal_size", 1000)
+	withRaw := models.Photo{ProjectID: project.ID, BaseName: "IMG_0002", NormalExt: ".jpg", NormalSize: 2000, RawExt: ".cr2", HasRaw: true, RawSize: 20000}
+	rawOnly := models.Photo{ProjectID: project.ID, BaseName: "IMG_0003", RawExt: ".cr2", HasRaw: true, RawSize: 30000}
+	excluded := models.Photo{ProjectID: project.ID, BaseName: "IMG_0004", NormalExt: ".jpg", NormalSize: 4000}
+	database.DB.Create(&withRaw)
+	database.DB.Create(&rawOnly)
+	database.DB.Create(&excluded)
+
+	link := models.ShareLink{
+		ProjectID:     project.ID,
+		AllowRaw:      true,
+		Exclusions:    []models.PhotoExclusion{{PhotoID: excluded.ID}},
+		RawExclusions: []models.RawExclusion{{PhotoID: rawOnly.ID}},
+	}
+	sizes, err := SummarizeShareSizes(&link)
+	if err != nil {
+		t.Fatalf("SummarizeShareSizes failed: %v", err)
+	}
+	if sizes.NormalCount != 2 || sizes.NormalBytes != 3000 || sizes.RawCount != 1 || sizes.RawBytes != 20000 {
+		t.Errorf("Unexpected totals: %+v", sizes)
+	}
+	// Names: IMG_0001.jpg and IMG_0002.jpg (12 bytes each), IMG_0002.cr2 (12 bytes)
+	if want := utils.EstimateZipSize(2, 3000, 24); sizes.ZipNormalBytes != want {
+		t.Errorf("ZipNormalBytes = %d, expected %d", sizes.ZipNormalBytes, want)
+	}
+	if want := utils.EstimateZipSize(3, 23000, 36); sizes.ZipAllBytes != want {
+		t.Errorf("ZipAllBytes = %d, expected %d", sizes.ZipAllBytes, want)
+	}
+
+	link.AllowRaw = false
+	sizes, _ = SummarizeShareSizes(&link)
+	if sizes.RawCount != 0 || sizes.RawBytes != 0 || sizes.ZipRawBytes != 0 || sizes.ZipAllBytes != sizes.ZipNormalBytes {
+		t.Errorf("Links without RAW access should not count RAW files: %+v", sizes)
+	}
+}
@@ -0,0 +1,515 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"photobridge/common"
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+)
+
+const importShortname = "[Import]"
+
+// Import modes: how files get from the archive into the upload directory
+const (
+	ImportCopy = "copy" // Copy files, leaving the archive untouched
+	ImportLink = "link" // Hard-link files (falls back to copying across filesystems)
+	ImportMove = "move" // Move files out of the archive
+)
+
+// normalExtPriority decides which image is imported when a base name has several
+// (e.g. IMG_0001.jpg and IMG_0001.png); lower comes first
+var normalExtPriority = map[string]int{".jpg": 0, ".jpeg": 1, ".png": 2, ".tif": 3, ".tiff": 4, ".webp": 5, ".gif": 6, ".bmp": 7, ".heic": 8, ".heif": 9}
+
+// ImportFolder is one folder of an archive and the project its photos go into
+type ImportFolder struct {
+	Folder    string   `json:"folder"`              // Relative to the import root ("." for files directly in it)
+	Project   string   `json:"project"`             // Target project, created if it does not exist
+	Skip      bool     `json:"skip,omitempty"`      // Leave this folder out of the import
+	Existing  bool     `json:"existing"`            // The project exists already; photos are merged into it
+	Photos    int      `json:"photos"`              // Distinct base names
+	Pairs     int      `json:"pairs"`               // Photos with both a normal image and a RAW file
+	RawOnly   int      `json:"raw_only"`            // Photos with only a RAW file
+	Ignored   []string `json:"ignored,omitempty"`   // Files that are neither images nor RAW files
+	Invalid   []string `json:"invalid,omitempty"`   // Photos whose file names are not accepted (rename them to import)
+	Conflicts []string `json:"conflicts,omitempty"` // Base names with several images or RAW files (only the first is imported)
+}
+
+// ImportPlan maps the folders of an archive to projects. It is proposed by
+// ScanImportDir and can be edited (project names, skip) before ApplyImportPlan.
+type ImportPlan struct {
+	Root    string         `json:"root"`
+	Folders []ImportFolder `json:"folders"`
+}
+
+// ImportOptions controls ApplyImportPlan
+type ImportOptions struct {
+	Mode       string // ImportCopy, ImportLink or ImportMove
+	Thumbnails bool   // Generate thumbnails during the import instead of on first view
+}
+
+// ImportResult summarizes an applied import
+type ImportResult struct {
+	Projects   []string // Projects that received photos
+	Created    int      // New photos
+	Merged     int      // Files added to photos that already existed (e.g. the RAW of an uploaded JPEG)
+	Duplicates int      // Files already in the project with identical content
+	Failed     []string // "folder/file: reason"
+}
+
+// importGroup is the normal image and RAW file of one shot in a folder
+type importGroup struct {
+	baseName string
+	normals  []string // File names, preferred first
+	raws     []string
+}
+
+// ScanImportDir walks an archive and proposes one project per folder containing
+// photos: files directly in root go into a project named after root, nested folders
+// into projects named after their path ("2023/Wedding" becomes "2023 - Wedding").
+// Hidden files and folders are ignored.
+func ScanImportDir(root string) (*ImportPlan, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	plan := &ImportPlan{Root: root}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		groups, ignored, invalid, err := scanImportFolder(path)
+		if err != nil {
+			return err
+		}
+		if len(groups) == 0 && len(invalid) == 0 {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		folder := ImportFolder{Folder: filepath.ToSlash(rel), Project: proposeProjectName(root, rel), Ignored: ignored, Invalid: invalid}
+		for _, group := range groups {
+			folder.Photos++
+			switch {
+			case len(group.normals) > 0 && len(group.raws) > 0:
+				folder.Pairs++
+			case len(group.normals) == 0:
+				folder.RawOnly++
+			}
+			if len(group.normals) > 1 || len(group.raws) > 1 {
+				folder.Conflicts = append(folder.Conflicts, group.baseName)
+			}
+		}
+		var count int64
+		database.DB.Model(&models.Project{}).Where("name = ?", folder.Project).Count(&count)
+		folder.Existing = count > 0
+		plan.Folders = append(plan.Folders, folder)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// scanImportFolder groups the images and RAW files directly in dir by base name.
+// Returns the groups sorted by base name, the names of unsupported files and those of
+// photos with file names uploads would reject.
+func scanImportFolder(dir string) (groups []importGroup, ignored, invalid []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	byName := map[string]*importGroup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !entry.Type().IsRegular() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(name))
+		isRaw, isImage := models.IsRawExtension(ext), models.IsImageExtension(ext)
+		if !isRaw && !isImage {
+			ignored = append(ignored, name)
+			continue
+		}
+		if !utils.ValidateFileName(name) {
+			invalid = append(invalid, name)
+			continue
+		}
+		baseName := strings.TrimSuffix(name, filepath.Ext(name))
+		group, ok := byName[baseName]
+		if !ok {
+			group = &importGroup{baseName: baseName}
+			byName[baseName] = group
+		}
+		if isRaw {
+			group.raws = append(group.raws, name)
+		} else {
+			group.normals = append(group.normals, name)
+		}
+	}
+
+	groups = make([]importGroup, 0, len(byName))
+	for _, group := range byName {
+		sort.Strings(group.raws)
+		sort.Slice(group.normals, func(i, j int) bool {
+			pi := normalExtPriority[strings.ToLower(filepath.Ext(group.normals[i]))]
+			pj := normalExtPriority[strings.ToLower(filepath.Ext(group.normals[j]))]
+			if pi != pj {
+				return pi < pj
+			}
+			return group.normals[i] < group.normals[j]
+		})
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].baseName < groups[j].baseName })
+	return groups, ignored, invalid, nil
+}
+
+// proposeProjectName derives a valid project name from a folder path
+func proposeProjectName(root, rel string) string {
+	name := filepath.Base(root)
+	if rel != "." {
+		name = strings.Join(strings.Split(filepath.ToSlash(rel), "/"), " - ")
+	}
+	// Characters not allowed in project names become underscores
+	name = strings.Map(func(r rune) rune {
+		if r <= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || unicode.Is(unicode.Han, r) ||
+			r == '_' || r == '-' || r == ' ' {
+			return r
+		}
+		return '_'
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Import"
+	}
+	return name
+}
+
+// ApplyImportPlan ingests the folders of a plan that are not skipped: projects are
+// created as needed, files are copied (or linked/moved) into the upload directory and
+// recorded with hashes, sizes, dimensions and capture times, pairing JPEG and RAW
+// files by base name. Files identical to one already in the project are skipped, and
+// existing files are never overwritten. Files are imported as they are (no upload
+// normalization).
+func ApplyImportPlan(plan *ImportPlan, opts ImportOptions) (*ImportResult, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = ImportCopy
+	case ImportCopy, ImportLink, ImportMove:
+	default:
+		return nil, fmt.Errorf("unknown import mode %q", opts.Mode)
+	}
+
+	result := &ImportResult{}
+	seenProjects := map[string]bool{}
+	for _, folder := range plan.Folders {
+		if folder.Skip {
+			continue
+		}
+		name, ok := utils.SanitizeProjectName(folder.Project)
+		if !ok {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: invalid project name %q", folder.Folder, folder.Project))
+			continue
+		}
+		project, err := findOrCreateImportProject(name, folder.Folder)
+		if err != nil {
+			return result, err
+		}
+		if !seenProjects[name] {
+			seenProjects[name] = true
+			result.Projects = append(result.Projects, name)
+		}
+
+		dir := filepath.Join(plan.Root, filepath.FromSlash(folder.Folder))
+		if err := importFolder(project, dir, folder.Folder, opts, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// findOrCreateImportProject returns the project with the given name, creating it and
+// its upload directory if needed
+func findOrCreateImportProject(name, folder string) (*models.Project, error) {
+	var project models.Project
+	if err := database.DB.Where("name = ?", name).First(&project).Error; err != nil {
+		project = models.Project{Name: name, Description: "Imported from " + folder}
+		if err := database.DB.Create(&project).Error; err != nil {
+			return nil, fmt.Errorf("failed to create project %s: %w", name, err)
+		}
+		log.Printf("%s Created project %q", importShortname, name)
+	}
+	dir, err := projectDir(project.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create project directory: %w", err)
+	}
+	return &project, nil
+}
+
+// importFolder ingests the photos of one archive folder into project
+func importFolder(project *models.Project, dir, folder string, opts ImportOptions, result *ImportResult) error {
+	groups, _, _, err := scanImportFolder(dir)
+	if err != nil {
+		return err
+	}
+	projectPath, err := projectDir(project.Name)
+	if err != nil {
+		return err
+	}
+
+	// Shared lock, like uploads: the project can't be renamed or deleted meanwhile
+	unlock := RLockProject(project.ID)
+	defer unlock()
+
+	for _, group := range groups {
+		var existing models.Photo
+		found := database.DB.Select(common.PhotoMetaColumns).
+			Where("project_id = ? AND base_name = ?", project.ID, group.baseName).First(&existing).Error == nil
+
+		photo := existing
+		if !found {
+			photo = models.Photo{ProjectID: project.ID, BaseName: group.baseName}
+		}
+		added, addedNormal := 0, false
+		var addedFiles []string
+		for _, files := range [][]string{group.normals, group.raws} {
+			if len(files) == 0 {
+				continue
+			}
+			status, err := importFile(project, projectPath, filepath.Join(dir, files[0]), &photo, opts)
+			switch {
+			case err != nil:
+				result.Failed = append(result.Failed, fmt.Sprintf("%s/%s: %v", folder, files[0], err))
+			case status == importDuplicate:
+				result.Duplicates++
+			default:
+				added++
+				ext := strings.ToLower(filepath.Ext(files[0]))
+				addedNormal = addedNormal || !models.IsRawExtension(ext)
+				addedFiles = append(addedFiles, photo.BaseName+ext)
+			}
+		}
+		if added == 0 {
+			continue
+		}
+
+		var thumbs *utils.ThumbnailResult
+		if opts.Thumbnails && addedNormal {
+			if thumbs, err = utils.GenerateThumbnails(context.Background(), filepath.Join(projectPath, photo.BaseName+photo.NormalExt)); err == nil {
+				photo.ThumbWidth, photo.ThumbHeight = thumbs.Width, thumbs.Height
+				photo.ThumbParams = thumbs.Params
+				photo.Width, photo.Height = thumbs.Width, thumbs.Height
+			} else {
+				thumbs = nil
+				log.Printf("%s Failed to generate thumbnail for %s: %v", importShortname, photo.BaseName, err)
+			}
+		}
+
+		// Move the files to object storage once they have been read (no-op for local storage)
+		if err := storeImportedFiles(project.Name, projectPath, addedFiles); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s/%s: %v", folder, group.baseName, err))
+			continue
+		}
+
+		if found {
+			// The photo was loaded without thumbnail blobs; leave those columns alone
+			err = database.DB.Omit("thumb_small", "thumb_large").Save(&photo).Error
+		} else {
+			err = database.DB.Create(&photo).Error
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s/%s: %v", folder, group.baseName, err))
+			continue
+		}
+		if thumbs != nil {
+			if err := SaveThumbnails(photo.ProjectID, photo.ID, thumbs.Small, thumbs.Large); err != nil {
+				log.Printf("%s Failed to write thumbnail for %s: %v", importShortname, photo.BaseName, err)
+			}
+		}
+		if found {
+			result.Merged += added
+		} else {
+			result.Created++
+		}
+		if photo.NormalExt != "" {
+			SetDefaultCoverPhoto(project.ID, CoverPhotoName(&photo))
+		}
+	}
+	log.Printf("%s Imported %s into %q", importShortname, folder, project.Name)
+	return nil
+}
+
+// Import file outcomes
+const (
+	importAdded     = "added"
+	importDuplicate = "duplicate"
+)
+
+// errImportFileExists is returned when the photo already has a different file of the same kind
+var errImportFileExists = errors.New("photo already has a file of this type")
+
+// importFile transfers one source file into the project and records it on photo
+func importFile(project *models.Project, projectPath, src string, photo *models.Photo, opts ImportOptions) (string, error) {
+	ext := strings.ToLower(filepath.Ext(src))
+	isRaw := models.IsRawExtension(ext)
+
+	hash, err := utils.CalculateFileHashFromPath(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	var count int64
+	if isRaw {
+		database.DB.Model(&models.Photo{}).Where("project_id = ? AND raw_ext <> '' AND raw_hash = ?", project.ID, hash).Count(&count)
+	} else {
+		database.DB.Model(&models.Photo{}).Where("project_id = ? AND normal_ext <> '' AND (normal_hash = ? OR (normal_hash = '' AND file_hash = ?))", project.ID, hash, hash).Count(&count)
+	}
+	if count > 0 {
+		return importDuplicate, nil
+	}
+	if (isRaw && photo.RawExt != "") || (!isRaw && photo.NormalExt != "") {
+		return "", errImportFileExists
+	}
+
+	// Validate the content before anything is written (or moved out of the archive)
+	if isRaw {
+		err = utils.ValidateRAWFile(src)
+	} else {
+		err = utils.ValidateImageContent(src)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dst, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, filepath.Join(projectPath, photo.BaseName+ext))
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("%s already exists in the project directory", filepath.Base(dst))
+	}
+	if !storage.IsLocal() {
+		if _, err := storage.Default().Stat(context.Background(), storage.Key(project.Name, filepath.Base(dst))); err == nil {
+			return "", fmt.Errorf("%s already exists in storage", filepath.Base(dst))
+		}
+	}
+	if err := transferImportFile(src, dst, opts.Mode); err != nil {
+		return "", err
+	}
+
+	recordPhotoFile(photo, dst, ext, hash)
+	return importAdded, nil
+}
+
+// recordPhotoFile sets the fields of photo describing its file at path (with extension
+// ext and content hash): extension, hash, size, dimensions, EXIF and capture time
+func recordPhotoFile(photo *models.Photo, path, ext, hash string) {
+	isRaw := models.IsRawExtension(ext)
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	if isRaw {
+		photo.RawExt, photo.HasRaw, photo.RawHash, photo.RawSize = ext, true, hash, size
+	} else {
+		photo.NormalExt, photo.NormalHash, photo.NormalSize = ext, hash, size
+		photo.Width, photo.Height, _ = utils.ReadImageSize(path)
+	}
+	if photo.FileHash == "" || !isRaw {
+		photo.FileHash = hash // Keep for backward compatibility
+	}
+	// The RAW's EXIF is the camera's own; a normal image's only counts without a RAW
+	summary, takenAt := ReadPhotoExif(path)
+	if isRaw || !photo.HasRaw {
+		photo.Exif = summary
+	}
+	if photo.TakenAt == nil {
+		photo.TakenAt = takenAt
+	}
+}
+
+// storeImportedFiles moves imported files from the project directory into storage
+func storeImportedFiles(projectName, projectPath string, names []string) error {
+	for _, name := range names {
+		if err := storage.Store(context.Background(), storage.Key(projectName, name), filepath.Join(projectPath, name)); err != nil {
+			return fmt.Errorf("failed to store %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// transferImportFile copies, hard-links or moves src to dst
+func transferImportFile(src, dst, mode string) error {
+	switch mode {
+	case ImportMove:
+		if err := os.Rename(src, dst); err == nil {
+			return nil
+		}
+		// Across filesystems: copy, then remove the source
+		if err := copyImportFile(src, dst); err != nil {
+			return err
+		}
+		return os.Remove(src)
+	case ImportLink:
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		}
+	}
+	return copyImportFile(src, dst)
+}
+
+// copyImportFile copies src to dst, removing dst again if the copy fails
+func copyImportFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
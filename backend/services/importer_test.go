@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+// writeImportJPEG writes a small solid-color JPEG (the color makes the content unique)
+func writeImportJPEG(t *testing.T, path string, shade uint8) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{R: shade, G: 100, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// setupImportArchive creates an archive with loose files in the root, a nested
+// folder with a JPEG/RAW pair and a RAW-only shot, a folder matching the existing
+// "wedding" project and a hidden folder
+func setupImportArchive(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "Archive")
+	for _, dir := range []string{"2023/Trip", "wedding", ".cache"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	writeImportJPEG(t, filepath.Join(root, "loose.jpg"), 10)
+	writeImportJPEG(t, filepath.Join(root, "2023/Trip/DSC_0001.JPG"), 20)
+	os.WriteFile(filepath.Join(root, "2023/Trip/DSC_0001.cr2"), []byte("II*\x00raw data 1"), 0644)
+	os.WriteFile(filepath.Join(root, "2023/Trip/DSC_0002.nef"), []byte("MM\x00*raw data 2"), 0644)
+	os.WriteFile(filepath.Join(root, "2023/Trip/notes.txt"), []byte("notes"), 0644)
+	writeImportJPEG(t, filepath.Join(root, "2023/Trip/bad(name).jpg"), 30)
+	writeImportJPEG(t, filepath.Join(root, "wedding/IMG_0002.jpg"), 40)
+	os.WriteFile(filepath.Join(root, "wedding/IMG_0001.cr2"), []byte("II*\x00raw of the existing photo"), 0644)
+	writeImportJPEG(t, filepath.Join(root, ".cache/thumb.jpg"), 50)
+	return root
+}
+
+func TestScanImportDir(t *testing.T) {
+	setupProjectTest(t)
+	root := setupImportArchive(t)
+
+	plan, err := ScanImportDir(root)
+	if err != nil {
+		t.Fatalf("ScanImportDir failed: %v", err)
+	}
+	if len(plan.Folders) != 3 {
+		t.Fatalf("Expected 3 folders (hidden one skipped), got %+v", plan.Folders)
+	}
+
+	byFolder := map[string]ImportFolder{}
+	for _, folder := range plan.Folders {
+		byFolder[folder.Folder] = folder
+	}
+	if root := byFolder["."]; root.Project != "Archive" || root.Photos != 1 || root.Existing {
+		t.Errorf("Unexpected root folder: %+v", root)
+	}
+	trip := byFolder["2023/Trip"]
+	if trip.Project != "2023 - Trip" || trip.Photos != 2 || trip.Pairs != 1 || trip.RawOnly != 1 {
+		t.Errorf("Unexpected nested folder: %+v", trip)
+	}
+	if len(trip.Ignored) != 1 || trip.Ignored[0] != "notes.txt" || len(trip.Invalid) != 1 {
+		t.Errorf("Expected notes.txt ignored and bad(name).jpg invalid, got %v / %v", trip.Ignored, trip.Invalid)
+	}
+	if wedding := byFolder["wedding"]; !wedding.Existing || wedding.Project != "wedding" {
+		t.Errorf("Expected the wedding folder to map to the existing project: %+v", wedding)
+	}
+
+	if name := proposeProjectName("/photos", "Family/Xmas 2022!"); name != "Family - Xmas 2022_" {
+		t.Errorf("proposeProjectName() = %q", name)
+	}
+}
+
+func TestApplyImportPlan(t *testing.T) {
+	project := setupProjectTest(t)
+	root := setupImportArchive(t)
+	plan, err := ScanImportDir(root)
+	if err != nil {
+		t.Fatalf("ScanImportDir failed: %v", err)
+	}
+	for i := range plan.Folders {
+		if plan.Folders[i].Folder == "." {
+			plan.Folders[i].Skip = true
+		}
+	}
+
+	result, err := ApplyImportPlan(plan, ImportOptions{Mode: ImportCopy, Thumbnails: true})
+	if err != nil {
+		t.Fatalf("ApplyImportPlan failed: %v", err)
+	}
+	if result.Created != 3 || result.Merged != 1 || result.Duplicates != 0 || len(result.Failed) != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	var count int64
+	database.DB.Model(&models.Project{}).Where("name = ?", "Archive").Count(&count)
+	if count != 0 {
+		t.Error("Skipped folders should not create projects")
+	}
+
+	var trip models.Project
+	if err := database.DB.Where("name = ?", "2023 - Trip").First(&trip).Error; err != nil {
+		t.Fatalf("Project for the nested folder not created: %v", err)
+	}
+	var pair models.Photo
+	database.DB.Where("project_id = ? AND base_name = ?", trip.ID, "DSC_0001").First(&pair)
+	if pair.NormalExt != ".jpg" || pair.RawExt != ".cr2" || !pair.HasRaw || pair.NormalHash == "" || pair.RawHash == "" ||
+		!HasThumbnails(&pair) || pair.Width != 32 || pair.NormalSize == 0 || pair.RawSize != 14 {
+		t.Errorf("JPEG/RAW pair not recorded correctly: %+v", pair)
+	}
+	tripDir := filepath.Join(config.AppConfig.UploadDir, trip.Name)
+	for _, name := range []string{"DSC_0001.jpg", "DSC_0001.cr2", "DSC_0002.nef"} {
+		if _, err := os.Stat(filepath.Join(tripDir, name)); err != nil {
+			t.Errorf("%s not copied: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "2023/Trip/DSC_0001.JPG")); err != nil {
+		t.Error("Copy mode should leave the archive untouched")
+	}
+	database.DB.First(&trip, trip.ID)
+	if trip.CoverPhoto != "DSC_0001.jpg" {
+		t.Errorf("Expected a default cover photo, got %q", trip.CoverPhoto)
+	}
+
+	// The RAW file is merged into the existing photo without touching its other fields
+	var existing models.Photo
+	database.DB.Where("project_id = ? AND base_name = ?", project.ID, "IMG_0001").First(&existing)
+	if existing.RawExt != ".cr2" || !existing.HasRaw || existing.NormalExt != ".jpg" {
+		t.Errorf("RAW not merged into the existing photo: %+v", existing)
+	}
+
+	// Importing again only finds duplicates
+	result, err = ApplyImportPlan(plan, ImportOptions{Mode: ImportCopy})
+	if err != nil {
+		t.Fatalf("Second ApplyImportPlan failed: %v", err)
+	}
+	if result.Created != 0 || result.Merged != 0 || result.Duplicates != 5 || len(result.Failed) != 0 {
+		t.Errorf("Re-import should only find duplicates: %+v", result)
+	}
+}
+
+func TestApplyImportPlanMove(t *testing.T) {
+	setupProjectTest(t)
+	root := setupImportArchive(t)
+	plan := &ImportPlan{Root: root, Folders: []ImportFolder{{Folder: "2023/Trip", Project: "Trip"}}}
+
+	result, err := ApplyImportPlan(plan, ImportOptions{Mode: ImportMove})
+	if err != nil || result.Created != 2 {
+		t.Fatalf("ApplyImportPlan() = %+v, %v", result, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "2023/Trip/DSC_0001.cr2")); !os.IsNotExist(err) {
+		t.Error("Move mode should remove files from the archive")
+	}
+	if _, err := os.Stat(filepath.Join(root, "2023/Trip/notes.txt")); err != nil {
+		t.Error("Unsupported files should stay in the archive")
+	}
+
+	if _, err := ApplyImportPlan(plan, ImportOptions{Mode: "symlink"}); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
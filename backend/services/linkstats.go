@@ -0,0 +1,164 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+// LinkStats summarizes the AccessLog of a share link, with the daily totals of the
+// entries rolled up after ACCESS_LOG_RETENTION_DAYS
+type LinkStats struct {
+	LinkID         uint               `json:"link_id"`
+	Views          int64              `json:"views"`           // Gallery openings
+	UniqueVisitors int64              `json:"unique_visitors"` // Distinct IPs over views and downloads (per day once rolled up)
+	Downloads      int64              `json:"downloads"`       // Single-photo downloads
+	ZipDownloads   int64              `json:"zip_downloads"`   // Download-all archives
+	FirstAccess    *time.Time         `json:"first_access"`
+	LastAccess     *time.Time         `json:"last_access"`
+	TopPhotos      []PhotoDownloadSum `json:"top_photos"` // Most downloaded photos, most first
+}
+
+// PhotoDownloadSum counts the single-photo downloads of a photo through a link
+type PhotoDownloadSum struct {
+	PhotoID   uint   `json:"photo_id"`
+	BaseName  string `json:"base_name"` // Empty once the photo is purged
+	Downloads int64  `json:"downloads"`
+}
+
+// ReadLinkStats counts the views and downloads of a link, with its top most
+// downloaded photos. Recent accesses are counted from the AccessLog, older ones from
+// the daily aggregates they were rolled up into.
+func ReadLinkStats(linkID uint, top int) (*LinkStats, error) {
+	stats := &LinkStats{LinkID: linkID, TopPhotos: []PhotoDownloadSum{}}
+	logs := func() *gorm.DB {
+		return database.DB.Model(&models.AccessLog{}).Where("link_id = ?", linkID)
+	}
+	daily := func() *gorm.DB {
+		return database.DB.Model(&models.LinkDailyStat{}).Where("link_id = ?", linkID)
+	}
+
+	var counts []struct {
+		Action string
+		Count  int64
+	}
+	if err := logs().Select("action, COUNT(*) AS count").Group("action").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		switch count.Action {
+		case models.LinkAccessView:
+			stats.Views = count.Count
+		case models.LinkAccessDownload:
+			stats.Downloads = count.Count
+		case models.LinkAccessZip:
+			stats.ZipDownloads = count.Count
+		}
+	}
+	if err := logs().Distinct("ip").Count(&stats.UniqueVisitors).Error; err != nil {
+		return nil, err
+	}
+
+	var rolled struct {
+		Views        int64
+		Downloads    int64
+		ZipDownloads int64
+		Visitors     int64
+	}
+	err := daily().
+		Select("COALESCE(SUM(views), 0) AS views, COALESCE(SUM(downloads), 0) AS downloads, COALESCE(SUM(zip_downloads), 0) AS zip_downloads, COALESCE(SUM(visitors), 0) AS visitors").
+		Scan(&rolled).Error
+	if err != nil {
+		return nil, err
+	}
+	stats.Views += rolled.Views
+	stats.Downloads += rolled.Downloads
+	stats.ZipDownloads += rolled.ZipDownloads
+	stats.UniqueVisitors += rolled.Visitors
+
+	// Rolled up days all precede the AccessLog entries left
+	var firstDay, lastDay models.LinkDailyStat
+	var first, last models.AccessLog
+	if daily().Order("day").Limit(1).Find(&firstDay).RowsAffected > 0 {
+		stats.FirstAccess = &firstDay.FirstAccess
+	} else if logs().Order("created_at, id").Limit(1).Find(&first).RowsAffected > 0 {
+		stats.FirstAccess = &first.CreatedAt
+	}
+	if logs().Order("created_at DESC, id DESC").Limit(1).Find(&last).RowsAffected > 0 {
+		stats.LastAccess = &last.CreatedAt
+	} else if daily().Order("day DESC").Limit(1).Find(&lastDay).RowsAffected > 0 {
+		stats.LastAccess = &lastDay.LastAccess
+	}
+
+	topPhotos, err := readTopPhotos(linkID, top)
+	if err != nil {
+		return nil, err
+	}
+	stats.TopPhotos = topPhotos
+	return stats, nil
+}
+
+// readTopPhotos adds up the single-photo downloads of a link's photos from the
+// AccessLog and the daily aggregates, most downloaded first
+func readTopPhotos(linkID uint, top int) ([]PhotoDownloadSum, error) {
+	var sums []PhotoDownloadSum
+	err := database.DB.Model(&models.AccessLog{}).
+		Select("photo_id, COUNT(*) AS downloads").
+		Where("link_id = ? AND action = ? AND photo_id IS NOT NULL", linkID, models.LinkAccessDownload).
+		Group("photo_id").
+		Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+	var rolled []PhotoDownloadSum
+	err = database.DB.Model(&models.LinkPhotoDailyStat{}).
+		Select("photo_id, SUM(downloads) AS downloads").
+		Where("link_id = ?", linkID).
+		Group("photo_id").
+		Scan(&rolled).Error
+	if err != nil {
+		return nil, err
+	}
+
+	downloads := map[uint]int64{}
+	for _, sum := range append(sums, rolled...) {
+		downloads[sum.PhotoID] += sum.Downloads
+	}
+	topPhotos := make([]PhotoDownloadSum, 0, len(downloads))
+	for photoID, count := range downloads {
+		topPhotos = append(topPhotos, PhotoDownloadSum{PhotoID: photoID, Downloads: count})
+	}
+	sort.Slice(topPhotos, func(i, j int) bool {
+		if topPhotos[i].Downloads != topPhotos[j].Downloads {
+			return topPhotos[i].Downloads > topPhotos[j].Downloads
+		}
+		return topPhotos[i].PhotoID < topPhotos[j].PhotoID
+	})
+	if len(topPhotos) > top {
+		topPhotos = topPhotos[:top]
+	}
+	if len(topPhotos) == 0 {
+		return topPhotos, nil
+	}
+
+	ids := make([]uint, len(topPhotos))
+	for i, sum := range topPhotos {
+		ids[i] = sum.PhotoID
+	}
+	var photos []models.Photo
+	if err := database.DB.Unscoped().Select("id, base_name").Where("id IN ?", ids).Find(&photos).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(photos))
+	for _, photo := range photos {
+		names[photo.ID] = photo.BaseName
+	}
+	for i := range topPhotos {
+		topPhotos[i].BaseName = names[topPhotos[i].PhotoID]
+	}
+	return topPhotos, nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestReadLinkStats(t *testing.T) {
+	project := setupProjectTest(t)
+	if err := database.DB.AutoMigrate(&models.AccessLog{}, &models.LinkDailyStat{}, &models.LinkPhotoDailyStat{}, &models.ProjectDailyStat{}); err != nil {
+		t.Fatalf("Failed to migrate access log: %v", err)
+	}
+	var first models.Photo
+	database.DB.Where("project_id = ?", project.ID).First(&first)
+	second := models.Photo{ProjectID: project.ID, BaseName: "IMG_0002", NormalExt: ".jpg"}
+	database.DB.Create(&second)
+
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := func(linkID uint, action, ip string, photoID uint, minutes int) {
+		log := models.AccessLog{LinkID: linkID, ProjectID: project.ID, Action: action, IP: ip, CreatedAt: start.Add(time.Duration(minutes) * time.Minute)}
+		if photoID != 0 {
+			log.PhotoID = &photoID
+		}
+		if err := database.DB.Create(&log).Error; err != nil {
+			t.Fatalf("Failed to create access log: %v", err)
+		}
+	}
+	entry(1, models.LinkAccessView, "10.0.0.1", 0, 0)
+	entry(1, models.LinkAccessView, "10.0.0.1", 0, 5)
+	entry(1, models.LinkAccessView, "10.0.0.2", 0, 10)
+	entry(1, models.LinkAccessDownload, "10.0.0.2", second.ID, 11)
+	entry(1, models.LinkAccessDownload, "10.0.0.3", second.ID, 12)
+	entry(1, models.LinkAccessDownload, "10.0.0.1", first.ID, 13)
+	entry(1, models.LinkAccessZip, "10.0.0.1", 0, 20)
+	entry(2, models.LinkAccessView, "10.0.0.9", 0, 30)
+
+	stats, err := ReadLinkStats(1, 10)
+	if err != nil {
+		t.Fatalf("ReadLinkStats() = %v", err)
+	}
+	if stats.Views != 3 || stats.Downloads != 3 || stats.ZipDownloads != 1 {
+		t.Errorf("counts = %d views, %d downloads, %d zips, want 3, 3, 1", stats.Views, stats.Downloads, stats.ZipDownloads)
+	}
+	if stats.UniqueVisitors != 3 {
+		t.Errorf("UniqueVisitors = %d, want 3", stats.UniqueVisitors)
+	}
+	if stats.FirstAccess == nil || !stats.FirstAccess.Equal(start) || stats.LastAccess == nil || !stats.LastAccess.Equal(start.Add(20*time.Minute)) {
+		t.Errorf("span = %v - %v, want %v - %v", stats.FirstAccess, stats.LastAccess, start, start.Add(20*time.Minute))
+	}
+	want := []PhotoDownloadSum{{second.ID, "IMG_0002", 2}, {first.ID, "IMG_0001", 1}}
+	if len(stats.TopPhotos) != len(want) {
+		t.Fatalf("TopPhotos = %+v, want %+v", stats.TopPhotos, want)
+	}
+	for i := range want {
+		if stats.TopPhotos[i] != want[i] {
+			t.Errorf("TopPhotos[%d] = %+v, want %+v", i, stats.TopPhotos[i], want[i])
+		}
+	}
+
+	if top, _ := ReadLinkStats(1, 1); len(top.TopPhotos) != 1 || top.TopPhotos[0].PhotoID != second.ID {
+		t.Errorf("TopPhotos with limit 1 = %+v", top.TopPhotos)
+	}
+	if empty, _ := ReadLinkStats(3, 10); empty.Views != 0 || empty.FirstAccess != nil || len(empty.TopPhotos) != 0 {
+		t.Errorf("stats of a link without accesses = %+v", empty)
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	sweeperShortname   = "[LinkSweeper]"
+	linkSummaryPeriod  = 7 * 24 * time.Hour
+	linkSummaryEventID = "links.retired.weekly"
+)
+
+// RetiredLink describes a share link that was retired by the sweeper
+type RetiredLink struct {
+	ID        uint       `json:"id"`
+	ProjectID uint       `json:"project_id"`
+	Token     string     `json:"token"`
+	Alias     string     `json:"alias"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	RetiredAt time.Time  `json:"retired_at"`
+}
+
+// expiredLinks matches share links past their expiry time
+const expiredLinks = "expires_at IS NOT NULL AND expires_at <= ?"
+
+// SweepExpiredLinks soft-deletes share links past their expiry time and removes their
+// exclusions. The client's selections and comments stay with the soft-deleted link
+// for the photographer. The expiry is checked again when deleting, so a link extended in the
+// meantime stays. Returns the links that were retired.
+func SweepExpiredLinks(now time.Time) ([]models.ShareLink, error) {
+	var retired []models.ShareLink
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var linkIDs []uint
+		if err := tx.Model(&models.ShareLink{}).Where(expiredLinks, now).Pluck("id", &linkIDs).Error; err != nil {
+			return err
+		}
+		if len(linkIDs) == 0 {
+			return nil
+		}
+		if err := tx.Where("id IN ?", linkIDs).Where(expiredLinks, now).Delete(&models.ShareLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("id IN ? AND deleted_at IS NOT NULL", linkIDs).Where(expiredLinks, now).Order("id").Find(&retired).Error; err != nil {
+			return err
+		}
+		if len(retired) == 0 {
+			return nil
+		}
+
+		linkIDs = linkIDs[:0]
+		for _, l := range retired {
+			linkIDs = append(linkIDs, l.ID)
+		}
+		if err := tx.Where("link_id IN ?", linkIDs).Delete(&models.PhotoExclusion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("link_id IN ?", linkIDs).Delete(&models.PhotoHighlight{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("link_id IN ?", linkIDs).Delete(&models.RawExclusion{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return retired, nil
+}
+
+// RetiredLinksSince returns links that were auto-retired (deleted after expiring) since the given time
+func RetiredLinksSince(since time.Time) ([]RetiredLink, error) {
+	var links []models.ShareLink
+	err := database.DB.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at >= ?", since).
+		Where("expires_at IS NOT NULL AND expires_at <= deleted_at").
+		Find(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	retired := make([]RetiredLink, 0, len(links))
+	for _, l := range links {
+		retired = append(retired, RetiredLink{
+			ID:        l.ID,
+			ProjectID: l.ProjectID,
+			Token:     l.Token,
+			Alias:     l.Alias,
+			ExpiresAt: l.ExpiresAt,
+			RetiredAt: l.DeletedAt.Time,
+		})
+	}
+	return retired, nil
+}
+
+// StartLinkSweeper runs the expired link sweep on the given interval and, when notifications
+// are configured, sends a weekly summary of retired links (per project for projects
+// with their own webhook). When the summary was last sent is kept in the database, so
+// restarts don't postpone it.
+func StartLinkSweeper(interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("%s Disabled", sweeperShortname)
+		return
+	}
+
+	log.Printf("%s Started with interval %s", sweeperShortname, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			runLinkSweep()
+
+			now := time.Now()
+			if lastSummary, ok := lastLinkSummary(now); ok && now.Sub(lastSummary) >= linkSummaryPeriod {
+				sendRetiredLinkSummary(lastSummary)
+				recordLinkSummary(now)
+			}
+
+			<-ticker.C
+		}
+	}()
+}
+
+// lastLinkSummary returns when the weekly summary was last sent. The first time, now
+// is recorded as the start of the first week. false if the database can't tell.
+func lastLinkSummary(now time.Time) (time.Time, bool) {
+	value, err := ReadSetting(models.SettingLinkSummarySentAt)
+	if err != nil {
+		log.Printf("%s Failed to read when the weekly summary was sent: %v", sweeperShortname, err)
+		return time.Time{}, false
+	}
+	if sentAt, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return sentAt, true
+	}
+	recordLinkSummary(now)
+	return now, true
+}
+
+// recordLinkSummary keeps when the weekly summary was sent
+func recordLinkSummary(sentAt time.Time) {
+	if err := WriteSetting(models.SettingLinkSummarySentAt, sentAt.Format(time.RFC3339Nano)); err != nil {
+		log.Printf("%s Failed to record the weekly summary: %v", sweeperShortname, err)
+	}
+}
+
+func runLinkSweep() {
+	retired, err := SweepExpiredLinks(time.Now())
+	if err != nil {
+		log.Printf("%s Sweep failed: %v", sweeperShortname, err)
+		return
+	}
+	if len(retired) > 0 {
+		log.Printf("%s Retired %d expired share links", sweeperShortname, len(retired))
+	}
+	for _, l := range retired {
+		RemoveShareCard(l.Token)
+	}
+}
+
+func sendRetiredLinkSummary(since time.Time) {
+	retired, err := RetiredLinksSince(since)
+	if err != nil {
+		log.Printf("%s Failed to build weekly summary: %v", sweeperShortname, err)
+		return
+	}
+	if len(retired) == 0 {
+		return
+	}
+
+	// Projects with their own webhook get their own summary; the rest share the global one
+	byProject := map[uint][]RetiredLink{}
+	projectIDs := []uint{}
+	for _, l := range retired {
+		if _, ok := byProject[l.ProjectID]; !ok {
+			projectIDs = append(projectIDs, l.ProjectID)
+		}
+		byProject[l.ProjectID] = append(byProject[l.ProjectID], l)
+	}
+	var overrides []models.Project
+	database.DB.Unscoped().Where("id IN ? AND notify_webhook_url <> ''", projectIDs).Order("id").Find(&overrides)
+
+	global := retired
+	if len(overrides) > 0 {
+		global = nil
+		overridden := map[uint]bool{}
+		for i := range overrides {
+			project := &overrides[i]
+			overridden[project.ID] = true
+			links := byProject[project.ID]
+			message := fmt.Sprintf("%d share links of %s were retired after expiring this week", len(links), project.Name)
+			if err := NotifyProject(project, linkSummaryEventID, message, links); err != nil {
+				log.Printf("%s Failed to send weekly summary of project %d: %v", sweeperShortname, project.ID, err)
+			}
+		}
+		for _, l := range retired {
+			if !overridden[l.ProjectID] {
+				global = append(global, l)
+			}
+		}
+	}
+	if len(global) == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("%d share links were retired after expiring this week", len(global))
+	if err := Notify(linkSummaryEventID, message, global); err != nil {
+		log.Printf("%s Failed to send weekly summary: %v", sweeperShortname, err)
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupSweeperTestDB creates an in-memory database for sweeper tests
+func setupSweeperTestDB(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.ShareLink{}, &models.PhotoExclusion{}, &models.PhotoHighlight{}, &models.RawExclusion{}, &models.PhotoSelection{}, &models.PhotoComment{}, &models.Setting{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+}
+
+func TestSweepExpiredLinks(t *testing.T) {
+	setupSweeperTestDB(t)
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	expired := models.ShareLink{ProjectID: 1, Token: "expired", ExpiresAt: &past}
+	active := models.ShareLink{ProjectID: 1, Token: "active", ExpiresAt: &future}
+	forever := models.ShareLink{ProjectID: 1, Token: "forever"}
+	usedUp := models.ShareLink{ProjectID: 1, Token: "used-up", MaxDownloads: 1, DownloadCount: 1}
+	downloadsLeft := models.ShareLink{ProjectID: 1, Token: "downloads-left", MaxDownloads: 2, DownloadCount: 1}
+	for _, l := range []*models.ShareLink{&expired, &active, &forever, &usedUp, &downloadsLeft} {
+		if err := database.DB.Create(l).Error; err != nil {
+			t.Fatalf("Failed to create link: %v", err)
+		}
+	}
+	database.DB.Create(&models.PhotoExclusion{LinkID: expired.ID, PhotoID: 10})
+	database.DB.Create(&models.PhotoExclusion{LinkID: active.ID, PhotoID: 10})
+	database.DB.Create(&models.RawExclusion{LinkID: expired.ID, PhotoID: 11})
+	database.DB.Create(&models.PhotoSelection{LinkID: expired.ID, PhotoID: 12})
+	database.DB.Create(&models.PhotoComment{LinkID: expired.ID, PhotoID: 12, Body: "Crop a bit tighter"})
+
+	retired, err := SweepExpiredLinks(now)
+	if err != nil {
+		t.Fatalf("SweepExpiredLinks returned error: %v", err)
+	}
+	if len(retired) != 1 || retired[0].ID != expired.ID {
+		t.Fatalf("Expected only the expired link to be retired, got %+v", retired)
+	}
+
+	// Used up downloads only stop downloads, the gallery stays
+	var remaining int64
+	database.DB.Model(&models.ShareLink{}).Count(&remaining)
+	if remaining != 4 {
+		t.Errorf("Expected 4 remaining links, got %d", remaining)
+	}
+
+	var exclusions int64
+	database.DB.Model(&models.PhotoExclusion{}).Where("link_id = ?", expired.ID).Count(&exclusions)
+	if exclusions != 0 {
+		t.Errorf("Exclusions of retired link should be removed, got %d", exclusions)
+	}
+	database.DB.Model(&models.PhotoExclusion{}).Where("link_id = ?", active.ID).Count(&exclusions)
+	if exclusions != 1 {
+		t.Errorf("Exclusions of active link should be kept, got %d", exclusions)
+	}
+	database.DB.Model(&models.RawExclusion{}).Where("link_id = ?", expired.ID).Count(&exclusions)
+	if exclusions != 0 {
+		t.Errorf("RAW exclusions of retired link should be removed, got %d", exclusions)
+	}
+
+	var selections int64
+	database.DB.Model(&models.PhotoSelection{}).Where("link_id = ?", expired.ID).Count(&selections)
+	if selections != 1 {
+		t.Errorf("Selections of retired link should be kept, got %d", selections)
+	}
+	var comments int64
+	database.DB.Model(&models.PhotoComment{}).Where("link_id = ?", expired.ID).Count(&comments)
+	if comments != 1 {
+		t.Errorf("Comments of retired link should be kept, got %d", comments)
+	}
+
+	// Second sweep is a no-op
+	retired, err = SweepExpiredLinks(now)
+	if err != nil || len(retired) != 0 {
+		t.Errorf("Second sweep should retire nothing, got %d (err=%v)", len(retired), err)
+	}
+}
+
+func TestRetiredLinksSince(t *testing.T) {
+	setupSweeperTestDB(t)
+
+	past := time.Now().Add(-time.Hour)
+	expired := models.ShareLink{ProjectID: 1, Token: "expired", ExpiresAt: &past}
+	manual := models.ShareLink{ProjectID: 1, Token: "manual"}
+	database.DB.Create(&expired)
+	database.DB.Create(&manual)
+
+	// Manually deleted links are not reported as auto-retired
+	database.DB.Delete(&manual)
+
+	if _, err := SweepExpiredLinks(time.Now()); err != nil {
+		t.Fatalf("SweepExpiredLinks returned error: %v", err)
+	}
+
+	retired, err := RetiredLinksSince(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("RetiredLinksSince returned error: %v", err)
+	}
+	if len(retired) != 1 || retired[0].Token != "expired" {
+		t.Errorf("Expected only the expired link in summary, got %+v", retired)
+	}
+}
+
+func TestLastLinkSummary(t *testing.T) {
+	setupSweeperTestDB(t)
+
+	// The first run starts the first week
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	if sentAt, ok := lastLinkSummary(start); !ok || !sentAt.Equal(start) {
+		t.Fatalf("lastLinkSummary() on the first run = %v, %v; want %v", sentAt, ok, start)
+	}
+	if sentAt, _ := lastLinkSummary(start.Add(48 * time.Hour)); !sentAt.Equal(start) {
+		t.Errorf("lastLinkSummary() after a restart = %v, want %v", sentAt, start)
+	}
+
+	sent := start.Add(linkSummaryPeriod)
+	recordLinkSummary(sent)
+	if sentAt, _ := lastLinkSummary(sent.Add(time.Hour)); !sentAt.Equal(sent) {
+		t.Errorf("lastLinkSummary() after sending = %v, want %v", sentAt, sent)
+	}
+}
+
+func TestShareLinkIsExpired(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name     string
+		expires  *time.Time
+		expected bool
+	}{
+		{"no expiry", nil, false},
+		{"zero expiry", &time.Time{}, false},
+		{"past", &past, true},
+		{"future", &future, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := models.ShareLink{ExpiresAt: tt.expires}
+			if got := link.IsExpired(); got != tt.expected {
+				t.Errorf("IsExpired() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
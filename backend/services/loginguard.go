@@ -0,0 +1,119 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+const loginShortname = "[LoginGuard]"
+
+const (
+	loginFreeFailures   = 3                   // Failures before backoff starts
+	loginBackoffBase    = time.Second         // Wait after the first failure beyond the free ones, doubled with each further one
+	loginBackoffMax     = 5 * time.Minute     // Longest backoff before the lockout
+	loginAuditRetention = 30 * 24 * time.Hour // How long login attempts are kept
+)
+
+// LoginRetryAfter returns how long admin logins from ip or as username must wait
+// after recent failures, 0 if they may try now. The IP and the username are counted
+// separately and the longer wait applies, so neither rotating usernames nor IPs gets
+// around it. From the fourth failure on each one doubles the wait; LOGIN_MAX_FAILURES
+// locks the login for LOGIN_LOCKOUT_MINUTES. Failures before the last success and
+// older than the lockout don't count, nor do refused attempts, so a lockout ends on
+// time even while someone keeps trying.
+func LoginRetryAfter(ip, username string, now time.Time) (time.Duration, error) {
+	if config.AppConfig.LoginMaxFailures <= 0 {
+		return 0, nil
+	}
+	var wait time.Duration
+	for _, key := range []struct{ column, value string }{{"ip", ip}, {"username", username}} {
+		d, err := loginKeyRetryAfter(key.column, key.value, now)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, d)
+	}
+	return wait, nil
+}
+
+// loginKeyRetryAfter returns the wait for the recent failures with one IP or username
+func loginKeyRetryAfter(column, value string, now time.Time) (time.Duration, error) {
+	since := now.Add(-loginLockout())
+	var lastSuccess models.LoginAttempt
+	err := database.DB.Select("created_at").Where(column+" = ? AND success = ? AND created_at > ?", value, true, since).
+		Order("created_at DESC").Limit(1).Find(&lastSuccess).Error
+	if err != nil {
+		return 0, err
+	}
+	if lastSuccess.CreatedAt.After(since) {
+		since = lastSuccess.CreatedAt
+	}
+
+	var failures []models.LoginAttempt
+	err = database.DB.Select("created_at").Where(column+" = ? AND success = ? AND blocked = ? AND created_at > ?", value, false, false, since).
+		Order("created_at DESC").Find(&failures).Error
+	if err != nil || len(failures) == 0 {
+		return 0, err
+	}
+	retry := failures[0].CreatedAt.Add(loginBackoff(len(failures)))
+	return max(retry.Sub(now), 0), nil
+}
+
+// loginBackoff returns how long to wait after a number of consecutive failures
+func loginBackoff(failures int) time.Duration {
+	switch {
+	case failures >= config.AppConfig.LoginMaxFailures:
+		return loginLockout()
+	case failures < loginFreeFailures:
+		return 0
+	case failures-loginFreeFailures >= 16:
+		return loginBackoffMax
+	}
+	return min(loginBackoffBase<<(failures-loginFreeFailures), loginBackoffMax)
+}
+
+// loginLockout returns how long too many failures lock the login
+func loginLockout() time.Duration {
+	return time.Duration(config.AppConfig.LoginLockoutMinutes) * time.Minute
+}
+
+// RecordLoginAttempt stores an admin login attempt for the backoff and the audit. A
+// successful login also deletes attempts older than the retention.
+func RecordLoginAttempt(attempt *models.LoginAttempt) {
+	if err := database.DB.Create(attempt).Error; err != nil {
+		log.Printf("%s Failed to record login attempt: %v", loginShortname, err)
+		return
+	}
+	if !attempt.Success {
+		if !attempt.Blocked {
+			log.Printf("%s Failed login as %q from %s", loginShortname, attempt.Username, attempt.IP)
+		}
+		return
+	}
+	database.DB.Where("created_at < ?", attempt.CreatedAt.Add(-loginAuditRetention)).Delete(&models.LoginAttempt{})
+}
+
+// LoginAudit lists recent admin login attempts, newest first
+func LoginAudit(q models.LoginAuditQuery) ([]models.LoginAttempt, error) {
+	query := database.DB.Order("created_at DESC, id DESC")
+	if q.IP != "" {
+		query = query.Where("ip = ?", q.IP)
+	}
+	if q.Username != "" {
+		query = query.Where("username = ?", q.Username)
+	}
+	if q.Success != nil {
+		query = query.Where("success = ?", *q.Success)
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = models.DefaultLoginAuditLimit
+	}
+	attempts := []models.LoginAttempt{}
+	err := query.Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func setupLoginGuardTest(t *testing.T) {
+	setupProjectTest(t)
+	database.DB.AutoMigrate(&models.LoginAttempt{})
+	config.AppConfig.LoginMaxFailures = 5
+	config.AppConfig.LoginLockoutMinutes = 15
+}
+
+func TestLoginRetryAfter(t *testing.T) {
+	setupLoginGuardTest(t)
+	now := time.Now()
+	fail := func(ip, username string, at time.Time) {
+		RecordLoginAttempt(&models.LoginAttempt{IP: ip, Username: username, CreatedAt: at})
+	}
+	retryAfter := func(ip, username string) time.Duration {
+		t.Helper()
+		wait, err := LoginRetryAfter(ip, username, now)
+		if err != nil {
+			t.Fatalf("LoginRetryAfter failed: %v", err)
+		}
+		return wait
+	}
+
+	// The first failures are free
+	for i := 0; i < loginFreeFailures-1; i++ {
+		fail("10.0.0.1", "admin", now)
+	}
+	if wait := retryAfter("10.0.0.1", "admin"); wait != 0 {
+		t.Errorf("Expected no wait after %d failures, got %s", loginFreeFailures-1, wait)
+	}
+
+	// Then the wait doubles, for the IP and the username alike
+	fail("10.0.0.1", "admin", now)
+	if wait := retryAfter("10.0.0.1", "other"); wait != time.Second {
+		t.Errorf("Expected 1s for the IP, got %s", wait)
+	}
+	if wait := retryAfter("10.0.0.2", "admin"); wait != time.Second {
+		t.Errorf("Expected 1s for the username, got %s", wait)
+	}
+	fail("10.0.0.2", "admin", now)
+	if wait := retryAfter("10.0.0.2", "admin"); wait != 2*time.Second {
+		t.Errorf("Expected 2s after 4 failures, got %s", wait)
+	}
+	if wait := retryAfter("10.0.0.3", "root"); wait != 0 {
+		t.Errorf("Expected no wait for another IP and username, got %s", wait)
+	}
+
+	// Refused attempts don't count
+	RecordLoginAttempt(&models.LoginAttempt{IP: "10.0.0.2", Username: "admin", Blocked: true, CreatedAt: now})
+	if wait := retryAfter("10.0.0.2", "admin"); wait != 2*time.Second {
+		t.Errorf("Expected blocked attempts to be ignored, got %s", wait)
+	}
+
+	// LOGIN_MAX_FAILURES locks the login
+	fail("10.0.0.2", "admin", now)
+	if wait := retryAfter("10.0.0.2", "admin"); wait != 15*time.Minute {
+		t.Errorf("Expected a 15 minute lockout, got %s", wait)
+	}
+
+	// A success resets the count
+	RecordLoginAttempt(&models.LoginAttempt{IP: "10.0.0.2", Username: "admin", Success: true, CreatedAt: now.Add(time.Millisecond)})
+	if wait, _ := LoginRetryAfter("10.0.0.2", "admin", now.Add(time.Second)); wait != 0 {
+		t.Errorf("Expected no wait after a success, got %s", wait)
+	}
+}
+
+func TestLoginRetryAfterExpires(t *testing.T) {
+	setupLoginGuardTest(t)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		RecordLoginAttempt(&models.LoginAttempt{IP: "10.0.0.1", Username: "admin", CreatedAt: now.Add(-20 * time.Minute)})
+	}
+	if wait, err := LoginRetryAfter("10.0.0.1", "admin", now); err != nil || wait != 0 {
+		t.Errorf("Expected failures older than the lockout to be forgotten, got %s, %v", wait, err)
+	}
+
+	config.AppConfig.LoginMaxFailures = 0
+	for i := 0; i < 5; i++ {
+		RecordLoginAttempt(&models.LoginAttempt{IP: "10.0.0.1", Username: "admin", CreatedAt: now})
+	}
+	if wait, _ := LoginRetryAfter("10.0.0.1", "admin", now); wait != 0 {
+		t.Errorf("Expected no wait with LOGIN_MAX_FAILURES=0, got %s", wait)
+	}
+}
+
+func TestLoginAudit(t *testing.T) {
+	setupLoginGuardTest(t)
+	now := time.Now()
+	old := models.LoginAttempt{IP: "10.0.0.1", Username: "admin", CreatedAt: now.Add(-loginAuditRetention - time.Hour)}
+	RecordLoginAttempt(&old)
+	RecordLoginAttempt(&models.LoginAttempt{IP: "10.0.0.1", Username: "admin", Country: "DE", CreatedAt: now.Add(-time.Minute)})
+	RecordLoginAttempt(&models.LoginAttempt{IP: "10.0.0.2", Username: "admin", Success: true, CreatedAt: now})
+
+	attempts, err := LoginAudit(models.LoginAuditQuery{})
+	if err != nil {
+		t.Fatalf("LoginAudit failed: %v", err)
+	}
+	if len(attempts) != 2 || !attempts[0].Success || attempts[1].Country != "DE" {
+		t.Fatalf("Expected the two recent attempts newest first, got %+v", attempts)
+	}
+
+	failed := false
+	attempts, _ = LoginAudit(models.LoginAuditQuery{Success: &failed, IP: "10.0.0.1"})
+	if len(attempts) != 1 || attempts[0].Success {
+		t.Errorf("Expected the failed attempt, got %+v", attempts)
+	}
+	attempts, _ = LoginAudit(models.LoginAuditQuery{Limit: 1})
+	if len(attempts) != 1 {
+		t.Errorf("Expected the limit to apply, got %d attempts", len(attempts))
+	}
+}
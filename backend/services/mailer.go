@@ -0,0 +1,261 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+const (
+	mailerShortname = "[Mail]"
+	// zipMailInterval is how long after a ZIP download email further downloads of the
+	// same link are not emailed, so resumed and repeated downloads don't flood the inbox
+	zipMailInterval = time.Hour
+	// selectionMailFiles limits the file names listed in a selections email
+	selectionMailFiles = 200
+)
+
+// ErrSMTPDisabled means SMTP_HOST (or a sender address) is not set, so no email
+// can be sent to clients
+var ErrSMTPDisabled = errors.New("email is not configured (SMTP_HOST, SMTP_FROM)")
+
+// GalleryEmail is the "your gallery is ready" email sent to a client
+type GalleryEmail struct {
+	Title           string
+	URL             string
+	Password        string // Empty when the password is sent separately
+	PasswordEnabled bool
+	ExpiresAt       string // Local time, empty if the link doesn't expire
+	Message         string // Personal note of the photographer
+	Locale          string
+}
+
+// galleryEmailTemplates holds the "subject" and "body" of the gallery email per locale
+var galleryEmailTemplates = map[string]*template.Template{
+	"zh-CN": template.Must(template.New("zh-CN").Parse(`{{define "subject"}}您的照片已准备好：{{.Title}}{{end}}
+{{- define "body"}}您好，
+
+您在「{{.Title}}」的照片已可以在线查看和下载{{if .ExpiresAt}}，有效期至 {{.ExpiresAt}}{{end}}。
+{{if .Message}}
+{{.Message}}
+{{end}}
+打开相册：{{.URL}}
+{{if .Password}}访问密码：{{.Password}}
+{{else if .PasswordEnabled}}访问密码将另行发送给您。
+{{end}}{{end}}`)),
+	"zh-TW": template.Must(template.New("zh-TW").Parse(`{{define "subject"}}您的照片已準備好：{{.Title}}{{end}}
+{{- define "body"}}您好，
+
+您在「{{.Title}}」的照片已可以線上瀏覽和下載{{if .ExpiresAt}}，有效期至 {{.ExpiresAt}}{{end}}。
+{{if .Message}}
+{{.Message}}
+{{end}}
+開啟相簿：{{.URL}}
+{{if .Password}}存取密碼：{{.Password}}
+{{else if .PasswordEnabled}}存取密碼將另行傳送給您。
+{{end}}{{end}}`)),
+	"en": template.Must(template.New("en").Parse(`{{define "subject"}}Your photos are ready: {{.Title}}{{end}}
+{{- define "body"}}Hello,
+
+Your photos of {{.Title}} are ready to view and download{{if .ExpiresAt}} until {{.ExpiresAt}}{{end}}.
+{{if .Message}}
+{{.Message}}
+{{end}}
+Open the gallery: {{.URL}}
+{{if .Password}}Password: {{.Password}}
+{{else if .PasswordEnabled}}The password will be sent to you separately.
+{{end}}{{end}}`)),
+	"ja": template.Must(template.New("ja").Parse(`{{define "subject"}}写真の準備ができました：{{.Title}}{{end}}
+{{- define "body"}}こんにちは。
+
+「{{.Title}}」の写真をオンラインで閲覧・ダウンロードいただけます{{if .ExpiresAt}}（{{.ExpiresAt}} まで）{{end}}。
+{{if .Message}}
+{{.Message}}
+{{end}}
+ギャラリーを開く：{{.URL}}
+{{if .Password}}パスワード：{{.Password}}
+{{else if .PasswordEnabled}}パスワードは別途お送りします。
+{{end}}{{end}}`)),
+}
+
+// ShareURL returns the address of a share link's gallery under baseURL
+func ShareURL(baseURL, token string) string {
+	return strings.TrimRight(baseURL, "/") + "/s/" + token
+}
+
+// linkTitle names a link in emails: the project, and the alias if it has one.
+// The project is loaded if the link doesn't carry it.
+func linkTitle(link *models.ShareLink) string {
+	if link.Project.ID == 0 {
+		database.DB.Select("id", "name").First(&link.Project, link.ProjectID)
+	}
+	if link.Alias != "" {
+		return fmt.Sprintf("%s - %s", link.Project.Name, link.Alias)
+	}
+	return link.Project.Name
+}
+
+// NewGalleryEmail prepares the gallery email of a link. The language is locale, then
+// the link's language, then utils.DefaultLocale.
+func NewGalleryEmail(link *models.ShareLink, baseURL, password, message, locale string) *GalleryEmail {
+	email := &GalleryEmail{
+		Title:           linkTitle(link),
+		URL:             ShareURL(baseURL, link.Token),
+		Password:        password,
+		PasswordEnabled: link.PasswordEnabled,
+		Message:         strings.TrimSpace(message),
+		Locale:          utils.NormalizeLocale(locale),
+	}
+	if email.Locale == "" {
+		email.Locale = utils.NormalizeLocale(link.Locale)
+	}
+	if email.Locale == "" {
+		email.Locale = utils.DefaultLocale
+	}
+	if link.ExpiresAt != nil && !link.ExpiresAt.IsZero() {
+		email.ExpiresAt = link.ExpiresAt.Local().Format("2006-01-02 15:04")
+	}
+	return email
+}
+
+// Render fills in the subject and body of the email's language
+func (e *GalleryEmail) Render() (subject, body string, err error) {
+	tmpl, ok := galleryEmailTemplates[e.Locale]
+	if !ok {
+		tmpl = galleryEmailTemplates[utils.DefaultLocale]
+	}
+	var b strings.Builder
+	if err := tmpl.ExecuteTemplate(&b, "subject", e); err != nil {
+		return "", "", err
+	}
+	subject = b.String()
+	b.Reset()
+	if err := tmpl.ExecuteTemplate(&b, "body", e); err != nil {
+		return "", "", err
+	}
+	return subject, b.String(), nil
+}
+
+// SendGalleryEmail emails a client that their gallery is ready. Unlike admin emails
+// it only needs SMTP_HOST, not ADMIN_EMAIL.
+func SendGalleryEmail(to []string, email *GalleryEmail) error {
+	if config.AppConfig.SMTPHost == "" || config.AppConfig.MailFrom() == "" {
+		return ErrSMTPDisabled
+	}
+	subject, body, err := email.Render()
+	if err != nil {
+		return err
+	}
+	if err := utils.SendMail(to, subject, body); err != nil {
+		return err
+	}
+	log.Printf("%s Sent gallery email for %s to %s", mailerShortname, email.Title, strings.Join(to, ", "))
+	return nil
+}
+
+// mailAdmin emails ADMIN_EMAIL in the background; failures are only logged
+func mailAdmin(kind, subject, body string) {
+	go func() {
+		if err := utils.SendMail(config.AppConfig.AdminEmails, subject, body); err != nil {
+			log.Printf("%s Failed to send %s email: %v", mailerShortname, kind, err)
+			return
+		}
+		log.Printf("%s Sent %s email", mailerShortname, kind)
+	}()
+}
+
+// NotifySelectionsSubmitted emails the photographer the photos a client submitted,
+// with MAIL_ON_SELECTIONS
+func NotifySelectionsSubmitted(link *models.ShareLink, baseURL, note string) {
+	if !config.AppConfig.MailEnabled() || !config.AppConfig.MailOnSelections {
+		return
+	}
+	report, err := SelectionReport(link)
+	if err != nil {
+		log.Printf("%s Failed to list the selections of link %d: %v", mailerShortname, link.ID, err)
+		return
+	}
+	files := make([]string, 0, len(report))
+	for _, row := range report {
+		if !row.Hidden {
+			files = append(files, row.FileName)
+		}
+	}
+	subject, body := selectionsMail(linkTitle(link), ShareURL(baseURL, link.Token), note, files)
+	mailAdmin("selections", subject, body)
+}
+
+// selectionsMail renders the email about submitted selections
+func selectionsMail(title, url, note string, files []string) (subject, body string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The client submitted %d selected photos on %s.\n", len(files), title)
+	if note = strings.TrimSpace(note); note != "" {
+		fmt.Fprintf(&b, "\nNote from the client:\n%s\n", note)
+	}
+	fmt.Fprintf(&b, "\nGallery: %s\n\nSelected files:\n", url)
+	for i, file := range files {
+		if i == selectionMailFiles {
+			fmt.Fprintf(&b, "  ... and %d more\n", len(files)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  - %s\n", file)
+	}
+	b.WriteString("\nThe full list can be exported as CSV from the share link in the admin panel.\n")
+	return "Selections submitted: " + title, b.String()
+}
+
+// zipMails remembers when a ZIP download of each link was last emailed
+var zipMails = struct {
+	sync.Mutex
+	sent map[uint]time.Time
+}{sent: make(map[uint]time.Time)}
+
+// allowZipMail reports whether a ZIP download of a link may be emailed now, at most
+// once per zipMailInterval
+func allowZipMail(linkID uint, now time.Time) bool {
+	zipMails.Lock()
+	defer zipMails.Unlock()
+	if last, ok := zipMails.sent[linkID]; ok && now.Sub(last) < zipMailInterval {
+		return false
+	}
+	for id, last := range zipMails.sent {
+		if now.Sub(last) >= zipMailInterval {
+			delete(zipMails.sent, id)
+		}
+	}
+	zipMails.sent[linkID] = now
+	return true
+}
+
+// NotifyZipDownloaded emails the photographer that a client downloaded a full gallery
+// ZIP, with MAIL_ON_ZIP. Downloads of the same link are emailed at most once an hour.
+func NotifyZipDownloaded(link *models.ShareLink, baseURL, downloadType, ip, country string) {
+	if !config.AppConfig.MailEnabled() || !config.AppConfig.MailOnZip || !allowZipMail(link.ID, time.Now()) {
+		return
+	}
+	subject, body := zipMail(linkTitle(link), ShareURL(baseURL, link.Token), downloadType, ip, country, time.Now())
+	mailAdmin("ZIP download", subject, body)
+}
+
+// zipMail renders the email about a full gallery download
+func zipMail(title, url, downloadType, ip, country string, at time.Time) (subject, body string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s was downloaded as a full-gallery ZIP (%s files) on %s.\n\n", title, downloadType, at.Local().Format("Mon 2006-01-02 15:04"))
+	if country != "" {
+		fmt.Fprintf(&b, "Visitor: %s (%s)\n", ip, country)
+	} else {
+		fmt.Fprintf(&b, "Visitor: %s\n", ip)
+	}
+	fmt.Fprintf(&b, "Gallery: %s\n", url)
+	fmt.Fprintf(&b, "\nFurther downloads of this link within the next hour are not emailed.\n")
+	return "Gallery downloaded: " + title, b.String()
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"photobridge/models"
+)
+
+func TestGalleryEmail(t *testing.T) {
+	expires := time.Date(2030, 5, 1, 18, 0, 0, 0, time.Local)
+	link := &models.ShareLink{
+		Token: "abc", Alias: "Family", Locale: "en", PasswordEnabled: true, ExpiresAt: &expires,
+		Project: models.Project{ID: 1, Name: "Wedding"},
+	}
+
+	email := NewGalleryEmail(link, "https://photos.example.com/", "4821", "See you soon!", "")
+	if email.Locale != "en" || email.URL != "https://photos.example.com/s/abc" {
+		t.Fatalf("Unexpected email %+v", email)
+	}
+	subject, body, err := email.Render()
+	if err != nil {
+		t.Fatalf("Render() = %v", err)
+	}
+	if subject != "Your photos are ready: Wedding - Family" {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+	for _, want := range []string{"until 2030-05-01 18:00", "See you soon!", "https://photos.example.com/s/abc", "Password: 4821"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the body to contain %q:\n%s", want, body)
+		}
+	}
+
+	// Without the password the client is told it comes separately
+	_, body, _ = NewGalleryEmail(link, "https://photos.example.com", "", "", "").Render()
+	if strings.Contains(body, "Password:") || !strings.Contains(body, "sent to you separately") {
+		t.Errorf("Unexpected body without password:\n%s", body)
+	}
+
+	// The requested language wins over the link's, the default applies without either
+	for _, tt := range []struct{ linkLocale, locale, want string }{
+		{"en", "ja", "ja"},
+		{"", "zh-Hant", "zh-TW"},
+		{"", "", "zh-CN"},
+	} {
+		link.Locale = tt.linkLocale
+		email := NewGalleryEmail(link, "https://photos.example.com", "", "", tt.locale)
+		if email.Locale != tt.want {
+			t.Errorf("Locale %q/%q = %s, want %s", tt.linkLocale, tt.locale, email.Locale, tt.want)
+		}
+		if subject, body, err := email.Render(); err != nil || !strings.Contains(subject, "Wedding - Family") || !strings.Contains(body, email.URL) {
+			t.Errorf("Render() in %s = %q, %q, %v", tt.want, subject, body, err)
+		}
+	}
+}
+
+func TestSelectionsMail(t *testing.T) {
+	files := make([]string, selectionMailFiles+5)
+	for i := range files {
+		files[i] = fmt.Sprintf("IMG_%04d.jpg", i)
+	}
+	subject, body := selectionsMail("Wedding", "https://photos.example.com/s/abc", " Please retouch 3 ", files)
+	if subject != "Selections submitted: Wedding" {
+		t.Errorf("Unexpected subject %q", subject)
+	}
+	for _, want := range []string{fmt.Sprintf("submitted %d selected photos", len(files)), "Please retouch 3\n", "IMG_0000.jpg", "... and 5 more"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the body to contain %q", want)
+		}
+	}
+	if strings.Contains(body, files[selectionMailFiles]) {
+		t.Error("Expected the file list to be capped")
+	}
+}
+
+func TestAllowZipMail(t *testing.T) {
+	now := time.Now()
+	if !allowZipMail(9001, now) {
+		t.Fatal("Expected the first download to be emailed")
+	}
+	if allowZipMail(9001, now.Add(time.Minute)) {
+		t.Error("Expected a second download within the interval to be skipped")
+	}
+	if !allowZipMail(9002, now.Add(time.Minute)) {
+		t.Error("Expected downloads of another link to be emailed")
+	}
+	if !allowZipMail(9001, now.Add(zipMailInterval)) {
+		t.Error("Expected a download after the interval to be emailed")
+	}
+}
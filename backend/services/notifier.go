@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"photobridge/config"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+const (
+	notifierShortname = "[Notifier]"
+	notifyTimeout     = 10 * time.Second
+)
+
+// Notification is the JSON payload posted to the admin webhook
+type Notification struct {
+	Event     string               `json:"event"`
+	Message   string               `json:"message"`
+	Project   *NotificationProject `json:"project,omitempty"` // Set for project events
+	Data      interface{}          `json:"data,omitempty"`
+	Timestamp time.Time            `json:"timestamp"`
+}
+
+// NotificationProject identifies the project of a project event
+type NotificationProject struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// NotificationsEnabled reports whether an admin webhook is configured
+func NotificationsEnabled() bool {
+	return config.AppConfig != nil && config.AppConfig.NotifyWebhookURL != ""
+}
+
+// Notify posts an event to the configured admin webhook.
+// It is a no-op when NOTIFY_WEBHOOK_URL is not set.
+func Notify(event, message string, data interface{}) error {
+	if !NotificationsEnabled() {
+		return nil
+	}
+	return postNotification(config.AppConfig.NotifyWebhookURL, Notification{
+		Event:     event,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// NotificationTargets resolves the webhooks receiving a project's events: the
+// project's own webhook if it has one (plus the global one with NotifyAlsoGlobal),
+// otherwise NOTIFY_WEBHOOK_URL. Empty when no webhook is configured.
+func NotificationTargets(project *models.Project) []string {
+	global := ""
+	if NotificationsEnabled() {
+		global = config.AppConfig.NotifyWebhookURL
+	}
+	if project.NotifyWebhookURL == "" {
+		if global == "" {
+			return nil
+		}
+		return []string{global}
+	}
+	targets := []string{project.NotifyWebhookURL}
+	if project.NotifyAlsoGlobal && global != "" && global != project.NotifyWebhookURL {
+		targets = append(targets, global)
+	}
+	return targets
+}
+
+// NotifyProject posts a project event to the webhooks resolved by NotificationTargets.
+// Every target is tried; the first error is returned.
+func NotifyProject(project *models.Project, event, message string, data interface{}) error {
+	notification := Notification{
+		Event:     event,
+		Message:   message,
+		Project:   &NotificationProject{ID: project.ID, Name: project.Name},
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+	var firstErr error
+	for _, target := range NotificationTargets(project) {
+		if err := postNotification(target, notification); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// postNotification sends one notification to a webhook
+func postNotification(webhookURL string, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	resp, err := utils.NewHTTPClient(notifyTimeout).Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	log.Printf("%s Sent %s notification", notifierShortname, notification.Event)
+	return nil
+}
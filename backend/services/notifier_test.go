@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+// webhookRecorder is a test webhook that records the notifications it receives
+type webhookRecorder struct {
+	*httptest.Server
+	mu       sync.Mutex
+	received []Notification
+}
+
+func newWebhookRecorder(t *testing.T) *webhookRecorder {
+	w := &webhookRecorder{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var n Notification
+		json.NewDecoder(r.Body).Decode(&n)
+		w.mu.Lock()
+		w.received = append(w.received, n)
+		w.mu.Unlock()
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+func (w *webhookRecorder) notifications() []Notification {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Notification{}, w.received...)
+}
+
+func TestNotificationTargets(t *testing.T) {
+	originalConfig := config.AppConfig
+	defer func() { config.AppConfig = originalConfig }()
+	config.AppConfig = &config.Config{NotifyWebhookURL: "https://global.example/hook"}
+
+	tests := []struct {
+		name    string
+		project models.Project
+		want    []string
+	}{
+		{"inherits global", models.Project{}, []string{"https://global.example/hook"}},
+		{"override", models.Project{NotifyWebhookURL: "https://client.example/hook"}, []string{"https://client.example/hook"}},
+		{"override and global", models.Project{NotifyWebhookURL: "https://client.example/hook", NotifyAlsoGlobal: true},
+			[]string{"https://client.example/hook", "https://global.example/hook"}},
+		{"same as global", models.Project{NotifyWebhookURL: "https://global.example/hook", NotifyAlsoGlobal: true},
+			[]string{"https://global.example/hook"}},
+	}
+	for _, tt := range tests {
+		if got := NotificationTargets(&tt.project); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: NotificationTargets() = %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+
+	config.AppConfig.NotifyWebhookURL = ""
+	if got := NotificationTargets(&models.Project{}); len(got) != 0 {
+		t.Errorf("Expected no targets without any webhook, got %v", got)
+	}
+	project := models.Project{NotifyWebhookURL: "https://client.example/hook", NotifyAlsoGlobal: true}
+	if got := NotificationTargets(&project); !reflect.DeepEqual(got, []string{"https://client.example/hook"}) {
+		t.Errorf("Project webhook should work without a global one, got %v", got)
+	}
+}
+
+func TestRetiredLinkSummaryRouting(t *testing.T) {
+	setupSweeperTestDB(t)
+	database.DB.AutoMigrate(&models.Project{})
+	global, client := newWebhookRecorder(t), newWebhookRecorder(t)
+
+	originalConfig := config.AppConfig
+	defer func() { config.AppConfig = originalConfig }()
+	config.AppConfig = &config.Config{NotifyWebhookURL: global.URL}
+
+	plain := models.Project{Name: "plain"}
+	overridden := models.Project{Name: "client", NotifyWebhookURL: client.URL}
+	database.DB.Create(&plain)
+	database.DB.Create(&overridden)
+
+	since := time.Now().Add(-time.Hour)
+	past := time.Now().Add(-time.Minute)
+	for _, link := range []models.ShareLink{
+		{ProjectID: plain.ID, Token: "plain1", ExpiresAt: &past},
+		{ProjectID: overridden.ID, Token: "client1", ExpiresAt: &past},
+		{ProjectID: overridden.ID, Token: "client2", ExpiresAt: &past},
+	} {
+		database.DB.Create(&link)
+	}
+	if _, err := SweepExpiredLinks(time.Now()); err != nil {
+		t.Fatalf("SweepExpiredLinks failed: %v", err)
+	}
+
+	sendRetiredLinkSummary(since)
+
+	globalReceived, clientReceived := global.notifications(), client.notifications()
+	if len(globalReceived) != 1 || len(globalReceived[0].Data.([]interface{})) != 1 {
+		t.Fatalf("Global webhook should get one summary with the plain project's link, got %+v", globalReceived)
+	}
+	if len(clientReceived) != 1 || len(clientReceived[0].Data.([]interface{})) != 2 {
+		t.Fatalf("Project webhook should get one summary with its two links, got %+v", clientReceived)
+	}
+	if p := clientReceived[0].Project; p == nil || p.ID != overridden.ID || p.Name != "client" {
+		t.Errorf("Project summary should identify the project, got %+v", p)
+	}
+}
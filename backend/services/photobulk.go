@@ -0,0 +1,418 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+
+	"gorm.io/gorm"
+)
+
+const bulkShortname = "[Bulk]"
+
+// ErrTargetProjectNotFound means the project photos should be moved to doesn't exist
+var ErrTargetProjectNotFound = errors.New("target project not found")
+
+// BulkPhotoResult reports what happened to each photo of a bulk operation
+type BulkPhotoResult struct {
+	Done      []uint `json:"done"`                // deleted or moved (including photos already in the target project)
+	NotFound  []uint `json:"not_found"`           // no such photo
+	Conflicts []uint `json:"conflicts,omitempty"` // move: the target project has a photo or file of the same name
+}
+
+// photoLinkModels are the per-link records that refer to photos
+var photoLinkModels = []interface{}{&models.PhotoExclusion{}, &models.PhotoHighlight{}, &models.PhotoSelection{}, &models.RawExclusion{}, &models.PhotoComment{}}
+
+// DeletePhotos deletes photos in one transaction; a failed transaction leaves every
+// photo intact. With the trash enabled the photos and their originals go to the trash
+// (see trashPhotos); otherwise their link references are deleted with them and their
+// files, thumbnails and RAW conversions are removed. Covers pointing at deleted photos
+// move to another photo.
+func DeletePhotos(ids []uint) (BulkPhotoResult, error) {
+	var result BulkPhotoResult
+	projectIDs, err := bulkProjectIDs(ids)
+	if err != nil {
+		return result, err
+	}
+	// Hold off renames of the directories the files are removed from
+	unlock := lockProjects(projectIDs, RLockProject)
+	defer unlock()
+
+	photos, projects, err := loadBulkPhotos(ids, projectIDs)
+	if err != nil {
+		return result, err
+	}
+	result.Done = photoIDs(photos)
+	result.NotFound = missingIDs(ids, result.Done)
+	if len(photos) == 0 {
+		return result, nil
+	}
+
+	backend := storage.Default()
+	trash := trashEnabled()
+	var trashed []fileMove
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if trash {
+			return trashPhotos(tx, backend, photos, projects, &trashed)
+		}
+		for _, model := range photoLinkModels {
+			if err := tx.Where("photo_id IN ?", result.Done).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id IN ?", result.Done).Delete(&models.Photo{}).Error
+	})
+	if err != nil {
+		revertFileMoves(backend, trashed)
+		return BulkPhotoResult{}, fmt.Errorf("failed to delete photos: %w", err)
+	}
+	if _, local := backend.(*storage.Local); trash && !local {
+		var oldKeys []string
+		for _, move := range trashed {
+			oldKeys = append(oldKeys, move.from)
+		}
+		deleteObjects(oldKeys)
+	}
+
+	coversGone := map[uint]string{}
+	for i := range photos {
+		photo := &photos[i]
+		project, ok := projects[photo.ProjectID]
+		if ok {
+			if !trash {
+				for _, key := range photoKeys(project.Name, photo) {
+					// Files might already be missing; the photo is gone either way
+					if err := backend.Delete(context.Background(), key); err != nil {
+						log.Printf("%s Failed to delete %s: %v", bulkShortname, key, err)
+					}
+				}
+			}
+			if name := CoverPhotoName(photo); photo.NormalExt != "" && project.CoverPhoto == name {
+				coversGone[project.ID] = name
+			}
+		}
+		if !trash {
+			RemoveThumbnails(photo.ProjectID, photo.ID)
+			RemoveConvertedJPEG(photo.ID)
+		}
+	}
+	for projectID := range projects {
+		if name, ok := coversGone[projectID]; ok {
+			ReplaceCoverPhoto(projectID, name, "")
+		}
+		// Photo counts changed
+		EnqueueProjectShareCards(projectID)
+	}
+	log.Printf("%s Deleted %d photos from %d projects", bulkShortname, len(photos), len(projects))
+	return result, nil
+}
+
+// MovePhotos moves photos to another project: their files and thumbnails follow, and
+// their exclusions, highlights and selections are dropped since those belong to links
+// of the old project, as is their album. Photos whose name is taken in the target project are left where
+// they are. The database changes and the file moves happen in one transaction; if a
+// file can't be moved, the files moved so far are moved back.
+func MovePhotos(ids []uint, targetID uint) (BulkPhotoResult, error) {
+	result := BulkPhotoResult{Done: []uint{}}
+	projectIDs, err := bulkProjectIDs(ids)
+	if err != nil {
+		return result, err
+	}
+	// Files are moved between directories: no renames or uploads meanwhile
+	unlock := lockProjects(append(projectIDs, targetID), LockProject)
+	defer unlock()
+
+	var target models.Project
+	if err := database.DB.First(&target, targetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return result, ErrTargetProjectNotFound
+		}
+		return result, err
+	}
+	photos, projects, err := loadBulkPhotos(ids, projectIDs)
+	if err != nil {
+		return result, err
+	}
+	result.NotFound = missingIDs(ids, photoIDs(photos))
+
+	var moving []models.Photo
+	taken := map[string]bool{}
+	var names []string
+	for _, photo := range photos {
+		names = append(names, photo.BaseName)
+	}
+	if len(names) > 0 {
+		var existing []string
+		if err := database.DB.Model(&models.Photo{}).Where("project_id = ? AND base_name IN ?", targetID, names).
+			Pluck("base_name", &existing).Error; err != nil {
+			return BulkPhotoResult{}, err
+		}
+		for _, name := range existing {
+			taken[name] = true
+		}
+	}
+	backend := storage.Default()
+	for _, photo := range photos {
+		_, sourceKnown := projects[photo.ProjectID]
+		switch {
+		case photo.ProjectID == targetID:
+			result.Done = append(result.Done, photo.ID)
+		case !sourceKnown || taken[photo.BaseName] || targetFileExists(backend, target.Name, &photo):
+			result.Conflicts = append(result.Conflicts, photo.ID)
+		default:
+			// Two photos of the same name from different projects: the first one wins
+			taken[photo.BaseName] = true
+			moving = append(moving, photo)
+		}
+	}
+	if len(moving) == 0 {
+		return result, nil
+	}
+
+	movingIDs := photoIDs(moving)
+	var moved []fileMove
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockProjectRow(tx, targetID); err != nil {
+			return err
+		}
+		for _, model := range photoLinkModels {
+			if err := tx.Where("photo_id IN ?", movingIDs).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		// Albums belong to the old project
+		if err := tx.Model(&models.Photo{}).Where("id IN ?", movingIDs).
+			Updates(map[string]interface{}{"project_id": targetID, "album_id": nil}).Error; err != nil {
+			return err
+		}
+		for i := range moving {
+			photo := &moving[i]
+			for _, fileName := range photoFileNames(photo) {
+				move := fileMove{
+					from: storage.Key(projects[photo.ProjectID].Name, fileName),
+					to:   storage.Key(target.Name, fileName),
+				}
+				if err := moveObject(backend, move); err != nil {
+					if errors.Is(err, os.ErrNotExist) {
+						continue // Lost file: nothing to move
+					}
+					return fmt.Errorf("failed to move %s: %w", move.from, err)
+				}
+				moved = append(moved, move)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		revertFileMoves(backend, moved)
+		return BulkPhotoResult{}, err
+	}
+	if _, local := backend.(*storage.Local); !local {
+		var oldKeys []string
+		for _, move := range moved {
+			oldKeys = append(oldKeys, move.from)
+		}
+		deleteObjects(oldKeys)
+	}
+
+	sources := map[uint]bool{}
+	firstCover := ""
+	for i := range moving {
+		photo := &moving[i]
+		sources[photo.ProjectID] = true
+		moveThumbnails(photo.ID, photo.ProjectID, targetID)
+		if photo.NormalExt == "" {
+			continue
+		}
+		if name := CoverPhotoName(photo); projects[photo.ProjectID].CoverPhoto == name {
+			ReplaceCoverPhoto(photo.ProjectID, name, "")
+		}
+		if firstCover == "" {
+			firstCover = CoverPhotoName(photo)
+		}
+	}
+	Queue.MovePhotos(movingIDs, targetID, target.Name)
+	for projectID := range sources {
+		EnqueueProjectShareCards(projectID)
+	}
+	if firstCover == "" || !SetDefaultCoverPhoto(targetID, firstCover) {
+		EnqueueProjectShareCards(targetID)
+	}
+
+	result.Done = append(result.Done, movingIDs...)
+	slices.Sort(result.Done)
+	log.Printf("%s Moved %d photos from %d projects to project %d", bulkShortname, len(moving), len(sources), targetID)
+	return result, nil
+}
+
+// fileMove is an original moved from one storage key to another
+type fileMove struct {
+	from, to string
+}
+
+// moveObject moves an original: a rename with local storage, a copy with object
+// storage (the source is deleted after the commit)
+func moveObject(backend storage.Backend, move fileMove) error {
+	local, ok := backend.(*storage.Local)
+	if !ok {
+		return backend.Copy(context.Background(), move.from, move.to)
+	}
+	from, err := local.Path(move.from)
+	if err != nil {
+		return err
+	}
+	to, err := local.Path(move.to)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+// revertFileMoves undoes the moves of a failed transaction
+func revertFileMoves(backend storage.Backend, moved []fileMove) {
+	local, ok := backend.(*storage.Local)
+	for _, move := range moved {
+		if !ok {
+			deleteObjects([]string{move.to})
+			continue
+		}
+		from, _ := local.Path(move.from)
+		to, _ := local.Path(move.to)
+		if err := os.Rename(to, from); err != nil {
+			log.Printf("%s Failed to move %s back, file left at %s: %v", bulkShortname, move.from, move.to, err)
+		}
+	}
+}
+
+// targetFileExists reports whether a file of the photo already exists in a project,
+// e.g. one left behind without a database record, which a move would overwrite
+func targetFileExists(backend storage.Backend, projectName string, photo *models.Photo) bool {
+	for _, key := range photoKeys(projectName, photo) {
+		if _, err := backend.Stat(context.Background(), key); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// moveThumbnails moves the thumbnail files of a photo to its new project's directory.
+// Thumbnails that can't be moved are removed and regenerated when requested.
+func moveThumbnails(photoID, fromProject, toProject uint) {
+	for _, size := range []string{ThumbSizeSmall, ThumbSizeLarge} {
+		from, to := ThumbPath(fromProject, photoID, size), ThumbPath(toProject, photoID, size)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		err := os.MkdirAll(filepath.Dir(to), 0755)
+		if err == nil {
+			err = os.Rename(from, to)
+		}
+		if err != nil {
+			log.Printf("%s Failed to move thumbnail of photo %d: %v", bulkShortname, photoID, err)
+			os.Remove(from)
+		}
+	}
+}
+
+// photoFileNames returns the file names of a photo's originals
+func photoFileNames(photo *models.Photo) []string {
+	var names []string
+	if photo.NormalExt != "" {
+		names = append(names, photo.BaseName+photo.NormalExt)
+	}
+	if photo.HasRaw && photo.RawExt != "" {
+		names = append(names, photo.BaseName+photo.RawExt)
+	}
+	return names
+}
+
+// photoKeys returns the storage keys of a photo's originals in a project
+func photoKeys(projectName string, photo *models.Photo) []string {
+	names := photoFileNames(photo)
+	for i, name := range names {
+		names[i] = storage.Key(projectName, name)
+	}
+	return names
+}
+
+// bulkProjectIDs returns the projects of the given photos, to be locked before the
+// photos are loaded again
+func bulkProjectIDs(ids []uint) ([]uint, error) {
+	var projectIDs []uint
+	err := database.DB.Model(&models.Photo{}).Where("id IN ?", ids).Distinct().Pluck("project_id", &projectIDs).Error
+	return projectIDs, err
+}
+
+// loadBulkPhotos loads the photos of a bulk operation that are (still) in the locked
+// projects, and those projects by ID
+func loadBulkPhotos(ids, projectIDs []uint) ([]models.Photo, map[uint]*models.Project, error) {
+	var photos []models.Photo
+	if len(projectIDs) == 0 {
+		return nil, map[uint]*models.Project{}, nil
+	}
+	if err := database.DB.Select("id, project_id, base_name, normal_ext, raw_ext, has_raw").
+		Where("id IN ? AND project_id IN ?", ids, projectIDs).Order("id").Find(&photos).Error; err != nil {
+		return nil, nil, err
+	}
+	var list []models.Project
+	if err := database.DB.Where("id IN ?", projectIDs).Find(&list).Error; err != nil {
+		return nil, nil, err
+	}
+	projects := make(map[uint]*models.Project, len(list))
+	for i := range list {
+		projects[list[i].ID] = &list[i]
+	}
+	return photos, projects, nil
+}
+
+// lockProjects takes a lock of each project in ID order, so concurrent bulk operations
+// on overlapping projects can't deadlock
+func lockProjects(projectIDs []uint, lock func(uint) func()) (unlock func()) {
+	ids := slices.Clone(projectIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	unlocks := make([]func(), len(ids))
+	for i, id := range ids {
+		unlocks[i] = lock(id)
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+func photoIDs(photos []models.Photo) []uint {
+	ids := make([]uint, len(photos))
+	for i := range photos {
+		ids[i] = photos[i].ID
+	}
+	return ids
+}
+
+// missingIDs returns the requested IDs that were not found, in request order
+func missingIDs(requested, found []uint) []uint {
+	seen := make(map[uint]bool, len(found))
+	for _, id := range found {
+		seen[id] = true
+	}
+	missing := []uint{}
+	for _, id := range requested {
+		if !seen[id] {
+			seen[id] = true
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+// setupBulkTest adds a second project "portraits" to the project test setup, and to
+// "wedding" a RAW+JPEG photo with thumbnails that is highlighted on a link
+func setupBulkTest(t *testing.T) (wedding, portraits *models.Project, photo *models.Photo) {
+	wedding = setupProjectTest(t)
+	config.AppConfig.DatabasePath = filepath.Join(t.TempDir(), "photobridge.db")
+	if err := database.DB.AutoMigrate(&models.PhotoExclusion{}, &models.PhotoHighlight{}, &models.PhotoSelection{}, &models.RawExclusion{}, &models.PhotoComment{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	portraits = &models.Project{Name: "portraits"}
+	database.DB.Create(portraits)
+
+	photo = &models.Photo{ProjectID: wedding.ID, BaseName: "IMG_0002", NormalExt: ".jpg", RawExt: ".ARW", HasRaw: true}
+	database.DB.Create(photo)
+	for _, name := range []string{"IMG_0002.jpg", "IMG_0002.ARW"} {
+		os.WriteFile(filepath.Join(config.AppConfig.UploadDir, wedding.Name, name), []byte(name), 0644)
+	}
+	if err := SaveThumbnails(wedding.ID, photo.ID, []byte("small"), []byte("large")); err != nil {
+		t.Fatalf("SaveThumbnails() = %v", err)
+	}
+	link := models.ShareLink{ProjectID: wedding.ID, Token: "bulk-link"}
+	database.DB.Create(&link)
+	database.DB.Create(&models.PhotoHighlight{LinkID: link.ID, PhotoID: photo.ID})
+	return wedding, portraits, photo
+}
+
+func TestMovePhotos(t *testing.T) {
+	wedding, portraits, photo := setupBulkTest(t)
+	// The portraits project already has an IMG_0001
+	database.DB.Create(&models.Photo{ProjectID: portraits.ID, BaseName: "IMG_0001", NormalExt: ".jpg"})
+	var cover models.Photo
+	database.DB.Where("project_id = ? AND base_name = ?", wedding.ID, "IMG_0001").First(&cover)
+
+	result, err := MovePhotos([]uint{photo.ID, cover.ID, 999}, portraits.ID)
+	if err != nil {
+		t.Fatalf("MovePhotos() = %v", err)
+	}
+	if len(result.Done) != 1 || result.Done[0] != photo.ID || len(result.Conflicts) != 1 || result.Conflicts[0] != cover.ID ||
+		len(result.NotFound) != 1 || result.NotFound[0] != 999 {
+		t.Fatalf("Expected photo %d moved, %d in conflict and 999 not found, got %+v", photo.ID, cover.ID, result)
+	}
+
+	var moved models.Photo
+	database.DB.First(&moved, photo.ID)
+	if moved.ProjectID != portraits.ID {
+		t.Errorf("Expected the photo in project %d, got %d", portraits.ID, moved.ProjectID)
+	}
+	for _, name := range []string{"IMG_0002.jpg", "IMG_0002.ARW"} {
+		if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, portraits.Name, name)); err != nil {
+			t.Errorf("Expected %s in the target directory: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, wedding.Name, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s gone from the source directory, got %v", name, err)
+		}
+	}
+	if !HasThumbnails(&moved) {
+		t.Error("Expected the thumbnails to move with the photo")
+	}
+	var highlights int64
+	database.DB.Model(&models.PhotoHighlight{}).Where("photo_id = ?", photo.ID).Count(&highlights)
+	if highlights != 0 {
+		t.Error("Expected highlights on links of the old project to be dropped")
+	}
+	var target models.Project
+	database.DB.First(&target, portraits.ID)
+	if target.CoverPhoto != "IMG_0002.jpg" {
+		t.Errorf("Expected the moved photo to become the cover of the target, got %q", target.CoverPhoto)
+	}
+
+	if _, err := MovePhotos([]uint{photo.ID}, 999); err != ErrTargetProjectNotFound {
+		t.Errorf("Expected ErrTargetProjectNotFound, got %v", err)
+	}
+}
+
+func TestMovePhotosStrayTargetFile(t *testing.T) {
+	_, portraits, photo := setupBulkTest(t)
+	// A file without a photo record (e.g. copied in by hand) would be overwritten
+	dir := filepath.Join(config.AppConfig.UploadDir, portraits.Name)
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "IMG_0002.ARW"), []byte("other"), 0644)
+
+	result, err := MovePhotos([]uint{photo.ID}, portraits.ID)
+	if err != nil || len(result.Conflicts) != 1 || len(result.Done) != 0 {
+		t.Fatalf("Expected a conflict for the existing file, got %+v (%v)", result, err)
+	}
+	var stored models.Photo
+	database.DB.First(&stored, photo.ID)
+	if stored.ProjectID != photo.ProjectID {
+		t.Error("Expected the photo to stay in its project")
+	}
+}
+
+func TestDeletePhotos(t *testing.T) {
+	wedding, _, photo := setupBulkTest(t)
+	var cover models.Photo
+	database.DB.Where("project_id = ? AND base_name = ?", wedding.ID, "IMG_0001").First(&cover)
+
+	result, err := DeletePhotos([]uint{cover.ID, 999})
+	if err != nil {
+		t.Fatalf("DeletePhotos() = %v", err)
+	}
+	if len(result.Done) != 1 || len(result.NotFound) != 1 || result.NotFound[0] != 999 {
+		t.Fatalf("Expected one deleted and 999 not found, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, wedding.Name, "IMG_0001.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected the file to be removed, got %v", err)
+	}
+	var stored models.Project
+	database.DB.First(&stored, wedding.ID)
+	if stored.CoverPhoto != "IMG_0002.jpg" {
+		t.Errorf("Expected the cover to move to the remaining photo, got %q", stored.CoverPhoto)
+	}
+
+	if _, err := DeletePhotos([]uint{photo.ID}); err != nil {
+		t.Fatalf("DeletePhotos() = %v", err)
+	}
+	var highlights, photos int64
+	database.DB.Model(&models.PhotoHighlight{}).Count(&highlights)
+	database.DB.Model(&models.Photo{}).Count(&photos)
+	if highlights != 0 || photos != 0 {
+		t.Errorf("Expected no photos or highlights left, got %d and %d", photos, highlights)
+	}
+	if HasThumbnails(photo) {
+		t.Error("Expected the thumbnails to be removed")
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"strings"
+
+	"photobridge/common"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+// photoSortColumns maps the sort keys of a photo listing to columns
+var photoSortColumns = map[string]string{
+	models.PhotoSortName:      "base_name",
+	models.PhotoSortCreatedAt: "created_at",
+	models.PhotoSortTakenAt:   common.CapturedAtExpr,
+}
+
+// ListPhotos filters, sorts and (if q asks for a page) pages a photo query, selecting
+// columns into photos. The total is the number of photos matching the filters across
+// all pages. q must be validated.
+func ListPhotos(query *gorm.DB, columns string, q models.PhotoListQuery, photos *[]models.Photo) (int64, error) {
+	query = filterPhotos(query, q).Session(&gorm.Session{})
+
+	var total int64
+	if q.Paged() {
+		if err := query.Model(&models.Photo{}).Count(&total).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	listing := query.Select(columns)
+	sort := q.Sort
+	if q.GroupBy == models.PhotoGroupDay {
+		// Days are listed in capture order
+		sort = models.PhotoSortTakenAt
+	}
+	if column, ok := photoSortColumns[sort]; ok {
+		if q.Order == "desc" {
+			column += " DESC"
+		}
+		listing = listing.Order(column)
+	}
+	// Upload order, also breaking ties so pages don't overlap
+	if q.Order == "desc" {
+		listing = listing.Order("id DESC")
+	} else {
+		listing = listing.Order("id")
+	}
+	if q.Paged() {
+		limit, offset := q.Limit()
+		listing = listing.Limit(limit).Offset(offset)
+	}
+	if err := listing.Find(photos).Error; err != nil {
+		return 0, err
+	}
+	if !q.Paged() {
+		total = int64(len(*photos))
+	}
+	return total, nil
+}
+
+// PhotoDay is a run of photos[Start:End] captured on Date (YYYY-MM-DD)
+type PhotoDay struct {
+	Date  string
+	Start int
+	End   int
+}
+
+// GroupPhotosByDay splits photos listed in capture order into days. The capture time
+// falls back to the upload time, as in the taken_at sort.
+func GroupPhotosByDay(photos []models.Photo) []PhotoDay {
+	var days []PhotoDay
+	for i := range photos {
+		captured := photos[i].CreatedAt
+		if photos[i].TakenAt != nil {
+			captured = *photos[i].TakenAt
+		}
+		date := captured.Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, PhotoDay{Date: date, Start: i})
+		}
+		days[len(days)-1].End = i + 1
+	}
+	return days
+}
+
+// filterPhotos restricts a photo query to the name, kind, capture days and album of q
+func filterPhotos(query *gorm.DB, q models.PhotoListQuery) *gorm.DB {
+	if q.Name != "" {
+		query = query.Where("LOWER(base_name) LIKE ? ESCAPE '!'", containsPattern(q.Name))
+	}
+	switch q.Kind {
+	case models.PhotoKindRawOnly:
+		query = query.Where("has_raw = ? AND normal_ext = ''", true)
+	case models.PhotoKindWithRaw:
+		query = query.Where("has_raw = ?", true)
+	case models.PhotoKindWithoutRaw:
+		query = query.Where("has_raw = ?", false)
+	}
+	if q.TakenFrom != nil {
+		query = query.Where(common.CapturedAtExpr+" >= ?", *q.TakenFrom)
+	}
+	if q.TakenTo != nil {
+		query = query.Where(common.CapturedAtExpr+" < ?", q.TakenTo.AddDate(0, 0, 1))
+	}
+	if q.AlbumID != nil {
+		if *q.AlbumID == 0 {
+			query = query.Where("album_id IS NULL")
+		} else {
+			query = query.Where("album_id = ?", *q.AlbumID)
+		}
+	}
+	return query
+}
+
+// containsPattern returns a case-insensitive LIKE pattern (for LOWER(column), with
+// ESCAPE '!') matching values that contain s. "!" escapes the wildcards: a backslash
+// means different things in MySQL and PostgreSQL literals.
+func containsPattern(s string) string {
+	return "%" + strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(strings.ToLower(s)) + "%"
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupPhotoListTest(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.Photo{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	day := func(d int) *time.Time {
+		taken := time.Date(2026, 5, d, 12, 0, 0, 0, time.UTC)
+		return &taken
+	}
+	album := uint(1)
+	photos := []models.Photo{
+		{ProjectID: 1, BaseName: "IMG_0003", NormalExt: ".jpg", TakenAt: day(3), AlbumID: &album},
+		{ProjectID: 1, BaseName: "IMG_0001", NormalExt: ".jpg", RawExt: ".ARW", HasRaw: true, TakenAt: day(1)},
+		{ProjectID: 1, BaseName: "DSC_0002", RawExt: ".NEF", HasRaw: true, TakenAt: day(2), AlbumID: &album},
+		{ProjectID: 1, BaseName: "img_100%", NormalExt: ".jpg"},
+		{ProjectID: 2, BaseName: "IMG_0001", NormalExt: ".jpg"},
+	}
+	for i := range photos {
+		photos[i].CreatedAt = time.Date(2026, 6, 1, i, 0, 0, 0, time.UTC)
+		if err := database.DB.Create(&photos[i]).Error; err != nil {
+			t.Fatalf("Failed to create photo: %v", err)
+		}
+	}
+}
+
+func listPhotoNames(t *testing.T, q models.PhotoListQuery) ([]string, int64) {
+	t.Helper()
+	if err := q.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	var photos []models.Photo
+	total, err := ListPhotos(database.DB.Where("project_id = ?", 1), "id, base_name", q, &photos)
+	if err != nil {
+		t.Fatalf("ListPhotos() = %v", err)
+	}
+	names := make([]string, len(photos))
+	for i, photo := range photos {
+		names[i] = photo.BaseName
+	}
+	return names, total
+}
+
+func TestListPhotos(t *testing.T) {
+	setupPhotoListTest(t)
+	may := func(d int) *time.Time {
+		date := time.Date(2026, 5, d, 0, 0, 0, 0, time.UTC)
+		return &date
+	}
+
+	inAlbum, noAlbum := uint(1), uint(0)
+
+	tests := []struct {
+		name  string
+		query models.PhotoListQuery
+		want  []string
+		total int64
+	}{
+		{"all in upload order", models.PhotoListQuery{}, []string{"IMG_0003", "IMG_0001", "DSC_0002", "img_100%"}, 4},
+		{"by name", models.PhotoListQuery{Sort: "name"}, []string{"DSC_0002", "IMG_0001", "IMG_0003", "img_100%"}, 4},
+		{"second page", models.PhotoListQuery{Sort: "name", Page: 2, PerPage: 3}, []string{"img_100%"}, 4},
+		{"newest upload first", models.PhotoListQuery{Sort: "created_at", Order: "desc", PerPage: 2}, []string{"img_100%", "DSC_0002"}, 4},
+		// Without a capture time the upload time (June) counts
+		{"by capture time", models.PhotoListQuery{Sort: "taken_at"}, []string{"IMG_0001", "DSC_0002", "IMG_0003", "img_100%"}, 4},
+		{"capture days", models.PhotoListQuery{TakenFrom: may(2), TakenTo: may(3)}, []string{"IMG_0003", "DSC_0002"}, 2},
+		{"name is case-insensitive", models.PhotoListQuery{Name: "img_"}, []string{"IMG_0003", "IMG_0001", "img_100%"}, 3},
+		{"wildcards are literal", models.PhotoListQuery{Name: "0%"}, []string{"img_100%"}, 1},
+		{"raw only", models.PhotoListQuery{Kind: "raw_only"}, []string{"DSC_0002"}, 1},
+		{"without raw", models.PhotoListQuery{Kind: "without_raw", Page: 1}, []string{"IMG_0003", "img_100%"}, 2},
+		{"page past the end", models.PhotoListQuery{Page: 5}, []string{}, 4},
+		{"grouped by day", models.PhotoListQuery{GroupBy: "day", Order: "desc"}, []string{"img_100%", "IMG_0003", "DSC_0002", "IMG_0001"}, 4},
+		{"album", models.PhotoListQuery{AlbumID: &inAlbum}, []string{"IMG_0003", "DSC_0002"}, 2},
+		{"in no album", models.PhotoListQuery{AlbumID: &noAlbum}, []string{"IMG_0001", "img_100%"}, 2},
+	}
+	for _, tt := range tests {
+		names, total := listPhotoNames(t, tt.query)
+		if len(names) != len(tt.want) || total != tt.total {
+			t.Errorf("%s: got %v (total %d), want %v (total %d)", tt.name, names, total, tt.want, tt.total)
+			continue
+		}
+		for i := range names {
+			if names[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, names, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestGroupPhotosByDay(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2026, 5, day, hour, 0, 0, 0, time.UTC) }
+	taken := func(day, hour int) *time.Time {
+		t := at(day, hour)
+		return &t
+	}
+	photos := []models.Photo{
+		{BaseName: "a", TakenAt: taken(1, 9)},
+		{BaseName: "b", TakenAt: taken(1, 23)},
+		{BaseName: "c", TakenAt: taken(2, 0)},
+		{BaseName: "d", CreatedAt: at(2, 10)}, // No capture time: the upload day counts
+		{BaseName: "e", TakenAt: taken(4, 8)},
+	}
+	want := []PhotoDay{{"2026-05-01", 0, 2}, {"2026-05-02", 2, 4}, {"2026-05-04", 4, 5}}
+	days := GroupPhotosByDay(photos)
+	if len(days) != len(want) {
+		t.Fatalf("GroupPhotosByDay() = %+v, want %+v", days, want)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("Day %d = %+v, want %+v", i, days[i], want[i])
+		}
+	}
+	if days := GroupPhotosByDay(nil); len(days) != 0 {
+		t.Errorf("Expected no days without photos, got %+v", days)
+	}
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Metadata import: tags, ratings and captions curated elsewhere (a Lightroom export,
+// a spreadsheet) are applied to the photos of a project by file name.
+
+// metadataColumns maps accepted CSV headers to the field they fill
+var metadataColumns = map[string]string{
+	"base_name": "name", "file_name": "name", "filename": "name", "file": "name", "name": "name",
+	"tags": "tags", "keywords": "tags",
+	"rating": "rating", "stars": "rating",
+	"caption": "caption", "description": "caption",
+}
+
+// metadataBatchSize bounds the base names looked up per query
+const metadataBatchSize = 500
+
+// PhotoMetadataResult reports what a metadata import changed
+type PhotoMetadataResult struct {
+	Updated     int      `json:"updated"`
+	NotFound    []string `json:"not_found"`    // base names without a photo in the project
+	TagsCreated int      `json:"tags_created"` // tags that didn't exist before
+}
+
+// ParsePhotoMetadataCSV reads a metadata CSV. The header row names the columns:
+// base_name (or file_name, with or without extension), and any of tags (separated by
+// commas or semicolons), rating (0-5) and caption. Semicolon-separated files as
+// written by some spreadsheet locales are detected from the header.
+func ParsePhotoMetadataCSV(r io.Reader) ([]models.PhotoMetadataRow, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3) // Excel writes a BOM
+	}
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if header, err := br.Peek(br.Buffered()); err == nil {
+		first, _, _ := bytes.Cut(header, []byte("\n"))
+		if bytes.Count(first, []byte(";")) > bytes.Count(first, []byte(",")) {
+			reader.Comma = ';'
+		}
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the CSV is empty")
+	} else if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		field, ok := metadataColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			continue
+		}
+		if _, dup := columns[field]; dup {
+			return nil, fmt.Errorf("more than one %s column", field)
+		}
+		columns[field] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("the CSV needs a base_name or file_name column")
+	}
+	if len(columns) == 1 {
+		return nil, fmt.Errorf("the CSV needs a tags, rating or caption column")
+	}
+
+	var rows []models.PhotoMetadataRow
+	seen := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		cell := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue // blank line
+		}
+
+		row := models.PhotoMetadataRow{Line: line, BaseName: metadataBaseName(cell("name"))}
+		if tags := cell("tags"); tags != "" {
+			row.Tags = splitTags(tags)
+		}
+		if rating := cell("rating"); rating != "" {
+			value, err := strconv.Atoi(rating)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: rating %q is not a number", line, rating)
+			}
+			row.Rating = &value
+		}
+		if caption := cell("caption"); caption != "" {
+			row.Caption = &caption
+		}
+		if err := row.Validate(); err != nil {
+			return nil, err
+		}
+		if first, ok := seen[row.BaseName]; ok {
+			return nil, fmt.Errorf("line %d: %s is already on line %d", line, row.BaseName, first)
+		}
+		seen[row.BaseName] = line
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("the CSV has no rows")
+	}
+	return rows, nil
+}
+
+// metadataBaseName strips a photo extension from a file name cell
+func metadataBaseName(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if models.IsImageExtension(ext) || models.IsRawExtension(ext) {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// splitTags splits a tags cell, dropping empty and repeated (case-insensitive) tags
+func splitTags(cell string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, part := range strings.FieldsFunc(cell, func(r rune) bool { return r == ',' || r == ';' }) {
+		tag := models.NormalizeTag(part)
+		if key := strings.ToLower(tag); tag != "" && !seen[key] {
+			seen[key] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ImportPhotoMetadata applies metadata rows to the photos of a project in one
+// transaction. Tags are added to a photo's tags, or replace them with replaceTags.
+// Rows naming no photo of the project are reported, not failed.
+func ImportPhotoMetadata(projectID uint, rows []models.PhotoMetadataRow, replaceTags bool) (*PhotoMetadataResult, error) {
+	result := &PhotoMetadataResult{NotFound: []string{}}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		photos := make(map[string]uint, len(rows))
+		for start := 0; start < len(rows); start += metadataBatchSize {
+			batch := rows[start:min(start+metadataBatchSize, len(rows))]
+			names := make([]string, len(batch))
+			for i, row := range batch {
+				names[i] = row.BaseName
+			}
+			var found []models.Photo
+			if err := tx.Select("id, base_name").Where("project_id = ? AND base_name IN ?", projectID, names).Find(&found).Error; err != nil {
+				return err
+			}
+			for _, photo := range found {
+				photos[photo.BaseName] = photo.ID
+			}
+		}
+
+		var matched []models.PhotoMetadataRow
+		for _, row := range rows {
+			if _, ok := photos[row.BaseName]; ok {
+				matched = append(matched, row)
+			} else {
+				result.NotFound = append(result.NotFound, row.BaseName)
+			}
+		}
+		var names []string
+		for _, row := range matched {
+			names = append(names, row.Tags...)
+		}
+		tags, created, err := ensureTags(tx, names)
+		if err != nil {
+			return err
+		}
+		result.TagsCreated = created
+
+		for _, row := range matched {
+			id := photos[row.BaseName]
+			updates := map[string]interface{}{}
+			if row.Rating != nil {
+				updates["rating"] = *row.Rating
+			}
+			if row.Caption != nil {
+				updates["caption"] = *row.Caption
+			}
+			// UpdateColumns keeps updated_at, which is part of the originals' ETags
+			if len(updates) > 0 {
+				if err := tx.Model(&models.Photo{}).Where("id = ?", id).UpdateColumns(updates).Error; err != nil {
+					return err
+				}
+			}
+			if row.Tags != nil {
+				tagIDs := make([]uint, len(row.Tags))
+				for i, name := range row.Tags {
+					tagIDs[i] = tags[strings.ToLower(name)].ID
+				}
+				if err := setPhotoTags(tx, id, tagIDs, replaceTags); err != nil {
+					return err
+				}
+			}
+			result.Updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// setPhotoTags adds tags to a photo, or replaces its tags. The join table is written
+// directly: gorm's association mode would touch the photo's updated_at.
+func setPhotoTags(tx *gorm.DB, photoID uint, tagIDs []uint, replace bool) error {
+	if replace {
+		if err := tx.Table("photo_tags").Where("photo_id = ? AND tag_id NOT IN ?", photoID, tagIDs).Delete(nil).Error; err != nil {
+			return err
+		}
+	}
+	links := make([]map[string]interface{}, len(tagIDs))
+	for i, tagID := range tagIDs {
+		links[i] = map[string]interface{}{"photo_id": photoID, "tag_id": tagID}
+	}
+	return tx.Table("photo_tags").Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// ensureTags loads the tags of the given (normalized) names, creating missing ones.
+// The map is keyed by lowercase name.
+func ensureTags(tx *gorm.DB, names []string) (map[string]models.Tag, int, error) {
+	wanted := make(map[string]string)
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if _, ok := wanted[key]; !ok {
+			wanted[key] = name
+		}
+	}
+	tags := make(map[string]models.Tag, len(wanted))
+	if len(wanted) == 0 {
+		return tags, 0, nil
+	}
+	keys := make([]string, 0, len(wanted))
+	for key := range wanted {
+		keys = append(keys, key)
+	}
+	for start := 0; start < len(keys); start += metadataBatchSize {
+		var existing []models.Tag
+		if err := tx.Where("LOWER(name) IN ?", keys[start:min(start+metadataBatchSize, len(keys))]).Find(&existing).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, tag := range existing {
+			tags[strings.ToLower(tag.Name)] = tag
+		}
+	}
+
+	var missing []models.Tag
+	for key, name := range wanted {
+		if _, ok := tags[key]; !ok {
+			missing = append(missing, models.Tag{Name: name})
+		}
+	}
+	if len(missing) > 0 {
+		if err := tx.CreateInBatches(&missing, metadataBatchSize).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, tag := range missing {
+			tags[strings.ToLower(tag.Name)] = tag
+		}
+	}
+	return tags, len(missing), nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestParsePhotoMetadataCSV(t *testing.T) {
+	rows, err := ParsePhotoMetadataCSV(strings.NewReader("\xef\xbb\xbfFile_Name,Keywords,Rating,Caption\n" +
+		"IMG_0001.jpg,\"bride, Ceremony; bride\",5,First kiss\n" +
+		"\n" +
+		"IMG_0002.ARW,,,\n" +
+		"notes.txt,detail,,\n"))
+	if err != nil {
+		t.Fatalf("ParsePhotoMetadataCSV() = %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %+v", rows)
+	}
+	first := rows[0]
+	if first.BaseName != "IMG_0001" || len(first.Tags) != 2 || first.Tags[1] != "Ceremony" ||
+		first.Rating == nil || *first.Rating != 5 || first.Caption == nil || *first.Caption != "First kiss" {
+		t.Errorf("Unexpected first row %+v", first)
+	}
+	if rows[1].BaseName != "IMG_0002" || rows[1].Tags != nil || rows[1].Rating != nil || rows[1].Caption != nil || rows[1].Line != 4 {
+		t.Errorf("Expected empty cells to leave values unchanged, got %+v", rows[1])
+	}
+	if rows[2].BaseName != "notes.txt" {
+		t.Errorf("Expected only photo extensions stripped, got %q", rows[2].BaseName)
+	}
+
+	semicolon, err := ParsePhotoMetadataCSV(strings.NewReader("base_name;tags;rating\nIMG_0001;a,b;3\n"))
+	if err != nil || len(semicolon) != 1 || len(semicolon[0].Tags) != 2 || *semicolon[0].Rating != 3 {
+		t.Errorf("Expected a semicolon-separated file to parse, got %+v (%v)", semicolon, err)
+	}
+
+	for name, input := range map[string]string{
+		"empty":           "",
+		"no name column":  "tags,rating\na,1\n",
+		"no data columns": "base_name,notes\nIMG_0001,x\n",
+		"bad rating":      "base_name,rating\nIMG_0001,five\n",
+		"rating too high": "base_name,rating\nIMG_0001,6\n",
+		"duplicate name":  "base_name,rating\nIMG_0001,1\nIMG_0001.jpg,2\n",
+		"no rows":         "base_name,rating\n",
+	} {
+		if _, err := ParsePhotoMetadataCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestImportPhotoMetadata(t *testing.T) {
+	project := setupProjectTest(t)
+	if err := database.DB.AutoMigrate(&models.Tag{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	database.DB.Create(&models.Tag{Name: "Bride"})
+	var photo models.Photo
+	database.DB.Where("base_name = ?", "IMG_0001").First(&photo)
+
+	rows, err := ParsePhotoMetadataCSV(strings.NewReader("base_name,tags,rating,caption\nIMG_0001,bride;ceremony,4,Vows\nIMG_0404,lost;bride,1,\n"))
+	if err != nil {
+		t.Fatalf("ParsePhotoMetadataCSV() = %v", err)
+	}
+	result, err := ImportPhotoMetadata(project.ID, rows, false)
+	if err != nil {
+		t.Fatalf("ImportPhotoMetadata() = %v", err)
+	}
+	if result.Updated != 1 || len(result.NotFound) != 1 || result.NotFound[0] != "IMG_0404" || result.TagsCreated != 1 {
+		t.Fatalf("Expected one update, IMG_0404 not found and one new tag, got %+v", result)
+	}
+
+	var stored models.Photo
+	database.DB.Preload("Tags").First(&stored, photo.ID)
+	if stored.Rating != 4 || stored.Caption != "Vows" || len(stored.Tags) != 2 {
+		t.Fatalf("Expected rating, caption and two tags, got %+v", stored)
+	}
+	if !stored.UpdatedAt.Equal(photo.UpdatedAt) {
+		t.Error("Expected updated_at to stay, it is part of the originals' ETags")
+	}
+	names := map[string]bool{stored.Tags[0].Name: true, stored.Tags[1].Name: true}
+	if !names["Bride"] || !names["ceremony"] {
+		t.Errorf("Expected the existing spelling of Bride to be reused, got %v", names)
+	}
+
+	rows, _ = ParsePhotoMetadataCSV(strings.NewReader("base_name,tags\nIMG_0001,portrait\n"))
+	if _, err := ImportPhotoMetadata(project.ID, rows, true); err != nil {
+		t.Fatalf("ImportPhotoMetadata() = %v", err)
+	}
+	stored = models.Photo{}
+	database.DB.Preload("Tags").First(&stored, photo.ID)
+	if len(stored.Tags) != 1 || stored.Tags[0].Name != "portrait" || stored.Rating != 4 {
+		t.Errorf("Expected the tags replaced and the rating kept, got %+v", stored)
+	}
+}
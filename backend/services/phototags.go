@@ -0,0 +1,112 @@
+package services
+
+import (
+	"strings"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+// TagCount is a tag with the number of photos it is on
+type TagCount struct {
+	models.Tag
+	PhotoCount int64 `json:"photo_count"`
+}
+
+// ListTags returns the tags in use, by name, with how many photos have them
+func ListTags() ([]TagCount, error) {
+	tags := []TagCount{}
+	err := database.DB.Model(&models.Tag{}).
+		Select("tags.id, tags.name, COUNT(*) AS photo_count").
+		Joins("JOIN photo_tags ON photo_tags.tag_id = tags.id").
+		Joins("JOIN photos ON photos.id = photo_tags.photo_id AND photos.deleted_at IS NULL").
+		Group("tags.id, tags.name").
+		Order("tags.name").
+		Scan(&tags).Error
+	return tags, err
+}
+
+// TagPhotos adds tags (normalized names) to photos, creating tags that don't exist
+// yet. Done lists the photos that exist, NotFound the others.
+func TagPhotos(ids []uint, names []string) (BulkPhotoResult, int, error) {
+	result, err := existingPhotos(ids)
+	if err != nil || len(result.Done) == 0 {
+		return result, 0, err
+	}
+	created := 0
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		tags, n, err := ensureTags(tx, names)
+		if err != nil {
+			return err
+		}
+		created = n
+		tagIDs := make([]uint, 0, len(tags))
+		for _, tag := range tags {
+			tagIDs = append(tagIDs, tag.ID)
+		}
+		for _, id := range result.Done {
+			if err := setPhotoTags(tx, id, tagIDs, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return BulkPhotoResult{}, 0, err
+	}
+	return result, created, nil
+}
+
+// UntagPhotos removes tags (by name, case-insensitive) from photos. The tags are kept
+// for other photos.
+func UntagPhotos(ids []uint, names []string) (BulkPhotoResult, error) {
+	result, err := existingPhotos(ids)
+	if err != nil || len(result.Done) == 0 {
+		return result, err
+	}
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = strings.ToLower(name)
+	}
+	tagIDs := database.DB.Model(&models.Tag{}).Select("id").Where("LOWER(name) IN ?", keys)
+	err = database.DB.Table("photo_tags").Where("photo_id IN ? AND tag_id IN (?)", result.Done, tagIDs).Delete(nil).Error
+	if err != nil {
+		return BulkPhotoResult{}, err
+	}
+	return result, nil
+}
+
+// existingPhotos splits photo IDs into existing photos (Done) and unknown ones
+func existingPhotos(ids []uint) (BulkPhotoResult, error) {
+	result := BulkPhotoResult{Done: []uint{}}
+	if err := database.DB.Model(&models.Photo{}).Where("id IN ?", ids).Order("id").Pluck("id", &result.Done).Error; err != nil {
+		return BulkPhotoResult{}, err
+	}
+	result.NotFound = missingIDs(ids, result.Done)
+	return result, nil
+}
+
+// SearchPhotos lists the photos, across projects or in q.ProjectID, whose base name,
+// tags, camera or lens contain every word of q (case-insensitive). Paging, sorting and
+// the other filters work as in ListPhotos on query (e.g. with preloads); q must be
+// validated.
+func SearchPhotos(query *gorm.DB, columns string, q models.PhotoSearchQuery, photos *[]models.Photo) (int64, error) {
+	if q.ProjectID != 0 {
+		query = query.Where("project_id = ?", q.ProjectID)
+	}
+	for _, term := range q.Terms() {
+		pattern := containsPattern(term)
+		tagged := database.DB.Table("photo_tags").Select("photo_tags.photo_id").
+			Joins("JOIN tags ON tags.id = photo_tags.tag_id").
+			Where("LOWER(tags.name) LIKE ? ESCAPE '!'", pattern)
+		query = query.Where(database.DB.
+			Where("LOWER(base_name) LIKE ? ESCAPE '!'", pattern).
+			Or("LOWER(exif_camera_make) LIKE ? ESCAPE '!'", pattern).
+			Or("LOWER(exif_camera_model) LIKE ? ESCAPE '!'", pattern).
+			Or("LOWER(exif_lens_model) LIKE ? ESCAPE '!'", pattern).
+			Or("id IN (?)", tagged))
+	}
+	return ListPhotos(query, columns, q.PhotoListQuery, photos)
+}
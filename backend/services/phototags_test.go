@@ -0,0 +1,127 @@
+package services
+
+import (
+	"testing"
+
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func setupTagTest(t *testing.T) (*models.Project, []models.Photo) {
+	t.Helper()
+	project := setupProjectTest(t)
+	if err := database.DB.AutoMigrate(&models.Tag{}); err != nil {
+		t.Fatalf("Failed to migrate tags: %v", err)
+	}
+	other := models.Project{Name: "portraits"}
+	database.DB.Create(&other)
+	photos := []models.Photo{
+		{ProjectID: project.ID, BaseName: "DSC_0100", NormalExt: ".jpg", Exif: models.PhotoExif{CameraMake: "Canon", CameraModel: "EOS R5", LensModel: "RF85mm F1.2 L USM"}},
+		{ProjectID: project.ID, BaseName: "DSC_0101", NormalExt: ".jpg", Exif: models.PhotoExif{CameraMake: "SONY", CameraModel: "ILCE-7M4"}},
+		{ProjectID: other.ID, BaseName: "headshot_100%", NormalExt: ".jpg"},
+	}
+	database.DB.Create(&photos)
+	return project, photos
+}
+
+func searchNames(t *testing.T, q models.PhotoSearchQuery) []string {
+	t.Helper()
+	if err := q.Validate(); err != nil {
+		t.Fatalf("Validate(%q) = %v", q.Q, err)
+	}
+	var photos []models.Photo
+	total, err := SearchPhotos(database.DB, "id, base_name", q, &photos)
+	if err != nil {
+		t.Fatalf("SearchPhotos(%q) = %v", q.Q, err)
+	}
+	names := make([]string, len(photos))
+	for i, photo := range photos {
+		names[i] = photo.BaseName
+	}
+	if total != int64(len(names)) && q.PerPage == 0 {
+		t.Errorf("SearchPhotos(%q): total %d for %v", q.Q, total, names)
+	}
+	return names
+}
+
+func TestTagAndUntagPhotos(t *testing.T) {
+	_, photos := setupTagTest(t)
+	database.DB.Create(&models.Tag{Name: "Bride"})
+
+	result, created, err := TagPhotos([]uint{photos[0].ID, photos[1].ID, 999}, []string{"bride", "First dance"})
+	if err != nil {
+		t.Fatalf("TagPhotos() = %v", err)
+	}
+	if len(result.Done) != 2 || len(result.NotFound) != 1 || result.NotFound[0] != 999 || created != 1 {
+		t.Errorf("Unexpected result %+v, %d tags created", result, created)
+	}
+	// Tagging again is a no-op
+	if _, created, err := TagPhotos([]uint{photos[0].ID}, []string{"Bride"}); err != nil || created != 0 {
+		t.Errorf("TagPhotos() again = %d, %v", created, err)
+	}
+	var stored models.Photo
+	database.DB.Preload("Tags").First(&stored, photos[0].ID)
+	if len(stored.Tags) != 2 {
+		t.Fatalf("Expected two tags, got %+v", stored.Tags)
+	}
+	if !stored.UpdatedAt.Equal(photos[0].UpdatedAt) {
+		t.Error("Expected updated_at to stay, it is part of the originals' ETags")
+	}
+
+	tags, err := ListTags()
+	if err != nil || len(tags) != 2 || tags[0].Name != "Bride" || tags[0].PhotoCount != 2 {
+		t.Errorf("ListTags() = %+v, %v", tags, err)
+	}
+
+	result, err = UntagPhotos([]uint{photos[0].ID}, []string{"BRIDE", "unknown"})
+	if err != nil || len(result.Done) != 1 {
+		t.Fatalf("UntagPhotos() = %+v, %v", result, err)
+	}
+	stored = models.Photo{}
+	database.DB.Preload("Tags").First(&stored, photos[0].ID)
+	if len(stored.Tags) != 1 || stored.Tags[0].Name != "First dance" {
+		t.Errorf("Expected only First dance to be left, got %+v", stored.Tags)
+	}
+	if tags, _ := ListTags(); len(tags) != 2 || tags[0].PhotoCount != 1 {
+		t.Errorf("Expected Bride to stay on the other photo, got %+v", tags)
+	}
+}
+
+func TestSearchPhotos(t *testing.T) {
+	project, photos := setupTagTest(t)
+	TagPhotos([]uint{photos[1].ID}, []string{"First dance"})
+
+	tests := []struct {
+		q    models.PhotoSearchQuery
+		want []string
+	}{
+		{models.PhotoSearchQuery{Q: "dsc"}, []string{"DSC_0100", "DSC_0101"}},
+		{models.PhotoSearchQuery{Q: "canon"}, []string{"DSC_0100"}},
+		{models.PhotoSearchQuery{Q: "85mm"}, []string{"DSC_0100"}},
+		{models.PhotoSearchQuery{Q: "Sony dance"}, []string{"DSC_0101"}},
+		{models.PhotoSearchQuery{Q: "canon dance"}, []string{}},
+		{models.PhotoSearchQuery{Q: "100"}, []string{"DSC_0100", "headshot_100%"}},
+		{models.PhotoSearchQuery{Q: "0%"}, []string{"headshot_100%"}},
+		{models.PhotoSearchQuery{Q: "c_0"}, []string{"DSC_0100", "DSC_0101"}},
+		{models.PhotoSearchQuery{Q: "100", ProjectID: project.ID}, []string{"DSC_0100"}},
+		{models.PhotoSearchQuery{Q: "dsc", PhotoListQuery: models.PhotoListQuery{Sort: models.PhotoSortName, Order: "desc"}}, []string{"DSC_0101", "DSC_0100"}},
+	}
+	for _, tt := range tests {
+		names := searchNames(t, tt.q)
+		if len(names) != len(tt.want) {
+			t.Errorf("Search %q: got %v, want %v", tt.q.Q, names, tt.want)
+			continue
+		}
+		for i := range names {
+			if names[i] != tt.want[i] {
+				t.Errorf("Search %q: got %v, want %v", tt.q.Q, names, tt.want)
+				break
+			}
+		}
+	}
+
+	paged := searchNames(t, models.PhotoSearchQuery{Q: "dsc", PhotoListQuery: models.PhotoListQuery{PerPage: 1, Page: 2}})
+	if len(paged) != 1 || paged[0] != "DSC_0101" {
+		t.Errorf("Expected the second match on page 2, got %v", paged)
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"photobridge/config"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+// defaultCachedPlaceholders bounds the in-memory placeholder cache (small ones are ~10 KB)
+// unless PLACEHOLDER_CACHE_SIZE is set
+const defaultCachedPlaceholders = 512
+
+var (
+	placeholderMu    sync.Mutex
+	placeholderCache = map[string][]byte{}
+)
+
+// IsRawOnly reports whether a photo has a RAW file but no normal image to thumbnail
+func IsRawOnly(photo *models.Photo) bool {
+	return photo.NormalExt == "" && photo.HasRaw
+}
+
+// RawPlaceholderThumb returns the placeholder thumbnail of a RAW-only photo.
+// size is "small" or "large". Rendered placeholders depend only on the file name,
+// so they are cached in memory; the cache is simply dropped when it fills up.
+func RawPlaceholderThumb(photo *models.Photo, size string) ([]byte, error) {
+	params := utils.CurrentThumbParams()
+	width := params.SmallWidth
+	if size == "large" {
+		width = params.LargeWidth
+	}
+	key := fmt.Sprintf("%d|%s|%s", width, photo.BaseName, photo.RawExt)
+
+	placeholderMu.Lock()
+	data, ok := placeholderCache[key]
+	placeholderMu.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	fontPath, maxCached := "", defaultCachedPlaceholders
+	if config.AppConfig != nil {
+		fontPath = config.AppConfig.ShareCardFont
+		if config.AppConfig.PlaceholderCache > 0 {
+			maxCached = config.AppConfig.PlaceholderCache
+		}
+	}
+	data, err := utils.ComposeRawPlaceholder(photo.BaseName, photo.RawExt, width, fontPath)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholderMu.Lock()
+	if len(placeholderCache) >= maxCached {
+		placeholderCache = map[string][]byte{}
+	}
+	placeholderCache[key] = data
+	placeholderMu.Unlock()
+	return data, nil
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"photobridge/models"
+)
+
+func TestRawPlaceholderThumb(t *testing.T) {
+	photo := &models.Photo{ID: 7, BaseName: "DSC_0042", RawExt: ".nef", HasRaw: true}
+	if !IsRawOnly(photo) {
+		t.Fatal("Photo without a normal image should be RAW-only")
+	}
+
+	small, err := RawPlaceholderThumb(photo, "small")
+	if err != nil {
+		t.Fatalf("RawPlaceholderThumb failed: %v", err)
+	}
+	large, err := RawPlaceholderThumb(photo, "large")
+	if err != nil {
+		t.Fatalf("RawPlaceholderThumb failed: %v", err)
+	}
+	if len(large) <= len(small) {
+		t.Error("Large placeholder should be bigger than the small one")
+	}
+	again, _ := RawPlaceholderThumb(photo, "small")
+	if &again[0] != &small[0] {
+		t.Error("Placeholder should be served from cache")
+	}
+
+	if IsRawOnly(&models.Photo{NormalExt: ".jpg", HasRaw: true}) {
+		t.Error("Photo with a JPEG is not RAW-only")
+	}
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"photobridge/common"
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+
+	"gorm.io/gorm"
+)
+
+const projectShortname = "[Project]"
+
+var (
+	ErrInvalidProjectName = errors.New("invalid project name")
+	ErrProjectNameTaken   = errors.New("project name already in use")
+	ErrProjectDirExists   = errors.New("project directory already exists")
+	ErrInvalidCoverPhoto  = errors.New("cover photo is not a photo of this project")
+
+	// renameDir is swapped out in tests to simulate filesystem failures
+	renameDir = os.Rename
+)
+
+// UpdateProject applies field updates to a project. A "name" update renames the
+// upload directory as well: the new name is validated first, the directory is
+// renamed inside the database transaction, and if the commit fails the rename is
+// reverted (and verified). Share cards are re-rendered and queued thumbnail tasks
+// are pointed at the new directory afterwards.
+// A rename holds the project's exclusive lock, so it never runs during an upload.
+// With ifVersion set (not 0) the project must still be at that version, otherwise
+// common.ErrVersionConflict is returned and nothing changes.
+func UpdateProject(project *models.Project, updates map[string]interface{}, ifVersion uint) error {
+	if _, renaming := updates["name"]; renaming {
+		unlock := LockProject(project.ID)
+		defer unlock()
+		// Another rename may have finished while we waited
+		if err := database.DB.First(project, project.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	newName, renaming := updates["name"].(string)
+	if renaming {
+		sanitized, valid := utils.SanitizeProjectName(newName)
+		if !valid {
+			return ErrInvalidProjectName
+		}
+		newName = sanitized
+		updates["name"] = newName
+		renaming = newName != project.Name
+		if !renaming {
+			delete(updates, "name")
+		}
+	}
+	if ifVersion != 0 && project.Version != ifVersion {
+		return common.ErrVersionConflict
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	var oldDir, newDir string
+	if renaming {
+		// The unique index also covers soft-deleted projects
+		var count int64
+		database.DB.Unscoped().Model(&models.Project{}).Where("name = ? AND id <> ?", newName, project.ID).Count(&count)
+		if count > 0 {
+			return ErrProjectNameTaken
+		}
+
+		var err error
+		if oldDir, err = projectDir(project.Name); err != nil {
+			return err
+		}
+		if newDir, err = projectDir(newName); err != nil {
+			return err
+		}
+		if _, err := os.Stat(newDir); err == nil {
+			return ErrProjectDirExists
+		}
+	}
+
+	oldName := project.Name
+	dirRenamed := false
+	var copiedKeys, oldKeys []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockProjectRow(tx, project.ID); err != nil {
+			return err
+		}
+		if cover, ok := updates["cover_photo"].(string); ok && cover != "" {
+			if !coverPhotoExists(tx, project.ID, cover) {
+				return ErrInvalidCoverPhoto
+			}
+		}
+		query := tx.Model(project)
+		if ifVersion != 0 {
+			query = query.Where("version = ?", ifVersion)
+		}
+		updates["version"] = gorm.Expr("version + 1")
+		result := query.Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if ifVersion != 0 {
+				return common.ErrVersionConflict
+			}
+			return gorm.ErrRecordNotFound
+		}
+		if !renaming {
+			return nil
+		}
+		if !storage.IsLocal() {
+			var err error
+			if copiedKeys, oldKeys, err = copyProjectObjects(tx, project.ID, oldName, newName); err != nil {
+				return fmt.Errorf("failed to copy project files: %w", err)
+			}
+		}
+		// A project without uploads may have no directory yet
+		if _, err := os.Stat(oldDir); os.IsNotExist(err) {
+			return nil
+		}
+		if err := renameDir(oldDir, newDir); err != nil {
+			return fmt.Errorf("failed to rename project directory: %w", err)
+		}
+		dirRenamed = true
+		return nil
+	})
+
+	if err != nil {
+		deleteObjects(copiedKeys)
+		if dirRenamed {
+			// The commit failed after the directory moved: move it back
+			if rbErr := revertDirRename(newDir, oldDir); rbErr != nil {
+				log.Printf("%s Rollback of %s -> %s failed, directory left at %s: %v",
+					projectShortname, oldName, newName, newDir, rbErr)
+				return fmt.Errorf("%w (directory rollback failed: %v)", err, rbErr)
+			}
+		}
+		return err
+	}
+
+	if renaming {
+		deleteObjects(oldKeys)
+		log.Printf("%s Renamed project %d: %s -> %s", projectShortname, project.ID, oldName, newName)
+		Queue.RenameProject(oldName, newName)
+	}
+	EnqueueProjectShareCards(project.ID)
+	return nil
+}
+
+func projectDir(name string) (string, error) {
+	if !utils.ValidatePathComponent(name) {
+		return "", ErrInvalidProjectName
+	}
+	return utils.ValidateSecurePath(config.AppConfig.UploadDir, filepath.Join(config.AppConfig.UploadDir, name))
+}
+
+// copyProjectObjects copies the originals of a project to keys under its new name
+// (object storage has no directory to rename). If a copy fails the copies made so far
+// are removed. Returns the new keys and the old ones to delete after the commit.
+func copyProjectObjects(tx *gorm.DB, projectID uint, oldName, newName string) (copied, old []string, err error) {
+	var photos []models.Photo
+	if err := tx.Select("base_name, normal_ext, raw_ext").Where("project_id = ?", projectID).Find(&photos).Error; err != nil {
+		return nil, nil, err
+	}
+	ctx := context.Background()
+	backend := storage.Default()
+	for _, photo := range photos {
+		for _, ext := range []string{photo.NormalExt, photo.RawExt} {
+			if ext == "" {
+				continue
+			}
+			from, to := storage.Key(oldName, photo.BaseName+ext), storage.Key(newName, photo.BaseName+ext)
+			if err := backend.Copy(ctx, from, to); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue // Lost file: nothing to move
+				}
+				deleteObjects(copied)
+				return nil, nil, err
+			}
+			copied = append(copied, to)
+			old = append(old, from)
+		}
+	}
+	return copied, old, nil
+}
+
+// deleteObjects removes objects from storage, logging failures
+func deleteObjects(keys []string) {
+	backend := storage.Default()
+	for _, key := range keys {
+		if err := backend.Delete(context.Background(), key); err != nil {
+			log.Printf("%s Failed to delete %s: %v", projectShortname, key, err)
+		}
+	}
+}
+
+// revertDirRename moves a renamed directory back and verifies it arrived
+func revertDirRename(from, to string) error {
+	if err := renameDir(from, to); err != nil {
+		return err
+	}
+	if info, err := os.Stat(to); err != nil || !info.IsDir() {
+		return fmt.Errorf("directory missing at %s after rollback", to)
+	}
+	return nil
+}
+
+// CoverPhotoName is the cover_photo value for a photo (the file name of its normal image)
+func CoverPhotoName(photo *models.Photo) string {
+	return photo.BaseName + photo.NormalExt
+}
+
+// CoverPhotoID returns the ID of the photo whose normal image is the project cover, or 0
+func CoverPhotoID(project *models.Project) uint {
+	ext := filepath.Ext(project.CoverPhoto)
+	if ext == "" {
+		return 0
+	}
+	var ids []uint
+	database.DB.Model(&models.Photo{}).
+		Where("project_id = ? AND base_name = ? AND normal_ext = ?", project.ID, strings.TrimSuffix(project.CoverPhoto, ext), ext).
+		Limit(1).Pluck("id", &ids)
+	if len(ids) == 0 {
+		return 0
+	}
+	return ids[0]
+}
+
+// coverPhotoExists reports whether name is the normal image of a photo in the project
+func coverPhotoExists(tx *gorm.DB, projectID uint, name string) bool {
+	ext := filepath.Ext(name)
+	if ext == "" {
+		return false
+	}
+	var count int64
+	tx.Model(&models.Photo{}).
+		Where("project_id = ? AND base_name = ? AND normal_ext = ?", projectID, strings.TrimSuffix(name, ext), ext).
+		Count(&count)
+	return count > 0
+}
+
+// SetDefaultCoverPhoto makes name the project cover if the project has none yet.
+// The conditional update keeps a cover chosen concurrently by an admin.
+func SetDefaultCoverPhoto(projectID uint, name string) bool {
+	result := database.DB.Model(&models.Project{}).
+		Where("id = ? AND (cover_photo = '' OR cover_photo IS NULL)", projectID).
+		Update("cover_photo", name)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	EnqueueProjectShareCards(projectID)
+	return true
+}
+
+// ReplaceCoverPhoto moves the cover off a photo file that is going away (deleted, or
+// replaced by an image with another extension). replacement may be empty, in which case
+// the first remaining photo with a normal image becomes the cover, or none if there is
+// no such photo. It only applies while the cover still points at oldName.
+func ReplaceCoverPhoto(projectID uint, oldName, replacement string) {
+	if replacement == "" {
+		var next models.Photo
+		if database.DB.Select("id, base_name, normal_ext").
+			Where("project_id = ? AND normal_ext <> ''", projectID).
+			Order("id").First(&next).Error == nil {
+			replacement = CoverPhotoName(&next)
+		}
+	}
+
+	result := database.DB.Model(&models.Project{}).
+		Where("id = ? AND cover_photo = ?", projectID, oldName).
+		Update("cover_photo", replacement)
+	if result.Error != nil {
+		log.Printf("%s Failed to reassign cover of project %d: %v", projectShortname, projectID, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("%s Cover of project %d changed from %s to %q", projectShortname, projectID, oldName, replacement)
+		EnqueueProjectShareCards(projectID)
+	}
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"photobridge/common"
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupProjectTest creates an in-memory database and a temporary upload directory
+func setupProjectTest(t *testing.T) *models.Project {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.Project{}, &models.Photo{}, &models.ShareLink{}, &models.Album{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	config.AppConfig = &config.Config{UploadDir: t.TempDir(), ThumbDir: t.TempDir()}
+
+	project := &models.Project{Name: "wedding", CoverPhoto: "IMG_0001.jpg"}
+	if err := database.DB.Create(project).Error; err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "IMG_0001", NormalExt: ".jpg"})
+	dir := filepath.Join(config.AppConfig.UploadDir, project.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "IMG_0001.jpg"), []byte("jpeg"), 0644); err != nil {
+		t.Fatalf("Failed to write photo: %v", err)
+	}
+	return project
+}
+
+func assertProjectState(t *testing.T, id uint, name string) {
+	t.Helper()
+	var stored models.Project
+	database.DB.First(&stored, id)
+	if stored.Name != name {
+		t.Errorf("Expected stored name %q, got %q", name, stored.Name)
+	}
+	if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, name, "IMG_0001.jpg")); err != nil {
+		t.Errorf("Expected photo under %s: %v", name, err)
+	}
+}
+
+func TestUpdateProjectRename(t *testing.T) {
+	project := setupProjectTest(t)
+
+	err := UpdateProject(project, map[string]interface{}{"name": "  wedding 2024 ", "description": "final"}, 0)
+	if err != nil {
+		t.Fatalf("UpdateProject failed: %v", err)
+	}
+
+	assertProjectState(t, project.ID, "wedding 2024")
+	if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, "wedding")); !os.IsNotExist(err) {
+		t.Error("Old directory should be gone after rename")
+	}
+	var stored models.Project
+	database.DB.First(&stored, project.ID)
+	if stored.Description != "final" || stored.CoverPhoto != "IMG_0001.jpg" {
+		t.Errorf("Unexpected project after rename: %+v", stored)
+	}
+}
+
+func TestUpdateProjectRenameWithoutDirectory(t *testing.T) {
+	project := setupProjectTest(t)
+	os.RemoveAll(filepath.Join(config.AppConfig.UploadDir, project.Name))
+
+	if err := UpdateProject(project, map[string]interface{}{"name": "empty"}, 0); err != nil {
+		t.Fatalf("Renaming a project without uploads should succeed: %v", err)
+	}
+	var stored models.Project
+	database.DB.First(&stored, project.ID)
+	if stored.Name != "empty" {
+		t.Errorf("Expected name to be updated, got %q", stored.Name)
+	}
+}
+
+func TestUpdateProjectRenameRejected(t *testing.T) {
+	project := setupProjectTest(t)
+	other := &models.Project{Name: "taken"}
+	database.DB.Create(other)
+	database.DB.Delete(other)
+	os.MkdirAll(filepath.Join(config.AppConfig.UploadDir, "stray"), 0755)
+
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"../escape", ErrInvalidProjectName},
+		{"   ", ErrInvalidProjectName},
+		{"taken", ErrProjectNameTaken},
+		{"stray", ErrProjectDirExists},
+	}
+	for _, tt := range tests {
+		err := UpdateProject(project, map[string]interface{}{"name": tt.name}, 0)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Rename to %q: expected %v, got %v", tt.name, tt.want, err)
+		}
+		assertProjectState(t, project.ID, "wedding")
+	}
+}
+
+func TestUpdateProjectRenameDirFailureRollsBackDB(t *testing.T) {
+	project := setupProjectTest(t)
+
+	originalRename := renameDir
+	defer func() { renameDir = originalRename }()
+	renameDir = func(string, string) error { return errors.New("disk on fire") }
+
+	if err := UpdateProject(project, map[string]interface{}{"name": "moved", "description": "x"}, 0); err == nil {
+		t.Fatal("Expected rename failure to be reported")
+	}
+	assertProjectState(t, project.ID, "wedding")
+	var stored models.Project
+	database.DB.First(&stored, project.ID)
+	if stored.Description != "" {
+		t.Error("Description update should be rolled back with the rename")
+	}
+}
+
+func TestRevertDirRename(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "new")
+	to := filepath.Join(dir, "old")
+	os.MkdirAll(from, 0755)
+
+	if err := revertDirRename(from, to); err != nil {
+		t.Fatalf("revertDirRename failed: %v", err)
+	}
+	if info, err := os.Stat(to); err != nil || !info.IsDir() {
+		t.Error("Directory should be back at its original path")
+	}
+	if err := revertDirRename(from, to); err == nil {
+		t.Error("Reverting a missing directory should fail")
+	}
+}
+
+func projectCover(id uint) string {
+	var stored models.Project
+	database.DB.First(&stored, id)
+	return stored.CoverPhoto
+}
+
+func TestUpdateProjectCoverValidation(t *testing.T) {
+	project := setupProjectTest(t)
+	other := &models.Project{Name: "other"}
+	database.DB.Create(other)
+	database.DB.Create(&models.Photo{ProjectID: other.ID, BaseName: "FOREIGN", NormalExt: ".jpg"})
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "IMG_0002", NormalExt: ".png"})
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "IMG_0003", RawExt: ".arw", HasRaw: true})
+
+	for _, cover := range []string{"IMG_0002.jpg", "FOREIGN.jpg", "IMG_0003.arw", "IMG_0002", "../../etc/passwd"} {
+		err := UpdateProject(project, map[string]interface{}{"cover_photo": cover}, 0)
+		if !errors.Is(err, ErrInvalidCoverPhoto) {
+			t.Errorf("Cover %q: expected ErrInvalidCoverPhoto, got %v", cover, err)
+		}
+	}
+	if cover := projectCover(project.ID); cover != "IMG_0001.jpg" {
+		t.Errorf("Rejected covers should not be stored, got %q", cover)
+	}
+
+	if err := UpdateProject(project, map[string]interface{}{"cover_photo": "IMG_0002.png"}, 0); err != nil {
+		t.Fatalf("Valid cover rejected: %v", err)
+	}
+	if cover := projectCover(project.ID); cover != "IMG_0002.png" {
+		t.Errorf("Expected cover IMG_0002.png, got %q", cover)
+	}
+}
+
+func TestUpdateProjectVersion(t *testing.T) {
+	project := setupProjectTest(t)
+	if project.Version != 1 {
+		t.Fatalf("Expected a new project at version 1, got %d", project.Version)
+	}
+
+	if err := UpdateProject(project, map[string]interface{}{"description": "first tab"}, 1); err != nil {
+		t.Fatalf("Update at the current version failed: %v", err)
+	}
+	var stored models.Project
+	database.DB.First(&stored, project.ID)
+	if stored.Version != 2 {
+		t.Errorf("Expected version 2 after the update, got %d", stored.Version)
+	}
+
+	// The second tab still has version 1
+	stale := &models.Project{}
+	database.DB.First(stale, project.ID)
+	stale.Version = 1
+	err := UpdateProject(stale, map[string]interface{}{"description": "second tab"}, 1)
+	if !errors.Is(err, common.ErrVersionConflict) {
+		t.Fatalf("Expected ErrVersionConflict, got %v", err)
+	}
+	database.DB.First(&stored, project.ID)
+	if stored.Description != "first tab" || stored.Version != 2 {
+		t.Errorf("A conflicting update should change nothing, got %q at version %d", stored.Description, stored.Version)
+	}
+
+	// Without If-Match the update always applies
+	if err := UpdateProject(&stored, map[string]interface{}{"description": "script"}, 0); err != nil {
+		t.Fatalf("Unchecked update failed: %v", err)
+	}
+	database.DB.First(&stored, project.ID)
+	if stored.Version != 3 {
+		t.Errorf("Expected version 3, got %d", stored.Version)
+	}
+}
+
+func TestReplaceCoverPhoto(t *testing.T) {
+	project := setupProjectTest(t)
+	second := models.Photo{ProjectID: project.ID, BaseName: "IMG_0002", NormalExt: ".jpg"}
+	database.DB.Create(&second)
+	database.DB.Create(&models.Photo{ProjectID: project.ID, BaseName: "IMG_0003", RawExt: ".arw", HasRaw: true})
+
+	// Deleting the cover photo moves the cover to the next photo with an image
+	database.DB.Where("base_name = ?", "IMG_0001").Delete(&models.Photo{})
+	ReplaceCoverPhoto(project.ID, "IMG_0001.jpg", "")
+	if cover := projectCover(project.ID); cover != "IMG_0002.jpg" {
+		t.Errorf("Expected cover to move to IMG_0002.jpg, got %q", cover)
+	}
+
+	// A cover that no longer matches (changed concurrently) is left alone
+	ReplaceCoverPhoto(project.ID, "IMG_0001.jpg", "")
+	if cover := projectCover(project.ID); cover != "IMG_0002.jpg" {
+		t.Errorf("Unrelated cover should be kept, got %q", cover)
+	}
+
+	// Without any image left the cover is cleared
+	database.DB.Delete(&second)
+	ReplaceCoverPhoto(project.ID, "IMG_0002.jpg", "")
+	if cover := projectCover(project.ID); cover != "" {
+		t.Errorf("Expected cover to be cleared, got %q", cover)
+	}
+}
+
+func TestSetDefaultCoverPhoto(t *testing.T) {
+	project := setupProjectTest(t)
+
+	if SetDefaultCoverPhoto(project.ID, "IMG_0009.jpg") {
+		t.Error("Existing cover should not be replaced")
+	}
+	database.DB.Model(project).Update("cover_photo", "")
+	if !SetDefaultCoverPhoto(project.ID, "IMG_0009.jpg") {
+		t.Error("Empty cover should be set")
+	}
+	if cover := projectCover(project.ID); cover != "IMG_0009.jpg" {
+		t.Errorf("Expected cover IMG_0009.jpg, got %q", cover)
+	}
+}
+
+// objectStore stands in for a remote backend: same behavior as local storage, but
+// storage.IsLocal reports false, so files move to it with storage.Store
+type objectStore struct {
+	*storage.Local
+}
+
+func TestUpdateProjectRenameObjectStorage(t *testing.T) {
+	project := setupProjectTest(t)
+	bucket := t.TempDir()
+	storage.Set(objectStore{storage.NewLocal(bucket)})
+	t.Cleanup(func() { storage.Set(nil) })
+	if err := storage.Store(context.Background(), "wedding/IMG_0001.jpg",
+		filepath.Join(config.AppConfig.UploadDir, "wedding", "IMG_0001.jpg")); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	if err := UpdateProject(project, map[string]interface{}{"name": "wedding 2024"}, 0); err != nil {
+		t.Fatalf("UpdateProject failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(bucket, "wedding 2024", "IMG_0001.jpg")); err != nil {
+		t.Errorf("Expected object under the new name: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(bucket, "wedding", "IMG_0001.jpg")); !os.IsNotExist(err) {
+		t.Error("Old object should be deleted after rename")
+	}
+}
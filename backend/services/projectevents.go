@@ -0,0 +1,80 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of project events, in the order a file goes through them
+const (
+	EventUploadReceived  = "upload_received"  // The server has the whole file and starts processing it
+	EventHashComputed    = "hash_computed"    // SHA-256 of the file, used for deduplication
+	EventUploadDone      = "upload_done"      // Stored as a photo (created, updated or duplicate)
+	EventUploadFailed    = "upload_failed"    // Refused or failed; see error_code
+	EventThumbnailDone   = "thumbnail_done"   // Thumbnails of a photo written
+	EventThumbnailFailed = "thumbnail_failed" // Generation failed; the photo falls back to a placeholder or on-view generation
+)
+
+// projectEventBuffer is how many events a slow subscriber may fall behind before
+// further events are dropped for it
+const projectEventBuffer = 256
+
+// ProjectEvent reports the progress of an upload or of thumbnail generation in a project
+type ProjectEvent struct {
+	Type        string    `json:"type"`
+	File        string    `json:"file,omitempty"` // File name as uploaded
+	PhotoID     uint      `json:"photo_id,omitempty"`
+	Size        int64     `json:"size,omitempty"`
+	Hash        string    `json:"hash,omitempty"`
+	Status      string    `json:"status,omitempty"`       // Upload status of upload_done
+	ThumbStatus string    `json:"thumb_status,omitempty"` // Thumbnail state after upload_done
+	Sizes       []string  `json:"sizes,omitempty"`        // Thumbnail sizes of thumbnail_done
+	ErrorCode   string    `json:"error_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+var projectEvents = struct {
+	sync.Mutex
+	subscribers map[uint]map[chan ProjectEvent]struct{}
+}{subscribers: make(map[uint]map[chan ProjectEvent]struct{})}
+
+// SubscribeProjectEvents returns a channel receiving the events of a project from now
+// on, and a function that ends the subscription
+func SubscribeProjectEvents(projectID uint) (<-chan ProjectEvent, func()) {
+	ch := make(chan ProjectEvent, projectEventBuffer)
+	projectEvents.Lock()
+	if projectEvents.subscribers[projectID] == nil {
+		projectEvents.subscribers[projectID] = make(map[chan ProjectEvent]struct{})
+	}
+	projectEvents.subscribers[projectID][ch] = struct{}{}
+	projectEvents.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			projectEvents.Lock()
+			delete(projectEvents.subscribers[projectID], ch)
+			if len(projectEvents.subscribers[projectID]) == 0 {
+				delete(projectEvents.subscribers, projectID)
+			}
+			projectEvents.Unlock()
+		})
+	}
+}
+
+// PublishProjectEvent sends an event to the subscribers of a project. It never blocks:
+// a subscriber whose buffer is full misses the event.
+func PublishProjectEvent(projectID uint, event ProjectEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	projectEvents.Lock()
+	defer projectEvents.Unlock()
+	for ch := range projectEvents.subscribers[projectID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
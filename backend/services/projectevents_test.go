@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProjectEvents(t *testing.T) {
+	events, unsubscribe := SubscribeProjectEvents(1)
+	other, unsubscribeOther := SubscribeProjectEvents(2)
+	defer unsubscribeOther()
+
+	PublishProjectEvent(1, ProjectEvent{Type: EventHashComputed, File: "IMG_1.jpg", Hash: "abc"})
+	select {
+	case event := <-events:
+		if event.Type != EventHashComputed || event.File != "IMG_1.jpg" || event.Time.IsZero() {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the event to reach the subscriber")
+	}
+	select {
+	case event := <-other:
+		t.Errorf("Expected other projects not to receive it, got %+v", event)
+	default:
+	}
+
+	// A subscriber that doesn't keep up misses events instead of blocking the publisher
+	for i := 0; i < projectEventBuffer+10; i++ {
+		PublishProjectEvent(1, ProjectEvent{Type: EventThumbnailDone, PhotoID: uint(i)})
+	}
+	if len(events) != projectEventBuffer {
+		t.Errorf("Expected a full buffer of %d events, got %d", projectEventBuffer, len(events))
+	}
+
+	unsubscribe()
+	unsubscribe()
+	projectEvents.Lock()
+	_, subscribed := projectEvents.subscribers[1]
+	projectEvents.Unlock()
+	if subscribed {
+		t.Error("Expected the project's last subscription to be removed")
+	}
+}
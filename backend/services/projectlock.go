@@ -0,0 +1,76 @@
+package services
+
+import (
+	"sync"
+
+	"photobridge/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// projectLock is a reference-counted lock for one project's upload directory
+type projectLock struct {
+	mu   sync.RWMutex
+	refs int
+}
+
+var (
+	projectLocksMu sync.Mutex
+	projectLocks   = map[uint]*projectLock{}
+)
+
+func acquireProjectLock(projectID uint) *projectLock {
+	projectLocksMu.Lock()
+	defer projectLocksMu.Unlock()
+	l, ok := projectLocks[projectID]
+	if !ok {
+		l = &projectLock{}
+		projectLocks[projectID] = l
+	}
+	l.refs++
+	return l
+}
+
+func releaseProjectLock(projectID uint, l *projectLock) {
+	projectLocksMu.Lock()
+	defer projectLocksMu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(projectLocks, projectID)
+	}
+}
+
+// LockProject takes the exclusive lock of a project, for operations that move or remove
+// its directory (rename, delete). It waits for running uploads and blocks new ones.
+// Callers must re-read the project after locking; it may have changed while waiting.
+func LockProject(projectID uint) (unlock func()) {
+	l := acquireProjectLock(projectID)
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		releaseProjectLock(projectID, l)
+	}
+}
+
+// RLockProject takes the shared lock of a project, for operations that write files into
+// its directory (uploads, photo deletion). Any number of them can run at once.
+func RLockProject(projectID uint) (unlock func()) {
+	l := acquireProjectLock(projectID)
+	l.mu.RLock()
+	return func() {
+		l.mu.RUnlock()
+		releaseProjectLock(projectID, l)
+	}
+}
+
+// lockProjectRow takes a row lock on the project inside tx so other server instances
+// sharing the database serialize too. SQLite has no row locks (its writers are
+// serialized anyway), so it is skipped there.
+func lockProjectRow(tx *gorm.DB, projectID uint) error {
+	if tx.Dialector.Name() == "sqlite" {
+		return nil
+	}
+	var project models.Project
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&project, projectID).Error
+}
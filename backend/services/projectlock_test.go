@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProjectLockExclusiveWaitsForShared(t *testing.T) {
+	unlockUpload := RLockProject(1)
+	unlockOther := RLockProject(1) // Uploads don't block each other
+
+	locked := make(chan struct{})
+	go func() {
+		unlock := LockProject(1)
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("Exclusive lock acquired while uploads were running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Other projects are unaffected
+	unlockElse := LockProject(2)
+	unlockElse()
+
+	unlockUpload()
+	unlockOther()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("Exclusive lock not acquired after uploads finished")
+	}
+}
+
+func TestProjectLockCleanup(t *testing.T) {
+	unlock := LockProject(3)
+	unlock()
+	unlock = RLockProject(3)
+	unlock()
+
+	projectLocksMu.Lock()
+	defer projectLocksMu.Unlock()
+	if _, ok := projectLocks[3]; ok {
+		t.Error("Unused project lock should be removed")
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+// Upload limit errors
+var (
+	ErrFileTooLarge  = errors.New("file exceeds the upload size limit")
+	ErrQuotaExceeded = errors.New("project storage quota exceeded")
+)
+
+const bytesPerMegabyte = 1 << 20
+
+// ProjectUsage is the storage used by a project's photo files and its limits. Sizes
+// are the recorded file sizes; photos in the trash don't count.
+type ProjectUsage struct {
+	Photos         int64 `json:"photos"`
+	NormalBytes    int64 `json:"normal_bytes"`
+	RawBytes       int64 `json:"raw_bytes"`
+	UsedBytes      int64 `json:"used_bytes"`
+	QuotaBytes     int64 `json:"quota_bytes"`      // 0 = unlimited
+	MaxUploadBytes int64 `json:"max_upload_bytes"` // Per file, 0 = unlimited
+}
+
+// MaxUploadBytes returns the MAX_UPLOAD_SIZE_MB limit of a single file (0 = unlimited)
+func MaxUploadBytes() int64 {
+	if config.AppConfig == nil {
+		return 0
+	}
+	return int64(config.AppConfig.MaxUploadSizeMB) * bytesPerMegabyte
+}
+
+// GetProjectUsage totals the files of a project's photos
+func GetProjectUsage(project *models.Project) (*ProjectUsage, error) {
+	usage := &ProjectUsage{
+		QuotaBytes:     int64(project.QuotaMB) * bytesPerMegabyte,
+		MaxUploadBytes: MaxUploadBytes(),
+	}
+	err := database.DB.Model(&models.Photo{}).Where("project_id = ?", project.ID).
+		Select("COUNT(*) AS photos, COALESCE(SUM(normal_size), 0) AS normal_bytes, COALESCE(SUM(raw_size), 0) AS raw_bytes").
+		Scan(usage).Error
+	if err != nil {
+		return nil, err
+	}
+	usage.UsedBytes = usage.NormalBytes + usage.RawBytes
+	return usage, nil
+}
+
+// CheckUploadSize returns ErrFileTooLarge if a file of size bytes exceeds MAX_UPLOAD_SIZE_MB
+func CheckUploadSize(size int64) error {
+	if limit := MaxUploadBytes(); limit > 0 && size > limit {
+		return ErrFileTooLarge
+	}
+	return nil
+}
+
+// CheckProjectQuota returns ErrQuotaExceeded if adding size bytes would take a project
+// beyond its quota. A file replacing another of the photo is counted in full, and
+// concurrent uploads may overshoot the quota by the files they add at the same time.
+func CheckProjectQuota(project *models.Project, size int64) error {
+	if project.QuotaMB <= 0 {
+		return nil
+	}
+	usage, err := GetProjectUsage(project)
+	if err != nil {
+		return err
+	}
+	if usage.UsedBytes+size > usage.QuotaBytes {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestProjectQuota(t *testing.T) {
+	setupThumbStoreTest(t)
+	config.AppConfig.MaxUploadSizeMB = 2
+	project := &models.Project{Name: "wedding", QuotaMB: 1}
+	database.DB.Create(project)
+	database.DB.Create(&[]models.Photo{
+		{ProjectID: project.ID, BaseName: "a", NormalExt: ".jpg", NormalSize: 300 << 10, RawExt: ".cr2", HasRaw: true, RawSize: 500 << 10},
+		{ProjectID: 99, BaseName: "other", NormalExt: ".jpg", NormalSize: 10 << 20},
+	})
+
+	usage, err := GetProjectUsage(project)
+	if err != nil {
+		t.Fatalf("GetProjectUsage failed: %v", err)
+	}
+	expected := ProjectUsage{Photos: 1, NormalBytes: 300 << 10, RawBytes: 500 << 10, UsedBytes: 800 << 10, QuotaBytes: 1 << 20, MaxUploadBytes: 2 << 20}
+	if *usage != expected {
+		t.Errorf("Expected %+v, got %+v", expected, *usage)
+	}
+
+	if err := CheckProjectQuota(project, 224<<10); err != nil {
+		t.Errorf("Expected a file filling the quota to be accepted, got %v", err)
+	}
+	if err := CheckProjectQuota(project, 225<<10); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	project.QuotaMB = 0
+	if err := CheckProjectQuota(project, 1<<30); err != nil {
+		t.Errorf("Expected no limit without a quota, got %v", err)
+	}
+
+	if err := CheckUploadSize(2 << 20); err != nil {
+		t.Errorf("Expected a file at the size limit to be accepted, got %v", err)
+	}
+	if err := CheckUploadSize(2<<20 + 1); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+	config.AppConfig.MaxUploadSizeMB = 0
+	if err := CheckUploadSize(1 << 40); err != nil {
+		t.Errorf("Expected no limit without MAX_UPLOAD_SIZE_MB, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"photobridge/config"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+)
+
+const rawConvertShortname = "[RawConvert]"
+
+// convertLocks serializes conversions of the same photo (photo ID → *sync.Mutex)
+var convertLocks sync.Map
+
+// RawConversionEnabled reports whether RAW_CONVERT_COMMAND is configured
+func RawConversionEnabled() bool {
+	return config.AppConfig != nil && config.AppConfig.RawConvertCommand != ""
+}
+
+// CanConvertRaw reports whether a converted JPEG can be offered for the photo (RAW-only photos)
+func CanConvertRaw(photo *models.Photo) bool {
+	return RawConversionEnabled() && photo.NormalExt == "" && photo.HasRaw && photo.RawExt != ""
+}
+
+// CanConvertHEIF reports whether a JPEG of a HEIC/HEIF original can be offered for
+// browsers that can't display it
+func CanConvertHEIF(photo *models.Photo) bool {
+	return utils.HEIFConversionEnabled() && models.IsHEIFExtension(strings.ToLower(photo.NormalExt))
+}
+
+// CanConvertToJPEG reports whether EnsureConvertedJPEG works for the photo
+func CanConvertToJPEG(photo *models.Photo) bool {
+	return CanConvertRaw(photo) || CanConvertHEIF(photo)
+}
+
+// convertedDir holds the cached conversions of a photo
+func convertedDir(photoID uint) string {
+	return filepath.Join(filepath.Dir(config.AppConfig.DatabasePath), "converted", strconv.FormatUint(uint64(photoID), 10))
+}
+
+// ConvertedJPEGPath returns where the converted JPEG of a photo is cached.
+// The file keeps the photo's base name so downloads and zip entries are named naturally.
+func ConvertedJPEGPath(photo *models.Photo) string {
+	return filepath.Join(convertedDir(photo.ID), photo.BaseName+".jpg")
+}
+
+// EnsureConvertedJPEG returns the converted JPEG of a RAW-only or HEIC/HEIF photo,
+// running the converter if there is no cached copy or the source file changed since
+// it was made.
+func EnsureConvertedJPEG(projectName string, photo *models.Photo) (string, error) {
+	var sourceExt, command string
+	switch {
+	case CanConvertHEIF(photo):
+		sourceExt, command = photo.NormalExt, config.AppConfig.HEIFConvertCommand
+	case CanConvertRaw(photo):
+		sourceExt, command = photo.RawExt, config.AppConfig.RawConvertCommand
+	default:
+		return "", fmt.Errorf("photo %d cannot be converted", photo.ID)
+	}
+	if !utils.ValidatePathComponent(projectName) || !utils.ValidatePathComponent(photo.BaseName) {
+		return "", fmt.Errorf("invalid project or file name")
+	}
+	sourceKey := storage.Key(projectName, photo.BaseName+sourceExt)
+	sourceInfo, err := storage.Default().Stat(context.Background(), sourceKey)
+	if err != nil {
+		return "", fmt.Errorf("source file not found: %w", err)
+	}
+
+	lock, _ := convertLocks.LoadOrStore(photo.ID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	output := ConvertedJPEGPath(photo)
+	if info, err := os.Stat(output); err == nil && !info.ModTime().Before(sourceInfo.ModTime) {
+		return output, nil
+	}
+
+	sourcePath, release, err := storage.Fetch(context.Background(), sourceKey)
+	if err != nil {
+		return "", fmt.Errorf("source file not found: %w", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.AppConfig.RawConvertTimeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := utils.ConvertRawToJPEG(ctx, command, sourcePath, output); err != nil {
+		log.Printf("%s Failed to convert photo %d: %v", rawConvertShortname, photo.ID, err)
+		return "", err
+	}
+	log.Printf("%s Converted photo %d in %s", rawConvertShortname, photo.ID, time.Since(start).Round(time.Millisecond))
+	return output, nil
+}
+
+// RemoveConvertedJPEG deletes the cached conversions of a photo
+func RemoveConvertedJPEG(photoID uint) {
+	if config.AppConfig == nil {
+		return
+	}
+	if err := os.RemoveAll(convertedDir(photoID)); err != nil {
+		log.Printf("%s Failed to remove conversions of photo %d: %v", rawConvertShortname, photoID, err)
+	}
+	convertLocks.Delete(photoID)
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"sync"
+
+	"photobridge/config"
+	"photobridge/models"
+)
+
+// rawPreviewFailures remembers RAW-only photos whose RAW has no usable embedded
+// preview (photo ID → RAW hash), so they keep their placeholder instead of being
+// queued on every request. Uploading another RAW changes the hash and retries.
+var rawPreviewFailures sync.Map
+
+// RawPreviewEnabled reports whether RAW_PREVIEW_ENABLED is set
+func RawPreviewEnabled() bool {
+	return config.AppConfig != nil && config.AppConfig.RawPreviewEnabled
+}
+
+// CanPreviewRaw reports whether thumbnails of a RAW-only photo can be generated from
+// the JPEG preview embedded in its RAW file
+func CanPreviewRaw(photo *models.Photo) bool {
+	if !RawPreviewEnabled() || !IsRawOnly(photo) || photo.RawExt == "" {
+		return false
+	}
+	failedHash, failed := rawPreviewFailures.Load(photo.ID)
+	return !failed || failedHash.(string) != photo.RawHash
+}
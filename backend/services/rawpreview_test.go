@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+// writeTestRAF writes a minimal Fujifilm RAF whose header points at a JPEG preview
+func writeTestRAF(t *testing.T, path string, width, height int) {
+	t.Helper()
+	var preview bytes.Buffer
+	if err := jpeg.Encode(&preview, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("Failed to encode preview: %v", err)
+	}
+	header := make([]byte, 100)
+	copy(header, "FUJIFILMCCD-RAW 0201")
+	binary.BigEndian.PutUint32(header[84:], uint32(len(header)))
+	binary.BigEndian.PutUint32(header[88:], uint32(preview.Len()))
+	if err := os.WriteFile(path, append(header, preview.Bytes()...), 0644); err != nil {
+		t.Fatalf("Failed to write RAF: %v", err)
+	}
+}
+
+func TestRawPreviewThumbnails(t *testing.T) {
+	project := setupProjectTest(t)
+	config.AppConfig.RawPreviewEnabled = true
+	dir := filepath.Join(config.AppConfig.UploadDir, project.Name)
+	writeTestRAF(t, filepath.Join(dir, "DSCF0001.RAF"), 600, 400)
+	os.WriteFile(filepath.Join(dir, "DSCF0002.RAF"), []byte("FUJIFILMCCD-RAW without a preview"), 0644)
+
+	withPreview := models.Photo{ProjectID: project.ID, BaseName: "DSCF0001", RawExt: ".RAF", HasRaw: true, RawHash: "a"}
+	withoutPreview := models.Photo{ProjectID: project.ID, BaseName: "DSCF0002", RawExt: ".RAF", HasRaw: true, RawHash: "b"}
+	database.DB.Create(&withPreview)
+	database.DB.Create(&withoutPreview)
+
+	q := createTestQueue()
+	for _, photo := range []*models.Photo{&withPreview, &withoutPreview} {
+		if status := q.ThumbnailStatus(photo, project.Name); status != ThumbStatusQueued {
+			t.Fatalf("Expected RAW-only photo %d to be queued, got %s", photo.ID, status)
+		}
+	}
+	for _, task := range q.tasks {
+		q.processTask(task)
+	}
+
+	database.DB.First(&withPreview, withPreview.ID)
+	if !HasThumbnails(&withPreview) || withPreview.Width != 600 || withPreview.Height != 400 {
+		t.Errorf("Expected thumbnails from the 600x400 preview, got %dx%d", withPreview.Width, withPreview.Height)
+	}
+	if status := q.CurrentThumbnailStatus(&withPreview); status != ThumbStatusReady {
+		t.Errorf("Expected ready, got %s", status)
+	}
+
+	// Without a preview the placeholder stays, until another RAW is uploaded
+	if status := q.ThumbnailStatus(&withoutPreview, project.Name); status != ThumbStatusRawOnly {
+		t.Errorf("Expected raw_only after a failed extraction, got %s", status)
+	}
+	withoutPreview.RawHash = "c"
+	if !CanPreviewRaw(&withoutPreview) {
+		t.Error("Expected a replaced RAW to be tried again")
+	}
+
+	config.AppConfig.RawPreviewEnabled = false
+	if status := q.CurrentThumbnailStatus(&withPreview); status != ThumbStatusRawOnly {
+		t.Errorf("Expected raw_only with RAW_PREVIEW_ENABLED=false, got %s", status)
+	}
+}
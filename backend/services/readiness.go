@@ -0,0 +1,97 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const readinessShortname = "[Startup]"
+
+// Startup steps an instance must complete before it is ready for traffic
+const (
+	StepMigrations = "migrations"
+	StepUploadDir  = "upload_dir"
+	StepThumbQueue = "thumbnail_queue"
+)
+
+// Step states
+const (
+	StepPending = "pending"
+	StepOK      = "ok"
+	StepFailed  = "failed"
+)
+
+// StartupStep is the outcome of one startup self-test step
+type StartupStep struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	ElapsedMs int64  `json:"elapsed_ms"` // time from process start until the step finished
+}
+
+// Readiness tracks the startup steps; the zero value has no steps and is ready
+type Readiness struct {
+	mu      sync.RWMutex
+	started time.Time
+	steps   []StartupStep
+}
+
+// Startup is the readiness of this instance, reported by /api/ready
+var Startup = NewReadiness(StepMigrations, StepUploadDir, StepThumbQueue)
+
+// NewReadiness creates a tracker with the given steps pending
+func NewReadiness(steps ...string) *Readiness {
+	r := &Readiness{started: time.Now()}
+	for _, name := range steps {
+		r.steps = append(r.steps, StartupStep{Name: name, Status: StepPending})
+	}
+	return r
+}
+
+// Complete records the outcome of a step: ok when err is nil, failed otherwise
+func (r *Readiness) Complete(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.steps {
+		if r.steps[i].Name != name {
+			continue
+		}
+		step := &r.steps[i]
+		step.Status, step.Message = StepOK, ""
+		step.ElapsedMs = time.Since(r.started).Milliseconds()
+		if err != nil {
+			step.Status, step.Message = StepFailed, err.Error()
+			log.Printf("%s Step %s failed: %v", readinessShortname, name, err)
+		} else {
+			log.Printf("%s Step %s ok after %dms", readinessShortname, name, step.ElapsedMs)
+		}
+	}
+}
+
+// Starting reports whether a step is still pending
+func (r *Readiness) Starting() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, step := range r.steps {
+		if step.Status == StepPending {
+			return true
+		}
+	}
+	return false
+}
+
+// Report returns whether every step succeeded, and a copy of the steps
+func (r *Readiness) Report() (bool, []StartupStep) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ready := true
+	steps := make([]StartupStep, len(r.steps))
+	for i, step := range r.steps {
+		steps[i] = step
+		if step.Status != StepOK {
+			ready = false
+		}
+	}
+	return ready, steps
+}
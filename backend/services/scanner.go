@@ -0,0 +1,346 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+)
+
+const scanShortname = "[Scanner]"
+
+// ErrScanNeedsLocalStorage is returned when originals are kept in object storage,
+// where there is no project directory to scan
+var ErrScanNeedsLocalStorage = errors.New("scanning needs local storage")
+
+// ScanResult reports how ScanProject reconciled a project with its directory
+type ScanResult struct {
+	Added     int      `json:"added"`               // Photos registered for files without one
+	Attached  int      `json:"attached"`            // Files added to existing photos (e.g. the RAW of a JPEG)
+	Changed   int      `json:"changed"`             // Files whose content changed since they were recorded
+	Detached  int      `json:"detached"`            // Missing files removed from photos that still have another file
+	Deleted   int      `json:"deleted"`             // Photos deleted because all their files are gone
+	Queued    int      `json:"queued"`              // Thumbnails queued for new and changed images
+	Invalid   []string `json:"invalid,omitempty"`   // File names uploads would reject; left alone
+	Conflicts []string `json:"conflicts,omitempty"` // Further images or RAW files of a base name; left alone
+	Failed    []string `json:"failed,omitempty"`    // Files that could not be read or are not valid images
+}
+
+// ScanProject reconciles a project with the files in its directory, e.g. after files
+// were copied in with rsync: files without a photo are registered (hashed, with
+// EXIF and dimensions), files whose size changed are hashed again, missing files are
+// removed from their photos, and photos without any file left are deleted (to the
+// trash, if enabled). Thumbnails of new and changed images are queued in the
+// background. Extensions are lowercased on disk, as uploads store them. A directory
+// without any photo file (e.g. an unmounted share) deletes nothing.
+func ScanProject(q *ThumbQueue, project *models.Project) (*ScanResult, error) {
+	if !storage.IsLocal() {
+		return nil, ErrScanNeedsLocalStorage
+	}
+	projectPath, err := projectDir(project.Name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(projectPath); os.IsNotExist(err) {
+		return &ScanResult{}, nil // Nothing uploaded yet
+	}
+
+	result := &ScanResult{}
+	vanished, err := scanProjectFiles(q, project, projectPath, result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleted after the shared lock is released; DeletePhotos takes it itself
+	if len(vanished) > 0 {
+		deleted, err := DeletePhotos(vanished)
+		if err != nil {
+			return result, err
+		}
+		result.Deleted = len(deleted.Done)
+	}
+
+	if result.Added+result.Attached+result.Changed+result.Detached+result.Deleted > 0 {
+		log.Printf("%s Project %q: %d added, %d attached, %d changed, %d detached, %d deleted", scanShortname,
+			project.Name, result.Added, result.Attached, result.Changed, result.Detached, result.Deleted)
+	}
+	return result, nil
+}
+
+// scanProjectFiles applies the directory's files to the project's photos and returns
+// the IDs of the photos left without any file
+func scanProjectFiles(q *ThumbQueue, project *models.Project, projectPath string, result *ScanResult) ([]uint, error) {
+	// Shared lock, like uploads: the project can't be renamed or deleted meanwhile
+	unlock := RLockProject(project.ID)
+	defer unlock()
+
+	groups, _, invalid, err := scanImportFolder(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	result.Invalid = invalid
+
+	var photos []models.Photo
+	if err := database.DB.Select(common.PhotoMetaColumns).Where("project_id = ?", project.ID).Find(&photos).Error; err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 && len(photos) > 0 {
+		log.Printf("%s No photo files in %s, leaving the %d photos of %q alone", scanShortname, projectPath, len(photos), project.Name)
+		return nil, nil
+	}
+	byName := make(map[string]*models.Photo, len(photos))
+	for i := range photos {
+		byName[photos[i].BaseName] = &photos[i]
+	}
+
+	var vanished []uint
+	for _, group := range groups {
+		photo, found := byName[group.baseName]
+		delete(byName, group.baseName)
+		if !found {
+			photo = &models.Photo{ProjectID: project.ID, BaseName: group.baseName}
+		}
+		scanned := scanPhoto{project: project, dir: projectPath, photo: photo, result: result}
+		scanned.reconcile(group.normals, false)
+		scanned.reconcile(group.raws, true)
+		if !scanned.changed {
+			continue
+		}
+		if photo.NormalExt == "" && !photo.HasRaw {
+			if found {
+				vanished = append(vanished, photo.ID)
+			}
+			continue
+		}
+		if found {
+			err = database.DB.Omit("thumb_small", "thumb_large").Save(photo).Error
+		} else {
+			err = database.DB.Create(photo).Error
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", group.baseName, err))
+			continue
+		}
+		if !found {
+			result.Added++
+		}
+		scanned.finish(q)
+	}
+
+	// Photos without any file in the directory
+	for _, photo := range byName {
+		vanished = append(vanished, photo.ID)
+	}
+	return vanished, nil
+}
+
+// scanPhoto reconciles one photo with the files of its base name
+type scanPhoto struct {
+	project      *models.Project
+	dir          string
+	photo        *models.Photo
+	result       *ScanResult
+	changed      bool   // The photo's record changed
+	thumbsStale  bool   // Its thumbnails no longer match its image
+	oldCoverName string // Cover name of a normal image that went away
+}
+
+// reconcile compares the normal image (or RAW file) of the photo with files, the
+// files of that kind on disk in order of preference
+func (s *scanPhoto) reconcile(files []string, isRaw bool) {
+	recorded := s.photo.NormalExt
+	if isRaw {
+		recorded = s.photo.RawExt
+	}
+
+	// The recorded file is still there: check it and leave any others alone
+	if recorded != "" {
+		for i, name := range files {
+			if name != s.photo.BaseName+recorded {
+				continue
+			}
+			s.checkChanged(name, isRaw)
+			s.addConflicts(append(files[:i:i], files[i+1:]...))
+			return
+		}
+		s.detach(isRaw)
+	}
+	if len(files) == 0 {
+		return
+	}
+	s.attach(files[0], isRaw)
+	s.addConflicts(files[1:])
+}
+
+// checkChanged hashes a recorded file again if its size changed
+func (s *scanPhoto) checkChanged(name string, isRaw bool) {
+	path := filepath.Join(s.dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		s.result.Failed = append(s.result.Failed, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	recordedSize, recordedHash := s.photo.NormalSize, s.photo.NormalHash
+	if isRaw {
+		recordedSize, recordedHash = s.photo.RawSize, s.photo.RawHash
+	}
+	if recordedSize == 0 || info.Size() == recordedSize {
+		return // Size unknown (photos from before sizes were recorded) or unchanged
+	}
+	hash, err := utils.CalculateFileHashFromPath(path)
+	if err != nil {
+		s.result.Failed = append(s.result.Failed, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	if hash == recordedHash {
+		return
+	}
+	recordPhotoFile(s.photo, path, strings.ToLower(filepath.Ext(name)), hash)
+	s.fileChanged(isRaw)
+	s.result.Changed++
+}
+
+// attach records a file the photo doesn't have yet, lowercasing its extension on disk
+func (s *scanPhoto) attach(name string, isRaw bool) {
+	path := filepath.Join(s.dir, name)
+	var err error
+	if isRaw {
+		err = utils.ValidateRAWFile(path)
+	} else {
+		err = utils.ValidateImageContent(path)
+	}
+	if err != nil {
+		s.result.Failed = append(s.result.Failed, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	if lower := s.photo.BaseName + ext; lower != name {
+		if _, err := os.Stat(filepath.Join(s.dir, lower)); err == nil && !sameFile(path, filepath.Join(s.dir, lower)) {
+			s.result.Failed = append(s.result.Failed, fmt.Sprintf("%s: %s exists too", name, lower))
+			return
+		}
+		if err := os.Rename(path, filepath.Join(s.dir, lower)); err != nil {
+			s.result.Failed = append(s.result.Failed, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		path = filepath.Join(s.dir, lower)
+	}
+
+	hash, err := utils.CalculateFileHashFromPath(path)
+	if err != nil {
+		s.result.Failed = append(s.result.Failed, fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+	isNew := s.photo.ID == 0
+	recordPhotoFile(s.photo, path, ext, hash)
+	s.fileChanged(isRaw)
+	if !isNew {
+		s.result.Attached++
+	}
+}
+
+// detach removes a missing file from the photo
+func (s *scanPhoto) detach(isRaw bool) {
+	if isRaw {
+		s.photo.RawExt, s.photo.HasRaw, s.photo.RawHash, s.photo.RawSize = "", false, "", 0
+	} else {
+		s.oldCoverName = CoverPhotoName(s.photo)
+		s.photo.NormalExt, s.photo.NormalHash, s.photo.NormalSize = "", "", 0
+		s.photo.Width, s.photo.Height = 0, 0
+		s.photo.FileHash = s.photo.RawHash // file_hash follows the remaining file, as in RepairMissingFiles
+	}
+	s.fileChanged(isRaw)
+	s.result.Detached++
+}
+
+// fileChanged notes that one of the photo's files was added, replaced or removed
+func (s *scanPhoto) fileChanged(isRaw bool) {
+	s.changed = true
+	if isRaw {
+		RemoveConvertedJPEG(s.photo.ID)
+	}
+	// Thumbnails come from the normal image, or from the RAW of a RAW-only photo
+	if !isRaw || s.photo.NormalExt == "" {
+		s.thumbsStale = true
+	}
+}
+
+// addConflicts reports files the photo can't hold besides the one it has
+func (s *scanPhoto) addConflicts(files []string) {
+	s.result.Conflicts = append(s.result.Conflicts, files...)
+}
+
+// finish clears stale thumbnails and the cover of a saved photo and queues its thumbnails
+func (s *scanPhoto) finish(q *ThumbQueue) {
+	if s.oldCoverName != "" && s.oldCoverName != CoverPhotoName(s.photo) {
+		ReplaceCoverPhoto(s.project.ID, s.oldCoverName, "")
+	}
+	if s.photo.NormalExt != "" {
+		SetDefaultCoverPhoto(s.project.ID, CoverPhotoName(s.photo))
+	}
+	if !s.thumbsStale {
+		return
+	}
+	if s.photo.ThumbWidth > 0 || HasThumbnails(s.photo) {
+		if err := clearThumbnails(s.photo); err != nil {
+			log.Printf("%s Failed to clear thumbnails of photo %d: %v", scanShortname, s.photo.ID, err)
+		}
+	}
+	if q != nil && q.EnqueueBackground(s.photo, s.project.Name) {
+		s.result.Queued++
+	}
+}
+
+// sameFile reports whether two paths name the same file (case-insensitive filesystems)
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// ScanAllProjects scans the directory of every project (see ScanProject)
+func ScanAllProjects(q *ThumbQueue) {
+	var projects []models.Project
+	if err := database.DB.Select("id, name").Order("id").Find(&projects).Error; err != nil {
+		log.Printf("%s Failed to load projects: %v", scanShortname, err)
+		return
+	}
+	for i := range projects {
+		if _, err := ScanProject(q, &projects[i]); err != nil {
+			log.Printf("%s Failed to scan project %q: %v", scanShortname, projects[i].Name, err)
+		}
+	}
+}
+
+// StartProjectScanner scans every project directory each SCAN_INTERVAL_MINUTES for
+// files copied in directly
+func StartProjectScanner(q *ThumbQueue, interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("%s Disabled", scanShortname)
+		return
+	}
+	if !storage.IsLocal() {
+		log.Printf("%s Disabled: originals are in object storage", scanShortname)
+		return
+	}
+
+	log.Printf("%s Started with interval %s", scanShortname, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			<-ticker.C
+			ScanAllProjects(q)
+		}
+	}()
+}
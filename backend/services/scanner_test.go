@@ -0,0 +1,100 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestScanProject(t *testing.T) {
+	project := setupProjectTest(t)
+	if err := database.DB.AutoMigrate(&models.PhotoExclusion{}, &models.PhotoHighlight{}, &models.PhotoSelection{}, &models.RawExclusion{}, &models.PhotoComment{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	dir := filepath.Join(config.AppConfig.UploadDir, project.Name)
+	database.DB.Create(&[]models.Photo{
+		{ProjectID: project.ID, BaseName: "gone", NormalExt: ".jpg"},
+		{ProjectID: project.ID, BaseName: "IMG_0003", NormalExt: ".jpg", RawExt: ".cr2", HasRaw: true},
+		{ProjectID: project.ID, BaseName: "IMG_0004", NormalExt: ".jpg", NormalSize: 10, NormalHash: "old"},
+	})
+	writeImportJPEG(t, filepath.Join(dir, "IMG_0003.jpg"), 10)
+	writeImportJPEG(t, filepath.Join(dir, "IMG_0004.jpg"), 20)
+	writeImportJPEG(t, filepath.Join(dir, "DSC_0010.JPG"), 30)
+	writeImportJPEG(t, filepath.Join(dir, "bad(name).jpg"), 40)
+	os.WriteFile(filepath.Join(dir, "IMG_0001.cr2"), []byte("II*\x00raw data"), 0644)
+
+	q := createTestQueue()
+	result, err := ScanProject(q, project)
+	if err != nil {
+		t.Fatalf("ScanProject failed: %v", err)
+	}
+	if result.Added != 1 || result.Attached != 1 || result.Changed != 1 || result.Detached != 1 || result.Deleted != 1 {
+		t.Errorf("Unexpected scan result %+v", result)
+	}
+	if !slices.Equal(result.Invalid, []string{"bad(name).jpg"}) {
+		t.Errorf("Expected the invalid file name to be reported, got %v", result.Invalid)
+	}
+	// The new photo and the changed image; IMG_0003 kept its image
+	if result.Queued != 2 {
+		t.Errorf("Expected 2 thumbnails to be queued, got %d", result.Queued)
+	}
+
+	var added models.Photo
+	if err := database.DB.Where("base_name = ?", "DSC_0010").First(&added).Error; err != nil {
+		t.Fatalf("Expected the copied file to be registered: %v", err)
+	}
+	if added.NormalExt != ".jpg" || added.NormalHash == "" || added.Width != 32 {
+		t.Errorf("Expected the new photo's file to be recorded, got %+v", added)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "DSC_0010.jpg")); err != nil {
+		t.Errorf("Expected the extension to be lowercased on disk: %v", err)
+	}
+
+	var photo models.Photo
+	database.DB.Where("base_name = ?", "IMG_0001").First(&photo)
+	if !photo.HasRaw || photo.RawExt != ".cr2" || photo.NormalExt != ".jpg" {
+		t.Errorf("Expected the RAW to be attached to IMG_0001, got %+v", photo)
+	}
+	var detached models.Photo
+	database.DB.Where("base_name = ?", "IMG_0003").First(&detached)
+	if detached.HasRaw || detached.RawExt != "" || detached.NormalExt != ".jpg" {
+		t.Errorf("Expected the missing RAW to be detached, got %+v", detached)
+	}
+	var changed models.Photo
+	database.DB.Where("base_name = ?", "IMG_0004").First(&changed)
+	if changed.NormalHash == "old" || changed.NormalSize == 10 {
+		t.Errorf("Expected the changed image to be hashed again, got %+v", changed)
+	}
+	if database.DB.Where("base_name = ?", "gone").First(&models.Photo{}).Error == nil {
+		t.Error("Expected the photo without files to be deleted")
+	}
+
+	// A second scan finds nothing to do
+	result, err = ScanProject(q, project)
+	if err != nil {
+		t.Fatalf("ScanProject failed: %v", err)
+	}
+	if result.Added+result.Attached+result.Changed+result.Detached+result.Deleted != 0 {
+		t.Errorf("Expected a rescan to change nothing, got %+v", result)
+	}
+}
+
+func TestScanProjectEmptyDir(t *testing.T) {
+	project := setupProjectTest(t)
+	os.Remove(filepath.Join(config.AppConfig.UploadDir, project.Name, "IMG_0001.jpg"))
+
+	result, err := ScanProject(createTestQueue(), project)
+	if err != nil {
+		t.Fatalf("ScanProject failed: %v", err)
+	}
+	var count int64
+	database.DB.Model(&models.Photo{}).Count(&count)
+	if result.Deleted != 0 || count != 1 {
+		t.Errorf("Expected an empty directory to delete nothing, got %+v with %d photos", result, count)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+)
+
+const seedShortname = "[Seed]"
+
+// seedProject describes a sample project and the base color of its generated images
+type seedProject struct {
+	Name        string
+	Description string
+	Color       color.RGBA
+}
+
+var seedProjects = []seedProject{
+	{"Demo Wedding", "Sample project created by the seed command", color.RGBA{R: 214, G: 170, B: 160, A: 255}},
+	{"Demo Portraits", "Sample project created by the seed command", color.RGBA{R: 120, G: 140, B: 190, A: 255}},
+	{"Demo Landscape", "Sample project created by the seed command", color.RGBA{R: 90, G: 160, B: 110, A: 255}},
+}
+
+// SeedResult summarizes what SeedDemoData created
+type SeedResult struct {
+	Projects []string
+	Photos   int
+	Links    []models.ShareLinkWithPassword // with the generated passwords, which are only stored hashed
+}
+
+// SeedDemoData creates sample projects with generated images, thumbnails and share links
+// in various configurations. Projects that already exist are skipped, so it can be re-run.
+func SeedDemoData(photosPerProject int) (*SeedResult, error) {
+	result := &SeedResult{}
+
+	for _, sample := range seedProjects {
+		var count int64
+		database.DB.Model(&models.Project{}).Where("name = ?", sample.Name).Count(&count)
+		if count > 0 {
+			log.Printf("%s Project %q already exists, skipping", seedShortname, sample.Name)
+			continue
+		}
+
+		project := models.Project{Name: sample.Name, Description: sample.Description}
+		if err := database.DB.Create(&project).Error; err != nil {
+			return result, fmt.Errorf("failed to create project %s: %w", sample.Name, err)
+		}
+
+		photos, err := seedPhotos(&project, sample.Color, photosPerProject)
+		if err != nil {
+			return result, err
+		}
+		links, err := seedShareLinks(&project, photos)
+		if err != nil {
+			return result, err
+		}
+
+		result.Projects = append(result.Projects, project.Name)
+		result.Photos += len(photos)
+		result.Links = append(result.Links, links...)
+	}
+
+	return result, nil
+}
+
+// seedPhotos writes generated JPEGs to the project directory and records them with thumbnails
+func seedPhotos(project *models.Project, base color.RGBA, n int) ([]models.Photo, error) {
+	dir := filepath.Join(config.AppConfig.UploadDir, project.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create project directory: %w", err)
+	}
+
+	var photos []models.Photo
+	for i := 0; i < n; i++ {
+		baseName := fmt.Sprintf("DEMO_%04d", i+1)
+		path := filepath.Join(dir, baseName+".jpg")
+
+		// Alternate landscape and portrait orientation
+		width, height := 1500, 1000
+		if i%3 == 2 {
+			width, height = 1000, 1500
+		}
+		if err := writeSeedImage(path, width, height, base, i); err != nil {
+			return nil, err
+		}
+
+		hash, err := utils.CalculateFileHashFromPath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		takenAt := time.Now().AddDate(0, -i, -i)
+		var size int64
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+		photo := models.Photo{
+			ProjectID:  project.ID,
+			BaseName:   baseName,
+			NormalExt:  ".jpg",
+			FileHash:   hash,
+			NormalHash: hash,
+			NormalSize: size,
+			TakenAt:    &takenAt,
+		}
+		thumbs, err := utils.GenerateThumbnails(context.Background(), path)
+		if err == nil {
+			photo.ThumbWidth = thumbs.Width
+			photo.ThumbHeight = thumbs.Height
+			photo.ThumbParams = thumbs.Params
+			photo.Width = thumbs.Width
+			photo.Height = thumbs.Height
+		} else {
+			log.Printf("%s Failed to generate thumbnail for %s: %v", seedShortname, path, err)
+		}
+		if err := storage.Store(context.Background(), storage.Key(project.Name, baseName+".jpg"), path); err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", baseName, err)
+		}
+
+		if err := database.DB.Create(&photo).Error; err != nil {
+			return nil, fmt.Errorf("failed to create photo %s: %w", baseName, err)
+		}
+		if thumbs != nil {
+			if err := SaveThumbnails(project.ID, photo.ID, thumbs.Small, thumbs.Large); err != nil {
+				return nil, fmt.Errorf("failed to write thumbnails of %s: %w", baseName, err)
+			}
+		}
+		photos = append(photos, photo)
+	}
+
+	if len(photos) > 0 {
+		project.CoverPhoto = photos[0].BaseName + photos[0].NormalExt
+		database.DB.Save(project)
+	}
+	return photos, nil
+}
+
+// writeSeedImage writes a diagonal gradient so thumbnails are visually distinguishable
+func writeSeedImage(path string, width, height int, base color.RGBA, index int) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	shift := (index * 37) % 96
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			t := (x + y) * 96 / (width + height)
+			img.Set(x, y, color.RGBA{
+				R: clampColor(int(base.R) + t - shift/2),
+				G: clampColor(int(base.G) + shift - t/2),
+				B: clampColor(int(base.B) - t + shift/3),
+				A: 255,
+			})
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+	return jpeg.Encode(f, img, &jpeg.Options{Quality: 85})
+}
+
+func clampColor(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// seedShareLinks creates one link per interesting configuration
+func seedShareLinks(project *models.Project, photos []models.Photo) ([]models.ShareLinkWithPassword, error) {
+	tomorrow := time.Now().Add(24 * time.Hour)
+	yesterday := time.Now().Add(-24 * time.Hour)
+
+	links := []models.ShareLink{
+		{Alias: "Public", AllowRaw: true},
+		{Alias: "Password protected", AllowRaw: true, PasswordEnabled: true},
+		{Alias: "No RAW downloads", AllowRaw: false},
+		{Alias: "Expires tomorrow", AllowRaw: true, ExpiresAt: &tomorrow},
+		{Alias: "Expired", AllowRaw: true, ExpiresAt: &yesterday},
+		{Alias: "With exclusions", AllowRaw: true},
+	}
+
+	created := make([]models.ShareLinkWithPassword, len(links))
+	for i := range links {
+		links[i].ProjectID = project.ID
+		links[i].Token = seedToken()
+		if links[i].PasswordEnabled {
+			password, err := utils.GenerateSharePassword(config.AppConfig.SharePasswordLength, config.AppConfig.SharePasswordChars)
+			if err != nil {
+				return nil, err
+			}
+			created[i].Password = password
+			hash, err := utils.HashPassword(password)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash share password: %w", err)
+			}
+			links[i].PasswordHash = hash
+		}
+		permissions := links[i].PermissionColumns()
+		if err := database.DB.Create(&links[i]).Error; err != nil {
+			return nil, fmt.Errorf("failed to create share link: %w", err)
+		}
+		database.DB.Model(&links[i]).Updates(permissions)
+		created[i].ShareLink = links[i]
+	}
+
+	// Pin the first few photos to the public link's hero strip
+	for i := 0; i < len(photos) && i < 3; i++ {
+		database.DB.Create(&models.PhotoHighlight{LinkID: links[0].ID, PhotoID: photos[i].ID, Position: i})
+	}
+
+	// Hide every other photo from the last link
+	excluded := links[len(links)-1]
+	for i := 1; i < len(photos); i += 2 {
+		database.DB.Create(&models.PhotoExclusion{LinkID: excluded.ID, PhotoID: photos[i].ID, Reason: models.ExclusionNotEdited})
+	}
+
+	return created, nil
+}
+
+// seedToken returns a share token in the same format as admin-created links
+func seedToken() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "=")
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSeedDemoData(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.Project{}, &models.Photo{}, &models.ShareLink{}, &models.PhotoExclusion{}, &models.PhotoHighlight{}, &models.RawExclusion{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	originalConfig := config.AppConfig
+	defer func() { config.AppConfig = originalConfig }()
+	config.AppConfig = &config.Config{UploadDir: t.TempDir(), ThumbDir: t.TempDir()}
+
+	result, err := SeedDemoData(2)
+	if err != nil {
+		t.Fatalf("SeedDemoData failed: %v", err)
+	}
+	if len(result.Projects) != len(seedProjects) {
+		t.Fatalf("Expected %d projects, got %d", len(seedProjects), len(result.Projects))
+	}
+	if result.Photos != 2*len(seedProjects) {
+		t.Errorf("Expected %d photos, got %d", 2*len(seedProjects), result.Photos)
+	}
+
+	var photo models.Photo
+	database.DB.First(&photo)
+	if !HasThumbnails(&photo) || photo.NormalHash == "" {
+		t.Error("Seeded photo should have thumbnails and a hash")
+	}
+	var project models.Project
+	database.DB.First(&project, photo.ProjectID)
+	if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, project.Name, photo.BaseName+photo.NormalExt)); err != nil {
+		t.Errorf("Seeded image missing on disk: %v", err)
+	}
+
+	var expired, protected int64
+	database.DB.Model(&models.ShareLink{}).Where("expires_at < ?", time.Now()).Count(&expired)
+	database.DB.Model(&models.ShareLink{}).Where("password_enabled = ?", true).Count(&protected)
+	if expired == 0 || protected == 0 {
+		t.Errorf("Expected expired and password-protected links, got %d expired, %d protected", expired, protected)
+	}
+
+	// Re-running skips existing projects
+	again, err := SeedDemoData(2)
+	if err != nil {
+		t.Fatalf("Second SeedDemoData failed: %v", err)
+	}
+	if len(again.Projects) != 0 {
+		t.Errorf("Expected existing projects to be skipped, got %v", again.Projects)
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SelectedPhoto is one row of a link's selection report
+type SelectedPhoto struct {
+	PhotoID    uint      `json:"photo_id"`
+	BaseName   string    `json:"base_name"`
+	FileName   string    `json:"file_name"`          // the JPEG, or the RAW file of a RAW-only photo
+	RawName    string    `json:"raw_name,omitempty"` // the RAW file, if any
+	SelectedAt time.Time `json:"selected_at"`
+	Hidden     bool      `json:"hidden,omitempty"` // excluded or out of the date range since it was picked
+}
+
+// SelectedPhotoIDs returns the photos a visitor picked on a link, in the order they
+// were picked. Photos the link no longer shows are left out.
+func SelectedPhotoIDs(link *models.ShareLink) ([]uint, error) {
+	var ids []uint
+	if err := database.DB.Model(&models.PhotoSelection{}).Where("link_id = ?", link.ID).
+		Order("created_at, id").Pluck("photo_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	visible, err := common.VisiblePhotoIDs(link, ids)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[uint]bool, len(visible))
+	for _, id := range visible {
+		keep[id] = true
+	}
+	selected := make([]uint, 0, len(visible))
+	for _, id := range ids {
+		if keep[id] {
+			selected = append(selected, id)
+		}
+	}
+	return selected, nil
+}
+
+// ErrNothingSelected is returned when submitting a selection without photos
+var ErrNothingSelected = errors.New("no photos are selected")
+
+// SubmitSelections records that the client has finished picking on a link and returns
+// how many photos they picked. Clients may submit again after changing their picks.
+func SubmitSelections(link *models.ShareLink, now time.Time) (int, error) {
+	ids, err := SelectedPhotoIDs(link)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, ErrNothingSelected
+	}
+	if err := database.DB.Model(link).UpdateColumn("submitted_at", now).Error; err != nil {
+		return 0, err
+	}
+	link.SubmittedAt = &now
+	return len(ids), nil
+}
+
+// UpdateSelections adds and removes photos from a link's selection in one transaction.
+// Only photos the link shows can be added; others are skipped, as are photos already
+// selected, which keep their original selection time.
+func UpdateSelections(link *models.ShareLink, add, remove []uint) error {
+	add, err := common.VisiblePhotoIDs(link, add)
+	if err != nil {
+		return err
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(remove) > 0 {
+			if err := tx.Where("link_id = ? AND photo_id IN ?", link.ID, remove).Delete(&models.PhotoSelection{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(add) == 0 {
+			return nil
+		}
+		rows := make([]models.PhotoSelection, len(add))
+		for i, photoID := range add {
+			rows[i] = models.PhotoSelection{LinkID: link.ID, PhotoID: photoID}
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+	})
+}
+
+// SelectionReport lists every photo picked on a link for the photographer, in the order
+// they were picked, including photos the link has hidden since
+func SelectionReport(link *models.ShareLink) ([]SelectedPhoto, error) {
+	var selections []models.PhotoSelection
+	if err := database.DB.Where("link_id = ?", link.ID).Order("created_at, id").Find(&selections).Error; err != nil {
+		return nil, err
+	}
+	photoIDs := make([]uint, len(selections))
+	for i, s := range selections {
+		photoIDs[i] = s.PhotoID
+	}
+
+	var photos []models.Photo
+	if len(photoIDs) > 0 {
+		if err := database.DB.Select(common.PhotoMetaColumns).Where("id IN ?", photoIDs).Find(&photos).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]*models.Photo, len(photos))
+	for i := range photos {
+		byID[photos[i].ID] = &photos[i]
+	}
+	visible, err := common.VisiblePhotoIDs(link, photoIDs)
+	if err != nil {
+		return nil, err
+	}
+	shown := make(map[uint]bool, len(visible))
+	for _, id := range visible {
+		shown[id] = true
+	}
+
+	report := make([]SelectedPhoto, 0, len(selections))
+	for _, s := range selections {
+		photo, ok := byID[s.PhotoID]
+		if !ok {
+			continue // Photos in the trash keep their selections until purged
+		}
+		row := SelectedPhoto{PhotoID: photo.ID, BaseName: photo.BaseName, SelectedAt: s.CreatedAt, Hidden: !shown[photo.ID]}
+		if photo.HasRaw && photo.RawExt != "" {
+			row.RawName = photo.BaseName + photo.RawExt
+		}
+		if photo.NormalExt != "" {
+			row.FileName = photo.BaseName + photo.NormalExt
+		} else {
+			row.FileName = row.RawName
+		}
+		report = append(report, row)
+	}
+	return report, nil
+}
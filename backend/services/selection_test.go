@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupSelectionTest(t *testing.T) (*models.ShareLink, []models.Photo) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.Photo{}, &models.ShareLink{}, &models.PhotoExclusion{}, &models.PhotoSelection{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	photos := []models.Photo{
+		{ProjectID: 1, BaseName: "IMG_1", NormalExt: ".jpg"},
+		{ProjectID: 1, BaseName: "IMG_2", NormalExt: ".jpg", RawExt: ".ARW", HasRaw: true},
+		{ProjectID: 1, BaseName: "IMG_3", RawExt: ".CR3", HasRaw: true},
+		{ProjectID: 2, BaseName: "OTHER", NormalExt: ".jpg"},
+	}
+	database.DB.Create(&photos)
+	link := models.ShareLink{ProjectID: 1, Token: "proof", Preferences: models.LinkPreferences{Proofing: true}}
+	database.DB.Create(&link)
+	return &link, photos
+}
+
+func TestUpdateSelections(t *testing.T) {
+	link, photos := setupSelectionTest(t)
+	database.DB.Create(&models.PhotoExclusion{LinkID: link.ID, PhotoID: photos[2].ID})
+
+	// Excluded photos and photos of other projects can't be picked
+	add := []uint{photos[1].ID, photos[0].ID, photos[2].ID, photos[3].ID, photos[1].ID}
+	if err := UpdateSelections(link, add, nil); err != nil {
+		t.Fatalf("UpdateSelections() = %v", err)
+	}
+	// Picking again is a no-op
+	if err := UpdateSelections(link, []uint{photos[0].ID}, nil); err != nil {
+		t.Fatalf("UpdateSelections() again = %v", err)
+	}
+	ids, err := SelectedPhotoIDs(link)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("Expected 2 selected photos, got %v (%v)", ids, err)
+	}
+
+	if err := UpdateSelections(link, nil, []uint{photos[1].ID}); err != nil {
+		t.Fatalf("UpdateSelections() remove = %v", err)
+	}
+	ids, _ = SelectedPhotoIDs(link)
+	if len(ids) != 1 || ids[0] != photos[0].ID {
+		t.Errorf("Expected only %d to stay selected, got %v", photos[0].ID, ids)
+	}
+}
+
+func TestSelectionReport(t *testing.T) {
+	link, photos := setupSelectionTest(t)
+	if err := UpdateSelections(link, []uint{photos[1].ID, photos[2].ID}, nil); err != nil {
+		t.Fatalf("UpdateSelections() = %v", err)
+	}
+	// Hiding a picked photo later keeps it in the report but not for visitors
+	database.DB.Create(&models.PhotoExclusion{LinkID: link.ID, PhotoID: photos[1].ID})
+
+	ids, _ := SelectedPhotoIDs(link)
+	if len(ids) != 1 || ids[0] != photos[2].ID {
+		t.Errorf("Expected visitors to see only %d, got %v", photos[2].ID, ids)
+	}
+
+	report, err := SelectionReport(link)
+	if err != nil || len(report) != 2 {
+		t.Fatalf("Expected 2 report rows, got %+v (%v)", report, err)
+	}
+	byID := map[uint]SelectedPhoto{report[0].PhotoID: report[0], report[1].PhotoID: report[1]}
+	if row := byID[photos[1].ID]; row.FileName != "IMG_2.jpg" || row.RawName != "IMG_2.ARW" || !row.Hidden {
+		t.Errorf("Unexpected row for IMG_2: %+v", row)
+	}
+	if row := byID[photos[2].ID]; row.FileName != "IMG_3.CR3" || row.Hidden {
+		t.Errorf("Unexpected row for RAW-only IMG_3: %+v", row)
+	}
+}
+
+func TestSubmitSelections(t *testing.T) {
+	link, photos := setupSelectionTest(t)
+	now := time.Now()
+
+	if _, err := SubmitSelections(link, now); !errors.Is(err, ErrNothingSelected) {
+		t.Errorf("Expected ErrNothingSelected, got %v", err)
+	}
+	UpdateSelections(link, []uint{photos[0].ID, photos[1].ID}, nil)
+	count, err := SubmitSelections(link, now)
+	if err != nil || count != 2 {
+		t.Fatalf("SubmitSelections() = %d, %v", count, err)
+	}
+	var stored models.ShareLink
+	database.DB.First(&stored, link.ID)
+	if stored.SubmittedAt == nil || !stored.SubmittedAt.Equal(now) {
+		t.Errorf("Expected submitted_at %v, got %v", now, stored.SubmittedAt)
+	}
+	if stored.Version != link.Version {
+		t.Errorf("Expected submitting not to change the link version, got %d", stored.Version)
+	}
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// ReadSetting returns the value of a setting, "" if it was never written
+func ReadSetting(name string) (string, error) {
+	var setting models.Setting
+	if err := database.DB.Where("name = ?", name).Limit(1).Find(&setting).Error; err != nil {
+		return "", err
+	}
+	return setting.Value, nil
+}
+
+// WriteSetting creates or replaces a setting
+func WriteSetting(name, value string) error {
+	setting := models.Setting{Name: name, Value: value, UpdatedAt: time.Now()}
+	return database.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&setting).Error
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"photobridge/common"
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+	"photobridge/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	shareCardShortname   = "[ShareCard]"
+	shareCardQueueLength = 256
+)
+
+var (
+	shareCardQueue   chan uint
+	shareCardPending sync.Map // Link IDs queued but not yet rendered
+	shareCardMu      sync.Mutex
+)
+
+// ShareCardPath returns where the cached share card of a link is stored
+func ShareCardPath(token string) string {
+	return filepath.Join(filepath.Dir(config.AppConfig.DatabasePath), "sharecards", token+".jpg")
+}
+
+// StartShareCardWorker starts the background worker that renders share cards
+func StartShareCardWorker() {
+	shareCardQueue = make(chan uint, shareCardQueueLength)
+	go func() {
+		for linkID := range shareCardQueue {
+			shareCardPending.Delete(linkID)
+
+			var link models.ShareLink
+			if err := database.DB.Preload("Project").Preload("Exclusions").Preload("Highlights").
+				First(&link, linkID).Error; err != nil {
+				continue // Link deleted in the meantime
+			}
+			if _, err := GenerateShareCard(&link); err != nil {
+				log.Printf("%s Failed to render card for link %d: %v", shareCardShortname, linkID, err)
+			}
+		}
+	}()
+	log.Printf("%s Worker started", shareCardShortname)
+}
+
+// EnqueueShareCard schedules a (re)render of a link's share card.
+// Duplicate requests for a queued link are dropped; a full queue is rendered lazily on first request.
+func EnqueueShareCard(linkID uint) {
+	if shareCardQueue == nil {
+		return
+	}
+	if _, queued := shareCardPending.LoadOrStore(linkID, true); queued {
+		return
+	}
+	select {
+	case shareCardQueue <- linkID:
+	default:
+		shareCardPending.Delete(linkID)
+		RemoveShareCardByID(linkID)
+	}
+}
+
+// EnqueueProjectShareCards re-renders the cards of every link of a project (after a rename or cover change)
+func EnqueueProjectShareCards(projectID uint) {
+	var linkIDs []uint
+	if err := database.DB.Model(&models.ShareLink{}).Where("project_id = ?", projectID).Pluck("id", &linkIDs).Error; err != nil {
+		log.Printf("%s Failed to list links of project %d: %v", shareCardShortname, projectID, err)
+		return
+	}
+	for _, id := range linkIDs {
+		EnqueueShareCard(id)
+	}
+}
+
+// RemoveShareCard deletes the cached card of a link
+func RemoveShareCard(token string) {
+	if err := os.Remove(ShareCardPath(token)); err != nil && !os.IsNotExist(err) {
+		log.Printf("%s Failed to remove card for %s: %v", shareCardShortname, token, err)
+	}
+}
+
+// RemoveProjectShareCards deletes the cached cards of all links of a project
+func RemoveProjectShareCards(projectID uint) {
+	var tokens []string
+	database.DB.Model(&models.ShareLink{}).Where("project_id = ?", projectID).Pluck("token", &tokens)
+	for _, token := range tokens {
+		RemoveShareCard(token)
+	}
+}
+
+// RemoveShareCardByID deletes the cached card so a stale image is never served
+func RemoveShareCardByID(linkID uint) {
+	var link models.ShareLink
+	if err := database.DB.Unscoped().Select("token").First(&link, linkID).Error; err == nil {
+		RemoveShareCard(link.Token)
+	}
+}
+
+// GenerateShareCard renders the card of a link and stores it on disk.
+// The link must have Project, Exclusions and Highlights loaded.
+func GenerateShareCard(link *models.ShareLink) (string, error) {
+	coverPath := ""
+	if !link.PasswordEnabled {
+		// Password-protected galleries get a text-only card so no photo leaks through the preview
+		var release func()
+		coverPath, release = shareCardCoverPath(link)
+		defer release()
+	}
+
+	title := link.Project.Name
+	subtitle := link.Alias
+	if count := countVisiblePhotos(link); count > 0 {
+		if subtitle != "" {
+			subtitle += " · "
+		}
+		subtitle += fmt.Sprintf("%d photos", count)
+	}
+
+	data, err := utils.ComposeShareCard(coverPath, title, subtitle, config.AppConfig.ShareCardFont)
+	if err != nil {
+		return "", err
+	}
+
+	path := ShareCardPath(link.Token)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create share card directory: %w", err)
+	}
+
+	// Write to a temp file and rename so readers never see a partial image
+	shareCardMu.Lock()
+	defer shareCardMu.Unlock()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write share card: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to save share card: %w", err)
+	}
+	return path, nil
+}
+
+// shareCardCoverPath picks the image for a card: the first highlight, then the
+// project cover, then the first photo. Photos excluded from the link are never used.
+// Call release when the file has been read.
+func shareCardCoverPath(link *models.ShareLink) (string, func()) {
+	excludedIDs := common.GetExcludedIDs(link.Exclusions)
+	var candidates []models.Photo
+
+	if ids := common.FilterDateRange(link, common.GetHighlightIDs(link.Highlights, excludedIDs)); len(ids) > 0 {
+		var photo models.Photo
+		if database.DB.Select("id, base_name, normal_ext").First(&photo, ids[0]).Error == nil {
+			candidates = append(candidates, photo)
+		}
+	}
+
+	query := database.DB.Select("id, base_name, normal_ext").
+		Where("project_id = ? AND normal_ext <> ''", link.ProjectID)
+	if len(excludedIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludedIDs)
+	}
+	query = common.ApplyDateRange(query, link).Session(&gorm.Session{})
+	if cover := link.Project.CoverPhoto; cover != "" {
+		var photo models.Photo
+		ext := filepath.Ext(cover)
+		if query.Where("base_name = ? AND normal_ext = ?", strings.TrimSuffix(cover, ext), ext).First(&photo).Error == nil {
+			candidates = append(candidates, photo)
+		}
+	}
+	var first models.Photo
+	if query.Order("id").First(&first).Error == nil {
+		candidates = append(candidates, first)
+	}
+
+	for _, photo := range candidates {
+		path, release, err := storage.Fetch(context.Background(), storage.Key(link.Project.Name, photo.BaseName+photo.NormalExt))
+		if err == nil {
+			return path, release
+		}
+	}
+	return "", func() {}
+}
+
+func countVisiblePhotos(link *models.ShareLink) int64 {
+	var count int64
+	query := database.DB.Model(&models.Photo{}).Where("project_id = ?", link.ProjectID)
+	if excludedIDs := common.GetExcludedIDs(link.Exclusions); len(excludedIDs) > 0 {
+		query = query.Where("id NOT IN ?", excludedIDs)
+	}
+	common.ApplyDateRange(query, link).Count(&count)
+	return count
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+// flightGroup coalesces concurrent calls with the same key into one execution,
+// like golang.org/x/sync/singleflight. Results are shared, not cached.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[uint]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// Do runs fn once for all concurrent callers with the same key.
+// shared reports whether the result was handed to more than one caller.
+func (g *flightGroup[T]) Do(key uint, fn func() (T, error)) (value T, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[uint]*flightCall[T])
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.value, call.err, true
+	}
+	call := &flightCall[T]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
+
+// ThumbLookup is a photo loaded for serving its thumbnail and the thumbnail state
+type ThumbLookup struct {
+	Photo  *models.Photo
+	Status string // ThumbStatusReady, ThumbStatusRawOnly, ThumbStatusQueued or ThumbStatusUnavailable (queue stopped or full)
+}
+
+// thumbLookups coalesces thumbnail requests, so a burst of gallery visitors hitting
+// one ungenerated thumbnail costs one query and one enqueue instead of one each
+var thumbLookups flightGroup[*ThumbLookup]
+
+// LookupThumbnail loads a photo (without thumbnail blobs), checks its thumbnail files
+// and enqueues generation if they are missing. Concurrent calls for the same photo share one lookup; the returned
+// photo is shared between callers and must not be modified.
+func (q *ThumbQueue) LookupThumbnail(photoID uint) (*ThumbLookup, error) {
+	lookup, err, _ := thumbLookups.Do(photoID, func() (*ThumbLookup, error) {
+		var photo models.Photo
+		if err := database.DB.Select(common.PhotoMetaColumns).First(&photo, photoID).Error; err != nil {
+			return nil, err
+		}
+		lookup := &ThumbLookup{Photo: &photo}
+		switch {
+		case photo.NormalExt == "" && !CanPreviewRaw(&photo):
+			lookup.Status = ThumbStatusRawOnly
+		case HasThumbnails(&photo), photo.ThumbWidth > 0 && movedThumbnailBlobs(photo.ID):
+			lookup.Status = ThumbStatusReady
+			q.refreshOutdated(&photo)
+		case q == nil || !q.IsRunning():
+			lookup.Status = ThumbStatusUnavailable
+		default:
+			var project models.Project
+			if err := database.DB.First(&project, photo.ProjectID).Error; err != nil {
+				return nil, err
+			}
+			if q.Enqueue(&photo, project.Name) || q.IsProcessing(photo.ID) {
+				lookup.Status = ThumbStatusQueued
+			} else {
+				lookup.Status = ThumbStatusUnavailable
+			}
+		}
+		return lookup, nil
+	})
+	return lookup, err
+}
+
+// refreshOutdated queues thumbnails generated with other thumbnail settings for
+// regeneration in the background; the outdated ones are served meanwhile
+func (q *ThumbQueue) refreshOutdated(photo *models.Photo) {
+	if q == nil || !q.IsRunning() || utils.ThumbParamsCurrent(photo.ThumbParams) || q.IsProcessing(photo.ID) {
+		return
+	}
+	var project models.Project
+	if err := database.DB.Select("id, name").First(&project, photo.ProjectID).Error; err != nil {
+		return
+	}
+	q.EnqueueBackground(photo, project.Name)
+}
+
+// movedThumbnailBlobs moves a photo's thumbnails from the database to files, if it
+// still has them there (photos from before the file store)
+func movedThumbnailBlobs(photoID uint) bool {
+	moved, err := moveThumbnailBlobs(photoID)
+	if err != nil {
+		log.Printf("%s Failed to move thumbnails of photo %d out of the database: %v", shortname, photoID, err)
+	}
+	return moved
+}
+
+// WaitForThumbnail blocks until the queued generation of a photo's thumbnail has
+// finished (successfully or not) or ctx is done. Returns false on ctx expiry.
+func (q *ThumbQueue) WaitForThumbnail(ctx context.Context, photoID uint) bool {
+	q.waitersMu.Lock()
+	if q.waiters == nil {
+		q.waiters = make(map[uint]chan struct{})
+	}
+	done, ok := q.waiters[photoID]
+	if !ok {
+		done = make(chan struct{})
+		q.waiters[photoID] = done
+	}
+	q.waitersMu.Unlock()
+
+	// Registered before checking, so a task finishing now still closes done
+	if !q.IsProcessing(photoID) {
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// finishTask marks a photo as no longer queued and wakes WaitForThumbnail callers
+func (q *ThumbQueue) finishTask(photoID uint) {
+	q.tasksMu.Lock()
+	delete(q.active, photoID)
+	q.tasksMu.Unlock()
+	q.processing.Delete(photoID)
+
+	q.waitersMu.Lock()
+	if done, ok := q.waiters[photoID]; ok {
+		close(done)
+		delete(q.waiters, photoID)
+	}
+	q.waitersMu.Unlock()
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupCoalesces(t *testing.T) {
+	var g flightGroup[int]
+	var calls int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = g.Do(7, func() (int, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return 42, nil
+			})
+		}(i)
+	}
+	// Let the callers pile up on the first execution
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("fn ran %d times, expected 1", calls)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("caller %d got %d, expected 42", i, v)
+		}
+	}
+
+	// Results are not cached once the call has finished
+	v, _, shared := g.Do(7, func() (int, error) { return 1, nil })
+	if v != 1 || shared {
+		t.Errorf("Later call should run again, got %d (shared=%v)", v, shared)
+	}
+}
+
+func TestLookupThumbnailEnqueuesOnce(t *testing.T) {
+	setupProjectTest(t)
+	q := createTestQueue()
+
+	lookup, err := q.LookupThumbnail(1)
+	if err != nil {
+		t.Fatalf("LookupThumbnail failed: %v", err)
+	}
+	if lookup.Status != ThumbStatusQueued || lookup.Photo.BaseName != "IMG_0001" {
+		t.Errorf("Unexpected lookup: status=%s photo=%s", lookup.Status, lookup.Photo.BaseName)
+	}
+	if _, err := q.LookupThumbnail(1); err != nil || q.QueueLength() != 1 {
+		t.Errorf("Repeated lookups should not enqueue again, queue length %d", q.QueueLength())
+	}
+
+	if _, err := q.LookupThumbnail(999); err == nil {
+		t.Error("Lookup of a missing photo should fail")
+	}
+
+	var nilQueue *ThumbQueue
+	if lookup, err := nilQueue.LookupThumbnail(1); err != nil || lookup.Status != ThumbStatusUnavailable {
+		t.Errorf("Without a queue the thumbnail should be unavailable, got %+v, %v", lookup, err)
+	}
+}
+
+func TestWaitForThumbnail(t *testing.T) {
+	q := createTestQueue()
+
+	// Nothing queued: returns immediately
+	if !q.WaitForThumbnail(context.Background(), 1) {
+		t.Error("Wait for a photo that is not queued should return true")
+	}
+
+	q.processing.Store(uint(1), true)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		q.finishTask(1)
+	}()
+	if !q.WaitForThumbnail(context.Background(), 1) {
+		t.Error("Wait should return true once the task finishes")
+	}
+
+	q.processing.Store(uint(2), true)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if q.WaitForThumbnail(ctx, 2) {
+		t.Error("Wait should return false when the context expires first")
+	}
+}
@@ -1,16 +1,16 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"log"
-	"path/filepath"
 	"runtime/debug"
 	"sync"
 	"time"
 
-	"photobridge/config"
 	"photobridge/database"
 	"photobridge/models"
+	"photobridge/storage"
 	"photobridge/utils"
 )
 
@@ -21,20 +21,39 @@ const (
 
 var ErrThumbnailTimeout = errors.New("thumbnail generation timeout")
 
+// Thumbnail states reported in upload responses
+const (
+	ThumbStatusReady       = "ready"       // Thumbnail already generated (e.g. duplicate upload)
+	ThumbStatusQueued      = "queued"      // Queued or being generated
+	ThumbStatusRawOnly     = "raw_only"    // No normal image or RAW preview to generate from, a placeholder is served
+	ThumbStatusUnavailable = "unavailable" // Queue stopped or full; generated on first view instead
+	ThumbStatusPending     = "pending"     // Not generated yet and not queued; generated on first view
+)
+
 // ThumbTask represents a thumbnail generation task (only stores path info, not image data)
 type ThumbTask struct {
 	PhotoID     uint
+	ProjectID   uint
 	ProjectName string
 	BaseName    string
 	NormalExt   string
+	RawExt      string // Set for RAW-only photos, whose embedded preview is used
+	RawHash     string
 }
 
 // ThumbQueue manages thumbnail generation with an unbounded queue
 type ThumbQueue struct {
+	priority   []ThumbTask // Thumbnails the admin panel waits for, taken first (see Prioritize)
 	tasks      []ThumbTask
+	background []ThumbTask        // Low-priority tasks, taken only when tasks is empty (see EnqueueBackground)
+	active     map[uint]time.Time // Photos being generated, with their start
 	tasksMu    sync.Mutex
 	cond       *sync.Cond
-	processing sync.Map // Track which photos are being processed or queued
+	processing sync.Map               // Track which photos are being processed or queued
+	waiters    map[uint]chan struct{} // Closed when a photo's task finishes (see WaitForThumbnail)
+	waitersMu  sync.Mutex
+	failures   map[uint]ThumbFailure // Last failed generation per photo (see Status)
+	failuresMu sync.Mutex
 	workers    int
 	jobTimeout time.Duration
 	running    bool
@@ -85,18 +104,33 @@ func (q *ThumbQueue) worker(id int) {
 	for {
 		// Get next task
 		q.tasksMu.Lock()
-		for len(q.tasks) == 0 && q.running {
+		for q.waiting() == 0 && q.running {
 			q.cond.Wait()
 		}
 
-		if !q.running && len(q.tasks) == 0 {
+		if !q.running && q.waiting() == 0 {
 			q.tasksMu.Unlock()
 			break
 		}
 
-		// Pop task from front
-		task := q.tasks[0]
-		q.tasks = q.tasks[1:]
+		// Pop task from front: admin panel requests first, background tasks only when
+		// nothing else waits
+		var task ThumbTask
+		switch {
+		case len(q.priority) > 0:
+			task = q.priority[0]
+			q.priority = q.priority[1:]
+		case len(q.tasks) > 0:
+			task = q.tasks[0]
+			q.tasks = q.tasks[1:]
+		default:
+			task = q.background[0]
+			q.background = q.background[1:]
+		}
+		if q.active == nil {
+			q.active = make(map[uint]time.Time)
+		}
+		q.active[task.PhotoID] = time.Now()
 		q.tasksMu.Unlock()
 
 		// Process task
@@ -112,7 +146,7 @@ func (q *ThumbQueue) processTaskSafely(task ThumbTask, workerID int) {
 		if r := recover(); r != nil {
 			log.Printf("%s Worker %d panic while processing photo %d: %v\n%s",
 				shortname, workerID, task.PhotoID, r, string(debug.Stack()))
-			q.processing.Delete(task.PhotoID)
+			q.finishTask(task.PhotoID)
 		}
 	}()
 	q.processTask(task)
@@ -120,89 +154,129 @@ func (q *ThumbQueue) processTaskSafely(task ThumbTask, workerID int) {
 
 // processTask generates thumbnails for a single photo from file path
 func (q *ThumbQueue) processTask(task ThumbTask) {
-	defer q.processing.Delete(task.PhotoID)
+	defer q.finishTask(task.PhotoID)
 
-	if task.NormalExt == "" {
-		return // Only RAW, skip
+	sourceExt, generate := task.NormalExt, utils.GenerateThumbnails
+	if sourceExt == "" {
+		sourceExt, generate = task.RawExt, utils.GenerateRawThumbnails
+	}
+	if sourceExt == "" {
+		return
 	}
 
 	// Validate project name for path safety
 	if !utils.ValidatePathComponent(task.ProjectName) {
 		log.Printf("%s Invalid project name for photo %d: %s", shortname, task.PhotoID, task.ProjectName)
+		q.recordFailure(task, errors.New("invalid project name"))
 		return
 	}
 
-	// Generate thumbnail from file path (not from memory)
-	imagePath := filepath.Join(config.AppConfig.UploadDir, task.ProjectName, task.BaseName+task.NormalExt)
-
-	// Validate the image path is secure
-	safeImagePath, err := utils.ValidateSecurePath(config.AppConfig.UploadDir, imagePath)
+	// Generate thumbnail from file path (not from memory); originals in object
+	// storage are downloaded to a temporary file first
+	safeImagePath, release, err := storage.Fetch(context.Background(), storage.Key(task.ProjectName, task.BaseName+sourceExt))
 	if err != nil {
-		log.Printf("%s Invalid file path for photo %d: %v", shortname, task.PhotoID, err)
+		log.Printf("%s Failed to read original of photo %d: %v", shortname, task.PhotoID, err)
+		q.recordFailure(task, err)
+		return
+	}
+	defer release()
+
+	// The job timeout cancels generation between its stages, releasing the decoded image
+	ctx := context.Background()
+	if q.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.jobTimeout)
+		defer cancel()
+	}
+	thumbResult, err := generate(ctx, safeImagePath)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = ErrThumbnailTimeout
+	}
+	if errors.Is(err, utils.ErrNoRawPreview) {
+		rawPreviewFailures.Store(task.PhotoID, task.RawHash)
+		log.Printf("%s No embedded preview in RAW of photo %d, keeping its placeholder", shortname, task.PhotoID)
+		// Not a failure to retry: the placeholder is final until the RAW file changes
+		PublishProjectEvent(task.ProjectID, ProjectEvent{Type: EventThumbnailFailed, PhotoID: task.PhotoID, Error: err.Error()})
 		return
 	}
-
-	thumbResult, err := q.generateWithTimeout(safeImagePath)
 	if err != nil {
 		log.Printf("%s Failed to generate thumbnail for photo %d (%s): %v", shortname, task.PhotoID, safeImagePath, err)
+		q.recordFailure(task, err)
+		return
+	}
+
+	if err := SaveThumbnails(task.ProjectID, task.PhotoID, thumbResult.Small, thumbResult.Large); err != nil {
+		log.Printf("%s Failed to write thumbnail for photo %d: %v", shortname, task.PhotoID, err)
+		q.recordFailure(task, err)
 		return
 	}
 
-	// Update database
+	// Update database (clearing thumbnails stored there before the file store)
 	if err := database.DB.Model(&models.Photo{}).Where("id = ?", task.PhotoID).Updates(map[string]interface{}{
-		"thumb_small":  thumbResult.Small,
-		"thumb_large":  thumbResult.Large,
+		"thumb_small":  nil,
+		"thumb_large":  nil,
 		"thumb_width":  thumbResult.Width,
 		"thumb_height": thumbResult.Height,
+		"thumb_params": thumbResult.Params,
+		"width":        thumbResult.Width,
+		"height":       thumbResult.Height,
 	}).Error; err != nil {
 		log.Printf("%s Failed to save thumbnail for photo %d: %v", shortname, task.PhotoID, err)
+		q.recordFailure(task, err)
 		return
 	}
 
-	log.Printf("%s Generated thumbnail for photo %d", shortname, task.PhotoID)
-}
-
-func (q *ThumbQueue) generateWithTimeout(imagePath string) (*utils.ThumbnailResult, error) {
-	if q.jobTimeout <= 0 {
-		return utils.GenerateThumbnails(imagePath)
+	// Backfill capture time for photos uploaded before it was recorded
+	if takenAt, ok := utils.ReadCaptureTime(safeImagePath); ok {
+		database.DB.Model(&models.Photo{}).Where("id = ? AND taken_at IS NULL", task.PhotoID).Update("taken_at", takenAt)
 	}
 
-	type thumbResult struct {
-		result *utils.ThumbnailResult
-		err    error
-	}
-	done := make(chan thumbResult, 1)
-	go func() {
-		result, err := utils.GenerateThumbnails(imagePath)
-		done <- thumbResult{result: result, err: err}
-	}()
-
-	select {
-	case out := <-done:
-		return out.result, out.err
-	case <-time.After(q.jobTimeout):
-		return nil, ErrThumbnailTimeout
-	}
+	log.Printf("%s Generated thumbnail for photo %d", shortname, task.PhotoID)
+	q.clearFailure(task.PhotoID)
+	// Both sizes come from one decode and are written together
+	PublishProjectEvent(task.ProjectID, ProjectEvent{
+		Type:    EventThumbnailDone,
+		PhotoID: task.PhotoID,
+		Sizes:   []string{ThumbSizeSmall, ThumbSizeLarge},
+	})
 }
 
 // Enqueue adds a thumbnail generation task to the queue
 // Returns true if the task was added, false if it's already queued or processing
 func (q *ThumbQueue) Enqueue(photo *models.Photo, projectName string) bool {
-	if photo.NormalExt == "" {
-		return false // Only RAW, no thumbnail needed
+	return q.enqueue(photo, projectName, false)
+}
+
+// EnqueueBackground adds a low-priority task (e.g. warming after a restore), which
+// workers only take when no regular task waits. A later Enqueue of the same photo
+// moves it to the regular queue.
+func (q *ThumbQueue) EnqueueBackground(photo *models.Photo, projectName string) bool {
+	return q.enqueue(photo, projectName, true)
+}
+
+func (q *ThumbQueue) enqueue(photo *models.Photo, projectName string, background bool) bool {
+	if !canGenerateThumbnail(photo) {
+		return false
 	}
 
 	// Check if already queued or processing
 	if _, loaded := q.processing.LoadOrStore(photo.ID, true); loaded {
+		if !background {
+			q.promote(photo.ID)
+		}
 		return false // Already in queue or processing
 	}
 
 	task := ThumbTask{
 		PhotoID:     photo.ID,
+		ProjectID:   photo.ProjectID,
 		ProjectName: projectName,
 		BaseName:    photo.BaseName,
 		NormalExt:   photo.NormalExt,
 	}
+	if photo.NormalExt == "" {
+		task.RawExt, task.RawHash = photo.RawExt, photo.RawHash
+	}
 
 	q.tasksMu.Lock()
 	// Reject enqueue when queue is stopped to avoid "stuck processing" states.
@@ -213,22 +287,157 @@ func (q *ThumbQueue) Enqueue(photo *models.Photo, projectName string) bool {
 	}
 
 	// Check queue length limit to prevent memory exhaustion
-	if len(q.tasks) >= maxQueueLength {
+	queue := &q.tasks
+	if background {
+		queue = &q.background
+	}
+	if len(*queue) >= maxQueueLength {
 		q.tasksMu.Unlock()
 		q.processing.Delete(photo.ID) // Remove from processing map
-		log.Printf("%s Queue full (%d), rejecting photo %d", shortname, maxQueueLength, photo.ID)
+		if !background {
+			log.Printf("%s Queue full (%d), rejecting photo %d", shortname, maxQueueLength, photo.ID)
+		}
 		return false
 	}
 
-	q.tasks = append(q.tasks, task)
-	queueLen := len(q.tasks)
+	*queue = append(*queue, task)
+	queueLen := len(*queue)
 	q.cond.Signal() // Wake up one worker
 	q.tasksMu.Unlock()
 
-	log.Printf("%s Enqueued photo %d (queue length: %d)", shortname, photo.ID, queueLen)
+	if !background {
+		log.Printf("%s Enqueued photo %d (queue length: %d)", shortname, photo.ID, queueLen)
+	}
+	return true
+}
+
+// canGenerateThumbnail reports whether a photo has an image thumbnails can be generated from
+func canGenerateThumbnail(photo *models.Photo) bool {
+	if photo.NormalExt == "" && !CanPreviewRaw(photo) {
+		return false // Only RAW without a usable preview, a placeholder is served
+	}
+	if utils.IsHEIFPath(photo.NormalExt) && !utils.HEIFConversionEnabled() {
+		return false // HEIC/HEIF can't be decoded without HEIF_CONVERT_COMMAND
+	}
 	return true
 }
 
+// promote moves a photo's background task to the end of the regular queue, so a
+// visitor waiting for it doesn't wait for the whole warm-up
+func (q *ThumbQueue) promote(photoID uint) {
+	q.tasksMu.Lock()
+	defer q.tasksMu.Unlock()
+	if task, ok := takeTask(&q.background, photoID); ok {
+		q.tasks = append(q.tasks, task)
+	}
+}
+
+// Prioritize moves a queued photo to the end of the priority lane, ahead of uploads,
+// visitors and background work, for thumbnails the admin panel is waiting for.
+// Returns false if the photo isn't waiting in the queue.
+func (q *ThumbQueue) Prioritize(photoID uint) bool {
+	if q == nil {
+		return false
+	}
+	q.tasksMu.Lock()
+	defer q.tasksMu.Unlock()
+	for _, task := range q.priority {
+		if task.PhotoID == photoID {
+			return true
+		}
+	}
+	for _, queue := range []*[]ThumbTask{&q.tasks, &q.background} {
+		if task, ok := takeTask(queue, photoID); ok {
+			q.priority = append(q.priority, task)
+			return true
+		}
+	}
+	return false
+}
+
+// takeTask removes a photo's task from a queue
+func takeTask(queue *[]ThumbTask, photoID uint) (ThumbTask, bool) {
+	for i, task := range *queue {
+		if task.PhotoID == photoID {
+			*queue = append((*queue)[:i], (*queue)[i+1:]...)
+			return task, true
+		}
+	}
+	return ThumbTask{}, false
+}
+
+// waiting counts the tasks of all lanes; tasksMu must be held
+func (q *ThumbQueue) waiting() int {
+	return len(q.priority) + len(q.tasks) + len(q.background)
+}
+
+// ThumbnailStatus enqueues a freshly uploaded photo and reports the state of its thumbnail
+func (q *ThumbQueue) ThumbnailStatus(photo *models.Photo, projectName string) string {
+	switch {
+	case photo.NormalExt == "" && !CanPreviewRaw(photo):
+		return ThumbStatusRawOnly
+	case photo.ThumbWidth > 0:
+		return ThumbStatusReady
+	case q == nil:
+		return ThumbStatusUnavailable
+	case q.Enqueue(photo, projectName) || q.IsProcessing(photo.ID):
+		return ThumbStatusQueued
+	default:
+		return ThumbStatusUnavailable
+	}
+}
+
+// CurrentThumbnailStatus reports the state of a photo's thumbnail without enqueueing it
+func (q *ThumbQueue) CurrentThumbnailStatus(photo *models.Photo) string {
+	switch {
+	case photo.NormalExt == "" && !CanPreviewRaw(photo):
+		return ThumbStatusRawOnly
+	case photo.ThumbWidth > 0:
+		return ThumbStatusReady
+	case q != nil && q.IsProcessing(photo.ID):
+		return ThumbStatusQueued
+	default:
+		return ThumbStatusPending
+	}
+}
+
+// RenameProject points queued tasks of a renamed project at its new directory
+func (q *ThumbQueue) RenameProject(oldName, newName string) {
+	if q == nil {
+		return
+	}
+	q.tasksMu.Lock()
+	defer q.tasksMu.Unlock()
+	for _, tasks := range [][]ThumbTask{q.priority, q.tasks, q.background} {
+		for i := range tasks {
+			if tasks[i].ProjectName == oldName {
+				tasks[i].ProjectName = newName
+			}
+		}
+	}
+}
+
+// MovePhotos points queued tasks of photos moved to another project at its directory
+func (q *ThumbQueue) MovePhotos(photoIDs []uint, projectID uint, projectName string) {
+	if q == nil {
+		return
+	}
+	moved := make(map[uint]bool, len(photoIDs))
+	for _, id := range photoIDs {
+		moved[id] = true
+	}
+	q.tasksMu.Lock()
+	defer q.tasksMu.Unlock()
+	for _, tasks := range [][]ThumbTask{q.priority, q.tasks, q.background} {
+		for i := range tasks {
+			if moved[tasks[i].PhotoID] {
+				tasks[i].ProjectID = projectID
+				tasks[i].ProjectName = projectName
+			}
+		}
+	}
+}
+
 // IsRunning reports whether the queue is accepting and processing tasks.
 func (q *ThumbQueue) IsRunning() bool {
 	q.tasksMu.Lock()
@@ -251,11 +460,11 @@ func (q *ThumbQueue) EnqueueByID(photoID uint) bool {
 	return q.Enqueue(&photo, project.Name)
 }
 
-// QueueLength returns the current number of tasks in the queue
+// QueueLength returns the current number of tasks in the queue, background tasks included
 func (q *ThumbQueue) QueueLength() int {
 	q.tasksMu.Lock()
 	defer q.tasksMu.Unlock()
-	return len(q.tasks)
+	return q.waiting()
 }
 
 // IsProcessing checks if a photo is being processed or queued
@@ -54,7 +54,7 @@ func TestThumbQueueEnqueue(t *testing.T) {
 func TestThumbQueueEnqueueRawOnly(t *testing.T) {
 	q := createTestQueue()
 
-	// RAW-only photo (no NormalExt) should not be enqueued
+	// RAW-only photo (no NormalExt) should not be enqueued without RAW previews
 	photo := &models.Photo{
 		BaseName: "rawfile",
 		RawExt:   ".cr2",
@@ -397,3 +397,78 @@ func TestThumbQueueEmptyNormalExt(t *testing.T) {
 		t.Errorf("Queue should be empty, got %d", q.QueueLength())
 	}
 }
+
+func TestThumbQueueThumbnailStatus(t *testing.T) {
+	q := createTestQueue()
+
+	photo := &models.Photo{BaseName: "test", NormalExt: ".jpg"}
+	photo.ID = 1
+	if status := q.ThumbnailStatus(photo, "test-project"); status != ThumbStatusQueued {
+		t.Errorf("New photo should be queued, got %s", status)
+	}
+	if status := q.ThumbnailStatus(photo, "test-project"); status != ThumbStatusQueued {
+		t.Errorf("Photo already in queue should report queued, got %s", status)
+	}
+
+	ready := &models.Photo{BaseName: "ready", NormalExt: ".jpg", ThumbWidth: 400}
+	ready.ID = 2
+	if status := q.ThumbnailStatus(ready, "test-project"); status != ThumbStatusReady {
+		t.Errorf("Photo with thumbnail should be ready, got %s", status)
+	}
+
+	raw := &models.Photo{BaseName: "raw", RawExt: ".cr2", HasRaw: true}
+	raw.ID = 3
+	if status := q.ThumbnailStatus(raw, "test-project"); status != ThumbStatusRawOnly {
+		t.Errorf("RAW-only photo should report raw_only, got %s", status)
+	}
+
+	q.running = false
+	other := &models.Photo{BaseName: "other", NormalExt: ".jpg"}
+	other.ID = 4
+	if status := q.ThumbnailStatus(other, "test-project"); status != ThumbStatusUnavailable {
+		t.Errorf("Stopped queue should report unavailable, got %s", status)
+	}
+
+	var nilQueue *ThumbQueue
+	if status := nilQueue.ThumbnailStatus(other, "test-project"); status != ThumbStatusUnavailable {
+		t.Errorf("Missing queue should report unavailable, got %s", status)
+	}
+}
+
+func TestThumbQueueCurrentThumbnailStatus(t *testing.T) {
+	q := createTestQueue()
+
+	photo := &models.Photo{BaseName: "test", NormalExt: ".jpg"}
+	photo.ID = 1
+	if status := q.CurrentThumbnailStatus(photo); status != ThumbStatusPending {
+		t.Errorf("Photo without thumbnail should be pending, got %s", status)
+	}
+	if q.QueueLength() != 0 {
+		t.Error("CurrentThumbnailStatus should not enqueue")
+	}
+
+	q.Enqueue(photo, "test-project")
+	if status := q.CurrentThumbnailStatus(photo); status != ThumbStatusQueued {
+		t.Errorf("Enqueued photo should be queued, got %s", status)
+	}
+
+	var nilQueue *ThumbQueue
+	if status := nilQueue.CurrentThumbnailStatus(&models.Photo{NormalExt: ".jpg", ThumbWidth: 400}); status != ThumbStatusReady {
+		t.Errorf("Photo with thumbnail should be ready, got %s", status)
+	}
+	if status := nilQueue.CurrentThumbnailStatus(&models.Photo{RawExt: ".nef", HasRaw: true}); status != ThumbStatusRawOnly {
+		t.Errorf("RAW-only photo should report raw_only, got %s", status)
+	}
+}
+
+func TestThumbQueueRenameProject(t *testing.T) {
+	q := &ThumbQueue{tasks: []ThumbTask{{PhotoID: 1, ProjectName: "old"}, {PhotoID: 2, ProjectName: "other"}}}
+
+	q.RenameProject("old", "new")
+
+	if q.tasks[0].ProjectName != "new" || q.tasks[1].ProjectName != "other" {
+		t.Errorf("Unexpected tasks after rename: %+v", q.tasks)
+	}
+	var nilQueue *ThumbQueue
+	nilQueue.RenameProject("old", "new")
+}
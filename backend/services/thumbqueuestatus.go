@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+// maxThumbFailures bounds the failed generations kept for the admin; the oldest go first
+const maxThumbFailures = 1000
+
+// ThumbFailure is the last failed thumbnail generation of a photo
+type ThumbFailure struct {
+	PhotoID   uint      `json:"photo_id"`
+	ProjectID uint      `json:"project_id"`
+	BaseName  string    `json:"base_name"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"` // Failed generations since the last success
+	FailedAt  time.Time `json:"failed_at"`
+	Queued    bool      `json:"queued"` // Waiting for another attempt
+}
+
+// ThumbQueueStatus is a snapshot of the thumbnail queue for the admin
+type ThumbQueueStatus struct {
+	Running        bool           `json:"running"`
+	Workers        int            `json:"workers"`
+	Active         int            `json:"active"`           // Thumbnails being generated right now
+	ActivePhotoIDs []uint         `json:"active_photo_ids"` // Longest running first
+	Depth          int            `json:"depth"`            // Waiting tasks of all lanes
+	Priority       int            `json:"priority"`         // Waiting for the admin panel
+	Regular        int            `json:"regular"`          // Uploads and visitors
+	Background     int            `json:"background"`       // Backfill, regeneration and scans
+	Failed         []ThumbFailure `json:"failed"`           // Newest first
+}
+
+// ThumbRetryResult counts what RetryFailed did with the failed generations
+type ThumbRetryResult struct {
+	Queued  int `json:"queued"`  // Queued again (or already waiting)
+	Removed int `json:"removed"` // Photos deleted since, forgotten
+	Skipped int `json:"skipped"` // Not queued (queue stopped or full, database error); kept for a later retry
+}
+
+// recordFailure remembers a failed generation for Status and tells the project's
+// event subscribers
+func (q *ThumbQueue) recordFailure(task ThumbTask, err error) {
+	q.failuresMu.Lock()
+	if q.failures == nil {
+		q.failures = make(map[uint]ThumbFailure)
+	}
+	failure := q.failures[task.PhotoID]
+	if failure.Attempts == 0 && len(q.failures) >= maxThumbFailures {
+		q.forgetOldestFailure()
+	}
+	q.failures[task.PhotoID] = ThumbFailure{
+		PhotoID:   task.PhotoID,
+		ProjectID: task.ProjectID,
+		BaseName:  task.BaseName,
+		Error:     err.Error(),
+		Attempts:  failure.Attempts + 1,
+		FailedAt:  time.Now(),
+	}
+	q.failuresMu.Unlock()
+
+	PublishProjectEvent(task.ProjectID, ProjectEvent{Type: EventThumbnailFailed, PhotoID: task.PhotoID, Error: err.Error()})
+}
+
+// forgetOldestFailure makes room for a new failure; failuresMu must be held
+func (q *ThumbQueue) forgetOldestFailure() {
+	var oldest *ThumbFailure
+	for _, failure := range q.failures {
+		if oldest == nil || failure.FailedAt.Before(oldest.FailedAt) {
+			oldest = &failure
+		}
+	}
+	if oldest != nil {
+		delete(q.failures, oldest.PhotoID)
+	}
+}
+
+// clearFailure forgets the failures of a photo once its thumbnails were generated
+func (q *ThumbQueue) clearFailure(photoID uint) {
+	q.failuresMu.Lock()
+	delete(q.failures, photoID)
+	q.failuresMu.Unlock()
+}
+
+// Status reports the depth of each lane, the thumbnails being generated and the
+// failed generations
+func (q *ThumbQueue) Status() ThumbQueueStatus {
+	q.tasksMu.Lock()
+	status := ThumbQueueStatus{
+		Running:        q.running,
+		Workers:        q.workers,
+		Active:         len(q.active),
+		ActivePhotoIDs: make([]uint, 0, len(q.active)),
+		Depth:          q.waiting(),
+		Priority:       len(q.priority),
+		Regular:        len(q.tasks),
+		Background:     len(q.background),
+	}
+	for id := range q.active {
+		status.ActivePhotoIDs = append(status.ActivePhotoIDs, id)
+	}
+	sort.Slice(status.ActivePhotoIDs, func(i, j int) bool {
+		return q.active[status.ActivePhotoIDs[i]].Before(q.active[status.ActivePhotoIDs[j]])
+	})
+	q.tasksMu.Unlock()
+
+	q.failuresMu.Lock()
+	status.Failed = make([]ThumbFailure, 0, len(q.failures))
+	for _, failure := range q.failures {
+		failure.Queued = q.IsProcessing(failure.PhotoID)
+		status.Failed = append(status.Failed, failure)
+	}
+	q.failuresMu.Unlock()
+	sort.Slice(status.Failed, func(i, j int) bool {
+		return status.Failed[i].FailedAt.After(status.Failed[j].FailedAt)
+	})
+	return status
+}
+
+// RetryFailed queues the photos whose generation failed again. They stay listed until
+// they succeed, so a photo failing again shows its attempts adding up.
+func (q *ThumbQueue) RetryFailed() ThumbRetryResult {
+	q.failuresMu.Lock()
+	ids := make([]uint, 0, len(q.failures))
+	for id := range q.failures {
+		ids = append(ids, id)
+	}
+	q.failuresMu.Unlock()
+
+	var result ThumbRetryResult
+	for _, id := range ids {
+		var photo models.Photo
+		var project models.Project
+		err := database.DB.Select(common.PhotoMetaColumns).First(&photo, id).Error
+		if err == nil {
+			err = database.DB.Select("id, name").First(&project, photo.ProjectID).Error
+		}
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			q.clearFailure(id)
+			result.Removed++
+		case err != nil:
+			result.Skipped++
+		case q.Enqueue(&photo, project.Name) || q.IsProcessing(id):
+			result.Queued++
+		default:
+			result.Skipped++
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestThumbQueuePrioritize(t *testing.T) {
+	q := createTestQueue()
+	for id := uint(1); id <= 3; id++ {
+		photo := &models.Photo{BaseName: "p", NormalExt: ".jpg"}
+		photo.ID = id
+		if id == 3 {
+			q.EnqueueBackground(photo, "wedding")
+		} else {
+			q.Enqueue(photo, "wedding")
+		}
+	}
+
+	if !q.Prioritize(2) || !q.Prioritize(3) || !q.Prioritize(2) {
+		t.Fatal("Expected queued photos to be prioritized")
+	}
+	if q.Prioritize(9) {
+		t.Error("Expected a photo that isn't queued not to be prioritized")
+	}
+	if len(q.priority) != 2 || q.priority[0].PhotoID != 2 || q.priority[1].PhotoID != 3 {
+		t.Errorf("Expected photos 2 and 3 in the priority lane, got %+v", q.priority)
+	}
+	status := q.Status()
+	if status.Depth != 3 || status.Priority != 2 || status.Regular != 1 || status.Background != 0 {
+		t.Errorf("Unexpected lanes %+v", status)
+	}
+	var nilQueue *ThumbQueue
+	nilQueue.Prioritize(1)
+}
+
+func TestThumbQueueRetryFailed(t *testing.T) {
+	setupThumbStoreTest(t)
+	database.DB.Create(&models.Project{Name: "wedding"})
+	photos := []models.Photo{
+		{ProjectID: 1, BaseName: "broken", NormalExt: ".jpg"},
+		{ProjectID: 1, BaseName: "deleted", NormalExt: ".jpg"},
+	}
+	database.DB.Create(&photos)
+
+	q := createTestQueue()
+	for _, photo := range photos {
+		q.recordFailure(ThumbTask{PhotoID: photo.ID, ProjectID: 1, BaseName: photo.BaseName}, errors.New("decode failed"))
+	}
+	q.recordFailure(ThumbTask{PhotoID: photos[0].ID, ProjectID: 1, BaseName: "broken"}, errors.New("timeout"))
+	database.DB.Delete(&photos[1])
+
+	status := q.Status()
+	if len(status.Failed) != 2 || status.Failed[0].PhotoID != photos[0].ID || status.Failed[0].Attempts != 2 || status.Failed[0].Error != "timeout" {
+		t.Fatalf("Expected both failures, the latest first with its attempts, got %+v", status.Failed)
+	}
+
+	result := q.RetryFailed()
+	if result.Queued != 1 || result.Removed != 1 || result.Skipped != 0 {
+		t.Errorf("Unexpected retry result %+v", result)
+	}
+	if q.QueueLength() != 1 || q.tasks[0].ProjectName != "wedding" {
+		t.Errorf("Expected the broken photo to be queued again, got %+v", q.tasks)
+	}
+	if failed := q.Status().Failed; len(failed) != 1 || !failed[0].Queued {
+		t.Errorf("Expected the retried photo to stay listed as queued, got %+v", failed)
+	}
+
+	q.clearFailure(photos[0].ID)
+	if failed := q.Status().Failed; len(failed) != 0 {
+		t.Errorf("Expected a success to clear the failure, got %+v", failed)
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"log"
+
+	"photobridge/common"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+// RegenerateResult reports what RegenerateProjectThumbnails did with a project's photos
+type RegenerateResult struct {
+	Photos   int `json:"photos"`   // Photos of the project
+	Cleared  int `json:"cleared"`  // Existing thumbnails removed (force)
+	Queued   int `json:"queued"`   // Queued or already being generated
+	Current  int `json:"current"`  // Kept, their thumbnails exist with the current settings (without force)
+	Skipped  int `json:"skipped"`  // Nothing to generate from (RAW without preview, HEIC without converter)
+	Deferred int `json:"deferred"` // Not queued because the queue is full; generated on first view
+}
+
+// RegenerateProjectThumbnails queues the thumbnails of a project's photos as
+// background tasks. Without force only missing thumbnails and those generated with
+// other thumbnail settings are generated (outdated ones are served until replaced);
+// with force every thumbnail is removed first, e.g. to fix corrupt generations. Removed thumbnails that don't fit in the queue are generated
+// when first viewed.
+func RegenerateProjectThumbnails(q *ThumbQueue, project *models.Project, force bool) (*RegenerateResult, error) {
+	var photos []models.Photo
+	if err := database.DB.Select(common.PhotoMetaColumns).Where("project_id = ?", project.ID).Order("id").Find(&photos).Error; err != nil {
+		return nil, err
+	}
+
+	result := &RegenerateResult{Photos: len(photos)}
+	for i := range photos {
+		photo := &photos[i]
+		if force {
+			if photo.ThumbWidth > 0 || HasThumbnails(photo) {
+				result.Cleared++
+			}
+			if err := clearThumbnails(photo); err != nil {
+				return result, err
+			}
+		} else if photo.ThumbWidth > 0 && (HasThumbnails(photo) || movedThumbnailBlobs(photo.ID)) && utils.ThumbParamsCurrent(photo.ThumbParams) {
+			result.Current++
+			continue
+		}
+
+		switch {
+		case !canGenerateThumbnail(photo):
+			result.Skipped++
+		case q != nil && (q.EnqueueBackground(photo, project.Name) || q.IsProcessing(photo.ID)):
+			result.Queued++
+		default:
+			result.Deferred++
+		}
+	}
+
+	log.Printf("%s Regenerating thumbnails of project %d: %d queued, %d cleared, %d deferred",
+		shortname, project.ID, result.Queued, result.Cleared, result.Deferred)
+	return result, nil
+}
+
+// clearThumbnails removes a photo's thumbnails so they are generated again, and
+// forgets a RAW preview that failed before
+func clearThumbnails(photo *models.Photo) error {
+	RemoveThumbnails(photo.ProjectID, photo.ID)
+	rawPreviewFailures.Delete(photo.ID)
+	err := database.DB.Model(&models.Photo{}).Where("id = ?", photo.ID).Updates(map[string]interface{}{
+		"thumb_small":  nil,
+		"thumb_large":  nil,
+		"thumb_width":  0,
+		"thumb_height": 0,
+	}).Error
+	photo.ThumbWidth, photo.ThumbHeight = 0, 0
+	return err
+}
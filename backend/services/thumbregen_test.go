@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestRegenerateProjectThumbnails(t *testing.T) {
+	setupThumbStoreTest(t)
+	project := &models.Project{Name: "wedding"}
+	database.DB.Create(project)
+	photos := []models.Photo{
+		{ProjectID: project.ID, BaseName: "ready", NormalExt: ".jpg", ThumbWidth: 400},
+		{ProjectID: project.ID, BaseName: "missing", NormalExt: ".jpg"},
+		{ProjectID: project.ID, BaseName: "raw_only", RawExt: ".ARW", HasRaw: true},
+	}
+	database.DB.Create(&photos)
+	SaveThumbnails(project.ID, photos[0].ID, []byte("s"), []byte("l"))
+
+	q := createTestQueue()
+	result, err := RegenerateProjectThumbnails(q, project, false)
+	if err != nil {
+		t.Fatalf("RegenerateProjectThumbnails failed: %v", err)
+	}
+	if *result != (RegenerateResult{Photos: 3, Queued: 1, Current: 1, Skipped: 1}) {
+		t.Errorf("Expected only the missing thumbnail to be queued, got %+v", result)
+	}
+	if len(q.background) != 1 || q.background[0].BaseName != "missing" {
+		t.Errorf("Expected a background task for the missing thumbnail, got %+v", q.background)
+	}
+
+	// Forced: existing thumbnails are removed and queued again
+	result, err = RegenerateProjectThumbnails(q, project, true)
+	if err != nil {
+		t.Fatalf("RegenerateProjectThumbnails failed: %v", err)
+	}
+	if *result != (RegenerateResult{Photos: 3, Cleared: 1, Queued: 2, Skipped: 1}) {
+		t.Errorf("Expected the existing thumbnail to be cleared and queued, got %+v", result)
+	}
+	if HasThumbnails(&photos[0]) {
+		t.Error("Expected the thumbnail files to be removed")
+	}
+	var photo models.Photo
+	database.DB.First(&photo, photos[0].ID)
+	if photo.ThumbWidth != 0 {
+		t.Errorf("Expected the thumbnail size to be cleared, got %d", photo.ThumbWidth)
+	}
+}
+
+func TestRegenerateProjectThumbnailsOutdated(t *testing.T) {
+	setupThumbStoreTest(t)
+	config.AppConfig.ThumbSmallWidth, config.AppConfig.ThumbLargeWidth = 300, 1200
+	config.AppConfig.ThumbSmallQuality, config.AppConfig.ThumbLargeQuality = 75, 85
+	project := &models.Project{Name: "wedding"}
+	database.DB.Create(project)
+	photos := []models.Photo{
+		{ProjectID: project.ID, BaseName: "current", NormalExt: ".jpg", ThumbWidth: 300, ThumbParams: "300q75-1200q85"},
+		{ProjectID: project.ID, BaseName: "outdated", NormalExt: ".jpg", ThumbWidth: 400},
+	}
+	database.DB.Create(&photos)
+	for _, photo := range photos {
+		SaveThumbnails(project.ID, photo.ID, []byte("s"), []byte("l"))
+	}
+
+	q := createTestQueue()
+	result, err := RegenerateProjectThumbnails(q, project, false)
+	if err != nil {
+		t.Fatalf("RegenerateProjectThumbnails failed: %v", err)
+	}
+	if *result != (RegenerateResult{Photos: 2, Queued: 1, Current: 1}) {
+		t.Errorf("Expected the thumbnail with other settings to be queued, got %+v", result)
+	}
+	if len(q.background) != 1 || q.background[0].BaseName != "outdated" {
+		t.Errorf("Expected a background task for the outdated thumbnail, got %+v", q.background)
+	}
+	if !HasThumbnails(&photos[1]) {
+		t.Error("Expected the outdated thumbnail to be served until it is replaced")
+	}
+}
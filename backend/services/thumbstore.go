@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+
+	"gorm.io/gorm"
+)
+
+// Thumbnail sizes, as in the thumbnail URLs
+const (
+	ThumbSizeSmall = "small"
+	ThumbSizeLarge = "large"
+)
+
+// Thumbnails are stored as files under THUMB_DIR, <project ID>/<photo ID>_<size>.jpg.
+// IDs rather than names keep the paths stable across project renames. Photos from
+// before the file store may still have their thumbnails in the thumb_small and
+// thumb_large columns; they are moved out on first use or by MigrateThumbnailBlobs.
+
+// ThumbPath is the file of a photo's thumbnail of the given size
+func ThumbPath(projectID, photoID uint, size string) string {
+	return filepath.Join(config.AppConfig.ThumbDir, strconv.FormatUint(uint64(projectID), 10),
+		fmt.Sprintf("%d_%s.jpg", photoID, size))
+}
+
+// HasThumbnails reports whether both thumbnail files of a photo exist
+func HasThumbnails(photo *models.Photo) bool {
+	for _, size := range []string{ThumbSizeSmall, ThumbSizeLarge} {
+		if _, err := os.Stat(ThumbPath(photo.ProjectID, photo.ID, size)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveThumbnails writes both thumbnail files of a photo. Each file is written to a
+// temporary name first, so readers never see a partial thumbnail.
+func SaveThumbnails(projectID, photoID uint, small, large []byte) error {
+	dir := filepath.Join(config.AppConfig.ThumbDir, strconv.FormatUint(uint64(projectID), 10))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for size, data := range map[string][]byte{ThumbSizeSmall: small, ThumbSizeLarge: large} {
+		f, err := os.CreateTemp(dir, ".thumb-*")
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), ThumbPath(projectID, photoID, size))
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveThumbnails deletes the thumbnail files of a photo (e.g. when its image changed)
+func RemoveThumbnails(projectID, photoID uint) {
+	for _, size := range []string{ThumbSizeSmall, ThumbSizeLarge} {
+		if err := os.Remove(ThumbPath(projectID, photoID, size)); err != nil && !os.IsNotExist(err) {
+			log.Printf("%s Failed to remove thumbnail of photo %d: %v", shortname, photoID, err)
+		}
+	}
+}
+
+// RemoveProjectThumbnails deletes the thumbnail directory of a deleted project
+func RemoveProjectThumbnails(projectID uint) {
+	if err := os.RemoveAll(filepath.Join(config.AppConfig.ThumbDir, strconv.FormatUint(uint64(projectID), 10))); err != nil {
+		log.Printf("%s Failed to remove thumbnails of project %d: %v", shortname, projectID, err)
+	}
+}
+
+// moveThumbnailBlobs writes the thumbnails a photo still has in the database to files
+// and clears the columns. Returns false when the photo had no complete pair of blobs
+// (an incomplete one is cleared; the thumbnails are regenerated instead).
+func moveThumbnailBlobs(photoID uint) (bool, error) {
+	var photo models.Photo
+	if err := database.DB.Select("id, project_id, thumb_small, thumb_large").First(&photo, photoID).Error; err != nil {
+		return false, err
+	}
+	if len(photo.ThumbSmall) == 0 && len(photo.ThumbLarge) == 0 {
+		return false, nil
+	}
+	complete := len(photo.ThumbSmall) > 0 && len(photo.ThumbLarge) > 0
+	if complete {
+		if err := SaveThumbnails(photo.ProjectID, photo.ID, photo.ThumbSmall, photo.ThumbLarge); err != nil {
+			return false, err
+		}
+	}
+	return complete, clearThumbnailBlobs(database.DB.Where("id = ?", photo.ID))
+}
+
+// clearThumbnailBlobs empties the thumbnail columns of the photos matched by query
+func clearThumbnailBlobs(query *gorm.DB) error {
+	return query.Model(&models.Photo{}).Updates(map[string]interface{}{"thumb_small": nil, "thumb_large": nil}).Error
+}
+
+// MigrateThumbnailBlobs moves every thumbnail still stored in the database to the file
+// store, in batches of batchSize photos. Blobs of deleted photos are dropped. Returns
+// the number of photos moved.
+func MigrateThumbnailBlobs(batchSize int) (int, error) {
+	if err := clearThumbnailBlobs(database.DB.Unscoped().Where("deleted_at IS NOT NULL")); err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	var lastID uint
+	for {
+		var ids []uint
+		if err := database.DB.Model(&models.Photo{}).
+			Where("id > ? AND (thumb_small IS NOT NULL OR thumb_large IS NOT NULL)", lastID).
+			Order("id").Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+			return moved, err
+		}
+		if len(ids) == 0 {
+			return moved, nil
+		}
+		for _, id := range ids {
+			ok, err := moveThumbnailBlobs(id)
+			if err != nil {
+				return moved, fmt.Errorf("photo %d: %w", id, err)
+			}
+			if ok {
+				moved++
+			}
+		}
+		lastID = ids[len(ids)-1]
+		log.Printf("%s Moved thumbnails of %d photo(s) to %s", shortname, moved, config.AppConfig.ThumbDir)
+	}
+}
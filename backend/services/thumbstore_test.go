@@ -0,0 +1,52 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupThumbStoreTest creates an in-memory database and an empty THUMB_DIR
+func setupThumbStoreTest(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.Project{}, &models.Photo{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	config.AppConfig = &config.Config{ThumbDir: t.TempDir()}
+}
+
+func TestMigrateThumbnailBlobs(t *testing.T) {
+	setupThumbStoreTest(t)
+	photos := []models.Photo{
+		{ProjectID: 3, BaseName: "a", NormalExt: ".jpg", ThumbWidth: 400, ThumbSmall: []byte("small"), ThumbLarge: []byte("large")},
+		{ProjectID: 3, BaseName: "b", NormalExt: ".jpg", ThumbWidth: 400, ThumbSmall: []byte("small")},
+		{ProjectID: 3, BaseName: "c", NormalExt: ".jpg", ThumbWidth: 400, ThumbSmall: []byte("small"), ThumbLarge: []byte("large")},
+	}
+	database.DB.Create(&photos)
+	database.DB.Delete(&photos[2])
+
+	moved, err := MigrateThumbnailBlobs(1)
+	if err != nil || moved != 1 {
+		t.Fatalf("Expected 1 photo moved, got %d (%v)", moved, err)
+	}
+	if data, _ := os.ReadFile(ThumbPath(3, photos[0].ID, ThumbSizeLarge)); string(data) != "large" {
+		t.Errorf("Expected the large thumbnail file, got %q", data)
+	}
+	var remaining int64
+	database.DB.Unscoped().Model(&models.Photo{}).Where("thumb_small IS NOT NULL OR thumb_large IS NOT NULL").Count(&remaining)
+	if remaining != 0 {
+		t.Errorf("Expected every blob to be cleared, %d left", remaining)
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"log"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+// warmScanBatch is the number of photos checked per query while warming
+const warmScanBatch = 500
+
+// WarmThumbnails queues up to limit photos without thumbnails (newest first) as
+// background tasks, so an instance restored without them regenerates the gallery
+// before visitors trigger it. Thumbnails generated with other thumbnail settings are
+// queued too. Photos whose thumbnails are still in the database
+// are moved to files instead. Returns the number of photos queued.
+func WarmThumbnails(q *ThumbQueue, limit int) int {
+	if q == nil || limit <= 0 {
+		return 0
+	}
+	limit = min(limit, maxQueueLength)
+
+	queued := 0
+	var lastID uint
+	for queued < limit {
+		var rows []struct {
+			models.Photo
+			ProjectName string
+		}
+		query := database.DB.Table("photos").
+			Select("photos.id, photos.project_id, photos.base_name, photos.normal_ext, photos.thumb_width, photos.thumb_params, projects.name AS project_name").
+			Joins("JOIN projects ON projects.id = photos.project_id AND projects.deleted_at IS NULL").
+			Where("photos.deleted_at IS NULL AND photos.normal_ext <> ''")
+		if lastID > 0 {
+			query = query.Where("photos.id < ?", lastID)
+		}
+		if err := query.Order("photos.id DESC").Limit(warmScanBatch).Scan(&rows).Error; err != nil {
+			log.Printf("%s Failed to find photos to warm: %v", shortname, err)
+			break
+		}
+		if len(rows) == 0 {
+			break
+		}
+		lastID = rows[len(rows)-1].ID
+
+		for i := range rows {
+			photo := &rows[i].Photo
+			if photo.ThumbWidth > 0 && (HasThumbnails(photo) || movedThumbnailBlobs(photo.ID)) && utils.ThumbParamsCurrent(photo.ThumbParams) {
+				continue
+			}
+			if q.EnqueueBackground(photo, rows[i].ProjectName) {
+				if queued++; queued == limit {
+					break
+				}
+			}
+		}
+	}
+	if queued > 0 {
+		log.Printf("%s Warming %d missing thumbnail(s) in the background", shortname, queued)
+	}
+	return queued
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+
+	"photobridge/database"
+	"photobridge/models"
+)
+
+func TestWarmThumbnails(t *testing.T) {
+	setupThumbStoreTest(t)
+	database.DB.Create(&models.Project{Name: "wedding"})
+	photos := []models.Photo{
+		{ProjectID: 1, BaseName: "ready", NormalExt: ".jpg", ThumbWidth: 400},
+		{ProjectID: 1, BaseName: "raw_only", RawExt: ".ARW", HasRaw: true},
+		{ProjectID: 1, BaseName: "legacy", NormalExt: ".jpg", ThumbWidth: 400, ThumbSmall: []byte("s"), ThumbLarge: []byte("l")},
+		{ProjectID: 1, BaseName: "restored", NormalExt: ".jpg", ThumbWidth: 400}, // files lost in a restore
+		{ProjectID: 1, BaseName: "new", NormalExt: ".jpg"},
+	}
+	database.DB.Create(&photos)
+	SaveThumbnails(1, photos[0].ID, []byte("s"), []byte("l"))
+
+	q := createTestQueue()
+	if queued := WarmThumbnails(q, 1); queued != 1 {
+		t.Fatalf("Expected the limit to bound warming to 1 photo, got %d", queued)
+	}
+	if len(q.background) != 1 || q.background[0].BaseName != "new" || q.background[0].ProjectName != "wedding" {
+		t.Fatalf("Expected the newest photo to be warmed first, got %+v", q.background)
+	}
+	if queued := WarmThumbnails(q, 10); queued != 1 || q.background[1].BaseName != "restored" {
+		t.Errorf("Expected only the photo with lost files to be queued next, got %d: %+v", queued, q.background)
+	}
+	if !HasThumbnails(&photos[2]) {
+		t.Error("Expected the thumbnails stored in the database to be moved to files")
+	}
+
+	// A visitor asking for a warming photo moves it ahead of the background
+	q.Enqueue(&photos[3], "wedding")
+	if len(q.tasks) != 1 || q.tasks[0].PhotoID != photos[3].ID || len(q.background) != 1 {
+		t.Errorf("Expected the photo to be promoted, got tasks %+v, background %+v", q.tasks, q.background)
+	}
+}
@@ -0,0 +1,489 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+
+	"gorm.io/gorm"
+)
+
+const trashShortname = "[Trash]"
+
+// trashPurgeInterval is how often expired trash items are purged
+const trashPurgeInterval = time.Hour
+
+var (
+	// ErrTrashItemNotFound means the trash has no such item (restored or purged)
+	ErrTrashItemNotFound = errors.New("trash item not found")
+	// ErrProjectInTrash means a photo can't be restored before its project is
+	ErrProjectInTrash = errors.New("the photo's project is in the trash, restore it first")
+	// ErrRestoreConflict means a photo of the same name took the deleted photo's place
+	ErrRestoreConflict = errors.New("the project has a photo or file of the same name")
+)
+
+// trashEnabled reports whether deleted photos and projects go to the trash
+func trashEnabled() bool {
+	return config.AppConfig.TrashRetentionDays > 0
+}
+
+// trashPurgeAt returns when items deleted now are purged
+func trashPurgeAt(now time.Time) time.Time {
+	return now.AddDate(0, 0, config.AppConfig.TrashRetentionDays)
+}
+
+// trashPhotos soft-deletes photos into the trash and moves their originals to the
+// storage trash, so uploads of the same name can't overwrite them. Their exclusions,
+// highlights and selections are kept for a restore. Moves are recorded in moved so a
+// failed transaction can move the files back.
+func trashPhotos(tx *gorm.DB, backend storage.Backend, photos []models.Photo, projects map[uint]*models.Project, moved *[]fileMove) error {
+	purgeAt := trashPurgeAt(time.Now())
+	items := make([]models.TrashItem, len(photos))
+	for i := range photos {
+		photo := &photos[i]
+		items[i] = models.TrashItem{
+			Kind:      models.TrashKindPhoto,
+			ProjectID: photo.ProjectID,
+			PhotoID:   &photo.ID,
+			Name:      photo.BaseName,
+			Files:     strings.Join(photoFileNames(photo), ","),
+			PurgeAt:   purgeAt,
+		}
+	}
+	if err := tx.Where("id IN ?", photoIDs(photos)).Delete(&models.Photo{}).Error; err != nil {
+		return err
+	}
+	if err := tx.CreateInBatches(items, 500).Error; err != nil {
+		return err
+	}
+
+	for i := range photos {
+		photo := &photos[i]
+		project, ok := projects[photo.ProjectID]
+		if !ok {
+			continue
+		}
+		for _, fileName := range photoFileNames(photo) {
+			move := fileMove{from: storage.Key(project.Name, fileName), to: storage.TrashKey(photo.ID, fileName)}
+			if err := moveObject(backend, move); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue // Lost file: nothing to keep
+				}
+				return fmt.Errorf("failed to move %s to the trash: %w", move.from, err)
+			}
+			*moved = append(*moved, move)
+		}
+	}
+	return nil
+}
+
+// DeleteProject deletes an empty project; the caller holds the project's lock and has
+// checked that no photos are left. With the trash enabled the project and its share
+// links are soft-deleted and can be restored; otherwise the links, thumbnails and the
+// project directory are removed right away.
+func DeleteProject(project *models.Project) error {
+	RemoveProjectShareCards(project.ID)
+	RemoveProjectUploadSessions(project.ID)
+
+	if trashEnabled() {
+		now := time.Now()
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// Links get the project's deletion time, which tells them apart on restore
+			if err := tx.Model(&models.ShareLink{}).Where("project_id = ?", project.ID).UpdateColumn("deleted_at", now).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Project{}).Where("id = ?", project.ID).UpdateColumn("deleted_at", now).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.TrashItem{
+				Kind:      models.TrashKindProject,
+				ProjectID: project.ID,
+				Name:      project.Name,
+				PurgeAt:   trashPurgeAt(now),
+			}).Error
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("%s Moved project %s to the trash", trashShortname, project.Name)
+		return nil
+	}
+
+	var linkIDs []uint
+	database.DB.Model(&models.ShareLink{}).Where("project_id = ?", project.ID).Pluck("id", &linkIDs)
+	if len(linkIDs) > 0 {
+		for _, model := range photoLinkModels {
+			database.DB.Where("link_id IN ?", linkIDs).Delete(model)
+		}
+		DeleteLinkStats(database.DB, linkIDs...)
+	}
+	RemoveProjectThumbnails(project.ID)
+	RemoveProjectContactSheets(project.ID)
+	database.DB.Where("project_id = ?", project.ID).Delete(&models.ShareLink{})
+	database.DB.Where("project_id = ?", project.ID).Delete(&models.APIKey{})
+	database.DB.Where("project_id = ?", project.ID).Delete(&models.ProjectDailyStat{})
+	deleteProjectAlbums(database.DB, project.ID)
+	if err := database.DB.Delete(project).Error; err != nil {
+		return err
+	}
+	removeProjectDir(project.Name)
+	return nil
+}
+
+// removeProjectDir deletes the upload directory of a project, if the name is safe
+func removeProjectDir(name string) {
+	dir, err := projectDir(name)
+	if err != nil {
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("%s Failed to remove %s: %v", trashShortname, dir, err)
+	}
+}
+
+// ListTrash returns the items in the trash, newest first; kind filters by photo or
+// project when not empty
+func ListTrash(kind string) ([]models.TrashItem, error) {
+	items := []models.TrashItem{}
+	query := database.DB.Order("created_at DESC, id DESC")
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if err := query.Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	var projectIDs []uint
+	for _, item := range items {
+		if item.Kind == models.TrashKindPhoto {
+			projectIDs = append(projectIDs, item.ProjectID)
+		}
+	}
+	if len(projectIDs) == 0 {
+		return items, nil
+	}
+	var projects []models.Project
+	if err := database.DB.Unscoped().Select("id, name").Where("id IN ?", projectIDs).Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(projects))
+	for _, project := range projects {
+		names[project.ID] = project.Name
+	}
+	for i := range items {
+		if items[i].Kind == models.TrashKindPhoto {
+			items[i].ProjectName = names[items[i].ProjectID]
+		}
+	}
+	return items, nil
+}
+
+// RestoreTrashItem brings a deleted photo or project back
+func RestoreTrashItem(id uint) error {
+	var item models.TrashItem
+	if err := database.DB.First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTrashItemNotFound
+		}
+		return err
+	}
+	unlock := LockProject(item.ProjectID)
+	defer unlock()
+	if item.Kind == models.TrashKindProject {
+		return restoreProject(&item)
+	}
+	return restorePhoto(&item)
+}
+
+// restorePhoto moves a photo's originals back into its project and undeletes it,
+// with the link references it had. The caller holds the project's lock.
+func restorePhoto(item *models.TrashItem) error {
+	var project models.Project
+	if err := database.DB.First(&project, item.ProjectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProjectInTrash
+		}
+		return err
+	}
+	var photo models.Photo
+	if err := database.DB.Unscoped().Select("id, project_id, base_name, normal_ext, raw_ext, has_raw").
+		Where("id = ? AND deleted_at IS NOT NULL", item.PhotoID).First(&photo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTrashItemNotFound
+		}
+		return err
+	}
+
+	backend := storage.Default()
+	var taken int64
+	if err := database.DB.Model(&models.Photo{}).Where("project_id = ? AND base_name = ?", project.ID, photo.BaseName).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 || targetFileExists(backend, project.Name, &photo) {
+		return ErrRestoreConflict
+	}
+
+	var moved []fileMove
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Photo{}).Where("id = ?", photo.ID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(item).Error; err != nil {
+			return err
+		}
+		for _, fileName := range photoFileNames(&photo) {
+			move := fileMove{from: storage.TrashKey(photo.ID, fileName), to: storage.Key(project.Name, fileName)}
+			if err := moveObject(backend, move); err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue // Lost before it was deleted
+				}
+				return fmt.Errorf("failed to restore %s: %w", move.to, err)
+			}
+			moved = append(moved, move)
+		}
+		return nil
+	})
+	if err != nil {
+		revertFileMoves(backend, moved)
+		return err
+	}
+	if _, local := backend.(*storage.Local); !local {
+		var trashKeys []string
+		for _, move := range moved {
+			trashKeys = append(trashKeys, move.from)
+		}
+		deleteObjects(trashKeys)
+	}
+
+	if photo.NormalExt == "" || !SetDefaultCoverPhoto(project.ID, CoverPhotoName(&photo)) {
+		EnqueueProjectShareCards(project.ID)
+	}
+	log.Printf("%s Restored photo %s to project %s", trashShortname, photo.BaseName, project.Name)
+	return nil
+}
+
+// restoreProject undeletes a project with the share links deleted along with it. The
+// caller holds the project's lock.
+func restoreProject(item *models.TrashItem) error {
+	var project models.Project
+	if err := database.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", item.ProjectID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTrashItemNotFound
+		}
+		return err
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.ShareLink{}).Where("project_id = ? AND deleted_at = ?", project.ID, project.DeletedAt.Time).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Project{}).Where("id = ?", project.ID).UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(item).Error
+	})
+	if err != nil {
+		return err
+	}
+	EnqueueProjectShareCards(project.ID)
+	log.Printf("%s Restored project %s", trashShortname, project.Name)
+	return nil
+}
+
+// PurgeTrashItem deletes an item in the trash for good, before its time
+func PurgeTrashItem(id uint) error {
+	var item models.TrashItem
+	if err := database.DB.First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTrashItemNotFound
+		}
+		return err
+	}
+	unlock := LockProject(item.ProjectID)
+	defer unlock()
+	if item.Kind == models.TrashKindProject {
+		return purgeProject(item.ProjectID)
+	}
+	return purgePhotos([]uint{*item.PhotoID})
+}
+
+// PurgeTrash deletes the items whose retention ended by now. Returns the number of
+// photos and projects purged.
+func PurgeTrash(now time.Time) (photos, projects int, err error) {
+	var items []models.TrashItem
+	if err := database.DB.Where("purge_at <= ?", now).Order("id").Find(&items).Error; err != nil {
+		return 0, 0, err
+	}
+	photoIDsByProject := map[uint][]uint{}
+	var projectIDs []uint
+	for _, item := range items {
+		if item.Kind == models.TrashKindProject {
+			projectIDs = append(projectIDs, item.ProjectID)
+		} else if item.PhotoID != nil {
+			photoIDsByProject[item.ProjectID] = append(photoIDsByProject[item.ProjectID], *item.PhotoID)
+		}
+	}
+
+	for projectID, ids := range photoIDsByProject {
+		unlock := LockProject(projectID)
+		err := purgePhotos(ids)
+		unlock()
+		if err != nil {
+			return photos, projects, err
+		}
+		photos += len(ids)
+	}
+	for _, projectID := range projectIDs {
+		unlock := LockProject(projectID)
+		err := purgeProject(projectID)
+		unlock()
+		if err != nil {
+			return photos, projects, err
+		}
+		projects++
+	}
+	return photos, projects, nil
+}
+
+// purgePhotos deletes photos in the trash for good: their records, link references,
+// tags, trashed originals, thumbnails and RAW conversions. The caller holds the
+// project's lock.
+func purgePhotos(ids []uint) error {
+	var photos []models.Photo
+	if err := database.DB.Unscoped().Select("id, project_id, base_name, normal_ext, raw_ext, has_raw").
+		Where("id IN ? AND deleted_at IS NOT NULL", ids).Find(&photos).Error; err != nil {
+		return err
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(photos) > 0 {
+			if err := deletePhotoRecords(tx, photoIDs(photos)); err != nil {
+				return err
+			}
+		}
+		return tx.Where("photo_id IN ?", ids).Delete(&models.TrashItem{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	for i := range photos {
+		photo := &photos[i]
+		var keys []string
+		for _, fileName := range photoFileNames(photo) {
+			keys = append(keys, storage.TrashKey(photo.ID, fileName))
+		}
+		deleteObjects(keys)
+		RemoveThumbnails(photo.ProjectID, photo.ID)
+		RemoveConvertedJPEG(photo.ID)
+	}
+	return nil
+}
+
+// deletePhotoRecords removes the records of deleted photos and everything that refers
+// to them (the access log stays)
+func deletePhotoRecords(tx *gorm.DB, ids []uint) error {
+	for _, model := range photoLinkModels {
+		if err := tx.Where("photo_id IN ?", ids).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Table("photo_tags").Where("photo_id IN ?", ids).Delete(nil).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Photo{}).Error
+}
+
+// purgeProject deletes a project in the trash for good, with its photos (all of them
+// in the trash), share links, thumbnails and directory. The caller holds the lock.
+func purgeProject(projectID uint) error {
+	var project models.Project
+	if err := database.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", projectID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Restored meanwhile, or gone: only the trash entry is left
+			return database.DB.Where("kind = ? AND project_id = ?", models.TrashKindProject, projectID).Delete(&models.TrashItem{}).Error
+		}
+		return err
+	}
+
+	var ids []uint
+	if err := database.DB.Unscoped().Model(&models.Photo{}).Where("project_id = ?", projectID).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		if err := purgePhotos(ids); err != nil {
+			return err
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var linkIDs []uint
+		if err := tx.Unscoped().Model(&models.ShareLink{}).Where("project_id = ?", projectID).Pluck("id", &linkIDs).Error; err != nil {
+			return err
+		}
+		if len(linkIDs) > 0 {
+			for _, model := range photoLinkModels {
+				if err := tx.Where("link_id IN ?", linkIDs).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			if err := DeleteLinkStats(tx, linkIDs...); err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("project_id = ?", projectID).Delete(&models.ShareLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", projectID).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", projectID).Delete(&models.ProjectDailyStat{}).Error; err != nil {
+			return err
+		}
+		if err := deleteProjectAlbums(tx, projectID); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&project).Error; err != nil {
+			return err
+		}
+		return tx.Where("kind = ? AND project_id = ?", models.TrashKindProject, projectID).Delete(&models.TrashItem{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	RemoveProjectThumbnails(projectID)
+	RemoveProjectContactSheets(projectID)
+	removeProjectDir(project.Name)
+	log.Printf("%s Purged project %s", trashShortname, project.Name)
+	return nil
+}
+
+// StartTrashPurger purges expired trash items at startup and then hourly. Items left
+// from before the trash was disabled are still purged on schedule.
+func StartTrashPurger() {
+	if trashEnabled() {
+		log.Printf("%s Keeping deleted photos and projects for %d days", trashShortname, config.AppConfig.TrashRetentionDays)
+	}
+	purge := func() {
+		photos, projects, err := PurgeTrash(time.Now())
+		if err != nil {
+			log.Printf("%s Purge failed: %v", trashShortname, err)
+		} else if photos > 0 || projects > 0 {
+			log.Printf("%s Purged %d photos and %d projects", trashShortname, photos, projects)
+		}
+	}
+	go func() {
+		purge()
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purge()
+		}
+	}()
+}
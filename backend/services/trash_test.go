@@ -0,0 +1,194 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/storage"
+)
+
+// setupTrashTest is the bulk test setup with a 30-day trash
+func setupTrashTest(t *testing.T) (wedding, portraits *models.Project, photo *models.Photo) {
+	wedding, portraits, photo = setupBulkTest(t)
+	if err := database.DB.AutoMigrate(&models.TrashItem{}, &models.Tag{}, &models.APIKey{}, &models.AccessLog{}, &models.LinkDailyStat{}, &models.LinkPhotoDailyStat{}, &models.ProjectDailyStat{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+	config.AppConfig.TrashRetentionDays = 30
+	return wedding, portraits, photo
+}
+
+func TestDeletePhotosToTrash(t *testing.T) {
+	wedding, _, photo := setupTrashTest(t)
+
+	if _, err := DeletePhotos([]uint{photo.ID}); err != nil {
+		t.Fatalf("DeletePhotos() = %v", err)
+	}
+	var visible int64
+	database.DB.Model(&models.Photo{}).Where("id = ?", photo.ID).Count(&visible)
+	if visible != 0 {
+		t.Error("Expected the photo to be hidden")
+	}
+	for _, name := range []string{"IMG_0002.jpg", "IMG_0002.ARW"} {
+		if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, wedding.Name, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s gone from the project directory, got %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, storage.TrashKey(photo.ID, name))); err != nil {
+			t.Errorf("Expected %s in the trash: %v", name, err)
+		}
+	}
+	if !HasThumbnails(photo) {
+		t.Error("Expected the thumbnails to be kept")
+	}
+
+	items, err := ListTrash("")
+	if err != nil || len(items) != 1 {
+		t.Fatalf("Expected one trash item, got %+v (%v)", items, err)
+	}
+	item := items[0]
+	if item.Kind != models.TrashKindPhoto || item.Name != "IMG_0002" || item.ProjectName != wedding.Name {
+		t.Errorf("Unexpected trash item %+v", item)
+	}
+	if days := item.PurgeAt.Sub(item.CreatedAt).Hours() / 24; days < 29 || days > 31 {
+		t.Errorf("Expected the item to be purged in 30 days, got %.1f", days)
+	}
+
+	if err := RestoreTrashItem(item.ID); err != nil {
+		t.Fatalf("RestoreTrashItem() = %v", err)
+	}
+	var restored models.Photo
+	if err := database.DB.First(&restored, photo.ID).Error; err != nil {
+		t.Fatalf("Expected the photo back: %v", err)
+	}
+	for _, name := range []string{"IMG_0002.jpg", "IMG_0002.ARW"} {
+		if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, wedding.Name, name)); err != nil {
+			t.Errorf("Expected %s back in the project directory: %v", name, err)
+		}
+	}
+	var highlights int64
+	database.DB.Model(&models.PhotoHighlight{}).Where("photo_id = ?", photo.ID).Count(&highlights)
+	if highlights != 1 {
+		t.Error("Expected the highlight to survive the trash")
+	}
+	if err := RestoreTrashItem(item.ID); err != ErrTrashItemNotFound {
+		t.Errorf("Expected ErrTrashItemNotFound for a restored item, got %v", err)
+	}
+}
+
+func TestRestorePhotoConflict(t *testing.T) {
+	wedding, _, photo := setupTrashTest(t)
+	DeletePhotos([]uint{photo.ID})
+	// A new upload of the same name took its place
+	database.DB.Create(&models.Photo{ProjectID: wedding.ID, BaseName: "IMG_0002", NormalExt: ".jpg"})
+
+	var item models.TrashItem
+	database.DB.First(&item)
+	if err := RestoreTrashItem(item.ID); err != ErrRestoreConflict {
+		t.Fatalf("Expected ErrRestoreConflict, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, storage.TrashKey(photo.ID, "IMG_0002.ARW"))); err != nil {
+		t.Errorf("Expected the files to stay in the trash: %v", err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	wedding, _, photo := setupTrashTest(t)
+	DeletePhotos([]uint{photo.ID})
+
+	if photos, projects, err := PurgeTrash(time.Now()); err != nil || photos != 0 || projects != 0 {
+		t.Fatalf("Expected nothing purged before its time, got %d, %d (%v)", photos, projects, err)
+	}
+	photos, _, err := PurgeTrash(time.Now().AddDate(0, 0, 31))
+	if err != nil || photos != 1 {
+		t.Fatalf("Expected one photo purged, got %d (%v)", photos, err)
+	}
+	var records, items, highlights int64
+	database.DB.Unscoped().Model(&models.Photo{}).Where("id = ?", photo.ID).Count(&records)
+	database.DB.Model(&models.TrashItem{}).Count(&items)
+	database.DB.Model(&models.PhotoHighlight{}).Count(&highlights)
+	if records != 0 || items != 0 || highlights != 0 {
+		t.Errorf("Expected the photo, item and highlight gone, got %d, %d and %d", records, items, highlights)
+	}
+	if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, storage.TrashKey(photo.ID, "IMG_0002.jpg"))); !os.IsNotExist(err) {
+		t.Errorf("Expected the trashed file removed, got %v", err)
+	}
+	if HasThumbnails(photo) {
+		t.Error("Expected the thumbnails to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(config.AppConfig.UploadDir, wedding.Name, "IMG_0001.jpg")); err != nil {
+		t.Errorf("Expected other photos untouched: %v", err)
+	}
+}
+
+func TestDeleteProjectToTrash(t *testing.T) {
+	_, portraits, _ := setupTrashTest(t)
+	link := models.ShareLink{ProjectID: portraits.ID, Token: "portraits-link"}
+	database.DB.Create(&link)
+
+	if err := DeleteProject(portraits); err != nil {
+		t.Fatalf("DeleteProject() = %v", err)
+	}
+	var projects, links int64
+	database.DB.Model(&models.Project{}).Where("id = ?", portraits.ID).Count(&projects)
+	database.DB.Model(&models.ShareLink{}).Where("id = ?", link.ID).Count(&links)
+	if projects != 0 || links != 0 {
+		t.Fatal("Expected the project and its link to be hidden")
+	}
+
+	items, _ := ListTrash(models.TrashKindProject)
+	if len(items) != 1 || items[0].Name != "portraits" {
+		t.Fatalf("Expected the project in the trash, got %+v", items)
+	}
+	if err := RestoreTrashItem(items[0].ID); err != nil {
+		t.Fatalf("RestoreTrashItem() = %v", err)
+	}
+	database.DB.Model(&models.Project{}).Where("id = ?", portraits.ID).Count(&projects)
+	database.DB.Model(&models.ShareLink{}).Where("id = ?", link.ID).Count(&links)
+	if projects != 1 || links != 1 {
+		t.Errorf("Expected the project and its link back, got %d and %d", projects, links)
+	}
+
+	// Purged for good
+	DeleteProject(portraits)
+	items, _ = ListTrash(models.TrashKindProject)
+	if err := PurgeTrashItem(items[0].ID); err != nil {
+		t.Fatalf("PurgeTrashItem() = %v", err)
+	}
+	database.DB.Unscoped().Model(&models.Project{}).Where("id = ?", portraits.ID).Count(&projects)
+	database.DB.Unscoped().Model(&models.ShareLink{}).Where("id = ?", link.ID).Count(&links)
+	if projects != 0 || links != 0 {
+		t.Errorf("Expected the project and its link purged, got %d and %d", projects, links)
+	}
+}
+
+func TestRestorePhotoOfProjectInTrash(t *testing.T) {
+	wedding, _, photo := setupTrashTest(t)
+	DeletePhotos([]uint{photo.ID})
+	var cover models.Photo
+	database.DB.Where("project_id = ? AND base_name = ?", wedding.ID, "IMG_0001").First(&cover)
+	DeletePhotos([]uint{cover.ID})
+	DeleteProject(wedding)
+
+	var item models.TrashItem
+	database.DB.Where("photo_id = ?", photo.ID).First(&item)
+	if err := RestoreTrashItem(item.ID); err != ErrProjectInTrash {
+		t.Fatalf("Expected ErrProjectInTrash, got %v", err)
+	}
+
+	// Purging the project takes its photos along
+	var projectItem models.TrashItem
+	database.DB.Where("kind = ?", models.TrashKindProject).First(&projectItem)
+	if err := PurgeTrashItem(projectItem.ID); err != nil {
+		t.Fatalf("PurgeTrashItem() = %v", err)
+	}
+	var photos, items int64
+	database.DB.Unscoped().Model(&models.Photo{}).Count(&photos)
+	database.DB.Model(&models.TrashItem{}).Count(&items)
+	if photos != 0 || items != 0 {
+		t.Errorf("Expected no photos or trash items left, got %d and %d", photos, items)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+
+	"gorm.io/gorm"
+)
+
+const twoFactorShortname = "[2FA]"
+
+// totpIssuer names the account in authenticator apps
+const totpIssuer = "PhotoBridge"
+
+var (
+	// ErrTwoFactorEnabled is returned when setting up 2FA while it is already on
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorNotSetUp is returned when enabling 2FA without a pending setup
+	ErrTwoFactorNotSetUp = errors.New("two-factor authentication is not set up")
+	// ErrTwoFactorDisabled is returned by actions that need 2FA to be on
+	ErrTwoFactorDisabled = errors.New("two-factor authentication is not enabled")
+	// ErrInvalidTwoFactorCode is returned for a wrong, reused or expired code
+	ErrInvalidTwoFactorCode = errors.New("invalid code")
+)
+
+// TwoFactorSetup is a new TOTP secret to enroll in an authenticator app
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURL string `json:"otpauth_url"`
+}
+
+// TwoFactorStatus reports whether 2FA protects the admin login
+type TwoFactorStatus struct {
+	Enabled         bool       `json:"enabled"`
+	EnabledAt       *time.Time `json:"enabled_at,omitempty"`
+	BackupCodesLeft int64      `json:"backup_codes_left"`
+}
+
+// loadTwoFactor returns the admin's 2FA record, nil without one
+func loadTwoFactor() (*models.AdminTwoFactor, error) {
+	var tf models.AdminTwoFactor
+	err := database.DB.Order("id").First(&tf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// TwoFactorEnabled reports whether admin logins need a second factor
+func TwoFactorEnabled() (bool, error) {
+	tf, err := loadTwoFactor()
+	return tf != nil && tf.Enabled, err
+}
+
+// GetTwoFactorStatus reports the 2FA state and how many backup codes are unused
+func GetTwoFactorStatus() (*TwoFactorStatus, error) {
+	tf, err := loadTwoFactor()
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{}
+	if tf == nil || !tf.Enabled {
+		return status, nil
+	}
+	status.Enabled, status.EnabledAt = true, tf.EnabledAt
+	err = database.DB.Model(&models.BackupCode{}).Where("used_at IS NULL").Count(&status.BackupCodesLeft).Error
+	return status, err
+}
+
+// SetUpTwoFactor generates a new TOTP secret, replacing a setup that wasn't confirmed.
+// It only protects logins once EnableTwoFactor confirmed a code from it.
+func SetUpTwoFactor() (*TwoFactorSetup, error) {
+	tf, err := loadTwoFactor()
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret := utils.GenerateTOTPSecret()
+	if tf == nil {
+		err = database.DB.Create(&models.AdminTwoFactor{Secret: secret}).Error
+	} else {
+		err = database.DB.Model(tf).Updates(map[string]interface{}{"secret": secret, "last_step": 0}).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURL: utils.TOTPURL(secret, totpIssuer, config.AppConfig.AdminUsername),
+	}, nil
+}
+
+// EnableTwoFactor turns 2FA on once a code of the pending secret is confirmed, and
+// returns the first backup codes
+func EnableTwoFactor(code string) ([]string, error) {
+	tf, err := loadTwoFactor()
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		return nil, ErrTwoFactorNotSetUp
+	}
+	if tf.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	now := time.Now()
+	step, ok := utils.VerifyTOTP(tf.Secret, code, now, tf.LastStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(tf).Updates(map[string]interface{}{"enabled": true, "enabled_at": now, "last_step": step}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceBackupCodes(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%s Enabled for the admin login", twoFactorShortname)
+	return codes, nil
+}
+
+// DisableTwoFactor turns 2FA off after checking a TOTP or backup code, and deletes
+// the secret and the backup codes
+func DisableTwoFactor(code string) error {
+	if err := VerifyTwoFactorCode(code); err != nil {
+		return err
+	}
+	if err := ResetTwoFactor(); err != nil {
+		return err
+	}
+	log.Printf("%s Disabled for the admin login", twoFactorShortname)
+	return nil
+}
+
+// ResetTwoFactor removes 2FA without a code, for "photobridge reset-2fa"
+func ResetTwoFactor() error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.AdminTwoFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.BackupCode{}).Error
+	})
+}
+
+// RegenerateBackupCodes replaces the backup codes after checking a TOTP code
+func RegenerateBackupCodes(code string) ([]string, error) {
+	tf, err := loadTwoFactor()
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || !tf.Enabled {
+		return nil, ErrTwoFactorDisabled
+	}
+	if !utils.IsTOTPCode(code) {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	if err := verifyTOTPCode(tf, code); err != nil {
+		return nil, err
+	}
+	var codes []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		codes, err = replaceBackupCodes(tx)
+		return err
+	})
+	return codes, err
+}
+
+// VerifyTwoFactorCode checks the second factor of a login: a TOTP code from the
+// authenticator, or an unused backup code, which is then used up
+func VerifyTwoFactorCode(code string) error {
+	tf, err := loadTwoFactor()
+	if err != nil {
+		return err
+	}
+	if tf == nil || !tf.Enabled {
+		return ErrTwoFactorDisabled
+	}
+	if utils.IsTOTPCode(code) {
+		return verifyTOTPCode(tf, code)
+	}
+
+	// Only the first of concurrent uses of a backup code succeeds
+	result := database.DB.Model(&models.BackupCode{}).
+		Where("code_hash = ? AND used_at IS NULL", utils.HashBackupCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	log.Printf("%s Backup code used", twoFactorShortname)
+	return nil
+}
+
+// verifyTOTPCode checks a TOTP code and records its step, so it can't be replayed
+func verifyTOTPCode(tf *models.AdminTwoFactor, code string) error {
+	step, ok := utils.VerifyTOTP(tf.Secret, code, time.Now(), tf.LastStep)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	// Conditional, so a code used by two concurrent logins only works for one
+	result := database.DB.Model(&models.AdminTwoFactor{}).Where("id = ? AND last_step < ?", tf.ID, step).Update("last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// replaceBackupCodes deletes the backup codes and stores new ones
+func replaceBackupCodes(tx *gorm.DB) ([]string, error) {
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&models.BackupCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := utils.GenerateBackupCodes()
+	rows := make([]models.BackupCode, len(codes))
+	for i, code := range codes {
+		rows[i] = models.BackupCode{CodeHash: utils.HashBackupCode(code)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+func setupTwoFactorTest(t *testing.T) {
+	setupProjectTest(t)
+	database.DB.AutoMigrate(&models.AdminTwoFactor{}, &models.BackupCode{})
+}
+
+// currentTOTPCode returns the code the authenticator shows for the set-up secret
+func currentTOTPCode(t *testing.T, step int64) string {
+	t.Helper()
+	var tf models.AdminTwoFactor
+	if err := database.DB.First(&tf).Error; err != nil {
+		t.Fatalf("No 2FA record: %v", err)
+	}
+	code, err := utils.TOTPCode(tf.Secret, step)
+	if err != nil {
+		t.Fatalf("TOTPCode failed: %v", err)
+	}
+	return code
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	setupTwoFactorTest(t)
+	step := utils.TOTPStep(time.Now())
+
+	if _, err := EnableTwoFactor("123456"); !errors.Is(err, ErrTwoFactorNotSetUp) {
+		t.Errorf("Expected ErrTwoFactorNotSetUp, got %v", err)
+	}
+	setup, err := SetUpTwoFactor()
+	if err != nil || setup.Secret == "" || setup.OTPAuthURL == "" {
+		t.Fatalf("SetUpTwoFactor failed: %+v, %v", setup, err)
+	}
+	if enabled, _ := TwoFactorEnabled(); enabled {
+		t.Error("Expected 2FA to stay off until a code is confirmed")
+	}
+
+	// A new setup replaces the unconfirmed secret
+	if _, err := SetUpTwoFactor(); err != nil {
+		t.Fatalf("Second SetUpTwoFactor failed: %v", err)
+	}
+	var count int64
+	database.DB.Model(&models.AdminTwoFactor{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected one 2FA record, got %d", count)
+	}
+
+	if _, err := EnableTwoFactor("abcdef"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("Expected a wrong code to be refused, got %v", err)
+	}
+	codes, err := EnableTwoFactor(currentTOTPCode(t, step))
+	if err != nil {
+		t.Fatalf("EnableTwoFactor failed: %v", err)
+	}
+	if len(codes) != utils.BackupCodeCount {
+		t.Errorf("Expected %d backup codes, got %d", utils.BackupCodeCount, len(codes))
+	}
+	if _, err := SetUpTwoFactor(); !errors.Is(err, ErrTwoFactorEnabled) {
+		t.Errorf("Expected ErrTwoFactorEnabled, got %v", err)
+	}
+	status, err := GetTwoFactorStatus()
+	if err != nil || !status.Enabled || status.BackupCodesLeft != int64(utils.BackupCodeCount) {
+		t.Errorf("Unexpected status %+v, %v", status, err)
+	}
+}
+
+func TestVerifyTwoFactorCode(t *testing.T) {
+	setupTwoFactorTest(t)
+	step := utils.TOTPStep(time.Now())
+	SetUpTwoFactor()
+	codes, err := EnableTwoFactor(currentTOTPCode(t, step-1))
+	if err != nil {
+		t.Fatalf("EnableTwoFactor failed: %v", err)
+	}
+
+	// The code used to enable 2FA can't be replayed, the next one works once
+	if err := VerifyTwoFactorCode(currentTOTPCode(t, step-1)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("Expected the enrollment code to be refused, got %v", err)
+	}
+	if err := VerifyTwoFactorCode(currentTOTPCode(t, step)); err != nil {
+		t.Errorf("Expected the current code to be accepted, got %v", err)
+	}
+	if err := VerifyTwoFactorCode(currentTOTPCode(t, step)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("Expected a reused code to be refused, got %v", err)
+	}
+
+	// Backup codes work once each
+	if err := VerifyTwoFactorCode(codes[0]); err != nil {
+		t.Errorf("Expected a backup code to be accepted, got %v", err)
+	}
+	if err := VerifyTwoFactorCode(codes[0]); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("Expected a used backup code to be refused, got %v", err)
+	}
+	status, _ := GetTwoFactorStatus()
+	if status.BackupCodesLeft != int64(utils.BackupCodeCount-1) {
+		t.Errorf("Expected %d backup codes left, got %d", utils.BackupCodeCount-1, status.BackupCodesLeft)
+	}
+
+	// Regenerating replaces every code
+	fresh, err := RegenerateBackupCodes(currentTOTPCode(t, step+1))
+	if err != nil {
+		t.Fatalf("RegenerateBackupCodes failed: %v", err)
+	}
+	if err := VerifyTwoFactorCode(codes[1]); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("Expected old backup codes to be gone, got %v", err)
+	}
+
+	if err := DisableTwoFactor(fresh[0]); err != nil {
+		t.Fatalf("DisableTwoFactor failed: %v", err)
+	}
+	if enabled, _ := TwoFactorEnabled(); enabled {
+		t.Error("Expected 2FA to be off")
+	}
+	var count int64
+	database.DB.Model(&models.BackupCode{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected the backup codes to be deleted, got %d", count)
+	}
+}
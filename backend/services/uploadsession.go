@@ -0,0 +1,346 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+	"photobridge/utils"
+)
+
+const (
+	uploadSessionShortname = "[Upload]"
+	// uploadJanitorInterval is how often abandoned upload sessions are looked for
+	uploadJanitorInterval = time.Hour
+)
+
+var (
+	// ErrUploadOffset means a chunk was sent for another offset than the session's
+	ErrUploadOffset = errors.New("offset does not match the bytes received")
+	// ErrUploadOverflow means a chunk went past the declared file size
+	ErrUploadOverflow = errors.New("chunk exceeds the declared file size")
+	// ErrUploadIncomplete means a session was completed before all bytes arrived
+	ErrUploadIncomplete = errors.New("upload is incomplete")
+	// ErrUploadHashMismatch means the assembled file does not match the declared hash
+	ErrUploadHashMismatch = errors.New("file hash does not match")
+)
+
+// uploadSessionLocks serializes appends and completion of one session (token → *sync.Mutex)
+var uploadSessionLocks sync.Map
+
+func lockUploadSession(token string) (unlock func()) {
+	lock, _ := uploadSessionLocks.LoadOrStore(token, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// uploadSessionPath is the partial file a session's chunks are appended to
+func uploadSessionPath(token string) string {
+	return filepath.Join(config.AppConfig.UploadChunkDir, token+".part")
+}
+
+// OpenUploadSession starts a chunked upload of one file to a project (req must be
+// validated). An unfinished session for the same file (name, size and hash) is returned
+// instead, so a client that lost its connection or was reloaded can continue where it
+// stopped. The second result reports whether a new session was created.
+func OpenUploadSession(projectID uint, req models.CreateUploadSessionRequest) (*models.UploadSession, bool, error) {
+	name, hash := req.FileName, req.Hash
+
+	// Only sessions with a hash can be resumed: a file with the same name and size could differ
+	if hash != "" {
+		var existing models.UploadSession
+		err := database.DB.Where("project_id = ? AND file_name = ? AND size = ? AND hash = ?", projectID, name, req.Size, hash).
+			Order("id DESC").Limit(1).Find(&existing).Error
+		if err != nil {
+			return nil, false, err
+		}
+		if existing.ID != 0 {
+			if err := syncUploadOffset(&existing); err != nil {
+				return nil, false, err
+			}
+			return &existing, false, nil
+		}
+	}
+
+	if err := os.MkdirAll(config.AppConfig.UploadChunkDir, 0755); err != nil {
+		return nil, false, fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	token, err := newUploadToken()
+	if err != nil {
+		return nil, false, err
+	}
+	session := models.UploadSession{Token: token, ProjectID: projectID, FileName: name, Size: req.Size, Hash: hash}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, false, err
+	}
+	log.Printf("%s Started chunked upload %s of %s (%d bytes) to project %d", uploadSessionShortname, token, name, req.Size, projectID)
+	return &session, true, nil
+}
+
+// FindUploadSession loads a session of a project by its token, with the offset
+// corrected to the bytes actually on disk
+func FindUploadSession(projectID uint, token string) (*models.UploadSession, error) {
+	var session models.UploadSession
+	if err := database.DB.Where("project_id = ? AND token = ?", projectID, token).First(&session).Error; err != nil {
+		return nil, err
+	}
+	if err := syncUploadOffset(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// AppendUploadChunk writes a chunk read from body at offset, which must be the number
+// of bytes received so far. Bytes written before a dropped connection are kept, so the
+// client resumes from the session's new offset either way.
+func AppendUploadChunk(session *models.UploadSession, offset int64, body io.Reader) error {
+	unlock := lockUploadSession(session.Token)
+	defer unlock()
+
+	if err := reloadUploadSession(session); err != nil {
+		return err
+	}
+	if offset != session.Received {
+		return ErrUploadOffset
+	}
+
+	f, err := os.OpenFile(uploadSessionPath(session.Token), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	written, copyErr := io.Copy(f, io.LimitReader(body, session.Size-offset))
+	if written > 0 {
+		session.Received += written
+		if err := database.DB.Model(session).Update("received", session.Received).Error; err != nil {
+			return err
+		}
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+	if n, _ := body.Read(make([]byte, 1)); n > 0 {
+		return ErrUploadOverflow
+	}
+	return nil
+}
+
+// CompleteUploadSession checks that every byte of a session arrived and matches its
+// hash, then hands the assembled file and its hash to process (which may move the file
+// away) and removes the session. A hash mismatch also removes the session, since the
+// received bytes can't be trusted for a retry.
+func CompleteUploadSession(session *models.UploadSession, process func(path, hash string)) error {
+	unlock := lockUploadSession(session.Token)
+	defer unlock()
+
+	if err := reloadUploadSession(session); err != nil {
+		return err
+	}
+	if session.Received != session.Size {
+		return ErrUploadIncomplete
+	}
+	path := uploadSessionPath(session.Token)
+	hash, err := utils.CalculateFileHashFromPath(path)
+	if err != nil {
+		return err
+	}
+	if session.Hash != "" && hash != session.Hash {
+		removeUploadSession(session)
+		return ErrUploadHashMismatch
+	}
+
+	process(path, hash)
+	removeUploadSession(session)
+	return nil
+}
+
+// CancelUploadSession discards a session and its partial file
+func CancelUploadSession(session *models.UploadSession) {
+	unlock := lockUploadSession(session.Token)
+	defer unlock()
+	removeUploadSession(session)
+}
+
+// RemoveProjectUploadSessions discards the unfinished uploads of a deleted project
+func RemoveProjectUploadSessions(projectID uint) {
+	var sessions []models.UploadSession
+	database.DB.Where("project_id = ?", projectID).Find(&sessions)
+	for i := range sessions {
+		CancelUploadSession(&sessions[i])
+	}
+}
+
+// UploadJanitorResult summarizes one cleanup of the chunk directory
+type UploadJanitorResult struct {
+	Expired   int   // sessions without a chunk for longer than the TTL
+	Orphans   int   // partial files without a session (e.g. the database was restored)
+	Reclaimed int64 // bytes of partial files removed
+}
+
+// CleanUploadSessions removes sessions that received no chunk since before cutoff, and
+// partial files in UPLOAD_CHUNK_DIR that no session refers to
+func CleanUploadSessions(cutoff time.Time) (UploadJanitorResult, error) {
+	var result UploadJanitorResult
+	var stale []models.UploadSession
+	if err := database.DB.Where("updated_at < ?", cutoff).Find(&stale).Error; err != nil {
+		return result, err
+	}
+	for i := range stale {
+		if size, ok := expireUploadSession(&stale[i], cutoff); ok {
+			result.Expired++
+			result.Reclaimed += size
+		}
+	}
+
+	entries, err := os.ReadDir(config.AppConfig.UploadChunkDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, err
+	}
+	for _, entry := range entries {
+		token, ok := strings.CutSuffix(entry.Name(), ".part")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if size, ok := removeOrphanPartialFile(token); ok {
+			result.Orphans++
+			result.Reclaimed += size
+		}
+	}
+	return result, nil
+}
+
+// expireUploadSession removes a stale session unless a chunk arrived while waiting for
+// its lock, returning the size of its partial file
+func expireUploadSession(session *models.UploadSession, cutoff time.Time) (int64, bool) {
+	unlock := lockUploadSession(session.Token)
+	defer unlock()
+
+	if err := database.DB.First(session, session.ID).Error; err != nil || !session.UpdatedAt.Before(cutoff) {
+		return 0, false
+	}
+	var size int64
+	if info, err := os.Stat(uploadSessionPath(session.Token)); err == nil {
+		size = info.Size()
+	}
+	removeUploadSession(session)
+	return size, true
+}
+
+// removeOrphanPartialFile deletes the partial file of token if no session refers to it
+func removeOrphanPartialFile(token string) (int64, bool) {
+	unlock := lockUploadSession(token)
+	defer unlock()
+	defer uploadSessionLocks.Delete(token)
+
+	var count int64
+	if err := database.DB.Model(&models.UploadSession{}).Where("token = ?", token).Count(&count).Error; err != nil || count > 0 {
+		return 0, false
+	}
+	path := uploadSessionPath(token)
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("%s Failed to remove orphaned partial file %s: %v", uploadSessionShortname, path, err)
+		return 0, false
+	}
+	return info.Size(), true
+}
+
+// StartUploadJanitor cleans the chunk directory at startup, reclaiming partial files
+// left by sessions that are gone, and then hourly expires sessions idle for longer than
+// ttl. Sessions are kept across restarts, so clients resume interrupted uploads as long
+// as UPLOAD_CHUNK_DIR survives them. A zero ttl keeps idle sessions forever.
+func StartUploadJanitor(ttl time.Duration) {
+	go func() {
+		for {
+			// Without a TTL only orphaned partial files are removed
+			cutoff := time.Time{}
+			if ttl > 0 {
+				cutoff = time.Now().Add(-ttl)
+			}
+			result, err := CleanUploadSessions(cutoff)
+			if err != nil {
+				log.Printf("%s Upload session cleanup failed: %v", uploadSessionShortname, err)
+			} else if result.Expired > 0 || result.Orphans > 0 {
+				log.Printf("%s Expired %d abandoned upload sessions and removed %d orphaned partial files, reclaiming %.1f MB",
+					uploadSessionShortname, result.Expired, result.Orphans, float64(result.Reclaimed)/(1<<20))
+			}
+			if ttl <= 0 {
+				return
+			}
+			time.Sleep(uploadJanitorInterval)
+		}
+	}()
+}
+
+// removeUploadSession deletes a session's record and partial file; the caller holds its lock
+func removeUploadSession(session *models.UploadSession) {
+	if err := os.Remove(uploadSessionPath(session.Token)); err != nil && !os.IsNotExist(err) {
+		log.Printf("%s Failed to remove partial file of %s: %v", uploadSessionShortname, session.Token, err)
+	}
+	database.DB.Delete(&models.UploadSession{}, session.ID)
+	uploadSessionLocks.Delete(session.Token)
+}
+
+// reloadUploadSession re-reads a session after taking its lock, failing with
+// gorm.ErrRecordNotFound when it was completed or cancelled meanwhile
+func reloadUploadSession(session *models.UploadSession) error {
+	if err := database.DB.First(session, session.ID).Error; err != nil {
+		return err
+	}
+	return syncUploadOffset(session)
+}
+
+// syncUploadOffset sets a session's offset to the size of its partial file, which is
+// the source of truth: a crash may have come between writing a chunk and recording it,
+// and a cleared chunk directory loses the bytes the record still counts
+func syncUploadOffset(session *models.UploadSession) error {
+	var size int64
+	info, err := os.Stat(uploadSessionPath(session.Token))
+	switch {
+	case err == nil:
+		size = min(info.Size(), session.Size)
+	case !os.IsNotExist(err):
+		return err
+	}
+	if size == session.Received {
+		return nil
+	}
+	session.Received = size
+	return database.DB.Model(session).Update("received", size).Error
+}
+
+// MoveUploadFile moves an assembled upload to dst, copying when UPLOAD_CHUNK_DIR is on
+// another filesystem
+func MoveUploadFile(src, dst string) error {
+	return transferImportFile(src, dst, ImportMove)
+}
+
+// newUploadToken returns a random session token
+func newUploadToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
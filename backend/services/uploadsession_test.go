@@ -0,0 +1,171 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupUploadSessionTest(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.DB.AutoMigrate(&models.UploadSession{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	config.AppConfig = &config.Config{UploadChunkDir: t.TempDir()}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestUploadSessionResumeAndComplete(t *testing.T) {
+	setupUploadSessionTest(t)
+	data := bytes.Repeat([]byte("raw data "), 1000)
+	req := models.CreateUploadSessionRequest{FileName: "IMG_0001.ARW", Size: int64(len(data)), Hash: sha256Hex(data)}
+
+	session, created, err := OpenUploadSession(1, req)
+	if err != nil || !created {
+		t.Fatalf("OpenUploadSession() = %v, created %v", err, created)
+	}
+	if err := AppendUploadChunk(session, 0, bytes.NewReader(data[:4000])); err != nil {
+		t.Fatalf("First chunk failed: %v", err)
+	}
+
+	// A client that lost its state gets the same session back, at the received offset
+	resumed, created, err := OpenUploadSession(1, req)
+	if err != nil || created {
+		t.Fatalf("Expected to resume the session, got created %v, err %v", created, err)
+	}
+	if resumed.Token != session.Token || resumed.Received != 4000 {
+		t.Fatalf("Expected session %s at 4000, got %s at %d", session.Token, resumed.Token, resumed.Received)
+	}
+	// Another project or hash starts over
+	if _, created, _ := OpenUploadSession(2, req); !created {
+		t.Error("Expected a new session for another project")
+	}
+
+	if err := AppendUploadChunk(resumed, 0, bytes.NewReader(data)); !errors.Is(err, ErrUploadOffset) {
+		t.Errorf("Expected ErrUploadOffset for a stale offset, got %v", err)
+	}
+	if err := CompleteUploadSession(resumed, func(string, string) {}); !errors.Is(err, ErrUploadIncomplete) {
+		t.Errorf("Expected ErrUploadIncomplete, got %v", err)
+	}
+	if err := AppendUploadChunk(resumed, 4000, bytes.NewReader(data[4000:])); err != nil {
+		t.Fatalf("Last chunk failed: %v", err)
+	}
+
+	var assembled []byte
+	err = CompleteUploadSession(resumed, func(path, hash string) {
+		if hash != req.Hash {
+			t.Errorf("Expected hash %s, got %s", req.Hash, hash)
+		}
+		assembled, _ = os.ReadFile(path)
+	})
+	if err != nil {
+		t.Fatalf("CompleteUploadSession() = %v", err)
+	}
+	if !bytes.Equal(assembled, data) {
+		t.Error("Assembled file differs from the uploaded data")
+	}
+	if _, err := FindUploadSession(1, session.Token); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected the completed session to be removed, got %v", err)
+	}
+	if _, err := os.Stat(uploadSessionPath(session.Token)); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial file to be removed, got %v", err)
+	}
+}
+
+func TestUploadSessionRejectsBadData(t *testing.T) {
+	setupUploadSessionTest(t)
+	data := []byte("0123456789")
+	session, _, err := OpenUploadSession(1, models.CreateUploadSessionRequest{FileName: "a.jpg", Size: 10, Hash: sha256Hex([]byte("something else"))})
+	if err != nil {
+		t.Fatalf("OpenUploadSession() = %v", err)
+	}
+
+	if err := AppendUploadChunk(session, 0, bytes.NewReader(append(data, 'x'))); !errors.Is(err, ErrUploadOverflow) {
+		t.Errorf("Expected ErrUploadOverflow, got %v", err)
+	}
+	if session.Received != 10 {
+		t.Errorf("Expected the bytes within the size to be kept, got %d", session.Received)
+	}
+	if err := CompleteUploadSession(session, func(string, string) { t.Error("Mismatched file was processed") }); !errors.Is(err, ErrUploadHashMismatch) {
+		t.Errorf("Expected ErrUploadHashMismatch, got %v", err)
+	}
+	if _, err := FindUploadSession(1, session.Token); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected the mismatched session to be removed, got %v", err)
+	}
+}
+
+func TestUploadSessionOffsetFollowsPartialFile(t *testing.T) {
+	setupUploadSessionTest(t)
+	session, _, _ := OpenUploadSession(1, models.CreateUploadSessionRequest{FileName: "a.jpg", Size: 100})
+	if err := AppendUploadChunk(session, 0, bytes.NewReader(make([]byte, 60))); err != nil {
+		t.Fatalf("AppendUploadChunk() = %v", err)
+	}
+
+	// The chunk directory was cleared (e.g. tmpfs after a reboot): the upload restarts at 0
+	os.Remove(uploadSessionPath(session.Token))
+	found, err := FindUploadSession(1, session.Token)
+	if err != nil || found.Received != 0 {
+		t.Fatalf("Expected offset 0 after losing the partial file, got %d (%v)", found.Received, err)
+	}
+
+	RemoveProjectUploadSessions(1)
+	if _, err := FindUploadSession(1, session.Token); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected sessions of a deleted project to be removed, got %v", err)
+	}
+}
+
+func TestCleanUploadSessions(t *testing.T) {
+	setupUploadSessionTest(t)
+	req := models.CreateUploadSessionRequest{FileName: "a.jpg", Size: 100}
+	abandoned, _, _ := OpenUploadSession(1, req)
+	active, _, _ := OpenUploadSession(1, req)
+	for _, session := range []*models.UploadSession{abandoned, active} {
+		if err := AppendUploadChunk(session, 0, bytes.NewReader(make([]byte, 40))); err != nil {
+			t.Fatalf("AppendUploadChunk() = %v", err)
+		}
+	}
+	database.DB.Model(abandoned).UpdateColumn("updated_at", time.Now().Add(-4*24*time.Hour))
+	// Left behind by a session the database no longer knows (e.g. restored from a backup)
+	orphan := filepath.Join(config.AppConfig.UploadChunkDir, "0123456789abcdef.part")
+	os.WriteFile(orphan, make([]byte, 10), 0644)
+
+	result, err := CleanUploadSessions(time.Now().Add(-72 * time.Hour))
+	if err != nil {
+		t.Fatalf("CleanUploadSessions() = %v", err)
+	}
+	if result.Expired != 1 || result.Orphans != 1 || result.Reclaimed != 50 {
+		t.Errorf("Expected 1 expired session and 1 orphan reclaiming 50 bytes, got %+v", result)
+	}
+	if _, err := FindUploadSession(1, abandoned.Token); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected the abandoned session to be removed, got %v", err)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("Expected the orphaned partial file to be removed, got %v", err)
+	}
+	if found, err := FindUploadSession(1, active.Token); err != nil || found.Received != 40 {
+		t.Errorf("Expected the active session to be kept at 40 bytes, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"photobridge/config"
+	"photobridge/database"
+	"photobridge/services"
+)
+
+// initialize runs the startup steps tracked by services.Startup while the server is
+// already answering probes, then starts the background services. Steps that can't
+// recover (database connection or migrations) still exit the process.
+func initialize() {
+	// Initialize database
+	database.Init()
+	services.Startup.Complete(services.StepMigrations, nil)
+
+	// The API_KEY setting is one of the stored API keys
+	if err := services.SyncEnvAPIKey(); err != nil {
+		log.Printf("%s Failed to store API_KEY: %v", shortname, err)
+	}
+
+	// Uploads, chunked upload sessions and thumbnails need writable directories
+	services.Startup.Complete(services.StepUploadDir, checkUploadDirs())
+
+	// Initialize thumbnail generation queue
+	// Workers and timeout are configurable via environment variables.
+	// Queue is unbounded - tasks only store file paths, not image data
+	services.InitQueue(
+		config.AppConfig.ThumbWorkers,
+		time.Duration(config.AppConfig.ThumbJobTimeoutSec)*time.Second,
+	)
+	services.Startup.Complete(services.StepThumbQueue, nil)
+
+	// Regenerate missing thumbnails (e.g. after a restore) before visitors ask for them
+	go services.WarmThumbnails(services.Queue, config.AppConfig.ThumbWarmLimit)
+
+	// Record dimensions of photos uploaded before they were stored
+	go services.BackfillPhotoDimensions()
+	go services.BackfillPhotoSizes()
+
+	// Store the EXIF of photos uploaded before it was read at upload time
+	go services.BackfillPhotoExif()
+
+	// Spool download-all archives so interrupted downloads can resume
+	services.InitArchiveSpool(config.AppConfig.ArchiveSpoolDir, config.AppConfig.ArchiveSpoolMaxMB)
+
+	// Prepare archives of large galleries in the background on request
+	services.InitDownloadJobs(config.AppConfig.DownloadJobDir, config.AppConfig.DownloadJobTTL, config.AppConfig.DownloadJobMaxMB)
+
+	// Render social share cards in the background
+	services.StartShareCardWorker()
+
+	// Reclaim partial files of abandoned chunked uploads
+	services.StartUploadJanitor(time.Duration(config.AppConfig.UploadSessionTTL) * time.Hour)
+
+	// Periodically retire expired share links
+	services.StartLinkSweeper(time.Duration(config.AppConfig.LinkSweepInterval) * time.Minute)
+
+	// Delete photos and projects whose time in the trash is over
+	services.StartTrashPurger()
+
+	// Register files copied into project directories directly
+	services.StartProjectScanner(services.Queue, time.Duration(config.AppConfig.ScanInterval)*time.Minute)
+
+	// Keep the SQLite WAL in check and warn about a ballooning database
+	services.StartDatabaseMonitor()
+
+	// Email the admin a weekly or monthly activity digest
+	services.StartDigestMailer()
+
+	// Roll share link views and downloads up into daily totals after their retention
+	services.StartAccessRollup()
+
+	if ready, _ := services.Startup.Report(); ready {
+		log.Printf("%s Ready", shortname)
+	} else {
+		log.Printf("%s Started with failed steps; /api/ready reports 503", shortname)
+	}
+}
+
+// checkUploadDirs verifies that UPLOAD_DIR, UPLOAD_CHUNK_DIR and THUMB_DIR accept new files
+func checkUploadDirs() error {
+	for _, dir := range []struct{ name, path string }{
+		{"UPLOAD_DIR", config.AppConfig.UploadDir},
+		{"UPLOAD_CHUNK_DIR", config.AppConfig.UploadChunkDir},
+		{"THUMB_DIR", config.AppConfig.ThumbDir},
+	} {
+		if err := config.CheckWritableDir(dir.path); err != nil {
+			return fmt.Errorf("%s: %w", dir.name, err)
+		}
+	}
+	return nil
+}
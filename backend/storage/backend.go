@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"photobridge/config"
+)
+
+const shortname = "[Storage]"
+
+// Backend stores the original photo files. Keys have the form "<project>/<file>",
+// mirroring the layout of UPLOAD_DIR. Missing objects are reported as errors
+// matching os.ErrNotExist.
+type Backend interface {
+	// Put stores size bytes read from r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the object for reading
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat returns the size and modification time of the object
+	Stat(ctx context.Context, key string) (FileInfo, error)
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// Copy duplicates an object (used when a project is renamed)
+	Copy(ctx context.Context, srcKey, dstKey string) error
+	// Stream answers an HTTP request for the object, including Range and conditional
+	// requests. Backends may redirect to a presigned URL instead of proxying the data.
+	// ETag and Last-Modified headers already set on w by the caller are kept.
+	Stream(w http.ResponseWriter, r *http.Request, key string) error
+}
+
+// FileInfo describes a stored object
+type FileInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+var (
+	current   Backend
+	currentMu sync.RWMutex
+)
+
+// Init selects the backend configured by STORAGE_BACKEND
+func Init() error {
+	backend, err := New(config.AppConfig)
+	if err != nil {
+		return err
+	}
+	Set(backend)
+	if _, ok := backend.(*S3); ok {
+		log.Printf("%s Storing originals in bucket %s at %s", shortname, config.AppConfig.S3Bucket, config.AppConfig.S3Endpoint)
+	}
+	return nil
+}
+
+// New creates the backend described by cfg
+func New(cfg *config.Config) (Backend, error) {
+	switch cfg.StorageBackend {
+	case "", "local":
+		return NewLocal(cfg.UploadDir), nil
+	case "s3":
+		return NewS3(S3Options{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			Prefix:          cfg.S3Prefix,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+			PresignTTL:      time.Duration(cfg.S3PresignTTLSeconds) * time.Second,
+		})
+	}
+	return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (use local or s3)", cfg.StorageBackend)
+}
+
+// Set replaces the current backend (used by Init and tests)
+func Set(backend Backend) {
+	currentMu.Lock()
+	current = backend
+	currentMu.Unlock()
+}
+
+// Default returns the current backend, or local storage in UPLOAD_DIR if Init has
+// not been called
+func Default() Backend {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	if current != nil {
+		return current
+	}
+	return NewLocal(config.AppConfig.UploadDir)
+}
+
+// IsLocal reports whether originals are stored in UPLOAD_DIR
+func IsLocal() bool {
+	_, ok := Default().(*Local)
+	return ok
+}
+
+// ServesContent reports whether Stream sends the file itself rather than redirecting
+// to a presigned URL (validators set by the caller would then describe the redirect)
+func ServesContent() bool {
+	if s3, ok := Default().(*S3); ok {
+		return s3.opts.PresignTTL <= 0
+	}
+	return true
+}
+
+// Key returns the key of a file in a project
+func Key(projectName, fileName string) string {
+	return projectName + "/" + fileName
+}
+
+// trashDir holds the originals of deleted photos until the trash is purged. Project
+// names can't start with a dot, so it never collides with a project, and /uploads
+// doesn't serve it.
+const trashDir = ".trash"
+
+// TrashKey returns the key an original of a deleted photo is kept under
+func TrashKey(photoID uint, fileName string) string {
+	return trashDir + "/" + strconv.FormatUint(uint64(photoID), 10) + "-" + fileName
+}
+
+// validKey rejects keys that could escape their project (the parts are validated
+// when projects and photos are created; this is a second line of defense)
+func validKey(key string) bool {
+	project, file, ok := strings.Cut(key, "/")
+	return ok && project != "" && file != "" && !strings.Contains(file, "/") &&
+		path.Clean(key) == key && (project == trashDir || !strings.HasPrefix(project, ".")) && !strings.HasPrefix(file, ".")
+}
+
+// Store moves a file that was just written to its place in UPLOAD_DIR into the
+// backend. Nothing needs to happen for local storage; other backends upload the
+// file and remove the local copy.
+func Store(ctx context.Context, key, localPath string) error {
+	backend := Default()
+	if _, ok := backend.(*Local); ok {
+		return nil
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = backend.Put(ctx, key, file, info.Size())
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(localPath)
+}
+
+// Fetch returns a local path holding the object, for code that needs a file (image
+// decoding, EXIF, RAW conversion). With local storage this is the stored file itself;
+// other backends download it to a temporary file. Call release when done.
+func Fetch(ctx context.Context, key string) (string, func(), error) {
+	backend := Default()
+	if local, ok := backend.(*Local); ok {
+		p, err := local.Path(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := os.Stat(p); err != nil {
+			return "", nil, err
+		}
+		return p, func() {}, nil
+	}
+
+	body, err := backend.Get(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer body.Close()
+	tmp, err := os.CreateTemp("", "photobridge-fetch-*"+path.Ext(key))
+	if err != nil {
+		return "", nil, err
+	}
+	release := func() { os.Remove(tmp.Name()) }
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		release()
+		return "", nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		release()
+		return "", nil, err
+	}
+	return tmp.Name(), release, nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"photobridge/config"
+)
+
+func TestLocalBackend(t *testing.T) {
+	root := t.TempDir()
+	local := NewLocal(root)
+	ctx := context.Background()
+
+	if err := local.Put(ctx, "wedding/IMG_0001.jpg", strings.NewReader("jpeg"), 4); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "wedding", "IMG_0001.jpg")); err != nil || string(data) != "jpeg" {
+		t.Fatalf("File not written to the project directory: %q, %v", data, err)
+	}
+	if info, err := local.Stat(ctx, "wedding/IMG_0001.jpg"); err != nil || info.Size != 4 {
+		t.Errorf("Stat() = %+v, %v", info, err)
+	}
+	if err := local.Copy(ctx, "wedding/IMG_0001.jpg", "party/IMG_0001.jpg"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=1-2")
+	if err := local.Stream(rec, req, "party/IMG_0001.jpg"); err != nil || rec.Code != http.StatusPartialContent || rec.Body.String() != "pe" {
+		t.Errorf("Stream() = %d %q, %v", rec.Code, rec.Body.String(), err)
+	}
+
+	if err := local.Delete(ctx, "wedding/IMG_0001.jpg"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := local.Get(ctx, "wedding/IMG_0001.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get of a deleted file should match os.ErrNotExist, got %v", err)
+	}
+	if err := local.Delete(ctx, "wedding/IMG_0001.jpg"); err != nil {
+		t.Errorf("Deleting a missing file should succeed, got %v", err)
+	}
+
+	for _, key := range []string{"../secret.jpg", "wedding/../../x.jpg", "wedding/.hidden", "IMG_0001.jpg", "a/b/c.jpg", ".config/x.jpg"} {
+		if _, err := local.Path(key); err == nil {
+			t.Errorf("Path(%q) should be rejected", key)
+		}
+	}
+	if _, err := local.Path(TrashKey(7, "IMG_0001.jpg")); err != nil {
+		t.Errorf("Path(%q) = %v, the trash should be accepted", TrashKey(7, "IMG_0001.jpg"), err)
+	}
+}
+
+func TestStoreAndFetch(t *testing.T) {
+	originalConfig := config.AppConfig
+	t.Cleanup(func() {
+		config.AppConfig = originalConfig
+		Set(nil)
+	})
+	config.AppConfig = &config.Config{UploadDir: t.TempDir()}
+	ctx := context.Background()
+
+	localPath := filepath.Join(config.AppConfig.UploadDir, "wedding", "IMG_0001.jpg")
+	os.MkdirAll(filepath.Dir(localPath), 0755)
+	os.WriteFile(localPath, []byte("jpeg"), 0644)
+
+	// Local storage: the file stays where it is and is fetched in place
+	if err := Store(ctx, "wedding/IMG_0001.jpg", localPath); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	path, release, err := Fetch(ctx, "wedding/IMG_0001.jpg")
+	if err != nil || path != localPath {
+		t.Fatalf("Fetch() = %s, %v; expected the stored file", path, err)
+	}
+	release()
+	if _, err := os.Stat(localPath); err != nil {
+		t.Fatal("Releasing a local file must not remove it")
+	}
+
+	// Remote storage: the file is uploaded and the local copy removed
+	fake, backend := newFakeS3(t)
+	Set(backend)
+	if IsLocal() {
+		t.Fatal("IsLocal() should be false with S3 storage")
+	}
+	if err := Store(ctx, "wedding/IMG_0001.jpg", localPath); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) || string(fake.objects["pb/wedding/IMG_0001.jpg"]) != "jpeg" {
+		t.Fatalf("Expected the file to move to the bucket (local err: %v)", err)
+	}
+
+	path, release, err = Fetch(ctx, "wedding/IMG_0001.jpg")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "jpeg" || filepath.Ext(path) != ".jpg" {
+		t.Errorf("Fetched %s with %q", path, data)
+	}
+	release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Releasing a fetched copy should remove it")
+	}
+	if _, _, err := Fetch(ctx, "wedding/missing.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Fetching a missing object should match os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	if backend, err := New(&config.Config{StorageBackend: "local", UploadDir: "/tmp"}); err != nil {
+		t.Errorf("New(local) failed: %v", err)
+	} else if _, ok := backend.(*Local); !ok {
+		t.Errorf("Expected a local backend, got %T", backend)
+	}
+	if _, err := New(&config.Config{StorageBackend: "s3"}); err == nil {
+		t.Error("Expected an error for S3 without a bucket")
+	}
+	if _, err := New(&config.Config{StorageBackend: "ftp"}); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
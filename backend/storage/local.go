@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"photobridge/utils"
+)
+
+// Local stores originals in a directory (UPLOAD_DIR), one subdirectory per project
+type Local struct {
+	root string
+}
+
+// NewLocal creates a backend storing files below root
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// Path returns the validated path of key below the root directory
+func (l *Local) Path(key string) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return utils.ValidateSecurePath(l.root, filepath.Join(l.root, filepath.FromSlash(key)))
+}
+
+// Put writes the file through a temporary file, so readers never see a partial file
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".put-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (FileInfo, error) {
+	p, err := l.Path(key)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Copy(ctx context.Context, srcKey, dstKey string) error {
+	src, err := l.Get(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return l.Put(ctx, dstKey, src, -1)
+}
+
+// Stream serves the file with http.ServeContent (ETag, If-None-Match, 304 and Range);
+// an ETag set by the caller is used for the conditional checks
+func (l *Local) Stream(w http.ResponseWriter, r *http.Request, key string) error {
+	p, err := l.Path(key)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return &os.PathError{Op: "open", Path: p, Err: os.ErrNotExist}
+	}
+	modTime := info.ModTime()
+	if w.Header().Get("Last-Modified") != "" {
+		modTime = time.Time{} // Keep the caller's validator
+	}
+	http.ServeContent(w, r, info.Name(), modTime, file)
+	return nil
+}
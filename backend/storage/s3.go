@@ -0,0 +1,413 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"photobridge/utils"
+)
+
+// unsignedPayload skips hashing request bodies (60 MB RAW files) when signing;
+// S3, MinIO and R2 accept it over HTTPS
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// maxStreamRedirectCache bounds how long clients may cache a redirect to a presigned URL
+const maxStreamRedirectCache = time.Hour
+
+// S3Options configures an S3-compatible backend
+type S3Options struct {
+	Endpoint        string // e.g. https://s3.eu-central-1.amazonaws.com, http://minio:9000 (empty = AWS endpoint of Region)
+	Region          string // "auto" for Cloudflare R2
+	Bucket          string
+	Prefix          string // Key prefix inside the bucket (e.g. "photobridge/")
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool          // Address the bucket in the path (MinIO) instead of the host name
+	PresignTTL      time.Duration // Downloads redirect to presigned URLs valid this long (0 = proxy through the server)
+}
+
+// S3 stores originals in an S3-compatible bucket (AWS S3, MinIO, Cloudflare R2).
+// Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	opts     S3Options
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3 validates the options and creates the backend
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://s3." + opts.Region + ".amazonaws.com"
+	}
+	if opts.Bucket == "" || opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, errors.New("S3 storage requires S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(opts.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", opts.Endpoint)
+	}
+	if opts.Prefix = strings.Trim(opts.Prefix, "/"); opts.Prefix != "" {
+		opts.Prefix += "/"
+	}
+	return &S3{opts: opts, endpoint: endpoint, client: utils.NewHTTPClient(0), now: time.Now}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if size < 0 {
+		return errors.New("S3 uploads need the object size")
+	}
+	body := io.NopCloser(r)
+	if size == 0 {
+		body = http.NoBody
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (FileInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, nil)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+	info := FileInfo{Size: resp.ContentLength}
+	info.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, nil)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Copy uses a server-side copy, so the data never passes through PhotoBridge
+func (s *S3) Copy(ctx context.Context, srcKey, dstKey string) error {
+	if !validKey(srcKey) {
+		return fmt.Errorf("invalid storage key %q", srcKey)
+	}
+	source := uriEncode("/"+s.opts.Bucket+"/"+s.opts.Prefix+srcKey, false)
+	resp, err := s.do(ctx, http.MethodPut, dstKey, http.NoBody, 0, map[string]string{"x-amz-copy-source": source})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// A copy can fail after the 200 status was sent; the error is then in the body
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := parseS3Error(body); err != nil {
+		return fmt.Errorf("copy %s: %w", srcKey, err)
+	}
+	return nil
+}
+
+// Stream redirects to a presigned URL, or proxies the object (forwarding Range and
+// conditional headers) when presigning is disabled
+func (s *S3) Stream(w http.ResponseWriter, r *http.Request, key string) error {
+	if s.opts.PresignTTL > 0 {
+		target, err := s.PresignGet(key, s.opts.PresignTTL)
+		if err != nil {
+			return err
+		}
+		// The redirect must not outlive the signature
+		cache := min(s.opts.PresignTTL/2, maxStreamRedirectCache)
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(cache.Seconds())))
+		http.Redirect(w, r, target, http.StatusFound)
+		return nil
+	}
+
+	headers := map[string]string{}
+	for _, name := range []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"} {
+		if value := r.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	resp, err := s.send(r.Context(), http.MethodGet, key, nil, 0, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified, http.StatusRequestedRangeNotSatisfiable:
+	default:
+		return s.responseError(resp, key)
+	}
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"} {
+		if value := resp.Header.Get(name); value != "" && w.Header().Get(name) == "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method != http.MethodHead {
+		io.Copy(w, resp.Body)
+	}
+	return nil
+}
+
+// Ping checks that the bucket is reachable with the configured credentials
+func (s *S3) Ping(ctx context.Context) error {
+	req, err := s.newRequest(ctx, http.MethodHead, s.bucketPath(), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bucket %s: %s", s.opts.Bucket, resp.Status)
+	}
+	return nil
+}
+
+// PresignGet returns a URL that downloads the object without credentials until ttl passes
+func (s *S3) PresignGet(key string, ttl time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return s.presign(s.objectPath(key), ttl), nil
+}
+
+// presign builds a presigned GET URL for a path below the endpoint
+func (s *S3) presign(objectPath string, ttl time.Duration) string {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.opts.Region + "/s3/aws4_request"
+	host, objectPath := s.hostAndPath(objectPath)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.opts.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	canonicalQuery := canonicalQueryString(query)
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		uriEncode(objectPath, false),
+		canonicalQuery,
+		"host:" + host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+	signature := s.sign(now, amzDate, scope, canonical)
+
+	return s.endpoint.Scheme + "://" + host + uriEncode(objectPath, false) + "?" + canonicalQuery + "&X-Amz-Signature=" + signature
+}
+
+// do sends a signed request for an object and turns error responses into errors
+func (s *S3) do(ctx context.Context, method, key string, body io.ReadCloser, size int64, headers map[string]string) (*http.Response, error) {
+	resp, err := s.send(ctx, method, key, body, size, headers)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, s.responseError(resp, key)
+	}
+	return resp, nil
+}
+
+// send sends a signed request for an object
+func (s *S3) send(ctx context.Context, method, key string, body io.ReadCloser, size int64, headers map[string]string) (*http.Response, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("invalid storage key %q", key)
+	}
+	req, err := s.newRequest(ctx, method, s.objectPath(key), body, size, headers)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+// newRequest builds a request for a path below the endpoint, signed with SigV4.
+// headers are signed along with host, x-amz-content-sha256 and x-amz-date.
+func (s *S3) newRequest(ctx context.Context, method, objectPath string, body io.ReadCloser, size int64, headers map[string]string) (*http.Request, error) {
+	host, objectPath := s.hostAndPath(objectPath)
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint.Scheme+"://"+host, body)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Path = objectPath
+	req.URL.RawPath = uriEncode(objectPath, false)
+	req.ContentLength = size
+
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.opts.Region + "/s3/aws4_request"
+
+	signed := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": unsignedPayload,
+		"x-amz-date":           amzDate,
+	}
+	for name, value := range headers {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-") {
+			signed[strings.ToLower(name)] = value
+		} else {
+			req.Header.Set(name, value)
+		}
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+		if name != "host" {
+			req.Header.Set(name, signed[name])
+		}
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(signed[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		method,
+		req.URL.RawPath,
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+	signature := s.sign(now, amzDate, scope, canonical)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKeyID, scope, signedHeaders, signature))
+	return req, nil
+}
+
+// sign computes the SigV4 signature of a canonical request
+func (s *S3) sign(now time.Time, amzDate, scope, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// bucketPath is the path of the bucket itself; objectPath that of an object
+func (s *S3) bucketPath() string {
+	if s.opts.PathStyle {
+		return "/" + s.opts.Bucket
+	}
+	return "/"
+}
+
+func (s *S3) objectPath(key string) string {
+	return strings.TrimRight(s.bucketPath(), "/") + "/" + s.opts.Prefix + key
+}
+
+// hostAndPath returns the host name and the path below the endpoint's own path
+func (s *S3) hostAndPath(objectPath string) (string, string) {
+	host := s.endpoint.Host
+	if !s.opts.PathStyle {
+		host = s.opts.Bucket + "." + host
+	}
+	return host, strings.TrimRight(s.endpoint.Path, "/") + objectPath
+}
+
+// s3Error is the XML error document of S3 responses
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// responseError converts an error response; missing objects match os.ErrNotExist
+func (s *S3) responseError(resp *http.Response, key string) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", key, os.ErrNotExist)
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := parseS3Error(body); err != nil {
+		return fmt.Errorf("%s: %s: %w", key, resp.Status, err)
+	}
+	return fmt.Errorf("%s: %s", key, resp.Status)
+}
+
+// parseS3Error returns the error described by an XML error document, if body is one
+func parseS3Error(body []byte) error {
+	var doc s3Error
+	if xml.Unmarshal(body, &doc) != nil || doc.Code == "" {
+		return nil
+	}
+	return fmt.Errorf("%s: %s", doc.Code, doc.Message)
+}
+
+// uriEncode percent-encodes everything but unreserved characters, as SigV4 requires
+// (slashes are kept unless encodeSlash is set)
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQueryString sorts and encodes query parameters for signing
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
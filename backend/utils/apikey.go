@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const (
+	apiKeyTag = "pb_"
+	// apiKeyPrefixLength is the part of a key shown in lists and used to find it
+	apiKeyPrefixLength = len(apiKeyTag) + 8
+)
+
+// GenerateAPIKey returns a new random API key ("pb_" and 40 hex digits)
+func GenerateAPIKey() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return apiKeyTag + hex.EncodeToString(b)
+}
+
+// APIKeyPrefix returns the start of a key, which identifies it without revealing it
+func APIKeyPrefix(key string) string {
+	if len(key) <= apiKeyPrefixLength {
+		return key[:len(key)/2] // Short keys set through API_KEY
+	}
+	return key[:apiKeyPrefixLength]
+}
+
+// HashAPIKey returns the stored form of an API key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
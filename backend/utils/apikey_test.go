@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key := GenerateAPIKey()
+	if !strings.HasPrefix(key, "pb_") || len(key) != 43 {
+		t.Errorf("Unexpected key format %q", key)
+	}
+	if other := GenerateAPIKey(); other == key {
+		t.Error("Expected different keys")
+	}
+	if prefix := APIKeyPrefix(key); prefix != key[:11] {
+		t.Errorf("Prefix = %q, expected %q", prefix, key[:11])
+	}
+	if prefix := APIKeyPrefix("short"); prefix != "sh" {
+		t.Errorf("Short keys should only reveal half, got %q", prefix)
+	}
+	if HashAPIKey(key) == HashAPIKey(key+"x") || len(HashAPIKey(key)) != 64 {
+		t.Error("Expected distinct 64-digit hashes")
+	}
+}
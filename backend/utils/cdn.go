@@ -8,8 +8,8 @@ import (
 )
 
 // GetCDNBaseURL returns the appropriate CDN base URL based on the client's country
-// For China (CF-IPCountry: CN), returns CNCDN_URL if configured
-// For other countries, returns empty string (use relative URLs)
+// Countries listed in CDN_REGION_MAP get their mapped mirror; China (CF-IPCountry: CN)
+// falls back to CNCDN_URL. Other countries get an empty string (use relative URLs)
 // In development (non-Docker) environment, always returns empty string
 func GetCDNBaseURL(c *gin.Context) string {
 	// Skip CDN in development environment (non-Docker)
@@ -17,19 +17,14 @@ func GetCDNBaseURL(c *gin.Context) string {
 		return ""
 	}
 
-	// Check if China CDN is configured
-	if config.AppConfig.CNCDNURL == "" {
+	// Check if any CDN mirror is configured
+	if !config.AppConfig.HasCDN() {
 		return ""
 	}
 
-	// Check CF-IPCountry header (set by Cloudflare)
-	country := c.GetHeader("CF-IPCountry")
+	// Check CF-IPCountry header (set by Cloudflare), falling back to local GeoIP
+	country := GetClientCountry(c)
 
-	// If request is from China, use China CDN
-	if country == "CN" {
-		return config.AppConfig.CNCDNURL
-	}
-
-	// For other countries, use relative URLs (served by main domain)
-	return ""
+	// Countries without a mirror use relative URLs (served by main domain)
+	return config.AppConfig.CDNURLForCountry(country)
 }
@@ -332,3 +332,45 @@ func BenchmarkGetCDNBaseURLNoCDN(b *testing.B) {
 		GetCDNBaseURL(c)
 	}
 }
+
+func TestGetCDNBaseURLRegionMap(t *testing.T) {
+	originalConfig := config.AppConfig
+	originalDocker := os.Getenv("DOCKER")
+	defer func() {
+		config.AppConfig = originalConfig
+		os.Setenv("DOCKER", originalDocker)
+	}()
+	os.Setenv("DOCKER", "true")
+
+	config.AppConfig = &config.Config{
+		CDNRegionURLs: map[string]string{
+			"HK": "https://cdn.hk.example.com",
+			"DE": "https://cdn.eu.example.com",
+		},
+	}
+
+	tests := []struct {
+		country  string
+		expected string
+	}{
+		{"HK", "https://cdn.hk.example.com"},
+		{"DE", "https://cdn.eu.example.com"},
+		{"CN", ""}, // No CNCDN_URL and no CN mapping
+		{"US", ""},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.country, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("CF-IPCountry", tt.country)
+			c.Request = req
+
+			if result := GetCDNBaseURL(c); result != tt.expected {
+				t.Errorf("GetCDNBaseURL() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
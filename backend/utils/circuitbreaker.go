@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreaker stops calling a failing upstream for a cooldown period
+// after Threshold consecutive failures. After the cooldown a single trial
+// call is allowed through (half-open); success closes the circuit again.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive failures
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a call may be attempted
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Threshold <= 0 || b.failures < b.Threshold {
+		return true
+	}
+	if b.now().Before(b.openUntil) {
+		return false
+	}
+	// Half-open: let one call through and re-arm the cooldown for the others
+	b.openUntil = b.now().Add(b.Cooldown)
+	return true
+}
+
+// Success records a successful call and closes the circuit
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// Failure records a failed call, opening the circuit once the threshold is reached
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.Threshold > 0 && b.failures >= b.Threshold {
+		b.openUntil = b.now().Add(b.Cooldown)
+	}
+}
+
+// IsOpen reports whether calls are currently being rejected
+func (b *CircuitBreaker) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.Threshold > 0 && b.failures >= b.Threshold && b.now().Before(b.openUntil)
+}
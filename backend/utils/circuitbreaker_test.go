@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := NewCircuitBreaker(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		b.Failure()
+		if !b.Allow() {
+			t.Fatalf("Breaker should allow calls below threshold (failure %d)", i+1)
+		}
+	}
+
+	b.Failure()
+	if b.Allow() {
+		t.Error("Breaker should reject calls after reaching threshold")
+	}
+	if !b.IsOpen() {
+		t.Error("IsOpen should report true after reaching threshold")
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("Breaker should be open")
+	}
+
+	// After cooldown exactly one trial call is allowed
+	now = now.Add(2 * time.Minute)
+	if !b.Allow() {
+		t.Fatal("Breaker should allow a trial call after cooldown")
+	}
+	if b.Allow() {
+		t.Error("Only one trial call should be allowed while half-open")
+	}
+
+	b.Success()
+	if !b.Allow() || b.IsOpen() {
+		t.Error("Success should close the breaker")
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := NewCircuitBreaker(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		b.Failure()
+	}
+	if !b.Allow() {
+		t.Error("Breaker with threshold 0 should never open")
+	}
+}
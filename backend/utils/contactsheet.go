@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	contactSheetTileWidth  = 240 // Width of a thumbnail cell
+	contactSheetTileHeight = contactSheetTileWidth * 2 / 3
+	contactSheetGap        = 16
+	contactSheetLabel      = 22 // Height of the base name under a tile
+	contactSheetMargin     = 32
+	contactSheetHeader     = 72
+	contactSheetQuality    = 85
+)
+
+var (
+	contactSheetBackground = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	contactSheetEmptyTile  = color.NRGBA{R: 228, G: 230, B: 235, A: 255}
+	contactSheetText       = color.NRGBA{R: 40, G: 44, B: 52, A: 255}
+	contactSheetMuted      = color.NRGBA{R: 110, G: 116, B: 128, A: 255}
+)
+
+// ContactSheetTile is one photo of a contact sheet: its small thumbnail (nil draws an
+// empty tile, e.g. while the thumbnail is generated) and the name printed under it
+type ContactSheetTile struct {
+	Thumb []byte
+	Name  string
+}
+
+// ComposeContactSheet renders a proof sheet: a title and subtitle over a grid of
+// thumbnails fitted into 3:2 cells, each labeled with its name, on white so it prints
+// well. fontPath is the optional SHARE_CARD_FONT, needed for CJK names.
+func ComposeContactSheet(title, subtitle string, tiles []ContactSheetTile, columns int, fontPath string) ([]byte, error) {
+	if columns < 1 {
+		return nil, fmt.Errorf("a contact sheet needs at least one column")
+	}
+	rows := (len(tiles) + columns - 1) / columns
+	cellHeight := contactSheetTileHeight + contactSheetLabel
+	width := 2*contactSheetMargin + columns*contactSheetTileWidth + (columns-1)*contactSheetGap
+	height := 2*contactSheetMargin + contactSheetHeader + rows*cellHeight + max(rows-1, 0)*contactSheetGap
+	canvas := imaging.New(width, height, contactSheetBackground)
+
+	titleFace, err := loadShareCardFace(fontPath, gobold.TTF, 28)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	subtitleFace, err := loadShareCardFace(fontPath, goregular.TTF, 16)
+	if err != nil {
+		return nil, err
+	}
+	defer subtitleFace.Close()
+	nameFace, err := loadShareCardFace(fontPath, goregular.TTF, 13)
+	if err != nil {
+		return nil, err
+	}
+	defer nameFace.Close()
+
+	textWidth := width - 2*contactSheetMargin
+	drawContactSheetText(canvas, titleFace, fitText(titleFace, title, textWidth), contactSheetMargin, contactSheetMargin+28, contactSheetText)
+	if subtitle != "" {
+		drawContactSheetText(canvas, subtitleFace, fitText(subtitleFace, subtitle, textWidth), contactSheetMargin, contactSheetMargin+54, contactSheetMuted)
+	}
+
+	top := contactSheetMargin + contactSheetHeader
+	for i, tile := range tiles {
+		x := contactSheetMargin + (i%columns)*(contactSheetTileWidth+contactSheetGap)
+		y := top + (i/columns)*(cellHeight+contactSheetGap)
+		cell := image.Rect(x, y, x+contactSheetTileWidth, y+contactSheetTileHeight)
+
+		var thumb image.Image
+		if tile.Thumb != nil {
+			thumb, _ = imaging.Decode(bytes.NewReader(tile.Thumb), imaging.AutoOrientation(true))
+		}
+		if thumb == nil {
+			draw.Draw(canvas, cell, image.NewUniform(contactSheetEmptyTile), image.Point{}, draw.Src)
+		} else {
+			fitted := imaging.Fit(thumb, contactSheetTileWidth, contactSheetTileHeight, imaging.Lanczos)
+			offset := image.Pt(x+(contactSheetTileWidth-fitted.Bounds().Dx())/2, y+(contactSheetTileHeight-fitted.Bounds().Dy())/2)
+			draw.Draw(canvas, fitted.Bounds().Add(offset), fitted, image.Point{}, draw.Src)
+		}
+
+		name := fitText(nameFace, tile.Name, contactSheetTileWidth)
+		nameX := x + (contactSheetTileWidth-font.MeasureString(nameFace, name).Ceil())/2
+		drawContactSheetText(canvas, nameFace, name, nameX, y+contactSheetTileHeight+16, contactSheetText)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: contactSheetQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func drawContactSheetText(dst draw.Image, face font.Face, text string, x, baseline int, c color.Color) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, baseline),
+	}
+	d.DrawString(text)
+}
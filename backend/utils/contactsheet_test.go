@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"bytes"
+	"image/jpeg"
+	"testing"
+)
+
+func TestComposeContactSheet(t *testing.T) {
+	thumb, err := ComposeRawPlaceholder("DSC_0001", ".nef", ThumbSmallWidth, "")
+	if err != nil {
+		t.Fatalf("ComposeRawPlaceholder() = %v", err)
+	}
+	tiles := []ContactSheetTile{
+		{Thumb: thumb, Name: "DSC_0001.NEF"},
+		{Name: "DSC_0002.jpg"}, // thumbnail not generated yet
+		{Thumb: []byte("not a jpeg"), Name: "DSC_0003.jpg"},
+	}
+	data, err := ComposeContactSheet("wedding", "3 photos", tiles, 2, "")
+	if err != nil {
+		t.Fatalf("ComposeContactSheet() = %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Contact sheet is not a valid JPEG: %v", err)
+	}
+	cellHeight := contactSheetTileHeight + contactSheetLabel
+	width := 2*contactSheetMargin + 2*contactSheetTileWidth + contactSheetGap
+	height := 2*contactSheetMargin + contactSheetHeader + 2*cellHeight + contactSheetGap
+	if b := img.Bounds(); b.Dx() != width || b.Dy() != height {
+		t.Errorf("Contact sheet size = %dx%d, expected %dx%d", b.Dx(), b.Dy(), width, height)
+	}
+
+	if _, err := ComposeContactSheet("wedding", "", tiles, 0, ""); err == nil {
+		t.Error("Expected an error without columns")
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"os"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// ReadCaptureTime returns the EXIF capture time of an image or RAW file
+// (DateTimeOriginal, falling back to DateTime). ok is false when the file
+// has no usable date, e.g. screenshots or exports with stripped metadata.
+func ReadCaptureTime(path string) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	x, err := exif.Decode(f)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	taken, err := x.DateTime()
+	// Cameras with an unset clock write "0000:00:00 00:00:00" or factory defaults
+	if err != nil || taken.Year() < 1900 {
+		return time.Time{}, false
+	}
+	return taken, true
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeJPEGWithDate writes a minimal JPEG whose APP1 segment carries DateTimeOriginal
+func writeJPEGWithDate(t *testing.T, path, dateTime string) {
+	t.Helper()
+
+	le := binary.LittleEndian
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, le, uint32(8))
+
+	// IFD0: one entry pointing at the Exif sub-IFD (offset 26)
+	binary.Write(&tiff, le, uint16(1))
+	binary.Write(&tiff, le, []uint16{0x8769, 4})
+	binary.Write(&tiff, le, []uint32{1, 26})
+	binary.Write(&tiff, le, uint32(0))
+
+	// Exif IFD: DateTimeOriginal stored after the IFD (offset 44)
+	binary.Write(&tiff, le, uint16(1))
+	binary.Write(&tiff, le, []uint16{0x9003, 2})
+	binary.Write(&tiff, le, []uint32{20, 44})
+	binary.Write(&tiff, le, uint32(0))
+	tiff.WriteString(dateTime + "\x00")
+
+	var jpg bytes.Buffer
+	jpg.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&jpg, binary.BigEndian, uint16(2+6+tiff.Len()))
+	jpg.WriteString("Exif\x00\x00")
+	jpg.Write(tiff.Bytes())
+	jpg.Write([]byte{0xFF, 0xD9})
+
+	if err := os.WriteFile(path, jpg.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test JPEG: %v", err)
+	}
+}
+
+func TestReadCaptureTime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dated.jpg")
+	writeJPEGWithDate(t, path, "2024:03:15 14:30:05")
+
+	taken, ok := ReadCaptureTime(path)
+	if !ok {
+		t.Fatal("Expected capture time to be read")
+	}
+	expected := time.Date(2024, 3, 15, 14, 30, 5, 0, time.Local)
+	if !taken.Equal(expected) {
+		t.Errorf("Capture time = %v, expected %v", taken, expected)
+	}
+}
+
+func TestReadCaptureTimeMissing(t *testing.T) {
+	dir := t.TempDir()
+
+	noExif := filepath.Join(dir, "plain.png")
+	createTestImage(t, noExif, 10, 10, "png")
+	if _, ok := ReadCaptureTime(noExif); ok {
+		t.Error("Expected no capture time for image without EXIF")
+	}
+
+	unset := filepath.Join(dir, "unset.jpg")
+	writeJPEGWithDate(t, unset, "0000:00:00 00:00:00")
+	if _, ok := ReadCaptureTime(unset); ok {
+		t.Error("Expected unset camera clock to be ignored")
+	}
+
+	if _, ok := ReadCaptureTime(filepath.Join(dir, "missing.jpg")); ok {
+		t.Error("Expected no capture time for missing file")
+	}
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoExifTimes is returned by ShiftExifTimes for files without EXIF dates it can rewrite
+var ErrNoExifTimes = errors.New("no EXIF capture time found")
+
+// exifTimeLayout is the format of EXIF ASCII dates (local time, no zone)
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// EXIF date tags rewritten by ShiftExifTimes: DateTime in IFD0, DateTimeOriginal and
+// DateTimeDigitized in the Exif IFD
+const (
+	tagDateTime          = 0x0132
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+)
+
+// maxJPEGHeaderScan bounds how far into a JPEG the EXIF segment is searched for
+const maxJPEGHeaderScan = 1 << 20
+
+// exifTimePatch is one date value to overwrite at an absolute file offset
+type exifTimePatch struct {
+	offset int64
+	value  []byte
+}
+
+// ShiftExifTimes moves the EXIF dates (DateTime, DateTimeOriginal, DateTimeDigitized) of
+// a JPEG or TIFF-based RAW file (CR2, NEF, ARW, DNG, ...) by offset. The dates are
+// patched in a copy that then replaces the file, so the rewrite is atomic and
+// hard-linked copies of the file are left alone. Returns the number of dates changed,
+// or ErrNoExifTimes if the file has none.
+func ShiftExifTimes(path string, offset time.Duration) (int, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	base, order, err := findTIFFHeader(src)
+	if err != nil {
+		return 0, err
+	}
+	patches, err := collectExifTimePatches(src, base, order, offset)
+	if err != nil {
+		return 0, err
+	}
+	if len(patches) == 0 {
+		return 0, ErrNoExifTimes
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".exiftime-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	for _, patch := range patches {
+		if _, err := tmp.WriteAt(patch.value, patch.offset); err != nil {
+			tmp.Close()
+			return 0, err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if info, err := src.Stat(); err == nil {
+		os.Chmod(tmp.Name(), info.Mode())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return len(patches), nil
+}
+
+// findTIFFHeader locates the TIFF structure holding the EXIF data: the start of a
+// TIFF-based file, or the payload of a JPEG's APP1 Exif segment
+func findTIFFHeader(r io.ReaderAt) (int64, binary.ByteOrder, error) {
+	head := make([]byte, 4)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return 0, nil, ErrNoExifTimes
+	}
+	if order := tiffByteOrder(head); order != nil {
+		return 0, order, nil
+	}
+	if head[0] != 0xFF || head[1] != jpegSOI {
+		return 0, nil, ErrNoExifTimes
+	}
+
+	pos := int64(2)
+	marker := make([]byte, 4)
+	for pos < maxJPEGHeaderScan {
+		if _, err := r.ReadAt(marker, pos); err != nil || marker[0] != 0xFF {
+			break
+		}
+		if marker[1] == jpegSOS {
+			break
+		}
+		length := int64(binary.BigEndian.Uint16(marker[2:]))
+		if marker[1] == jpegAPP1 && length >= 2+int64(len(exifHeader))+8 {
+			header := make([]byte, len(exifHeader)+4)
+			if _, err := r.ReadAt(header, pos+4); err == nil && string(header[:len(exifHeader)]) == string(exifHeader) {
+				if order := tiffByteOrder(header[len(exifHeader):]); order != nil {
+					return pos + 4 + int64(len(exifHeader)), order, nil
+				}
+			}
+		}
+		pos += 2 + length
+	}
+	return 0, nil, ErrNoExifTimes
+}
+
+// tiffByteOrder returns the byte order of a standard TIFF header, or nil
+func tiffByteOrder(head []byte) binary.ByteOrder {
+	switch string(head[:4]) {
+	case "II*\x00":
+		return binary.LittleEndian
+	case "MM\x00*":
+		return binary.BigEndian
+	}
+	return nil
+}
+
+// collectExifTimePatches reads the date tags of IFD0 and the Exif IFD and returns
+// their shifted values. Unset dates (e.g. "0000:00:00 00:00:00") are left alone.
+func collectExifTimePatches(r io.ReaderAt, base int64, order binary.ByteOrder, offset time.Duration) ([]exifTimePatch, error) {
+	var patches []exifTimePatch
+	readIFD := func(ifdOffset uint32, tags ...uint16) (exifIFD uint32, err error) {
+		countBuf := make([]byte, 2)
+		if _, err := r.ReadAt(countBuf, base+int64(ifdOffset)); err != nil {
+			return 0, errInvalidExif
+		}
+		entries := make([]byte, 12*int(order.Uint16(countBuf)))
+		if _, err := r.ReadAt(entries, base+int64(ifdOffset)+2); err != nil {
+			return 0, errInvalidExif
+		}
+		for i := 0; i+12 <= len(entries); i += 12 {
+			entry := entries[i:]
+			tag, typ, n := order.Uint16(entry), order.Uint16(entry[2:]), order.Uint32(entry[4:])
+			if tag == tagExifIFD {
+				exifIFD = order.Uint32(entry[8:])
+				continue
+			}
+			// Dates are 20-byte ASCII values, always stored out of line
+			if typ != 2 || n < uint32(len(exifTimeLayout)) || !containsTag(tags, tag) {
+				continue
+			}
+			valueOffset := base + int64(order.Uint32(entry[8:]))
+			value := make([]byte, len(exifTimeLayout))
+			if _, err := r.ReadAt(value, valueOffset); err != nil {
+				return 0, errInvalidExif
+			}
+			taken, err := time.Parse(exifTimeLayout, string(value))
+			if err != nil || taken.Year() < 1900 {
+				continue
+			}
+			shifted := taken.Add(offset)
+			if shifted.Year() < 1900 || shifted.Year() > 9999 {
+				return 0, errors.New("shifted capture time out of range")
+			}
+			patches = append(patches, exifTimePatch{offset: valueOffset, value: []byte(shifted.Format(exifTimeLayout))})
+		}
+		return exifIFD, nil
+	}
+
+	ifd0Buf := make([]byte, 4)
+	if _, err := r.ReadAt(ifd0Buf, base+4); err != nil {
+		return nil, errInvalidExif
+	}
+	exifIFD, err := readIFD(order.Uint32(ifd0Buf), tagDateTime)
+	if err != nil {
+		return nil, err
+	}
+	if exifIFD != 0 {
+		if _, err := readIFD(exifIFD, tagDateTimeOriginal, tagDateTimeDigitized); err != nil {
+			return nil, err
+		}
+	}
+	return patches, nil
+}
+
+func containsTag(tags []uint16, tag uint16) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
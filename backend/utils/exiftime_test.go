@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTIFFWithDates writes a minimal big-endian TIFF (as used by TIFF-based RAW
+// formats) with DateTime in IFD0 and DateTimeOriginal in the Exif IFD
+func writeTIFFWithDates(t *testing.T, path, dateTime, original string) {
+	t.Helper()
+	be := binary.BigEndian
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00*")
+	binary.Write(&tiff, be, uint32(8))
+
+	// IFD0 (offset 8): DateTime (value at 38) and the Exif IFD pointer (58)
+	binary.Write(&tiff, be, uint16(2))
+	binary.Write(&tiff, be, []uint16{0x0132, 2})
+	binary.Write(&tiff, be, []uint32{20, 38})
+	binary.Write(&tiff, be, []uint16{0x8769, 4})
+	binary.Write(&tiff, be, []uint32{1, 58})
+	binary.Write(&tiff, be, uint32(0))
+	tiff.WriteString(dateTime + "\x00")
+
+	// Exif IFD (offset 58): DateTimeOriginal (value at 76)
+	binary.Write(&tiff, be, uint16(1))
+	binary.Write(&tiff, be, []uint16{0x9003, 2})
+	binary.Write(&tiff, be, []uint32{20, 76})
+	binary.Write(&tiff, be, uint32(0))
+	tiff.WriteString(original + "\x00")
+
+	if err := os.WriteFile(path, tiff.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test TIFF: %v", err)
+	}
+}
+
+func TestShiftExifTimesJPEG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	writeOrientedJPEG(t, path, 1) // DateTime 2024:05:01 10:00:00
+	before, _ := os.ReadFile(path)
+
+	changed, err := ShiftExifTimes(path, -90*time.Minute)
+	if err != nil || changed != 1 {
+		t.Fatalf("ShiftExifTimes() = %d, %v; expected 1 date changed", changed, err)
+	}
+	taken, ok := ReadCaptureTime(path)
+	if !ok || taken.Format(exifTimeLayout) != "2024:05:01 08:30:00" {
+		t.Errorf("Capture time = %v (ok=%v), expected 08:30", taken, ok)
+	}
+	after, _ := os.ReadFile(path)
+	if len(after) != len(before) {
+		t.Errorf("File size changed from %d to %d bytes", len(before), len(after))
+	}
+}
+
+func TestShiftExifTimesTIFF(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.nef")
+	writeTIFFWithDates(t, path, "2024:12:31 23:30:00", "2024:12:31 23:00:00")
+	// A hard-linked copy (see duplicate resolution) keeps its dates
+	linked := filepath.Join(dir, "copy.nef")
+	if err := os.Link(path, linked); err != nil {
+		t.Skipf("Hard links not supported: %v", err)
+	}
+
+	changed, err := ShiftExifTimes(path, time.Hour)
+	if err != nil || changed != 2 {
+		t.Fatalf("ShiftExifTimes() = %d, %v; expected 2 dates changed", changed, err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{"2025:01:01 00:30:00", "2025:01:01 00:00:00"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("Expected %q in the rewritten file", want)
+		}
+	}
+	if taken, ok := ReadCaptureTime(linked); !ok || taken.Year() != 2024 {
+		t.Errorf("Hard-linked copy should be untouched, got %v", taken)
+	}
+}
+
+func TestShiftExifTimesWithoutDates(t *testing.T) {
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "plain.jpg")
+	createTestImage(t, plain, 10, 10, "jpg")
+	unset := filepath.Join(dir, "unset.dng")
+	writeTIFFWithDates(t, unset, "0000:00:00 00:00:00", "0000:00:00 00:00:00")
+	text := filepath.Join(dir, "notes.txt")
+	os.WriteFile(text, []byte("not an image"), 0644)
+
+	for _, path := range []string{plain, unset, text} {
+		before, _ := os.ReadFile(path)
+		if _, err := ShiftExifTimes(path, time.Hour); !errors.Is(err, ErrNoExifTimes) {
+			t.Errorf("%s: expected ErrNoExifTimes, got %v", filepath.Base(path), err)
+		}
+		if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+			t.Errorf("%s should be left untouched", filepath.Base(path))
+		}
+	}
+}
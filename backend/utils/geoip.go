@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"log"
+	"net"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+var (
+	geoIPReader *maxminddb.Reader
+	geoIPMutex  sync.RWMutex
+)
+
+// geoIPRecord is the subset of a GeoLite2-Country/City record we need
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// InitGeoIP opens a MaxMind GeoLite2 database used when Cloudflare headers are absent.
+// An empty path disables the fallback.
+func InitGeoIP(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return err
+	}
+
+	geoIPMutex.Lock()
+	if geoIPReader != nil {
+		geoIPReader.Close()
+	}
+	geoIPReader = reader
+	geoIPMutex.Unlock()
+
+	log.Printf("[GeoIP] Loaded %s (%s)", path, reader.Metadata.DatabaseType)
+	return nil
+}
+
+// LookupCountry returns the ISO country code for an IP from the local GeoIP database,
+// or "" if the database is not loaded or the IP is unknown
+func LookupCountry(ip string) string {
+	geoIPMutex.RLock()
+	defer geoIPMutex.RUnlock()
+
+	if geoIPReader == nil {
+		return ""
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		// Strip port if present
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			parsed = net.ParseIP(host)
+		}
+	}
+	if parsed == nil {
+		return ""
+	}
+
+	var record geoIPRecord
+	if err := geoIPReader.Lookup(parsed, &record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+// GetRealIP extracts the real client IP from Cloudflare headers
+// Priority: CF-Connecting-IP > X-Real-IP > X-Forwarded-For > RemoteAddr
+func GetRealIP(c *gin.Context) string {
+	// Cloudflare passes the real IP in CF-Connecting-IP
+	if ip := c.GetHeader("CF-Connecting-IP"); ip != "" {
+		return ip
+	}
+
+	// Fallback to X-Real-IP
+	if ip := c.GetHeader("X-Real-IP"); ip != "" {
+		return ip
+	}
+
+	// Fallback to X-Forwarded-For (take the first IP)
+	if ip := c.GetHeader("X-Forwarded-For"); ip != "" {
+		// X-Forwarded-For can be: "client, proxy1, proxy2"
+		// We want the first IP (the client)
+		for i := 0; i < len(ip); i++ {
+			if ip[i] == ',' || ip[i] == ' ' {
+				return ip[:i]
+			}
+		}
+		return ip
+	}
+
+	// Fallback to RemoteAddr
+	return c.ClientIP()
+}
+
+// GetClientCountry returns the visitor's country code from CF-IPCountry,
+// falling back to the local GeoIP database when the header is absent
+func GetClientCountry(c *gin.Context) string {
+	if country := c.GetHeader("CF-IPCountry"); country != "" {
+		return country
+	}
+	return LookupCountry(GetRealIP(c))
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInitGeoIP(t *testing.T) {
+	if err := InitGeoIP(""); err != nil {
+		t.Errorf("Empty path should disable GeoIP without error, got %v", err)
+	}
+	if err := InitGeoIP(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("Expected error for missing database")
+	}
+}
+
+func TestLookupCountryWithoutDatabase(t *testing.T) {
+	if country := LookupCountry("8.8.8.8"); country != "" {
+		t.Errorf("Expected empty country without database, got %q", country)
+	}
+	if country := LookupCountry("not-an-ip"); country != "" {
+		t.Errorf("Expected empty country for invalid IP, got %q", country)
+	}
+}
+
+func TestGetRealIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{"cloudflare", map[string]string{"CF-Connecting-IP": "1.1.1.1", "X-Real-IP": "2.2.2.2"}, "1.1.1.1"},
+		{"x-real-ip", map[string]string{"X-Real-IP": "2.2.2.2"}, "2.2.2.2"},
+		{"x-forwarded-for", map[string]string{"X-Forwarded-For": "3.3.3.3, 10.0.0.1"}, "3.3.3.3"},
+		{"remote addr", nil, "192.0.2.1"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				c.Request.Header.Set(k, v)
+			}
+			if ip := GetRealIP(c); ip != tt.expected {
+				t.Errorf("GetRealIP() = %q, expected %q", ip, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetClientCountryPrefersHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.Header.Set("CF-IPCountry", "JP")
+
+	if country := GetClientCountry(c); country != "JP" {
+		t.Errorf("Expected CF-IPCountry to be used, got %q", country)
+	}
+
+	c.Request.Header.Del("CF-IPCountry")
+	if country := GetClientCountry(c); country != "" {
+		t.Errorf("Expected empty country without header or database, got %q", country)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"io"
 	"mime/multipart"
+	"os"
 )
 
 // CalculateFileHash computes SHA-256 hash of a multipart file
@@ -22,3 +23,19 @@ func CalculateFileHash(file *multipart.FileHeader) (string, error) {
 
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
+
+// CalculateFileHashFromPath computes SHA-256 hash of a file on disk
+func CalculateFileHashFromPath(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
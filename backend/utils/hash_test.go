@@ -254,3 +254,24 @@ func BenchmarkCalculateFileHash(b *testing.B) {
 		CalculateFileHash(fh)
 	}
 }
+
+func TestCalculateFileHashFromPath(t *testing.T) {
+	content := []byte("photo bytes")
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	hash, err := CalculateFileHashFromPath(path)
+	if err != nil {
+		t.Fatalf("CalculateFileHashFromPath failed: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected hash %s", hash)
+	}
+
+	if _, err := CalculateFileHashFromPath(filepath.Join(t.TempDir(), "missing.jpg")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
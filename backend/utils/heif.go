@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"photobridge/config"
+)
+
+// ErrHEIFUnsupported is returned for HEIC/HEIF files when HEIF_CONVERT_COMMAND is not set
+var ErrHEIFUnsupported = errors.New("HEIC/HEIF images need HEIF_CONVERT_COMMAND")
+
+// IsHEIFPath reports whether a file is HEIC/HEIF by its extension
+func IsHEIFPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".heic" || ext == ".heif"
+}
+
+// HEIFConversionEnabled reports whether HEIF_CONVERT_COMMAND is configured
+func HEIFConversionEnabled() bool {
+	return config.AppConfig != nil && config.AppConfig.HEIFConvertCommand != ""
+}
+
+// decodeHEIF renders a HEIC/HEIF file to a temporary JPEG with HEIF_CONVERT_COMMAND
+// and decodes it; Go has no HEVC decoder of its own
+func decodeHEIF(ctx context.Context, path string) (image.Image, error) {
+	if !HEIFConversionEnabled() {
+		return nil, ErrHEIFUnsupported
+	}
+	dir, err := os.MkdirTemp("", "photobridge-heif-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.AppConfig.RawConvertTimeout)*time.Second)
+	defer cancel()
+	output := filepath.Join(dir, "converted.jpg")
+	if err := ConvertRawToJPEG(ctx, config.AppConfig.HEIFConvertCommand, path, output); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return jpeg.Decode(contextReader{ctx: ctx, r: file})
+}
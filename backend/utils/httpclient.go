@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"photobridge/config"
+)
+
+var (
+	outboundTransport     *http.Transport
+	outboundTransportOnce sync.Once
+)
+
+// NewHTTPClient returns a client for outbound calls (Turnstile, webhooks, notifications).
+// All clients share one transport that honors OUTBOUND_PROXY_URL (or the standard
+// HTTP_PROXY/HTTPS_PROXY/NO_PROXY variables) and the optional OUTBOUND_CA_BUNDLE.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	outboundTransportOnce.Do(func() {
+		transport, err := buildOutboundTransport()
+		if err != nil {
+			log.Printf("[HTTPClient] %v, falling back to default transport settings", err)
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		}
+		outboundTransport = transport
+	})
+	return &http.Client{Transport: outboundTransport, Timeout: timeout}
+}
+
+// buildOutboundTransport creates the shared transport from configuration
+func buildOutboundTransport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if config.AppConfig == nil {
+		return transport, nil
+	}
+
+	if proxy := config.AppConfig.OutboundProxyURL; proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid OUTBOUND_PROXY_URL %q", proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if bundle := config.AppConfig.OutboundCABundle; bundle != "" {
+		pool, err := LoadCABundle(bundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return transport, nil
+}
+
+// LoadCABundle returns the system cert pool extended with the PEM certificates at path
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle %s: %w", path, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"photobridge/config"
+)
+
+func writeTestCA(t *testing.T, path string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+}
+
+func withOutboundConfig(t *testing.T, cfg *config.Config) {
+	original := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = original })
+	config.AppConfig = cfg
+}
+
+func TestBuildOutboundTransportProxy(t *testing.T) {
+	withOutboundConfig(t, &config.Config{OutboundProxyURL: "http://proxy.internal:3128"})
+
+	transport, err := buildOutboundTransport()
+	if err != nil {
+		t.Fatalf("buildOutboundTransport failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "https://challenges.cloudflare.com", nil)
+	proxyURL, err := transport.Proxy(req)
+	if err != nil || proxyURL == nil || proxyURL.Host != "proxy.internal:3128" {
+		t.Errorf("Expected explicit proxy to be used, got %v (err=%v)", proxyURL, err)
+	}
+}
+
+func TestBuildOutboundTransportInvalidProxy(t *testing.T) {
+	withOutboundConfig(t, &config.Config{OutboundProxyURL: "::not a url"})
+
+	if _, err := buildOutboundTransport(); err == nil {
+		t.Error("Expected error for invalid proxy URL")
+	}
+}
+
+func TestBuildOutboundTransportCABundle(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestCA(t, bundle)
+	withOutboundConfig(t, &config.Config{OutboundCABundle: bundle})
+
+	transport, err := buildOutboundTransport()
+	if err != nil {
+		t.Fatalf("buildOutboundTransport failed: %v", err)
+	}
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.RootCAs == nil {
+		t.Error("Expected custom root CAs to be configured")
+	}
+}
+
+func TestLoadCABundleInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := LoadCABundle(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("Expected error for missing bundle")
+	}
+
+	garbage := filepath.Join(dir, "garbage.pem")
+	os.WriteFile(garbage, []byte("not a certificate"), 0644)
+	if _, err := LoadCABundle(garbage); err == nil {
+		t.Error("Expected error for bundle without certificates")
+	}
+}
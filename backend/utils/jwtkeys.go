@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	"photobridge/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for signing or verification
+const minRSAKeyBits = 2048
+
+// JWTKeySet signs admin tokens with the current key and verifies them against it and
+// any rotated-out keys. Asymmetric keys are identified by a "kid" header derived from
+// the public key, so verifiers can pick the right key from the JWKS.
+type JWTKeySet struct {
+	method     jwt.SigningMethod
+	signingKey interface{} // []byte for HS256, crypto.Signer otherwise
+	signingKID string
+	verifyKeys map[string]crypto.PublicKey // kid → public key (asymmetric only)
+}
+
+// JWK is one public key of the JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // Ed25519
+	X   string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+var (
+	jwtKeys   *JWTKeySet
+	jwtKeysMu sync.RWMutex
+)
+
+// InitJWTKeys loads the admin token keys from the configuration
+func InitJWTKeys() error {
+	keys, err := LoadJWTKeys(config.AppConfig.JWTAlgorithm, config.AppConfig.JWTPrivateKeyFile, config.AppConfig.JWTVerifyKeyFiles)
+	if err != nil {
+		return err
+	}
+	jwtKeysMu.Lock()
+	jwtKeys = keys
+	jwtKeysMu.Unlock()
+	return nil
+}
+
+// AdminJWTKeys returns the loaded key set, or an HS256 set using JWT_SECRET if
+// InitJWTKeys has not been called
+func AdminJWTKeys() *JWTKeySet {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	if jwtKeys != nil {
+		return jwtKeys
+	}
+	return &JWTKeySet{method: jwt.SigningMethodHS256, signingKey: []byte(config.AppConfig.JWTSecret)}
+}
+
+// LoadJWTKeys builds a key set for algorithm (HS256, RS256 or EdDSA). Asymmetric
+// algorithms sign with the PEM private key in privateKeyFile and also accept tokens
+// signed by the keys in verifyKeyFiles (public or private PEM keys).
+func LoadJWTKeys(algorithm, privateKeyFile string, verifyKeyFiles []string) (*JWTKeySet, error) {
+	var method jwt.SigningMethod
+	switch algorithm {
+	case "", "HS256":
+		return &JWTKeySet{method: jwt.SigningMethodHS256, signingKey: []byte(config.AppConfig.JWTSecret)}, nil
+	case "RS256":
+		method = jwt.SigningMethodRS256
+	case "EDDSA", "EdDSA":
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+	if privateKeyFile == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", method.Alg())
+	}
+
+	key, err := readPEMKey(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a private key", privateKeyFile)
+	}
+	if err := checkKeyMatchesMethod(signer.Public(), method); err != nil {
+		return nil, fmt.Errorf("%s: %w", privateKeyFile, err)
+	}
+
+	keys := &JWTKeySet{method: method, signingKey: signer, verifyKeys: map[string]crypto.PublicKey{}}
+	if keys.signingKID, err = keyID(signer.Public()); err != nil {
+		return nil, err
+	}
+	keys.verifyKeys[keys.signingKID] = signer.Public()
+
+	for _, file := range verifyKeyFiles {
+		key, err := readPEMKey(file)
+		if err != nil {
+			return nil, err
+		}
+		public := key
+		if signer, ok := key.(crypto.Signer); ok {
+			public = signer.Public()
+		}
+		if err := checkKeyMatchesMethod(public, method); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		kid, err := keyID(public)
+		if err != nil {
+			return nil, err
+		}
+		keys.verifyKeys[kid] = public
+	}
+	return keys, nil
+}
+
+// Algorithm returns the JWT "alg" of signed tokens
+func (k *JWTKeySet) Algorithm() string {
+	return k.method.Alg()
+}
+
+// Sign creates a signed token for claims
+func (k *JWTKeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.signingKID != "" {
+		token.Header["kid"] = k.signingKID
+	}
+	return token.SignedString(k.signingKey)
+}
+
+// Parse verifies a token and decodes it into claims. Only the configured algorithm is
+// accepted, which prevents algorithm confusion (e.g. an HS256 token keyed with the
+// RSA public key).
+func (k *JWTKeySet) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		if secret, ok := k.signingKey.([]byte); ok {
+			return secret, nil
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = k.signingKID
+		}
+		key, ok := k.verifyKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	})
+}
+
+// JWKS returns the public verification keys (none for HS256, whose secret is never published)
+func (k *JWTKeySet) JWKS() []JWK {
+	kids := make([]string, 0, len(k.verifyKeys))
+	for kid := range k.verifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := []JWK{}
+	for _, kid := range kids {
+		key := k.verifyKeys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: k.method.Alg()}
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(key)
+		}
+		// The signing key goes first so naive verifiers try it first
+		if kid == k.signingKID {
+			jwks = append([]JWK{jwk}, jwks...)
+		} else {
+			jwks = append(jwks, jwk)
+		}
+	}
+	return jwks
+}
+
+// readPEMKey reads a PKCS#8/PKCS#1 private key or a PKIX public key from a PEM file
+func readPEMKey(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+}
+
+// checkKeyMatchesMethod rejects keys that cannot be used with the signing method
+func checkKeyMatchesMethod(key crypto.PublicKey, method jwt.SigningMethod) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if method != jwt.SigningMethodRS256 {
+			return errors.New("RSA key requires JWT_ALGORITHM=RS256")
+		}
+		if key.N.BitLen() < minRSAKeyBits {
+			return fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+	case ed25519.PublicKey:
+		if method != jwt.SigningMethodEdDSA {
+			return errors.New("Ed25519 key requires JWT_ALGORITHM=EdDSA")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// keyID derives a stable key ID from the public key (truncated SHA-256 of its PKIX encoding)
+func keyID(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"photobridge/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKeyPEM writes key as a PKCS#8 private key PEM file, or a PKIX public key one
+func writeKeyPEM(t *testing.T, dir, name string, key interface{}) string {
+	t.Helper()
+	var block *pem.Block
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("Failed to marshal public key: %v", err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("Failed to marshal private key: %v", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return path
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "admin", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+}
+
+func TestJWTKeysHS256Default(t *testing.T) {
+	originalConfig := config.AppConfig
+	defer func() { config.AppConfig = originalConfig }()
+	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+
+	keys, err := LoadJWTKeys("", "", nil)
+	if err != nil {
+		t.Fatalf("LoadJWTKeys failed: %v", err)
+	}
+	if keys.Algorithm() != "HS256" || len(keys.JWKS()) != 0 {
+		t.Errorf("Expected HS256 without published keys, got %s with %d keys", keys.Algorithm(), len(keys.JWKS()))
+	}
+	token, err := keys.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if _, err := keys.Parse(token, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("Parse failed: %v", err)
+	}
+
+	config.AppConfig.JWTSecret = "other-secret"
+	other, _ := LoadJWTKeys("HS256", "", nil)
+	if _, err := other.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("Token signed with another secret should be rejected")
+	}
+}
+
+func TestJWTKeysRS256Rotation(t *testing.T) {
+	originalConfig := config.AppConfig
+	defer func() { config.AppConfig = originalConfig }()
+	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+
+	dir := t.TempDir()
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	oldPath := writeKeyPEM(t, dir, "old.pem", oldKey)
+	oldPublicPath := writeKeyPEM(t, dir, "old.pub", &oldKey.PublicKey)
+	newPath := writeKeyPEM(t, dir, "new.pem", newKey)
+
+	oldKeys, err := LoadJWTKeys("RS256", oldPath, nil)
+	if err != nil {
+		t.Fatalf("LoadJWTKeys failed: %v", err)
+	}
+	oldToken, _ := oldKeys.Sign(testClaims())
+
+	// After rotation the old key is only used for verification
+	keys, err := LoadJWTKeys("RS256", newPath, []string{oldPublicPath})
+	if err != nil {
+		t.Fatalf("LoadJWTKeys failed: %v", err)
+	}
+	newToken, _ := keys.Sign(testClaims())
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		if _, err := keys.Parse(token, &jwt.RegisteredClaims{}); err != nil {
+			t.Errorf("%s token should verify: %v", name, err)
+		}
+	}
+
+	jwks := keys.JWKS()
+	if len(jwks) != 2 || jwks[0].Kty != "RSA" || jwks[0].Alg != "RS256" || jwks[0].N == "" {
+		t.Fatalf("Unexpected JWKS: %+v", jwks)
+	}
+	if parsed, _ := jwt.Parse(newToken, nil); parsed == nil || parsed.Header["kid"] != jwks[0].Kid {
+		t.Error("Signing key should be listed first and match the token kid")
+	}
+
+	// Once the old key is dropped, its tokens are rejected
+	withoutOld, _ := LoadJWTKeys("RS256", newPath, nil)
+	if _, err := withoutOld.Parse(oldToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("Token with an unknown kid should be rejected")
+	}
+}
+
+func TestJWTKeysRejectsAlgorithmConfusion(t *testing.T) {
+	originalConfig := config.AppConfig
+	defer func() { config.AppConfig = originalConfig }()
+	config.AppConfig = &config.Config{JWTSecret: "test-secret"}
+
+	dir := t.TempDir()
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	keys, err := LoadJWTKeys("EDDSA", writeKeyPEM(t, dir, "ed.pem", private), nil)
+	if err != nil {
+		t.Fatalf("LoadJWTKeys failed: %v", err)
+	}
+	if jwks := keys.JWKS(); len(jwks) != 1 || jwks[0].Kty != "OKP" || jwks[0].Crv != "Ed25519" {
+		t.Errorf("Unexpected JWKS: %+v", jwks)
+	}
+	token, _ := keys.Sign(testClaims())
+	if _, err := keys.Parse(token, &jwt.RegisteredClaims{}); err != nil {
+		t.Errorf("EdDSA token should verify: %v", err)
+	}
+
+	hmacKeys, _ := LoadJWTKeys("HS256", "", nil)
+	hmacToken, _ := hmacKeys.Sign(testClaims())
+	if _, err := keys.Parse(hmacToken, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("HS256 token should be rejected when EdDSA is configured")
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := LoadJWTKeys("EDDSA", writeKeyPEM(t, dir, "rsa.pem", rsaKey), nil); err == nil {
+		t.Error("RSA key should not be accepted for EdDSA")
+	}
+	if _, err := LoadJWTKeys("RS256", "", nil); err == nil {
+		t.Error("RS256 without a private key should fail")
+	}
+	if _, err := LoadJWTKeys("HS512", "", nil); err == nil {
+		t.Error("Unsupported algorithm should fail")
+	}
+}
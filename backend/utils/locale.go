@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SupportedLocales are the languages the share gallery can be shown in
+var SupportedLocales = []string{"zh-CN", "zh-TW", "en", "ja"}
+
+// DefaultLocale is used when neither the visitor nor the link suggests a language
+const DefaultLocale = "zh-CN"
+
+// Sources of a suggested locale, in order of precedence
+const (
+	LocaleSourceLink           = "link"
+	LocaleSourceAcceptLanguage = "accept-language"
+	LocaleSourceCountry        = "country"
+	LocaleSourceDefault        = "default"
+)
+
+// countryLocales maps visitor countries to a gallery language
+var countryLocales = map[string]string{
+	"CN": "zh-CN",
+	"SG": "zh-CN",
+	"TW": "zh-TW",
+	"HK": "zh-TW",
+	"MO": "zh-TW",
+	"JP": "ja",
+}
+
+// NormalizeLocale maps a language tag (zh-Hant-HK, en-US, ja) to a supported locale.
+// Returns "" if the language is not supported.
+func NormalizeLocale(tag string) string {
+	parts := strings.Split(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")), "-")
+	switch parts[0] {
+	case "zh":
+		for _, sub := range parts[1:] {
+			switch sub {
+			case "hant", "tw", "hk", "mo":
+				return "zh-TW"
+			}
+		}
+		return "zh-CN"
+	case "en":
+		return "en"
+	case "ja":
+		return "ja"
+	}
+	return ""
+}
+
+// SuggestLocale picks the gallery language for a visitor: the link's override,
+// then the Accept-Language header, then the visitor's country, then DefaultLocale.
+// Returns the locale and which of the LocaleSource* values it came from.
+func SuggestLocale(linkLocale, acceptLanguage, country string) (string, string) {
+	if locale := NormalizeLocale(linkLocale); locale != "" {
+		return locale, LocaleSourceLink
+	}
+	if locale := matchAcceptLanguage(acceptLanguage); locale != "" {
+		return locale, LocaleSourceAcceptLanguage
+	}
+	if locale, ok := countryLocales[strings.ToUpper(country)]; ok {
+		return locale, LocaleSourceCountry
+	}
+	if country != "" && country != "XX" && country != "T1" && country != "DEV" {
+		// Visitors from other countries get English rather than the default
+		return "en", LocaleSourceCountry
+	}
+	return DefaultLocale, LocaleSourceDefault
+}
+
+// matchAcceptLanguage returns the supported locale with the highest quality value
+// in an Accept-Language header ("ja,en-US;q=0.9,zh;q=0.8"), or "" if none match
+func matchAcceptLanguage(header string) string {
+	type candidate struct {
+		tag     string
+		quality float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if tag != "" && tag != "*" && quality > 0 {
+			candidates = append(candidates, candidate{tag, quality})
+		}
+	}
+	// Stable sort keeps header order between equal quality values
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+
+	for _, c := range candidates {
+		if locale := NormalizeLocale(c.tag); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
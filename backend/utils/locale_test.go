@@ -0,0 +1,49 @@
+package utils
+
+import "testing"
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := map[string]string{
+		"zh":         "zh-CN",
+		"zh-CN":      "zh-CN",
+		"zh-Hans-SG": "zh-CN",
+		"zh-Hant":    "zh-TW",
+		"zh_HK":      "zh-TW",
+		"en-US":      "en",
+		"EN":         "en",
+		"ja-JP":      "ja",
+		"fr-FR":      "",
+		"":           "",
+	}
+	for tag, want := range tests {
+		if got := NormalizeLocale(tag); got != want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestSuggestLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		link           string
+		acceptLanguage string
+		country        string
+		want           string
+		source         string
+	}{
+		{"link override wins", "ja", "en-US,en;q=0.9", "CN", "ja", LocaleSourceLink},
+		{"accept-language", "", "fr-FR,en-GB;q=0.8,zh;q=0.5", "CN", "en", LocaleSourceAcceptLanguage},
+		{"quality order", "", "zh-TW;q=0.4,ja;q=0.9", "", "ja", LocaleSourceAcceptLanguage},
+		{"unsupported languages fall back to country", "", "fr,de;q=0.9", "HK", "zh-TW", LocaleSourceCountry},
+		{"other countries get english", "", "", "DE", "en", LocaleSourceCountry},
+		{"unknown country", "", "", "XX", DefaultLocale, LocaleSourceDefault},
+		{"nothing known", "", "", "", DefaultLocale, LocaleSourceDefault},
+		{"q=0 is ignored", "", "en;q=0", "JP", "ja", LocaleSourceCountry},
+	}
+	for _, tt := range tests {
+		locale, source := SuggestLocale(tt.link, tt.acceptLanguage, tt.country)
+		if locale != tt.want || source != tt.source {
+			t.Errorf("%s: got %q (%s), want %q (%s)", tt.name, locale, source, tt.want, tt.source)
+		}
+	}
+}
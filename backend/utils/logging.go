@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"photobridge/config"
+)
+
+// ParseLogLevel reads a LOG_LEVEL value: debug, info (default), warn or error
+func ParseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", value)
+	}
+	return level, nil
+}
+
+// SetupLogging routes every log line to w in the given format: request logs and
+// log.Printf lines alike. Lines of log.Printf are tagged with their "[Component]"
+// prefix and classified by wording (see legacyHandler).
+func SetupLogging(w io.Writer, format, level string) error {
+	minLevel, err := ParseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: minLevel, AddSource: true, ReplaceAttr: shortSource}
+	var handler slog.Handler
+	switch format {
+	case "", config.LogFormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case config.LogFormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (use text or json)", format)
+	}
+	// SetDefault only passes the caller of log.Printf lines on when a file flag is set
+	log.SetFlags(log.Flags() | log.Lshortfile)
+	slog.SetDefault(slog.New(legacyHandler{handler}))
+	return nil
+}
+
+// shortSource writes the caller as "file.go:42" instead of a function/file/line group
+func shortSource(groups []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.SourceKey || len(groups) > 0 {
+		return a
+	}
+	source, ok := a.Value.Any().(*slog.Source)
+	if !ok || source.File == "" {
+		return slog.Attr{}
+	}
+	return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+}
+
+// legacyHandler structures the lines of log.Printf, which all arrive at info level:
+// a leading "[Component]" becomes the component attribute, and messages starting with
+// "Failed" or "Error" are logged as errors, "Warning" as warnings.
+type legacyHandler struct {
+	slog.Handler
+}
+
+func (h legacyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	// Info lines may turn into errors, so they are always considered
+	return h.Handler.Enabled(ctx, level) || level == slog.LevelInfo
+}
+
+func (h legacyHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level == slog.LevelInfo && strings.HasPrefix(r.Message, "[") {
+		if end := strings.Index(r.Message, "] "); end > 0 && !strings.ContainsAny(r.Message[1:end], " []") {
+			component, message := r.Message[1:end], r.Message[end+2:]
+			level := r.Level
+			switch {
+			case strings.HasPrefix(message, "Failed"), strings.HasPrefix(message, "Error"):
+				level = slog.LevelError
+			case strings.HasPrefix(message, "Warning"):
+				level = slog.LevelWarn
+			}
+			record := slog.NewRecord(r.Time, level, message, r.PC)
+			record.AddAttrs(slog.String("component", component))
+			r.Attrs(func(a slog.Attr) bool {
+				record.AddAttrs(a)
+				return true
+			})
+			r = record
+		}
+	}
+	if !h.Handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h legacyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return legacyHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h legacyHandler) WithGroup(name string) slog.Handler {
+	return legacyHandler{h.Handler.WithGroup(name)}
+}
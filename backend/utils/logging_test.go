@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureLogs sets up logging into a buffer and restores the defaults afterwards
+func captureLogs(t *testing.T, format, level string) *bytes.Buffer {
+	t.Helper()
+	previous, flags, writer := slog.Default(), log.Flags(), log.Writer()
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		log.SetFlags(flags)
+		log.SetOutput(writer)
+	})
+	var buf bytes.Buffer
+	if err := SetupLogging(&buf, format, level); err != nil {
+		t.Fatalf("SetupLogging() = %v", err)
+	}
+	return &buf
+}
+
+func TestSetupLoggingJSON(t *testing.T) {
+	buf := captureLogs(t, "json", "info")
+
+	log.Printf("[Digest] Sent weekly digest")
+	log.Printf("[Digest] Failed to send the weekly digest: %v", "timeout")
+	log.Printf("[Storage] Warning: bucket is almost full")
+	log.Printf("no component here")
+	slog.Debug("hidden below info")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d:\n%s", len(lines), buf.String())
+	}
+	tests := []struct{ level, component, msg string }{
+		{"INFO", "Digest", "Sent weekly digest"},
+		{"ERROR", "Digest", "Failed to send the weekly digest: timeout"},
+		{"WARN", "Storage", "Warning: bucket is almost full"},
+		{"INFO", "", "no component here"},
+	}
+	for i, tt := range tests {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatalf("Line %d is not JSON: %s", i, lines[i])
+		}
+		component, _ := entry["component"].(string)
+		if entry["level"] != tt.level || component != tt.component || entry["msg"] != tt.msg {
+			t.Errorf("Line %d = %v, want %s %q %q", i, entry, tt.level, tt.component, tt.msg)
+		}
+		if source, _ := entry["source"].(string); !strings.HasPrefix(source, "logging_test.go:") {
+			t.Errorf("Line %d: expected the caller as source, got %v", i, entry["source"])
+		}
+	}
+}
+
+func TestSetupLoggingLevel(t *testing.T) {
+	buf := captureLogs(t, "text", "error")
+
+	log.Printf("[Digest] Sent weekly digest")
+	log.Printf("[Digest] Failed to send the weekly digest")
+	slog.Warn("request", "status", 404)
+
+	out := buf.String()
+	if strings.Contains(out, "Sent weekly") || strings.Contains(out, "status=404") {
+		t.Errorf("Expected lines below error to be dropped:\n%s", out)
+	}
+	if !strings.Contains(out, "level=ERROR") || !strings.Contains(out, "component=Digest") {
+		t.Errorf("Expected the failure as a logfmt error line:\n%s", out)
+	}
+}
+
+func TestSetupLoggingInvalid(t *testing.T) {
+	if err := SetupLogging(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("Expected an unknown format to be refused")
+	}
+	if err := SetupLogging(&bytes.Buffer{}, "json", "loud"); err == nil {
+		t.Error("Expected an unknown level to be refused")
+	}
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"photobridge/config"
+)
+
+// fakeSMTPServer accepts one message and returns the envelope and data it received
+func fakeSMTPServer(t *testing.T) (port int, received chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received = make(chan []string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		var lines []string
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.Fields(line + " x")[0]); verb {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				data, _ := text.ReadDotLines()
+				lines = append(lines, data...)
+				text.PrintfLine("250 Queued")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				received <- lines
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestSendMail(t *testing.T) {
+	port, received := fakeSMTPServer(t)
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	config.AppConfig = &config.Config{SMTPHost: "127.0.0.1", SMTPPort: port, SMTPFrom: "photobridge@example.com"}
+
+	to := []string{"admin@example.com", "studio@example.com"}
+	if err := SendMail(to, "每周摘要", "Uploads: 3 photos\nViews: 5\n"); err != nil {
+		t.Fatalf("SendMail() = %v", err)
+	}
+
+	var lines []string
+	select {
+	case lines = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The server received no message")
+	}
+	session := strings.Join(lines, "\n")
+	for _, want := range []string{"MAIL FROM:<photobridge@example.com>", "RCPT TO:<admin@example.com>", "RCPT TO:<studio@example.com>",
+		"To: admin@example.com, studio@example.com", "Subject: =?utf-8?q?", "Content-Transfer-Encoding: base64"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("Session lacks %q:\n%s", want, session)
+		}
+	}
+
+	// The body follows the blank line after the headers
+	var body strings.Builder
+	for i, line := range lines {
+		if line == "" {
+			body.WriteString(strings.Join(lines[i+1:], ""))
+			break
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil || string(decoded) != "Uploads: 3 photos\r\nViews: 5\r\n" {
+		t.Errorf("Body = %q (%v)", decoded, err)
+	}
+}
+
+func TestSendMailWithoutServer(t *testing.T) {
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+
+	config.AppConfig = &config.Config{}
+	if err := SendMail([]string{"admin@example.com"}, "Test", "Body"); err == nil {
+		t.Error("Expected an error without SMTP_HOST")
+	}
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	config.AppConfig = &config.Config{SMTPHost: "127.0.0.1", SMTPPort: port, SMTPFrom: "photobridge@example.com"}
+	if err := SendMail([]string{"admin@example.com"}, "Test", "Body"); err == nil || !strings.Contains(err.Error(), strconv.Itoa(port)) {
+		t.Errorf("Expected a connection error naming the address, got %v", err)
+	}
+}
+
+func TestBuildMailMessageWrapsBody(t *testing.T) {
+	message := string(buildMailMessage("a@example.com", []string{"b@example.com"}, "Test", strings.Repeat("x", 200), time.Now()))
+	scanner := bufio.NewScanner(strings.NewReader(message))
+	for scanner.Scan() {
+		if len(scanner.Text()) > 78 {
+			t.Errorf("Line longer than 78 characters: %q", scanner.Text())
+		}
+	}
+	if !strings.Contains(message, "Message-ID: <") || !strings.Contains(message, "@example.com>") {
+		t.Errorf("Expected a Message-ID in the sender's domain:\n%s", message)
+	}
+}
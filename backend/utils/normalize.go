@@ -0,0 +1,283 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/disintegration/imaging"
+)
+
+// JPEG markers used when rewriting metadata segments
+const (
+	jpegSOI  = 0xD8
+	jpegSOS  = 0xDA
+	jpegAPP1 = 0xE1
+	jpegAPP2 = 0xE2
+	jpegCOM  = 0xFE
+)
+
+// EXIF tags touched by NormalizeJPEG
+const (
+	tagOrientation = 0x0112
+	tagExifIFD     = 0x8769
+	tagGPSIFD      = 0x8825
+	tagInteropIFD  = 0xA005
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	mpfHeader  = []byte("MPF\x00")
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	// XMP copies of the orientation, e.g. tiff:Orientation="6" or <tiff:Orientation>6</tiff:Orientation>
+	xmpOrientation = regexp.MustCompile(`(tiff:Orientation(?:="|>))[2-8]`)
+
+	errInvalidExif = errors.New("invalid EXIF data")
+)
+
+// exifTypeSizes are the byte sizes of the TIFF field types
+var exifTypeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// jpegSegment is a marker segment before the scan data; data excludes the marker and length
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// NormalizeJPEG bakes the EXIF orientation of a JPEG into its pixels and strips the
+// embedded EXIF thumbnail, rewriting the file in place. Other metadata (EXIF, ICC
+// profile, XMP) is kept with the orientation reset to normal. Rotated images are
+// re-encoded at the given quality; upright ones only have their metadata rewritten.
+// Non-JPEG files and files that need no changes are left untouched.
+// Returns whether the file was rewritten.
+func NormalizeJPEG(path string, quality int) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	segments, rest, ok := splitJPEG(data)
+	if !ok {
+		return false, nil // Not a JPEG (or one we cannot parse safely)
+	}
+
+	orientation := 1
+	thumbStripped := false
+	for i, seg := range segments {
+		if seg.marker != jpegAPP1 || !bytes.HasPrefix(seg.data, exifHeader) {
+			continue
+		}
+		tiff := seg.data[len(exifHeader):]
+		o, cleaned, stripped, err := normalizeExif(tiff)
+		if err != nil {
+			return false, err
+		}
+		orientation = o
+		thumbStripped = stripped
+		segments[i].data = append(append([]byte{}, exifHeader...), cleaned...)
+		break
+	}
+	if orientation == 1 && !thumbStripped {
+		return false, nil
+	}
+
+	var out bytes.Buffer
+	out.Write([]byte{0xFF, jpegSOI})
+	if orientation == 1 {
+		// Upright: keep the compressed image as is, only the EXIF segment changed
+		writeJPEGSegments(&out, segments, false)
+		out.Write(rest)
+		return true, replaceFile(path, out.Bytes())
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	if _, isCMYK := img.(*image.CMYK); isCMYK {
+		// Re-encoding would drop the CMYK color model; leave such files alone
+		return false, nil
+	}
+	if quality < 1 || quality > 100 {
+		quality = 95
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, applyOrientation(img, orientation), &jpeg.Options{Quality: quality}); err != nil {
+		return false, err
+	}
+	// Re-encoded data comes after the original metadata segments
+	writeJPEGSegments(&out, segments, true)
+	out.Write(encoded.Bytes()[2:])
+	return true, replaceFile(path, out.Bytes())
+}
+
+// splitJPEG returns the marker segments before the first scan and the remaining bytes
+// (SOS onwards). ok is false if data is not a well-formed JPEG.
+func splitJPEG(data []byte) (segments []jpegSegment, rest []byte, ok bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegSOI {
+		return nil, nil, false
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, nil, false
+		}
+		marker := data[pos+1]
+		if marker == 0xFF { // fill byte
+			pos++
+			continue
+		}
+		if marker == jpegSOS {
+			return segments, data[pos:], true
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, nil, false
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: data[pos+4 : pos+2+length]})
+		pos += 2 + length
+	}
+	return nil, nil, false
+}
+
+// writeJPEGSegments writes segments back out. With metadataOnly, only APPn and COM
+// segments are written (the re-encoded image brings its own tables), and MPF indexes
+// are dropped since the secondary images they point at are not carried over.
+func writeJPEGSegments(out *bytes.Buffer, segments []jpegSegment, metadataOnly bool) {
+	for _, seg := range segments {
+		isMetadata := (seg.marker >= 0xE0 && seg.marker <= 0xEF) || seg.marker == jpegCOM
+		if metadataOnly && (!isMetadata || (seg.marker == jpegAPP2 && bytes.HasPrefix(seg.data, mpfHeader))) {
+			continue
+		}
+		data := seg.data
+		if seg.marker == jpegAPP1 && bytes.HasPrefix(data, xmpHeader) {
+			data = xmpOrientation.ReplaceAll(data, []byte("${1}1"))
+		}
+		out.Write([]byte{0xFF, seg.marker})
+		binary.Write(out, binary.BigEndian, uint16(len(data)+2))
+		out.Write(data)
+	}
+}
+
+// normalizeExif reads the orientation from a TIFF-structured EXIF block and returns a
+// copy with the orientation set to 1 and the thumbnail IFD (IFD1) unlinked. The block
+// is truncated at IFD1 when nothing else is stored after it. stripped reports whether
+// a thumbnail was removed.
+func normalizeExif(tiff []byte) (orientation int, cleaned []byte, stripped bool, err error) {
+	if len(tiff) < 8 {
+		return 0, nil, false, errInvalidExif
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, nil, false, errInvalidExif
+	}
+	cleaned = append([]byte{}, tiff...)
+	orientation = 1
+
+	// end tracks how far IFD0 and its sub-IFDs (with their values) extend
+	end := uint32(8)
+	// depth bounds the recursion into sub-IFDs (IFD0 → Exif → Interop) against looping offsets
+	var walk func(offset uint32, isIFD0 bool, depth int) (next uint32, err error)
+	walk = func(offset uint32, isIFD0 bool, depth int) (uint32, error) {
+		if depth > 2 || offset < 8 || uint64(offset)+2 > uint64(len(cleaned)) {
+			return 0, errInvalidExif
+		}
+		count := uint32(order.Uint16(cleaned[offset:]))
+		tableEnd := offset + 2 + count*12 + 4
+		if uint64(tableEnd) > uint64(len(cleaned)) {
+			return 0, errInvalidExif
+		}
+		end = max(end, tableEnd)
+		for i := uint32(0); i < count; i++ {
+			entry := cleaned[offset+2+i*12:]
+			tag, typ, n := order.Uint16(entry), order.Uint16(entry[2:]), order.Uint32(entry[4:])
+			size := uint64(exifTypeSizes[typ]) * uint64(n)
+			if size > 4 {
+				valueOffset := uint64(order.Uint32(entry[8:]))
+				if valueOffset+size > uint64(len(cleaned)) {
+					return 0, errInvalidExif
+				}
+				end = max(end, uint32(valueOffset+size))
+			}
+			switch {
+			case isIFD0 && tag == tagOrientation && typ == 3 && n == 1:
+				if o := int(order.Uint16(entry[8:])); o >= 2 && o <= 8 {
+					orientation = o
+					order.PutUint16(entry[8:], 1)
+				}
+			case tag == tagExifIFD || tag == tagGPSIFD || tag == tagInteropIFD:
+				if _, err := walk(order.Uint32(entry[8:]), false, depth+1); err != nil {
+					return 0, err
+				}
+			}
+		}
+		return order.Uint32(cleaned[tableEnd-4:]), nil
+	}
+
+	ifd0 := order.Uint32(cleaned[4:])
+	ifd1, err := walk(ifd0, true, 0)
+	if err != nil {
+		return 0, nil, false, err
+	}
+	if ifd1 == 0 {
+		return orientation, cleaned, false, nil
+	}
+
+	// Unlink IFD1 from IFD0, then drop its bytes if they trail everything else
+	count := uint32(order.Uint16(cleaned[ifd0:]))
+	order.PutUint32(cleaned[ifd0+2+count*12:], 0)
+	if ifd1 >= end && int(ifd1) <= len(cleaned) {
+		cleaned = cleaned[:ifd1]
+	}
+	return orientation, cleaned, true, nil
+}
+
+// applyOrientation transforms img so it displays upright without EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
+// replaceFile atomically replaces path with data
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".normalize-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		os.Chmod(tmp.Name(), info.Mode())
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// writeOrientedJPEG writes a 40x20 JPEG (red left half, blue right half) whose EXIF
+// carries the given orientation, a DateTime and an embedded thumbnail in IFD1
+func writeOrientedJPEG(t *testing.T, path string, orientation uint16) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if x < 20 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	thumb := []byte{0xFF, 0xD8, 0xFF, 0xD9}
+
+	le := binary.LittleEndian
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, le, uint32(8))
+
+	// IFD0 (offset 8): Orientation and DateTime (value at 38), IFD1 at 58
+	binary.Write(&tiff, le, uint16(2))
+	binary.Write(&tiff, le, []uint16{0x0112, 3})
+	binary.Write(&tiff, le, []uint32{1, uint32(orientation)})
+	binary.Write(&tiff, le, []uint16{0x0132, 2})
+	binary.Write(&tiff, le, []uint32{20, 38})
+	binary.Write(&tiff, le, uint32(58))
+	tiff.WriteString("2024:05:01 10:00:00\x00")
+
+	// IFD1 (offset 58): the thumbnail stored right after it (offset 88)
+	binary.Write(&tiff, le, uint16(2))
+	binary.Write(&tiff, le, []uint16{0x0201, 4})
+	binary.Write(&tiff, le, []uint32{1, 88})
+	binary.Write(&tiff, le, []uint16{0x0202, 4})
+	binary.Write(&tiff, le, []uint32{1, uint32(len(thumb))})
+	binary.Write(&tiff, le, uint32(0))
+	tiff.Write(thumb)
+
+	var jpg bytes.Buffer
+	jpg.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&jpg, binary.BigEndian, uint16(2+6+tiff.Len()))
+	jpg.WriteString("Exif\x00\x00")
+	jpg.Write(tiff.Bytes())
+	jpg.Write(encoded.Bytes()[2:])
+
+	if err := os.WriteFile(path, jpg.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test JPEG: %v", err)
+	}
+}
+
+func decodeExif(t *testing.T, path string) *exif.Exif {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	x, err := exif.Decode(f)
+	if err != nil {
+		t.Fatalf("Failed to decode EXIF: %v", err)
+	}
+	return x
+}
+
+func TestNormalizeJPEGRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rotated.jpg")
+	writeOrientedJPEG(t, path, 6) // displayed rotated 90° clockwise
+
+	changed, err := NormalizeJPEG(path, 95)
+	if err != nil || !changed {
+		t.Fatalf("NormalizeJPEG() = %v, %v; expected a rewrite", changed, err)
+	}
+
+	f, _ := os.Open(path)
+	img, err := jpeg.Decode(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("Normalized file is not a valid JPEG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Errorf("Expected 20x40 after rotation, got %dx%d", b.Dx(), b.Dy())
+	}
+	// Rotating clockwise moves the red left half to the top
+	if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+		t.Errorf("Expected red at the top after rotation")
+	}
+
+	x := decodeExif(t, path)
+	if tag, err := x.Get(exif.Orientation); err != nil {
+		t.Errorf("Orientation tag missing: %v", err)
+	} else if o, _ := tag.Int(0); o != 1 {
+		t.Errorf("Orientation = %d, expected 1", o)
+	}
+	if _, err := x.JpegThumbnail(); err == nil {
+		t.Error("Embedded thumbnail should be stripped")
+	}
+	if taken, ok := ReadCaptureTime(path); !ok || taken.Year() != 2024 {
+		t.Errorf("Capture time should be kept, got %v (ok=%v)", taken, ok)
+	}
+
+	// Normalizing again is a no-op
+	if changed, err := NormalizeJPEG(path, 95); err != nil || changed {
+		t.Errorf("Second NormalizeJPEG() = %v, %v; expected no change", changed, err)
+	}
+}
+
+func TestNormalizeJPEGUprightIsLossless(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upright.jpg")
+	writeOrientedJPEG(t, path, 1)
+	original, _ := os.ReadFile(path)
+
+	changed, err := NormalizeJPEG(path, 95)
+	if err != nil || !changed {
+		t.Fatalf("NormalizeJPEG() = %v, %v; expected the thumbnail to be stripped", changed, err)
+	}
+	normalized, _ := os.ReadFile(path)
+	if len(normalized) >= len(original) {
+		t.Errorf("File should shrink, %d -> %d bytes", len(original), len(normalized))
+	}
+	// The compressed image data is kept byte for byte
+	sos := []byte{0xFF, 0xDA}
+	if !bytes.Equal(original[bytes.Index(original, sos):], normalized[bytes.Index(normalized, sos):]) {
+		t.Error("Scan data of an upright image should not be re-encoded")
+	}
+	if _, err := decodeExif(t, path).JpegThumbnail(); err == nil {
+		t.Error("Embedded thumbnail should be stripped")
+	}
+}
+
+func TestNormalizeJPEGSkipsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	pngPath := filepath.Join(dir, "image.png")
+	createTestImage(t, pngPath, 10, 10, "png")
+	plainPath := filepath.Join(dir, "plain.jpg")
+	createTestImage(t, plainPath, 10, 10, "jpg")
+
+	for _, path := range []string{pngPath, plainPath} {
+		before, _ := os.ReadFile(path)
+		changed, err := NormalizeJPEG(path, 95)
+		after, _ := os.ReadFile(path)
+		if err != nil || changed || !bytes.Equal(before, after) {
+			t.Errorf("%s should be left untouched (changed=%v, err=%v)", filepath.Base(path), changed, err)
+		}
+	}
+
+	if _, err := NormalizeJPEG(filepath.Join(dir, "missing.jpg"), 95); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -2,34 +2,106 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"fmt"
 	"math/big"
-	"time"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
-// GenerateSharePassword generates a random 4-digit password (1000-9999)
-func GenerateSharePassword() string {
-	min := int64(1000)
-	max := int64(9999)
+// Lengths of share passwords, chosen by the admin or generated
+const (
+	MinSharePasswordLength     = 4
+	MaxSharePasswordLength     = 64 // bcrypt only uses the first 72 bytes
+	DefaultSharePasswordLength = 4
+)
 
-	n, err := rand.Int(rand.Reader, big.NewInt(max-min+1))
-	if err != nil {
-		// Fallback: use timestamp last 4 digits
-		return fmt.Sprintf("%04d", time.Now().Unix()%10000)
+// Alphabets of generated share passwords
+const (
+	SharePasswordDigits       = "digits"
+	SharePasswordAlphanumeric = "alphanumeric" // lowercase letters and digits, without look-alikes
+)
+
+// sharePasswordChars is the alphanumeric alphabet; 0/o and 1/l/i are left out since
+// clients type the password from a message or a printed card
+const sharePasswordChars = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// ErrSharePasswordOptions is returned by GenerateSharePassword for a length or
+// alphabet it can't generate
+var ErrSharePasswordOptions = fmt.Errorf("password length must be %d to %d and the alphabet %q or %q",
+	MinSharePasswordLength, MaxSharePasswordLength, SharePasswordDigits, SharePasswordAlphanumeric)
+
+// GenerateSharePassword generates a random password of length characters from the
+// alphabet; 0 and "" mean DefaultSharePasswordLength and SharePasswordDigits. Numeric
+// passwords don't start with 0, so spreadsheets and phone keypads keep them intact.
+func GenerateSharePassword(length int, alphabet string) (string, error) {
+	if length == 0 {
+		length = DefaultSharePasswordLength
+	}
+	if length < MinSharePasswordLength || length > MaxSharePasswordLength {
+		return "", ErrSharePasswordOptions
+	}
+	chars := "0123456789"
+	switch alphabet {
+	case "", SharePasswordDigits:
+	case SharePasswordAlphanumeric:
+		chars = sharePasswordChars
+	default:
+		return "", ErrSharePasswordOptions
 	}
 
-	return fmt.Sprintf("%04d", n.Int64()+min)
+	password := make([]byte, length)
+	for i := range password {
+		set := chars
+		if i == 0 && alphabet != SharePasswordAlphanumeric {
+			set = chars[1:]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password[i] = set[n.Int64()]
+	}
+	return string(password), nil
 }
 
-// ValidateSharePassword validates that the password is exactly 4 digits
+// ValidateSharePassword checks that a share password has MinSharePasswordLength to
+// MaxSharePasswordLength ASCII letters and digits
 func ValidateSharePassword(password string) bool {
-	if len(password) != 4 {
+	if len(password) < MinSharePasswordLength || len(password) > MaxSharePasswordLength {
 		return false
 	}
 	for _, c := range password {
-		if c < '0' || c > '9' {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
 			return false
 		}
 	}
 	return true
 }
+
+// HashPassword hashes a password with bcrypt for storage
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHash reports whether a stored value is a bcrypt hash rather than a plain password
+func IsPasswordHash(value string) bool {
+	return strings.HasPrefix(value, "$2a$") || strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
+
+// VerifyPassword checks a password against a bcrypt hash, or in constant time against
+// a plain password (e.g. ADMIN_PASSWORD set without hashing it)
+func VerifyPassword(stored, password string) bool {
+	if stored == "" {
+		return false
+	}
+	if IsPasswordHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -9,7 +11,10 @@ func TestGenerateSharePassword(t *testing.T) {
 	// Generate multiple passwords
 	passwords := make(map[string]bool)
 	for i := 0; i < 100; i++ {
-		password := GenerateSharePassword()
+		password, err := GenerateSharePassword(0, "")
+		if err != nil {
+			t.Fatalf("GenerateSharePassword() = %v", err)
+		}
 
 		// Should be 4 characters
 		if len(password) != 4 {
@@ -38,6 +43,40 @@ func TestGenerateSharePassword(t *testing.T) {
 	}
 }
 
+func TestGenerateSharePassword_Options(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		password, err := GenerateSharePassword(12, SharePasswordAlphanumeric)
+		if err != nil {
+			t.Fatalf("GenerateSharePassword() = %v", err)
+		}
+		if len(password) != 12 || !ValidateSharePassword(password) {
+			t.Fatalf("Expected 12 letters or digits, got %q", password)
+		}
+		if strings.ContainsAny(password, "01ilo") {
+			t.Fatalf("Expected no look-alike characters, got %q", password)
+		}
+
+		digits, _ := GenerateSharePassword(MaxSharePasswordLength, SharePasswordDigits)
+		if len(digits) != MaxSharePasswordLength || digits[0] == '0' || strings.Trim(digits, "0123456789") != "" {
+			t.Fatalf("Expected %d digits not starting with 0, got %q", MaxSharePasswordLength, digits)
+		}
+	}
+
+	invalid := []struct {
+		length   int
+		alphabet string
+	}{
+		{MinSharePasswordLength - 1, SharePasswordDigits},
+		{MaxSharePasswordLength + 1, ""},
+		{8, "emoji"},
+	}
+	for _, tt := range invalid {
+		if _, err := GenerateSharePassword(tt.length, tt.alphabet); !errors.Is(err, ErrSharePasswordOptions) {
+			t.Errorf("GenerateSharePassword(%d, %q) = %v, want ErrSharePasswordOptions", tt.length, tt.alphabet, err)
+		}
+	}
+}
+
 func TestValidateSharePassword_Valid(t *testing.T) {
 	tests := []string{
 		"1000",
@@ -45,6 +84,9 @@ func TestValidateSharePassword_Valid(t *testing.T) {
 		"5678",
 		"9999",
 		"0000", // Edge case: technically valid format
+		"12345",
+		"abcd",
+		"Spring2024Wedding",
 	}
 
 	for _, password := range tests {
@@ -61,11 +103,10 @@ func TestValidateSharePassword_Invalid(t *testing.T) {
 	}{
 		{"empty", ""},
 		{"too short", "123"},
-		{"too long", "12345"},
-		{"contains letters", "12a4"},
+		{"too long", strings.Repeat("a", MaxSharePasswordLength+1)},
 		{"contains special chars", "12@4"},
 		{"contains spaces", "12 4"},
-		{"non-numeric", "abcd"},
+		{"non-ASCII", "密码1234"},
 		{"negative", "-123"},
 	}
 
@@ -81,7 +122,7 @@ func TestValidateSharePassword_Invalid(t *testing.T) {
 func TestGenerateSharePassword_Format(t *testing.T) {
 	// Test that generated passwords always pass validation
 	for i := 0; i < 100; i++ {
-		password := GenerateSharePassword()
+		password, _ := GenerateSharePassword(0, "")
 		if !ValidateSharePassword(password) {
 			t.Errorf("Generated password %q should pass validation", password)
 		}
@@ -94,7 +135,7 @@ func TestGenerateSharePassword_Randomness(t *testing.T) {
 	iterations := 1000
 
 	for i := 0; i < iterations; i++ {
-		password := GenerateSharePassword()
+		password, _ := GenerateSharePassword(DefaultSharePasswordLength, SharePasswordDigits)
 		passwords[password]++
 	}
 
@@ -133,7 +174,7 @@ func TestValidateSharePassword_Boundaries(t *testing.T) {
 		{"1001", true},  // Just above minimum
 		{"9998", true},  // Just below maximum
 		{"9999", true},  // Maximum
-		{"10000", false}, // Too long (5 digits)
+		{"10000", true}, // Longer passwords are allowed
 		{"999", false},  // Too short (3 digits)
 	}
 
@@ -144,3 +185,36 @@ func TestValidateSharePassword_Boundaries(t *testing.T) {
 		}
 	}
 }
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("Spring2024")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if !IsPasswordHash(hash) || strings.Contains(hash, "Spring2024") {
+		t.Fatalf("Expected a bcrypt hash, got %q", hash)
+	}
+	if other, _ := HashPassword("Spring2024"); other == hash {
+		t.Error("Hashes of the same password should be salted differently")
+	}
+
+	if !VerifyPassword(hash, "Spring2024") {
+		t.Error("The hashed password should verify")
+	}
+	if VerifyPassword(hash, "spring2024") || VerifyPassword(hash, "") {
+		t.Error("Other passwords should not verify")
+	}
+}
+
+func TestVerifyPasswordPlain(t *testing.T) {
+	// Plain values (e.g. an unhashed ADMIN_PASSWORD) are compared as they are
+	if !VerifyPassword("admin123", "admin123") {
+		t.Error("Matching plain password should verify")
+	}
+	if VerifyPassword("admin123", "admin12") || VerifyPassword("admin123", "admin1234") {
+		t.Error("Different plain passwords should not verify")
+	}
+	if VerifyPassword("", "") {
+		t.Error("An empty stored password should never verify")
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	placeholderBackground = color.NRGBA{R: 48, G: 52, B: 61, A: 255}
+	placeholderBadge      = color.NRGBA{R: 74, G: 144, B: 226, A: 255}
+	placeholderText       = color.NRGBA{R: 210, G: 214, B: 222, A: 255}
+)
+
+// ComposeRawPlaceholder renders the stand-in thumbnail for a photo that only has a RAW
+// file: a 3:2 tile with the extension as a badge and the base name underneath.
+// fontPath is the optional SHARE_CARD_FONT, needed for CJK file names.
+func ComposeRawPlaceholder(baseName, ext string, width int, fontPath string) ([]byte, error) {
+	height := width * 2 / 3
+	canvas := imaging.New(width, height, placeholderBackground)
+
+	badgeFace, err := loadShareCardFace(fontPath, gobold.TTF, float64(width)/10)
+	if err != nil {
+		return nil, err
+	}
+	defer badgeFace.Close()
+	nameFace, err := loadShareCardFace(fontPath, goregular.TTF, float64(width)/20)
+	if err != nil {
+		return nil, err
+	}
+	defer nameFace.Close()
+
+	badge := strings.ToUpper(strings.TrimPrefix(ext, "."))
+	if badge == "" {
+		badge = "RAW"
+	}
+	badgeWidth := font.MeasureString(badgeFace, badge).Ceil()
+	badgeHeight := badgeFace.Metrics().Ascent.Ceil()
+	padX, padY := width/30, width/60
+	badgeRect := image.Rect(
+		(width-badgeWidth)/2-padX, height*45/100-badgeHeight-padY,
+		(width+badgeWidth)/2+padX, height*45/100+padY,
+	)
+	draw.Draw(canvas, badgeRect, image.NewUniform(placeholderBadge), image.Point{}, draw.Src)
+	drawCenteredText(canvas, badgeFace, badge, height*45/100, color.White)
+
+	margin := width / 16
+	drawCenteredText(canvas, nameFace, fitText(nameFace, baseName, width-2*margin), height*72/100, placeholderText)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: JpegQualitySmall}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func drawCenteredText(dst *image.NRGBA, face font.Face, text string, baseline int, c color.Color) {
+	x := (dst.Bounds().Dx() - font.MeasureString(face, text).Ceil()) / 2
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, baseline),
+	}
+	d.DrawString(text)
+}
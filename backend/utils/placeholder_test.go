@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"bytes"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComposeRawPlaceholder(t *testing.T) {
+	for _, width := range []int{ThumbSmallWidth, ThumbLargeWidth} {
+		data, err := ComposeRawPlaceholder("DSC_0042", ".nef", width, "")
+		if err != nil {
+			t.Fatalf("ComposeRawPlaceholder(%d) failed: %v", width, err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Placeholder is not a valid JPEG: %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != width || b.Dy() != width*2/3 {
+			t.Errorf("Placeholder size = %dx%d, expected %dx%d", b.Dx(), b.Dy(), width, width*2/3)
+		}
+	}
+
+	// Long names are truncated rather than failing
+	if _, err := ComposeRawPlaceholder(strings.Repeat("very_long_name_", 20), ".cr3", ThumbSmallWidth, ""); err != nil {
+		t.Errorf("Long base name failed: %v", err)
+	}
+	if _, err := ComposeRawPlaceholder("IMG", ".arw", ThumbSmallWidth, filepath.Join(t.TempDir(), "missing.ttf")); err == nil {
+		t.Error("Expected error for missing font")
+	}
+}
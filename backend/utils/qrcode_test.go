@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRenderQRCodePNG(t *testing.T) {
+	data, err := RenderQRCodePNG("https://photos.example.com/s/abc", 256)
+	if err != nil {
+		t.Fatalf("RenderQRCodePNG() = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG, got %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+		t.Errorf("Expected 256x256, got %v", b)
+	}
+}
+
+func TestRenderQRCodeSVG(t *testing.T) {
+	data, err := RenderQRCodeSVG("https://photos.example.com/s/abc#password=x", 300)
+	if err != nil {
+		t.Fatalf("RenderQRCodeSVG() = %v", err)
+	}
+	svg := string(data)
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>") {
+		t.Fatalf("Expected an SVG document, got %q", svg)
+	}
+	if !strings.Contains(svg, `width="300"`) || !strings.Contains(svg, "M") {
+		t.Errorf("Expected a 300px drawing with dark modules, got %q", svg)
+	}
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConvertCommand is returned when the conversion command template cannot be used
+var ErrInvalidConvertCommand = errors.New("convert command must contain {input} and {output}")
+
+// buildConvertArgs splits a command template such as "darktable-cli {input} {output}"
+// and substitutes the placeholders. No shell is involved; wrap the command in
+// "sh -c" to use pipes.
+func buildConvertArgs(template, input, output string) ([]string, error) {
+	if !strings.Contains(template, "{input}") || !strings.Contains(template, "{output}") {
+		return nil, ErrInvalidConvertCommand
+	}
+	args, err := splitCommandLine(template)
+	if err != nil {
+		return nil, err
+	}
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, "{input}", input)
+		args[i] = strings.ReplaceAll(arg, "{output}", output)
+	}
+	return args, nil
+}
+
+// splitCommandLine splits on whitespace, keeping single- or double-quoted parts together
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in convert command")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// ConvertRawToJPEG runs the external converter to render a RAW (or HEIF) file as JPEG.
+// The result is written to a temporary file and renamed into place only if it is a valid JPEG.
+func ConvertRawToJPEG(ctx context.Context, template, input, output string) error {
+	ext := filepath.Ext(output)
+	partial := strings.TrimSuffix(output, ext) + ".partial" + ext
+	os.Remove(partial) // Some converters refuse to overwrite existing files
+
+	args, err := buildConvertArgs(template, input, partial)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second // Don't hang on child processes still holding stderr after a kill
+	if err := cmd.Run(); err != nil {
+		os.Remove(partial)
+		if ctx.Err() != nil {
+			return fmt.Errorf("conversion timed out: %w", ctx.Err())
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return fmt.Errorf("converter failed: %v: %s", err, msg)
+	}
+
+	if err := checkJPEG(partial); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	if err := os.Rename(partial, output); err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("failed to save converted file: %w", err)
+	}
+	return nil
+}
+
+func checkJPEG(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("converter produced no output: %w", err)
+	}
+	defer f.Close()
+	if _, err := jpeg.DecodeConfig(f); err != nil {
+		return fmt.Errorf("converter output is not a JPEG: %w", err)
+	}
+	return nil
+}
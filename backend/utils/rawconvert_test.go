@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildConvertArgs(t *testing.T) {
+	args, err := buildConvertArgs("darktable-cli {input} {output} --hq true", "/in/a.cr2", "/out/a.jpg")
+	if err != nil {
+		t.Fatalf("buildConvertArgs failed: %v", err)
+	}
+	expected := []string{"darktable-cli", "/in/a.cr2", "/out/a.jpg", "--hq", "true"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Got %v, expected %v", args, expected)
+	}
+
+	args, err = buildConvertArgs(`sh -c "dcraw -c $0 | cjpeg > $1" {input} {output}`, "/in/a b.cr2", "/out/a.jpg")
+	if err != nil {
+		t.Fatalf("buildConvertArgs failed: %v", err)
+	}
+	expected = []string{"sh", "-c", "dcraw -c $0 | cjpeg > $1", "/in/a b.cr2", "/out/a.jpg"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Got %v, expected %v", args, expected)
+	}
+
+	if _, err := buildConvertArgs(`sh -c "unterminated {input} {output}`, "a", "b"); err == nil {
+		t.Error("Expected error for unterminated quote")
+	}
+	if _, err := buildConvertArgs("dcraw -c {input}", "a", "b"); err != ErrInvalidConvertCommand {
+		t.Errorf("Expected ErrInvalidConvertCommand without {output}, got %v", err)
+	}
+}
+
+func TestConvertRawToJPEG(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "photo.cr2")
+	createTestImage(t, input, 40, 30, "jpeg") // Stand-in RAW: cp "converts" it to a JPEG
+	output := filepath.Join(dir, "converted", "photo.jpg")
+
+	if err := ConvertRawToJPEG(context.Background(), "cp {input} {output}", input, output); err != nil {
+		t.Fatalf("ConvertRawToJPEG failed: %v", err)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Errorf("Converted file missing: %v", err)
+	}
+}
+
+func TestConvertRawToJPEGErrors(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "photo.cr2")
+	os.WriteFile(input, []byte("not an image"), 0644)
+	output := filepath.Join(dir, "photo.jpg")
+
+	if err := ConvertRawToJPEG(context.Background(), "false {input} {output}", input, output); err == nil {
+		t.Error("Expected error when the converter fails")
+	}
+	if err := ConvertRawToJPEG(context.Background(), "cp {input} {output}", input, output); err == nil {
+		t.Error("Expected error when the output is not a JPEG")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("Invalid output should not be kept")
+	}
+
+	script := filepath.Join(dir, "slow.sh")
+	os.WriteFile(script, []byte("sleep 5\n"), 0644)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := ConvertRawToJPEG(ctx, "sh "+script+" {input} {output}", input, output); err == nil {
+		t.Error("Expected timeout error")
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Converter should be killed on timeout")
+	}
+}
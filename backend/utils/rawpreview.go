@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+	"io"
+	"os"
+)
+
+// ErrNoRawPreview is returned when a RAW file has no embedded JPEG that can be decoded
+var ErrNoRawPreview = errors.New("no embedded JPEG preview found")
+
+// TIFF tags pointing at embedded previews
+const (
+	tagCompression      = 0x0103
+	tagStripOffsets     = 0x0111
+	tagStripByteCounts  = 0x0117
+	tagSubIFDs          = 0x014A
+	tagJPEGOffset       = 0x0201 // JPEGInterchangeFormat
+	tagJPEGLength       = 0x0202 // JPEGInterchangeFormatLength
+	tagPanasonicPreview = 0x002E // JpgFromRaw of RW2 files, stored as the tag's value
+)
+
+const (
+	// maxRawIFDs bounds the IFDs visited in one file against looping offsets
+	maxRawIFDs = 32
+	// maxRawPreviewSize skips "previews" that are really sensor data with a JPEG header
+	maxRawPreviewSize = 64 << 20
+	// cr3PreviewScan is how far into a CR3 file the PRVW box is searched for
+	cr3PreviewScan = 8 << 20
+)
+
+// rawPreviewRange is the byte range of a candidate JPEG inside a RAW file
+type rawPreviewRange struct {
+	offset int64
+	length int64
+}
+
+// ExtractRawPreview returns the largest JPEG preview embedded in a RAW file and the
+// orientation of the RAW (1 when unknown), which the previews themselves don't carry.
+// TIFF-based formats (CR2, NEF, ARW, DNG, ORF, RW2, PEF, SRW) reference previews from
+// their IFDs, Fujifilm RAF records one in its header and Canon CR3 keeps one in a
+// PRVW box. Sensor data compressed as lossless JPEG fails to decode and is skipped.
+func ExtractRawPreview(path string) ([]byte, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	size := info.Size()
+
+	header := make([]byte, 16)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return nil, 0, ErrNoRawPreview
+	}
+	var candidates []rawPreviewRange
+	orientation := 1
+	switch {
+	case bytes.HasPrefix(header, []byte("FUJIFILMCCD-RAW")):
+		candidates = rafPreviews(f)
+	case string(header[4:12]) == "ftypcrx ":
+		candidates = cr3Previews(f, size)
+	default:
+		candidates, orientation = tiffPreviews(f, size)
+	}
+
+	var best rawPreviewRange
+	bestPixels := 0
+	for _, candidate := range candidates {
+		if candidate.offset <= 0 || candidate.length < 4 || candidate.length > maxRawPreviewSize || candidate.offset+candidate.length > size {
+			continue
+		}
+		cfg, err := jpeg.DecodeConfig(io.NewSectionReader(f, candidate.offset, candidate.length))
+		if err != nil {
+			continue
+		}
+		if pixels := cfg.Width * cfg.Height; pixels > bestPixels {
+			best, bestPixels = candidate, pixels
+		}
+	}
+	if bestPixels == 0 {
+		return nil, 0, ErrNoRawPreview
+	}
+
+	data := make([]byte, best.length)
+	if _, err := f.ReadAt(data, best.offset); err != nil {
+		return nil, 0, err
+	}
+	return data, orientation, nil
+}
+
+// GenerateRawThumbnails creates small and large thumbnails from the embedded preview of
+// a RAW file, turned upright. Width and Height are those of the preview, which may be
+// smaller than the sensor image.
+func GenerateRawThumbnails(ctx context.Context, rawPath string) (*ThumbnailResult, error) {
+	preview, orientation, err := ExtractRawPreview(rawPath)
+	if err != nil {
+		return nil, err
+	}
+	img, err := jpeg.Decode(contextReader{ctx: ctx, r: bytes.NewReader(preview)})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	preview = nil
+	return thumbnailsFromImage(ctx, applyOrientation(img, orientation))
+}
+
+// tiffPreviews collects the JPEGs referenced from the IFDs of a TIFF-based RAW file:
+// the IFD chain, sub-IFDs and Panasonic's JpgFromRaw tag. The magic number is not
+// checked since ORF and RW2 use their own.
+func tiffPreviews(r io.ReaderAt, size int64) ([]rawPreviewRange, int) {
+	head := make([]byte, 8)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, 1
+	}
+	var order binary.ByteOrder
+	switch string(head[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 1
+	}
+
+	var candidates []rawPreviewRange
+	orientation := 1
+	pending := []int64{int64(order.Uint32(head[4:]))}
+	visited := make(map[int64]bool)
+	for len(pending) > 0 && len(visited) < maxRawIFDs {
+		offset := pending[0]
+		pending = pending[1:]
+		if visited[offset] || offset < 8 || offset >= size {
+			continue
+		}
+		isIFD0 := len(visited) == 0
+		visited[offset] = true
+
+		entries, next, err := readIFD(r, order, offset)
+		if err != nil {
+			continue
+		}
+		var jpegRange, strip rawPreviewRange
+		var compression uint32
+		for _, entry := range entries {
+			switch entry.tag {
+			case tagJPEGOffset:
+				jpegRange.offset = int64(entry.value(order))
+			case tagJPEGLength:
+				jpegRange.length = int64(entry.value(order))
+			case tagCompression:
+				compression = entry.value(order)
+			case tagStripOffsets:
+				if entry.count == 1 {
+					strip.offset = int64(entry.value(order))
+				}
+			case tagStripByteCounts:
+				if entry.count == 1 {
+					strip.length = int64(entry.value(order))
+				}
+			case tagPanasonicPreview:
+				candidates = append(candidates, rawPreviewRange{int64(order.Uint32(entry.data[:])), int64(entry.count)})
+			case tagSubIFDs:
+				pending = append(pending, subIFDOffsets(r, order, entry)...)
+			case tagOrientation:
+				if o := int(entry.value(order)); isIFD0 && o >= 2 && o <= 8 {
+					orientation = o
+				}
+			}
+		}
+		candidates = append(candidates, jpegRange)
+		// Old-style JPEG (6) or JPEG (7) strips: previews in NEF/DNG, also lossless sensor data
+		if compression == 6 || compression == 7 {
+			candidates = append(candidates, strip)
+		}
+		if next != 0 {
+			pending = append(pending, next)
+		}
+	}
+	return candidates, orientation
+}
+
+// ifdEntry is one 12-byte IFD entry; data holds the value or its offset
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  [4]byte
+}
+
+// value returns a single SHORT or LONG value stored in the entry
+func (e ifdEntry) value(order binary.ByteOrder) uint32 {
+	if e.typ == 3 {
+		return uint32(order.Uint16(e.data[:]))
+	}
+	return order.Uint32(e.data[:])
+}
+
+// readIFD reads the entries of the IFD at offset and the offset of the next one
+func readIFD(r io.ReaderAt, order binary.ByteOrder, offset int64) ([]ifdEntry, int64, error) {
+	countBuf := make([]byte, 2)
+	if _, err := r.ReadAt(countBuf, offset); err != nil {
+		return nil, 0, err
+	}
+	count := int(order.Uint16(countBuf))
+	if count == 0 || count > 1000 {
+		return nil, 0, ErrNoRawPreview
+	}
+	table := make([]byte, count*12+4)
+	if _, err := r.ReadAt(table, offset+2); err != nil {
+		return nil, 0, err
+	}
+	entries := make([]ifdEntry, count)
+	for i := range entries {
+		raw := table[i*12:]
+		entries[i] = ifdEntry{tag: order.Uint16(raw), typ: order.Uint16(raw[2:]), count: order.Uint32(raw[4:])}
+		copy(entries[i].data[:], raw[8:12])
+	}
+	return entries, int64(order.Uint32(table[count*12:])), nil
+}
+
+// subIFDOffsets returns the offsets listed by a SubIFDs entry
+func subIFDOffsets(r io.ReaderAt, order binary.ByteOrder, entry ifdEntry) []int64 {
+	if entry.count == 1 {
+		return []int64{int64(order.Uint32(entry.data[:]))}
+	}
+	if entry.count == 0 || entry.count > maxRawIFDs {
+		return nil
+	}
+	buf := make([]byte, entry.count*4)
+	if _, err := r.ReadAt(buf, int64(order.Uint32(entry.data[:]))); err != nil {
+		return nil
+	}
+	offsets := make([]int64, entry.count)
+	for i := range offsets {
+		offsets[i] = int64(order.Uint32(buf[i*4:]))
+	}
+	return offsets
+}
+
+// rafPreviews returns the JPEG whose offset and length a RAF header records at byte 84
+func rafPreviews(r io.ReaderAt) []rawPreviewRange {
+	buf := make([]byte, 8)
+	if _, err := r.ReadAt(buf, 84); err != nil {
+		return nil
+	}
+	return []rawPreviewRange{{int64(binary.BigEndian.Uint32(buf)), int64(binary.BigEndian.Uint32(buf[4:]))}}
+}
+
+// cr3Previews returns the JPEG of a CR3 file's PRVW box: after the box type come
+// 16 bytes of header (dimensions and, last, the JPEG length) and then the JPEG
+func cr3Previews(r io.ReaderAt, size int64) []rawPreviewRange {
+	buf := make([]byte, min(size, cr3PreviewScan))
+	n, _ := r.ReadAt(buf, 0)
+	buf = buf[:n]
+	i := bytes.Index(buf, []byte("PRVW"))
+	if i < 0 || i+20 > len(buf) {
+		return nil
+	}
+	return []rawPreviewRange{{int64(i + 20), int64(binary.BigEndian.Uint32(buf[i+16:]))}}
+}
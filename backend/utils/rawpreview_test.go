@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func encodeTestJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// writeTestRaw writes a little-endian TIFF laid out like a CR2/NEF: IFD0 with an
+// orientation and a small JPEG, and a sub-IFD with a larger one
+func writeTestRaw(t *testing.T, path string, orientation uint16, small, large []byte) {
+	t.Helper()
+	le := binary.LittleEndian
+	const ifd0, subIFD = 8, 8 + 2 + 4*12 + 4
+	smallOffset := uint32(subIFD + 2 + 2*12 + 4)
+	largeOffset := smallOffset + uint32(len(small))
+
+	var raw bytes.Buffer
+	raw.WriteString("II*\x00")
+	binary.Write(&raw, le, uint32(ifd0))
+
+	binary.Write(&raw, le, uint16(4))
+	binary.Write(&raw, le, []uint16{tagOrientation, 3})
+	binary.Write(&raw, le, []uint32{1, uint32(orientation)})
+	binary.Write(&raw, le, []uint16{tagSubIFDs, 4})
+	binary.Write(&raw, le, []uint32{1, subIFD})
+	binary.Write(&raw, le, []uint16{tagJPEGOffset, 4})
+	binary.Write(&raw, le, []uint32{1, smallOffset})
+	binary.Write(&raw, le, []uint16{tagJPEGLength, 4})
+	binary.Write(&raw, le, []uint32{1, uint32(len(small))})
+	binary.Write(&raw, le, uint32(0))
+
+	binary.Write(&raw, le, uint16(2))
+	binary.Write(&raw, le, []uint16{tagJPEGOffset, 4})
+	binary.Write(&raw, le, []uint32{1, largeOffset})
+	binary.Write(&raw, le, []uint16{tagJPEGLength, 4})
+	binary.Write(&raw, le, []uint32{1, uint32(len(large))})
+	binary.Write(&raw, le, uint32(0))
+
+	raw.Write(small)
+	raw.Write(large)
+	if err := os.WriteFile(path, raw.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write RAW: %v", err)
+	}
+}
+
+func TestExtractRawPreviewTIFF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_0001.CR2")
+	large := encodeTestJPEG(t, 600, 400)
+	writeTestRaw(t, path, 6, encodeTestJPEG(t, 160, 120), large)
+
+	preview, orientation, err := ExtractRawPreview(path)
+	if err != nil {
+		t.Fatalf("ExtractRawPreview() = %v", err)
+	}
+	if !bytes.Equal(preview, large) || orientation != 6 {
+		t.Errorf("Expected the 600x400 preview with orientation 6, got %d bytes with orientation %d", len(preview), orientation)
+	}
+
+	result, err := GenerateRawThumbnails(context.Background(), path)
+	if err != nil {
+		t.Fatalf("GenerateRawThumbnails() = %v", err)
+	}
+	if result.Width != 400 || result.Height != 600 || len(result.Small) == 0 {
+		t.Errorf("Expected upright 400x600 thumbnails, got %dx%d", result.Width, result.Height)
+	}
+}
+
+func TestExtractRawPreviewRAF(t *testing.T) {
+	preview := encodeTestJPEG(t, 300, 200)
+	header := make([]byte, 100)
+	copy(header, "FUJIFILMCCD-RAW 0201")
+	binary.BigEndian.PutUint32(header[84:], 100)
+	binary.BigEndian.PutUint32(header[88:], uint32(len(preview)))
+	path := filepath.Join(t.TempDir(), "DSCF0001.RAF")
+	os.WriteFile(path, append(header, preview...), 0644)
+
+	got, orientation, err := ExtractRawPreview(path)
+	if err != nil || !bytes.Equal(got, preview) || orientation != 1 {
+		t.Errorf("Expected the RAF preview, got %d bytes, orientation %d, err %v", len(got), orientation, err)
+	}
+}
+
+func TestExtractRawPreviewMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.NEF")
+	// The IFDs reference data that isn't a JPEG and an empty preview
+	writeTestRaw(t, path, 1, []byte("not a jpeg"), nil)
+	if _, _, err := ExtractRawPreview(path); !errors.Is(err, ErrNoRawPreview) {
+		t.Errorf("Expected ErrNoRawPreview, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is appended to rotated log file names (app-20240102-150405.000.log)
+const backupTimeFormat = "20060102-150405.000"
+
+// RotatingFile is an io.Writer that writes to a log file and rotates it
+// when it grows beyond MaxSize bytes or when an Interval boundary (e.g. midnight UTC
+// for 24h) has passed since the current file was started.
+// Rotated files are kept up to MaxBackups files and MaxAge in age (0 = unlimited).
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+	MaxAge     time.Duration
+	Interval   time.Duration
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// NewRotatingFile opens (or creates) the log file at path and returns a rotating writer
+func NewRotatingFile(path string, maxSizeMB, maxBackups, maxAgeDays int, interval time.Duration) (*RotatingFile, error) {
+	r := &RotatingFile{
+		Path:       path,
+		MaxSize:    int64(maxSizeMB) << 20,
+		MaxBackups: maxBackups,
+		MaxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		Interval:   interval,
+		now:        time.Now,
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write implements io.Writer, rotating the file first if needed
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) shouldRotate(incoming int64) bool {
+	if r.MaxSize > 0 && r.size > 0 && r.size+incoming > r.MaxSize {
+		return true
+	}
+	if r.Interval > 0 && r.now().Truncate(r.Interval).After(r.openedAt.Truncate(r.Interval)) {
+		return true
+	}
+	return false
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	r.openedAt = r.now()
+	if r.size > 0 {
+		r.openedAt = r.startedAt(info)
+	}
+	return nil
+}
+
+// startedAt estimates when an existing log file was started, so restarts do not
+// reset the rotation interval: the newest rotation time if there is a backup,
+// otherwise the file's last modification time
+func (r *RotatingFile) startedAt(info os.FileInfo) time.Time {
+	ext := filepath.Ext(r.Path)
+	prefix := strings.TrimSuffix(r.Path, ext) + "-"
+	backups, _ := filepath.Glob(prefix + "*" + ext)
+
+	var latest time.Time
+	for _, b := range backups {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(b, prefix), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	if latest.IsZero() {
+		return info.ModTime()
+	}
+	return latest
+}
+
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+
+	ext := filepath.Ext(r.Path)
+	backup := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.Path, ext), r.now().Format(backupTimeFormat), ext)
+	if err := os.Rename(r.Path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.cleanup()
+	return nil
+}
+
+// cleanup removes rotated files beyond MaxBackups or older than MaxAge
+func (r *RotatingFile) cleanup() {
+	ext := filepath.Ext(r.Path)
+	pattern := strings.TrimSuffix(r.Path, ext) + "-*" + ext
+	backups, err := filepath.Glob(pattern)
+	if err != nil || len(backups) == 0 {
+		return
+	}
+
+	// Timestamped names sort chronologically; newest first
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	cutoff := r.now().Add(-r.MaxAge)
+	for i, b := range backups {
+		remove := r.MaxBackups > 0 && i >= r.MaxBackups
+		if !remove && r.MaxAge > 0 {
+			if info, err := os.Stat(b); err == nil && info.ModTime().Before(cutoff) {
+				remove = true
+			}
+		}
+		if remove {
+			os.Remove(b)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listBackups(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	return matches
+}
+
+func TestRotatingFileWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	r, err := NewRotatingFile(path, 1, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := r.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	if string(data) != "hello\n" {
+		t.Errorf("Unexpected log content %q", string(data))
+	}
+	if len(listBackups(t, dir)) != 0 {
+		t.Error("No rotation expected for small writes")
+	}
+}
+
+func TestRotatingFileSizeRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	r, err := NewRotatingFile(path, 1, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+	r.MaxSize = 10
+
+	r.Write([]byte("0123456789"))
+	r.Write([]byte("next"))
+
+	backups := listBackups(t, dir)
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup after exceeding size, got %d", len(backups))
+	}
+	old, _ := os.ReadFile(backups[0])
+	if string(old) != "0123456789" {
+		t.Errorf("Backup should hold old content, got %q", string(old))
+	}
+	current, _ := os.ReadFile(path)
+	if string(current) != "next" {
+		t.Errorf("Current log should hold new content, got %q", string(current))
+	}
+}
+
+func TestRotatingFileIntervalRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	r, err := NewRotatingFile(path, 100, 0, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	r.Write([]byte("first\n"))
+
+	now = now.Add(2 * time.Hour)
+	r.Write([]byte("second\n"))
+
+	if len(listBackups(t, dir)) != 1 {
+		t.Errorf("Expected time-based rotation to create a backup")
+	}
+}
+
+func TestRotatingFileIntervalSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// A log written two days ago by a previous run
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Failed to set mtime: %v", err)
+	}
+
+	r, err := NewRotatingFile(path, 100, 0, 0, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+	r.Write([]byte("new\n"))
+
+	if len(listBackups(t, dir)) != 1 {
+		t.Fatalf("Expected the stale log to be rotated after restart")
+	}
+	current, _ := os.ReadFile(path)
+	if string(current) != "new\n" {
+		t.Errorf("Current log should hold new content, got %q", string(current))
+	}
+}
+
+func TestRotatingFileIntervalFromLastBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	// Last rotation two days ago; the current log was appended to just now
+	stamp := time.Now().Add(-48 * time.Hour).Format(backupTimeFormat)
+	os.WriteFile(filepath.Join(dir, "app-"+stamp+".log"), []byte("older\n"), 0644)
+	os.WriteFile(path, []byte("recent\n"), 0644)
+
+	r, err := NewRotatingFile(path, 100, 0, 0, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+	r.Write([]byte("new\n"))
+
+	if len(listBackups(t, dir)) != 2 {
+		t.Errorf("Expected rotation measured from the last backup")
+	}
+}
+
+func TestRotatingFileMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	r, err := NewRotatingFile(path, 1, 2, 0, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer r.Close()
+	r.MaxSize = 1
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	for i := 0; i < 5; i++ {
+		now = now.Add(time.Second)
+		r.Write([]byte("xx"))
+	}
+
+	backups := listBackups(t, dir)
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 backups kept, got %d: %s", len(backups), strings.Join(backups, ", "))
+	}
+}
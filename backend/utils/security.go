@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -180,7 +181,7 @@ func ValidateImageFile(filePath string, allowedTypes []string) (string, error) {
 			}
 		}
 		if !allowed {
-			return "", fmt.Errorf("image type not allowed: detected type is %s", detectedType)
+			return "", &FileTypeError{Ext: strings.ToLower(filepath.Ext(filePath)), DetectedType: detectedType}
 		}
 	}
 
@@ -188,7 +189,7 @@ func ValidateImageFile(filePath string, allowedTypes []string) (string, error) {
 }
 
 // ValidateRAWFile 验证文件是否为 RAW 格式（相机原始文件）
-// 由于 RAW 格式种类繁多且复杂，这里主要通过扩展名和一些常见的 magic bytes 验证
+// 通过各 RAW 格式的文件头验证；内容为其他照片格式（如改了扩展名的 JPEG）时返回 *FileTypeError
 func ValidateRAWFile(filePath string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -202,30 +203,66 @@ func ValidateRAWFile(filePath string) error {
 	if err != nil && err != io.EOF {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
+	if isRAWHeader(buffer[:n]) {
+		return nil
+	}
 
-	// 使用 mimetype 检测
-	mtype := mimetype.Detect(buffer[:n])
-	detectedType := mtype.String()
+	detectedType := mimetype.Detect(buffer[:n]).String()
+	if strings.HasPrefix(detectedType, "image/") {
+		return &FileTypeError{Ext: strings.ToLower(filepath.Ext(filePath)), DetectedType: detectedType}
+	}
+	return fmt.Errorf("file is not a RAW file: detected type is %s", detectedType)
+}
 
-	// RAW 文件可能被识别为 application/octet-stream 或特定的 RAW 格式
-	// 这里我们接受这些类型
-	acceptedTypes := []string{
-		"application/octet-stream", // 通用二进制文件
-		"image/x-canon-cr2",        // Canon CR2
-		"image/x-canon-crw",        // Canon CRW
-		"image/x-nikon-nef",        // Nikon NEF
-		"image/x-sony-arw",         // Sony ARW
-		"image/x-adobe-dng",        // Adobe DNG
-		"image/tiff",               // 某些 RAW 文件基于 TIFF
-	}
-
-	for _, acceptedType := range acceptedTypes {
-		if detectedType == acceptedType {
-			return nil
+// isRAWHeader reports whether a file starts like one of the supported RAW formats:
+// TIFF-based (CR2, NEF, ARW, DNG, PEF, SRW and most .raw), ORF, RW2, RAF, X3F, CRW
+// and CR3 (ISO base media with the crx brand)
+func isRAWHeader(header []byte) bool {
+	for _, signature := range []string{
+		"II*\x00", "MM\x00*", // TIFF
+		"IIRO", "IIRS", "MMOR", // Olympus ORF
+		"IIU\x00",                    // Panasonic RW2
+		"FUJIFILMCCD-RAW",            // Fujifilm RAF
+		"FOVb",                       // Sigma X3F
+		"II\x1a\x00\x00\x00HEAPCCDR", // Canon CRW
+	} {
+		if bytes.HasPrefix(header, []byte(signature)) {
+			return true
 		}
 	}
+	return len(header) >= 12 && string(header[4:12]) == "ftypcrx "
+}
+
+// FileTypeError reports a file whose content is a photo format other than the one
+// its extension names, e.g. a PNG saved as .jpg
+type FileTypeError struct {
+	Ext          string // The file's extension, lowercase
+	DetectedType string // MIME type detected from the content
+}
+
+func (e *FileTypeError) Error() string {
+	return fmt.Sprintf("content is %s, which does not match the %s extension", e.DetectedType, e.Ext)
+}
+
+// imageExtensionTypes are the MIME types (as detected by mimetype) each image
+// extension may contain
+var imageExtensionTypes = map[string][]string{
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png", "image/vnd.mozilla.apng"},
+	".gif":  {"image/gif"},
+	".webp": {"image/webp"},
+	".bmp":  {"image/bmp"},
+	".tif":  {"image/tiff"},
+	".tiff": {"image/tiff"},
+	".heic": {"image/heic", "image/heic-sequence", "image/heif", "image/heif-sequence"},
+	".heif": {"image/heic", "image/heic-sequence", "image/heif", "image/heif-sequence"},
+}
 
-	// 如果不是已知的 RAW 类型，返回警告（但不阻止，因为 RAW 格式太多了）
-	// 实际使用中，主要依靠扩展名验证
-	return nil
+// ValidateImageContent checks that an image file is an image of the type its
+// extension names (see ValidateImageFile); an image of another type is a *FileTypeError
+func ValidateImageContent(filePath string) error {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	_, err := ValidateImageFile(filePath, imageExtensionTypes[ext])
+	return err
 }
@@ -1,6 +1,16 @@
 package utils
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestValidatePathComponent(t *testing.T) {
 	tests := []struct {
@@ -103,3 +113,53 @@ func TestValidateFileName(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatePhotoContent(t *testing.T) {
+	var jpegData, pngData bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	jpeg.Encode(&jpegData, img, nil)
+	png.Encode(&pngData, img)
+
+	tests := []struct {
+		name     string
+		file     string
+		content  []byte
+		valid    bool
+		mismatch bool // Expect a *FileTypeError
+	}{
+		{"jpeg", "a.jpg", jpegData.Bytes(), true, false},
+		{"uppercase extension", "a.JPEG", jpegData.Bytes(), true, false},
+		{"png", "a.png", pngData.Bytes(), true, false},
+		{"png named jpg", "a.jpg", pngData.Bytes(), false, true},
+		{"text named jpg", "a.jpg", []byte("not an image"), false, false},
+		{"tiff raw", "a.cr2", []byte("II*\x00\x08\x00\x00\x00"), true, false},
+		{"big-endian tiff raw", "a.nef", []byte("MM\x00*\x00\x00\x00\x08"), true, false},
+		{"orf", "a.orf", []byte("IIRO\x08\x00\x00\x00"), true, false},
+		{"rw2", "a.rw2", []byte("IIU\x00\x08\x00\x00\x00"), true, false},
+		{"raf", "a.raf", []byte("FUJIFILMCCD-RAW 0201FF383501"), true, false},
+		{"cr3", "a.cr3", []byte("\x00\x00\x00\x18ftypcrx \x00\x00\x00\x01crx isom"), true, false},
+		{"jpeg named raw", "a.arw", jpegData.Bytes(), false, true},
+		{"text named raw", "a.dng", []byte("raw data"), false, false},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			os.WriteFile(path, tt.content, 0644)
+			var err error
+			if ext := strings.ToLower(filepath.Ext(tt.file)); ext == ".jpg" || ext == ".jpeg" || ext == ".png" {
+				err = ValidateImageContent(path)
+			} else {
+				err = ValidateRAWFile(path)
+			}
+			if (err == nil) != tt.valid {
+				t.Fatalf("Expected valid=%v, got %v", tt.valid, err)
+			}
+			var typeErr *FileTypeError
+			if errors.As(err, &typeErr) != tt.mismatch {
+				t.Errorf("Expected mismatch=%v, got %v", tt.mismatch, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"photobridge/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ShareAccessTokenPrefix marks share access tokens, so they can be told apart from
+// admin JWTs that the web app also sends as bearer tokens
+const ShareAccessTokenPrefix = "pbs_"
+
+// shareAccessScope is the only scope share access tokens grant
+const shareAccessScope = "share:read"
+
+type shareAccessClaims struct {
+	Scope           string `json:"scope"`
+	PasswordVersion string `json:"pwv"` // See sharePasswordVersion
+	jwt.RegisteredClaims
+}
+
+// shareAccessKey derives the signing key from the JWT secret, so a share access
+// token can never be accepted as an admin token (or the other way round)
+func shareAccessKey() []byte {
+	return []byte(config.AppConfig.JWTSecret + ":share-access")
+}
+
+// sharePasswordVersion fingerprints the password hash of a link, so tokens minted
+// before the password changed are rejected without revealing anything about it
+func sharePasswordVersion(passwordHash string) string {
+	h := hmac.New(sha256.New, shareAccessKey())
+	h.Write([]byte(passwordHash))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// IsShareAccessToken reports whether a bearer token looks like a share access token
+func IsShareAccessToken(token string) bool {
+	return strings.HasPrefix(token, ShareAccessTokenPrefix)
+}
+
+// GenerateShareAccessToken mints a read-only bearer token for one share link, valid
+// while the link's password stays passwordHash
+func GenerateShareAccessToken(shareToken, passwordHash string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := shareAccessClaims{
+		Scope:           shareAccessScope,
+		PasswordVersion: sharePasswordVersion(passwordHash),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   shareToken,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(shareAccessKey())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return ShareAccessTokenPrefix + signed, expiresAt, nil
+}
+
+// VerifyShareAccessToken checks that a share access token is valid, unexpired and
+// was minted for shareToken while its password hash was passwordHash
+func VerifyShareAccessToken(token, shareToken, passwordHash string) bool {
+	raw, ok := strings.CutPrefix(token, ShareAccessTokenPrefix)
+	if !ok || shareToken == "" {
+		return false
+	}
+
+	claims := &shareAccessClaims{}
+	parsed, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return shareAccessKey(), nil
+	})
+	if err != nil || !parsed.Valid {
+		return false
+	}
+	return claims.Scope == shareAccessScope && claims.Subject == shareToken &&
+		hmac.Equal([]byte(claims.PasswordVersion), []byte(sharePasswordVersion(passwordHash)))
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"photobridge/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestShareAccessToken(t *testing.T) {
+	if config.AppConfig == nil || config.AppConfig.JWTSecret == "" {
+		config.AppConfig = &config.Config{
+			JWTSecret: "test-secret-for-testing",
+		}
+	}
+
+	token, expiresAt, err := GenerateShareAccessToken("share-abc", "hash-1", time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateShareAccessToken failed: %v", err)
+	}
+	if !IsShareAccessToken(token) {
+		t.Errorf("Token should carry the %s prefix: %s", ShareAccessTokenPrefix, token)
+	}
+	if time.Until(expiresAt) < 59*time.Minute {
+		t.Errorf("Unexpected expiry %v", expiresAt)
+	}
+
+	if !VerifyShareAccessToken(token, "share-abc", "hash-1") {
+		t.Error("Token should be valid for its share link")
+	}
+	if VerifyShareAccessToken(token, "share-other", "hash-1") {
+		t.Error("Token must not be valid for another share link")
+	}
+	if VerifyShareAccessToken(token[len(ShareAccessTokenPrefix):], "share-abc", "hash-1") {
+		t.Error("Token without prefix should be rejected")
+	}
+	if VerifyShareAccessToken(token+"x", "share-abc", "hash-1") {
+		t.Error("Tampered token should be rejected")
+	}
+
+	// Changing the password revokes the token
+	if VerifyShareAccessToken(token, "share-abc", "hash-2") {
+		t.Error("Token must not be valid after the password changed")
+	}
+
+	expired, _, _ := GenerateShareAccessToken("share-abc", "hash-1", -time.Minute)
+	if VerifyShareAccessToken(expired, "share-abc", "hash-1") {
+		t.Error("Expired token should be rejected")
+	}
+}
+
+func TestShareAccessTokenNotAdminToken(t *testing.T) {
+	if config.AppConfig == nil || config.AppConfig.JWTSecret == "" {
+		config.AppConfig = &config.Config{
+			JWTSecret: "test-secret-for-testing",
+		}
+	}
+
+	// A JWT signed with the admin secret must not pass as a share access token
+	claims := jwt.MapClaims{"sub": "share-abc", "scope": "share:read", "exp": time.Now().Add(time.Hour).Unix()}
+	adminSigned, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JWTSecret))
+	if VerifyShareAccessToken(ShareAccessTokenPrefix+adminSigned, "share-abc", "hash-1") {
+		t.Error("Token signed with the admin key should be rejected")
+	}
+}
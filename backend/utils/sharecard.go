@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	ShareCardWidth   = 1200 // Open Graph recommended size
+	ShareCardHeight  = 630
+	shareCardQuality = 85
+	shareCardMargin  = 64
+)
+
+// ComposeShareCard renders a link preview image: the cover photo cropped to 1200x630
+// with the title and subtitle on a darkened band. An empty coverPath produces a plain
+// card (used for password-protected links). fontPath optionally names a TTF/OTF/TTC font,
+// required for CJK titles; the built-in Go fonts are used otherwise.
+func ComposeShareCard(coverPath, title, subtitle, fontPath string) ([]byte, error) {
+	var canvas *image.NRGBA
+	if coverPath != "" {
+		cover, err := imaging.Open(coverPath, imaging.AutoOrientation(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cover: %w", err)
+		}
+		canvas = imaging.Fill(cover, ShareCardWidth, ShareCardHeight, imaging.Center, imaging.Lanczos)
+	} else {
+		canvas = imaging.New(ShareCardWidth, ShareCardHeight, color.NRGBA{R: 38, G: 42, B: 51, A: 255})
+	}
+
+	// Darken the lower part so white text stays readable on bright photos
+	bandTop := ShareCardHeight * 55 / 100
+	for y := bandTop; y < ShareCardHeight; y++ {
+		alpha := uint8(200 * (y - bandTop) / (ShareCardHeight - bandTop))
+		draw.Draw(canvas, image.Rect(0, y, ShareCardWidth, y+1),
+			image.NewUniform(color.NRGBA{A: alpha}), image.Point{}, draw.Over)
+	}
+
+	titleFace, err := loadShareCardFace(fontPath, gobold.TTF, 60)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	subtitleFace, err := loadShareCardFace(fontPath, goregular.TTF, 30)
+	if err != nil {
+		return nil, err
+	}
+	defer subtitleFace.Close()
+
+	maxWidth := ShareCardWidth - 2*shareCardMargin
+	y := ShareCardHeight - shareCardMargin
+	if subtitle != "" {
+		drawShareCardText(canvas, subtitleFace, fitText(subtitleFace, subtitle, maxWidth), y, color.NRGBA{R: 220, G: 220, B: 220, A: 255})
+		y -= 52
+	}
+	drawShareCardText(canvas, titleFace, fitText(titleFace, title, maxWidth), y, color.White)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: shareCardQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// loadShareCardFace loads the configured font, falling back to the given built-in font
+func loadShareCardFace(fontPath string, fallback []byte, size float64) (font.Face, error) {
+	data := fallback
+	if fontPath != "" {
+		custom, err := os.ReadFile(fontPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read share card font: %w", err)
+		}
+		data = custom
+	}
+
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse share card font: %w", err)
+	}
+	f, err := collection.Font(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse share card font: %w", err)
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// fitText truncates text with an ellipsis so it fits within maxWidth pixels
+func fitText(face font.Face, text string, maxWidth int) string {
+	text = strings.TrimSpace(text)
+	if font.MeasureString(face, text).Ceil() <= maxWidth {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimSpace(string(runes)) + "…"
+		if font.MeasureString(face, candidate).Ceil() <= maxWidth {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func drawShareCardText(dst draw.Image, face font.Face, text string, baseline int, c color.Color) {
+	d := font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(shareCardMargin, baseline),
+	}
+	d.DrawString(text)
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestComposeShareCard(t *testing.T) {
+	dir := t.TempDir()
+	cover := filepath.Join(dir, "cover.jpg")
+	createTestImage(t, cover, 800, 1200, "jpeg")
+
+	for _, coverPath := range []string{cover, ""} {
+		data, err := ComposeShareCard(coverPath, "Summer Wedding", "42 photos", "")
+		if err != nil {
+			t.Fatalf("ComposeShareCard(%q) failed: %v", coverPath, err)
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Share card is not a valid JPEG: %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != ShareCardWidth || b.Dy() != ShareCardHeight {
+			t.Errorf("Share card size = %dx%d, expected %dx%d", b.Dx(), b.Dy(), ShareCardWidth, ShareCardHeight)
+		}
+	}
+}
+
+func TestComposeShareCardErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ComposeShareCard(filepath.Join(dir, "missing.jpg"), "Title", "", ""); err == nil {
+		t.Error("Expected error for missing cover")
+	}
+	if _, err := ComposeShareCard("", "Title", "", filepath.Join(dir, "missing.ttf")); err == nil {
+		t.Error("Expected error for missing font")
+	}
+}
+
+func TestFitText(t *testing.T) {
+	face, err := loadShareCardFace("", goregular.TTF, 30)
+	if err != nil {
+		t.Fatalf("Failed to load font: %v", err)
+	}
+	defer face.Close()
+
+	if got := fitText(face, "Short", 1000); got != "Short" {
+		t.Errorf("Short text should be unchanged, got %q", got)
+	}
+
+	long := strings.Repeat("Very long project name ", 20)
+	got := fitText(face, long, 400)
+	if !strings.HasSuffix(got, "…") || len(got) >= len(long) {
+		t.Errorf("Long text should be truncated with an ellipsis, got %q", got)
+	}
+}
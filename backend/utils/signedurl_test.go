@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"photobridge/config"
+)
+
+func setupSigningConfig(t *testing.T) {
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+
+	config.AppConfig = &config.Config{
+		CDNSignKey:        "sign-secret",
+		CDNSignParam:      "auth_key",
+		CDNSignTTLSeconds: 600,
+	}
+}
+
+func TestSignUploadURLDisabled(t *testing.T) {
+	originalConfig := config.AppConfig
+	defer func() { config.AppConfig = originalConfig }()
+	config.AppConfig = &config.Config{}
+
+	path := "/uploads/project/photo.jpg"
+	if got := SignUploadURL(path); got != path {
+		t.Errorf("Expected unsigned path when signing is disabled, got %q", got)
+	}
+	if !VerifyUploadSignature(path, "", time.Now()) {
+		t.Error("Verification should pass when signing is disabled")
+	}
+}
+
+func TestSignUploadURLTypeAFormat(t *testing.T) {
+	setupSigningConfig(t)
+
+	path := "/uploads/My%20Project/IMG_0001.jpg"
+	token := signUploadToken(path, 1700000000)
+
+	// Must match the CDN's own computation: md5("URI-timestamp-rand-uid-key")
+	sum := md5.Sum([]byte(path + "-1700000000-0-0-sign-secret"))
+	expected := "1700000000-0-0-" + hex.EncodeToString(sum[:])
+	if token != expected {
+		t.Errorf("Token = %q, expected %q", token, expected)
+	}
+
+	signed := SignUploadURL(path)
+	if !strings.HasPrefix(signed, path+"?auth_key=") {
+		t.Errorf("Unexpected signed URL %q", signed)
+	}
+}
+
+func TestVerifyUploadSignature(t *testing.T) {
+	setupSigningConfig(t)
+
+	path := "/uploads/project/photo.jpg"
+	now := time.Unix(1700000000, 0)
+	token := signUploadToken(path, now.Unix())
+
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		at       time.Time
+		expected bool
+	}{
+		{"Valid", path, token, now, true},
+		{"Near expiry", path, token, now.Add(599 * time.Second), true},
+		{"Expired", path, token, now.Add(601 * time.Second), false},
+		{"Signed in the future", path, token, now.Add(-10 * time.Minute), false},
+		{"Other path", "/uploads/project/other.jpg", token, now, false},
+		{"Tampered timestamp", path, "1700009999" + token[10:], now, false},
+		{"Malformed", path, "garbage", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyUploadSignature(tt.path, tt.token, tt.at); got != tt.expected {
+				t.Errorf("VerifyUploadSignature() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -2,28 +2,72 @@ package utils
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"image"
 	_ "image/gif"
 	"image/jpeg"
 	_ "image/png"
+	"io"
 	"os"
 
+	"photobridge/config"
+
 	"github.com/disintegration/imaging"
 	_ "golang.org/x/image/bmp"
 	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 )
 
+// Default thumbnail sizes and JPEG qualities (THUMB_SMALL_WIDTH, THUMB_LARGE_WIDTH,
+// THUMB_SMALL_QUALITY and THUMB_LARGE_QUALITY override them, see CurrentThumbParams)
 const (
 	ThumbSmallWidth  = 400
 	ThumbLargeWidth  = 1600
 	JpegQualitySmall = 75
 	JpegQualityLarge = 85
-
-	// For very large images, pre-shrink to reduce peak memory and resize cost.
-	preShrinkMaxLongSide = ThumbLargeWidth * 2
 )
 
+// ThumbParams are the sizes and JPEG qualities thumbnails are generated with
+type ThumbParams struct {
+	SmallWidth   int
+	LargeWidth   int
+	SmallQuality int
+	LargeQuality int
+}
+
+// DefaultThumbParams are the parameters without configuration; thumbnails generated
+// before the parameters were recorded used them
+var DefaultThumbParams = ThumbParams{ThumbSmallWidth, ThumbLargeWidth, JpegQualitySmall, JpegQualityLarge}
+
+// CurrentThumbParams returns the configured thumbnail parameters
+func CurrentThumbParams() ThumbParams {
+	if config.AppConfig == nil || config.AppConfig.ThumbLargeWidth == 0 {
+		return DefaultThumbParams
+	}
+	return ThumbParams{
+		SmallWidth:   config.AppConfig.ThumbSmallWidth,
+		LargeWidth:   config.AppConfig.ThumbLargeWidth,
+		SmallQuality: config.AppConfig.ThumbSmallQuality,
+		LargeQuality: config.AppConfig.ThumbLargeQuality,
+	}
+}
+
+// String identifies the parameters; it is recorded with each photo's thumbnails so
+// thumbnails generated with other settings can be found
+func (p ThumbParams) String() string {
+	return fmt.Sprintf("%dq%d-%dq%d", p.SmallWidth, p.SmallQuality, p.LargeWidth, p.LargeQuality)
+}
+
+// ThumbParamsCurrent reports whether thumbnails generated with the recorded
+// parameters (empty for thumbnails from before they were recorded) are up to date
+func ThumbParamsCurrent(recorded string) bool {
+	if recorded == "" {
+		recorded = DefaultThumbParams.String()
+	}
+	return recorded == CurrentThumbParams().String()
+}
+
 // ThumbnailResult contains generated thumbnails and source dimensions.
 type ThumbnailResult struct {
 	Small       []byte
@@ -32,73 +76,142 @@ type ThumbnailResult struct {
 	Height      int
 	SmallWidth  int
 	SmallHeight int
+	Params      string // ThumbParams the thumbnails were generated with
 }
 
-// GenerateThumbnails creates small and large JPEG thumbnails from an image file.
-func GenerateThumbnails(imagePath string) (*ThumbnailResult, error) {
+// ReadImageSize returns the pixel dimensions of an image from its header, without decoding it
+func ReadImageSize(imagePath string) (width, height int, ok bool) {
 	file, err := os.Open(imagePath)
 	if err != nil {
-		return nil, err
+		return 0, 0, false
 	}
 	defer file.Close()
 
 	cfg, _, err := image.DecodeConfig(file)
-	if err != nil {
-		return nil, err
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return 0, 0, false
 	}
-	if _, err := file.Seek(0, 0); err != nil {
+	return cfg.Width, cfg.Height, true
+}
+
+// preShrinkLongSide returns the size very large images are pre-shrunk to, to reduce
+// peak memory and resize cost: THUMB_PRESHRINK_PX, never below the large thumbnail
+// width, or twice that width by default
+func preShrinkLongSide(largeWidth int) int {
+	if config.AppConfig != nil && config.AppConfig.ThumbPreShrinkPx > 0 {
+		return max(config.AppConfig.ThumbPreShrinkPx, largeWidth)
+	}
+	return largeWidth * 2
+}
+
+// GenerateThumbnails creates small and large JPEG thumbnails from an image file. It
+// stops with ctx's error once ctx is done, during decoding or between the resize and
+// encode stages, so an abandoned job releases its buffers. HEIC/HEIF files are
+// converted with HEIF_CONVERT_COMMAND first (ErrHEIFUnsupported without one).
+func GenerateThumbnails(ctx context.Context, imagePath string) (*ThumbnailResult, error) {
+	if IsHEIFPath(imagePath) {
+		img, err := decodeHEIF(ctx, imagePath)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			return nil, err
+		}
+		return thumbnailsFromImage(ctx, img)
+	}
+
+	file, err := os.Open(imagePath)
+	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	img, _, err := image.Decode(contextReader{ctx: ctx, r: file})
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		return nil, err
 	}
+	return thumbnailsFromImage(ctx, img)
+}
+
+// contextReader fails reads once its context is done, which aborts a decode midway
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
 
+// thumbnailsFromImage creates small and large JPEG thumbnails from a decoded image,
+// checking ctx before each stage
+func thumbnailsFromImage(ctx context.Context, img image.Image) (*ThumbnailResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	params := CurrentThumbParams()
+	size := img.Bounds().Size()
 	result := &ThumbnailResult{
-		Width:  cfg.Width,
-		Height: cfg.Height,
+		Width:  size.X,
+		Height: size.Y,
+		Params: params.String(),
 	}
 
 	working := img
-	longSide := cfg.Width
-	if cfg.Height > longSide {
-		longSide = cfg.Height
-	}
+	longSide := max(size.X, size.Y)
+	preShrinkMaxLongSide := preShrinkLongSide(params.LargeWidth)
 	if longSide > preShrinkMaxLongSide {
 		// Pre-shrink huge images across all formats to lower memory/CPU in later stages.
-		if cfg.Width >= cfg.Height {
+		if size.X >= size.Y {
 			working = imaging.Resize(img, preShrinkMaxLongSide, 0, imaging.Box)
 		} else {
 			working = imaging.Resize(img, 0, preShrinkMaxLongSide, imaging.Box)
 		}
 		img = nil
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 
-	largeWidth := ThumbLargeWidth
-	if cfg.Width < largeWidth {
-		largeWidth = cfg.Width
+	largeWidth := params.LargeWidth
+	if size.X < largeWidth {
+		largeWidth = size.X
 	}
 	largeImg := imaging.Resize(working, largeWidth, 0, imaging.CatmullRom)
 	// Source image is no longer needed after the large thumbnail is created.
 	working = nil
 	img = nil
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Encode large first and release no-longer-needed references as early as possible.
 	var largeBuf bytes.Buffer
-	if err := jpeg.Encode(&largeBuf, largeImg, &jpeg.Options{Quality: JpegQualityLarge}); err != nil {
+	if err := jpeg.Encode(&largeBuf, largeImg, &jpeg.Options{Quality: params.LargeQuality}); err != nil {
 		return nil, err
 	}
 	result.Large = largeBuf.Bytes()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	smallImg := imaging.Resize(largeImg, ThumbSmallWidth, 0, imaging.Box)
+	smallImg := imaging.Resize(largeImg, params.SmallWidth, 0, imaging.Box)
 	largeImg = nil
 	smallBounds := smallImg.Bounds()
 	result.SmallWidth = smallBounds.Dx()
 	result.SmallHeight = smallBounds.Dy()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	var smallBuf bytes.Buffer
-	if err := jpeg.Encode(&smallBuf, smallImg, &jpeg.Options{Quality: JpegQualitySmall}); err != nil {
+	if err := jpeg.Encode(&smallBuf, smallImg, &jpeg.Options{Quality: params.SmallQuality}); err != nil {
 		return nil, err
 	}
 	result.Small = smallBuf.Bytes()
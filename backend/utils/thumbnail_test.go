@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"image"
 	"image/color"
 	"image/jpeg"
@@ -8,6 +11,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"photobridge/config"
 )
 
 func createTestImage(t *testing.T, path string, width, height int, format string) {
@@ -54,7 +60,7 @@ func TestGenerateThumbnailsJPEG(t *testing.T) {
 	createTestImage(t, imagePath, 2000, 1500, "jpeg")
 
 	// Generate thumbnails
-	result, err := GenerateThumbnails(imagePath)
+	result, err := GenerateThumbnails(context.Background(), imagePath)
 	if err != nil {
 		t.Fatalf("GenerateThumbnails failed: %v", err)
 	}
@@ -100,7 +106,7 @@ func TestGenerateThumbnailsPNG(t *testing.T) {
 	imagePath := filepath.Join(tempDir, "test.png")
 	createTestImage(t, imagePath, 2000, 1500, "png")
 
-	result, err := GenerateThumbnails(imagePath)
+	result, err := GenerateThumbnails(context.Background(), imagePath)
 	if err != nil {
 		t.Fatalf("GenerateThumbnails failed: %v", err)
 	}
@@ -128,7 +134,7 @@ func TestGenerateThumbnailsSmallImage(t *testing.T) {
 	imagePath := filepath.Join(tempDir, "small.jpg")
 	createTestImage(t, imagePath, 800, 600, "jpeg")
 
-	result, err := GenerateThumbnails(imagePath)
+	result, err := GenerateThumbnails(context.Background(), imagePath)
 	if err != nil {
 		t.Fatalf("GenerateThumbnails failed: %v", err)
 	}
@@ -150,7 +156,7 @@ func TestGenerateThumbnailsSmallImage(t *testing.T) {
 }
 
 func TestGenerateThumbnailsNonExistent(t *testing.T) {
-	_, err := GenerateThumbnails("/nonexistent/path/image.jpg")
+	_, err := GenerateThumbnails(context.Background(), "/nonexistent/path/image.jpg")
 	if err == nil {
 		t.Error("Should return error for non-existent file")
 	}
@@ -169,12 +175,61 @@ func TestGenerateThumbnailsInvalidImage(t *testing.T) {
 		t.Fatalf("Failed to create invalid file: %v", err)
 	}
 
-	_, err = GenerateThumbnails(invalidPath)
+	_, err = GenerateThumbnails(context.Background(), invalidPath)
 	if err == nil {
 		t.Error("Should return error for invalid image")
 	}
 }
 
+func TestGenerateThumbnailsCancelled(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "test.jpg")
+	createTestImage(t, imagePath, 2000, 1500, "jpeg")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GenerateThumbnails(ctx, imagePath); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the decode to stop with context.Canceled, got %v", err)
+	}
+
+	// A job cancelled after decoding stops before resizing
+	img := image.NewRGBA(image.Rect(0, 0, 2000, 1500))
+	if _, err := thumbnailsFromImage(ctx, img); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the resize to be skipped with context.Canceled, got %v", err)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	if _, err := GenerateThumbnails(expired, imagePath); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestGenerateThumbnailsHEIF(t *testing.T) {
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	imagePath := filepath.Join(t.TempDir(), "IMG_0001.HEIC")
+	createTestImage(t, imagePath, 2000, 1500, "jpeg") // Stand-in HEIC: cp "converts" it to a JPEG
+
+	config.AppConfig = &config.Config{RawConvertTimeout: 10}
+	if _, err := GenerateThumbnails(context.Background(), imagePath); !errors.Is(err, ErrHEIFUnsupported) {
+		t.Errorf("Expected ErrHEIFUnsupported without a converter, got %v", err)
+	}
+
+	config.AppConfig.HEIFConvertCommand = "cp {input} {output}"
+	result, err := GenerateThumbnails(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("GenerateThumbnails failed: %v", err)
+	}
+	if result.Width != 2000 || result.Height != 1500 || len(result.Small) == 0 || len(result.Large) == 0 {
+		t.Errorf("Expected thumbnails of a 2000x1500 image, got %dx%d", result.Width, result.Height)
+	}
+
+	config.AppConfig.HEIFConvertCommand = "false {input} {output}"
+	if _, err := GenerateThumbnails(context.Background(), imagePath); err == nil {
+		t.Error("Expected an error when the converter fails")
+	}
+}
+
 func TestThumbnailConstants(t *testing.T) {
 	if ThumbSmallWidth <= 0 {
 		t.Error("ThumbSmallWidth should be positive")
@@ -193,6 +248,34 @@ func TestThumbnailConstants(t *testing.T) {
 	}
 }
 
+func TestGenerateThumbnailsConfiguredParams(t *testing.T) {
+	originalConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = originalConfig })
+	config.AppConfig = &config.Config{ThumbSmallWidth: 200, ThumbLargeWidth: 800, ThumbSmallQuality: 60, ThumbLargeQuality: 90}
+
+	imagePath := filepath.Join(t.TempDir(), "test.jpg")
+	createTestImage(t, imagePath, 2000, 1500, "jpeg")
+	result, err := GenerateThumbnails(context.Background(), imagePath)
+	if err != nil {
+		t.Fatalf("GenerateThumbnails failed: %v", err)
+	}
+	if result.SmallWidth != 200 || result.Params != "200q60-800q90" {
+		t.Errorf("Expected thumbnails with the configured parameters, got width %d and %q", result.SmallWidth, result.Params)
+	}
+	large, _, err := image.DecodeConfig(bytes.NewReader(result.Large))
+	if err != nil || large.Width != 800 {
+		t.Errorf("Expected a large thumbnail 800 wide, got %d (%v)", large.Width, err)
+	}
+
+	if ThumbParamsCurrent("") {
+		t.Error("Expected thumbnails from before parameters were recorded to be outdated")
+	}
+	config.AppConfig = nil
+	if !ThumbParamsCurrent("") || ThumbParamsCurrent("200q60-800q90") {
+		t.Error("Expected unrecorded parameters to be the defaults")
+	}
+}
+
 func TestGenerateThumbnailsAspectRatio(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "thumbtest")
 	if err != nil {
@@ -204,7 +287,7 @@ func TestGenerateThumbnailsAspectRatio(t *testing.T) {
 	imagePath := filepath.Join(tempDir, "aspect.jpg")
 	createTestImage(t, imagePath, 4000, 3000, "jpeg")
 
-	result, err := GenerateThumbnails(imagePath)
+	result, err := GenerateThumbnails(context.Background(), imagePath)
 	if err != nil {
 		t.Fatalf("GenerateThumbnails failed: %v", err)
 	}
@@ -228,7 +311,7 @@ func TestGenerateThumbnailsVerticalImage(t *testing.T) {
 	imagePath := filepath.Join(tempDir, "vertical.jpg")
 	createTestImage(t, imagePath, 1500, 2000, "jpeg")
 
-	result, err := GenerateThumbnails(imagePath)
+	result, err := GenerateThumbnails(context.Background(), imagePath)
 	if err != nil {
 		t.Fatalf("GenerateThumbnails failed: %v", err)
 	}
@@ -250,3 +333,23 @@ func TestGenerateThumbnailsVerticalImage(t *testing.T) {
 			result.SmallWidth, result.SmallHeight)
 	}
 }
+
+func TestReadImageSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	imgPath := filepath.Join(tmpDir, "test.jpg")
+	createTestImage(t, imgPath, 1200, 800, "jpeg")
+
+	width, height, ok := ReadImageSize(imgPath)
+	if !ok || width != 1200 || height != 800 {
+		t.Errorf("ReadImageSize = %dx%d (ok=%v), want 1200x800", width, height, ok)
+	}
+
+	badPath := filepath.Join(tmpDir, "bad.jpg")
+	os.WriteFile(badPath, []byte("not an image"), 0644)
+	if _, _, ok := ReadImageSize(badPath); ok {
+		t.Error("ReadImageSize should fail for invalid images")
+	}
+	if _, _, ok := ReadImageSize(filepath.Join(tmpDir, "missing.jpg")); ok {
+		t.Error("ReadImageSize should fail for missing files")
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors ("12345678901234567890")
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, last 6 of the 8 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode failed: %v", err)
+		}
+		if code != tt.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	now := time.Now()
+	step := TOTPStep(now)
+	code, _ := TOTPCode(secret, step)
+
+	matched, ok := VerifyTOTP(secret, code, now, 0)
+	if !ok || matched != step {
+		t.Fatalf("Expected the current code to match step %d, got %d, %v", step, matched, ok)
+	}
+	if _, ok := VerifyTOTP(secret, code, now, step); ok {
+		t.Error("Expected a used code to be refused")
+	}
+
+	// One step of clock drift is accepted, two are not
+	previous, _ := TOTPCode(secret, step-1)
+	if _, ok := VerifyTOTP(secret, previous, now, 0); !ok {
+		t.Error("Expected the previous step to be accepted")
+	}
+	old, _ := TOTPCode(secret, step-2)
+	if _, ok := VerifyTOTP(secret, old, now, 0); ok && old != code && old != previous {
+		t.Error("Expected a code two steps old to be refused")
+	}
+
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := VerifyTOTP(secret, bad, now, 0); ok {
+			t.Errorf("Expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestTOTPURL(t *testing.T) {
+	url := TOTPURL("ABC", "PhotoBridge", "admin")
+	if !strings.HasPrefix(url, "otpauth://totp/PhotoBridge:admin?") || !strings.Contains(url, "secret=ABC") ||
+		!strings.Contains(url, "issuer=PhotoBridge") {
+		t.Errorf("Unexpected URL %s", url)
+	}
+}
+
+func TestBackupCodes(t *testing.T) {
+	codes := GenerateBackupCodes()
+	if len(codes) != BackupCodeCount {
+		t.Fatalf("Expected %d codes, got %d", BackupCodeCount, len(codes))
+	}
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("Unexpected code format %q", code)
+		}
+		if IsTOTPCode(code) {
+			t.Errorf("Backup code %q taken for a TOTP code", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != len(codes) {
+		t.Error("Expected distinct codes")
+	}
+
+	if HashBackupCode(codes[0]) != HashBackupCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))+" ") {
+		t.Error("Expected case, spaces and dashes to be ignored")
+	}
+	if !IsTOTPCode("123 456") || IsTOTPCode("12345a") {
+		t.Error("IsTOTPCode misclassified a code")
+	}
+}
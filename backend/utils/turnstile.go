@@ -1,17 +1,22 @@
 package utils
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"photobridge/config"
@@ -27,8 +32,26 @@ type TurnstileResponse struct {
 	CData       string   `json:"cdata"`
 }
 
-// VerifyTurnstileToken verifies a Turnstile token with Cloudflare's API
-func VerifyTurnstileToken(token string, remoteIP string) (bool, error) {
+const turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+var (
+	// turnstileEndpoint is a variable so tests can point it at a local server
+	turnstileEndpoint = turnstileVerifyURL
+
+	// turnstileBreaker stops hammering Cloudflare after repeated failures
+	turnstileBreaker = NewCircuitBreaker(5, 30*time.Second)
+
+	// ErrTurnstileUnavailable is returned when Cloudflare cannot be reached
+	ErrTurnstileUnavailable = errors.New("turnstile verification service unavailable")
+)
+
+// VerifyTurnstileToken verifies a Turnstile token with Cloudflare's API, giving up when
+// ctx (the visitor's request) is canceled. Tokens are single-use, so only calls that
+// can't have used the token up are retried, up to TURNSTILE_MAX_RETRIES times: those
+// whose request was never sent, and 5xx responses. A timeout after sending isn't.
+// When Cloudflare is unreachable (or the circuit breaker is open) the result follows
+// the TURNSTILE_FAIL_OPEN policy: allow the visitor through, or reject with ErrTurnstileUnavailable.
+func VerifyTurnstileToken(ctx context.Context, token string, remoteIP string) (bool, error) {
 	// If Turnstile is not configured, skip verification
 	if config.AppConfig.TurnstileSecretKey == "" {
 		return true, nil
@@ -49,31 +72,97 @@ func VerifyTurnstileToken(token string, remoteIP string) (bool, error) {
 		formData.Set("remoteip", remoteIP)
 	}
 
-	// Make POST request to Cloudflare
-	resp, err := http.PostForm("https://challenges.cloudflare.com/turnstile/v0/siteverify", formData)
+	if !turnstileBreaker.Allow() {
+		return turnstileUnavailable(fmt.Errorf("circuit breaker open"))
+	}
+
+	var result *TurnstileResponse
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		result, retryable, lastErr = postTurnstile(ctx, formData)
+		if lastErr == nil || !retryable || attempt >= config.AppConfig.TurnstileMaxRetries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(attempt+1) * 200 * time.Millisecond):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	// The visitor went away; that says nothing about Cloudflare
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if lastErr != nil {
+		turnstileBreaker.Failure()
+		return turnstileUnavailable(lastErr)
+	}
+	turnstileBreaker.Success()
+
+	// Check if verification succeeded
+	if !result.Success {
+		return false, fmt.Errorf("turnstile verification failed: %v", result.ErrorCodes)
+	}
+
+	return true, nil
+}
+
+// postTurnstile performs a single siteverify call with the configured timeout. Failed
+// calls are retryable when Cloudflare can't have used the token up: the request was
+// never sent, or Cloudflare answered with a 5xx.
+func postTurnstile(ctx context.Context, formData url.Values) (*TurnstileResponse, bool, error) {
+	if timeout := config.AppConfig.TurnstileTimeoutSec; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	var sent atomic.Bool
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { sent.Store(true) },
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, turnstileEndpoint, strings.NewReader(formData.Encode()))
 	if err != nil {
-		return false, fmt.Errorf("failed to verify turnstile token: %w", err)
+		return nil, false, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Per-attempt timeout is applied via context; the transport honors outbound proxy settings
+	resp, err := NewHTTPClient(0).Do(req)
+	if err != nil {
+		return nil, !sent.Load(), fmt.Errorf("failed to verify turnstile token: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 500 {
+		return nil, true, fmt.Errorf("turnstile returned status %d", resp.StatusCode)
+	}
+
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return false, fmt.Errorf("failed to read response: %w", err)
+		return nil, false, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Parse response
 	var result TurnstileResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return false, fmt.Errorf("failed to parse response: %w", err)
+		return nil, false, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	// Check if verification succeeded
-	if !result.Success {
-		return false, fmt.Errorf("turnstile verification failed: %v", result.ErrorCodes)
-	}
+	return &result, false, nil
+}
 
-	return true, nil
+// turnstileUnavailable applies the fail-open/fail-closed policy
+func turnstileUnavailable(cause error) (bool, error) {
+	if config.AppConfig.TurnstileFailOpen {
+		log.Printf("[Turnstile] Verification unavailable, failing open: %v", cause)
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: %v", ErrTurnstileUnavailable, cause)
 }
 
 // GenerateVerificationCookie generates a secure, signed cookie value for verified users
@@ -142,8 +231,9 @@ func VerifyVerificationCookie(cookie string) bool {
 
 // GeneratePasswordCookie generates a secure, signed cookie value for password-verified users
 // Format: timestamp.randomToken.signature
-// The signature includes the shareToken to prevent cookie reuse across different share links
-func GeneratePasswordCookie(shareToken string) string {
+// The signature includes the shareToken to prevent cookie reuse across different share links,
+// and the link's password hash so that changing the password revokes the cookie
+func GeneratePasswordCookie(shareToken, passwordHash string) string {
 	// Generate timestamp
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 
@@ -155,8 +245,8 @@ func GeneratePasswordCookie(shareToken string) string {
 	}
 	randomToken := base64.URLEncoding.EncodeToString(randomBytes)
 
-	// Create payload to sign (includes shareToken to bind cookie to specific link)
-	payload := timestamp + "." + randomToken + "." + shareToken
+	// Create payload to sign (includes shareToken and passwordHash to bind cookie to specific link and password)
+	payload := timestamp + "." + randomToken + "." + shareToken + "." + passwordHash
 
 	// Sign with HMAC-SHA256 using JWT secret
 	h := hmac.New(sha256.New, []byte(config.AppConfig.JWTSecret))
@@ -168,9 +258,10 @@ func GeneratePasswordCookie(shareToken string) string {
 }
 
 // VerifyPasswordCookie verifies the signature of a password verification cookie
-// The cookie is bound to a specific shareToken and cannot be used for other share links
+// The cookie is bound to a specific shareToken and cannot be used for other share links,
+// nor once the link's password (passwordHash) has changed
 // Also checks TTL (1 day) to prevent long-term cookie reuse
-func VerifyPasswordCookie(cookie string, shareToken string) bool {
+func VerifyPasswordCookie(cookie, shareToken, passwordHash string) bool {
 	// Split cookie into parts
 	parts := strings.Split(cookie, ".")
 	if len(parts) != 3 {
@@ -193,8 +284,8 @@ func VerifyPasswordCookie(cookie string, shareToken string) bool {
 		return false
 	}
 
-	// Recreate payload (must include shareToken)
-	payload := timestampStr + "." + randomToken + "." + shareToken
+	// Recreate payload (must include shareToken and the current passwordHash)
+	payload := timestampStr + "." + randomToken + "." + shareToken + "." + passwordHash
 
 	// Compute expected signature
 	h := hmac.New(sha256.New, []byte(config.AppConfig.JWTSecret))
@@ -1,11 +1,16 @@
 package utils
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -216,6 +221,9 @@ func TestVerifyVerificationCookie_TTL(t *testing.T) {
 
 // Password Cookie Tests
 
+// passwordHash stands for the bcrypt hash of a link's password
+const passwordHash = "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+
 func TestGeneratePasswordCookie_Format(t *testing.T) {
 	// Ensure config is initialized
 	if config.AppConfig == nil || config.AppConfig.JWTSecret == "" {
@@ -225,7 +233,7 @@ func TestGeneratePasswordCookie_Format(t *testing.T) {
 	}
 
 	shareToken := "test-token-abc123"
-	cookie := GeneratePasswordCookie(shareToken)
+	cookie := GeneratePasswordCookie(shareToken, passwordHash)
 
 	// Should be non-empty
 	if cookie == "" {
@@ -274,9 +282,9 @@ func TestGeneratePasswordCookie_Uniqueness(t *testing.T) {
 	shareToken := "test-token-abc123"
 
 	// Generate multiple cookies for the same token
-	cookie1 := GeneratePasswordCookie(shareToken)
+	cookie1 := GeneratePasswordCookie(shareToken, passwordHash)
 	time.Sleep(time.Millisecond) // Small delay
-	cookie2 := GeneratePasswordCookie(shareToken)
+	cookie2 := GeneratePasswordCookie(shareToken, passwordHash)
 
 	// Should be different (due to different timestamps and/or random tokens)
 	if cookie1 == cookie2 {
@@ -295,15 +303,15 @@ func TestVerifyPasswordCookie_Valid(t *testing.T) {
 	shareToken := "test-token-abc123"
 
 	// Generate a cookie
-	cookie := GeneratePasswordCookie(shareToken)
+	cookie := GeneratePasswordCookie(shareToken, passwordHash)
 
 	// Should verify successfully with correct token
-	if !VerifyPasswordCookie(cookie, shareToken) {
+	if !VerifyPasswordCookie(cookie, shareToken, passwordHash) {
 		t.Error("Valid password cookie should verify successfully")
 	}
 
 	// Should fail with different token
-	if VerifyPasswordCookie(cookie, "different-token") {
+	if VerifyPasswordCookie(cookie, "different-token", passwordHash) {
 		t.Error("Password cookie should not verify with different share token")
 	}
 }
@@ -332,7 +340,7 @@ func TestVerifyPasswordCookie_Invalid(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if VerifyPasswordCookie(tt.cookie, shareToken) {
+			if VerifyPasswordCookie(tt.cookie, shareToken, passwordHash) {
 				t.Errorf("Invalid password cookie %q should not verify", tt.cookie)
 			}
 		})
@@ -350,7 +358,7 @@ func TestVerifyPasswordCookie_TTL(t *testing.T) {
 	// Create a cookie with expired timestamp (2 days old)
 	expiredTimestamp := strconv.FormatInt(time.Now().Unix()-2*24*60*60, 10)
 	randomToken := "test-random-token"
-	payload := expiredTimestamp + "." + randomToken + "." + shareToken
+	payload := expiredTimestamp + "." + randomToken + "." + shareToken + "." + passwordHash
 
 	h := hmac.New(sha256.New, []byte("test-secret"))
 	h.Write([]byte(payload))
@@ -359,13 +367,13 @@ func TestVerifyPasswordCookie_TTL(t *testing.T) {
 	expiredCookie := expiredTimestamp + "." + randomToken + "." + signature
 
 	// Should fail due to TTL expiration
-	if VerifyPasswordCookie(expiredCookie, shareToken) {
+	if VerifyPasswordCookie(expiredCookie, shareToken, passwordHash) {
 		t.Error("Expired password cookie should not verify")
 	}
 
 	// Create a fresh cookie (should verify)
 	freshTimestamp := strconv.FormatInt(time.Now().Unix(), 10)
-	freshPayload := freshTimestamp + "." + randomToken + "." + shareToken
+	freshPayload := freshTimestamp + "." + randomToken + "." + shareToken + "." + passwordHash
 
 	h = hmac.New(sha256.New, []byte("test-secret"))
 	h.Write([]byte(freshPayload))
@@ -374,7 +382,7 @@ func TestVerifyPasswordCookie_TTL(t *testing.T) {
 	freshCookie := freshTimestamp + "." + randomToken + "." + freshSignature
 
 	// Should verify
-	if !VerifyPasswordCookie(freshCookie, shareToken) {
+	if !VerifyPasswordCookie(freshCookie, shareToken, passwordHash) {
 		t.Error("Fresh password cookie should verify")
 	}
 }
@@ -386,17 +394,17 @@ func TestVerifyPasswordCookie_DifferentSecret(t *testing.T) {
 	config.AppConfig = &config.Config{
 		JWTSecret: "secret1",
 	}
-	cookie := GeneratePasswordCookie(shareToken)
+	cookie := GeneratePasswordCookie(shareToken, passwordHash)
 
 	// Verify with different secret
 	config.AppConfig.JWTSecret = "secret2"
-	if VerifyPasswordCookie(cookie, shareToken) {
+	if VerifyPasswordCookie(cookie, shareToken, passwordHash) {
 		t.Error("Password cookie signed with different secret should not verify")
 	}
 
 	// Restore original secret and verify
 	config.AppConfig.JWTSecret = "secret1"
-	if !VerifyPasswordCookie(cookie, shareToken) {
+	if !VerifyPasswordCookie(cookie, shareToken, passwordHash) {
 		t.Error("Password cookie should verify with original secret")
 	}
 }
@@ -411,15 +419,154 @@ func TestVerifyPasswordCookie_TokenBinding(t *testing.T) {
 	token2 := "token-xyz789"
 
 	// Generate cookie for token1
-	cookie := GeneratePasswordCookie(token1)
+	cookie := GeneratePasswordCookie(token1, passwordHash)
 
 	// Should verify with token1
-	if !VerifyPasswordCookie(cookie, token1) {
+	if !VerifyPasswordCookie(cookie, token1, passwordHash) {
 		t.Error("Password cookie should verify with original token")
 	}
 
 	// Should NOT verify with token2 (cookie is bound to specific token)
-	if VerifyPasswordCookie(cookie, token2) {
+	if VerifyPasswordCookie(cookie, token2, passwordHash) {
 		t.Error("Password cookie should not verify with different token (token binding)")
 	}
 }
+
+// setupTurnstileServer points verification at a local server and resets the breaker
+func setupTurnstileServer(t *testing.T, handler http.HandlerFunc, failOpen bool) {
+	server := httptest.NewServer(handler)
+	originalEndpoint := turnstileEndpoint
+	originalBreaker := turnstileBreaker
+	originalConfig := config.AppConfig
+	t.Cleanup(func() {
+		server.Close()
+		turnstileEndpoint = originalEndpoint
+		turnstileBreaker = originalBreaker
+		config.AppConfig = originalConfig
+	})
+
+	turnstileEndpoint = server.URL
+	turnstileBreaker = NewCircuitBreaker(2, time.Minute)
+	config.AppConfig = &config.Config{
+		TurnstileSecretKey:  "secret",
+		TurnstileTimeoutSec: 1,
+		TurnstileMaxRetries: 1,
+		TurnstileFailOpen:   failOpen,
+	}
+}
+
+func TestVerifyTurnstileToken_Success(t *testing.T) {
+	setupTurnstileServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true}`))
+	}, false)
+
+	ok, err := VerifyTurnstileToken(context.Background(), "token", "1.2.3.4")
+	if !ok || err != nil {
+		t.Errorf("Expected success, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestVerifyTurnstileToken_Rejected(t *testing.T) {
+	var calls int32
+	setupTurnstileServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}, true)
+
+	ok, err := VerifyTurnstileToken(context.Background(), "token", "")
+	if ok || err == nil {
+		t.Error("Rejected token should fail even when failing open")
+	}
+	if calls != 1 {
+		t.Errorf("Rejected tokens should not be retried, got %d calls", calls)
+	}
+}
+
+func TestVerifyTurnstileToken_RetriesAndFailClosed(t *testing.T) {
+	var calls int32
+	setupTurnstileServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}, false)
+
+	ok, err := VerifyTurnstileToken(context.Background(), "token", "")
+	if ok || !errors.Is(err, ErrTurnstileUnavailable) {
+		t.Errorf("Expected ErrTurnstileUnavailable, got ok=%v err=%v", ok, err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 1 retry (2 calls), got %d", calls)
+	}
+}
+
+func TestVerifyTurnstileToken_TimeoutNotRetried(t *testing.T) {
+	var calls int32
+	setupTurnstileServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(1500 * time.Millisecond) // Past the 1 s timeout; the token may be used up
+		w.Write([]byte(`{"success":true}`))
+	}, false)
+
+	ok, err := VerifyTurnstileToken(context.Background(), "token", "")
+	if ok || !errors.Is(err, ErrTurnstileUnavailable) {
+		t.Errorf("Expected ErrTurnstileUnavailable, got ok=%v err=%v", ok, err)
+	}
+	if calls != 1 {
+		t.Errorf("A call that timed out after sending the token must not be retried, got %d calls", calls)
+	}
+}
+
+func TestVerifyTurnstileToken_RequestCanceled(t *testing.T) {
+	setupTurnstileServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ok, err := VerifyTurnstileToken(ctx, "token", ""); ok || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got ok=%v err=%v", ok, err)
+	}
+	if !turnstileBreaker.Allow() {
+		t.Error("Canceled requests should not trip the breaker")
+	}
+}
+
+func TestVerifyTurnstileToken_FailOpenAndBreaker(t *testing.T) {
+	var calls int32
+	setupTurnstileServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, true)
+
+	for i := 0; i < 2; i++ {
+		ok, err := VerifyTurnstileToken(context.Background(), "token", "")
+		if !ok || err != nil {
+			t.Fatalf("Fail-open should allow when Cloudflare is down, got ok=%v err=%v", ok, err)
+		}
+	}
+
+	// Breaker is now open; no further calls reach the server
+	before := atomic.LoadInt32(&calls)
+	if ok, _ := VerifyTurnstileToken(context.Background(), "token", ""); !ok {
+		t.Error("Fail-open should allow while breaker is open")
+	}
+	if atomic.LoadInt32(&calls) != before {
+		t.Error("Open breaker should short-circuit calls to Cloudflare")
+	}
+}
+
+func TestVerifyPasswordCookie_PasswordBinding(t *testing.T) {
+	config.AppConfig = &config.Config{
+		JWTSecret: "test-secret",
+	}
+
+	token := "token-abc123"
+	cookie := GeneratePasswordCookie(token, passwordHash)
+
+	// A new password revokes the cookies issued for the old one
+	if VerifyPasswordCookie(cookie, token, "$2a$10$differenthash") {
+		t.Error("Password cookie should not verify once the link's password changed")
+	}
+	if VerifyPasswordCookie(cookie, token, "") {
+		t.Error("Password cookie should not verify without the link's password hash")
+	}
+}
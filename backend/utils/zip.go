@@ -2,20 +2,24 @@ package utils
 
 import (
 	"archive/zip"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // MaxFilesPerZip limits the number of files in a single zip download to prevent abuse
 const MaxFilesPerZip = 1000
 
-
 // CreateZip creates a zip archive from a list of files using streaming.
 // This implementation is memory-efficient as it uses io.Copy which streams
 // file contents through a small buffer (typically 32KB) rather than loading
-// entire files into memory.
+// entire files into memory. Archives of 4 GiB and more get ZIP64 records: entries
+// past 4 GiB carry ZIP64 extra fields in the central directory, and the archive
+// ends with a ZIP64 end of central directory. ZipSize returns the exact size.
 func CreateZip(writer io.Writer, files []string, basePath string) error {
 	if len(files) > MaxFilesPerZip {
 		return fmt.Errorf("too many files (%d), maximum allowed is %d", len(files), MaxFilesPerZip)
@@ -31,7 +35,18 @@ func CreateZip(writer io.Writer, files []string, basePath string) error {
 		}
 	}
 
-	return nil
+	// Close writes the central directory; report its failure so callers can discard the archive
+	return zipWriter.Close()
+}
+
+// ZipEntryName returns the name CreateZip gives a file: its path relative to basePath,
+// or just its name for files outside basePath (e.g. cached conversions)
+func ZipEntryName(filePath, basePath string) string {
+	relPath, err := filepath.Rel(basePath, filePath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return filepath.Base(filePath)
+	}
+	return filepath.ToSlash(relPath)
 }
 
 func addFileToZip(zipWriter *zip.Writer, filePath string, basePath string) error {
@@ -51,12 +66,7 @@ func addFileToZip(zipWriter *zip.Writer, filePath string, basePath string) error
 		return err
 	}
 
-	// Use relative path in zip
-	relPath, err := filepath.Rel(basePath, filePath)
-	if err != nil {
-		relPath = filepath.Base(filePath)
-	}
-	header.Name = relPath
+	header.Name = ZipEntryName(filePath, basePath)
 
 	// Always use Store (no compression) - photos are already compressed
 	// This reduces CPU and memory usage significantly on limited servers
@@ -70,3 +80,148 @@ func addFileToZip(zipWriter *zip.Writer, filePath string, basePath string) error
 	_, err = io.Copy(writer, file)
 	return err
 }
+
+// ZipEntry is a file for CreateZipEntries that is not necessarily on local disk
+type ZipEntry struct {
+	Name    string
+	ModTime time.Time
+	Open    func() (io.ReadCloser, error)
+}
+
+// CreateZipEntries streams entries into a zip archive like CreateZip. Entries whose
+// file no longer exists are left out.
+func CreateZipEntries(writer io.Writer, entries []ZipEntry) error {
+	if len(entries) > MaxFilesPerZip {
+		return fmt.Errorf("too many files (%d), maximum allowed is %d", len(entries), MaxFilesPerZip)
+	}
+
+	zipWriter := zip.NewWriter(writer)
+	defer zipWriter.Close()
+
+	for _, entry := range entries {
+		if err := addEntryToZip(zipWriter, entry); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+func addEntryToZip(zipWriter *zip.Writer, entry ZipEntry) error {
+	file, err := entry.Open()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := &zip.FileHeader{Name: entry.Name, Method: zip.Store, Modified: entry.ModTime}
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// Per-entry overhead of archives written by CreateZip: local header (30) and central
+// directory record (46), each with an extended timestamp field (9), plus the data
+// descriptor (16). The file name is stored twice on top of this.
+const zipEntryOverhead = 30 + 46 + 2*9 + 16
+
+// EstimateZipSize approximates the size of an archive CreateZip writes for count
+// files holding dataBytes in total, whose relative names add up to nameBytes
+func EstimateZipSize(count int64, dataBytes, nameBytes int64) int64 {
+	if count == 0 {
+		return 0
+	}
+	size := dataBytes + count*zipEntryOverhead + 2*nameBytes + 22 // end of central directory
+	if size > 0xFFFFFFFF || count > 0xFFFF {
+		size += 56 + 20 // zip64 end of central directory record and locator
+	}
+	return size
+}
+
+// Record sizes of the archives written by CreateZip and CreateZipEntries: every entry
+// has a local header, an extended timestamp field (in the local header and the central
+// directory) and a data descriptor, which takes 64-bit sizes past 4 GiB
+const (
+	zipLocalHeaderLen   = 30
+	zipCentralHeaderLen = 46
+	zipTimestampLen     = 9
+	zipDescriptorLen    = 16
+	zipDescriptor64Len  = 24
+	zip64ExtraLen       = 4 // ZIP64 extra field header, plus 8 bytes per large value
+	zipEndLen           = 22
+	zip64EndLen         = 56 + 20 // ZIP64 end of central directory record and locator
+	zipUint32Max        = 1<<32 - 1
+	zipUint16Max        = 1<<16 - 1
+)
+
+// ZipFile is an entry of an archive to be sized by ZipSize
+type ZipFile struct {
+	Name string // Name in the archive (see ZipEntryName)
+	Size int64
+}
+
+// ZipSize returns the exact size of the archive CreateZip writes for files in this
+// order, so downloads can be sent with a Content-Length. ZIP64 records are counted
+// where archive/zip adds them.
+func ZipSize(files []ZipFile) int64 {
+	var offset, central int64
+	zip64 := false
+	for _, f := range files {
+		name := int64(len(f.Name))
+		central += zipCentralHeaderLen + name + zipTimestampLen
+		if f.Size >= zipUint32Max || offset >= zipUint32Max {
+			// Stored entries: the extra holds both sizes if they are large, then the offset
+			zip64 = true
+			central += zip64ExtraLen
+			if f.Size >= zipUint32Max {
+				central += 16
+			}
+			if offset >= zipUint32Max {
+				central += 8
+			}
+		}
+		offset += zipLocalHeaderLen + name + zipTimestampLen + f.Size + zipDescriptorLen
+		if f.Size > zipUint32Max {
+			offset += zipDescriptor64Len - zipDescriptorLen
+		}
+	}
+	size := offset + central + zipEndLen
+	if zip64 || len(files) >= zipUint16Max || central >= zipUint32Max || offset >= zipUint32Max {
+		size += zip64EndLen
+	}
+	return size
+}
+
+// ZipVolume is a part of a download split by SplitZipVolumes: files[Start:End]
+type ZipVolume struct {
+	Start int   `json:"-"`
+	End   int   `json:"-"`
+	Files int   `json:"files"`
+	Size  int64 `json:"size"` // Archive size, see ZipSize
+}
+
+// SplitZipVolumes splits files, in order, into archives of at most maxSize bytes and
+// MaxFilesPerZip files each. A file too large for any volume gets one of its own.
+// maxSize <= 0 keeps one archive.
+func SplitZipVolumes(files []ZipFile, maxSize int64) []ZipVolume {
+	volumes := []ZipVolume{{}}
+	for i := range files {
+		current := &volumes[len(volumes)-1]
+		size := ZipSize(files[current.Start : i+1])
+		if maxSize > 0 && i > current.Start && (size > maxSize || i-current.Start == MaxFilesPerZip) {
+			volumes = append(volumes, ZipVolume{Start: i})
+			current = &volumes[len(volumes)-1]
+			size = ZipSize(files[i : i+1])
+		}
+		current.End, current.Files, current.Size = i+1, i+1-current.Start, size
+	}
+	if len(files) == 0 {
+		volumes[0].Size = ZipSize(nil)
+	}
+	return volumes
+}
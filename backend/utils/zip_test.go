@@ -3,9 +3,13 @@ package utils
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/binary"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreateZip(t *testing.T) {
@@ -128,3 +132,186 @@ func TestCreateZipNonExistentFile(t *testing.T) {
 		t.Error("Expected error for non-existent file, got nil")
 	}
 }
+
+func TestCreateZipFileOutsideBase(t *testing.T) {
+	baseDir := t.TempDir()
+	otherDir := t.TempDir()
+
+	inside := filepath.Join(baseDir, "a.jpg")
+	outside := filepath.Join(otherDir, "b.jpg")
+	os.WriteFile(inside, []byte("a"), 0644)
+	os.WriteFile(outside, []byte("b"), 0644)
+
+	var buf bytes.Buffer
+	if err := CreateZip(&buf, []string{inside, outside}, baseDir); err != nil {
+		t.Fatalf("CreateZip failed: %v", err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+	for i, expected := range []string{"a.jpg", "b.jpg"} {
+		if zipReader.File[i].Name != expected {
+			t.Errorf("Entry %d = %q, expected %q", i, zipReader.File[i].Name, expected)
+		}
+	}
+}
+
+func TestEstimateZipSize(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"IMG_0001.jpg", "IMG_0002.jpg", "DSC_1234.arw"}
+	var files []string
+	var dataBytes, nameBytes int64
+	for i, name := range names {
+		path := filepath.Join(dir, name)
+		content := bytes.Repeat([]byte{byte(i)}, 1000*(i+1))
+		os.WriteFile(path, content, 0644)
+		files = append(files, path)
+		dataBytes += int64(len(content))
+		nameBytes += int64(len(name))
+	}
+
+	var buf bytes.Buffer
+	if err := CreateZip(&buf, files, dir); err != nil {
+		t.Fatalf("CreateZip failed: %v", err)
+	}
+	if estimate := EstimateZipSize(int64(len(files)), dataBytes, nameBytes); estimate != int64(buf.Len()) {
+		t.Errorf("EstimateZipSize() = %d, actual archive is %d bytes", estimate, buf.Len())
+	}
+	if EstimateZipSize(0, 0, 0) != 0 {
+		t.Error("Empty archives should be estimated at 0")
+	}
+}
+
+func TestZipSize(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	var paths []string
+	var files []ZipFile
+	for i, name := range []string{"IMG_0001.jpg", "sub/照片.jpg", "DSC_1234.arw", "empty.jpg"} {
+		path := filepath.Join(dir, name)
+		content := bytes.Repeat([]byte{byte(i)}, 777*i*i)
+		os.WriteFile(path, content, 0644)
+		paths = append(paths, path)
+		files = append(files, ZipFile{Name: ZipEntryName(path, dir), Size: int64(len(content))})
+	}
+	outside := filepath.Join(t.TempDir(), "converted.jpg")
+	os.WriteFile(outside, []byte("jpeg"), 0644)
+	paths = append(paths, outside)
+	files = append(files, ZipFile{Name: ZipEntryName(outside, dir), Size: 4})
+
+	for n := 0; n <= len(paths); n++ {
+		var buf bytes.Buffer
+		if err := CreateZip(&buf, paths[:n], dir); err != nil {
+			t.Fatalf("CreateZip failed: %v", err)
+		}
+		if size := ZipSize(files[:n]); size != int64(buf.Len()) {
+			t.Errorf("ZipSize() of %d files = %d, actual archive is %d bytes", n, size, buf.Len())
+		}
+	}
+}
+
+// zeroReader reads n zero bytes
+type zeroReader struct{ n int64 }
+
+func (r *zeroReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	clear(p)
+	r.n -= int64(len(p))
+	return len(p), nil
+}
+
+func (r *zeroReader) Close() error { return nil }
+
+// tailWriter counts what is written and keeps the last bytes
+type tailWriter struct {
+	n    int64
+	tail []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	w.tail = append(w.tail, p...)
+	if len(w.tail) > 4096 {
+		w.tail = w.tail[len(w.tail)-4096:]
+	}
+	return len(p), nil
+}
+
+func TestZipSizeZip64(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes a 4 GiB archive")
+	}
+	// The second entry starts past 4 GiB, so the archive needs ZIP64 records
+	sizes := []int64{zipUint32Max + 10, 100}
+	var entries []ZipEntry
+	var files []ZipFile
+	for i, size := range sizes {
+		size := size
+		name := []string{"big.arw", "small.jpg"}[i]
+		entries = append(entries, ZipEntry{Name: name, ModTime: time.Now(), Open: func() (io.ReadCloser, error) {
+			return &zeroReader{size}, nil
+		}})
+		files = append(files, ZipFile{Name: name, Size: size})
+	}
+
+	w := &tailWriter{}
+	if err := CreateZipEntries(w, entries); err != nil {
+		t.Fatalf("CreateZipEntries failed: %v", err)
+	}
+	if size := ZipSize(files); size != w.n {
+		t.Errorf("ZipSize() = %d, actual archive is %d bytes", size, w.n)
+	}
+	// ZIP64 end of central directory, its locator, then the classic end record
+	end := w.tail[len(w.tail)-zip64EndLen-zipEndLen:]
+	if binary.LittleEndian.Uint32(end) != 0x06064b50 || binary.LittleEndian.Uint32(end[56:]) != 0x07064b50 {
+		t.Error("Expected ZIP64 end of central directory records")
+	}
+	if !strings.Contains(string(w.tail), "small.jpg") {
+		t.Error("Expected the central directory at the end")
+	}
+}
+
+func TestSplitZipVolumes(t *testing.T) {
+	files := []ZipFile{{"a.jpg", 400}, {"b.jpg", 400}, {"c.jpg", 400}, {"huge.arw", 5000}, {"d.jpg", 100}}
+
+	one := SplitZipVolumes(files, 0)
+	if len(one) != 1 || one[0].Files != 5 || one[0].Size != ZipSize(files) {
+		t.Errorf("Expected one archive without a volume size, got %+v", one)
+	}
+
+	volumes := SplitZipVolumes(files, 1100)
+	want := [][2]int{{0, 2}, {2, 3}, {3, 4}, {4, 5}}
+	if len(volumes) != len(want) {
+		t.Fatalf("Expected %d volumes, got %+v", len(want), volumes)
+	}
+	for i, v := range volumes {
+		if v.Start != want[i][0] || v.End != want[i][1] || v.Size != ZipSize(files[v.Start:v.End]) {
+			t.Errorf("Volume %d = %+v, want files %v", i, v, want[i])
+		}
+		if v.Size > 1100 && v.Files > 1 {
+			t.Errorf("Volume %d is over the limit: %+v", i, v)
+		}
+	}
+
+	if empty := SplitZipVolumes(nil, 1000); len(empty) != 1 || empty[0].Files != 0 {
+		t.Errorf("Expected one empty archive, got %+v", empty)
+	}
+}
+
+func TestSplitZipVolumesFileLimit(t *testing.T) {
+	files := make([]ZipFile, MaxFilesPerZip+1)
+	for i := range files {
+		files[i] = ZipFile{Name: "IMG.jpg", Size: 10}
+	}
+	volumes := SplitZipVolumes(files, 1<<40)
+	if len(volumes) != 2 || volumes[0].Files != MaxFilesPerZip || volumes[1].Files != 1 {
+		t.Errorf("Expected volumes of at most %d files, got %+v", MaxFilesPerZip, volumes)
+	}
+}
@@ -1,5 +1,5 @@
 import { describe, it, expect, vi, beforeEach, afterEach } from 'vitest'
-import { getUploadUrl, getShareThumbSmallUrl, getShareThumbLargeUrl, clearThumbCache } from '../api'
+import { getUploadUrl, getImageUrl, getShareThumbSmallUrl, getShareThumbLargeUrl, clearThumbCache } from '../api'
 
 describe('API utilities', () => {
   describe('getUploadUrl', () => {
@@ -28,19 +28,30 @@ describe('API utilities', () => {
   describe('getShareThumbSmallUrl', () => {
     it('constructs correct URL', () => {
       const url = getShareThumbSmallUrl('abc123', 42)
-      expect(url).toContain('/api/share/abc123/photo/42/thumb/small')
+      expect(url).toContain('/api/image/42?share=abc123&size=small')
     })
 
     it('handles special characters in token', () => {
       const url = getShareThumbSmallUrl('test-token_123', 1)
-      expect(url).toContain('/api/share/test-token_123/photo/1/thumb/small')
+      expect(url).toContain('/api/image/1?share=test-token_123&size=small')
     })
   })
 
   describe('getShareThumbLargeUrl', () => {
     it('constructs correct URL', () => {
       const url = getShareThumbLargeUrl('xyz789', 100)
-      expect(url).toContain('/api/share/xyz789/photo/100/thumb/large')
+      expect(url).toContain('/api/image/100?share=xyz789&size=large')
+    })
+  })
+
+  describe('getImageUrl', () => {
+    it('builds admin image URLs without a share token', () => {
+      expect(getImageUrl(7, { size: 'original', format: 'raw' })).toContain('/api/image/7?size=original&format=raw')
+    })
+
+    it('prefers the CDN base URL', () => {
+      expect(getImageUrl(7, { token: 'abc', cdnBaseUrl: 'https://cdn.example.com' }))
+        .toBe('https://cdn.example.com/api/image/7?share=abc&size=large')
     })
   })
 
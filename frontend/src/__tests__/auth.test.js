@@ -20,7 +20,8 @@ vi.stubGlobal('localStorage', localStorageMock)
 
 // Mock the API module
 vi.mock('../api', () => ({
-  login: vi.fn()
+  login: vi.fn(),
+  logout: vi.fn(() => Promise.resolve())
 }))
 
 describe('Auth Store', () => {
@@ -55,19 +56,32 @@ describe('Auth Store', () => {
 
   it('login stores token correctly', async () => {
     const { login: mockLogin } = await import('../api')
-    mockLogin.mockResolvedValue({ data: { token: 'new-token' } })
+    mockLogin.mockResolvedValue({ data: { token: 'new-token', refresh_token: 'new-refresh-token' } })
 
     const { useAuthStore } = await import('../stores/auth')
     const store = useAuthStore()
 
     await store.login('admin', 'password')
 
-    expect(mockLogin).toHaveBeenCalledWith('admin', 'password')
+    expect(mockLogin).toHaveBeenCalledWith('admin', 'password', undefined)
     expect(store.token).toBe('new-token')
     expect(localStorageMock.setItem).toHaveBeenCalledWith('token', 'new-token')
+    expect(localStorageMock.setItem).toHaveBeenCalledWith('refresh_token', 'new-refresh-token')
     expect(store.isAuthenticated).toBe(true)
   })
 
+  it('login passes the two-factor code', async () => {
+    const { login: mockLogin } = await import('../api')
+    mockLogin.mockResolvedValue({ data: { token: 'new-token', refresh_token: 'new-refresh-token' } })
+
+    const { useAuthStore } = await import('../stores/auth')
+    const store = useAuthStore()
+
+    await store.login('admin', 'password', '123456')
+
+    expect(mockLogin).toHaveBeenCalledWith('admin', 'password', '123456')
+  })
+
   it('logout clears token', async () => {
     localStorageMock.store['token'] = 'existing-token'
 
@@ -79,9 +93,21 @@ describe('Auth Store', () => {
 
     expect(store.token).toBeNull()
     expect(localStorageMock.removeItem).toHaveBeenCalledWith('token')
+    expect(localStorageMock.removeItem).toHaveBeenCalledWith('refresh_token')
     expect(store.isAuthenticated).toBe(false)
   })
 
+  it('logout revokes the session on the server', async () => {
+    const { logout: mockLogout } = await import('../api')
+    const { useAuthStore } = await import('../stores/auth')
+    const store = useAuthStore()
+    store.token = 'existing-token'
+
+    store.logout()
+
+    expect(mockLogout).toHaveBeenCalledWith('existing-token')
+  })
+
   it('isAuthenticated returns true when token exists', async () => {
     const { useAuthStore } = await import('../stores/auth')
     const store = useAuthStore()
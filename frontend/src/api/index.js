@@ -17,12 +17,52 @@ api.interceptors.request.use(
   (error) => Promise.reject(error)
 )
 
+// Admin access tokens are short-lived: they are renewed with the refresh token, which
+// the server replaces on every use. Concurrent callers share one refresh.
+let refreshing = null
+export function refreshAccessToken() {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refresh_token')
+    refreshing = (refreshToken
+      ? axios.post(`${api.defaults.baseURL}/admin/refresh`, { refresh_token: refreshToken })
+      : Promise.reject(new Error('No refresh token'))
+    ).then((response) => {
+      localStorage.setItem('token', response.data.token)
+      localStorage.setItem('refresh_token', response.data.refresh_token)
+      return response.data.token
+    }, (error) => {
+      // Another tab refreshed with the same token meanwhile
+      if (refreshToken && localStorage.getItem('refresh_token') !== refreshToken) {
+        return localStorage.getItem('token')
+      }
+      throw error
+    }).finally(() => {
+      refreshing = null
+    })
+  }
+  return refreshing
+}
+
 // Response interceptor
 api.interceptors.response.use(
   (response) => response,
-  (error) => {
-    if (error.response?.status === 401) {
+  async (error) => {
+    const request = error.config
+    if (error.response?.status === 401 && request?.url?.startsWith('/admin/') &&
+        request.url !== '/admin/login' && !request.retried && localStorage.getItem('refresh_token')) {
+      try {
+        const token = await refreshAccessToken()
+        request.retried = true
+        request.headers.Authorization = `Bearer ${token}`
+        return api(request)
+      } catch {
+        // The session is gone; sign in again
+      }
+    }
+    // A failed login is shown on the login page itself
+    if (error.response?.status === 401 && request?.url !== '/admin/login') {
       localStorage.removeItem('token')
+      localStorage.removeItem('refresh_token')
       if (!window.location.pathname.startsWith('/share/')) {
         window.location.href = '/login'
       }
@@ -32,37 +72,152 @@ api.interceptors.response.use(
 )
 
 // Auth
-export const login = (username, password) =>
-  api.post('/admin/login', { username, password })
+// otp: TOTP or backup code, once two-factor authentication is enabled
+export const login = (username, password, otp) =>
+  api.post('/admin/login', { username, password, otp })
+// The token is passed explicitly since it is already gone from localStorage when the request is sent
+export const logout = (token) =>
+  api.post('/admin/logout', null, { headers: { Authorization: `Bearer ${token}` } })
+
+// Sessions (signed-in devices)
+export const getAdminSessions = () => api.get('/admin/sessions')
+export const revokeAdminSession = (id) => api.delete(`/admin/sessions/${id}`)
+export const getLoginAudit = (params = {}) => api.get('/admin/security/login-audit', { params })
+export const getTwoFactorStatus = () => api.get('/admin/2fa')
+export const setUpTwoFactor = () => api.post('/admin/2fa/setup')
+export const enableTwoFactor = (code) => api.post('/admin/2fa/enable', { code })
+export const disableTwoFactor = (code) => api.post('/admin/2fa/disable', { code })
+export const regenerateBackupCodes = (code) => api.post('/admin/2fa/backup-codes', { code })
+export const getAPIKeys = () => api.get('/admin/apikeys')
+export const createAPIKey = (data) => api.post('/admin/apikeys', data)
+export const updateAPIKey = (id, data) => api.put(`/admin/apikeys/${id}`, data)
+export const deleteAPIKey = (id) => api.delete(`/admin/apikeys/${id}`)
+
+// If-Match for project and link updates: the save fails with 412 when another tab
+// changed the resource after `version` was read
+const ifMatch = (version) => (version ? { headers: { 'If-Match': `"${version}"` } } : {})
 
 // Projects
 export const getProjects = () => api.get('/admin/projects')
 export const createProject = (data) => api.post('/admin/projects', data)
 export const getProject = (id) => api.get(`/admin/projects/${id}`)
-export const updateProject = (id, data) => api.put(`/admin/projects/${id}`, data)
+export const updateProject = (id, data, version) => api.put(`/admin/projects/${id}`, data, ifMatch(version))
+export const getProjectUsage = (id) => api.get(`/admin/projects/${id}/usage`)
 export const deleteProject = (id) => api.delete(`/admin/projects/${id}`)
 
+// Trash (deleted photos and projects until they are purged)
+export const getTrash = (kind = '') => api.get('/admin/trash', kind ? { params: { kind } } : {})
+export const restoreTrashItem = (id) => api.post(`/admin/trash/${id}/restore`)
+export const purgeTrashItem = (id) => api.delete(`/admin/trash/${id}`)
+
 // Photos
 export const getProjectPhotos = (projectId) => api.get(`/admin/projects/${projectId}/photos`)
 export const deletePhoto = (id) => api.delete(`/admin/photos/${id}`)
+export const bulkDeletePhotos = (photoIds) => api.post('/admin/photos/bulk-delete', { photo_ids: photoIds })
+export const bulkMovePhotos = (photoIds, projectId) => api.post('/admin/photos/bulk-move', { photo_ids: photoIds, project_id: projectId })
+export const tagPhotos = (photoIds, tags) => api.post('/admin/photos/bulk-tag', { photo_ids: photoIds, tags })
+export const untagPhotos = (photoIds, tags) => api.post('/admin/photos/bulk-untag', { photo_ids: photoIds, tags })
+export const searchPhotos = (q, params = {}) => api.get('/admin/photos/search', { params: { q, ...params } })
+export const getTags = () => api.get('/admin/tags')
+export const importPhotoMetadata = (projectId, file, replaceTags = false) => {
+  const formData = new FormData()
+  formData.append('file', file)
+  return api.post(`/admin/projects/${projectId}/photos/metadata`, formData, { params: { replace_tags: replaceTags } })
+}
+// photoIds are sent as repeated photo_ids keys, which is what the backend binds
+export const getContactSheet = (projectId, { photoIds = [], linkId, columns } = {}) => {
+  const params = new URLSearchParams()
+  photoIds.forEach(id => params.append('photo_ids', id))
+  if (linkId) params.append('link_id', linkId)
+  if (columns) params.append('columns', columns)
+  return api.get(`/admin/projects/${projectId}/contact-sheet`, { params, responseType: 'blob' })
+}
+export const regenerateThumbnails = (projectId, force = false) =>
+  api.post(`/admin/projects/${projectId}/regenerate-thumbnails`, null, { params: force ? { force: true } : {} })
+export const getThumbQueue = () => api.get('/admin/thumbqueue')
+export const retryFailedThumbnails = () => api.post('/admin/thumbqueue/retry-failed')
+export const scanProject = (projectId) => api.post(`/admin/projects/${projectId}/scan`)
 export const checkHashes = (projectId, hashes) => api.post(`/admin/projects/${projectId}/photos/check-hashes`, { hashes })
+export const shiftCaptureTimes = (projectId, data) => api.post(`/admin/projects/${projectId}/photos/capture-time`, data)
+
+// Albums (sections within a project); albumId null takes photos out of their album
+export const getAlbums = (projectId) => api.get(`/admin/projects/${projectId}/albums`)
+export const createAlbum = (projectId, data) => api.post(`/admin/projects/${projectId}/albums`, data)
+export const updateAlbum = (id, data) => api.put(`/admin/albums/${id}`, data)
+export const deleteAlbum = (id) => api.delete(`/admin/albums/${id}`)
+export const assignPhotoAlbum = (projectId, photoIds, albumId) =>
+  api.post(`/admin/projects/${projectId}/photos/album`, { photo_ids: photoIds, album_id: albumId })
+
+// Chunked uploads: large files are appended in chunks and resume from the server's offset
+export const createUploadSession = (projectId, data) => api.post(`/admin/projects/${projectId}/photos/chunks`, data)
+export const getUploadSession = (projectId, id) => api.get(`/admin/projects/${projectId}/photos/chunks/${id}`)
+export const uploadChunk = (projectId, id, offset, chunk, options = {}) =>
+  api.patch(`/admin/projects/${projectId}/photos/chunks/${id}`, chunk, {
+    params: { offset },
+    headers: { 'Content-Type': 'application/octet-stream' },
+    timeout: 0,
+    ...options
+  })
+export const completeUploadSession = (projectId, id) => api.post(`/admin/projects/${projectId}/photos/chunks/${id}/complete`, null, { timeout: 0 })
 
 // Share links
+export const getComments = (params = {}) => api.get('/admin/comments', { params })
+export const moderateComment = (id, hidden) => api.patch(`/admin/comments/${id}`, { hidden })
+export const deleteComment = (id) => api.delete(`/admin/comments/${id}`)
 export const getShareLinks = (projectId) => api.get(`/admin/projects/${projectId}/links`)
 export const createShareLink = (projectId, data) => api.post(`/admin/projects/${projectId}/links`, data)
-export const updateShareLink = (id, data) => api.put(`/admin/links/${id}`, data)
-export const deleteShareLink = (id) => api.delete(`/admin/links/${id}`)
+export const updateShareLink = (id, data, version) => api.put(`/admin/links/${id}`, data, ifMatch(version))
+export const patchShareLinkExclusions = (id, add, remove, reason = '', note = '', version) =>
+  api.patch(`/admin/links/${id}/exclusions`, { add, remove, reason, note }, ifMatch(version))
+export const getLinkPhotos = (id, params = {}) => api.get(`/admin/links/${id}/photos`, { params })
+export const setLinkPhotoIncluded = (id, photoId, included, reason = '', note = '', version) =>
+  api.patch(`/admin/links/${id}/photos/${photoId}`, { included, reason, note }, ifMatch(version))
+export const deleteShareLink = (id, deleteFeedback = false) =>
+  api.delete(`/admin/links/${id}`, deleteFeedback ? { params: { delete_feedback: true } } : {})
+export const regenerateSharePassword = (id) => api.post(`/admin/links/${id}/password/regenerate`)
+export const cloneShareLink = (id, data = {}) => api.post(`/admin/links/${id}/clone`, data)
+export const createShareAccessToken = (id, ttlMinutes) =>
+  api.post(`/admin/links/${id}/access-token`, ttlMinutes ? { ttl_minutes: ttlMinutes } : {})
+// format: '' for JSON, 'csv' or 'txt' (file name list) for an export file
+export const getLinkSelections = (id, format = '') =>
+  api.get(`/admin/links/${id}/selections`, format ? { params: { format }, responseType: 'blob' } : {})
+export const getLinkStats = (id) => api.get(`/admin/links/${id}/stats`)
+// data: { to: [], password, message, locale }; the password must be the link's current one
+export const sendGalleryEmail = (id, data) => api.post(`/admin/links/${id}/email`, data)
+// params: { format: 'png' | 'svg', size, password }; the password must be the link's current one
+// POSTed so the password stays out of URLs and request logs
+export const getLinkQRCode = (id, body = {}) =>
+  api.post(`/admin/links/${id}/qrcode`, body, { responseType: 'blob' })
 
 // Public share
 export const getShareInfo = (token) => api.get(`/share/${token}`)
-export const getSharePhotos = (token) => api.get(`/share/${token}/photos`)
+export const getSharePhotos = (token, params = {}) => api.get(`/share/${token}/photos`, { params })
 export const getPhotoExif = (token, photoId) => api.get(`/share/${token}/photo/${photoId}/exif`)
+export const getPhotoComments = (token, photoId) => api.get(`/share/${token}/photo/${photoId}/comments`)
+export const postPhotoComment = (token, photoId, body, name = '') =>
+  api.post(`/share/${token}/photo/${photoId}/comments`, { body, name })
 export const verifySharePassword = (token, password) =>
   api.post(`/share/${token}/verify-password`, { password })
+export const getShareSelections = (token) => api.get(`/share/${token}/selections`)
+export const updateShareSelections = (token, add = [], remove = []) =>
+  api.post(`/share/${token}/selections`, { add, remove })
+export const submitShareSelections = (token, note = '') =>
+  api.post(`/share/${token}/selections/submit`, { note })
+export const downloadShareSelection = (token, photoIds, type = 'normal') =>
+  api.post(`/share/${token}/download`, { photo_ids: photoIds, type }, { responseType: 'blob' })
+export const getShareDownloadParts = (token, type = 'normal') =>
+  api.get(`/share/${token}/download/parts`, { params: { type } })
+export const createShareDownloadJob = (token, type = 'normal', part = 0) =>
+  api.post(`/share/${token}/download-jobs`, { type, part })
+export const getShareDownloadJob = (token, jobId) => api.get(`/share/${token}/download-jobs/${jobId}`)
+
+// Admin photo detail (files, hashes, EXIF summary, thumbnail state, link references)
+export const getAdminPhotoDetail = (photoId) => api.get(`/admin/photos/${photoId}`)
 
 // Admin EXIF and files
 export const getAdminPhotoExif = (photoId) => api.get(`/admin/photos/${photoId}/exif`)
 export const getAdminPhotoFiles = (photoId) => api.get(`/admin/photos/${photoId}/files`)
+export const getPhotoAccessLog = (photoId, limit = 100) => api.get(`/admin/photos/${photoId}/access-log`, { params: { limit } })
 
 // Get base URL for uploads/downloads (without /api suffix)
 // This is used for file paths like /uploads/... or /api/share/.../download
@@ -86,15 +241,57 @@ export const getUploadUrl = () => {
   return baseUrl
 }
 
-// Thumbnail URLs (share routes don't need auth)
+// Image proxy URL: size is small, large (web-size preview) or original; format is
+// auto, jpeg or raw (originals only). With a share token the image is fetched through
+// the link (no auth header needed), otherwise it is an admin request.
 // cdnBaseUrl: optional CDN base URL (from backend cdn_base_url field)
-export const getShareThumbSmallUrl = (token, photoId, cdnBaseUrl = '') => {
+export const getImageUrl = (photoId, { size = 'large', format = '', token = '', cdnBaseUrl = '' } = {}) => {
+  const params = new URLSearchParams()
+  if (token) params.set('share', token)
+  params.set('size', size)
+  if (format) params.set('format', format)
   const baseUrl = cdnBaseUrl || getUploadUrl()
-  return `${baseUrl}/api/share/${token}/photo/${photoId}/thumb/small`
+  return `${baseUrl}/api/image/${photoId}?${params}`
 }
-export const getShareThumbLargeUrl = (token, photoId, cdnBaseUrl = '') => {
-  const baseUrl = cdnBaseUrl || getUploadUrl()
-  return `${baseUrl}/api/share/${token}/photo/${photoId}/thumb/large`
+
+// Thumbnail URLs (share routes don't need auth)
+export const getShareThumbSmallUrl = (token, photoId, cdnBaseUrl = '') =>
+  getImageUrl(photoId, { size: 'small', token, cdnBaseUrl })
+export const getShareThumbLargeUrl = (token, photoId, cdnBaseUrl = '') =>
+  getImageUrl(photoId, { size: 'large', token, cdnBaseUrl })
+
+// Upload and thumbnail progress of a project as server-sent events, passed to
+// onEvent(type, data). EventSource can't send the Authorization header, so the stream
+// is read with fetch. Resolves when the server ends the stream.
+export async function streamProjectEvents(projectId, onEvent, signal) {
+  const url = `${api.defaults.baseURL}/admin/projects/${projectId}/events`
+  const open = (token) => fetch(url, { headers: { Authorization: `Bearer ${token}` }, signal })
+  let res = await open(localStorage.getItem('token'))
+  if (res.status === 401 && localStorage.getItem('refresh_token')) {
+    res = await open(await refreshAccessToken())
+  }
+  if (!res.ok) throw new Error(`HTTP ${res.status}`)
+
+  const reader = res.body.pipeThrough(new TextDecoderStream()).getReader()
+  let buffer = ''
+  for (;;) {
+    const { value, done } = await reader.read()
+    if (done) return
+    buffer += value
+    let end
+    while ((end = buffer.indexOf('\n\n')) >= 0) {
+      const block = buffer.slice(0, end)
+      buffer = buffer.slice(end + 2)
+      let type = 'message'
+      let data = ''
+      for (const line of block.split('\n')) {
+        if (line.startsWith('event:')) type = line.slice(6).trim()
+        else if (line.startsWith('data:')) data += line.slice(5).trim()
+      }
+      // Lines starting with ":" are heartbeats
+      if (data) onEvent(type, JSON.parse(data))
+    }
+  }
 }
 
 // Admin thumbnail fetchers - return blob URLs with auth
@@ -106,7 +303,8 @@ export const fetchAdminThumbSmall = async (photoId) => {
     return thumbCache.get(cacheKey)
   }
   try {
-    const response = await api.get(`/admin/photos/${photoId}/thumb/small`, {
+    const response = await api.get(`/image/${photoId}`, {
+      params: { size: 'small' },
       responseType: 'blob'
     })
     const blobUrl = URL.createObjectURL(response.data)
@@ -124,7 +322,8 @@ export const fetchAdminThumbLarge = async (photoId) => {
     return thumbCache.get(cacheKey)
   }
   try {
-    const response = await api.get(`/admin/photos/${photoId}/thumb/large`, {
+    const response = await api.get(`/image/${photoId}`, {
+      params: { size: 'large' },
       responseType: 'blob'
     })
     const blobUrl = URL.createObjectURL(response.data)
@@ -135,6 +334,17 @@ export const fetchAdminThumbLarge = async (photoId) => {
   }
 }
 
+// Drop the cached thumbnails of a photo, e.g. once they were (re)generated
+export const forgetAdminThumbs = (photoId) => {
+  for (const size of ['small', 'large']) {
+    const cacheKey = `${size}-${photoId}`
+    if (thumbCache.has(cacheKey)) {
+      URL.revokeObjectURL(thumbCache.get(cacheKey))
+      thumbCache.delete(cacheKey)
+    }
+  }
+}
+
 // Clear thumbnail cache (call when logging out or when photos change)
 export const clearThumbCache = () => {
   for (const url of thumbCache.values()) {
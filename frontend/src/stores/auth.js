@@ -1,22 +1,30 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
-import { login as apiLogin } from '../api'
+import { login as apiLogin, logout as apiLogout } from '../api'
 
 export const useAuthStore = defineStore('auth', () => {
   const token = ref(localStorage.getItem('token') || null)
 
   const isAuthenticated = computed(() => !!token.value)
 
-  async function login(username, password) {
-    const response = await apiLogin(username, password)
+  async function login(username, password, otp) {
+    const response = await apiLogin(username, password, otp)
     token.value = response.data.token
     localStorage.setItem('token', response.data.token)
+    localStorage.setItem('refresh_token', response.data.refresh_token)
     return response
   }
 
   function logout() {
+    // Revoke the session server-side; the local tokens are dropped either way. The
+    // stored access token is the latest one, the ref may predate a refresh.
+    const accessToken = localStorage.getItem('token') || token.value
+    if (accessToken) {
+      apiLogout(accessToken).catch(() => {})
+    }
     token.value = null
     localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
   }
 
   return {
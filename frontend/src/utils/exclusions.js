@@ -0,0 +1,25 @@
+// Exclusion reason categories, matching models.Exclusion* in the backend
+
+export const exclusionReasons = {
+  not_edited: '未精修',
+  duplicate: '重复',
+  client_request: '客户要求',
+  other: '其他'
+}
+
+// Describe one exclusion for tooltips, e.g. "重复：与 IMG_0002 相同"
+export function describeExclusion(exclusion) {
+  if (!exclusion) return ''
+  const label = exclusionReasons[exclusion.reason] || '未注明原因'
+  return exclusion.note ? `${label}：${exclusion.note}` : label
+}
+
+// Count a link's exclusions per reason, e.g. "未精修 3 · 重复 1"
+export function summarizeExclusions(exclusions = []) {
+  const counts = {}
+  for (const e of exclusions) {
+    const label = exclusionReasons[e.reason] || '未注明原因'
+    counts[label] = (counts[label] || 0) + 1
+  }
+  return Object.entries(counts).map(([label, n]) => `${label} ${n}`).join(' · ')
+}
@@ -21,4 +21,4 @@ echo API Key: %API_KEY%
 echo Server Port: %PORT%
 echo.
 
-go run main.go
+go run .